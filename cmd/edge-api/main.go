@@ -27,7 +27,7 @@ func main() {
 	var app = apps.NewAppCmd()
 	app.Version(teaconst.Version)
 	app.Product(teaconst.ProductName)
-	app.Usage(teaconst.ProcessName + " [-h|-v|start|stop|restart|setup|upgrade|service|daemon|issues]")
+	app.Usage(teaconst.ProcessName + " [-h|-v|start|stop|restart|setup|upgrade [--dry-run]|service|daemon|issues]")
 
 	// 短版本号
 	app.On("-V", func() {
@@ -54,13 +54,53 @@ func main() {
 		_, _ = os.Stdout.Write(resultJSON)
 	})
 	app.On("upgrade", func() {
-		fmt.Println("start ...")
+		var flagSet = flag.NewFlagSet("upgrade", flag.ExitOnError)
+		var dryRun = false
+		flagSet.BoolVar(&dryRun, "dry-run", false, "print planned changes without applying them")
+		_ = flagSet.Parse(os.Args[2:])
+
 		executor, err := setup.NewSQLExecutorFromCmd()
 		if err != nil {
 			fmt.Println("ERROR: " + err.Error())
 			return
 		}
+
+		// 只打印迁移计划
+		if dryRun {
+			executor.SetDryRun(true)
+			err = executor.Run(false)
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				return
+			}
+			var plan = executor.Plan()
+			if plan == nil || plan.IsEmpty() {
+				fmt.Println("-- nothing to upgrade")
+				return
+			}
+			fmt.Print(plan.ForwardScript())
+			if plan.HasDestructive() {
+				fmt.Println()
+				fmt.Print(plan.ReverseScript())
+			}
+			return
+		}
+
+		fmt.Println("start ...")
 		err = executor.Run(true)
+
+		// 保存回滚脚本
+		var plan = executor.Plan()
+		if plan != nil && plan.HasDestructive() && len(executor.BatchId()) > 0 {
+			var rollbackFile = Tea.LogFile("upgrade-rollback-" + executor.BatchId() + ".sql")
+			writeErr := os.WriteFile(rollbackFile, []byte(plan.ReverseScript()), 0666)
+			if writeErr != nil {
+				fmt.Println("WARNING: write rollback script failed: " + writeErr.Error())
+			} else {
+				fmt.Println("rollback script: " + rollbackFile)
+			}
+		}
+
 		if err != nil {
 			fmt.Println("ERROR: " + err.Error())
 			return
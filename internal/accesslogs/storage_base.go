@@ -0,0 +1,50 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"strings"
+	"time"
+)
+
+// BaseStorage 存储基础
+type BaseStorage struct {
+}
+
+// Marshal 将日志转换为JSON
+func (this *BaseStorage) Marshal(accessLog *pb.HTTPAccessLog) ([]byte, error) {
+	return json.Marshal(accessLog)
+}
+
+// FormatVariables 格式化字符串中的时间变量
+// 支持：${year}、${month}、${week}、${day}、${hour}、${minute}、${second}、${date}
+func (this *BaseStorage) FormatVariables(s string, t time.Time) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+
+	_, week := t.ISOWeek()
+	var replacer = strings.NewReplacer(
+		"${year}", timeutil.Format("Y", t),
+		"${month}", timeutil.Format("m", t),
+		"${week}", fmt.Sprintf("%02d", week),
+		"${day}", timeutil.Format("d", t),
+		"${hour}", timeutil.Format("H", t),
+		"${minute}", timeutil.Format("i", t),
+		"${second}", timeutil.Format("s", t),
+		"${date}", timeutil.Format("Ymd", t),
+	)
+	return replacer.Replace(s)
+}
+
+// 解码选项
+func decodeOptions(options []byte, ptr any) error {
+	if len(options) == 0 {
+		return nil
+	}
+	return json.Unmarshal(options, ptr)
+}
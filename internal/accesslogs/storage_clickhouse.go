@@ -0,0 +1,134 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var clickHouseNameReg = regexp.MustCompile(`^[\w.]+$`)
+
+// ClickHouseStorageOptions ClickHouse存储选项
+type ClickHouseStorageOptions struct {
+	Endpoint string `json:"endpoint"` // HTTP接口地址，比如 http://127.0.0.1:8123
+	Database string `json:"database"` // 数据库名
+	Table    string `json:"table"`    // 表名
+	Username string `json:"username"` // 用户名
+	Password string `json:"password"` // 密码
+}
+
+// ClickHouseStorage ClickHouse存储
+// 表中可以只包含以下字段的一部分：
+// timestamp, day, requestId, nodeId, serverId, status, remoteAddr, host,
+// firewallPolicyId, firewallRuleGroupId, firewallRuleSetId, firewallRuleId, content
+type ClickHouseStorage struct {
+	BaseStorage
+
+	options *ClickHouseStorageOptions
+	query   string
+}
+
+// Init 初始化
+func (this *ClickHouseStorage) Init(options []byte) error {
+	this.options = &ClickHouseStorageOptions{}
+	err := decodeOptions(options, this.options)
+	if err != nil {
+		return err
+	}
+
+	if len(this.options.Endpoint) == 0 {
+		return errors.New("'endpoint' should not be empty")
+	}
+	if !strings.HasPrefix(this.options.Endpoint, "http://") && !strings.HasPrefix(this.options.Endpoint, "https://") {
+		this.options.Endpoint = "http://" + this.options.Endpoint
+	}
+	this.options.Endpoint = strings.TrimRight(this.options.Endpoint, "/")
+
+	if len(this.options.Table) == 0 {
+		return errors.New("'table' should not be empty")
+	}
+	if !clickHouseNameReg.MatchString(this.options.Table) {
+		return errors.New("invalid table name '" + this.options.Table + "'")
+	}
+	var table = this.options.Table
+	if len(this.options.Database) > 0 {
+		if !clickHouseNameReg.MatchString(this.options.Database) {
+			return errors.New("invalid database name '" + this.options.Database + "'")
+		}
+		table = this.options.Database + "." + table
+	}
+	this.query = "INSERT INTO " + table + " FORMAT JSONEachRow"
+
+	return nil
+}
+
+// Start 启动
+func (this *ClickHouseStorage) Start() error {
+	return nil
+}
+
+// Write 写入日志
+func (this *ClickHouseStorage) Write(accessLogs []*pb.HTTPAccessLog) error {
+	if len(accessLogs) == 0 {
+		return nil
+	}
+
+	var buf = &bytes.Buffer{}
+	for _, accessLog := range accessLogs {
+		content, err := this.Marshal(accessLog)
+		if err != nil {
+			return err
+		}
+		rowJSON, err := json.Marshal(map[string]any{
+			"timestamp":           accessLog.Timestamp,
+			"day":                 time.Unix(accessLog.Timestamp, 0).Format("20060102"),
+			"requestId":           accessLog.RequestId,
+			"nodeId":              accessLog.NodeId,
+			"serverId":            accessLog.ServerId,
+			"status":              accessLog.Status,
+			"remoteAddr":          accessLog.RemoteAddr,
+			"host":                accessLog.Host,
+			"firewallPolicyId":    accessLog.FirewallPolicyId,
+			"firewallRuleGroupId": accessLog.FirewallRuleGroupId,
+			"firewallRuleSetId":   accessLog.FirewallRuleSetId,
+			"firewallRuleId":      accessLog.FirewallRuleId,
+			"content":             string(content),
+		})
+		if err != nil {
+			return err
+		}
+		buf.Write(rowJSON)
+		buf.WriteByte('\n')
+	}
+
+	var query = url.Values{}
+	query.Set("query", this.query)
+	query.Set("input_format_skip_unknown_fields", "1")
+	req, err := http.NewRequest(http.MethodPost, this.options.Endpoint+"/?"+query.Encode(), buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if len(this.options.Username) > 0 {
+		req.Header.Set("X-ClickHouse-User", this.options.Username)
+	}
+	if len(this.options.Password) > 0 {
+		req.Header.Set("X-ClickHouse-Key", this.options.Password)
+	}
+
+	_, err = doHTTPRequest(req, 30*time.Second)
+	return err
+}
+
+// Close 关闭
+func (this *ClickHouseStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ESStorageOptions Elasticsearch存储选项
+type ESStorageOptions struct {
+	Endpoint     string `json:"endpoint"`     // 服务地址，比如 http://127.0.0.1:9200
+	Index        string `json:"index"`        // 索引名称，支持时间变量
+	MappingType  string `json:"mappingType"`  // 文档类型，仅用于老版本的Elasticsearch
+	Username     string `json:"username"`     // 用户名
+	Password     string `json:"password"`     // 密码
+	IsDataStream bool   `json:"isDataStream"` // 是否为数据流
+}
+
+// ESStorage Elasticsearch存储
+type ESStorage struct {
+	BaseStorage
+
+	options *ESStorageOptions
+}
+
+// Init 初始化
+func (this *ESStorage) Init(options []byte) error {
+	this.options = &ESStorageOptions{}
+	err := decodeOptions(options, this.options)
+	if err != nil {
+		return err
+	}
+
+	if len(this.options.Endpoint) == 0 {
+		return errors.New("'endpoint' should not be empty")
+	}
+	if !strings.HasPrefix(this.options.Endpoint, "http://") && !strings.HasPrefix(this.options.Endpoint, "https://") {
+		this.options.Endpoint = "http://" + this.options.Endpoint
+	}
+	this.options.Endpoint = strings.TrimRight(this.options.Endpoint, "/")
+
+	if len(this.options.Index) == 0 {
+		return errors.New("'index' should not be empty")
+	}
+	return nil
+}
+
+// Start 启动
+func (this *ESStorage) Start() error {
+	return nil
+}
+
+// Write 写入日志
+func (this *ESStorage) Write(accessLogs []*pb.HTTPAccessLog) error {
+	if len(accessLogs) == 0 {
+		return nil
+	}
+
+	var action = "index"
+	if this.options.IsDataStream {
+		action = "create"
+	}
+
+	var buf = &bytes.Buffer{}
+	for _, accessLog := range accessLogs {
+		var meta = map[string]any{
+			"_index": this.FormatVariables(this.options.Index, time.Unix(accessLog.Timestamp, 0)),
+		}
+		if len(this.options.MappingType) > 0 {
+			meta["_type"] = this.options.MappingType
+		}
+		metaJSON, err := json.Marshal(map[string]any{
+			action: meta,
+		})
+		if err != nil {
+			return err
+		}
+		data, err := this.Marshal(accessLog)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(metaJSON)
+		buf.WriteByte('\n')
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	req, err := http.NewRequest(http.MethodPost, this.options.Endpoint+"/_bulk", buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if len(this.options.Username) > 0 || len(this.options.Password) > 0 {
+		req.SetBasicAuth(this.options.Username, this.options.Password)
+	}
+
+	respData, err := doHTTPRequest(req, 30*time.Second)
+	if err != nil {
+		return err
+	}
+
+	return this.checkBulkResponse(respData, accessLogs)
+}
+
+// Close 关闭
+func (this *ESStorage) Close() error {
+	return nil
+}
+
+// 检查Bulk API的返回结果
+// 返回结果中的条目和提交的日志一一对应，只有部分失败时返回 *PartialWriteError
+func (this *ESStorage) checkBulkResponse(data []byte, accessLogs []*pb.HTTPAccessLog) error {
+	var resp = &struct {
+		Errors bool                             `json:"errors"`
+		Items  []map[string]*esBulkResponseItem `json:"items"`
+	}{}
+	err := json.Unmarshal(data, resp)
+	if err != nil {
+		return errors.New("decode response failed: " + err.Error())
+	}
+	if !resp.Errors {
+		return nil
+	}
+
+	var failedLogs = []*pb.HTTPAccessLog{}
+	var firstErr error
+	for index, item := range resp.Items {
+		for _, result := range item {
+			if result != nil && result.Error != nil {
+				if firstErr == nil {
+					firstErr = errors.New("bulk write failed: " + result.Error.Type + ": " + result.Error.Reason)
+				}
+				if index < len(accessLogs) {
+					failedLogs = append(failedLogs, accessLogs[index])
+				}
+			}
+		}
+	}
+	if firstErr == nil {
+		return errors.New("bulk write failed")
+	}
+	if len(resp.Items) != len(accessLogs) {
+		// 无法对应到具体的日志，整批重试
+		return firstErr
+	}
+	return NewPartialWriteError(failedLogs, firstErr)
+}
+
+type esBulkResponseItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/accesslogs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestESStorage_Write(t *testing.T) {
+	var body []byte
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/_bulk" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ = io.ReadAll(req.Body)
+		_, _ = writer.Write([]byte(`{"took": 1, "errors": false, "items": []}`))
+	}))
+	defer server.Close()
+
+	var storage = &accesslogs.ESStorage{}
+	err := storage.Init([]byte(`{"endpoint": "` + server.URL + `", "index": "edge-${date}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.Write([]*pb.HTTPAccessLog{
+		{RequestId: "1", Timestamp: time.Now().Unix()},
+		{RequestId: "2", Timestamp: time.Now().Unix()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(body, []byte{'\n'}) != 4 {
+		t.Fatal("invalid bulk body:", string(body))
+	}
+	if !bytes.Contains(body, []byte(`"_index":"edge-`+time.Now().Format("20060102")+`"`)) {
+		t.Fatal("invalid index:", string(body))
+	}
+}
+
+func TestESStorage_Write_Error(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		_, _ = writer.Write([]byte(`{"errors": true, "items": [{"index": {"status": 400, "error": {"type": "mapper_parsing_exception", "reason": "failed to parse"}}}]}`))
+	}))
+	defer server.Close()
+
+	var storage = &accesslogs.ESStorage{}
+	err := storage.Init([]byte(`{"endpoint": "` + server.URL + `", "index": "edge"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Write([]*pb.HTTPAccessLog{{RequestId: "1"}})
+	if err == nil {
+		t.Fatal("should return error")
+	}
+	t.Log(err)
+}
+
+func TestESStorage_Write_PartialError(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		_, _ = writer.Write([]byte(`{"errors": true, "items": [{"index": {"status": 201}}, {"index": {"status": 429, "error": {"type": "es_rejected_execution_exception", "reason": "rejected"}}}]}`))
+	}))
+	defer server.Close()
+
+	var storage = &accesslogs.ESStorage{}
+	err := storage.Init([]byte(`{"endpoint": "` + server.URL + `", "index": "edge"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Write([]*pb.HTTPAccessLog{{RequestId: "1"}, {RequestId: "2"}})
+	var partialErr *accesslogs.PartialWriteError
+	if !errors.As(err, &partialErr) {
+		t.Fatal("should return partial write error, but got", err)
+	}
+	if len(partialErr.FailedLogs) != 1 || partialErr.FailedLogs[0].RequestId != "2" {
+		t.Fatal("invalid failed logs")
+	}
+	t.Log(err)
+}
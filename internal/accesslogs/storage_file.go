@@ -0,0 +1,210 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sizes"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FileStorageOptions 文件存储选项
+type FileStorageOptions struct {
+	Path       string `json:"path"`       // 文件路径，支持时间变量
+	AutoCreate bool   `json:"autoCreate"` // 是否自动创建目录
+	MaxSizeMB  int64  `json:"maxSizeMB"`  // 单个文件最大尺寸，超出后轮转，0表示不限制
+	MaxBackups int    `json:"maxBackups"` // 轮转后最多保留的文件数量
+}
+
+// FileStorage 文件存储
+// 每条日志为一行JSON
+type FileStorage struct {
+	BaseStorage
+
+	options *FileStorageOptions
+
+	writer     *os.File
+	writerPath string
+	writerSize int64
+
+	locker sync.Mutex
+}
+
+// Init 初始化
+func (this *FileStorage) Init(options []byte) error {
+	this.options = &FileStorageOptions{}
+	err := decodeOptions(options, this.options)
+	if err != nil {
+		return err
+	}
+	if len(this.options.Path) == 0 {
+		return errors.New("'path' should not be empty")
+	}
+	if this.options.MaxBackups <= 0 {
+		this.options.MaxBackups = 7
+	}
+	return nil
+}
+
+// Start 启动
+func (this *FileStorage) Start() error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	_, err := this.openWriter(time.Now())
+	return err
+}
+
+// Write 写入日志
+func (this *FileStorage) Write(accessLogs []*pb.HTTPAccessLog) error {
+	if len(accessLogs) == 0 {
+		return nil
+	}
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	writer, err := this.openWriter(time.Now())
+	if err != nil {
+		return err
+	}
+
+	var buf = []byte{}
+	var lineEnds = make([]int, 0, len(accessLogs)) // 每条日志在buf中的结束位置
+	for _, accessLog := range accessLogs {
+		data, err := this.Marshal(accessLog)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+		lineEnds = append(lineEnds, len(buf))
+	}
+
+	// 检查是否需要轮转
+	var maxSize = this.options.MaxSizeMB * sizes.M
+	if maxSize > 0 && this.writerSize > 0 && this.writerSize+int64(len(buf)) > maxSize {
+		err = this.rotate()
+		if err != nil {
+			return err
+		}
+		writer, err = this.openWriter(time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	var oldSize = this.writerSize
+	n, err := writer.Write(buf)
+	if err == nil {
+		this.writerSize += int64(n)
+		return nil
+	}
+
+	// 查找最后一条完整写入的日志
+	var lastEnd = 0
+	var failedIndex = 0
+	for index, end := range lineEnds {
+		if end > n {
+			failedIndex = index
+			break
+		}
+		lastEnd = end
+	}
+
+	// 截掉写入了一半的日志，防止重试时和下一条日志连在同一行
+	this.writerSize = oldSize + int64(lastEnd)
+	if n > lastEnd {
+		truncateErr := writer.Truncate(this.writerSize)
+		if truncateErr != nil {
+			// 无法截断时关闭文件，下次写入时重新打开
+			_ = this.closeWriter()
+			err = errors.New(err.Error() + ", truncate file failed: " + truncateErr.Error())
+		}
+	}
+
+	// 只重试没有完整写入的日志
+	if lastEnd > 0 {
+		return NewPartialWriteError(accessLogs[failedIndex:], err)
+	}
+	return err
+}
+
+// Close 关闭
+func (this *FileStorage) Close() error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	return this.closeWriter()
+}
+
+// 打开当前时间对应的文件
+func (this *FileStorage) openWriter(t time.Time) (*os.File, error) {
+	var path = this.FormatVariables(this.options.Path, t)
+	if this.writer != nil && this.writerPath == path {
+		return this.writer, nil
+	}
+
+	_ = this.closeWriter()
+
+	if this.options.AutoCreate {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fp, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := fp.Stat()
+	if err != nil {
+		_ = fp.Close()
+		return nil, err
+	}
+
+	this.writer = fp
+	this.writerPath = path
+	this.writerSize = stat.Size()
+	return fp, nil
+}
+
+func (this *FileStorage) closeWriter() error {
+	if this.writer == nil {
+		return nil
+	}
+	var err = this.writer.Close()
+	this.writer = nil
+	this.writerPath = ""
+	this.writerSize = 0
+	return err
+}
+
+// 轮转文件：path -> path.1 -> path.2 ...
+func (this *FileStorage) rotate() error {
+	var path = this.writerPath
+	err := this.closeWriter()
+	if err != nil {
+		return err
+	}
+
+	_ = os.Remove(path + "." + strconv.Itoa(this.options.MaxBackups))
+	for i := this.options.MaxBackups - 1; i >= 1; i-- {
+		var oldPath = path + "." + strconv.Itoa(i)
+		_, statErr := os.Stat(oldPath)
+		if statErr != nil {
+			continue
+		}
+		err = os.Rename(oldPath, path+"."+strconv.Itoa(i+1))
+		if err != nil {
+			return err
+		}
+	}
+	return os.Rename(path, path+".1")
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs_test
+
+import (
+	"bytes"
+	"github.com/TeaOSLab/EdgeAPI/internal/accesslogs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileStorage_Write(t *testing.T) {
+	var dir = t.TempDir()
+	var storage = &accesslogs.FileStorage{}
+	err := storage.Init([]byte(`{"path": "` + dir + `/logs/access-${date}.log", "autoCreate": true, "maxSizeMB": 1, "maxBackups": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = storage.Close()
+	}()
+
+	err = storage.Write([]*pb.HTTPAccessLog{
+		{RequestId: "1", Timestamp: time.Now().Unix()},
+		{RequestId: "2", Timestamp: time.Now().Unix()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var path = dir + "/logs/access-" + time.Now().Format("20060102") + ".log"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(data, []byte{'\n'}) != 2 {
+		t.Fatal("should contain 2 lines, but got:", string(data))
+	}
+
+	// 轮转
+	var accessLog = &pb.HTTPAccessLog{RequestId: string(bytes.Repeat([]byte{'a'}, 1<<20)), Timestamp: time.Now().Unix()}
+	err = storage.Write([]*pb.HTTPAccessLog{accessLog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(path + ".1")
+	if err != nil {
+		t.Fatal("should be rotated:", err)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxHTTPResponseSize = 1 << 20
+
+// 发送HTTP请求，并返回响应内容
+func doHTTPRequest(req *http.Request, timeout time.Duration) ([]byte, error) {
+	resp, err := utils.SharedHttpClient(timeout).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var message = string(data)
+		if len(message) > 256 {
+			message = message[:256]
+		}
+		return data, errors.New("invalid response status code '" + strconv.Itoa(resp.StatusCode) + "': " + message)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import "github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+
+// StorageInterface 访问日志存储接口
+type StorageInterface interface {
+	// Init 使用选项初始化
+	Init(options []byte) error
+
+	// Start 启动
+	Start() error
+
+	// Write 写入日志
+	// 如果只有部分日志写入失败，应该返回 *PartialWriteError，以便只重试失败的日志
+	Write(accessLogs []*pb.HTTPAccessLog) error
+
+	// Close 关闭
+	Close() error
+}
+
+// PartialWriteError 部分日志写入失败
+type PartialWriteError struct {
+	FailedLogs []*pb.HTTPAccessLog // 写入失败的日志
+	Err        error               // 失败原因
+}
+
+// NewPartialWriteError 获取新对象
+func NewPartialWriteError(failedLogs []*pb.HTTPAccessLog, err error) *PartialWriteError {
+	return &PartialWriteError{
+		FailedLogs: failedLogs,
+		Err:        err,
+	}
+}
+
+func (this *PartialWriteError) Error() string {
+	return this.Err.Error()
+}
+
+func (this *PartialWriteError) Unwrap() error {
+	return this.Err
+}
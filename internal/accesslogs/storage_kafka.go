@@ -0,0 +1,137 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KafkaStorageOptions Kafka存储选项
+type KafkaStorageOptions struct {
+	Endpoint string `json:"endpoint"` // REST Proxy地址，比如 http://127.0.0.1:8082
+	Topic    string `json:"topic"`    // 主题
+	Username string `json:"username"` // 用户名
+	Password string `json:"password"` // 密码
+}
+
+// KafkaStorage 通过兼容Kafka REST Proxy（v2）的接口写入日志
+type KafkaStorage struct {
+	BaseStorage
+
+	options *KafkaStorageOptions
+}
+
+// Init 初始化
+func (this *KafkaStorage) Init(options []byte) error {
+	this.options = &KafkaStorageOptions{}
+	err := decodeOptions(options, this.options)
+	if err != nil {
+		return err
+	}
+
+	if len(this.options.Endpoint) == 0 {
+		return errors.New("'endpoint' should not be empty")
+	}
+	if !strings.HasPrefix(this.options.Endpoint, "http://") && !strings.HasPrefix(this.options.Endpoint, "https://") {
+		this.options.Endpoint = "http://" + this.options.Endpoint
+	}
+	this.options.Endpoint = strings.TrimRight(this.options.Endpoint, "/")
+
+	if len(this.options.Topic) == 0 {
+		return errors.New("'topic' should not be empty")
+	}
+	return nil
+}
+
+// Start 启动
+func (this *KafkaStorage) Start() error {
+	return nil
+}
+
+// Write 写入日志
+func (this *KafkaStorage) Write(accessLogs []*pb.HTTPAccessLog) error {
+	if len(accessLogs) == 0 {
+		return nil
+	}
+
+	type record struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}
+	var records = []*record{}
+	for _, accessLog := range accessLogs {
+		data, err := this.Marshal(accessLog)
+		if err != nil {
+			return err
+		}
+		records = append(records, &record{
+			Key:   accessLog.RequestId,
+			Value: data,
+		})
+	}
+	body, err := json.Marshal(map[string]any{
+		"records": records,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, this.options.Endpoint+"/topics/"+url.PathEscape(this.options.Topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if len(this.options.Username) > 0 || len(this.options.Password) > 0 {
+		req.SetBasicAuth(this.options.Username, this.options.Password)
+	}
+
+	respData, err := doHTTPRequest(req, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	return this.checkResponse(respData, accessLogs)
+}
+
+// Close 关闭
+func (this *KafkaStorage) Close() error {
+	return nil
+}
+
+// 检查返回结果，其中的offsets和提交的记录一一对应
+func (this *KafkaStorage) checkResponse(data []byte, accessLogs []*pb.HTTPAccessLog) error {
+	var resp = &struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}{}
+	err := json.Unmarshal(data, resp)
+	if err != nil || len(resp.Offsets) != len(accessLogs) {
+		// 无法识别的返回结果，认为全部成功
+		return nil
+	}
+
+	var failedLogs = []*pb.HTTPAccessLog{}
+	var firstErr error
+	for index, offset := range resp.Offsets {
+		if offset.ErrorCode == nil && len(offset.Error) == 0 {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = errors.New("write records failed: " + offset.Error)
+		}
+		failedLogs = append(failedLogs, accessLogs[index])
+	}
+	if firstErr == nil {
+		return nil
+	}
+	return NewPartialWriteError(failedLogs, firstErr)
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/types"
+	"sync"
+	"time"
+)
+
+var SharedStorageManager = NewStorageManager()
+
+// StorageManager 访问日志存储策略管理
+type StorageManager struct {
+	writerMap map[int64]*StorageWriter // policyId => *StorageWriter
+
+	disableDefaultDB bool
+
+	locker sync.RWMutex
+}
+
+// NewStorageManager 获取新对象
+func NewStorageManager() *StorageManager {
+	return &StorageManager{
+		writerMap: map[int64]*StorageWriter{},
+	}
+}
+
+// Start 启动并定时检查策略变化
+func (this *StorageManager) Start() {
+	var ticker = time.NewTicker(1 * time.Minute)
+	for {
+		err := this.Loop()
+		if err != nil {
+			remotelogs.Error("ACCESS_LOG_STORAGE_MANAGER", err.Error())
+		}
+		<-ticker.C
+	}
+}
+
+// Loop 单次检查
+func (this *StorageManager) Loop() error {
+	policies, err := models.SharedHTTPAccessLogPolicyDAO.FindAllEnabledAndOnPolicies(nil)
+	if err != nil {
+		return err
+	}
+
+	var policyIdMap = map[int64]bool{}
+	var disableDefaultDB bool
+	for _, policy := range policies {
+		var policyId = int64(policy.Id)
+
+		// 只有成功启动的策略才生效
+		if !this.checkPolicy(policy) {
+			continue
+		}
+		policyIdMap[policyId] = true
+
+		// 任一生效的策略停止默认数据库存储时，不再写入数据库
+		if policy.DisableDefaultDB {
+			disableDefaultDB = true
+		}
+	}
+
+	// 关闭已经删除或停用的策略
+	this.locker.Lock()
+	var closingWriters = []*StorageWriter{}
+	for policyId, writer := range this.writerMap {
+		if !policyIdMap[policyId] {
+			delete(this.writerMap, policyId)
+			closingWriters = append(closingWriters, writer)
+		}
+	}
+	this.disableDefaultDB = disableDefaultDB
+	this.locker.Unlock()
+
+	for _, writer := range closingWriters {
+		_ = writer.Close()
+	}
+
+	return nil
+}
+
+// WriteToPolicy 写入日志到某个策略
+func (this *StorageManager) WriteToPolicy(policyId int64, accessLogs []*pb.HTTPAccessLog) bool {
+	this.locker.RLock()
+	writer, ok := this.writerMap[policyId]
+	this.locker.RUnlock()
+	if !ok {
+		return false
+	}
+	return this.writeToWriter(policyId, writer, accessLogs)
+}
+
+// WriteToAllPolicies 写入日志到所有生效的策略
+func (this *StorageManager) WriteToAllPolicies(accessLogs []*pb.HTTPAccessLog) bool {
+	this.locker.RLock()
+	var writerMap = make(map[int64]*StorageWriter, len(this.writerMap))
+	for policyId, writer := range this.writerMap {
+		writerMap[policyId] = writer
+	}
+	this.locker.RUnlock()
+
+	for policyId, writer := range writerMap {
+		this.writeToWriter(policyId, writer, accessLogs)
+	}
+	return len(writerMap) > 0
+}
+
+// HasPolicies 是否有生效的策略
+func (this *StorageManager) HasPolicies() bool {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	return len(this.writerMap) > 0
+}
+
+// DisableDefaultDB 是否停止默认的数据库存储
+func (this *StorageManager) DisableDefaultDB() bool {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+	return len(this.writerMap) > 0 && this.disableDefaultDB
+}
+
+// 写入日志到写入器
+// 如果写入器在此期间因为策略变化而被关闭，则改为写入到策略新的写入器
+func (this *StorageManager) writeToWriter(policyId int64, writer *StorageWriter, accessLogs []*pb.HTTPAccessLog) bool {
+	for {
+		if writer.Write(accessLogs) {
+			return true
+		}
+
+		this.locker.RLock()
+		newWriter, ok := this.writerMap[policyId]
+		this.locker.RUnlock()
+		if !ok || newWriter == writer {
+			// 策略已经删除或停用
+			return false
+		}
+		writer = newWriter
+	}
+}
+
+// 检查单个策略，如果有变化则重新启动
+func (this *StorageManager) checkPolicy(policy *models.HTTPAccessLogPolicy) bool {
+	var policyId = int64(policy.Id)
+	var version = int(policy.Version)
+
+	this.locker.RLock()
+	oldWriter, ok := this.writerMap[policyId]
+	this.locker.RUnlock()
+	if ok && oldWriter.Version() == version {
+		return true
+	}
+
+	writer, err := this.newWriter(policy)
+	if err != nil {
+		remotelogs.Error("ACCESS_LOG_STORAGE_MANAGER", "start policy '"+types.String(policyId)+"' failed: "+err.Error())
+
+		// 新的配置无法启动时，继续使用老的配置
+		return ok
+	}
+
+	this.locker.Lock()
+	this.writerMap[policyId] = writer
+	this.locker.Unlock()
+
+	if oldWriter != nil {
+		_ = oldWriter.Close()
+	}
+	return true
+}
+
+func (this *StorageManager) newWriter(policy *models.HTTPAccessLogPolicy) (*StorageWriter, error) {
+	storage, err := NewStorage(policy.Type)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.Init(policy.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	var bufferOptions = &BufferOptions{}
+	err = decodeOptions(policy.Options, bufferOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var writer = NewStorageWriter(int64(policy.Id), int(policy.Version), policy.FirewallOnly == 1, storage, bufferOptions)
+	err = writer.Start()
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
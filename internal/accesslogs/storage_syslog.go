@@ -0,0 +1,160 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	syslogDefaultPriority = 16*8 + 6 // local0.info
+)
+
+// SyslogStorageOptions Syslog存储选项
+type SyslogStorageOptions struct {
+	Protocol   string `json:"protocol"`   // 协议：udp|tcp|unix|unixgram
+	ServerAddr string `json:"serverAddr"` // 服务地址
+	ServerPort int    `json:"serverPort"` // 服务端口
+	Socket     string `json:"socket"`     // Socket路径，用于unix|unixgram协议
+	Tag        string `json:"tag"`        // 标签
+	Priority   int    `json:"priority"`   // 优先级：facility * 8 + severity，-1表示使用默认值
+}
+
+// SyslogStorage Syslog存储
+// 使用RFC 3164格式发送，每条日志内容为一行JSON
+type SyslogStorage struct {
+	BaseStorage
+
+	options  *SyslogStorageOptions
+	network  string
+	addr     string
+	hostname string
+
+	conn   net.Conn
+	locker sync.Mutex
+}
+
+// Init 初始化
+func (this *SyslogStorage) Init(options []byte) error {
+	this.options = &SyslogStorageOptions{
+		Priority: -1,
+	}
+	err := decodeOptions(options, this.options)
+	if err != nil {
+		return err
+	}
+
+	switch this.options.Protocol {
+	case "", "udp":
+		this.network = "udp"
+	case "tcp":
+		this.network = "tcp"
+	case "unix", "unixgram":
+		this.network = this.options.Protocol
+	default:
+		return errors.New("invalid protocol '" + this.options.Protocol + "'")
+	}
+
+	if this.network == "unix" || this.network == "unixgram" {
+		if len(this.options.Socket) == 0 {
+			return errors.New("'socket' should not be empty")
+		}
+		this.addr = this.options.Socket
+	} else {
+		if len(this.options.ServerAddr) == 0 {
+			return errors.New("'serverAddr' should not be empty")
+		}
+		var port = this.options.ServerPort
+		if port <= 0 {
+			port = 514
+		}
+		this.addr = net.JoinHostPort(this.options.ServerAddr, strconv.Itoa(port))
+	}
+
+	if this.options.Priority < 0 || this.options.Priority > 191 {
+		this.options.Priority = syslogDefaultPriority
+	}
+	if len(this.options.Tag) == 0 {
+		this.options.Tag = "edge-api"
+	}
+
+	this.hostname, _ = os.Hostname()
+	if len(this.hostname) == 0 {
+		this.hostname = "localhost"
+	}
+
+	return nil
+}
+
+// Start 启动
+func (this *SyslogStorage) Start() error {
+	return nil
+}
+
+// Write 写入日志
+func (this *SyslogStorage) Write(accessLogs []*pb.HTTPAccessLog) error {
+	if len(accessLogs) == 0 {
+		return nil
+	}
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.conn == nil {
+		conn, err := net.DialTimeout(this.network, this.addr, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		this.conn = conn
+	}
+
+	for index, accessLog := range accessLogs {
+		data, err := this.Marshal(accessLog)
+		if err != nil {
+			return err
+		}
+
+		_ = this.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		_, err = this.conn.Write(this.format(data, time.Now()))
+		if err != nil {
+			// 下次重新连接
+			_ = this.conn.Close()
+			this.conn = nil
+
+			// 之前的日志已经发送成功
+			return NewPartialWriteError(accessLogs[index:], err)
+		}
+	}
+
+	return nil
+}
+
+// Close 关闭
+func (this *SyslogStorage) Close() error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.conn != nil {
+		var err = this.conn.Close()
+		this.conn = nil
+		return err
+	}
+	return nil
+}
+
+// 格式化为RFC 3164消息
+func (this *SyslogStorage) format(data []byte, t time.Time) []byte {
+	var message = "<" + strconv.Itoa(this.options.Priority) + ">" + t.Format(time.Stamp) + " " + this.hostname + " " + this.options.Tag + ": " + string(data)
+
+	// 基于流的协议需要使用换行符分隔消息
+	if this.network == "tcp" || this.network == "unix" {
+		message += "\n"
+	}
+	return []byte(message)
+}
@@ -0,0 +1,212 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/types"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BufferOptions 缓冲和重试选项，和存储选项放在同一个JSON中
+type BufferOptions struct {
+	BufferSize           int `json:"bufferSize"`           // 缓冲队列长度
+	BatchSize            int `json:"batchSize"`            // 每批写入的条数
+	FlushIntervalSeconds int `json:"flushIntervalSeconds"` // 最长刷新间隔
+	MaxRetries           int `json:"maxRetries"`           // 失败后最多重试次数
+	RetryIntervalSeconds int `json:"retryIntervalSeconds"` // 重试间隔，每次重试后会递增
+}
+
+func (this *BufferOptions) init() {
+	if this.BufferSize <= 0 {
+		this.BufferSize = 10_000
+	}
+	if this.BatchSize <= 0 {
+		this.BatchSize = 1000
+	}
+	if this.BatchSize > this.BufferSize {
+		this.BatchSize = this.BufferSize
+	}
+	if this.FlushIntervalSeconds <= 0 {
+		this.FlushIntervalSeconds = 1
+	}
+	if this.MaxRetries < 0 {
+		this.MaxRetries = 0
+	}
+	if this.RetryIntervalSeconds <= 0 {
+		this.RetryIntervalSeconds = 1
+	}
+}
+
+// StorageWriter 单个策略的日志写入器
+// 日志先放入缓冲队列中，再由后台任务批量写入到存储中
+type StorageWriter struct {
+	policyId     int64
+	version      int
+	firewallOnly bool
+
+	storage StorageInterface
+	options *BufferOptions
+
+	queue    chan *pb.HTTPAccessLog
+	closeCh  chan bool
+	wg       sync.WaitGroup
+	isClosed bool
+	locker   sync.RWMutex
+
+	countDropped int64
+	countFailed  int64
+}
+
+// NewStorageWriter 获取新对象
+func NewStorageWriter(policyId int64, version int, firewallOnly bool, storage StorageInterface, options *BufferOptions) *StorageWriter {
+	if options == nil {
+		options = &BufferOptions{}
+	}
+	options.init()
+
+	return &StorageWriter{
+		policyId:     policyId,
+		version:      version,
+		firewallOnly: firewallOnly,
+		storage:      storage,
+		options:      options,
+		queue:        make(chan *pb.HTTPAccessLog, options.BufferSize),
+		closeCh:      make(chan bool),
+	}
+}
+
+// Start 启动
+func (this *StorageWriter) Start() error {
+	err := this.storage.Start()
+	if err != nil {
+		return err
+	}
+
+	this.wg.Add(1)
+	goman.New(func() {
+		defer this.wg.Done()
+		this.loop()
+	})
+	return nil
+}
+
+// Write 将日志放入缓冲队列，队列满时丢弃
+// 如果写入器已经关闭，则返回false，调用者可以改为写入到新的写入器
+func (this *StorageWriter) Write(accessLogs []*pb.HTTPAccessLog) bool {
+	this.locker.RLock()
+	defer this.locker.RUnlock()
+
+	if this.isClosed {
+		return false
+	}
+
+	for _, accessLog := range accessLogs {
+		if this.firewallOnly && accessLog.FirewallPolicyId <= 0 {
+			continue
+		}
+
+		select {
+		case this.queue <- accessLog:
+		default:
+			atomic.AddInt64(&this.countDropped, 1)
+		}
+	}
+	return true
+}
+
+// Version 策略版本
+func (this *StorageWriter) Version() int {
+	return this.version
+}
+
+// CountDropped 因为队列已满而丢弃的日志数量
+func (this *StorageWriter) CountDropped() int64 {
+	return atomic.LoadInt64(&this.countDropped)
+}
+
+// CountFailed 因为写入失败而丢弃的日志数量
+func (this *StorageWriter) CountFailed() int64 {
+	return atomic.LoadInt64(&this.countFailed)
+}
+
+// Close 关闭，关闭前会尽量写入队列中剩余的日志
+func (this *StorageWriter) Close() error {
+	// 等待正在进行的写入完成，之后不再接收新的日志，保证队列中的日志都能被写入
+	this.locker.Lock()
+	if this.isClosed {
+		this.locker.Unlock()
+		return nil
+	}
+	this.isClosed = true
+	this.locker.Unlock()
+
+	close(this.closeCh)
+	this.wg.Wait()
+	return this.storage.Close()
+}
+
+func (this *StorageWriter) loop() {
+	var ticker = time.NewTicker(time.Duration(this.options.FlushIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	var batch = make([]*pb.HTTPAccessLog, 0, this.options.BatchSize)
+	for {
+		select {
+		case accessLog := <-this.queue:
+			batch = append(batch, accessLog)
+			if len(batch) >= this.options.BatchSize {
+				this.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				this.flush(batch)
+				batch = batch[:0]
+			}
+		case <-this.closeCh:
+			// 写入剩余的日志
+			var countLeft = len(this.queue)
+			for i := 0; i < countLeft; i++ {
+				batch = append(batch, <-this.queue)
+			}
+			if len(batch) > 0 {
+				this.flush(batch)
+			}
+			return
+		}
+	}
+}
+
+// 写入一批日志，失败时重试
+// 如果只有部分日志写入失败，重试时只写入失败的日志，避免重复写入已成功的日志
+func (this *StorageWriter) flush(accessLogs []*pb.HTTPAccessLog) {
+	var err error
+	for i := 0; i <= this.options.MaxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(time.Duration(this.options.RetryIntervalSeconds*i) * time.Second):
+			case <-this.closeCh:
+				// 关闭时不再等待，直接重试
+			}
+		}
+
+		err = this.storage.Write(accessLogs)
+		if err == nil {
+			return
+		}
+
+		var partialErr *PartialWriteError
+		if errors.As(err, &partialErr) && len(partialErr.FailedLogs) > 0 {
+			accessLogs = partialErr.FailedLogs
+		}
+	}
+
+	atomic.AddInt64(&this.countFailed, int64(len(accessLogs)))
+	remotelogs.Error("ACCESS_LOG_STORAGE", "write "+types.String(len(accessLogs))+" access logs to policy '"+types.String(this.policyId)+"' failed: "+err.Error())
+}
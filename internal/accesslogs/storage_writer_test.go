@@ -0,0 +1,148 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs_test
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/accesslogs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/types"
+	"sync"
+	"testing"
+)
+
+type testStorage struct {
+	countFails        int
+	countPartialFails int
+	logs              []*pb.HTTPAccessLog
+	locker            sync.Mutex
+}
+
+func (this *testStorage) Init(options []byte) error {
+	return nil
+}
+
+func (this *testStorage) Start() error {
+	return nil
+}
+
+func (this *testStorage) Write(accessLogs []*pb.HTTPAccessLog) error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.countFails > 0 {
+		this.countFails--
+		return errors.New("test error")
+	}
+
+	// 只写入第一条日志
+	if this.countPartialFails > 0 && len(accessLogs) > 1 {
+		this.countPartialFails--
+		this.logs = append(this.logs, accessLogs[0])
+		return accesslogs.NewPartialWriteError(accessLogs[1:], errors.New("test partial error"))
+	}
+
+	this.logs = append(this.logs, accessLogs...)
+	return nil
+}
+
+func (this *testStorage) Close() error {
+	return nil
+}
+
+func TestStorageWriter_Retry(t *testing.T) {
+	var storage = &testStorage{countFails: 1}
+	var writer = accesslogs.NewStorageWriter(1, 1, false, storage, &accesslogs.BufferOptions{
+		BatchSize:  2,
+		MaxRetries: 2,
+	})
+	err := writer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer.Write([]*pb.HTTPAccessLog{{RequestId: "1"}, {RequestId: "2"}, {RequestId: "3"}})
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(storage.logs) != 3 {
+		t.Fatal("expect 3 logs, but got", len(storage.logs))
+	}
+	if writer.CountFailed() != 0 {
+		t.Fatal("should not fail")
+	}
+}
+
+func TestStorageWriter_RetryPartial(t *testing.T) {
+	var storage = &testStorage{countPartialFails: 1}
+	var writer = accesslogs.NewStorageWriter(1, 1, false, storage, &accesslogs.BufferOptions{
+		BatchSize:  3,
+		MaxRetries: 1,
+	})
+	err := writer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer.Write([]*pb.HTTPAccessLog{{RequestId: "1"}, {RequestId: "2"}, {RequestId: "3"}})
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 重试时不应该重复写入已成功的日志
+	if len(storage.logs) != 3 {
+		t.Fatal("expect 3 logs, but got", len(storage.logs))
+	}
+	for index, accessLog := range storage.logs {
+		if accessLog.RequestId != types.String(index+1) {
+			t.Fatal("unexpected log order:", index, accessLog.RequestId)
+		}
+	}
+}
+
+func TestStorageWriter_FirewallOnly(t *testing.T) {
+	var storage = &testStorage{}
+	var writer = accesslogs.NewStorageWriter(1, 1, true, storage, nil)
+	err := writer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer.Write([]*pb.HTTPAccessLog{{RequestId: "1"}, {RequestId: "2", FirewallPolicyId: 1}})
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(storage.logs) != 1 {
+		t.Fatal("expect 1 log, but got", len(storage.logs))
+	}
+}
+
+func TestStorageWriter_WriteAfterClose(t *testing.T) {
+	var storage = &testStorage{}
+	var writer = accesslogs.NewStorageWriter(1, 1, false, storage, nil)
+	err := writer.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !writer.Write([]*pb.HTTPAccessLog{{RequestId: "1"}}) {
+		t.Fatal("should be written before closing")
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 关闭后不再接收日志，由调用者改为写入到新的写入器
+	if writer.Write([]*pb.HTTPAccessLog{{RequestId: "2"}}) {
+		t.Fatal("should not be written after closing")
+	}
+	if len(storage.logs) != 1 {
+		t.Fatal("expect 1 log, but got", len(storage.logs))
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package accesslogs
+
+import (
+	"errors"
+	"github.com/iwind/TeaGo/maps"
+)
+
+// StorageType 存储类型
+type StorageType = string
+
+const (
+	StorageTypeFile       StorageType = "file"       // 文件
+	StorageTypeSyslog     StorageType = "syslog"     // Syslog
+	StorageTypeES         StorageType = "es"         // Elasticsearch
+	StorageTypeClickHouse StorageType = "clickhouse" // ClickHouse
+	StorageTypeKafka      StorageType = "kafka"      // 兼容Kafka REST Proxy的服务
+)
+
+// FindAllStorageTypes 所有存储类型
+func FindAllStorageTypes() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "文件",
+			"code":        StorageTypeFile,
+			"description": "将日志以JSON格式按行写入到本地文件中，支持按大小轮转",
+		},
+		{
+			"name":        "Syslog",
+			"code":        StorageTypeSyslog,
+			"description": "将日志发送到Syslog服务",
+		},
+		{
+			"name":        "Elasticsearch",
+			"code":        StorageTypeES,
+			"description": "通过Bulk API将日志批量写入到Elasticsearch",
+		},
+		{
+			"name":        "ClickHouse",
+			"code":        StorageTypeClickHouse,
+			"description": "通过HTTP接口将日志以JSONEachRow格式写入到ClickHouse",
+		},
+		{
+			"name":        "Kafka",
+			"code":        StorageTypeKafka,
+			"description": "通过Kafka REST Proxy将日志写入到Kafka主题",
+		},
+	}
+}
+
+// NewStorage 根据类型创建存储
+func NewStorage(storageType StorageType) (StorageInterface, error) {
+	switch storageType {
+	case StorageTypeFile:
+		return &FileStorage{}, nil
+	case StorageTypeSyslog:
+		return &SyslogStorage{}, nil
+	case StorageTypeES:
+		return &ESStorage{}, nil
+	case StorageTypeClickHouse:
+		return &ClickHouseStorage{}, nil
+	case StorageTypeKafka:
+		return &KafkaStorage{}, nil
+	}
+	return nil, errors.New("invalid storage type '" + storageType + "'")
+}
@@ -1,3 +1,9 @@
 package acme
 
 type AuthCallback func(domain, token, keyAuth string)
+
+// TLSALPNAuthCallback TLS-ALPN-01认证回调，certData和keyData为PEM格式的验证证书和私钥
+type TLSALPNAuthCallback func(domain, token string, certData []byte, keyData []byte)
+
+// TLSALPNCleanUpCallback TLS-ALPN-01认证结束后的清理回调
+type TLSALPNCleanUpCallback func(domain, token string)
@@ -5,6 +5,7 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
 	"github.com/go-acme/lego/v4/challenge/dns01"
 	"github.com/iwind/TeaGo/lists"
 	"os"
@@ -12,31 +13,43 @@ import (
 	"sync"
 )
 
+// 最多跟随的CNAME层级
+const maxCNAMEDepth = 8
+
 type DNSProvider struct {
 	raw       dnsclients.ProviderInterface
 	dnsDomain string
 
+	delegation  bool                              // 是否通过CNAME委托验证
+	lookupCNAME func(host string) (string, error) // 查询CNAME，方便测试时替换
+
 	locker             sync.Mutex
 	deletedRecordNames []string
 }
 
 func NewDNSProvider(raw dnsclients.ProviderInterface, dnsDomain string) *DNSProvider {
 	return &DNSProvider{
-		raw:       raw,
-		dnsDomain: dnsDomain,
+		raw:         raw,
+		dnsDomain:   dnsDomain,
+		lookupCNAME: utils.LookupCNAME,
 	}
 }
 
+// EnableDelegation 启用CNAME委托验证
+// 证书域名的 _acme-challenge 记录需要CNAME到 dnsDomain 下的某个记录，TXT记录会写入到CNAME的目标记录中
+func (this *DNSProvider) EnableDelegation() {
+	this.delegation = true
+}
+
 func (this *DNSProvider) Present(domain, token, keyAuth string) error {
 	_ = os.Setenv("LEGO_DISABLE_CNAME_SUPPORT", "true")
 	fqdn, value := dns01.GetRecord(domain, keyAuth)
 
 	// 设置记录
-	var index = strings.Index(fqdn, "."+this.dnsDomain)
-	if index < 0 {
-		return errors.New("invalid fqdn value")
+	recordName, err := this.findRecordName(fqdn)
+	if err != nil {
+		return err
 	}
-	var recordName = fqdn[:index]
 
 	// 先删除老的
 	this.locker.Lock()
@@ -60,7 +73,7 @@ func (this *DNSProvider) Present(domain, token, keyAuth string) error {
 	}
 
 	// 添加新的
-	err := this.raw.AddRecord(this.dnsDomain, &dnstypes.Record{
+	err = this.raw.AddRecord(this.dnsDomain, &dnstypes.Record{
 		Id:    "",
 		Name:  recordName,
 		Type:  dnstypes.RecordTypeTXT,
@@ -77,3 +90,42 @@ func (this *DNSProvider) Present(domain, token, keyAuth string) error {
 func (this *DNSProvider) CleanUp(domain, token, keyAuth string) error {
 	return nil
 }
+
+// 查找TXT记录在 dnsDomain 中的记录名
+func (this *DNSProvider) findRecordName(fqdn string) (string, error) {
+	if this.delegation {
+		target, err := this.findCNAMETarget(fqdn)
+		if err != nil {
+			return "", err
+		}
+		fqdn = target
+	}
+
+	var suffix = "." + strings.ToLower(dns01.ToFqdn(this.dnsDomain))
+	if !strings.HasSuffix(strings.ToLower(fqdn), suffix) {
+		if this.delegation {
+			return "", errors.New("the CNAME target '" + fqdn + "' is not under the domain '" + this.dnsDomain + "'")
+		}
+		return "", errors.New("invalid fqdn value")
+	}
+	return fqdn[:len(fqdn)-len(suffix)], nil
+}
+
+// 查找CNAME记录的最终目标
+func (this *DNSProvider) findCNAMETarget(fqdn string) (string, error) {
+	var target = fqdn
+	for i := 0; i < maxCNAMEDepth; i++ {
+		cname, err := this.lookupCNAME(dns01.UnFqdn(target))
+		if err != nil {
+			return "", fmt.Errorf("lookup CNAME of '%s' failed: %w", target, err)
+		}
+		if len(cname) == 0 || strings.EqualFold(dns01.ToFqdn(cname), target) {
+			break
+		}
+		target = dns01.ToFqdn(cname)
+	}
+	if target == fqdn {
+		return "", errors.New("'" + dns01.UnFqdn(fqdn) + "' should be a CNAME record pointing to a record under the domain '" + this.dnsDomain + "'")
+	}
+	return target, nil
+}
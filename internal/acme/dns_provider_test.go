@@ -0,0 +1,59 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"testing"
+)
+
+func TestDNSProvider_FindRecordName(t *testing.T) {
+	var provider = NewDNSProvider(nil, "example.com")
+	recordName, err := provider.findRecordName("_acme-challenge.www.example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recordName != "_acme-challenge.www" {
+		t.Fatal("unexpected record name:", recordName)
+	}
+
+	_, err = provider.findRecordName("_acme-challenge.www.example.com.cn.")
+	if err == nil {
+		t.Fatal("should fail with domain not under 'example.com'")
+	}
+}
+
+func TestDNSProvider_FindRecordName_Delegation(t *testing.T) {
+	var cnames = map[string]string{
+		"_acme-challenge.customer.org":           "_acme-challenge.customer.org.proxy.net.",
+		"_acme-challenge.customer.org.proxy.net": "customer-org.acme.example.com.",
+	}
+
+	var provider = NewDNSProvider(nil, "acme.example.com")
+	provider.EnableDelegation()
+	provider.lookupCNAME = func(host string) (string, error) {
+		return cnames[host], nil
+	}
+
+	recordName, err := provider.findRecordName("_acme-challenge.customer.org.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recordName != "customer-org" {
+		t.Fatal("unexpected record name:", recordName)
+	}
+
+	// 没有设置CNAME
+	_, err = provider.findRecordName("_acme-challenge.other.org.")
+	if err == nil {
+		t.Fatal("should fail without CNAME")
+	}
+	t.Log(err)
+
+	// CNAME到了其他域名
+	cnames["_acme-challenge.other.org"] = "_acme-challenge.other.net."
+	_, err = provider.findRecordName("_acme-challenge.other.org.")
+	if err == nil {
+		t.Fatal("should fail with CNAME target not under 'acme.example.com'")
+	}
+	t.Log(err)
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// NewHTTPClient 获取访问ACME服务的HTTP客户端
+// caCerts 为额外信任的PEM格式CA证书，为空时只使用系统CA证书
+func NewHTTPClient(caCerts string) (*http.Client, error) {
+	var transport = http.DefaultTransport.(*http.Transport).Clone()
+	if len(caCerts) > 0 {
+		certPool, err := ParseCACerts(caCerts)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: certPool,
+		}
+	}
+
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+	}, nil
+}
+
+// ParseCACerts 解析PEM格式的CA证书，并和系统CA证书合并
+func ParseCACerts(caCerts string) (*x509.CertPool, error) {
+	certPool, err := x509.SystemCertPool()
+	if err != nil || certPool == nil {
+		certPool = x509.NewCertPool()
+	}
+	if !certPool.AppendCertsFromPEM([]byte(caCerts)) {
+		return nil, errors.New("no valid PEM certificates found in CA bundle")
+	}
+	return certPool, nil
+}
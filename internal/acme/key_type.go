@@ -0,0 +1,106 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+type KeyType = string
+
+const (
+	KeyTypeRSA2048 KeyType = "RSA2048"
+	KeyTypeRSA3072 KeyType = "RSA3072"
+	KeyTypeRSA4096 KeyType = "RSA4096"
+	KeyTypeEC256   KeyType = "EC256"
+	KeyTypeEC384   KeyType = "EC384"
+
+	DefaultKeyType = KeyTypeRSA2048
+)
+
+type KeyTypeDefinition struct {
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+// FindAllKeyTypes 所有支持的私钥类型
+func FindAllKeyTypes() []*KeyTypeDefinition {
+	return []*KeyTypeDefinition{
+		{Name: "RSA-2048", Code: KeyTypeRSA2048},
+		{Name: "RSA-3072", Code: KeyTypeRSA3072},
+		{Name: "RSA-4096", Code: KeyTypeRSA4096},
+		{Name: "EC-P256", Code: KeyTypeEC256},
+		{Name: "EC-P384", Code: KeyTypeEC384},
+	}
+}
+
+// IsValidKeyType 判断私钥类型是否可用
+func IsValidKeyType(keyType KeyType) bool {
+	for _, def := range FindAllKeyTypes() {
+		if def.Code == keyType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsECKeyType 判断是否为ECDSA私钥类型
+func IsECKeyType(keyType KeyType) bool {
+	return keyType == KeyTypeEC256 || keyType == KeyTypeEC384
+}
+
+// GeneratePrivateKey 生成私钥
+func GeneratePrivateKey(keyType KeyType) (crypto.PrivateKey, error) {
+	switch keyType {
+	case KeyTypeRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeRSA3072:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	case KeyTypeRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case KeyTypeEC256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeEC384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	}
+	return nil, errors.New("invalid key type '" + keyType + "'")
+}
+
+// ParsePEMPrivateKey 解析PEM格式的私钥，并返回私钥类型
+// 不支持的私钥类型返回的keyType为空
+func ParsePEMPrivateKey(keyData []byte) (privateKey crypto.PrivateKey, keyType KeyType, err error) {
+	privateKey, err = certcrypto.ParsePEMPrivateKey(keyData)
+	if err != nil {
+		return nil, "", err
+	}
+	return privateKey, DetectKeyType(privateKey), nil
+}
+
+// DetectKeyType 检测私钥类型
+func DetectKeyType(privateKey crypto.PrivateKey) KeyType {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		switch key.N.BitLen() {
+		case 2048:
+			return KeyTypeRSA2048
+		case 3072:
+			return KeyTypeRSA3072
+		case 4096:
+			return KeyTypeRSA4096
+		}
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return KeyTypeEC256
+		case elliptic.P384():
+			return KeyTypeEC384
+		}
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"github.com/go-acme/lego/v4/certcrypto"
+	"testing"
+)
+
+func TestGeneratePrivateKey(t *testing.T) {
+	for _, def := range FindAllKeyTypes() {
+		privateKey, err := GeneratePrivateKey(def.Code)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keyData := certcrypto.PEMEncode(privateKey)
+		_, keyType, err := ParsePEMPrivateKey(keyData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keyType != def.Code {
+			t.Fatal("expect '" + def.Code + "', but got '" + keyType + "'")
+		}
+		t.Log(def.Name, "ok")
+	}
+
+	_, err := GeneratePrivateKey("RSA1024")
+	if err == nil {
+		t.Fatal("should fail with invalid key type")
+	}
+}
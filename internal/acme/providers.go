@@ -12,6 +12,21 @@ type Provider struct {
 	TestAPIURL     string `json:"testAPIURL"`
 	RequireEAB     bool   `json:"requireEAB"`
 	EABDescription string `json:"eabDescription"`
+
+	// 自定义服务商
+	Id       int64  `json:"id"`       // 自定义服务商ID，内置服务商为0
+	IsCustom bool   `json:"isCustom"` // 是否为自定义服务商
+	CACerts  string `json:"caCerts"`  // 访问目录地址时额外信任的PEM格式CA证书，用于内部CA
+}
+
+// IsBuiltinProviderCode 判断是否为内置服务商代号
+func IsBuiltinProviderCode(code string) bool {
+	for _, provider := range FindAllProviders() {
+		if provider.Code == code {
+			return true
+		}
+	}
+	return false
 }
 
 func FindProviderWithCode(code string) *Provider {
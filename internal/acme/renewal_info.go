@@ -0,0 +1,234 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRenewalInfoRetryAfter = 6 * time.Hour  // 服务商没有返回Retry-After时的重新查询间隔
+	minRenewalInfoRetryAfter     = 1 * time.Hour  // 最短重新查询间隔
+	maxRenewalInfoRetryAfter     = 24 * time.Hour // 最长重新查询间隔
+
+	renewalInfoDirectoryTTL = 24 * time.Hour // 目录中ARI地址的缓存时间
+)
+
+// 服务商目录中的ARI地址
+type renewalInfoDirectory struct {
+	renewalInfoURL string
+	expiresAt      time.Time
+}
+
+var renewalInfoDirectoryMap = map[string]*renewalInfoDirectory{} // directory key => directory
+var renewalInfoDirectoryLocker = &sync.Mutex{}
+
+// RenewalInfo ACME续期信息（ARI）
+type RenewalInfo struct {
+	WindowStart    time.Time     // 建议续期时间窗口开始时间
+	WindowEnd      time.Time     // 建议续期时间窗口结束时间
+	ExplanationURL string        // 说明文档地址
+	RetryAfter     time.Duration // 下次查询的间隔
+}
+
+// RenewAt 在建议的续期时间窗口中选择续期时间
+// 根据证书ID在时间窗口中选择一个固定的时间点，以免每次检查时选择的时间都不同，同时也能让不同证书的续期时间分散开
+func (this *RenewalInfo) RenewAt(certId string) time.Time {
+	var window = this.WindowEnd.Sub(this.WindowStart)
+	if window <= 0 {
+		return this.WindowStart
+	}
+	var sum = sha256.Sum256([]byte(certId))
+	var offset = time.Duration(binary.BigEndian.Uint64(sum[:8]) % uint64(window))
+	return this.WindowStart.Add(offset)
+}
+
+// ShouldRenew 判断当前是否应该续期
+func (this *RenewalInfo) ShouldRenew(certId string, now time.Time) bool {
+	return !now.Before(this.RenewAt(certId))
+}
+
+// ParseLeafCert 解析PEM格式证书中的第一个证书
+func ParseLeafCert(certData []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, certData = pem.Decode(certData)
+		if block == nil {
+			return nil, errors.New("no certificate found in PEM data")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// RenewalCertId 计算ARI中使用的证书ID
+// 格式为 base64url(AuthorityKeyIdentifier) + "." + base64url(证书序列号的DER编码)
+func RenewalCertId(cert *x509.Certificate) (string, error) {
+	if len(cert.AuthorityKeyId) == 0 {
+		return "", errors.New("certificate has no authority key identifier")
+	}
+	if cert.SerialNumber == nil || cert.SerialNumber.Sign() <= 0 {
+		return "", errors.New("invalid certificate serial number")
+	}
+
+	var serialBytes = cert.SerialNumber.Bytes()
+	if serialBytes[0]&0x80 != 0 {
+		// DER编码中正整数最高位不能为1
+		serialBytes = append([]byte{0}, serialBytes...)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cert.AuthorityKeyId) + "." + base64.RawURLEncoding.EncodeToString(serialBytes), nil
+}
+
+// FetchRenewalInfo 从服务商查询证书的续期信息
+// 如果服务商不支持ARI，返回nil
+func FetchRenewalInfo(provider *Provider, certData []byte) (info *RenewalInfo, certId string, err error) {
+	if provider == nil {
+		return nil, "", errors.New("provider should not be nil")
+	}
+
+	cert, err := ParseLeafCert(certData)
+	if err != nil {
+		return nil, "", fmt.Errorf("parse certificate failed: %w", err)
+	}
+	certId, err = RenewalCertId(cert)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := NewHTTPClient(provider.CACerts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 从目录中查找ARI地址
+	renewalInfoURL, err := findRenewalInfoURL(client, provider)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch directory failed: %w", err)
+	}
+	if len(renewalInfoURL) == 0 {
+		return nil, certId, nil
+	}
+
+	var result = struct {
+		SuggestedWindow struct {
+			Start time.Time `json:"start"`
+			End   time.Time `json:"end"`
+		} `json:"suggestedWindow"`
+		ExplanationURL string `json:"explanationURL"`
+	}{}
+	header, err := fetchJSON(client, strings.TrimSuffix(renewalInfoURL, "/")+"/"+certId, &result)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch renewal info failed: %w", err)
+	}
+	if result.SuggestedWindow.Start.IsZero() || result.SuggestedWindow.End.Before(result.SuggestedWindow.Start) {
+		return nil, "", errors.New("invalid suggested window in renewal info")
+	}
+
+	return &RenewalInfo{
+		WindowStart:    result.SuggestedWindow.Start,
+		WindowEnd:      result.SuggestedWindow.End,
+		ExplanationURL: result.ExplanationURL,
+		RetryAfter:     parseRetryAfter(header.Get("Retry-After"), time.Now()),
+	}, certId, nil
+}
+
+// 查找服务商目录中的ARI地址
+// 同一个服务商的所有证书共用一个目录，所以查询结果会缓存一段时间；查询失败时不缓存
+func findRenewalInfoURL(client *http.Client, provider *Provider) (string, error) {
+	var key = provider.APIURL + "@" + fmt.Sprintf("%x", sha256.Sum256([]byte(provider.CACerts)))
+
+	renewalInfoDirectoryLocker.Lock()
+	directory, ok := renewalInfoDirectoryMap[key]
+	renewalInfoDirectoryLocker.Unlock()
+	if ok && time.Now().Before(directory.expiresAt) {
+		return directory.renewalInfoURL, nil
+	}
+
+	var result = struct {
+		RenewalInfo string `json:"renewalInfo"`
+	}{}
+	_, err := fetchJSON(client, provider.APIURL, &result)
+	if err != nil {
+		return "", err
+	}
+
+	renewalInfoDirectoryLocker.Lock()
+	// 清除过期的缓存
+	var now = time.Now()
+	for oldKey, oldDirectory := range renewalInfoDirectoryMap {
+		if !now.Before(oldDirectory.expiresAt) {
+			delete(renewalInfoDirectoryMap, oldKey)
+		}
+	}
+	renewalInfoDirectoryMap[key] = &renewalInfoDirectory{
+		renewalInfoURL: result.RenewalInfo,
+		expiresAt:      now.Add(renewalInfoDirectoryTTL),
+	}
+	renewalInfoDirectoryLocker.Unlock()
+
+	return result.RenewalInfo, nil
+}
+
+// 获取JSON数据
+func fetchJSON(client *http.Client, url string, ptr any) (http.Header, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("invalid response status '" + strconv.Itoa(resp.StatusCode) + "'")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, ptr)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Header, nil
+}
+
+// 解析Retry-After，支持秒数和HTTP日期两种格式
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	var retryAfter = DefaultRenewalInfoRetryAfter
+	value = strings.TrimSpace(value)
+	if len(value) > 0 {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			retryAfter = time.Duration(seconds) * time.Second
+		} else {
+			t, err := http.ParseTime(value)
+			if err == nil {
+				retryAfter = t.Sub(now)
+			}
+		}
+	}
+
+	if retryAfter < minRenewalInfoRetryAfter {
+		retryAfter = minRenewalInfoRetryAfter
+	} else if retryAfter > maxRenewalInfoRetryAfter {
+		retryAfter = maxRenewalInfoRetryAfter
+	}
+	return retryAfter
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testCreateCert(t *testing.T, serialNumber *big.Int) []byte {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber:   serialNumber,
+		Subject:        pkix.Name{CommonName: "example.com"},
+		NotBefore:      time.Now(),
+		NotAfter:       time.Now().Add(90 * 24 * time.Hour),
+		AuthorityKeyId: []byte{0x69, 0x88, 0x5b, 0x6b, 0x87, 0x46, 0x40, 0x41, 0xe1, 0xb3, 0x7b, 0x84, 0x7b, 0xa0, 0xae, 0x2c, 0xde, 0x01, 0xc8, 0xd4},
+		DNSNames:       []string{"example.com"},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+}
+
+func TestRenewalCertId(t *testing.T) {
+	// RFC 9773 中的例子
+	serialNumber, _ := new(big.Int).SetString("0087654321", 16)
+	cert, err := ParseLeafCert(testCreateCert(t, serialNumber))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certId, err := RenewalCertId(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certId != "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE" {
+		t.Fatal("unexpected cert id:", certId)
+	}
+}
+
+func TestRenewalInfo_RenewAt(t *testing.T) {
+	var now = time.Now()
+	var info = &RenewalInfo{
+		WindowStart: now.Add(24 * time.Hour),
+		WindowEnd:   now.Add(48 * time.Hour),
+	}
+	var renewAt = info.RenewAt("a.b")
+	if renewAt.Before(info.WindowStart) || !renewAt.Before(info.WindowEnd) {
+		t.Fatal("renew time should be in window:", renewAt)
+	}
+	if !renewAt.Equal(info.RenewAt("a.b")) {
+		t.Fatal("renew time should be stable")
+	}
+	if info.ShouldRenew("a.b", now) {
+		t.Fatal("should not renew before window")
+	}
+	if !info.ShouldRenew("a.b", info.WindowEnd) {
+		t.Fatal("should renew after window")
+	}
+}
+
+func TestFetchRenewalInfo(t *testing.T) {
+	var mux = http.NewServeMux()
+	var server = httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	var countDirectoryRequests = 0
+	mux.HandleFunc("/directory", func(writer http.ResponseWriter, request *http.Request) {
+		countDirectoryRequests++
+		_, _ = writer.Write([]byte(`{"newNonce":"` + server.URL + `/new-nonce","renewalInfo":"` + server.URL + `/renewal-info"}`))
+	})
+	mux.HandleFunc("/renewal-info/", func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/renewal-info/aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writer.Header().Set("Retry-After", "21600")
+		_, _ = writer.Write([]byte(`{"suggestedWindow":{"start":"2025-01-02T04:00:00Z","end":"2025-01-03T04:00:00Z"},"explanationURL":"https://acme.example.com/docs/ari"}`))
+	})
+
+	var provider = &Provider{
+		Name:     "Test CA",
+		Code:     "test",
+		APIURL:   server.URL + "/directory",
+		IsCustom: true,
+		CACerts:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+	}
+
+	serialNumber, _ := new(big.Int).SetString("0087654321", 16)
+	info, certId, err := FetchRenewalInfo(provider, testCreateCert(t, serialNumber))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil {
+		t.Fatal("renewal info should not be nil")
+	}
+	t.Log(certId, info.WindowStart, info.WindowEnd, info.RetryAfter)
+	if info.WindowStart.Format(time.RFC3339) != "2025-01-02T04:00:00Z" || info.RetryAfter != 6*time.Hour {
+		t.Fatal("unexpected renewal info")
+	}
+
+	// 同一个服务商的目录只查询一次
+	_, _, err = FetchRenewalInfo(provider, testCreateCert(t, serialNumber))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countDirectoryRequests != 1 {
+		t.Fatal("directory should be cached, but requested", countDirectoryRequests, "times")
+	}
+
+	// 不信任服务器证书
+	provider.CACerts = ""
+	_, _, err = FetchRenewalInfo(provider, testCreateCert(t, serialNumber))
+	if err == nil {
+		t.Fatal("should fail without trusting the CA")
+	}
+}
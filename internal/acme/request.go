@@ -1,6 +1,7 @@
 package acme
 
 import (
+	"crypto"
 	"fmt"
 	teaconst "github.com/TeaOSLab/EdgeAPI/internal/const"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
@@ -12,13 +13,24 @@ import (
 	"github.com/iwind/TeaGo/Tea"
 	"io"
 	"log"
+	"strings"
 )
 
+// Result 证书申请结果
+type Result struct {
+	KeyType  KeyType
+	CertData []byte
+	KeyData  []byte
+}
+
 type Request struct {
 	debug bool
 
 	task   *Task
 	onAuth AuthCallback
+
+	onTLSALPNAuth    TLSALPNAuthCallback
+	onTLSALPNCleanUp TLSALPNCleanUpCallback
 }
 
 func NewRequest(task *Task) *Request {
@@ -35,7 +47,22 @@ func (this *Request) OnAuth(onAuth AuthCallback) {
 	this.onAuth = onAuth
 }
 
+// OnTLSALPNAuth 设置TLS-ALPN-01认证回调
+func (this *Request) OnTLSALPNAuth(onAuth TLSALPNAuthCallback, onCleanUp TLSALPNCleanUpCallback) {
+	this.onTLSALPNAuth = onAuth
+	this.onTLSALPNCleanUp = onCleanUp
+}
+
 func (this *Request) Run() (certData []byte, keyData []byte, err error) {
+	results, err := this.RunResults()
+	if err != nil {
+		return nil, nil, err
+	}
+	return results[0].CertData, results[0].KeyData, nil
+}
+
+// RunResults 申请证书，返回的第一个结果为主证书，如果设置了DualKeyType，则第二个结果为另一种私钥类型的证书
+func (this *Request) RunResults() (results []*Result, err error) {
 	if this.task.Provider == nil {
 		err = errors.New("provider should not be nil")
 		return
@@ -45,18 +72,25 @@ func (this *Request) Run() (certData []byte, keyData []byte, err error) {
 		return
 	}
 
+	var client *lego.Client
 	switch this.task.AuthType {
 	case AuthTypeDNS:
-		return this.runDNS()
+		client, err = this.runDNS()
 	case AuthTypeHTTP:
-		return this.runHTTP()
+		client, err = this.runHTTP()
+	case AuthTypeTLSALPN:
+		client, err = this.runTLSALPN()
 	default:
 		err = errors.New("invalid task type '" + this.task.AuthType + "'")
-		return
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return this.obtain(client)
 }
 
-func (this *Request) runDNS() (certData []byte, keyData []byte, err error) {
+func (this *Request) runDNS() (client *lego.Client, err error) {
 	if !this.debug {
 		if !Tea.IsTesting() {
 			acmelog.Logger = log.New(io.Discard, "", log.LstdFlags)
@@ -80,68 +114,49 @@ func (this *Request) runDNS() (certData []byte, keyData []byte, err error) {
 		return
 	}
 
-	var config = lego.NewConfig(this.task.User)
-	config.Certificate.KeyType = certcrypto.RSA2048
-	config.CADirURL = this.task.Provider.APIURL
-	config.UserAgent = teaconst.ProductName + "/" + teaconst.Version
+	client, err = this.newClient()
+	if err != nil {
+		return nil, err
+	}
 
-	client, err := lego.NewClient(config)
+	var dnsProvider = NewDNSProvider(this.task.DNSProvider, this.task.DNSDomain)
+	if this.task.DNSDelegation {
+		dnsProvider.EnableDelegation()
+	}
+	err = client.Challenge.SetDNS01Provider(dnsProvider)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	// 注册用户
-	var resource = this.task.User.GetRegistration()
-	if resource != nil {
-		_, err = client.Registration.QueryRegistration()
-		if err != nil {
-			return nil, nil, err
-		}
-	} else {
-		if this.task.Provider.RequireEAB {
-			resource, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
-				TermsOfServiceAgreed: true,
-				Kid:                  this.task.Account.EABKid,
-				HmacEncoded:          this.task.Account.EABKey,
-			})
-			if err != nil {
-				return nil, nil, fmt.Errorf("register user failed: %w", err)
-			}
-			err = this.task.User.Register(resource)
-			if err != nil {
-				return nil, nil, err
-			}
-		} else {
-			resource, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
-			if err != nil {
-				return nil, nil, err
-			}
-			err = this.task.User.Register(resource)
-			if err != nil {
-				return nil, nil, err
-			}
+	return client, nil
+}
+
+func (this *Request) runHTTP() (client *lego.Client, err error) {
+	if !this.debug {
+		if !Tea.IsTesting() {
+			acmelog.Logger = log.New(io.Discard, "", log.LstdFlags)
 		}
 	}
 
-	err = client.Challenge.SetDNS01Provider(NewDNSProvider(this.task.DNSProvider, this.task.DNSDomain))
-	if err != nil {
-		return nil, nil, err
+	if this.task.User == nil {
+		err = errors.New("'user' must not be nil")
+		return
 	}
 
-	// 申请证书
-	var request = certificate.ObtainRequest{
-		Domains: this.task.Domains,
-		Bundle:  true,
+	client, err = this.newClient()
+	if err != nil {
+		return nil, err
 	}
-	certResource, err := client.Certificate.Obtain(request)
+
+	err = client.Challenge.SetHTTP01Provider(NewHTTPProvider(this.onAuth))
 	if err != nil {
-		return nil, nil, fmt.Errorf("obtain cert failed: %w", err)
+		return nil, err
 	}
 
-	return certResource.Certificate, certResource.PrivateKey, nil
+	return client, nil
 }
 
-func (this *Request) runHTTP() (certData []byte, keyData []byte, err error) {
+func (this *Request) runTLSALPN() (client *lego.Client, err error) {
 	if !this.debug {
 		if !Tea.IsTesting() {
 			acmelog.Logger = log.New(io.Discard, "", log.LstdFlags)
@@ -152,15 +167,42 @@ func (this *Request) runHTTP() (certData []byte, keyData []byte, err error) {
 		err = errors.New("'user' must not be nil")
 		return
 	}
+	for _, domain := range this.task.Domains {
+		if strings.HasPrefix(domain, "*.") {
+			err = errors.New("wildcard domain '" + domain + "' can not be validated with TLS-ALPN-01")
+			return
+		}
+	}
+
+	client, err = this.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.Challenge.SetTLSALPN01Provider(NewTLSALPNProvider(this.onTLSALPNAuth, this.onTLSALPNCleanUp))
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
 
+// 创建客户端并注册用户
+func (this *Request) newClient() (client *lego.Client, err error) {
 	var config = lego.NewConfig(this.task.User)
-	config.Certificate.KeyType = certcrypto.RSA2048
+	config.Certificate.KeyType = certcrypto.RSA2048 // 私钥在申请证书时单独生成，这里只是默认值
 	config.CADirURL = this.task.Provider.APIURL
 	config.UserAgent = teaconst.ProductName + "/" + teaconst.Version
+	if len(this.task.Provider.CACerts) > 0 {
+		config.HTTPClient, err = NewHTTPClient(this.task.Provider.CACerts)
+		if err != nil {
+			return nil, fmt.Errorf("load CA certificates of provider '%s' failed: %w", this.task.Provider.Name, err)
+		}
+	}
 
-	client, err := lego.NewClient(config)
+	client, err = lego.NewClient(config)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	// 注册用户
@@ -168,7 +210,7 @@ func (this *Request) runHTTP() (certData []byte, keyData []byte, err error) {
 	if resource != nil {
 		_, err = client.Registration.QueryRegistration()
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	} else {
 		if this.task.Provider.RequireEAB {
@@ -178,38 +220,73 @@ func (this *Request) runHTTP() (certData []byte, keyData []byte, err error) {
 				HmacEncoded:          this.task.Account.EABKey,
 			})
 			if err != nil {
-				return nil, nil, fmt.Errorf("register user failed: %w", err)
+				return nil, fmt.Errorf("register user failed: %w", err)
 			}
 			err = this.task.User.Register(resource)
 			if err != nil {
-				return nil, nil, err
+				return nil, err
 			}
 		} else {
 			resource, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
 			if err != nil {
-				return nil, nil, err
+				return nil, err
 			}
 			err = this.task.User.Register(resource)
 			if err != nil {
-				return nil, nil, err
+				return nil, err
 			}
 		}
 	}
 
-	err = client.Challenge.SetHTTP01Provider(NewHTTPProvider(this.onAuth))
-	if err != nil {
-		return nil, nil, err
+	return client, nil
+}
+
+// 申请证书
+func (this *Request) obtain(client *lego.Client) (results []*Result, err error) {
+	var keyType = this.task.KeyType
+	if len(keyType) == 0 {
+		keyType = DefaultKeyType
+	}
+	var keyTypes = []KeyType{keyType}
+	if len(this.task.DualKeyType) > 0 && this.task.DualKeyType != keyType {
+		keyTypes = append(keyTypes, this.task.DualKeyType)
 	}
 
-	// 申请证书
-	var request = certificate.ObtainRequest{
-		Domains: this.task.Domains,
-		Bundle:  true,
+	for _, keyType := range keyTypes {
+		privateKey, err := this.findPrivateKey(keyType)
+		if err != nil {
+			return nil, err
+		}
+
+		var request = certificate.ObtainRequest{
+			Domains:    this.task.Domains,
+			Bundle:     true,
+			PrivateKey: privateKey,
+		}
+		certResource, err := client.Certificate.Obtain(request)
+		if err != nil {
+			return nil, fmt.Errorf("obtain cert failed (%s): %w", keyType, err)
+		}
+
+		results = append(results, &Result{
+			KeyType:  keyType,
+			CertData: certResource.Certificate,
+			KeyData:  certResource.PrivateKey,
+		})
 	}
-	certResource, err := client.Certificate.Obtain(request)
-	if err != nil {
-		return nil, nil, err
+
+	return results, nil
+}
+
+// 查找可以复用的私钥，找不到时生成新的私钥
+func (this *Request) findPrivateKey(keyType KeyType) (crypto.PrivateKey, error) {
+	var keyData = this.task.ReuseKeys[keyType]
+	if len(keyData) > 0 {
+		privateKey, reuseKeyType, err := ParsePEMPrivateKey(keyData)
+		if err == nil && reuseKeyType == keyType {
+			return privateKey, nil
+		}
 	}
 
-	return certResource.Certificate, certResource.PrivateKey, nil
+	return GeneratePrivateKey(keyType)
 }
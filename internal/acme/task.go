@@ -5,8 +5,9 @@ import "github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
 type AuthType = string
 
 const (
-	AuthTypeDNS  AuthType = "dns"
-	AuthTypeHTTP AuthType = "http"
+	AuthTypeDNS     AuthType = "dns"
+	AuthTypeHTTP    AuthType = "http"
+	AuthTypeTLSALPN AuthType = "tlsAlpn"
 )
 
 type Task struct {
@@ -16,7 +17,13 @@ type Task struct {
 	AuthType AuthType
 	Domains  []string
 
+	// 私钥相关
+	KeyType     KeyType            // 私钥类型，为空时使用RSA2048
+	DualKeyType KeyType            // 同时申请的另一种私钥类型的证书，为空表示不申请
+	ReuseKeys   map[KeyType][]byte // 续期时复用的PEM格式私钥，没有对应类型的私钥时生成新的私钥
+
 	// DNS相关
-	DNSProvider dnsclients.ProviderInterface
-	DNSDomain   string
+	DNSProvider   dnsclients.ProviderInterface
+	DNSDomain     string
+	DNSDelegation bool // 是否通过CNAME委托验证，启用后证书域名不需要托管在DNS服务商中
 }
@@ -0,0 +1,40 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package acme
+
+import (
+	"fmt"
+	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
+)
+
+// TLSALPNProvider TLS-ALPN-01认证
+// 验证证书会通过回调分发到边缘节点，由节点在ALPN为acme-tls/1的TLS握手中返回
+type TLSALPNProvider struct {
+	onAuth    TLSALPNAuthCallback
+	onCleanUp TLSALPNCleanUpCallback
+}
+
+func NewTLSALPNProvider(onAuth TLSALPNAuthCallback, onCleanUp TLSALPNCleanUpCallback) *TLSALPNProvider {
+	return &TLSALPNProvider{
+		onAuth:    onAuth,
+		onCleanUp: onCleanUp,
+	}
+}
+
+func (this *TLSALPNProvider) Present(domain, token, keyAuth string) error {
+	certData, keyData, err := tlsalpn01.ChallengeBlocks(domain, keyAuth)
+	if err != nil {
+		return fmt.Errorf("generate TLS-ALPN-01 challenge certificate failed: %w", err)
+	}
+	if this.onAuth != nil {
+		this.onAuth(domain, token, certData, keyData)
+	}
+	return nil
+}
+
+func (this *TLSALPNProvider) CleanUp(domain, token, keyAuth string) error {
+	if this.onCleanUp != nil {
+		this.onCleanUp(domain, token)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ctlogs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Entry 证书透明度日志中的证书记录
+type Entry struct {
+	Id           string    // 记录ID，在同一个来源中唯一
+	IssuerName   string    // 颁发机构
+	CommonName   string    // 证书的CN
+	DNSNames     []string  // 证书包含的域名
+	SerialNumber string    // 证书序列号，为统一格式的十六进制字符串
+	NotBefore    time.Time // 生效时间
+	NotAfter     time.Time // 过期时间
+	LoggedAt     time.Time // 记录到日志的时间
+}
+
+// AllNames 证书中包含的所有域名
+func (this *Entry) AllNames() []string {
+	var result = []string{}
+	var nameMap = map[string]bool{}
+	for _, name := range append([]string{this.CommonName}, this.DNSNames...) {
+		name = NormalizeDomain(name)
+		if len(name) == 0 || nameMap[name] {
+			continue
+		}
+		nameMap[name] = true
+		result = append(result, name)
+	}
+	return result
+}
+
+// NormalizeSerialNumber 格式化证书序列号
+// 去除分隔符和前导零，统一转换为小写的十六进制字符串，以便和本地证书的序列号比较
+func NormalizeSerialNumber(serialNumber string) string {
+	serialNumber = strings.NewReplacer(":", "", " ", "", "-", "").Replace(strings.TrimSpace(serialNumber))
+	serialNumber = strings.TrimPrefix(strings.ToLower(serialNumber), "0x")
+	var n = new(big.Int)
+	_, ok := n.SetString(serialNumber, 16)
+	if !ok {
+		return ""
+	}
+	return n.Text(16)
+}
+
+// ParseSerialNumber 读取PEM证书链中第一个证书的序列号
+func ParseSerialNumber(certData []byte) string {
+	for {
+		block, rest := pem.Decode(certData)
+		if block == nil {
+			return ""
+		}
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return ""
+			}
+			return NormalizeSerialNumber(cert.SerialNumber.Text(16))
+		}
+		certData = rest
+	}
+}
+
+// NormalizeDomain 格式化域名
+func NormalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
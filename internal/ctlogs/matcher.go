@@ -0,0 +1,103 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ctlogs
+
+import (
+	"sort"
+	"strings"
+)
+
+// DomainMatcher 将证书中的域名和需要监控的域名进行匹配
+type DomainMatcher struct {
+	domainMap map[string]map[string]bool // domain => { ownerKey => true }
+	childMap  map[string][]string        // .parent => [domain1, ...]，用来查找证书中泛域名覆盖的监控域名
+}
+
+// NewDomainMatcher 获取新对象
+func NewDomainMatcher() *DomainMatcher {
+	return &DomainMatcher{
+		domainMap: map[string]map[string]bool{},
+		childMap:  map[string][]string{},
+	}
+}
+
+// AddDomain 添加需要监控的域名
+// ownerKey用来标识域名所属的对象，比如用户、服务或者证书
+func (this *DomainMatcher) AddDomain(domain string, ownerKey string) {
+	domain = NormalizeDomain(domain)
+	if len(domain) == 0 || strings.Contains(domain, " ") {
+		return
+	}
+	owners, ok := this.domainMap[domain]
+	if !ok {
+		owners = map[string]bool{}
+		this.domainMap[domain] = owners
+
+		if index := strings.Index(domain, "."); index > 0 {
+			this.childMap[domain[index:]] = append(this.childMap[domain[index:]], domain)
+		}
+	}
+	owners[ownerKey] = true
+}
+
+// Len 监控的域名数量
+func (this *DomainMatcher) Len() int {
+	return len(this.domainMap)
+}
+
+// QueryDomains 用来查询的域名列表
+// 泛域名去掉前面的通配符部分，结果已去重并排序
+func (this *DomainMatcher) QueryDomains() []string {
+	var domainMap = map[string]bool{}
+	for domain := range this.domainMap {
+		domainMap[strings.TrimPrefix(domain, "*.")] = true
+	}
+	var result = []string{}
+	for domain := range domainMap {
+		result = append(result, domain)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Match 查找和证书域名匹配的监控域名
+// 返回 monitoredDomain => ownerKeys
+func (this *DomainMatcher) Match(certNames []string) map[string][]string {
+	var result = map[string][]string{}
+	var addOwners = func(domain string) {
+		owners, ok := this.domainMap[domain]
+		if !ok {
+			return
+		}
+		if _, ok = result[domain]; ok {
+			return
+		}
+		var ownerKeys = []string{}
+		for ownerKey := range owners {
+			ownerKeys = append(ownerKeys, ownerKey)
+		}
+		sort.Strings(ownerKeys)
+		result[domain] = ownerKeys
+	}
+
+	for _, certName := range certNames {
+		certName = NormalizeDomain(certName)
+		if len(certName) == 0 {
+			continue
+		}
+
+		// 完全一致
+		addOwners(certName)
+
+		if strings.HasPrefix(certName, "*.") {
+			// 证书中的泛域名覆盖监控的下一级域名
+			for _, domain := range this.childMap[certName[1:]] {
+				addOwners(domain)
+			}
+		} else if index := strings.Index(certName, "."); index > 0 {
+			// 监控的泛域名覆盖证书中的下一级域名
+			addOwners("*" + certName[index:])
+		}
+	}
+	return result
+}
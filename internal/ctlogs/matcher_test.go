@@ -0,0 +1,69 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ctlogs_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/ctlogs"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestDomainMatcher_Match(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var matcher = ctlogs.NewDomainMatcher()
+	matcher.AddDomain("example.com", "user:1")
+	matcher.AddDomain("WWW.example.com.", "user:1")
+	matcher.AddDomain("www.example.com", "server:2")
+	matcher.AddDomain("*.example.org", "cert:3")
+	a.IsTrue(matcher.Len() == 3)
+	t.Log(matcher.QueryDomains())
+	a.IsTrue(len(matcher.QueryDomains()) == 3)
+
+	{
+		var result = matcher.Match([]string{"www.example.com"})
+		t.Log(result)
+		a.IsTrue(len(result) == 1)
+		a.IsTrue(len(result["www.example.com"]) == 2)
+	}
+	{
+		var result = matcher.Match([]string{"*.example.com"})
+		t.Log(result)
+		a.IsTrue(len(result) == 1)
+		a.IsTrue(len(result["www.example.com"]) == 2)
+	}
+	{
+		var result = matcher.Match([]string{"a.b.example.com", "other.com"})
+		a.IsTrue(len(result) == 0)
+	}
+	{
+		var result = matcher.Match([]string{"mail.example.org"})
+		t.Log(result)
+		a.IsTrue(len(result["*.example.org"]) == 1)
+	}
+	{
+		var result = matcher.Match([]string{"*.example.org"})
+		a.IsTrue(len(result["*.example.org"]) == 1)
+	}
+
+	// 多级域名
+	matcher.AddDomain("a.b.example.com", "user:4")
+	{
+		var result = matcher.Match([]string{"*.b.example.com"})
+		t.Log(result)
+		a.IsTrue(len(result) == 1)
+		a.IsTrue(len(result["a.b.example.com"]) == 1)
+	}
+	{
+		var result = matcher.Match([]string{"*.example.com"})
+		a.IsTrue(len(result) == 1)
+		a.IsTrue(result["a.b.example.com"] == nil)
+	}
+}
+
+func TestNormalizeSerialNumber(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsTrue(ctlogs.NormalizeSerialNumber("03:E2:B0") == "3e2b0")
+	a.IsTrue(ctlogs.NormalizeSerialNumber("0003e2b0") == "3e2b0")
+	a.IsTrue(ctlogs.NormalizeSerialNumber("xyz") == "")
+}
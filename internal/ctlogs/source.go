@@ -0,0 +1,58 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ctlogs
+
+import (
+	"errors"
+	"net/http"
+)
+
+type SourceType = string
+
+const (
+	SourceTypeCrtSh SourceType = "crtsh" // 兼容crt.sh的查询接口
+	SourceTypeCTLog SourceType = "ctLog" // RFC 6962 证书透明度日志
+)
+
+// FindAllSourceTypes 所有支持的来源类型
+func FindAllSourceTypes() []*SourceTypeDefinition {
+	return []*SourceTypeDefinition{
+		{Name: "crt.sh", Code: SourceTypeCrtSh},
+		{Name: "CT Log（RFC 6962）", Code: SourceTypeCTLog},
+	}
+}
+
+type SourceTypeDefinition struct {
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+// DomainSource 可以按域名查询的来源
+type DomainSource interface {
+	// FindEntriesWithDomain 查询某个域名及其子域名的证书记录
+	FindEntriesWithDomain(domain string) ([]*Entry, error)
+}
+
+// LogSource 需要按顺序读取的日志来源
+type LogSource interface {
+	// FetchEntries 从某个位置开始读取记录，返回下一次读取的位置
+	FetchEntries(start int64, maxEntries int) (entries []*Entry, next int64, err error)
+
+	// TreeSize 日志当前的记录数
+	TreeSize() (int64, error)
+}
+
+// NewSource 根据类型构造来源
+// 返回的对象实现了DomainSource或者LogSource
+func NewSource(sourceType SourceType, apiURL string, httpClient *http.Client) (any, error) {
+	if len(apiURL) == 0 {
+		return nil, errors.New("api url should not be empty")
+	}
+	switch sourceType {
+	case SourceTypeCrtSh:
+		return NewCrtShSource(apiURL, httpClient), nil
+	case SourceTypeCTLog:
+		return NewCTLogSource(apiURL, httpClient), nil
+	}
+	return nil, errors.New("invalid source type '" + sourceType + "'")
+}
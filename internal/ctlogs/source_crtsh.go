@@ -0,0 +1,134 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ctlogs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// crt.sh返回的时间格式，不带时区，均为UTC时间
+const crtShTimeLayout = "2006-01-02T15:04:05.999999999"
+
+type crtShEntry struct {
+	Id             int64  `json:"id"`
+	IssuerName     string `json:"issuer_name"`
+	CommonName     string `json:"common_name"`
+	NameValue      string `json:"name_value"`
+	SerialNumber   string `json:"serial_number"`
+	NotBefore      string `json:"not_before"`
+	NotAfter       string `json:"not_after"`
+	EntryTimestamp string `json:"entry_timestamp"`
+}
+
+// CrtShSource 兼容crt.sh的查询接口
+type CrtShSource struct {
+	apiURL     string
+	httpClient *http.Client
+}
+
+// NewCrtShSource 获取新对象
+func NewCrtShSource(apiURL string, httpClient *http.Client) *CrtShSource {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &CrtShSource{
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// FindEntriesWithDomain 查询某个域名及其子域名相关的未过期证书
+func (this *CrtShSource) FindEntriesWithDomain(domain string) ([]*Entry, error) {
+	domain = NormalizeDomain(strings.TrimPrefix(domain, "*."))
+	if len(domain) == 0 {
+		return nil, errors.New("domain should not be empty")
+	}
+
+	// crt.sh按域名完全匹配，子域名和泛域名需要使用通配符单独查询
+	var result = []*Entry{}
+	var entryIdMap = map[string]bool{}
+	for _, q := range []string{domain, "%." + domain} {
+		entries, err := this.findEntries(q)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entryIdMap[entry.Id] {
+				continue
+			}
+			entryIdMap[entry.Id] = true
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// 执行单次查询
+func (this *CrtShSource) findEntries(q string) ([]*Entry, error) {
+	var query = url.Values{}
+	query.Set("q", q)
+	query.Set("output", "json")
+	query.Set("exclude", "expired")
+	req, err := http.NewRequest(http.MethodGet, this.apiURL+"/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := this.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected response status '" + strconv.Itoa(resp.StatusCode) + "'")
+	}
+
+	var crtShEntries = []*crtShEntry{}
+	err = json.Unmarshal(data, &crtShEntries)
+	if err != nil {
+		return nil, errors.New("decode response failed: " + err.Error())
+	}
+
+	var result = []*Entry{}
+	for _, crtShEntry := range crtShEntries {
+		var entry = &Entry{
+			Id:           strconv.FormatInt(crtShEntry.Id, 10),
+			IssuerName:   crtShEntry.IssuerName,
+			CommonName:   crtShEntry.CommonName,
+			SerialNumber: NormalizeSerialNumber(crtShEntry.SerialNumber),
+			NotBefore:    this.parseTime(crtShEntry.NotBefore),
+			NotAfter:     this.parseTime(crtShEntry.NotAfter),
+			LoggedAt:     this.parseTime(crtShEntry.EntryTimestamp),
+		}
+		for _, name := range strings.Split(crtShEntry.NameValue, "\n") {
+			name = strings.TrimSpace(name)
+			if len(name) > 0 {
+				entry.DNSNames = append(entry.DNSNames, name)
+			}
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+func (this *CrtShSource) parseTime(timeString string) time.Time {
+	t, err := time.ParseInLocation(crtShTimeLayout, timeString, time.UTC)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
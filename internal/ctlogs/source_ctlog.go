@@ -0,0 +1,154 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ctlogs
+
+import (
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ctLogEntryTypeX509    = 0
+	ctLogEntryTypePrecert = 1
+)
+
+// CTLogSource RFC 6962 证书透明度日志
+type CTLogSource struct {
+	apiURL     string
+	httpClient *http.Client
+}
+
+// NewCTLogSource 获取新对象
+// apiURL为日志地址，不包含 /ct/v1 部分
+func NewCTLogSource(apiURL string, httpClient *http.Client) *CTLogSource {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &CTLogSource{
+		apiURL:     strings.TrimSuffix(strings.TrimSuffix(apiURL, "/"), "/ct/v1"),
+		httpClient: httpClient,
+	}
+}
+
+// TreeSize 读取日志当前的记录数
+func (this *CTLogSource) TreeSize() (int64, error) {
+	var sth = struct {
+		TreeSize int64 `json:"tree_size"`
+	}{}
+	err := this.get("/ct/v1/get-sth", &sth)
+	if err != nil {
+		return 0, err
+	}
+	return sth.TreeSize, nil
+}
+
+// FetchEntries 从某个位置开始读取记录
+// 日志服务可能返回比请求更少的记录，所以需要使用返回的next作为下一次读取的位置
+func (this *CTLogSource) FetchEntries(start int64, maxEntries int) (entries []*Entry, next int64, err error) {
+	if maxEntries <= 0 {
+		return nil, start, nil
+	}
+
+	var resp = struct {
+		Entries []struct {
+			LeafInput []byte `json:"leaf_input"`
+			ExtraData []byte `json:"extra_data"`
+		} `json:"entries"`
+	}{}
+	err = this.get("/ct/v1/get-entries?start="+strconv.FormatInt(start, 10)+"&end="+strconv.FormatInt(start+int64(maxEntries)-1, 10), &resp)
+	if err != nil {
+		return nil, start, err
+	}
+
+	for index, rawEntry := range resp.Entries {
+		var id = start + int64(index)
+		cert, loggedAt, err := this.parseLeaf(rawEntry.LeafInput, rawEntry.ExtraData)
+		if err != nil {
+			// 跳过无法解析的记录
+			continue
+		}
+		entries = append(entries, &Entry{
+			Id:           strconv.FormatInt(id, 10),
+			IssuerName:   cert.Issuer.String(),
+			CommonName:   cert.Subject.CommonName,
+			DNSNames:     cert.DNSNames,
+			SerialNumber: NormalizeSerialNumber(cert.SerialNumber.Text(16)),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+			LoggedAt:     loggedAt,
+		})
+	}
+	return entries, start + int64(len(resp.Entries)), nil
+}
+
+// 解析 MerkleTreeLeaf
+// 预签名证书（precert）的leaf中只有TBSCertificate，所以从extra_data中读取完整的预签名证书
+func (this *CTLogSource) parseLeaf(leafInput []byte, extraData []byte) (cert *x509.Certificate, loggedAt time.Time, err error) {
+	// version(1) + leaf_type(1) + timestamp(8) + entry_type(2)
+	if len(leafInput) < 12 {
+		return nil, loggedAt, errors.New("invalid leaf input")
+	}
+	if leafInput[0] != 0 || leafInput[1] != 0 {
+		return nil, loggedAt, errors.New("unsupported leaf version or type")
+	}
+	loggedAt = time.UnixMilli(int64(binary.BigEndian.Uint64(leafInput[2:10])))
+
+	var certData []byte
+	switch binary.BigEndian.Uint16(leafInput[10:12]) {
+	case ctLogEntryTypeX509:
+		certData, _, err = this.readUint24Bytes(leafInput[12:])
+	case ctLogEntryTypePrecert:
+		certData, _, err = this.readUint24Bytes(extraData)
+	default:
+		err = errors.New("unsupported entry type")
+	}
+	if err != nil {
+		return nil, loggedAt, err
+	}
+
+	cert, err = x509.ParseCertificate(certData)
+	return
+}
+
+// 读取以3字节长度开头的数据
+func (this *CTLogSource) readUint24Bytes(data []byte) (result []byte, rest []byte, err error) {
+	if len(data) < 3 {
+		return nil, nil, errors.New("invalid data length")
+	}
+	var length = int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+	if len(data) < 3+length {
+		return nil, nil, errors.New("invalid data length")
+	}
+	return data[3 : 3+length], data[3+length:], nil
+}
+
+func (this *CTLogSource) get(path string, result any) error {
+	resp, err := this.httpClient.Get(this.apiURL + path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected response status '" + strconv.Itoa(resp.StatusCode) + "'")
+	}
+
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return errors.New("decode response failed: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package ctlogs_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/ctlogs"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCrtShSource_FindEntriesWithDomain(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("output") != "json" {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		const entry1024 = `{"issuer_ca_id":1,"issuer_name":"C=US, O=Test CA, CN=Test","common_name":"example.com","name_value":"example.com\nwww.example.com","id":1024,"entry_timestamp":"2024-03-01T10:20:30.123","not_before":"2024-03-01T09:20:30","not_after":"2024-05-30T09:20:29","serial_number":"03e2b0"}`
+		switch req.URL.Query().Get("q") {
+		case "example.com":
+			_, _ = writer.Write([]byte(`[` + entry1024 + `]`))
+		case "%.example.com":
+			// 同一个证书可能在两次查询中都出现
+			_, _ = writer.Write([]byte(`[` + entry1024 + `,{"issuer_ca_id":1,"issuer_name":"C=US, O=Test CA, CN=Test","common_name":"a.b.example.com","name_value":"a.b.example.com","id":1025,"entry_timestamp":"2024-03-02T10:20:30","not_before":"2024-03-02T09:20:30","not_after":"2024-05-31T09:20:29","serial_number":"03e2b1"}]`))
+		default:
+			writer.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	var source = ctlogs.NewCrtShSource(server.URL+"/", nil)
+	entries, err := source.FindEntriesWithDomain("*.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatal("expect 2 entries, but got", len(entries))
+	}
+	if entries[1].Id != "1025" || entries[1].CommonName != "a.b.example.com" {
+		t.Fatalf("unexpected subdomain entry: %+v", entries[1])
+	}
+	var entry = entries[0]
+	if entry.Id != "1024" || entry.SerialNumber != "3e2b0" || len(entry.DNSNames) != 2 {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if !entry.NotBefore.Equal(time.Date(2024, 3, 1, 9, 20, 30, 0, time.UTC)) {
+		t.Fatal("unexpected not before:", entry.NotBefore)
+	}
+	if entry.LoggedAt.IsZero() {
+		t.Fatal("logged at should not be zero")
+	}
+	t.Log(entry.AllNames())
+}
+
+func TestCTLogSource_FetchEntries(t *testing.T) {
+	var certs = [][]byte{
+		testCreateCert(t, 1, "a.example.com"),
+		testCreateCert(t, 2, "b.example.com"),
+		testCreateCert(t, 3, "c.example.com"),
+	}
+
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/ct/v1/get-sth":
+			_, _ = writer.Write([]byte(`{"tree_size":` + strconv.Itoa(len(certs)) + `}`))
+		case "/ct/v1/get-entries":
+			start, _ := strconv.Atoi(req.URL.Query().Get("start"))
+			end, _ := strconv.Atoi(req.URL.Query().Get("end"))
+
+			// 每次最多返回2条
+			if end-start > 1 {
+				end = start + 1
+			}
+			if end >= len(certs) {
+				end = len(certs) - 1
+			}
+
+			type rawEntry struct {
+				LeafInput []byte `json:"leaf_input"`
+				ExtraData []byte `json:"extra_data"`
+			}
+			var result = struct {
+				Entries []rawEntry `json:"entries"`
+			}{}
+			for i := start; i <= end; i++ {
+				var leaf = make([]byte, 12)
+				binary.BigEndian.PutUint64(leaf[2:10], uint64(time.Now().UnixMilli()))
+				leaf = append(leaf, byte(len(certs[i])>>16), byte(len(certs[i])>>8), byte(len(certs[i])))
+				leaf = append(leaf, certs[i]...)
+				leaf = append(leaf, 0, 0) // extensions
+				result.Entries = append(result.Entries, rawEntry{LeafInput: leaf})
+			}
+			data, _ := json.Marshal(result)
+			_, _ = writer.Write(data)
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var source = ctlogs.NewCTLogSource(server.URL+"/ct/v1/", nil)
+	treeSize, err := source.TreeSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if treeSize != 3 {
+		t.Fatal("expect tree size 3, but got", treeSize)
+	}
+
+	var names = []string{}
+	var next int64 = 0
+	for next < treeSize {
+		entries, nextIndex, err := source.FetchEntries(next, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nextIndex <= next {
+			t.Fatal("next index should grow")
+		}
+		next = nextIndex
+		for _, entry := range entries {
+			names = append(names, entry.DNSNames...)
+			t.Log(entry.Id, entry.SerialNumber, entry.DNSNames)
+		}
+	}
+	if len(names) != 3 || names[2] != "c.example.com" {
+		t.Fatal("unexpected names:", names)
+	}
+}
+
+func testCreateCert(t *testing.T, serialNumber int64, domain string) []byte {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber: big.NewInt(serialNumber),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	certData, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certData
+}
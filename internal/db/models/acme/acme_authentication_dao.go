@@ -4,6 +4,12 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
+	"strings"
+)
+
+const (
+	ACMEAuthenticationTypeHTTP    = ""        // HTTP-01
+	ACMEAuthenticationTypeTLSALPN = "tlsAlpn" // TLS-ALPN-01
 )
 
 type ACMEAuthenticationDAO dbs.DAO
@@ -34,10 +40,51 @@ func (this *ACMEAuthenticationDAO) CreateAuth(tx *dbs.Tx, taskId int64, domain s
 	op.Domain = domain
 	op.Token = token
 	op.Key = key
+	op.Type = ACMEAuthenticationTypeHTTP
 	err := this.Save(tx, op)
 	return err
 }
 
+// CreateTLSALPNAuth 创建TLS-ALPN-01认证信息
+func (this *ACMEAuthenticationDAO) CreateTLSALPNAuth(tx *dbs.Tx, taskId int64, domain string, token string, certData []byte, keyData []byte) error {
+	var op = NewACMEAuthenticationOperator()
+	op.TaskId = taskId
+	op.Domain = strings.ToLower(domain)
+	op.Token = token
+	op.Type = ACMEAuthenticationTypeTLSALPN
+	op.CertData = certData
+	op.KeyData = keyData
+	err := this.Save(tx, op)
+	return err
+}
+
+// FindTLSALPNAuthWithDomain 根据域名查找TLS-ALPN-01认证信息
+func (this *ACMEAuthenticationDAO) FindTLSALPNAuthWithDomain(tx *dbs.Tx, domain string) (*ACMEAuthentication, error) {
+	one, err := this.Query(tx).
+		Attr("domain", strings.ToLower(domain)).
+		Attr("type", ACMEAuthenticationTypeTLSALPN).
+		DescPk().
+		Find()
+	if err != nil {
+		return nil, err
+	}
+	if one == nil {
+		return nil, nil
+	}
+	return one.(*ACMEAuthentication), nil
+}
+
+// DeleteTLSALPNAuth 删除TLS-ALPN-01认证信息
+func (this *ACMEAuthenticationDAO) DeleteTLSALPNAuth(tx *dbs.Tx, taskId int64, domain string, token string) error {
+	_, err := this.Query(tx).
+		Attr("taskId", taskId).
+		Attr("domain", strings.ToLower(domain)).
+		Attr("token", token).
+		Attr("type", ACMEAuthenticationTypeTLSALPN).
+		Delete()
+	return err
+}
+
 // 根据令牌查找认证信息
 func (this *ACMEAuthenticationDAO) FindAuthWithToken(tx *dbs.Tx, token string) (*ACMEAuthentication, error) {
 	one, err := this.Query(tx).
@@ -8,6 +8,9 @@ type ACMEAuthentication struct {
 	Token     string `field:"token"`     // 令牌
 	Key       string `field:"key"`       // 密钥
 	CreatedAt uint64 `field:"createdAt"` // 创建时间
+	Type      string `field:"type"`      // 认证类型
+	CertData  string `field:"certData"`  // TLS-ALPN-01验证证书
+	KeyData   string `field:"keyData"`   // TLS-ALPN-01验证证书私钥
 }
 
 type ACMEAuthenticationOperator struct {
@@ -17,6 +20,9 @@ type ACMEAuthenticationOperator struct {
 	Token     interface{} // 令牌
 	Key       interface{} // 密钥
 	CreatedAt interface{} // 创建时间
+	Type      interface{} // 认证类型
+	CertData  interface{} // TLS-ALPN-01验证证书
+	KeyData   interface{} // TLS-ALPN-01验证证书私钥
 }
 
 func NewACMEAuthenticationOperator() *ACMEAuthenticationOperator {
@@ -0,0 +1,172 @@
+package acme
+
+import (
+	acmeutils "github.com/TeaOSLab/EdgeAPI/internal/acme"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+const (
+	ACMEProviderStateEnabled  = 1 // 已启用
+	ACMEProviderStateDisabled = 0 // 已禁用
+)
+
+type ACMEProviderDAO dbs.DAO
+
+func NewACMEProviderDAO() *ACMEProviderDAO {
+	return dbs.NewDAO(&ACMEProviderDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeACMEProviders",
+			Model:  new(ACMEProvider),
+			PkName: "id",
+		},
+	}).(*ACMEProviderDAO)
+}
+
+var SharedACMEProviderDAO *ACMEProviderDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedACMEProviderDAO = NewACMEProviderDAO()
+	})
+}
+
+// EnableACMEProvider 启用条目
+func (this *ACMEProviderDAO) EnableACMEProvider(tx *dbs.Tx, id int64) error {
+	_, err := this.Query(tx).
+		Pk(id).
+		Set("state", ACMEProviderStateEnabled).
+		Update()
+	return err
+}
+
+// DisableACMEProvider 禁用条目
+func (this *ACMEProviderDAO) DisableACMEProvider(tx *dbs.Tx, id int64) error {
+	_, err := this.Query(tx).
+		Pk(id).
+		Set("state", ACMEProviderStateDisabled).
+		Update()
+	return err
+}
+
+// FindEnabledACMEProvider 查找启用中的条目
+func (this *ACMEProviderDAO) FindEnabledACMEProvider(tx *dbs.Tx, id int64) (*ACMEProvider, error) {
+	result, err := this.Query(tx).
+		Pk(id).
+		Attr("state", ACMEProviderStateEnabled).
+		Find()
+	if result == nil {
+		return nil, err
+	}
+	return result.(*ACMEProvider), err
+}
+
+// CreateProvider 创建自定义服务商
+func (this *ACMEProviderDAO) CreateProvider(tx *dbs.Tx, adminId int64, name string, code string, description string, apiURL string, requireEAB bool, eabDescription string, caCerts string) (int64, error) {
+	if acmeutils.IsBuiltinProviderCode(code) {
+		return 0, errors.New("provider code '" + code + "' is already used by builtin provider")
+	}
+	exists, err := this.ExistProviderCode(tx, code, 0)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, errors.New("provider code '" + code + "' already exists")
+	}
+
+	var op = NewACMEProviderOperator()
+	op.AdminId = adminId
+	op.Name = name
+	op.Code = code
+	op.Description = description
+	op.ApiURL = apiURL
+	op.RequireEAB = requireEAB
+	op.EabDescription = eabDescription
+	op.CaCerts = caCerts
+	op.IsOn = true
+	op.CreatedAt = time.Now().Unix()
+	op.State = ACMEProviderStateEnabled
+	return this.SaveInt64(tx, op)
+}
+
+// UpdateProvider 修改自定义服务商
+// 代号已经被ACME用户和账号引用，所以不允许修改
+func (this *ACMEProviderDAO) UpdateProvider(tx *dbs.Tx, providerId int64, name string, description string, apiURL string, requireEAB bool, eabDescription string, caCerts string, isOn bool) error {
+	if providerId <= 0 {
+		return errors.New("invalid providerId")
+	}
+
+	var op = NewACMEProviderOperator()
+	op.Id = providerId
+	op.Name = name
+	op.Description = description
+	op.ApiURL = apiURL
+	op.RequireEAB = requireEAB
+	op.EabDescription = eabDescription
+	op.CaCerts = caCerts
+	op.IsOn = isOn
+	return this.Save(tx, op)
+}
+
+// ExistProviderCode 检查代号是否已存在
+func (this *ACMEProviderDAO) ExistProviderCode(tx *dbs.Tx, code string, excludingProviderId int64) (bool, error) {
+	var query = this.Query(tx).
+		State(ACMEProviderStateEnabled).
+		Attr("code", code)
+	if excludingProviderId > 0 {
+		query.Neq("id", excludingProviderId)
+	}
+	return query.Exist()
+}
+
+// FindAllEnabledProviders 查找所有自定义服务商
+func (this *ACMEProviderDAO) FindAllEnabledProviders(tx *dbs.Tx) (result []*ACMEProvider, err error) {
+	_, err = this.Query(tx).
+		State(ACMEProviderStateEnabled).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindProviderWithCode 根据代号查找服务商，包括内置服务商和启用的自定义服务商
+func (this *ACMEProviderDAO) FindProviderWithCode(tx *dbs.Tx, code string) (*acmeutils.Provider, error) {
+	var provider = acmeutils.FindProviderWithCode(code)
+	if provider != nil {
+		return provider, nil
+	}
+
+	one, err := this.Query(tx).
+		State(ACMEProviderStateEnabled).
+		Attr("code", code).
+		Attr("isOn", true).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*ACMEProvider).ToProvider(), nil
+}
+
+// FindAllProviders 查找所有可用的服务商，包括内置服务商和启用的自定义服务商
+func (this *ACMEProviderDAO) FindAllProviders(tx *dbs.Tx) ([]*acmeutils.Provider, error) {
+	var providers = acmeutils.FindAllProviders()
+
+	var customProviders []*ACMEProvider
+	_, err := this.Query(tx).
+		State(ACMEProviderStateEnabled).
+		Attr("isOn", true).
+		AscPk().
+		Slice(&customProviders).
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, customProvider := range customProviders {
+		providers = append(providers, customProvider.ToProvider())
+	}
+	return providers, nil
+}
@@ -0,0 +1,29 @@
+package acme
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/iwind/TeaGo/bootstrap"
+	"github.com/iwind/TeaGo/dbs"
+	"testing"
+)
+
+func TestACMEProviderDAO_FindAllProviders(t *testing.T) {
+	dbs.NotifyReady()
+
+	var tx *dbs.Tx
+	provider, err := SharedACMEProviderDAO.FindProviderWithCode(tx, "letsencrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider == nil || provider.IsCustom {
+		t.Fatal("should find builtin provider")
+	}
+
+	providers, err := SharedACMEProviderDAO.FindAllProviders(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, provider := range providers {
+		t.Log(provider.Code, provider.Name, provider.IsCustom)
+	}
+}
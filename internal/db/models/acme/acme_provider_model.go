@@ -0,0 +1,36 @@
+package acme
+
+// ACMEProvider 自定义ACME服务商
+type ACMEProvider struct {
+	Id             uint64 `field:"id"`             // ID
+	AdminId        uint32 `field:"adminId"`        // 管理员ID
+	IsOn           bool   `field:"isOn"`           // 是否启用
+	Name           string `field:"name"`           // 名称
+	Code           string `field:"code"`           // 代号
+	Description    string `field:"description"`    // 描述
+	ApiURL         string `field:"apiURL"`         // 目录地址
+	RequireEAB     bool   `field:"requireEAB"`     // 是否需要EAB
+	EabDescription string `field:"eabDescription"` // EAB说明
+	CaCerts        string `field:"caCerts"`        // 额外信任的CA证书
+	CreatedAt      uint64 `field:"createdAt"`      // 创建时间
+	State          uint8  `field:"state"`          // 状态
+}
+
+type ACMEProviderOperator struct {
+	Id             any // ID
+	AdminId        any // 管理员ID
+	IsOn           any // 是否启用
+	Name           any // 名称
+	Code           any // 代号
+	Description    any // 描述
+	ApiURL         any // 目录地址
+	RequireEAB     any // 是否需要EAB
+	EabDescription any // EAB说明
+	CaCerts        any // 额外信任的CA证书
+	CreatedAt      any // 创建时间
+	State          any // 状态
+}
+
+func NewACMEProviderOperator() *ACMEProviderOperator {
+	return &ACMEProviderOperator{}
+}
@@ -0,0 +1,18 @@
+package acme
+
+import acmeutils "github.com/TeaOSLab/EdgeAPI/internal/acme"
+
+// ToProvider 转换为ACME服务商
+func (this *ACMEProvider) ToProvider() *acmeutils.Provider {
+	return &acmeutils.Provider{
+		Id:             int64(this.Id),
+		Name:           this.Name,
+		Code:           this.Code,
+		Description:    this.Description,
+		APIURL:         this.ApiURL,
+		RequireEAB:     this.RequireEAB,
+		EABDescription: this.EabDescription,
+		IsCustom:       true,
+		CACerts:        this.CaCerts,
+	}
+}
@@ -0,0 +1,135 @@
+package acme
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+const (
+	ACMERenewalTriggerExpiring = "expiring" // 证书即将到期
+	ACMERenewalTriggerARI      = "ari"      // 服务商建议的续期时间
+	ACMERenewalTriggerManual   = "manual"   // 手动执行
+)
+
+type ACMERenewalLogDAO dbs.DAO
+
+func NewACMERenewalLogDAO() *ACMERenewalLogDAO {
+	return dbs.NewDAO(&ACMERenewalLogDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeACMERenewalLogs",
+			Model:  new(ACMERenewalLog),
+			PkName: "id",
+		},
+	}).(*ACMERenewalLogDAO)
+}
+
+var SharedACMERenewalLogDAO *ACMERenewalLogDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedACMERenewalLogDAO = NewACMERenewalLogDAO()
+	})
+}
+
+// CreateLog 记录一次续期
+// 失败时根据上一次的记录累加连续失败次数，retryInterval用来根据连续失败次数计算下次重试的间隔
+func (this *ACMERenewalLogDAO) CreateLog(tx *dbs.Tx, adminId int64, userId int64, certId int64, taskId int64, taskLogId int64, trigger string, isOk bool, errMsg string, retryInterval func(failures int) time.Duration) (*ACMERenewalLog, error) {
+	var failures = 0
+	var nextRetryAt int64 = 0
+	if !isOk {
+		latestLog, err := this.FindLatestLogWithCertId(tx, certId)
+		if err != nil {
+			return nil, err
+		}
+		failures = 1
+		if latestLog != nil && !latestLog.IsOk {
+			failures = int(latestLog.Failures) + 1
+		}
+		if retryInterval != nil {
+			nextRetryAt = time.Now().Add(retryInterval(failures)).Unix()
+		}
+	}
+
+	var op = NewACMERenewalLogOperator()
+	op.AdminId = adminId
+	op.UserId = userId
+	op.CertId = certId
+	op.TaskId = taskId
+	op.TaskLogId = taskLogId
+	op.Trigger = trigger
+	op.IsOk = isOk
+	op.Error = utils.LimitString(errMsg, 1024)
+	op.Failures = failures
+	op.NextRetryAt = nextRetryAt
+	op.CreatedAt = time.Now().Unix()
+	logId, err := this.SaveInt64(tx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ACMERenewalLog{
+		Id:          uint64(logId),
+		AdminId:     uint32(adminId),
+		UserId:      uint32(userId),
+		CertId:      uint64(certId),
+		TaskId:      uint64(taskId),
+		TaskLogId:   uint64(taskLogId),
+		Trigger:     trigger,
+		IsOk:        isOk,
+		Error:       errMsg,
+		Failures:    uint32(failures),
+		NextRetryAt: uint64(nextRetryAt),
+	}, nil
+}
+
+// FindLatestLogWithCertId 查找证书最近一次续期记录
+func (this *ACMERenewalLogDAO) FindLatestLogWithCertId(tx *dbs.Tx, certId int64) (*ACMERenewalLog, error) {
+	one, err := this.Query(tx).
+		Attr("certId", certId).
+		DescPk().
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*ACMERenewalLog), nil
+}
+
+// CountLogs 计算续期记录数量
+func (this *ACMERenewalLogDAO) CountLogs(tx *dbs.Tx, userId int64, certId int64, taskId int64) (int64, error) {
+	var query = this.Query(tx)
+	if userId > 0 {
+		query.Attr("userId", userId)
+	}
+	if certId > 0 {
+		query.Attr("certId", certId)
+	}
+	if taskId > 0 {
+		query.Attr("taskId", taskId)
+	}
+	return query.Count()
+}
+
+// ListLogs 列出单页续期记录
+func (this *ACMERenewalLogDAO) ListLogs(tx *dbs.Tx, userId int64, certId int64, taskId int64, offset int64, size int64) (result []*ACMERenewalLog, err error) {
+	var query = this.Query(tx)
+	if userId > 0 {
+		query.Attr("userId", userId)
+	}
+	if certId > 0 {
+		query.Attr("certId", certId)
+	}
+	if taskId > 0 {
+		query.Attr("taskId", taskId)
+	}
+	_, err = query.
+		Offset(offset).
+		Limit(size).
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
@@ -0,0 +1,5 @@
+package acme
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+)
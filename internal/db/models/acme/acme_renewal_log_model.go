@@ -0,0 +1,36 @@
+package acme
+
+// ACMERenewalLog 证书续期历史
+type ACMERenewalLog struct {
+	Id          uint64 `field:"id"`          // ID
+	AdminId     uint32 `field:"adminId"`     // 管理员ID
+	UserId      uint32 `field:"userId"`      // 用户ID
+	CertId      uint64 `field:"certId"`      // 证书ID
+	TaskId      uint64 `field:"taskId"`      // 任务ID
+	TaskLogId   uint64 `field:"taskLogId"`   // 任务日志ID
+	Trigger     string `field:"trigger"`     // 触发方式
+	IsOk        bool   `field:"isOk"`        // 是否成功
+	Error       string `field:"error"`       // 错误信息
+	Failures    uint32 `field:"failures"`    // 连续失败次数
+	NextRetryAt uint64 `field:"nextRetryAt"` // 下次重试时间
+	CreatedAt   uint64 `field:"createdAt"`   // 创建时间
+}
+
+type ACMERenewalLogOperator struct {
+	Id          any // ID
+	AdminId     any // 管理员ID
+	UserId      any // 用户ID
+	CertId      any // 证书ID
+	TaskId      any // 任务ID
+	TaskLogId   any // 任务日志ID
+	Trigger     any // 触发方式
+	IsOk        any // 是否成功
+	Error       any // 错误信息
+	Failures    any // 连续失败次数
+	NextRetryAt any // 下次重试时间
+	CreatedAt   any // 创建时间
+}
+
+func NewACMERenewalLogOperator() *ACMERenewalLogOperator {
+	return &ACMERenewalLogOperator{}
+}
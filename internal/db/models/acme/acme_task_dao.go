@@ -187,7 +187,7 @@ func (this *ACMETaskDAO) ListEnabledACMETasks(tx *dbs.Tx, userId int64, isAvaila
 }
 
 // CreateACMETask 创建任务
-func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64, authType acmeutils.AuthType, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool, authURL string) (int64, error) {
+func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64, authType acmeutils.AuthType, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool, authURL string, keyType string, dualKeyType string, reuseKey bool, dnsDelegation bool) (int64, error) {
 	var op = NewACMETaskOperator()
 	op.AdminId = adminId
 	op.UserId = userId
@@ -208,6 +208,10 @@ func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64,
 
 	op.AutoRenew = autoRenew
 	op.AuthURL = authURL
+	op.KeyType = keyType
+	op.DualKeyType = dualKeyType
+	op.ReuseKey = reuseKey
+	op.DnsDelegation = dnsDelegation
 	op.IsOn = true
 	op.State = ACMETaskStateEnabled
 	err := this.Save(tx, op)
@@ -218,7 +222,7 @@ func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64,
 }
 
 // UpdateACMETask 修改任务
-func (this *ACMETaskDAO) UpdateACMETask(tx *dbs.Tx, acmeTaskId int64, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool, authURL string) error {
+func (this *ACMETaskDAO) UpdateACMETask(tx *dbs.Tx, acmeTaskId int64, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool, authURL string, keyType string, dualKeyType string, reuseKey bool, dnsDelegation bool) error {
 	if acmeTaskId <= 0 {
 		return errors.New("invalid acmeTaskId")
 	}
@@ -241,10 +245,27 @@ func (this *ACMETaskDAO) UpdateACMETask(tx *dbs.Tx, acmeTaskId int64, acmeUserId
 
 	op.AutoRenew = autoRenew
 	op.AuthURL = authURL
+	op.KeyType = keyType
+	op.DualKeyType = dualKeyType
+	op.ReuseKey = reuseKey
+	op.DnsDelegation = dnsDelegation
 	err := this.Save(tx, op)
 	return err
 }
 
+// FindACMETaskProvider 查找任务使用的服务商
+func (this *ACMETaskDAO) FindACMETaskProvider(tx *dbs.Tx, task *ACMETask) (*acmeutils.Provider, error) {
+	user, err := SharedACMEUserDAO.FindEnabledACMEUser(tx, int64(task.AcmeUserId))
+	if err != nil || user == nil {
+		return nil, err
+	}
+	var providerCode = user.ProviderCode
+	if len(providerCode) == 0 {
+		providerCode = acmeutils.DefaultProviderCode
+	}
+	return SharedACMEProviderDAO.FindProviderWithCode(tx, providerCode)
+}
+
 // CheckUserACMETask 检查用户权限
 func (this *ACMETaskDAO) CheckUserACMETask(tx *dbs.Tx, userId int64, acmeTaskId int64) (bool, error) {
 	var query = this.Query(tx)
@@ -280,12 +301,31 @@ func (this *ACMETaskDAO) UpdateACMETaskCert(tx *dbs.Tx, taskId int64, certId int
 	return err
 }
 
+// UpdateACMETaskDualCert 设置任务关联的另一种私钥类型的证书
+func (this *ACMETaskDAO) UpdateACMETaskDualCert(tx *dbs.Tx, taskId int64, certId int64) error {
+	if taskId <= 0 {
+		return errors.New("invalid taskId")
+	}
+
+	var op = NewACMETaskOperator()
+	op.Id = taskId
+	op.DualCertId = certId
+	err := this.Save(tx, op)
+	return err
+}
+
 // RunTask 执行任务并记录日志
 func (this *ACMETaskDAO) RunTask(tx *dbs.Tx, taskId int64) (isOk bool, errMsg string, resultCertId int64) {
+	isOk, errMsg, resultCertId, _ = this.RunTaskWithLogId(tx, taskId)
+	return
+}
+
+// RunTaskWithLogId 执行任务并记录日志，同时返回日志ID
+func (this *ACMETaskDAO) RunTaskWithLogId(tx *dbs.Tx, taskId int64) (isOk bool, errMsg string, resultCertId int64, taskLogId int64) {
 	isOk, errMsg, resultCertId = this.runTaskWithoutLog(tx, taskId)
 
 	// 记录日志
-	err := SharedACMETaskLogDAO.CreateACMETaskLog(tx, taskId, isOk, errMsg)
+	taskLogId, err := SharedACMETaskLogDAO.CreateACMETaskLog(tx, taskId, isOk, errMsg)
 	if err != nil {
 		logs.Error(err)
 	}
@@ -324,7 +364,11 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 	if len(user.ProviderCode) == 0 {
 		user.ProviderCode = acmeutils.DefaultProviderCode
 	}
-	var acmeProvider = acmeutils.FindProviderWithCode(user.ProviderCode)
+	acmeProvider, err := SharedACMEProviderDAO.FindProviderWithCode(tx, user.ProviderCode)
+	if err != nil {
+		errMsg = "查询服务商时出错：" + err.Error()
+		return
+	}
 	if acmeProvider == nil {
 		errMsg = "服务商已不可用"
 		return
@@ -399,11 +443,12 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 		}
 
 		acmeTask = &acmeutils.Task{
-			User:        remoteUser,
-			AuthType:    acmeutils.AuthTypeDNS,
-			DNSProvider: providerInterface,
-			DNSDomain:   task.DnsDomain,
-			Domains:     task.DecodeDomains(),
+			User:          remoteUser,
+			AuthType:      acmeutils.AuthTypeDNS,
+			DNSProvider:   providerInterface,
+			DNSDomain:     task.DnsDomain,
+			DNSDelegation: task.DnsDelegation == 1,
+			Domains:       task.DecodeDomains(),
 		}
 	} else if task.AuthType == acmeutils.AuthTypeHTTP {
 		acmeTask = &acmeutils.Task{
@@ -411,9 +456,30 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 			AuthType: acmeutils.AuthTypeHTTP,
 			Domains:  task.DecodeDomains(),
 		}
+	} else if task.AuthType == acmeutils.AuthTypeTLSALPN {
+		acmeTask = &acmeutils.Task{
+			User:     remoteUser,
+			AuthType: acmeutils.AuthTypeTLSALPN,
+			Domains:  task.DecodeDomains(),
+		}
+	} else {
+		errMsg = "不支持的认证方式 '" + task.AuthType + "'"
+		return
 	}
 	acmeTask.Provider = acmeProvider
 	acmeTask.Account = acmeAccount
+	acmeTask.KeyType = task.KeyType
+	acmeTask.DualKeyType = task.DualKeyType
+
+	// 复用私钥
+	if task.ReuseKey == 1 {
+		reuseKeys, err := this.findReuseKeys(tx, task)
+		if err != nil {
+			errMsg = "查询可复用的私钥时出错：" + err.Error()
+			return
+		}
+		acmeTask.ReuseKeys = reuseKeys
+	}
 
 	var acmeRequest = acmeutils.NewRequest(acmeTask)
 	acmeRequest.OnAuth(func(domain, token, keyAuth string) {
@@ -449,11 +515,24 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 			}
 		}
 	})
-	certData, keyData, err := acmeRequest.Run()
+	acmeRequest.OnTLSALPNAuth(func(domain, token string, certData []byte, keyData []byte) {
+		err := SharedACMEAuthenticationDAO.CreateTLSALPNAuth(tx, taskId, domain, token, certData, keyData)
+		if err != nil {
+			remotelogs.Error("ACME", "write TLS-ALPN authentication to database error: "+err.Error())
+		}
+	}, func(domain, token string) {
+		err := SharedACMEAuthenticationDAO.DeleteTLSALPNAuth(tx, taskId, domain, token)
+		if err != nil {
+			remotelogs.Error("ACME", "delete TLS-ALPN authentication from database error: "+err.Error())
+		}
+	})
+	results, err := acmeRequest.RunResults()
 	if err != nil {
 		errMsg = "证书生成失败：" + err.Error()
 		return
 	}
+	var certData = results[0].CertData
+	var keyData = results[0].KeyData
 
 	// 分析证书
 	var sslConfig = &sslconfigs.SSLCertConfig{
@@ -512,6 +591,92 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 		}
 	}
 
+	// 另一种私钥类型的证书
+	if len(results) > 1 {
+		errMsg = this.saveDualCert(tx, task, resultCertId, results[1])
+		if len(errMsg) > 0 {
+			return
+		}
+	}
+
 	isOk = true
 	return
 }
+
+// 保存另一种私钥类型的证书，并加入到主证书所在的策略中
+func (this *ACMETaskDAO) saveDualCert(tx *dbs.Tx, task *ACMETask, certId int64, result *acmeutils.Result) (errMsg string) {
+	var sslConfig = &sslconfigs.SSLCertConfig{
+		CertData: result.CertData,
+		KeyData:  result.KeyData,
+	}
+	err := sslConfig.Init(context.Background())
+	if err != nil {
+		return "证书生成成功，但是分析" + result.KeyType + "证书信息时发生错误：" + err.Error()
+	}
+
+	var dualCertId = int64(task.DualCertId)
+	if dualCertId > 0 {
+		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, dualCertId)
+		if err != nil {
+			return "证书生成成功，但查询已绑定的" + result.KeyType + "证书时出错：" + err.Error()
+		}
+		if cert != nil {
+			err = models.SharedSSLCertDAO.UpdateCert(tx, dualCertId, cert.IsOn, cert.Name, cert.Description, cert.ServerName, cert.IsCA, result.CertData, result.KeyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+			if err != nil {
+				return "证书生成成功，但是修改数据库中的" + result.KeyType + "证书信息时出错：" + err.Error()
+			}
+		} else {
+			// 证书已被删除，重新创建
+			dualCertId = 0
+		}
+	}
+
+	if dualCertId <= 0 {
+		dualCertId, err = models.SharedSSLCertDAO.CreateCert(tx, int64(task.AdminId), int64(task.UserId), true, task.DnsDomain+"免费证书（"+result.KeyType+"）", "免费申请的证书", "", false, result.CertData, result.KeyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+		if err != nil {
+			return "证书生成成功，但是保存" + result.KeyType + "证书到数据库失败：" + err.Error()
+		}
+
+		err = models.SharedSSLCertDAO.UpdateCertACME(tx, dualCertId, int64(task.Id))
+		if err != nil {
+			return "证书生成成功，修改" + result.KeyType + "证书ACME信息时出错：" + err.Error()
+		}
+
+		err = this.UpdateACMETaskDualCert(tx, int64(task.Id), dualCertId)
+		if err != nil {
+			return "证书生成成功，设置任务关联的" + result.KeyType + "证书时出错：" + err.Error()
+		}
+	}
+
+	// 加入到使用主证书的策略中
+	err = models.SharedSSLPolicyDAO.AddCertToPoliciesWithCertId(tx, certId, dualCertId)
+	if err != nil {
+		return "证书生成成功，将" + result.KeyType + "证书加入到SSL策略时出错：" + err.Error()
+	}
+
+	return ""
+}
+
+// 查找任务已有证书的私钥，用于续期时复用
+func (this *ACMETaskDAO) findReuseKeys(tx *dbs.Tx, task *ACMETask) (map[acmeutils.KeyType][]byte, error) {
+	var result = map[acmeutils.KeyType][]byte{}
+	for _, certId := range []int64{int64(task.CertId), int64(task.DualCertId)} {
+		if certId <= 0 {
+			continue
+		}
+		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, certId)
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil || len(cert.KeyData) == 0 {
+			continue
+		}
+		_, keyType, err := acmeutils.ParsePEMPrivateKey(cert.KeyData)
+		if err != nil || len(keyType) == 0 {
+			// 无法解析的私钥不再复用
+			continue
+		}
+		result[keyType] = cert.KeyData
+	}
+	return result, nil
+}
@@ -29,13 +29,12 @@ func init() {
 }
 
 // CreateACMETaskLog 生成日志
-func (this *ACMETaskLogDAO) CreateACMETaskLog(tx *dbs.Tx, taskId int64, isOk bool, errMsg string) error {
+func (this *ACMETaskLogDAO) CreateACMETaskLog(tx *dbs.Tx, taskId int64, isOk bool, errMsg string) (int64, error) {
 	var op = NewACMETaskLogOperator()
 	op.TaskId = taskId
 	op.Error = utils.LimitString(errMsg, 1024)
 	op.IsOk = isOk
-	err := this.Save(tx, op)
-	return err
+	return this.SaveInt64(tx, op)
 }
 
 // FindLatestACMETasKLog 取得任务的最后一条执行日志
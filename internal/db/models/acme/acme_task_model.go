@@ -18,6 +18,11 @@ type ACMETask struct {
 	AutoRenew     uint8    `field:"autoRenew"`     // 是否自动更新
 	AuthType      string   `field:"authType"`      // 认证类型
 	AuthURL       string   `field:"authURL"`       // 认证URL
+	KeyType       string   `field:"keyType"`       // 私钥类型
+	DualKeyType   string   `field:"dualKeyType"`   // 同时申请的另一种私钥类型
+	DualCertId    uint64   `field:"dualCertId"`    // 另一种私钥类型的证书ID
+	ReuseKey      uint8    `field:"reuseKey"`      // 续期时是否复用私钥
+	DnsDelegation uint8    `field:"dnsDelegation"` // 是否通过CNAME委托DNS验证
 }
 
 type ACMETaskOperator struct {
@@ -35,6 +40,11 @@ type ACMETaskOperator struct {
 	AutoRenew     interface{} // 是否自动更新
 	AuthType      interface{} // 认证类型
 	AuthURL       interface{} // 认证URL
+	KeyType       interface{} // 私钥类型
+	DualKeyType   interface{} // 同时申请的另一种私钥类型
+	DualCertId    interface{} // 另一种私钥类型的证书ID
+	ReuseKey      interface{} // 续期时是否复用私钥
+	DnsDelegation interface{} // 是否通过CNAME委托DNS验证
 }
 
 func NewACMETaskOperator() *ACMETaskOperator {
@@ -55,7 +55,13 @@ func (this *AdminDAO) DisableAdmin(tx *dbs.Tx, adminId int64) error {
 	}
 
 	// 删除AccessTokens
-	return SharedAPIAccessTokenDAO.DeleteAccessTokens(tx, adminId, 0)
+	err = SharedAPIAccessTokenDAO.DeleteAccessTokens(tx, adminId, 0)
+	if err != nil {
+		return err
+	}
+
+	// 强制退出登录
+	return SharedLoginSessionDAO.DeleteAllSessions(tx, adminId, 0, "")
 }
 
 // FindEnabledAdmin 查找启用中的条目
@@ -152,7 +158,12 @@ func (this *AdminDAO) UpdateAdminPassword(tx *dbs.Tx, adminId int64, password st
 	op.Id = adminId
 	op.Password = stringutil.Md5(password)
 	err := this.Save(tx, op)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// 修改密码后需要重新登录
+	return SharedLoginSessionDAO.DeleteAllSessions(tx, adminId, 0, "")
 }
 
 // CreateAdmin 创建管理员
@@ -222,6 +233,14 @@ func (this *AdminDAO) UpdateAdmin(tx *dbs.Tx, adminId int64, username string, ca
 		}
 	}
 
+	// 禁用或修改密码后需要重新登录
+	if !isOn || len(password) > 0 {
+		err = SharedLoginSessionDAO.DeleteAllSessions(tx, adminId, 0, "")
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -250,7 +269,15 @@ func (this *AdminDAO) UpdateAdminLogin(tx *dbs.Tx, adminId int64, username strin
 		op.Password = stringutil.Md5(password)
 	}
 	err := this.Save(tx, op)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// 修改密码后需要重新登录
+	if len(password) > 0 {
+		return SharedLoginSessionDAO.DeleteAllSessions(tx, adminId, 0, "")
+	}
+	return nil
 }
 
 // UpdateAdminModules 修改管理员可以管理的模块
@@ -268,6 +295,21 @@ func (this *AdminDAO) UpdateAdminModules(tx *dbs.Tx, adminId int64, allowModules
 	return nil
 }
 
+// UpdateAdminRoles 修改管理员是否为超级管理员和权限
+func (this *AdminDAO) UpdateAdminRoles(tx *dbs.Tx, adminId int64, isSuper bool, allowModulesJSON []byte) error {
+	if adminId <= 0 {
+		return errors.New("invalid adminId")
+	}
+	if len(allowModulesJSON) == 0 {
+		allowModulesJSON = []byte("[]")
+	}
+	var op = NewAdminOperator()
+	op.Id = adminId
+	op.IsSuper = isSuper
+	op.Modules = allowModulesJSON
+	return this.Save(tx, op)
+}
+
 // FindAllAdminModules 查询所有管理的权限
 func (this *AdminDAO) FindAllAdminModules(tx *dbs.Tx) (result []*Admin, err error) {
 	_, err = this.Query(tx).
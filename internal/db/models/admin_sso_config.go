@@ -0,0 +1,106 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/sso"
+	"regexp"
+)
+
+const (
+	AdminSSOConfigSettingCode = "adminSSOConfig" // 管理员单点登录配置
+
+	AdminSSOStateTTL = 600 // OIDC登录状态有效期（秒）
+)
+
+var adminSSOProviderCodeReg = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// AdminSSOConfig 管理员单点登录配置
+type AdminSSOConfig struct {
+	Providers []*AdminSSOProvider `json:"providers"` // 认证服务
+}
+
+// DefaultAdminSSOConfig 默认配置
+func DefaultAdminSSOConfig() *AdminSSOConfig {
+	return &AdminSSOConfig{
+		Providers: []*AdminSSOProvider{},
+	}
+}
+
+// Init 检查配置
+func (this *AdminSSOConfig) Init() error {
+	var codeMap = map[string]bool{}
+	for _, provider := range this.Providers {
+		if provider == nil {
+			return errors.New("provider should not be nil")
+		}
+		err := provider.Init()
+		if err != nil {
+			return errors.New("provider '" + provider.Code + "': " + err.Error())
+		}
+		if codeMap[provider.Code] {
+			return errors.New("duplicate provider code '" + provider.Code + "'")
+		}
+		codeMap[provider.Code] = true
+	}
+	return nil
+}
+
+// FindProvider 查找启用的认证服务
+func (this *AdminSSOConfig) FindProvider(code string) *AdminSSOProvider {
+	for _, provider := range this.Providers {
+		if provider.IsOn && provider.Code == code {
+			return provider
+		}
+	}
+	return nil
+}
+
+// AdminSSOProvider 外部认证服务
+type AdminSSOProvider struct {
+	Code           string             `json:"code"`           // 代号，用来关联管理员和外部身份，修改后已关联的管理员需要重新创建
+	Name           string             `json:"name"`           // 在登录页面显示的名称
+	Type           sso.ProviderType   `json:"type"`           // 类型：ldap、oidc
+	IsOn           bool               `json:"isOn"`           // 是否启用
+	LDAP           *sso.LDAPConfig    `json:"ldap"`           // LDAP配置
+	OIDC           *sso.OIDCConfig    `json:"oidc"`           // OIDC配置
+	Mappings       []*sso.RoleMapping `json:"mappings"`       // 分组和权限的映射
+	RequireMapping bool               `json:"requireMapping"` // 是否只允许匹配映射的用户登录
+	AutoCreate     bool               `json:"autoCreate"`     // 第一次登录时是否自动创建管理员
+	SyncRoles      bool               `json:"syncRoles"`      // 每次登录时是否根据映射更新管理员权限
+}
+
+// Init 检查配置
+func (this *AdminSSOProvider) Init() error {
+	if !adminSSOProviderCodeReg.MatchString(this.Code) {
+		return errors.New("invalid code, only letters, digits, '_' and '-' are allowed")
+	}
+	if len(this.Name) == 0 {
+		this.Name = this.Code
+	}
+	switch this.Type {
+	case sso.ProviderTypeLDAP:
+		if this.LDAP == nil {
+			return errors.New("'ldap' should not be nil")
+		}
+		err := this.LDAP.Init()
+		if err != nil {
+			return err
+		}
+	case sso.ProviderTypeOIDC:
+		if this.OIDC == nil {
+			return errors.New("'oidc' should not be nil")
+		}
+		err := this.OIDC.Init()
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("invalid type '" + this.Type + "'")
+	}
+	if this.Mappings == nil {
+		this.Mappings = []*sso.RoleMapping{}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/sso"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+const (
+	AdminSSOIdentityStateEnabled  = 1 // 已启用
+	AdminSSOIdentityStateDisabled = 0 // 已禁用
+)
+
+type AdminSSOIdentityDAO dbs.DAO
+
+func NewAdminSSOIdentityDAO() *AdminSSOIdentityDAO {
+	return dbs.NewDAO(&AdminSSOIdentityDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeAdminSSOIdentities",
+			Model:  new(AdminSSOIdentity),
+			PkName: "id",
+		},
+	}).(*AdminSSOIdentityDAO)
+}
+
+var SharedAdminSSOIdentityDAO *AdminSSOIdentityDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedAdminSSOIdentityDAO = NewAdminSSOIdentityDAO()
+	})
+}
+
+// FindEnabledIdentity 根据外部身份查找关联
+func (this *AdminSSOIdentityDAO) FindEnabledIdentity(tx *dbs.Tx, providerCode string, subject string) (*AdminSSOIdentity, error) {
+	one, err := this.Query(tx).
+		Attr("providerCode", providerCode).
+		Attr("subject", subject).
+		State(AdminSSOIdentityStateEnabled).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*AdminSSOIdentity), nil
+}
+
+// CreateIdentity 关联管理员和外部身份
+func (this *AdminSSOIdentityDAO) CreateIdentity(tx *dbs.Tx, adminId int64, providerCode string, identity *sso.Identity) (int64, error) {
+	if adminId <= 0 {
+		return 0, errors.New("invalid adminId")
+	}
+	if identity == nil || len(identity.Subject) == 0 {
+		return 0, errors.New("invalid identity")
+	}
+	groupsJSON, err := this.encodeGroups(identity.Groups)
+	if err != nil {
+		return 0, err
+	}
+
+	var now = time.Now().Unix()
+	var op = NewAdminSSOIdentityOperator()
+	op.AdminId = adminId
+	op.ProviderCode = providerCode
+	op.Subject = identity.Subject
+	op.Username = identity.Username
+	op.Email = identity.Email
+	op.Groups = groupsJSON
+	op.CreatedAt = now
+	op.LastLoginAt = now
+	op.State = AdminSSOIdentityStateEnabled
+	return this.SaveInt64(tx, op)
+}
+
+// UpdateIdentityLogin 记录登录信息
+func (this *AdminSSOIdentityDAO) UpdateIdentityLogin(tx *dbs.Tx, identityId int64, identity *sso.Identity) error {
+	if identityId <= 0 {
+		return errors.New("invalid identityId")
+	}
+	groupsJSON, err := this.encodeGroups(identity.Groups)
+	if err != nil {
+		return err
+	}
+	return this.Query(tx).
+		Pk(identityId).
+		Set("username", identity.Username).
+		Set("email", identity.Email).
+		Set("groups", groupsJSON).
+		Set("lastLoginAt", time.Now().Unix()).
+		UpdateQuickly()
+}
+
+func (this *AdminSSOIdentityDAO) encodeGroups(groups []string) ([]byte, error) {
+	if groups == nil {
+		groups = []string{}
+	}
+	return json.Marshal(groups)
+}
@@ -0,0 +1,34 @@
+package models
+
+import "github.com/iwind/TeaGo/dbs"
+
+// AdminSSOIdentity 管理员关联的外部身份
+type AdminSSOIdentity struct {
+	Id           uint64   `field:"id"`           // ID
+	AdminId      uint32   `field:"adminId"`      // 管理员ID
+	ProviderCode string   `field:"providerCode"` // 认证服务代号
+	Subject      string   `field:"subject"`      // 外部身份唯一标识
+	Username     string   `field:"username"`     // 外部用户名
+	Email        string   `field:"email"`        // 邮箱
+	Groups       dbs.JSON `field:"groups"`       // 分组
+	CreatedAt    uint64   `field:"createdAt"`    // 创建时间
+	LastLoginAt  uint64   `field:"lastLoginAt"`  // 最后登录时间
+	State        uint8    `field:"state"`        // 状态
+}
+
+type AdminSSOIdentityOperator struct {
+	Id           any // ID
+	AdminId      any // 管理员ID
+	ProviderCode any // 认证服务代号
+	Subject      any // 外部身份唯一标识
+	Username     any // 外部用户名
+	Email        any // 邮箱
+	Groups       any // 分组
+	CreatedAt    any // 创建时间
+	LastLoginAt  any // 最后登录时间
+	State        any // 状态
+}
+
+func NewAdminSSOIdentityOperator() *AdminSSOIdentityOperator {
+	return &AdminSSOIdentityOperator{}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "encoding/json"
+
+// DecodeGroups 解析分组
+func (this *AdminSSOIdentity) DecodeGroups() []string {
+	var result = []string{}
+	if IsNull(this.Groups) {
+		return result
+	}
+	_ = json.Unmarshal(this.Groups, &result)
+	return result
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models
+
+const (
+	APIAccessTokenConfigSettingCode = "apiAccessTokenConfig" // API访问令牌配置
+
+	APIAccessTokenMinTTL = 60 // 访问令牌最短有效期（秒）
+)
+
+// APIAccessTokenConfig API访问令牌配置
+type APIAccessTokenConfig struct {
+	DefaultTTL      int64 `json:"defaultTTL"`      // 默认有效期（秒）
+	MaxTTL          int64 `json:"maxTTL"`          // 最长有效期（秒）
+	RefreshTTL      int64 `json:"refreshTTL"`      // 刷新令牌有效期（秒）
+	MaxTokensPerKey int   `json:"maxTokensPerKey"` // 每个AccessKey最多同时有效的令牌数，超出后删除最早的令牌
+}
+
+// DefaultAPIAccessTokenConfig 默认配置
+func DefaultAPIAccessTokenConfig() *APIAccessTokenConfig {
+	return &APIAccessTokenConfig{
+		DefaultTTL:      7200,
+		MaxTTL:          86400,
+		RefreshTTL:      30 * 86400,
+		MaxTokensPerKey: 100,
+	}
+}
+
+// Init 整理配置
+// 不合法的值使用默认值代替
+func (this *APIAccessTokenConfig) Init() {
+	var defaultConfig = DefaultAPIAccessTokenConfig()
+	if this.MaxTTL < APIAccessTokenMinTTL {
+		this.MaxTTL = defaultConfig.MaxTTL
+	}
+	if this.DefaultTTL < APIAccessTokenMinTTL {
+		this.DefaultTTL = defaultConfig.DefaultTTL
+	}
+	if this.DefaultTTL > this.MaxTTL {
+		this.DefaultTTL = this.MaxTTL
+	}
+	if this.RefreshTTL < 0 {
+		this.RefreshTTL = defaultConfig.RefreshTTL
+	}
+	if this.MaxTokensPerKey <= 0 {
+		this.MaxTokensPerKey = defaultConfig.MaxTokensPerKey
+	}
+}
@@ -1,11 +1,16 @@
 package models
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
-	"github.com/iwind/TeaGo/rands"
+	"strconv"
 	"time"
 )
 
@@ -30,11 +35,21 @@ func init() {
 	})
 }
 
+// GeneratedAPIAccessToken 生成的令牌
+// 令牌明文只在生成时返回，数据库中只保存哈希值
+type GeneratedAPIAccessToken struct {
+	Token            string
+	ExpiresAt        int64
+	RefreshToken     string
+	RefreshExpiresAt int64
+	Scopes           []string
+}
+
 // GenerateAccessToken 生成AccessToken
-func (this *APIAccessTokenDAO) GenerateAccessToken(tx *dbs.Tx, adminId int64, userId int64) (token string, expiresAt int64, err error) {
+// 同一个AccessKey可以同时拥有多个令牌；ttl为0时使用默认有效期
+func (this *APIAccessTokenDAO) GenerateAccessToken(tx *dbs.Tx, adminId int64, userId int64, accessKeyId int64, scopes []string, ttl int64) (*GeneratedAPIAccessToken, error) {
 	if adminId <= 0 && userId <= 0 {
-		err = errors.New("either 'adminId' or 'userId' should not be zero")
-		return
+		return nil, errors.New("either 'adminId' or 'userId' should not be zero")
 	}
 
 	if adminId > 0 {
@@ -44,37 +59,118 @@ func (this *APIAccessTokenDAO) GenerateAccessToken(tx *dbs.Tx, adminId int64, us
 		adminId = 0
 	}
 
-	// 查询以前的
-	accessToken, err := this.Query(tx).
-		Attr("adminId", adminId).
-		Attr("userId", userId).
-		Find()
+	config, err := SharedSysSettingDAO.ReadAPIAccessTokenConfig(tx)
 	if err != nil {
-		return "", 0, err
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = config.DefaultTTL
+	}
+	if ttl < APIAccessTokenMinTTL || ttl > config.MaxTTL {
+		return nil, errors.New("ttl should be between " + strconv.Itoa(APIAccessTokenMinTTL) + " and " + strconv.FormatInt(config.MaxTTL, 10) + " seconds")
 	}
 
-	token = rands.String(128) // TODO 增强安全性，将来使用 base64_encode(encrypt(salt+random)) 算法来代替
-	expiresAt = time.Now().Unix() + 7200
-
-	var op = NewAPIAccessTokenOperator()
+	if scopes == nil {
+		scopes = []string{}
+	}
+	scopesJSON, err := json.Marshal(scopes)
+	if err != nil {
+		return nil, err
+	}
 
-	if accessToken != nil {
-		op.Id = accessToken.(*APIAccessToken).Id
+	result, op, err := this.newToken(ttl, config.RefreshTTL)
+	if err != nil {
+		return nil, err
 	}
+	result.Scopes = scopes
 
 	op.AdminId = adminId
 	op.UserId = userId
-	op.Token = token
-	op.CreatedAt = time.Now().Unix()
-	op.ExpiredAt = expiresAt
+	op.AccessKeyId = accessKeyId
+	op.Scopes = scopesJSON
 	err = this.Save(tx, op)
-	return
+	if err != nil {
+		return nil, err
+	}
+
+	// 清理过期的和超出数量的令牌
+	err = this.cleanAccessTokens(tx, adminId, userId, accessKeyId, config.MaxTokensPerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RefreshAccessToken 使用刷新令牌换取新的令牌
+// 旧的令牌和刷新令牌会同时失效，新的令牌保持原来的访问范围和有效期长度
+// 刷新令牌已经被使用过时返回nil
+func (this *APIAccessTokenDAO) RefreshAccessToken(tx *dbs.Tx, refreshToken string) (*APIAccessToken, *GeneratedAPIAccessToken, error) {
+	if len(refreshToken) == 0 {
+		return nil, nil, nil
+	}
+
+	one, err := this.Query(tx).
+		Attr("refreshToken", this.hashToken(refreshToken)).
+		Gt("refreshExpiredAt", time.Now().Unix()).
+		Find()
+	if err != nil || one == nil {
+		return nil, nil, err
+	}
+	var accessToken = one.(*APIAccessToken)
+
+	config, err := SharedSysSettingDAO.ReadAPIAccessTokenConfig(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ttl = int64(accessToken.ExpiredAt) - int64(accessToken.CreatedAt)
+	if ttl < APIAccessTokenMinTTL || ttl > config.MaxTTL {
+		ttl = config.DefaultTTL
+	}
+	result, op, err := this.newToken(ttl, config.RefreshTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	result.Scopes = accessToken.DecodeScopes()
+
+	// 只有刷新令牌没有变化时才更新，以防止同一个刷新令牌被并发使用多次
+	rows, err := this.Query(tx).
+		Pk(accessToken.Id).
+		Attr("refreshToken", accessToken.RefreshToken).
+		Set("token", op.Token).
+		Set("createdAt", op.CreatedAt).
+		Set("expiredAt", op.ExpiredAt).
+		Set("refreshToken", op.RefreshToken).
+		Set("refreshExpiredAt", op.RefreshExpiredAt).
+		Update()
+	if err != nil {
+		return nil, nil, err
+	}
+	if rows <= 0 {
+		return nil, nil, nil
+	}
+	return accessToken, result, nil
 }
 
 // FindAccessToken 查找AccessToken
 func (this *APIAccessTokenDAO) FindAccessToken(tx *dbs.Tx, token string) (*APIAccessToken, error) {
+	if len(token) == 0 {
+		return nil, nil
+	}
+	one, err := this.Query(tx).
+		Attr("token", this.hashToken(token)).
+		Find()
+	if one == nil || err != nil {
+		return nil, err
+	}
+	return one.(*APIAccessToken), nil
+}
+
+// FindEnabledAccessToken 查找单个令牌
+func (this *APIAccessTokenDAO) FindEnabledAccessToken(tx *dbs.Tx, tokenId int64) (*APIAccessToken, error) {
 	one, err := this.Query(tx).
-		Attr("token", token).
+		Pk(tokenId).
 		Find()
 	if one == nil || err != nil {
 		return nil, err
@@ -82,6 +178,52 @@ func (this *APIAccessTokenDAO) FindAccessToken(tx *dbs.Tx, token string) (*APIAc
 	return one.(*APIAccessToken), nil
 }
 
+// FindAllAccessTokensWithAccessKeyId 查找某个AccessKey的所有未过期的令牌
+func (this *APIAccessTokenDAO) FindAllAccessTokensWithAccessKeyId(tx *dbs.Tx, accessKeyId int64) (result []*APIAccessToken, err error) {
+	var now = time.Now().Unix()
+	_, err = this.Query(tx).
+		Attr("accessKeyId", accessKeyId).
+		Where("(expiredAt>=:now OR refreshExpiredAt>=:now)").
+		Param("now", now).
+		Result("id", "adminId", "userId", "accessKeyId", "scopes", "createdAt", "expiredAt", "refreshExpiredAt").
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// RevokeAccessToken 使用令牌或刷新令牌撤销令牌
+func (this *APIAccessTokenDAO) RevokeAccessToken(tx *dbs.Tx, token string) error {
+	if len(token) == 0 {
+		return nil
+	}
+	var tokenHash = this.hashToken(token)
+	return this.Query(tx).
+		Where("(token=:token OR refreshToken=:token)").
+		Param("token", tokenHash).
+		DeleteQuickly()
+}
+
+// DeleteAccessToken 删除单个令牌
+func (this *APIAccessTokenDAO) DeleteAccessToken(tx *dbs.Tx, tokenId int64) error {
+	if tokenId <= 0 {
+		return nil
+	}
+	return this.Query(tx).
+		Pk(tokenId).
+		DeleteQuickly()
+}
+
+// DeleteAccessTokensWithAccessKeyId 删除某个AccessKey的所有令牌
+func (this *APIAccessTokenDAO) DeleteAccessTokensWithAccessKeyId(tx *dbs.Tx, accessKeyId int64) error {
+	if accessKeyId <= 0 {
+		return nil
+	}
+	return this.Query(tx).
+		Attr("accessKeyId", accessKeyId).
+		DeleteQuickly()
+}
+
 // DeleteAccessTokens 删除用户的令牌
 func (this *APIAccessTokenDAO) DeleteAccessTokens(tx *dbs.Tx, adminId int64, userId int64) error {
 	var query = this.Query(tx)
@@ -94,3 +236,90 @@ func (this *APIAccessTokenDAO) DeleteAccessTokens(tx *dbs.Tx, adminId int64, use
 	}
 	return query.DeleteQuickly()
 }
+
+// 构造新的令牌
+func (this *APIAccessTokenDAO) newToken(ttl int64, refreshTTL int64) (*GeneratedAPIAccessToken, *APIAccessTokenOperator, error) {
+	token, err := this.randomToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var now = time.Now().Unix()
+	var result = &GeneratedAPIAccessToken{
+		Token:     token,
+		ExpiresAt: now + ttl,
+	}
+
+	var op = NewAPIAccessTokenOperator()
+	op.Token = this.hashToken(token)
+	op.CreatedAt = now
+	op.ExpiredAt = result.ExpiresAt
+
+	// 刷新令牌
+	if refreshTTL > 0 {
+		refreshToken, err := this.randomToken()
+		if err != nil {
+			return nil, nil, err
+		}
+		result.RefreshToken = refreshToken
+		result.RefreshExpiresAt = now + refreshTTL
+		op.RefreshToken = this.hashToken(refreshToken)
+	} else {
+		op.RefreshToken = ""
+	}
+	op.RefreshExpiredAt = result.RefreshExpiresAt
+	return result, op, nil
+}
+
+// 清理过期的令牌，并删除超出数量的最早的令牌
+func (this *APIAccessTokenDAO) cleanAccessTokens(tx *dbs.Tx, adminId int64, userId int64, accessKeyId int64, maxTokens int) error {
+	var now = time.Now().Unix()
+	err := this.Query(tx).
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Attr("accessKeyId", accessKeyId).
+		Lt("expiredAt", now).
+		Lt("refreshExpiredAt", now).
+		DeleteQuickly()
+	if err != nil {
+		return err
+	}
+
+	if maxTokens <= 0 {
+		return nil
+	}
+	ones, err := this.Query(tx).
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Attr("accessKeyId", accessKeyId).
+		ResultPk().
+		DescPk().
+		Offset(int64(maxTokens)).
+		Limit(1000).
+		FindAll()
+	if err != nil {
+		return err
+	}
+	for _, one := range ones {
+		err = this.DeleteAccessToken(tx, int64(one.(*APIAccessToken).Id))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 生成随机令牌
+func (this *APIAccessTokenDAO) randomToken() (string, error) {
+	var data = make([]byte, 48)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// 计算令牌的哈希值
+func (this *APIAccessTokenDAO) hashToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
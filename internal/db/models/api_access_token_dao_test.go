@@ -2,4 +2,38 @@ package models
 
 import (
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/iwind/TeaGo/bootstrap"
+	"github.com/iwind/TeaGo/dbs"
+	"testing"
 )
+
+func TestAPIAccessTokenDAO_RefreshAccessToken(t *testing.T) {
+	var dao = NewAPIAccessTokenDAO()
+	var tx *dbs.Tx
+
+	token, err := dao.GenerateAccessToken(tx, 1, 0, 0, nil, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token.RefreshToken) == 0 {
+		t.Log("refresh token is disabled")
+		return
+	}
+
+	_, newToken, err := dao.RefreshAccessToken(tx, token.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newToken == nil {
+		t.Fatal("refresh token should be accepted")
+	}
+
+	// 同一个刷新令牌只能使用一次
+	_, newToken2, err := dao.RefreshAccessToken(tx, token.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newToken2 != nil {
+		t.Fatal("refresh token should not be reused")
+	}
+}
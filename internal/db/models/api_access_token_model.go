@@ -1,22 +1,32 @@
 package models
 
+import "github.com/iwind/TeaGo/dbs"
+
 // APIAccessToken API访问令牌
 type APIAccessToken struct {
-	Id        uint64 `field:"id"`        // ID
-	UserId    uint32 `field:"userId"`    // 用户ID
-	AdminId   uint32 `field:"adminId"`   // 管理员ID
-	Token     string `field:"token"`     // 令牌
-	CreatedAt uint64 `field:"createdAt"` // 创建时间
-	ExpiredAt uint64 `field:"expiredAt"` // 过期时间
+	Id               uint64   `field:"id"`               // ID
+	UserId           uint32   `field:"userId"`           // 用户ID
+	AdminId          uint32   `field:"adminId"`          // 管理员ID
+	AccessKeyId      uint32   `field:"accessKeyId"`      // AccessKey ID
+	Token            string   `field:"token"`            // 令牌（哈希值）
+	Scopes           dbs.JSON `field:"scopes"`           // 访问范围
+	RefreshToken     string   `field:"refreshToken"`     // 刷新令牌（哈希值）
+	CreatedAt        uint64   `field:"createdAt"`        // 创建时间
+	ExpiredAt        uint64   `field:"expiredAt"`        // 过期时间
+	RefreshExpiredAt uint64   `field:"refreshExpiredAt"` // 刷新令牌过期时间
 }
 
 type APIAccessTokenOperator struct {
-	Id        interface{} // ID
-	UserId    interface{} // 用户ID
-	AdminId   interface{} // 管理员ID
-	Token     interface{} // 令牌
-	CreatedAt interface{} // 创建时间
-	ExpiredAt interface{} // 过期时间
+	Id               interface{} // ID
+	UserId           interface{} // 用户ID
+	AdminId          interface{} // 管理员ID
+	AccessKeyId      interface{} // AccessKey ID
+	Token            interface{} // 令牌（哈希值）
+	Scopes           interface{} // 访问范围
+	RefreshToken     interface{} // 刷新令牌（哈希值）
+	CreatedAt        interface{} // 创建时间
+	ExpiredAt        interface{} // 过期时间
+	RefreshExpiredAt interface{} // 刷新令牌过期时间
 }
 
 func NewAPIAccessTokenOperator() *APIAccessTokenOperator {
@@ -1 +1,12 @@
 package models
+
+import "encoding/json"
+
+// DecodeScopes 解析访问范围
+func (this *APIAccessToken) DecodeScopes() []string {
+	var scopes = []string{}
+	if IsNotNull(this.Scopes) {
+		_ = json.Unmarshal(this.Scopes, &scopes)
+	}
+	return scopes
+}
@@ -114,6 +114,15 @@ func (this *APINodeDAO) FindEnabledAPINodeWithUniqueIdAndSecret(tx *dbs.Tx, uniq
 	return one.(*APINode), nil
 }
 
+// FindEnabledAPINodeIdWithUniqueId 根据唯一ID查找节点ID
+func (this *APINodeDAO) FindEnabledAPINodeIdWithUniqueId(tx *dbs.Tx, uniqueId string) (int64, error) {
+	return this.Query(tx).
+		State(APINodeStateEnabled).
+		Attr("uniqueId", uniqueId).
+		ResultPk().
+		FindInt64Col(0)
+}
+
 // FindAPINodeName 根据主键查找名称
 func (this *APINodeDAO) FindAPINodeName(tx *dbs.Tx, id int64) (string, error) {
 	return this.Query(tx).
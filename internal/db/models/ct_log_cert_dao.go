@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/ctlogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+type CTLogCertDAO dbs.DAO
+
+func NewCTLogCertDAO() *CTLogCertDAO {
+	return dbs.NewDAO(&CTLogCertDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeCTLogCerts",
+			Model:  new(CTLogCert),
+			PkName: "id",
+		},
+	}).(*CTLogCertDAO)
+}
+
+var SharedCTLogCertDAO *CTLogCertDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedCTLogCertDAO = NewCTLogCertDAO()
+	})
+}
+
+// CreateCertIfNotExists 记录发现的证书
+// 同一个管理员或用户的同一个证书只记录一次，预签名证书和正式证书的序列号相同，所以也只会记录一次
+func (this *CTLogCertDAO) CreateCertIfNotExists(tx *dbs.Tx, adminId int64, userId int64, domain string, sourceType string, entry *ctlogs.Entry) (isCreated bool, err error) {
+	exists, err := this.Query(tx).
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Attr("serialNumber", entry.SerialNumber).
+		Attr("issuerName", utils.LimitString(entry.IssuerName, 255)).
+		Exist()
+	if err != nil || exists {
+		return false, err
+	}
+
+	dnsNamesJSON, err := json.Marshal(entry.AllNames())
+	if err != nil {
+		return false, err
+	}
+
+	var op = NewCTLogCertOperator()
+	op.AdminId = adminId
+	op.UserId = userId
+	op.Domain = domain
+	op.DnsNames = dnsNamesJSON
+	op.CommonName = utils.LimitString(entry.CommonName, 255)
+	op.IssuerName = utils.LimitString(entry.IssuerName, 255)
+	op.SerialNumber = entry.SerialNumber
+	op.NotBefore = this.unixTime(entry.NotBefore)
+	op.NotAfter = this.unixTime(entry.NotAfter)
+	op.LoggedAt = this.unixTime(entry.LoggedAt)
+	op.SourceType = sourceType
+	op.EntryId = entry.Id
+	op.CreatedAt = time.Now().Unix()
+	err = this.Save(tx, op)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CountCerts 计算发现的证书数量
+func (this *CTLogCertDAO) CountCerts(tx *dbs.Tx, userId int64, domain string) (int64, error) {
+	var query = this.Query(tx)
+	if userId > 0 {
+		query.Attr("userId", userId)
+	}
+	if len(domain) > 0 {
+		query.Attr("domain", domain)
+	}
+	return query.Count()
+}
+
+// ListCerts 列出单页发现的证书
+func (this *CTLogCertDAO) ListCerts(tx *dbs.Tx, userId int64, domain string, offset int64, size int64) (result []*CTLogCert, err error) {
+	var query = this.Query(tx)
+	if userId > 0 {
+		query.Attr("userId", userId)
+	}
+	if len(domain) > 0 {
+		query.Attr("domain", domain)
+	}
+	_, err = query.
+		Offset(offset).
+		Limit(size).
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+func (this *CTLogCertDAO) unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,42 @@
+package models
+
+import "github.com/iwind/TeaGo/dbs"
+
+// CTLogCert 在证书透明度日志中发现的非本系统签发的证书
+type CTLogCert struct {
+	Id           uint64   `field:"id"`           // ID
+	AdminId      uint32   `field:"adminId"`      // 管理员ID
+	UserId       uint32   `field:"userId"`       // 用户ID
+	Domain       string   `field:"domain"`       // 匹配的域名
+	DnsNames     dbs.JSON `field:"dnsNames"`     // 证书中的域名
+	CommonName   string   `field:"commonName"`   // 证书CN
+	IssuerName   string   `field:"issuerName"`   // 颁发机构
+	SerialNumber string   `field:"serialNumber"` // 序列号
+	NotBefore    uint64   `field:"notBefore"`    // 生效时间
+	NotAfter     uint64   `field:"notAfter"`     // 过期时间
+	LoggedAt     uint64   `field:"loggedAt"`     // 记录到日志的时间
+	SourceType   string   `field:"sourceType"`   // 来源类型
+	EntryId      string   `field:"entryId"`      // 来源中的记录ID
+	CreatedAt    uint64   `field:"createdAt"`    // 创建时间
+}
+
+type CTLogCertOperator struct {
+	Id           any // ID
+	AdminId      any // 管理员ID
+	UserId       any // 用户ID
+	Domain       any // 匹配的域名
+	DnsNames     any // 证书中的域名
+	CommonName   any // 证书CN
+	IssuerName   any // 颁发机构
+	SerialNumber any // 序列号
+	NotBefore    any // 生效时间
+	NotAfter     any // 过期时间
+	LoggedAt     any // 记录到日志的时间
+	SourceType   any // 来源类型
+	EntryId      any // 来源中的记录ID
+	CreatedAt    any // 创建时间
+}
+
+func NewCTLogCertOperator() *CTLogCertOperator {
+	return &CTLogCertOperator{}
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/ctlogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"net/url"
+	"time"
+)
+
+const (
+	CTLogMonitorConfigSettingCode = "ctLogMonitorConfig" // 证书透明度日志监控配置
+	CTLogMonitorStateSettingCode  = "ctLogMonitorState"  // 证书透明度日志读取位置
+)
+
+// CTLogMonitorConfig 证书透明度日志监控配置
+type CTLogMonitorConfig struct {
+	IsOn              bool              `json:"isOn"`              // 是否启用
+	SourceType        ctlogs.SourceType `json:"sourceType"`        // 来源类型
+	APIURL            string            `json:"apiURL"`            // 接口地址
+	IntervalMinutes   int               `json:"intervalMinutes"`   // 检查间隔（分钟）
+	RequestIntervalMs int               `json:"requestIntervalMs"` // 两次请求之间的间隔（毫秒），避免触发接口的频率限制
+	MaxEntriesPerLoop int               `json:"maxEntriesPerLoop"` // 每次检查最多读取的日志条数，仅对CT Log有效；没有读取完时每次定时任务（10分钟）都会继续读取，直到追上日志的最新位置
+	SinceAt           int64             `json:"sinceAt"`           // 只提醒在此时间之后签发的证书
+}
+
+// DefaultCTLogMonitorConfig 默认配置
+func DefaultCTLogMonitorConfig() *CTLogMonitorConfig {
+	return &CTLogMonitorConfig{
+		IsOn:              false,
+		SourceType:        ctlogs.SourceTypeCrtSh,
+		APIURL:            "https://crt.sh",
+		IntervalMinutes:   6 * 60,
+		RequestIntervalMs: 1000,
+		MaxEntriesPerLoop: 10000,
+	}
+}
+
+// Init 校验配置
+func (this *CTLogMonitorConfig) Init() error {
+	if this.SourceType != ctlogs.SourceTypeCrtSh && this.SourceType != ctlogs.SourceTypeCTLog {
+		return errors.New("invalid source type '" + this.SourceType + "'")
+	}
+	u, err := url.Parse(this.APIURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return errors.New("invalid api url '" + this.APIURL + "'")
+	}
+	if this.IntervalMinutes <= 0 {
+		return errors.New("interval minutes should be greater than 0")
+	}
+	if this.RequestIntervalMs < 0 {
+		this.RequestIntervalMs = 0
+	}
+	if this.MaxEntriesPerLoop <= 0 {
+		return errors.New("max entries per loop should be greater than 0")
+	}
+	if this.IsOn && this.SinceAt <= 0 {
+		this.SinceAt = time.Now().Unix()
+	}
+	return nil
+}
+
+// CTLogMonitorState 证书透明度日志读取状态
+type CTLogMonitorState struct {
+	APIURL    string `json:"apiURL"`    // 日志地址，地址变化后重新开始读取
+	NextIndex int64  `json:"nextIndex"` // 下一次读取的位置
+}
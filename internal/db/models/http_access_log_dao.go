@@ -22,6 +22,7 @@ import (
 	"github.com/iwind/TeaGo/types"
 	timeutil "github.com/iwind/TeaGo/utils/time"
 	"golang.org/x/net/idna"
+	"google.golang.org/protobuf/proto"
 	"net"
 	"net/http"
 	"net/url"
@@ -69,6 +70,9 @@ func init() {
 
 	// 队列相关
 	dbs.OnReadyDone(func() {
+		// 磁盘队列
+		setupAccessLogSpool()
+
 		// 检查队列变化
 		goman.New(func() {
 			var ticker = time.NewTicker(60 * time.Second)
@@ -134,6 +138,8 @@ func NewHTTPAccessLogDAO() *HTTPAccessLogDAO {
 func (this *HTTPAccessLogDAO) CreateHTTPAccessLogs(tx *dbs.Tx, accessLogs []*pb.HTTPAccessLog) error {
 	// 写入队列
 	var queue = accessLogQueue // 这样写非常重要，防止在写入过程中队列有切换
+	var s = accessLogSpool
+	var sampledAccessLogs = make([]*pb.HTTPAccessLog, 0, len(accessLogs))
 	for _, accessLog := range accessLogs {
 		if accessLog.FirewallPolicyId == 0 { // 如果是非WAF记录，则采取采样率
 			// 采样率
@@ -145,6 +151,11 @@ func (this *HTTPAccessLogDAO) CreateHTTPAccessLogs(tx *dbs.Tx, accessLogs []*pb.
 			}
 		}
 
+		if s != nil {
+			sampledAccessLogs = append(sampledAccessLogs, accessLog)
+			continue
+		}
+
 		select {
 		case queue <- accessLog:
 		default:
@@ -152,6 +163,21 @@ func (this *HTTPAccessLogDAO) CreateHTTPAccessLogs(tx *dbs.Tx, accessLogs []*pb.
 		}
 	}
 
+	// 写入磁盘队列，失败时退回到内存队列
+	if len(sampledAccessLogs) > 0 {
+		err := writeAccessLogsToSpool(s, sampledAccessLogs)
+		if err != nil {
+			remotelogs.Error("HTTP_ACCESS_LOG_QUEUE", "write access logs to spool failed: "+err.Error())
+			for _, accessLog := range sampledAccessLogs {
+				select {
+				case queue <- accessLog:
+				default:
+					// 超出的丢弃
+				}
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -161,27 +187,18 @@ func (this *HTTPAccessLogDAO) DumpAccessLogsFromQueue(size int) (hasMore bool, e
 		size = 100
 	}
 
+	// 内存队列为空时再导入磁盘队列
 	if len(oldAccessLogQueue) == 0 && len(accessLogQueue) == 0 {
+		var s = accessLogSpool
+		if s != nil {
+			return this.dumpAccessLogsFromSpool(s, size)
+		}
 		return false, nil
 	}
 
-	var dao = randomHTTPAccessLogDAO()
-	if dao == nil {
-		dao = &HTTPAccessLogDAOWrapper{
-			DAO:    SharedHTTPAccessLogDAO,
-			NodeId: 0,
-		}
-
-		// 检查本地数据库空间
-		if dbutils.IsLocalDatabase && !dbutils.HasFreeSpace {
-			return false, errors.New("dump accesslog failed: there is no enough space left for database (" + dbutils.LocalDatabaseDataDir + ")")
-		}
-	} else if dao.IsLocal {
-		// 检查本地数据库空间
-		// 我们假定本地只能安装一个数据库，访问日志中的数据库和当前API连接的数据库一致
-		if !dbutils.HasFreeSpace {
-			return true, errors.New("dump accesslog failed: there is no enough space left for database (" + dbutils.LocalDatabaseDataDir + ")")
-		}
+	dao, err := this.findDumpingDAO()
+	if err != nil {
+		return false, err
 	}
 
 	// 开始事务
@@ -256,8 +273,10 @@ func (this *HTTPAccessLogDAO) CreateHTTPAccessLog(tx *dbs.Tx, dao *HTTPAccessLog
 	fields["firewallRuleSetId"] = accessLog.FirewallRuleSetId
 	fields["firewallRuleId"] = accessLog.FirewallRuleId
 
+	// 日志同时会被存储策略异步读取，所以这里不能直接修改原日志
 	if len(accessLog.RequestBody) > 0 {
 		fields["requestBody"] = accessLog.RequestBody
+		accessLog = proto.Clone(accessLog).(*pb.HTTPAccessLog)
 		accessLog.RequestBody = nil
 	}
 
@@ -0,0 +1,246 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models
+
+import (
+	teaconst "github.com/TeaOSLab/EdgeAPI/internal/const"
+	dbutils "github.com/TeaOSLab/EdgeAPI/internal/db/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/events"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/spool"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/types"
+	"google.golang.org/protobuf/proto"
+	"time"
+)
+
+// 本地磁盘队列
+// 启用后访问日志先写入磁盘，导入数据库成功后再提交处理位置，API节点重启或数据库短暂不可用时不会丢失日志
+var (
+	accessLogSpool             *spool.Spool
+	accessLogSpoolHeadFails    = 0 // 队列头部记录连续写入失败的次数
+	accessLogSpoolMaxHeadFails = 3 // 同一条记录因为数据问题连续失败超过此次数则跳过
+)
+
+// 日志数据本身有问题时的错误代码，这些错误重试也不会成功
+var accessLogSpoolDataErrCodes = []uint16{
+	1048, // Column cannot be null
+	1062, // Duplicate entry
+	1264, // Out of range value
+	1265, // Data truncated
+	1292, // Incorrect value
+	1366, // Incorrect string value
+	1406, // Data too long
+	1451, // Cannot delete or update a parent row: a foreign key constraint fails
+	1452, // Cannot add or update a child row: a foreign key constraint fails
+	3140, // Invalid JSON text
+	3819, // Check constraint is violated
+}
+
+// HTTPAccessLogQueueStats 访问日志队列积压信息
+type HTTPAccessLogQueueStats struct {
+	MemoryCount  int64     // 内存队列中的日志数
+	SpoolIsOn    bool      // 是否启用了磁盘队列
+	SpoolCount   int64     // 磁盘队列中未处理的日志数
+	SpoolSize    int64     // 磁盘队列中未处理的数据尺寸
+	SpoolFiles   int       // 磁盘队列分段文件数
+	OldestAt     time.Time // 磁盘队列中最早日志的写入时间
+	DroppedCount int64     // 因为超出磁盘空间上限而丢弃的日志数
+}
+
+// 启动磁盘队列
+func setupAccessLogSpool() {
+	if !teaconst.IsMain {
+		return
+	}
+
+	config, err := SharedSysSettingDAO.ReadHTTPAccessLogSpoolConfig(nil)
+	if err != nil {
+		remotelogs.Error("HTTP_ACCESS_LOG_QUEUE", "read spool config failed: "+err.Error())
+		return
+	}
+	if !config.IsOn {
+		return
+	}
+
+	s, err := spool.Open(Tea.Root+"/data/accesslogs-spool", &spool.Options{
+		SegmentSize: config.SegmentSize,
+		MaxSize:     config.MaxSize,
+	})
+	if err != nil {
+		remotelogs.Error("HTTP_ACCESS_LOG_QUEUE", "open spool failed: "+err.Error())
+		return
+	}
+	var stats = s.Stats()
+	if stats.Count > 0 {
+		remotelogs.Println("HTTP_ACCESS_LOG_QUEUE", "replay "+types.String(stats.Count)+" access logs from spool")
+	}
+	accessLogSpool = s
+
+	// 退出时将内存中的日志转存到磁盘
+	events.On(events.EventQuit, func() {
+		var accessLogs = []*pb.HTTPAccessLog{}
+		for _, queue := range []chan *pb.HTTPAccessLog{oldAccessLogQueue, accessLogQueue} {
+		Loop:
+			for {
+				select {
+				case accessLog := <-queue:
+					accessLogs = append(accessLogs, accessLog)
+				default:
+					break Loop
+				}
+			}
+		}
+		if len(accessLogs) > 0 {
+			err := writeAccessLogsToSpool(s, accessLogs)
+			if err != nil {
+				remotelogs.Error("HTTP_ACCESS_LOG_QUEUE", "save access logs to spool failed: "+err.Error())
+			}
+		}
+		_ = s.Close()
+	})
+}
+
+// 写入日志到磁盘队列
+func writeAccessLogsToSpool(s *spool.Spool, accessLogs []*pb.HTTPAccessLog) error {
+	var dataList = make([][]byte, 0, len(accessLogs))
+	for _, accessLog := range accessLogs {
+		data, err := proto.Marshal(accessLog)
+		if err != nil {
+			return err
+		}
+		dataList = append(dataList, data)
+	}
+	return s.Write(dataList...)
+}
+
+// FindHTTPAccessLogQueueStats 读取访问日志队列积压信息
+func (this *HTTPAccessLogDAO) FindHTTPAccessLogQueueStats() *HTTPAccessLogQueueStats {
+	var result = &HTTPAccessLogQueueStats{
+		MemoryCount: int64(len(oldAccessLogQueue) + len(accessLogQueue)),
+	}
+	var s = accessLogSpool
+	if s != nil {
+		var stats = s.Stats()
+		result.SpoolIsOn = true
+		result.SpoolCount = stats.Count
+		result.SpoolSize = stats.Size
+		result.SpoolFiles = stats.Segments
+		result.OldestAt = stats.OldestAt
+		result.DroppedCount = stats.DroppedCount
+	}
+	return result
+}
+
+// 选择用来写入访问日志的数据库
+func (this *HTTPAccessLogDAO) findDumpingDAO() (*HTTPAccessLogDAOWrapper, error) {
+	var dao = randomHTTPAccessLogDAO()
+	if dao == nil {
+		dao = &HTTPAccessLogDAOWrapper{
+			DAO:    SharedHTTPAccessLogDAO,
+			NodeId: 0,
+		}
+
+		// 检查本地数据库空间
+		if dbutils.IsLocalDatabase && !dbutils.HasFreeSpace {
+			return nil, errors.New("dump accesslog failed: there is no enough space left for database (" + dbutils.LocalDatabaseDataDir + ")")
+		}
+	} else if dao.IsLocal {
+		// 检查本地数据库空间
+		// 我们假定本地只能安装一个数据库，访问日志中的数据库和当前API连接的数据库一致
+		if !dbutils.HasFreeSpace {
+			return nil, errors.New("dump accesslog failed: there is no enough space left for database (" + dbutils.LocalDatabaseDataDir + ")")
+		}
+	}
+	return dao, nil
+}
+
+// 从磁盘队列导入访问日志
+// 只有在事务提交成功后才提交队列的处理位置，失败的日志会在下次继续导入
+func (this *HTTPAccessLogDAO) dumpAccessLogsFromSpool(s *spool.Spool, size int) (hasMore bool, err error) {
+	records, err := s.Read(size)
+	if err != nil {
+		if err == spool.ErrClosed {
+			return false, nil
+		}
+		return false, err
+	}
+	if len(records) == 0 {
+		return false, nil
+	}
+
+	dao, err := this.findDumpingDAO()
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := dao.DAO.Instance.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	var countProcessed = 0
+	var insertErr error
+	for _, record := range records {
+		var accessLog = &pb.HTTPAccessLog{}
+		err = proto.Unmarshal(record.Data, accessLog)
+		if err != nil {
+			// 无法解析的日志直接跳过
+			remotelogs.Error("HTTP_ACCESS_LOG_QUEUE", "decode access log from spool failed: "+err.Error())
+			countProcessed++
+			continue
+		}
+
+		insertErr = this.CreateHTTPAccessLog(tx, dao.DAO, accessLog)
+		if insertErr != nil {
+			break
+		}
+		countProcessed++
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	if insertErr != nil {
+		if countProcessed > 0 {
+			accessLogSpoolHeadFails = 0
+		}
+		accessLogSpoolHeadFails++
+
+		// 同一条日志因为数据或约束问题一直写入失败，说明日志本身有问题，跳过此条日志，防止阻塞整个队列
+		// 其他错误（比如连接断开、锁等待超时等）可能是暂时的，不能跳过，否则会丢失日志
+		if accessLogSpoolHeadFails >= accessLogSpoolMaxHeadFails && isAccessLogSpoolDataErr(insertErr) {
+			remotelogs.Error("HTTP_ACCESS_LOG_QUEUE", "skip access log from spool after "+types.String(accessLogSpoolHeadFails)+" failures: "+insertErr.Error())
+			accessLogSpoolHeadFails = 0
+			countProcessed++
+		}
+	} else {
+		accessLogSpoolHeadFails = 0
+	}
+
+	if countProcessed > 0 {
+		err = s.Commit(records[:countProcessed])
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if insertErr != nil {
+		return false, insertErr
+	}
+	return len(records) == size, nil
+}
+
+// 判断是否为日志数据本身导致的错误
+func isAccessLogSpoolDataErr(err error) bool {
+	for _, code := range accessLogSpoolDataErrCodes {
+		if CheckSQLErrCode(err, code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models
+
+import "github.com/TeaOSLab/EdgeAPI/internal/utils/sizes"
+
+const HTTPAccessLogSpoolConfigSettingCode = "httpAccessLogSpoolConfig" // 访问日志本地磁盘队列配置
+
+// HTTPAccessLogSpoolConfig 访问日志本地磁盘队列配置
+// 修改后需要重启API节点才能生效
+type HTTPAccessLogSpoolConfig struct {
+	IsOn        bool  `json:"isOn"`        // 是否启用，不启用时只使用内存队列
+	MaxSize     int64 `json:"maxSize"`     // 占用磁盘空间上限（字节），超出后丢弃最早的日志
+	SegmentSize int64 `json:"segmentSize"` // 单个分段文件尺寸（字节）
+}
+
+// DefaultHTTPAccessLogSpoolConfig 默认配置
+func DefaultHTTPAccessLogSpoolConfig() *HTTPAccessLogSpoolConfig {
+	return &HTTPAccessLogSpoolConfig{
+		IsOn:        true,
+		MaxSize:     2 * sizes.G,
+		SegmentSize: 32 * sizes.M,
+	}
+}
+
+// Init 整理配置
+func (this *HTTPAccessLogSpoolConfig) Init() {
+	if this.MaxSize < 16*sizes.M {
+		this.MaxSize = 16 * sizes.M
+	}
+	if this.SegmentSize <= 0 || this.SegmentSize > this.MaxSize/4 {
+		this.SegmentSize = this.MaxSize / 4
+	}
+}
@@ -247,6 +247,225 @@ func (this *HTTPFirewallPolicyDAO) CreateDefaultFirewallPolicy(tx *dbs.Tx, name
 	return policyId, nil
 }
 
+// CopyFirewallPolicy 复制策略
+// 规则分组、规则集和规则都会复制一份新的；toPolicyId为0时创建新的策略和新的IP名单，否则覆盖已有策略的设置，保留已有策略的IP名单，并禁用已有策略原来的规则分组
+func (this *HTTPFirewallPolicyDAO) CopyFirewallPolicy(tx *dbs.Tx, fromPolicyId int64, toPolicyId int64, userId int64, serverId int64, copyRegions bool) (newPolicyId int64, err error) {
+	if fromPolicyId <= 0 {
+		return
+	}
+	policy, err := this.FindEnabledHTTPFirewallPolicy(tx, fromPolicyId)
+	if err != nil || policy == nil {
+		return 0, err
+	}
+
+	// 已有策略的Inbound和Outbound
+	var oldInboundConfig = &firewallconfigs.HTTPFirewallInboundConfig{}
+	var oldOutboundConfig = &firewallconfigs.HTTPFirewallOutboundConfig{}
+	if toPolicyId > 0 {
+		oldPolicy, err := this.FindEnabledHTTPFirewallPolicy(tx, toPolicyId)
+		if err != nil {
+			return 0, err
+		}
+		if oldPolicy == nil {
+			return 0, errors.New("could not find firewall policy '" + types.String(toPolicyId) + "'")
+		}
+		if IsNotNull(oldPolicy.Inbound) {
+			err = json.Unmarshal(oldPolicy.Inbound, oldInboundConfig)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if IsNotNull(oldPolicy.Outbound) {
+			err = json.Unmarshal(oldPolicy.Outbound, oldOutboundConfig)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// inbound
+	var inboundConfig = &firewallconfigs.HTTPFirewallInboundConfig{IsOn: true}
+	if IsNotNull(policy.Inbound) {
+		err = json.Unmarshal(policy.Inbound, inboundConfig)
+		if err != nil {
+			return 0, err
+		}
+	}
+	inboundConfig.GroupRefs, err = this.cloneRuleGroupRefs(tx, inboundConfig.GroupRefs)
+	if err != nil {
+		return 0, err
+	}
+	inboundConfig.Groups = nil
+	inboundConfig.AllowListRef = oldInboundConfig.AllowListRef
+	inboundConfig.DenyListRef = oldInboundConfig.DenyListRef
+	inboundConfig.GreyListRef = oldInboundConfig.GreyListRef
+	if !copyRegions {
+		inboundConfig.Region = oldInboundConfig.Region
+	}
+	inboundJSON, err := json.Marshal(inboundConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	// outbound
+	var outboundConfig = &firewallconfigs.HTTPFirewallOutboundConfig{IsOn: true}
+	if IsNotNull(policy.Outbound) {
+		err = json.Unmarshal(policy.Outbound, outboundConfig)
+		if err != nil {
+			return 0, err
+		}
+	}
+	outboundConfig.GroupRefs, err = this.cloneRuleGroupRefs(tx, outboundConfig.GroupRefs)
+	if err != nil {
+		return 0, err
+	}
+	outboundConfig.Groups = nil
+	outboundJSON, err := json.Marshal(outboundConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	var op = NewHTTPFirewallPolicyOperator()
+	if toPolicyId > 0 {
+		op.Id = toPolicyId
+	} else {
+		op.TemplateId = policy.TemplateId
+		op.AdminId = policy.AdminId
+		op.UserId = userId
+		op.ServerId = serverId
+		op.State = HTTPFirewallPolicyStateEnabled
+		op.Name = policy.Name
+		op.Description = policy.Description
+	}
+	op.IsOn = policy.IsOn
+	if IsNotNull(policy.BlockOptions) {
+		op.BlockOptions = policy.BlockOptions
+	}
+	if IsNotNull(policy.PageOptions) {
+		op.PageOptions = policy.PageOptions
+	}
+	if IsNotNull(policy.CaptchaOptions) {
+		op.CaptchaOptions = policy.CaptchaOptions
+	}
+	if IsNotNull(policy.JsCookieOptions) {
+		op.JsCookieOptions = policy.JsCookieOptions
+	}
+	op.Mode = policy.Mode
+	op.UseLocalFirewall = policy.UseLocalFirewall
+	if IsNotNull(policy.SynFlood) {
+		op.SynFlood = policy.SynFlood
+	}
+	if IsNotNull(policy.Log) {
+		op.Log = policy.Log
+	}
+	op.MaxRequestBodySize = policy.MaxRequestBodySize
+	op.DenyCountryHTML = policy.DenyCountryHTML
+	op.DenyProvinceHTML = policy.DenyProvinceHTML
+	newPolicyId, err = this.SaveInt64(tx, op)
+	if err != nil {
+		return 0, err
+	}
+
+	// 没有IP名单时会自动创建
+	err = this.UpdateFirewallPolicyInboundAndOutbound(tx, newPolicyId, userId, serverId, inboundJSON, outboundJSON, toPolicyId > 0)
+	if err != nil {
+		return 0, err
+	}
+
+	// 已有策略原来的规则分组已经被新复制的分组替代
+	err = this.disableRuleGroupRefs(tx, oldInboundConfig.GroupRefs)
+	if err != nil {
+		return 0, err
+	}
+	err = this.disableRuleGroupRefs(tx, oldOutboundConfig.GroupRefs)
+	if err != nil {
+		return 0, err
+	}
+
+	return newPolicyId, nil
+}
+
+// DisableFirewallPolicyAndRuleGroups 禁用策略以及策略中的规则分组
+// 用于服务自身的策略被替换之后
+func (this *HTTPFirewallPolicyDAO) DisableFirewallPolicyAndRuleGroups(tx *dbs.Tx, policyId int64) error {
+	policy, err := this.FindEnabledHTTPFirewallPolicy(tx, policyId)
+	if err != nil || policy == nil {
+		return err
+	}
+
+	if IsNotNull(policy.Inbound) {
+		var inboundConfig = &firewallconfigs.HTTPFirewallInboundConfig{}
+		err = json.Unmarshal(policy.Inbound, inboundConfig)
+		if err != nil {
+			return err
+		}
+		err = this.disableRuleGroupRefs(tx, inboundConfig.GroupRefs)
+		if err != nil {
+			return err
+		}
+	}
+	if IsNotNull(policy.Outbound) {
+		var outboundConfig = &firewallconfigs.HTTPFirewallOutboundConfig{}
+		err = json.Unmarshal(policy.Outbound, outboundConfig)
+		if err != nil {
+			return err
+		}
+		err = this.disableRuleGroupRefs(tx, outboundConfig.GroupRefs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return this.DisableHTTPFirewallPolicy(tx, policyId)
+}
+
+// 禁用一组规则分组
+func (this *HTTPFirewallPolicyDAO) disableRuleGroupRefs(tx *dbs.Tx, groupRefs []*firewallconfigs.HTTPFirewallRuleGroupRef) error {
+	for _, groupRef := range groupRefs {
+		if groupRef.GroupId <= 0 {
+			continue
+		}
+		err := SharedHTTPFirewallRuleGroupDAO.DisableHTTPFirewallRuleGroup(tx, groupRef.GroupId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 复制一组规则分组
+func (this *HTTPFirewallPolicyDAO) cloneRuleGroupRefs(tx *dbs.Tx, groupRefs []*firewallconfigs.HTTPFirewallRuleGroupRef) ([]*firewallconfigs.HTTPFirewallRuleGroupRef, error) {
+	var newGroupRefs = []*firewallconfigs.HTTPFirewallRuleGroupRef{}
+	for _, groupRef := range groupRefs {
+		groupConfig, err := SharedHTTPFirewallRuleGroupDAO.ComposeFirewallRuleGroup(tx, groupRef.GroupId, false)
+		if err != nil {
+			return nil, err
+		}
+		if groupConfig == nil {
+			continue
+		}
+
+		// 清除ID，以便创建新的分组、规则集和规则
+		groupConfig.Id = 0
+		for _, setConfig := range groupConfig.Sets {
+			setConfig.Id = 0
+			for _, ruleConfig := range setConfig.Rules {
+				ruleConfig.Id = 0
+			}
+		}
+
+		newGroupId, err := SharedHTTPFirewallRuleGroupDAO.CreateGroupFromConfig(tx, groupConfig)
+		if err != nil {
+			return nil, err
+		}
+		newGroupRefs = append(newGroupRefs, &firewallconfigs.HTTPFirewallRuleGroupRef{
+			IsOn:    groupRef.IsOn,
+			GroupId: newGroupId,
+		})
+	}
+	return newGroupRefs, nil
+}
+
 // UpdateFirewallPolicyInboundAndOutbound 修改策略的Inbound和Outbound
 func (this *HTTPFirewallPolicyDAO) UpdateFirewallPolicyInboundAndOutbound(tx *dbs.Tx, policyId int64, userId int64, serverId int64, inboundJSON []byte, outboundJSON []byte, shouldNotify bool) error {
 	if policyId <= 0 {
@@ -224,6 +224,46 @@ func (this *HTTPHeaderDAO) UpdateHeader(tx *dbs.Tx, headerId int64, name string,
 	return this.NotifyUpdate(tx, headerId)
 }
 
+// CloneHeader 复制Header
+func (this *HTTPHeaderDAO) CloneHeader(tx *dbs.Tx, fromHeaderId int64, userId int64) (newHeaderId int64, err error) {
+	if fromHeaderId <= 0 {
+		return
+	}
+	headerOne, err := this.Query(tx).
+		Pk(fromHeaderId).
+		Find()
+	if err != nil || headerOne == nil {
+		return 0, err
+	}
+	var header = headerOne.(*HTTPHeader)
+
+	var op = NewHTTPHeaderOperator()
+	op.AdminId = header.AdminId
+	op.UserId = userId
+	op.TemplateId = header.TemplateId
+	op.IsOn = header.IsOn
+	op.Name = header.Name
+	op.Value = header.Value
+	op.Order = header.Order
+	if len(header.Status) > 0 {
+		op.Status = header.Status
+	}
+	op.DisableRedirect = header.DisableRedirect
+	op.ShouldAppend = header.ShouldAppend
+	op.ShouldReplace = header.ShouldReplace
+	if len(header.ReplaceValues) > 0 {
+		op.ReplaceValues = header.ReplaceValues
+	}
+	if len(header.Methods) > 0 {
+		op.Methods = header.Methods
+	}
+	if len(header.Domains) > 0 {
+		op.Domains = header.Domains
+	}
+	op.State = header.State
+	return this.SaveInt64(tx, op)
+}
+
 // ComposeHeaderConfig 组合Header配置
 func (this *HTTPHeaderDAO) ComposeHeaderConfig(tx *dbs.Tx, headerId int64) (*shared.HTTPHeaderConfig, error) {
 	header, err := this.FindEnabledHTTPHeader(tx, headerId)
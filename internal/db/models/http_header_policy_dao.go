@@ -87,6 +87,86 @@ func (this *HTTPHeaderPolicyDAO) CreateHeaderPolicy(tx *dbs.Tx) (int64, error) {
 	return types.Int64(op.Id), nil
 }
 
+// CloneHeaderPolicy 复制策略，策略中的Header也会一并复制
+func (this *HTTPHeaderPolicyDAO) CloneHeaderPolicy(tx *dbs.Tx, fromPolicyId int64, userId int64) (newPolicyId int64, err error) {
+	if fromPolicyId <= 0 {
+		return
+	}
+	policy, err := this.FindEnabledHTTPHeaderPolicy(tx, fromPolicyId)
+	if err != nil || policy == nil {
+		return 0, err
+	}
+
+	var op = NewHTTPHeaderPolicyOperator()
+	op.IsOn = policy.IsOn
+	op.State = HTTPHeaderPolicyStateEnabled
+	op.AdminId = policy.AdminId
+	op.UserId = userId
+
+	if IsNotNull(policy.AddHeaders) {
+		op.AddHeaders, err = this.cloneHeaderRefs(tx, policy.AddHeaders, userId)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if IsNotNull(policy.AddTrailers) {
+		op.AddTrailers, err = this.cloneHeaderRefs(tx, policy.AddTrailers, userId)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if IsNotNull(policy.SetHeaders) {
+		op.SetHeaders, err = this.cloneHeaderRefs(tx, policy.SetHeaders, userId)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if IsNotNull(policy.ReplaceHeaders) {
+		op.ReplaceHeaders, err = this.cloneHeaderRefs(tx, policy.ReplaceHeaders, userId)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if IsNotNull(policy.Expires) {
+		op.Expires = policy.Expires
+	}
+	if IsNotNull(policy.DeleteHeaders) {
+		op.DeleteHeaders = policy.DeleteHeaders
+	}
+	if IsNotNull(policy.NonStandardHeaders) {
+		op.NonStandardHeaders = policy.NonStandardHeaders
+	}
+	if IsNotNull(policy.Cors) {
+		op.Cors = policy.Cors
+	}
+
+	return this.SaveInt64(tx, op)
+}
+
+// 复制一组Header引用
+func (this *HTTPHeaderPolicyDAO) cloneHeaderRefs(tx *dbs.Tx, refsJSON []byte, userId int64) ([]byte, error) {
+	var refs = []*shared.HTTPHeaderRef{}
+	err := json.Unmarshal(refsJSON, &refs)
+	if err != nil {
+		return nil, err
+	}
+	var newRefs = []*shared.HTTPHeaderRef{}
+	for _, ref := range refs {
+		newHeaderId, err := SharedHTTPHeaderDAO.CloneHeader(tx, ref.HeaderId, userId)
+		if err != nil {
+			return nil, err
+		}
+		if newHeaderId <= 0 {
+			continue
+		}
+		newRefs = append(newRefs, &shared.HTTPHeaderRef{
+			IsOn:     ref.IsOn,
+			HeaderId: newHeaderId,
+		})
+	}
+	return json.Marshal(newRefs)
+}
+
 // UpdateAddingHeaders 修改AddHeaders
 func (this *HTTPHeaderPolicyDAO) UpdateAddingHeaders(tx *dbs.Tx, policyId int64, headersJSON []byte) error {
 	if policyId <= 0 {
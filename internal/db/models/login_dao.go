@@ -1,11 +1,19 @@
 package models
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/maps"
+	"strings"
+	"time"
 )
 
 const (
@@ -16,9 +24,14 @@ const (
 type LoginType = string
 
 const (
-	LoginTypeOTP LoginType = "otp"
+	LoginTypeOTP           LoginType = "otp"
+	LoginTypeWebAuthn      LoginType = "webAuthn"      // 安全密钥
+	LoginTypeRecoveryCodes LoginType = "recoveryCodes" // 恢复码
 )
 
+// LoginRecoveryCodeCount 每次生成的恢复码数量
+const LoginRecoveryCodeCount = 10
+
 type LoginDAO dbs.DAO
 
 func NewLoginDAO() *LoginDAO {
@@ -179,3 +192,111 @@ func (this *LoginDAO) CheckLoginIsOn(tx *dbs.Tx, adminId int64, userId int64, lo
 
 	return query.Exist()
 }
+
+// GenerateRecoveryCodes 生成新的恢复码，之前的恢复码全部失效
+// 只保存恢复码的哈希值，生成的明文恢复码只能在这里返回一次
+func (this *LoginDAO) GenerateRecoveryCodes(tx *dbs.Tx, adminId int64, userId int64) ([]string, error) {
+	var codes = []string{}
+	var hashes = []string{}
+	for i := 0; i < LoginRecoveryCodeCount; i++ {
+		var buf = make([]byte, 6)
+		_, err := rand.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		var code = hex.EncodeToString(buf)
+		code = code[:4] + "-" + code[4:8] + "-" + code[8:]
+		codes = append(codes, code)
+		hashes = append(hashes, this.hashRecoveryCode(code))
+	}
+
+	err := this.UpdateLogin(tx, adminId, userId, LoginTypeRecoveryCodes, maps.Map{
+		"codes":     hashes,
+		"createdAt": time.Now().Unix(),
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode 使用恢复码登录，每个恢复码只能使用一次
+func (this *LoginDAO) UseRecoveryCode(tx *dbs.Tx, adminId int64, userId int64, code string) (ok bool, err error) {
+	if adminId <= 0 && userId <= 0 {
+		return false, errors.New("invalid adminId and userId")
+	}
+
+	// 在事务中锁定记录，防止同一个恢复码被并发使用
+	if tx == nil {
+		err = this.Instance.RunTx(func(tx *dbs.Tx) error {
+			ok, err = this.UseRecoveryCode(tx, adminId, userId, code)
+			return err
+		})
+		return
+	}
+
+	var query = this.Query(tx).
+		Attr("type", LoginTypeRecoveryCodes).
+		State(LoginStateEnabled).
+		Attr("isOn", true).
+		Lock(dbs.QueryLockForUpdate)
+	if adminId > 0 {
+		query.Attr("adminId", adminId)
+	} else {
+		query.Attr("userId", userId)
+	}
+	one, err := query.Find()
+	if err != nil || one == nil {
+		return false, err
+	}
+	var login = one.(*Login)
+
+	params, err := login.DecodeRecoveryCodesParams()
+	if err != nil {
+		return false, err
+	}
+
+	var hash = this.hashRecoveryCode(code)
+	var matchedIndex = -1
+	for index, codeHash := range params.Codes {
+		if subtle.ConstantTimeCompare([]byte(codeHash), []byte(hash)) == 1 {
+			matchedIndex = index
+		}
+	}
+	if matchedIndex < 0 {
+		return false, nil
+	}
+
+	params.Codes = append(params.Codes[:matchedIndex:matchedIndex], params.Codes[matchedIndex+1:]...)
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return false, err
+	}
+	err = this.Query(tx).
+		Pk(login.Id).
+		Set("params", paramsJSON).
+		UpdateQuickly()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CountRecoveryCodes 计算剩余的恢复码数量
+func (this *LoginDAO) CountRecoveryCodes(tx *dbs.Tx, adminId int64, userId int64) (int, error) {
+	login, err := this.FindEnabledLoginWithType(tx, adminId, userId, LoginTypeRecoveryCodes)
+	if err != nil || login == nil || !login.IsOn {
+		return 0, err
+	}
+	params, err := login.DecodeRecoveryCodesParams()
+	if err != nil {
+		return 0, err
+	}
+	return len(params.Codes), nil
+}
+
+// 计算恢复码的哈希值，忽略大小写、空格和分隔符
+func (this *LoginDAO) hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(code)))
+}
@@ -1 +1,22 @@
 package models
+
+import "encoding/json"
+
+// LoginRecoveryCodesParams 恢复码参数
+type LoginRecoveryCodesParams struct {
+	Codes     []string `json:"codes"`     // 未使用的恢复码哈希值
+	CreatedAt int64    `json:"createdAt"` // 生成时间
+}
+
+// DecodeRecoveryCodesParams 解析恢复码参数
+func (this *Login) DecodeRecoveryCodesParams() (*LoginRecoveryCodesParams, error) {
+	var params = &LoginRecoveryCodesParams{}
+	if IsNull(this.Params) {
+		return params, nil
+	}
+	err := json.Unmarshal(this.Params, params)
+	if err != nil {
+		return nil, err
+	}
+	return params, nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models
+
+const (
+	LoginSessionConfigSettingCode = "loginSessionConfig" // 登录SESSION配置
+
+	LoginSessionActiveInterval = 60 // 更新最后活跃时间的最小间隔（秒）
+)
+
+// LoginSessionConfig 登录SESSION配置
+type LoginSessionConfig struct {
+	MaxAdminSessions int `json:"maxAdminSessions"` // 每个管理员同时在线的最大SESSION数量，0表示不限制
+	MaxUserSessions  int `json:"maxUserSessions"`  // 每个用户同时在线的最大SESSION数量，0表示不限制
+}
+
+// DefaultLoginSessionConfig 默认配置
+func DefaultLoginSessionConfig() *LoginSessionConfig {
+	return &LoginSessionConfig{
+		MaxAdminSessions: 10,
+		MaxUserSessions:  10,
+	}
+}
+
+// Init 整理配置
+func (this *LoginSessionConfig) Init() {
+	if this.MaxAdminSessions < 0 {
+		this.MaxAdminSessions = 0
+	}
+	if this.MaxUserSessions < 0 {
+		this.MaxUserSessions = 0
+	}
+}
+
+// MaxSessions 获取某个账号的最大SESSION数量
+func (this *LoginSessionConfig) MaxSessions(adminId int64, userId int64) int {
+	if adminId > 0 {
+		return this.MaxAdminSessions
+	}
+	if userId > 0 {
+		return this.MaxUserSessions
+	}
+	return 0
+}
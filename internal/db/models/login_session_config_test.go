@@ -0,0 +1,22 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestLoginSessionConfig_MaxSessions(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var config = &models.LoginSessionConfig{
+		MaxAdminSessions: 5,
+		MaxUserSessions:  -1,
+	}
+	config.Init()
+	a.IsTrue(config.MaxSessions(1, 0) == 5)
+	a.IsTrue(config.MaxSessions(0, 1) == 0)
+	a.IsTrue(config.MaxSessions(0, 0) == 0)
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
@@ -59,6 +60,7 @@ func (this *LoginSessionDAO) CreateSession(tx *dbs.Tx, sid string, ip string, ex
 	op.Values = "{}"
 	op.ExpiresAt = expiresAt
 	op.CreatedAt = time.Now().Unix()
+	op.ActiveAt = time.Now().Unix()
 
 	if oldSessionId > 0 {
 		err := this.Save(tx, op)
@@ -85,10 +87,14 @@ func (this *LoginSessionDAO) WriteSessionValue(tx *dbs.Tx, sid string, key strin
 	}
 	var sessionId int64
 	var valueMap = maps.Map{}
+	var oldAdminId int64
+	var oldUserId int64
 	if sessionOne != nil {
 		var session = sessionOne.(*LoginSession)
 		if session.IsAvailable() {
 			sessionId = int64(session.Id)
+			oldAdminId = int64(session.AdminId)
+			oldUserId = int64(session.UserId)
 
 			if !IsNull(session.Values) {
 				err = json.Unmarshal(session.Values, &valueMap)
@@ -142,7 +148,69 @@ func (this *LoginSessionDAO) WriteSessionValue(tx *dbs.Tx, sid string, key strin
 		sessionOp.Ip = value
 	}
 
-	return this.Save(tx, sessionOp)
+	// 浏览器信息
+	if key == "@userAgent" {
+		sessionOp.UserAgent = utils.LimitString(types.String(value), 512)
+	}
+
+	err = this.Save(tx, sessionOp)
+	if err != nil {
+		return err
+	}
+
+	// 登录时限制同时在线的SESSION数量，同一个SESSION重复写入登录信息时不再检查
+	if (adminId > 0 || userId > 0) && (adminId != oldAdminId || userId != oldUserId) {
+		return this.limitSessions(tx, adminId, userId, sid)
+	}
+	return nil
+}
+
+// PopSessionValue 读取并删除SESSION中的数据
+// 用于只能使用一次的数据，比如安全密钥的挑战码
+func (this *LoginSessionDAO) PopSessionValue(tx *dbs.Tx, sid string, key string) (value any, err error) {
+	if len(sid) == 0 || len(sid) > 64 {
+		return nil, errors.New("invalid 'sid'")
+	}
+
+	if tx == nil {
+		err = this.Instance.RunTx(func(tx *dbs.Tx) error {
+			value, err = this.PopSessionValue(tx, sid, key)
+			return err
+		})
+		return
+	}
+
+	one, err := this.Query(tx).
+		Attr("sid", sid).
+		Lock(dbs.QueryLockForUpdate).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	var session = one.(*LoginSession)
+	if !session.IsAvailable() || IsNull(session.Values) {
+		return nil, nil
+	}
+
+	var valueMap = maps.Map{}
+	err = json.Unmarshal(session.Values, &valueMap)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := valueMap[key]
+	if !ok {
+		return nil, nil
+	}
+	delete(valueMap, key)
+
+	err = this.Query(tx).
+		Pk(session.Id).
+		Set("values", valueMap.AsJSON()).
+		UpdateQuickly()
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
 }
 
 // DeleteSession 删除SESSION
@@ -174,6 +242,119 @@ func (this *LoginSessionDAO) FindSession(tx *dbs.Tx, sid string) (*LoginSession,
 	return session, nil
 }
 
+// FindSessionWithId 根据ID查询SESSION
+func (this *LoginSessionDAO) FindSessionWithId(tx *dbs.Tx, sessionId int64) (*LoginSession, error) {
+	one, err := this.Query(tx).
+		Pk(sessionId).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*LoginSession), nil
+}
+
+// FindAllAvailableSessions 列出某个管理员或用户所有可用的SESSION
+func (this *LoginSessionDAO) FindAllAvailableSessions(tx *dbs.Tx, adminId int64, userId int64) (result []*LoginSession, err error) {
+	if adminId <= 0 && userId <= 0 {
+		return nil, nil
+	}
+	_, err = this.Query(tx).
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Where("(expiresAt=0 OR expiresAt>:now)").
+		Param("now", time.Now().Unix()).
+		Desc("activeAt").
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// UpdateSessionActiveAt 更新最后活跃时间
+// 为了减少写入，距离上次更新不足 LoginSessionActiveInterval 秒时不更新
+func (this *LoginSessionDAO) UpdateSessionActiveAt(tx *dbs.Tx, session *LoginSession) error {
+	if session == nil {
+		return nil
+	}
+	var now = time.Now().Unix()
+	if int64(session.ActiveAt)+LoginSessionActiveInterval > now {
+		return nil
+	}
+	return this.Query(tx).
+		Pk(session.Id).
+		Set("activeAt", now).
+		UpdateQuickly()
+}
+
+// DeleteSessionWithId 根据ID删除SESSION
+func (this *LoginSessionDAO) DeleteSessionWithId(tx *dbs.Tx, sessionId int64) error {
+	if sessionId <= 0 {
+		return errors.New("invalid 'sessionId'")
+	}
+	return this.Query(tx).
+		Pk(sessionId).
+		DeleteQuickly()
+}
+
+// DeleteAllSessions 删除某个管理员或用户的所有SESSION，强制其重新登录
+// exceptSid 为需要保留的SESSION，为空表示全部删除
+func (this *LoginSessionDAO) DeleteAllSessions(tx *dbs.Tx, adminId int64, userId int64, exceptSid string) error {
+	if adminId <= 0 && userId <= 0 {
+		return nil
+	}
+	var query = this.Query(tx).
+		Attr("adminId", adminId).
+		Attr("userId", userId)
+	if len(exceptSid) > 0 {
+		query.Neq("sid", exceptSid)
+	}
+	return query.DeleteQuickly()
+}
+
+// 删除过期和超出数量限制的SESSION
+func (this *LoginSessionDAO) limitSessions(tx *dbs.Tx, adminId int64, userId int64, sid string) error {
+	err := this.Query(tx).
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Where("expiresAt>0 AND expiresAt<:now").
+		Param("now", time.Now().Unix()).
+		DeleteQuickly()
+	if err != nil {
+		return err
+	}
+
+	config, err := SharedSysSettingDAO.ReadLoginSessionConfig(tx)
+	if err != nil {
+		return err
+	}
+	var maxSessions = config.MaxSessions(adminId, userId)
+	if maxSessions <= 0 {
+		return nil
+	}
+
+	// 保留当前SESSION和最近活跃的其他SESSION
+	ones, err := this.Query(tx).
+		ResultPk().
+		Attr("adminId", adminId).
+		Attr("userId", userId).
+		Neq("sid", sid).
+		Desc("activeAt").
+		DescPk().
+		Offset(int64(maxSessions - 1)).
+		Limit(1000).
+		FindAll()
+	if err != nil {
+		return err
+	}
+	for _, one := range ones {
+		err = this.DeleteSessionWithId(tx, int64(one.(*LoginSession).Id))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (this *LoginSessionDAO) ClearOldSessions(tx *dbs.Tx, adminId int64, userId int64, sid string, ip string) error {
 	// 删除此用户之前创建的SESSION
 	err := this.Query(tx).
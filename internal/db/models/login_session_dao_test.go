@@ -5,6 +5,7 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/iwind/TeaGo/bootstrap"
 	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
 	"testing"
 	"time"
 )
@@ -45,3 +46,128 @@ func TestLoginSessionDAO_WriteSessionValue(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestLoginSessionDAO_FindAllAvailableSessions(t *testing.T) {
+	dbs.NotifyReady()
+
+	var dao = models.NewLoginSessionDAO()
+	var tx *dbs.Tx
+	var adminId int64 = 1_000_001
+
+	err := dao.DeleteAllSessions(tx, adminId, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sid := range []string{"test-session-list-1", "test-session-list-2"} {
+		err = dao.WriteSessionValue(tx, sid, "adminId", adminId)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := dao.FindAllAvailableSessions(tx, adminId, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatal("expect 2 sessions, but got", len(sessions))
+	}
+	for _, session := range sessions {
+		if int64(session.AdminId) != adminId {
+			t.Fatal("invalid adminId:", session.AdminId)
+		}
+	}
+}
+
+func TestLoginSessionDAO_RevokeSessions(t *testing.T) {
+	dbs.NotifyReady()
+
+	var dao = models.NewLoginSessionDAO()
+	var tx *dbs.Tx
+	var adminId int64 = 1_000_002
+
+	for _, sid := range []string{"test-session-revoke-1", "test-session-revoke-2", "test-session-revoke-3"} {
+		err := dao.WriteSessionValue(tx, sid, "adminId", adminId)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 撤销单个SESSION
+	session, err := dao.FindSession(tx, "test-session-revoke-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session == nil {
+		t.Fatal("session should exist")
+	}
+	err = dao.DeleteSessionWithId(tx, int64(session.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err = dao.FindSession(tx, "test-session-revoke-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session != nil {
+		t.Fatal("session should be revoked")
+	}
+
+	// 撤销当前SESSION之外的所有SESSION
+	err = dao.DeleteAllSessions(tx, adminId, 0, "test-session-revoke-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := dao.FindAllAvailableSessions(tx, adminId, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Sid != "test-session-revoke-3" {
+		t.Fatal("only current session should be kept")
+	}
+}
+
+func TestLoginSessionDAO_LimitSessions(t *testing.T) {
+	dbs.NotifyReady()
+
+	var dao = models.NewLoginSessionDAO()
+	var tx *dbs.Tx
+	var adminId int64 = 1_000_003
+
+	err := dao.DeleteAllSessions(tx, adminId, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := models.SharedSysSettingDAO.ReadLoginSessionConfig(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var maxSessions = config.MaxSessions(adminId, 0)
+	if maxSessions <= 0 {
+		t.Log("sessions are not limited")
+		return
+	}
+
+	for i := 0; i < maxSessions+2; i++ {
+		err = dao.WriteSessionValue(tx, "test-session-limit-"+types.String(i), "adminId", adminId)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 重复写入登录信息不会删除其他SESSION
+	err = dao.WriteSessionValue(tx, "test-session-limit-0", "adminId", adminId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := dao.FindAllAvailableSessions(tx, adminId, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != maxSessions {
+		t.Fatal("expect", maxSessions, "sessions, but got", len(sessions))
+	}
+}
@@ -10,7 +10,9 @@ type LoginSession struct {
 	Sid       string   `field:"sid"`       // 令牌
 	Values    dbs.JSON `field:"values"`    // 数据
 	Ip        string   `field:"ip"`        // 登录IP
+	UserAgent string   `field:"userAgent"` // 浏览器信息
 	CreatedAt uint64   `field:"createdAt"` // 创建时间
+	ActiveAt  uint64   `field:"activeAt"`  // 最后活跃时间
 	ExpiresAt uint64   `field:"expiresAt"` // 过期时间
 }
 
@@ -21,7 +23,9 @@ type LoginSessionOperator struct {
 	Sid       any // 令牌
 	Values    any // 数据
 	Ip        any // 登录IP
+	UserAgent any // 浏览器信息
 	CreatedAt any // 创建时间
+	ActiveAt  any // 最后活跃时间
 	ExpiresAt any // 过期时间
 }
 
@@ -0,0 +1,64 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/webauthn"
+	"strings"
+)
+
+const (
+	LoginWebAuthnConfigSettingCode = "loginWebAuthnConfig" // 安全密钥登录配置
+
+	LoginWebAuthnChallengeTTL = 300 // 挑战码有效期（秒）
+	LoginWebAuthnReverifyTTL  = 300 // 敏感操作前再次校验的有效期（秒）
+)
+
+// LoginWebAuthnConfig 安全密钥登录配置
+type LoginWebAuthnConfig struct {
+	RPId               string   `json:"rpId"`               // RP ID，为空时使用管理平台或用户平台访问的域名
+	RPName             string   `json:"rpName"`             // 在浏览器中显示的名称
+	Origins            []string `json:"origins"`            // 允许的来源，为空时允许RP ID及其子域名下的HTTPS来源
+	UserVerification   string   `json:"userVerification"`   // 用户验证：required、preferred、discouraged
+	RequireSuperAdmins bool     `json:"requireSuperAdmins"` // 超级管理员是否必须使用安全密钥登录
+}
+
+// DefaultLoginWebAuthnConfig 默认配置
+func DefaultLoginWebAuthnConfig() *LoginWebAuthnConfig {
+	return &LoginWebAuthnConfig{
+		UserVerification: webauthn.UserVerificationPreferred,
+	}
+}
+
+// Init 整理配置
+func (this *LoginWebAuthnConfig) Init() {
+	this.RPId = strings.ToLower(strings.TrimSpace(this.RPId))
+	switch this.UserVerification {
+	case webauthn.UserVerificationRequired, webauthn.UserVerificationPreferred, webauthn.UserVerificationDiscouraged:
+	default:
+		this.UserVerification = webauthn.UserVerificationPreferred
+	}
+	var origins = []string{}
+	for _, origin := range this.Origins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if len(origin) > 0 {
+			origins = append(origins, origin)
+		}
+	}
+	this.Origins = origins
+}
+
+// RequireUserVerification 是否要求用户验证
+func (this *LoginWebAuthnConfig) RequireUserVerification() bool {
+	return this.UserVerification == webauthn.UserVerificationRequired
+}
+
+// NewRelyingParty 根据配置构造依赖方
+// host 为当前访问的域名，只有在没有设置RP ID时才会使用
+func (this *LoginWebAuthnConfig) NewRelyingParty(host string) *webauthn.RelyingParty {
+	var rpId = this.RPId
+	if len(rpId) == 0 {
+		rpId = host
+	}
+	return webauthn.NewRelyingParty(rpId, this.RPName, this.Origins)
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/webauthn"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+const (
+	LoginWebAuthnCredentialStateEnabled  = 1 // 已启用
+	LoginWebAuthnCredentialStateDisabled = 0 // 已禁用
+)
+
+// LoginWebAuthnCredentialMaxCount 每个管理员或用户最多可以注册的安全密钥数量
+const LoginWebAuthnCredentialMaxCount = 20
+
+type LoginWebAuthnCredentialDAO dbs.DAO
+
+func NewLoginWebAuthnCredentialDAO() *LoginWebAuthnCredentialDAO {
+	return dbs.NewDAO(&LoginWebAuthnCredentialDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeLoginWebAuthnCredentials",
+			Model:  new(LoginWebAuthnCredential),
+			PkName: "id",
+		},
+	}).(*LoginWebAuthnCredentialDAO)
+}
+
+var SharedLoginWebAuthnCredentialDAO *LoginWebAuthnCredentialDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedLoginWebAuthnCredentialDAO = NewLoginWebAuthnCredentialDAO()
+	})
+}
+
+// CreateCredential 保存注册成功的安全密钥
+func (this *LoginWebAuthnCredentialDAO) CreateCredential(tx *dbs.Tx, adminId int64, userId int64, name string, credential *webauthn.Credential, transports []string) (int64, error) {
+	if adminId <= 0 && userId <= 0 {
+		return 0, errors.New("invalid adminId and userId")
+	}
+	if credential == nil {
+		return 0, errors.New("credential should not be nil")
+	}
+
+	// 同一个密钥不能重复注册
+	exists, err := this.Query(tx).
+		Attr("credentialId", webauthn.EncodeBase64(credential.Id)).
+		State(LoginWebAuthnCredentialStateEnabled).
+		Exist()
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, errors.New("the security key has already been registered")
+	}
+
+	count, err := this.CountEnabledCredentials(tx, adminId, userId)
+	if err != nil {
+		return 0, err
+	}
+	if count >= LoginWebAuthnCredentialMaxCount {
+		return 0, errors.New("too many security keys")
+	}
+
+	if transports == nil {
+		transports = []string{}
+	}
+	transportsJSON, err := json.Marshal(transports)
+	if err != nil {
+		return 0, err
+	}
+
+	var op = NewLoginWebAuthnCredentialOperator()
+	if adminId > 0 {
+		op.AdminId = adminId
+	} else {
+		op.UserId = userId
+	}
+	op.Name = name
+	op.CredentialId = webauthn.EncodeBase64(credential.Id)
+	op.PublicKey = webauthn.EncodeBase64(credential.PublicKey)
+	op.Algorithm = credential.Algorithm
+	op.SignCount = credential.SignCount
+	op.Aaguid = hex.EncodeToString(credential.AAGUID)
+	op.AttestationFormat = credential.AttestationFormat
+	op.Transports = transportsJSON
+	op.CreatedAt = time.Now().Unix()
+	op.State = LoginWebAuthnCredentialStateEnabled
+	return this.SaveInt64(tx, op)
+}
+
+// DisableCredential 删除安全密钥
+func (this *LoginWebAuthnCredentialDAO) DisableCredential(tx *dbs.Tx, credentialId int64) error {
+	return this.Query(tx).
+		Pk(credentialId).
+		Set("state", LoginWebAuthnCredentialStateDisabled).
+		UpdateQuickly()
+}
+
+// FindEnabledCredential 查找安全密钥
+func (this *LoginWebAuthnCredentialDAO) FindEnabledCredential(tx *dbs.Tx, credentialId int64) (*LoginWebAuthnCredential, error) {
+	one, err := this.Query(tx).
+		Pk(credentialId).
+		State(LoginWebAuthnCredentialStateEnabled).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*LoginWebAuthnCredential), nil
+}
+
+// FindEnabledCredentialWithCredentialId 根据凭证ID查找安全密钥
+func (this *LoginWebAuthnCredentialDAO) FindEnabledCredentialWithCredentialId(tx *dbs.Tx, adminId int64, userId int64, credentialId []byte) (*LoginWebAuthnCredential, error) {
+	if len(credentialId) == 0 {
+		return nil, nil
+	}
+	one, err := this.enabledQuery(tx, adminId, userId).
+		Attr("credentialId", webauthn.EncodeBase64(credentialId)).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*LoginWebAuthnCredential), nil
+}
+
+// FindAllEnabledCredentials 列出管理员或用户的所有安全密钥
+func (this *LoginWebAuthnCredentialDAO) FindAllEnabledCredentials(tx *dbs.Tx, adminId int64, userId int64) (result []*LoginWebAuthnCredential, err error) {
+	_, err = this.enabledQuery(tx, adminId, userId).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// CountEnabledCredentials 计算管理员或用户的安全密钥数量
+func (this *LoginWebAuthnCredentialDAO) CountEnabledCredentials(tx *dbs.Tx, adminId int64, userId int64) (int64, error) {
+	return this.enabledQuery(tx, adminId, userId).
+		Count()
+}
+
+// UpdateCredentialSignCount 登录成功后更新签名计数器
+func (this *LoginWebAuthnCredentialDAO) UpdateCredentialSignCount(tx *dbs.Tx, credentialId int64, signCount uint32) error {
+	return this.Query(tx).
+		Pk(credentialId).
+		Set("signCount", signCount).
+		Set("lastUsedAt", time.Now().Unix()).
+		UpdateQuickly()
+}
+
+// UpdateCredentialName 修改安全密钥名称
+func (this *LoginWebAuthnCredentialDAO) UpdateCredentialName(tx *dbs.Tx, credentialId int64, name string) error {
+	return this.Query(tx).
+		Pk(credentialId).
+		Set("name", name).
+		UpdateQuickly()
+}
+
+func (this *LoginWebAuthnCredentialDAO) enabledQuery(tx *dbs.Tx, adminId int64, userId int64) *dbs.Query {
+	var query = this.Query(tx).
+		State(LoginWebAuthnCredentialStateEnabled)
+	if adminId > 0 {
+		query.Attr("adminId", adminId)
+	} else {
+		query.Attr("userId", userId).
+			Gt("userId", 0)
+	}
+	return query
+}
@@ -0,0 +1,42 @@
+package models
+
+import "github.com/iwind/TeaGo/dbs"
+
+// LoginWebAuthnCredential 安全密钥
+type LoginWebAuthnCredential struct {
+	Id                uint64   `field:"id"`                // ID
+	AdminId           uint32   `field:"adminId"`           // 管理员ID
+	UserId            uint32   `field:"userId"`            // 用户ID
+	Name              string   `field:"name"`              // 名称
+	CredentialId      string   `field:"credentialId"`      // 凭证ID（base64url）
+	PublicKey         string   `field:"publicKey"`         // COSE格式的公钥（base64url）
+	Algorithm         int32    `field:"algorithm"`         // 签名算法
+	SignCount         uint32   `field:"signCount"`         // 签名计数器
+	Aaguid            string   `field:"aaguid"`            // 认证器型号
+	AttestationFormat string   `field:"attestationFormat"` // 证明格式
+	Transports        dbs.JSON `field:"transports"`        // 传输方式
+	CreatedAt         uint64   `field:"createdAt"`         // 创建时间
+	LastUsedAt        uint64   `field:"lastUsedAt"`        // 最后使用时间
+	State             uint8    `field:"state"`             // 状态
+}
+
+type LoginWebAuthnCredentialOperator struct {
+	Id                any // ID
+	AdminId           any // 管理员ID
+	UserId            any // 用户ID
+	Name              any // 名称
+	CredentialId      any // 凭证ID（base64url）
+	PublicKey         any // COSE格式的公钥（base64url）
+	Algorithm         any // 签名算法
+	SignCount         any // 签名计数器
+	Aaguid            any // 认证器型号
+	AttestationFormat any // 证明格式
+	Transports        any // 传输方式
+	CreatedAt         any // 创建时间
+	LastUsedAt        any // 最后使用时间
+	State             any // 状态
+}
+
+func NewLoginWebAuthnCredentialOperator() *LoginWebAuthnCredentialOperator {
+	return &LoginWebAuthnCredentialOperator{}
+}
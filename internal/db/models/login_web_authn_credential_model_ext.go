@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/webauthn"
+)
+
+// DecodeCredentialId 解析凭证ID
+func (this *LoginWebAuthnCredential) DecodeCredentialId() []byte {
+	data, _ := webauthn.DecodeBase64(this.CredentialId)
+	return data
+}
+
+// DecodePublicKey 解析公钥
+func (this *LoginWebAuthnCredential) DecodePublicKey() []byte {
+	data, _ := webauthn.DecodeBase64(this.PublicKey)
+	return data
+}
+
+// DecodeTransports 解析传输方式
+func (this *LoginWebAuthnCredential) DecodeTransports() []string {
+	var result = []string{}
+	if IsNull(this.Transports) {
+		return result
+	}
+	_ = json.Unmarshal(this.Transports, &result)
+	return result
+}
@@ -1 +1,37 @@
 package models
+
+import (
+	"encoding/json"
+	"github.com/iwind/TeaGo/maps"
+)
+
+// MessageMediaInstanceRate 媒介发送频率限制
+type MessageMediaInstanceRate struct {
+	Minutes int32 `json:"minutes"` // 时间范围（分钟）
+	Count   int32 `json:"count"`   // 在时间范围内最多发送数量
+}
+
+// IsValid 判断是否为有效的限制
+func (this *MessageMediaInstanceRate) IsValid() bool {
+	return this != nil && this.Minutes > 0 && this.Count > 0
+}
+
+// DecodeRate 解析发送频率
+func (this *MessageMediaInstance) DecodeRate() *MessageMediaInstanceRate {
+	var rate = &MessageMediaInstanceRate{}
+	if IsNull(this.Rate) {
+		return rate
+	}
+	_ = json.Unmarshal(this.Rate, rate)
+	return rate
+}
+
+// DecodeParams 解析媒介参数
+func (this *MessageMediaInstance) DecodeParams() maps.Map {
+	var params = maps.Map{}
+	if IsNull(this.Params) {
+		return params
+	}
+	_ = json.Unmarshal(this.Params, &params)
+	return params
+}
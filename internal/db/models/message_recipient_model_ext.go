@@ -3,6 +3,9 @@ package models
 import (
 	"encoding/json"
 	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/types"
+	"strings"
+	"time"
 )
 
 // DecodeGroupIds 解析分组ID
@@ -18,3 +21,35 @@ func (this *MessageRecipient) DecodeGroupIds() []int64 {
 	}
 	return result
 }
+
+// IsInTimeRange 判断某个时间是否在接收时间段内
+func (this *MessageRecipient) IsInTimeRange(t time.Time) bool {
+	var fromSeconds = this.parseDaySeconds(this.TimeFrom)
+	var toSeconds = this.parseDaySeconds(this.TimeTo)
+	if (fromSeconds < 0 && toSeconds < 0) || fromSeconds == toSeconds {
+		return true
+	}
+
+	var seconds = t.Hour()*3600 + t.Minute()*60 + t.Second()
+	if fromSeconds < 0 {
+		return seconds <= toSeconds
+	}
+	if toSeconds < 0 {
+		return seconds >= fromSeconds
+	}
+
+	// 跨天，比如 22:00:00 - 06:00:00
+	if fromSeconds > toSeconds {
+		return seconds >= fromSeconds || seconds <= toSeconds
+	}
+	return seconds >= fromSeconds && seconds <= toSeconds
+}
+
+// 将 HH:MM:SS 转换为当天的秒数，格式错误时返回-1
+func (this *MessageRecipient) parseDaySeconds(timeString string) int {
+	var pieces = strings.Split(timeString, ":")
+	if len(pieces) != 3 {
+		return -1
+	}
+	return types.Int(pieces[0])*3600 + types.Int(pieces[1])*60 + types.Int(pieces[2])
+}
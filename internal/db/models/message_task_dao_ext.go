@@ -4,11 +4,285 @@
 package models
 
 import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
 	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"time"
+)
+
+type MessageTaskStatus = int
+
+const (
+	MessageTaskStatusNone    MessageTaskStatus = 0 // 普通状态
+	MessageTaskStatusSending MessageTaskStatus = 1 // 发送中
+	MessageTaskStatusSuccess MessageTaskStatus = 2 // 发送成功
+	MessageTaskStatusFailed  MessageTaskStatus = 3 // 发送失败
+)
+
+// MessageTasksNotifier 有新的消息任务时通知发送
+var MessageTasksNotifier = make(chan bool, 2)
+
+// 发送失败后的重试
+const (
+	MessageTaskMaxRetries       = 3     // 最多重试次数
+	MessageTaskRetryBaseSeconds = 60    // 第一次重试的等待时间，之后每次翻倍
+	MessageTaskRetryMaxAge      = 86400 // 只重试最近创建的任务
 )
 
 // CreateMessageTasks 从集群、节点或者服务中创建任务
 func (this *MessageTaskDAO) CreateMessageTasks(tx *dbs.Tx, role nodeconfigs.NodeRole, clusterId int64, nodeId int64, serverId int64, messageType MessageType, subject string, body string) error {
+	receivers, err := SharedMessageReceiverDAO.FindEnabledBestFitReceivers(tx, role, clusterId, nodeId, serverId, messageType)
+	if err != nil {
+		return err
+	}
+	if len(receivers) == 0 {
+		return nil
+	}
+
+	// 展开接收人和接收人分组
+	var recipientIds = []int64{}
+	var recipientIdMap = map[int64]bool{}
+	for _, receiver := range receivers {
+		if receiver.RecipientId > 0 {
+			var recipientId = int64(receiver.RecipientId)
+			if !recipientIdMap[recipientId] {
+				recipientIdMap[recipientId] = true
+				recipientIds = append(recipientIds, recipientId)
+			}
+		} else if receiver.RecipientGroupId > 0 {
+			groupRecipientIds, err := SharedMessageRecipientDAO.FindAllEnabledAndOnRecipientIdsWithGroup(tx, int64(receiver.RecipientGroupId))
+			if err != nil {
+				return err
+			}
+			for _, recipientId := range groupRecipientIds {
+				if !recipientIdMap[recipientId] {
+					recipientIdMap[recipientId] = true
+					recipientIds = append(recipientIds, recipientId)
+				}
+			}
+		}
+	}
+
+	var cacheMap = utils.NewCacheMap()
+	var now = time.Now()
+	for _, recipientId := range recipientIds {
+		recipient, err := SharedMessageRecipientDAO.FindEnabledMessageRecipient(tx, recipientId, cacheMap)
+		if err != nil {
+			return err
+		}
+		if recipient == nil || !recipient.IsOn || recipient.InstanceId == 0 {
+			continue
+		}
+
+		// 检查接收时间段
+		if !recipient.IsInTimeRange(now) {
+			continue
+		}
+
+		instance, err := SharedMessageMediaInstanceDAO.FindEnabledMessageMediaInstance(tx, int64(recipient.InstanceId), cacheMap)
+		if err != nil {
+			return err
+		}
+		if instance == nil || !instance.IsOn {
+			continue
+		}
+
+		_, err = this.CreateMessageTask(tx, recipientId, int64(instance.Id), recipient.User, subject, body, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateMessageTask 创建单个消息任务
+// 如果媒介设置了HASH有效期，则在有效期内相同的消息只发送一次
+func (this *MessageTaskDAO) CreateMessageTask(tx *dbs.Tx, recipientId int64, instanceId int64, user string, subject string, body string, isPrimary bool) (taskId int64, err error) {
+	var hash = this.calHash(instanceId, user, subject, body)
+
+	hashLifeSeconds, err := SharedMessageMediaInstanceDAO.FindInstanceHashLifeSeconds(tx, instanceId)
+	if err != nil {
+		return 0, err
+	}
+	if hashLifeSeconds > 0 {
+		exists, err := this.Query(tx).
+			Attr("hash", hash).
+			Gte("createdAt", time.Now().Unix()-int64(hashLifeSeconds)).
+			State(MessageTaskStateEnabled).
+			Exist()
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			return 0, nil
+		}
+	}
+
+	var op = NewMessageTaskOperator()
+	op.RecipientId = recipientId
+	op.InstanceId = instanceId
+	op.User = user
+	op.Hash = hash
+	op.Subject = subject
+	op.Body = body
+	op.IsPrimary = isPrimary
+	op.Status = MessageTaskStatusNone
+	op.Day = timeutil.Format("Ymd")
+	op.State = MessageTaskStateEnabled
+	taskId, err = this.SaveInt64(tx, op)
+	if err != nil {
+		return 0, err
+	}
+
+	// 通知发送
+	select {
+	case MessageTasksNotifier <- true:
+	default:
+	}
+
+	return taskId, nil
+}
+
+// FindSendingMessageTasks 查找需要发送的任务
+func (this *MessageTaskDAO) FindSendingMessageTasks(tx *dbs.Tx, size int64) (result []*MessageTask, err error) {
+	if size <= 0 {
+		return nil, nil
+	}
+	_, err = this.Query(tx).
+		Attr("status", MessageTaskStatusNone).
+		State(MessageTaskStateEnabled).
+		Desc("isPrimary").
+		AscPk().
+		Limit(size).
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// UpdateMessageTaskStatus 设置发送状态
+func (this *MessageTaskDAO) UpdateMessageTaskStatus(tx *dbs.Tx, taskId int64, status MessageTaskStatus, result []byte) error {
+	if taskId <= 0 {
+		return nil
+	}
+	var op = NewMessageTaskOperator()
+	op.Id = taskId
+	op.Status = status
+	op.SentAt = time.Now().Unix()
+	if len(result) > 0 {
+		op.Result = result
+	}
+	return this.Save(tx, op)
+}
+
+// UpdateMessageTaskResult 设置发送结果，并记录发送日志
+func (this *MessageTaskDAO) UpdateMessageTaskResult(tx *dbs.Tx, taskId int64, isOk bool, errString string, response string) error {
+	var status = MessageTaskStatusSuccess
+	if !isOk {
+		status = MessageTaskStatusFailed
+	}
+
+	resultJSON, err := json.Marshal(maps.Map{
+		"isOk":     isOk,
+		"error":    errString,
+		"response": response,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = this.UpdateMessageTaskStatus(tx, taskId, status, resultJSON)
+	if err != nil {
+		return err
+	}
+
+	return SharedMessageTaskLogDAO.CreateLog(tx, taskId, isOk, errString, response)
+}
+
+// CountInstanceSentTasks 计算某个媒介从某个时间点开始已发送的任务数量
+func (this *MessageTaskDAO) CountInstanceSentTasks(tx *dbs.Tx, instanceId int64, sinceTimestamp int64) (int64, error) {
+	return this.Query(tx).
+		Attr("instanceId", instanceId).
+		Attr("status", []int{MessageTaskStatusSending, MessageTaskStatusSuccess, MessageTaskStatusFailed}).
+		Gte("sentAt", sinceTimestamp).
+		Count()
+}
+
+// ResetTimeoutSendingTasks 将长时间处于发送中的任务设置为失败
+func (this *MessageTaskDAO) ResetTimeoutSendingTasks(tx *dbs.Tx, timeoutSeconds int64) error {
+	_, err := this.Query(tx).
+		Attr("status", MessageTaskStatusSending).
+		Lt("sentAt", time.Now().Unix()-timeoutSeconds).
+		Set("status", MessageTaskStatusFailed).
+		Update()
+	return err
+}
+
+// RetryFailedMessageTasks 将可以重试的失败任务重新设置为待发送
+// 每个任务最多重试 MessageTaskMaxRetries 次，重试间隔按失败次数翻倍
+func (this *MessageTaskDAO) RetryFailedMessageTasks(tx *dbs.Tx, size int64) error {
+	var now = time.Now().Unix()
+	ones, err := this.Query(tx).
+		Attr("status", MessageTaskStatusFailed).
+		State(MessageTaskStateEnabled).
+		Gte("createdAt", now-MessageTaskRetryMaxAge).
+		Lte("sentAt", now-MessageTaskRetryBaseSeconds).
+		Result("id", "sentAt").
+		AscPk().
+		Limit(size).
+		FindAll()
+	if err != nil {
+		return err
+	}
+	for _, one := range ones {
+		var task = one.(*MessageTask)
+		countFails, err := SharedMessageTaskLogDAO.Query(tx).
+			Attr("taskId", task.Id).
+			Attr("isOk", false).
+			Count()
+		if err != nil {
+			return err
+		}
+		if countFails > MessageTaskMaxRetries {
+			continue
+		}
+		if int64(task.SentAt)+MessageTaskRetryDelay(countFails) > now {
+			continue
+		}
+		err = this.Query(tx).
+			Pk(task.Id).
+			Attr("status", MessageTaskStatusFailed).
+			Set("status", MessageTaskStatusNone).
+			UpdateQuickly()
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// MessageTaskRetryDelay 计算失败countFails次后重试需要等待的时间
+func MessageTaskRetryDelay(countFails int64) int64 {
+	if countFails <= 1 {
+		return MessageTaskRetryBaseSeconds
+	}
+	if countFails > MessageTaskMaxRetries {
+		countFails = MessageTaskMaxRetries
+	}
+	return MessageTaskRetryBaseSeconds << (countFails - 1)
+}
+
+// 计算任务HASH
+func (this *MessageTaskDAO) calHash(instanceId int64, user string, subject string, body string) string {
+	var h = md5.New()
+	h.Write([]byte(types.String(instanceId) + "@" + user + "@"))
+	h.Write([]byte(subject + "@"))
+	h.Write([]byte(body))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
@@ -61,3 +63,87 @@ func (this *NodeActionDAO) FindEnabledNodeAction(tx *dbs.Tx, id int64) (*NodeAct
 	}
 	return result.(*NodeAction), err
 }
+
+// CreateNodeAction 创建动作
+func (this *NodeActionDAO) CreateNodeAction(tx *dbs.Tx, role string, nodeId int64, conds *NodeActionConds, action *NodeActionConfig) (int64, error) {
+	if conds == nil {
+		conds = &NodeActionConds{}
+	}
+	condsJSON, err := json.Marshal(conds)
+	if err != nil {
+		return 0, err
+	}
+
+	if action == nil {
+		return 0, errors.New("invalid action")
+	}
+	actionJSON, err := json.Marshal(action)
+	if err != nil {
+		return 0, err
+	}
+
+	var op = NewNodeActionOperator()
+	op.Role = role
+	op.NodeId = nodeId
+	op.IsOn = true
+	op.Conds = condsJSON
+	op.Action = actionJSON
+	op.State = NodeActionStateEnabled
+	return this.SaveInt64(tx, op)
+}
+
+// FindAllAvailableNodeActions 查找节点所有启用的动作
+func (this *NodeActionDAO) FindAllAvailableNodeActions(tx *dbs.Tx, role string, nodeId int64) (result []*NodeAction, err error) {
+	_, err = this.Query(tx).
+		Attr("role", role).
+		Attr("nodeId", nodeId).
+		Attr("isOn", true).
+		State(NodeActionStateEnabled).
+		Desc("order").
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// UpdateNodeAction 修改动作
+func (this *NodeActionDAO) UpdateNodeAction(tx *dbs.Tx, actionId int64, conds *NodeActionConds, action *NodeActionConfig, isOn bool) error {
+	if actionId <= 0 {
+		return errors.New("invalid actionId")
+	}
+	if conds == nil {
+		conds = &NodeActionConds{}
+	}
+	condsJSON, err := json.Marshal(conds)
+	if err != nil {
+		return err
+	}
+
+	if action == nil {
+		return errors.New("invalid action")
+	}
+	actionJSON, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+
+	var op = NewNodeActionOperator()
+	op.Id = actionId
+	op.IsOn = isOn
+	op.Conds = condsJSON
+	op.Action = actionJSON
+	return this.Save(tx, op)
+}
+
+// FindAllEnabledNodeActions 查找节点所有的动作，包括未启用的
+func (this *NodeActionDAO) FindAllEnabledNodeActions(tx *dbs.Tx, role string, nodeId int64) (result []*NodeAction, err error) {
+	_, err = this.Query(tx).
+		Attr("role", role).
+		Attr("nodeId", nodeId).
+		State(NodeActionStateEnabled).
+		Desc("order").
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
@@ -1,6 +1,45 @@
 package models_test
 
 import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/assert"
 	_ "github.com/iwind/TeaGo/bootstrap"
+	"testing"
 )
+
+func TestNodeAction_Decode(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var action = &models.NodeAction{
+		Conds:  []byte(`{"events": ["healthCheckFailed"]}`),
+		Action: []byte(`{"code": "switchToBackupCluster", "params": {"clusterId": 2}, "cooldownSeconds": 300}`),
+	}
+
+	var conds = action.DecodeConds()
+	a.IsTrue(conds.MatchEvent(models.NodeActionEventHealthCheckFailed))
+	a.IsFalse(conds.MatchEvent(models.NodeActionEventHealthCheckRecovered))
+
+	config, err := action.DecodeAction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.IsTrue(config.Code == models.NodeActionCodeSwitchToBackupCluster)
+	a.IsTrue(config.Params.GetInt64("clusterId") == 2)
+	a.IsTrue(config.CooldownSeconds == 300)
+}
+
+func TestNode_DecodeActionStatus(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	{
+		var node = &models.Node{}
+		a.IsTrue(node.DecodeActionStatus() == nil)
+	}
+	{
+		var node = &models.Node{ActionStatus: []byte(`{"actionId": 1, "code": "offline"}`)}
+		var status = node.DecodeActionStatus()
+		a.IsNotNil(status)
+		a.IsTrue(status.Code == models.NodeActionCodeOffline)
+	}
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/rands"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"time"
+)
+
+type NodeActionLogDAO dbs.DAO
+
+func NewNodeActionLogDAO() *NodeActionLogDAO {
+	return dbs.NewDAO(&NodeActionLogDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNodeActionLogs",
+			Model:  new(NodeActionLog),
+			PkName: "id",
+		},
+	}).(*NodeActionLogDAO)
+}
+
+var SharedNodeActionLogDAO *NodeActionLogDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNodeActionLogDAO = NewNodeActionLogDAO()
+	})
+}
+
+func init() {
+	dbs.OnReadyDone(func() {
+		// 清理数据任务
+		var ticker = time.NewTicker(time.Duration(rands.Int(24, 48)) * time.Hour)
+		goman.New(func() {
+			for range ticker.C {
+				err := SharedNodeActionLogDAO.CleanExpiredLogs(nil, 30) // 只保留30天
+				if err != nil {
+					remotelogs.Error("SharedNodeActionLogDAO", "clean expired data failed: "+err.Error())
+				}
+			}
+		})
+	})
+}
+
+// CreateLog 记录动作执行结果
+func (this *NodeActionLogDAO) CreateLog(tx *dbs.Tx, action *NodeAction, clusterId int64, event NodeActionEvent, code NodeActionCode, resultErr error) error {
+	var op = NewNodeActionLogOperator()
+	op.ActionId = action.Id
+	op.Role = action.Role
+	op.ClusterId = clusterId
+	op.NodeId = action.NodeId
+	op.Event = event
+	op.Code = code
+	op.IsOk = resultErr == nil
+	if resultErr != nil {
+		op.Error = resultErr.Error()
+	} else {
+		op.Error = ""
+	}
+	op.Day = timeutil.Format("Ymd")
+	return this.Save(tx, op)
+}
+
+// FindLatestLog 查找动作最近一次执行日志
+func (this *NodeActionLogDAO) FindLatestLog(tx *dbs.Tx, actionId int64, nodeId int64) (*NodeActionLog, error) {
+	one, err := this.Query(tx).
+		Attr("actionId", actionId).
+		Attr("nodeId", nodeId).
+		DescPk().
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*NodeActionLog), nil
+}
+
+// CountLogs 计算日志数量
+func (this *NodeActionLogDAO) CountLogs(tx *dbs.Tx, role string, nodeId int64, actionId int64) (int64, error) {
+	var query = this.Query(tx)
+	if len(role) > 0 {
+		query.Attr("role", role)
+	}
+	if nodeId > 0 {
+		query.Attr("nodeId", nodeId)
+	}
+	if actionId > 0 {
+		query.Attr("actionId", actionId)
+	}
+	return query.Count()
+}
+
+// ListLogs 列出单页日志
+func (this *NodeActionLogDAO) ListLogs(tx *dbs.Tx, role string, nodeId int64, actionId int64, offset int64, size int64) (result []*NodeActionLog, err error) {
+	var query = this.Query(tx)
+	if len(role) > 0 {
+		query.Attr("role", role)
+	}
+	if nodeId > 0 {
+		query.Attr("nodeId", nodeId)
+	}
+	if actionId > 0 {
+		query.Attr("actionId", actionId)
+	}
+	_, err = query.
+		Offset(offset).
+		Limit(size).
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// CleanExpiredLogs 清理
+func (this *NodeActionLogDAO) CleanExpiredLogs(tx *dbs.Tx, days int) error {
+	if days <= 0 {
+		days = 30
+	}
+	var day = timeutil.Format("Ymd", time.Now().AddDate(0, 0, -days))
+	_, err := this.Query(tx).
+		Lt("day", day).
+		Delete()
+	return err
+}
@@ -0,0 +1,34 @@
+package models
+
+// NodeActionLog 节点动作执行日志
+type NodeActionLog struct {
+	Id        uint64 `field:"id"`        // ID
+	ActionId  uint64 `field:"actionId"`  // 动作ID
+	Role      string `field:"role"`      // 节点角色
+	ClusterId uint32 `field:"clusterId"` // 集群ID
+	NodeId    uint32 `field:"nodeId"`    // 节点ID
+	Event     string `field:"event"`     // 触发事件
+	Code      string `field:"code"`      // 动作代号
+	IsOk      bool   `field:"isOk"`      // 是否成功
+	Error     string `field:"error"`     // 错误信息
+	CreatedAt uint64 `field:"createdAt"` // 创建时间
+	Day       string `field:"day"`       // YYYYMMDD
+}
+
+type NodeActionLogOperator struct {
+	Id        interface{} // ID
+	ActionId  interface{} // 动作ID
+	Role      interface{} // 节点角色
+	ClusterId interface{} // 集群ID
+	NodeId    interface{} // 节点ID
+	Event     interface{} // 触发事件
+	Code      interface{} // 动作代号
+	IsOk      interface{} // 是否成功
+	Error     interface{} // 错误信息
+	CreatedAt interface{} // 创建时间
+	Day       interface{} // YYYYMMDD
+}
+
+func NewNodeActionLogOperator() *NodeActionLogOperator {
+	return &NodeActionLogOperator{}
+}
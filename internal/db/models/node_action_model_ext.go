@@ -1 +1,82 @@
 package models
+
+import (
+	"encoding/json"
+	"github.com/iwind/TeaGo/lists"
+	"github.com/iwind/TeaGo/maps"
+)
+
+// NodeActionEvent 触发节点动作的事件
+type NodeActionEvent = string
+
+const (
+	NodeActionEventHealthCheckFailed    NodeActionEvent = "healthCheckFailed"    // 健康检查失败
+	NodeActionEventHealthCheckRecovered NodeActionEvent = "healthCheckRecovered" // 健康检查恢复
+)
+
+// NodeActionCode 节点动作代号
+type NodeActionCode = string
+
+const (
+	NodeActionCodeSwitchToBackupCluster NodeActionCode = "switchToBackupCluster" // 将节点的DNS解析切换到备用集群
+	NodeActionCodeOffline               NodeActionCode = "offline"               // 从DNS中下线节点
+	NodeActionCodeRestore               NodeActionCode = "restore"               // 恢复节点，清除以往动作产生的状态
+	NodeActionCodeWebHook               NodeActionCode = "webHook"               // 调用WebHook
+)
+
+// NodeActionDefaultCooldownSeconds 默认冷却时间
+// 非自动下线模式下每次健康检查失败都会触发事件，没有冷却时间时会频繁执行动作
+const NodeActionDefaultCooldownSeconds int64 = 600
+
+// NodeActionConds 节点动作触发条件
+type NodeActionConds struct {
+	Events []NodeActionEvent `json:"events"` // 触发事件
+}
+
+// MatchEvent 检查事件是否匹配
+func (this *NodeActionConds) MatchEvent(event NodeActionEvent) bool {
+	return lists.ContainsString(this.Events, event)
+}
+
+// NodeActionConfig 节点动作配置
+type NodeActionConfig struct {
+	Code            NodeActionCode `json:"code"`            // 动作代号
+	Params          maps.Map       `json:"params"`          // 动作参数
+	CooldownSeconds int64          `json:"cooldownSeconds"` // 冷却时间，在此时间内不会重复执行，小于等于0时使用默认冷却时间
+}
+
+// NodeActionStatus 节点当前动作状态
+type NodeActionStatus struct {
+	ActionId        int64          `json:"actionId"`                  // 动作ID
+	Code            NodeActionCode `json:"code"`                      // 动作代号
+	BackupClusterId int64          `json:"backupClusterId,omitempty"` // 备用集群ID
+	CreatedAt       int64          `json:"createdAt"`                 // 开始时间
+}
+
+// DecodeConds 解析触发条件
+func (this *NodeAction) DecodeConds() *NodeActionConds {
+	var conds = &NodeActionConds{}
+	if IsNotNull(this.Conds) {
+		_ = json.Unmarshal(this.Conds, conds)
+	}
+	return conds
+}
+
+// DecodeAction 解析动作配置
+func (this *NodeAction) DecodeAction() (*NodeActionConfig, error) {
+	var config = &NodeActionConfig{}
+	if IsNull(this.Action) {
+		return config, nil
+	}
+	err := json.Unmarshal(this.Action, config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Params == nil {
+		config.Params = maps.Map{}
+	}
+	if config.CooldownSeconds <= 0 {
+		config.CooldownSeconds = NodeActionDefaultCooldownSeconds
+	}
+	return config, nil
+}
@@ -1636,6 +1636,51 @@ func (this *NodeDAO) FindAllEnabledNodesDNSWithClusterId(tx *dbs.Tx, clusterId i
 		DescPk().
 		Slice(&result).
 		FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// 已切换到备用集群的节点仍然需要解析
+	switchedNodes, err := this.findAllSwitchedNodesDNSWithClusterId(tx, clusterId, includeSecondaryNodes, includingLnNodes, isInstalled)
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, switchedNodes...)
+	return
+}
+
+// 获取一个集群中已下线但切换到了备用集群的节点DNS信息
+func (this *NodeDAO) findAllSwitchedNodesDNSWithClusterId(tx *dbs.Tx, clusterId int64, includeSecondaryNodes bool, includingLnNodes bool, isInstalled bool) (result []*Node, err error) {
+	var query = this.Query(tx)
+	if includeSecondaryNodes {
+		query.Where("(clusterId=:primaryClusterId OR JSON_CONTAINS(secondaryClusterIds, :primaryClusterIdString))").
+			Param("primaryClusterId", clusterId).
+			Param("primaryClusterIdString", types.String(clusterId))
+	} else {
+		query.Attr("clusterId", clusterId)
+	}
+	if !includingLnNodes {
+		query.Lte("level", 1)
+	}
+	ones, err := query.
+		State(NodeStateEnabled).
+		Attr("isOn", true).
+		Attr("isUp", false).
+		Attr("isInstalled", isInstalled).
+		Where("actionStatus IS NOT NULL").
+		Result("id", "name", "dnsRoutes", "isOn", "offlineDay", "actionStatus", "isBackupForCluster", "isBackupForGroup", "backupIPs", "clusterId", "groupId").
+		DescPk().
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, one := range ones {
+		var node = one.(*Node)
+		var status = node.DecodeActionStatus()
+		if status != nil && status.Code == NodeActionCodeSwitchToBackupCluster {
+			result = append(result, node)
+		}
+	}
 	return
 }
 
@@ -1883,6 +1928,31 @@ func (this *NodeDAO) UpdateNodeUp(tx *dbs.Tx, nodeId int64, isUp bool) error {
 	return this.NotifyDNSUpdate(tx, nodeId)
 }
 
+// UpdateNodeActionStatus 修改节点当前动作状态，status为nil时表示清除
+func (this *NodeDAO) UpdateNodeActionStatus(tx *dbs.Tx, nodeId int64, status *NodeActionStatus) error {
+	if nodeId <= 0 {
+		return errors.New("invalid nodeId")
+	}
+
+	var query = this.Query(tx).
+		Pk(nodeId)
+	if status == nil {
+		query.Set("actionStatus", dbs.SQL("NULL"))
+	} else {
+		statusJSON, err := json.Marshal(status)
+		if err != nil {
+			return err
+		}
+		query.Set("actionStatus", statusJSON)
+	}
+	err := query.UpdateQuickly()
+	if err != nil {
+		return err
+	}
+
+	return this.NotifyDNSUpdate(tx, nodeId)
+}
+
 // UpdateNodeActive 修改节点活跃状态
 func (this *NodeDAO) UpdateNodeActive(tx *dbs.Tx, nodeId int64, isActive bool) error {
 	if nodeId <= 0 {
@@ -9,6 +9,7 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/zero"
 	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
 	"github.com/iwind/TeaGo/dbs"
+	"net"
 )
 
 func (this *NodeDAO) loadServersFromCluster(tx *dbs.Tx, clusterId int64, serverIdMap map[int64]zero.Zero) ([]*Server, error) {
@@ -21,5 +22,79 @@ func (this *NodeDAO) composeExtConfig(tx *dbs.Tx, config *nodeconfigs.NodeConfig
 
 // CheckNodeIPAddresses 检查节点IP地址
 func (this *NodeDAO) CheckNodeIPAddresses(tx *dbs.Tx, node *Node) (shouldSkip bool, shouldOverwrite bool, ipAddressStrings []string, err error) {
+	var status = node.DecodeActionStatus()
+	if status == nil {
+		return
+	}
+
+	switch status.Code {
+	case NodeActionCodeOffline:
+		shouldSkip = true
+	case NodeActionCodeSwitchToBackupCluster:
+		ipAddressStrings, err = this.findClusterDNSIPAddresses(tx, status.BackupClusterId)
+		if err != nil {
+			return
+		}
+
+		// 备用集群中没有可用的IP时，不再解析当前节点
+		if len(ipAddressStrings) == 0 {
+			shouldSkip = true
+			return
+		}
+		shouldOverwrite = true
+	}
+
 	return
 }
+
+// 查找集群中可以用来解析的IP地址
+func (this *NodeDAO) findClusterDNSIPAddresses(tx *dbs.Tx, clusterId int64) ([]string, error) {
+	var result = []string{}
+	if clusterId <= 0 {
+		return result, nil
+	}
+
+	nodes, err := this.Query(tx).
+		State(NodeStateEnabled).
+		Attr("clusterId", clusterId).
+		Attr("isOn", true).
+		Attr("isUp", true).
+		Attr("isInstalled", true).
+		Result("id", "actionStatus").
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var ipMap = map[string]bool{}
+	for _, nodeOne := range nodes {
+		var node = nodeOne.(*Node)
+
+		// 不使用本身也处于动作状态中的节点
+		if node.DecodeActionStatus() != nil {
+			continue
+		}
+
+		ipAddresses, err := SharedNodeIPAddressDAO.FindAllEnabledAddressesWithNode(tx, int64(node.Id), nodeconfigs.NodeRoleNode)
+		if err != nil {
+			return nil, err
+		}
+		for _, ipAddress := range ipAddresses {
+			if !ipAddress.IsValidInCluster(clusterId) {
+				continue
+			}
+
+			var ip = ipAddress.DNSIP()
+			if len(ip) == 0 || !ipAddress.CanAccess || !ipAddress.IsUp || !ipAddress.IsOn {
+				continue
+			}
+			if net.ParseIP(ip) == nil || ipMap[ip] {
+				continue
+			}
+			ipMap[ip] = true
+			result = append(result, ip)
+		}
+	}
+
+	return result, nil
+}
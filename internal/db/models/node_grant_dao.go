@@ -74,7 +74,7 @@ func (this *NodeGrantDAO) FindNodeGrantName(tx *dbs.Tx, id uint32) (string, erro
 }
 
 // CreateGrant 创建认证信息
-func (this *NodeGrantDAO) CreateGrant(tx *dbs.Tx, adminId int64, name string, method string, username string, password string, privateKey string, passphrase string, description string, nodeId int64, su bool) (grantId int64, err error) {
+func (this *NodeGrantDAO) CreateGrant(tx *dbs.Tx, adminId int64, name string, method string, username string, password string, privateKey string, passphrase string, certificate string, jumpHostsJSON []byte, description string, nodeId int64, su bool) (grantId int64, err error) {
 	var op = NewNodeGrantOperator()
 	op.AdminId = adminId
 	op.Name = name
@@ -88,10 +88,20 @@ func (this *NodeGrantDAO) CreateGrant(tx *dbs.Tx, adminId int64, name string, me
 		op.Username = username
 		op.PrivateKey = privateKey
 		op.Passphrase = passphrase
+	case "certificate":
+		op.Username = username
+		op.PrivateKey = privateKey
+		op.Passphrase = passphrase
+		op.Certificate = certificate
 	}
 	if username != "root" { // only for non-root user
 		op.Su = su
 	}
+	if IsNotNull(jumpHostsJSON) {
+		op.JumpHosts = jumpHostsJSON
+	} else {
+		op.JumpHosts = "[]"
+	}
 	op.Description = description
 	op.NodeId = nodeId
 	op.State = NodeGrantStateEnabled
@@ -100,7 +110,7 @@ func (this *NodeGrantDAO) CreateGrant(tx *dbs.Tx, adminId int64, name string, me
 }
 
 // UpdateGrant 修改认证信息
-func (this *NodeGrantDAO) UpdateGrant(tx *dbs.Tx, grantId int64, name string, method string, username string, password string, privateKey string, passphrase string, description string, nodeId int64, su bool) error {
+func (this *NodeGrantDAO) UpdateGrant(tx *dbs.Tx, grantId int64, name string, method string, username string, password string, privateKey string, passphrase string, certificate string, jumpHostsJSON []byte, description string, nodeId int64, su bool) error {
 	if grantId <= 0 {
 		return errors.New("invalid grantId")
 	}
@@ -118,12 +128,22 @@ func (this *NodeGrantDAO) UpdateGrant(tx *dbs.Tx, grantId int64, name string, me
 		op.Username = username
 		op.PrivateKey = privateKey
 		op.Passphrase = passphrase
+	case "certificate":
+		op.Username = username
+		op.PrivateKey = privateKey
+		op.Passphrase = passphrase
+		op.Certificate = certificate
 	}
 	if username != "root" { // only for non-root user
 		op.Su = su
 	} else {
 		op.Su = false
 	}
+	if IsNotNull(jumpHostsJSON) {
+		op.JumpHosts = jumpHostsJSON
+	} else {
+		op.JumpHosts = "[]"
+	}
 	op.Description = description
 	op.NodeId = nodeId
 	err := this.Save(tx, op)
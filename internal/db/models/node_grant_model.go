@@ -1,21 +1,25 @@
 package models
 
+import "github.com/iwind/TeaGo/dbs"
+
 // NodeGrant 节点授权
 type NodeGrant struct {
-	Id          uint32 `field:"id"`          // ID
-	AdminId     uint32 `field:"adminId"`     // 管理员ID
-	Name        string `field:"name"`        // 名称
-	Method      string `field:"method"`      // 登录方式
-	Username    string `field:"username"`    // 用户名
-	Password    string `field:"password"`    // 密码
-	Su          uint8  `field:"su"`          // 是否需要su
-	PrivateKey  string `field:"privateKey"`  // 私钥
-	Passphrase  string `field:"passphrase"`  // 私钥密码
-	Description string `field:"description"` // 备注
-	NodeId      uint32 `field:"nodeId"`      // 专有节点
-	Role        string `field:"role"`        // 角色
-	State       uint8  `field:"state"`       // 状态
-	CreatedAt   uint64 `field:"createdAt"`   // 创建时间
+	Id          uint32   `field:"id"`          // ID
+	AdminId     uint32   `field:"adminId"`     // 管理员ID
+	Name        string   `field:"name"`        // 名称
+	Method      string   `field:"method"`      // 登录方式
+	Username    string   `field:"username"`    // 用户名
+	Password    string   `field:"password"`    // 密码
+	Su          uint8    `field:"su"`          // 是否需要su
+	PrivateKey  string   `field:"privateKey"`  // 私钥
+	Passphrase  string   `field:"passphrase"`  // 私钥密码
+	Certificate string   `field:"certificate"` // OpenSSH用户证书
+	JumpHosts   dbs.JSON `field:"jumpHosts"`   // 跳板机
+	Description string   `field:"description"` // 备注
+	NodeId      uint32   `field:"nodeId"`      // 专有节点
+	Role        string   `field:"role"`        // 角色
+	State       uint8    `field:"state"`       // 状态
+	CreatedAt   uint64   `field:"createdAt"`   // 创建时间
 }
 
 type NodeGrantOperator struct {
@@ -28,6 +32,8 @@ type NodeGrantOperator struct {
 	Su          interface{} // 是否需要su
 	PrivateKey  interface{} // 私钥
 	Passphrase  interface{} // 私钥密码
+	Certificate interface{} // OpenSSH用户证书
+	JumpHosts   interface{} // 跳板机
 	Description interface{} // 备注
 	NodeId      interface{} // 专有节点
 	Role        interface{} // 角色
@@ -1 +1,16 @@
 package models
+
+import "encoding/json"
+
+// DecodeJumpHosts 解析跳板机
+func (this *NodeGrant) DecodeJumpHosts() ([]*NodeSSHJumpHost, error) {
+	var result = []*NodeSSHJumpHost{}
+	if !IsNotNull(this.JumpHosts) {
+		return result, nil
+	}
+	err := json.Unmarshal(this.JumpHosts, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+const (
+	NodeLoginHostKeySourceAuto   = "auto"   // 第一次连接时自动记录
+	NodeLoginHostKeySourceManual = "manual" // 手动添加
+)
+
+type NodeLoginHostKeyDAO dbs.DAO
+
+func NewNodeLoginHostKeyDAO() *NodeLoginHostKeyDAO {
+	return dbs.NewDAO(&NodeLoginHostKeyDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNodeLoginHostKeys",
+			Model:  new(NodeLoginHostKey),
+			PkName: "id",
+		},
+	}).(*NodeLoginHostKeyDAO)
+}
+
+var SharedNodeLoginHostKeyDAO *NodeLoginHostKeyDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNodeLoginHostKeyDAO = NewNodeLoginHostKeyDAO()
+	})
+}
+
+// FindHostKey 查找某个节点主机的公钥
+func (this *NodeLoginHostKeyDAO) FindHostKey(tx *dbs.Tx, role nodeconfigs.NodeRole, nodeId int64, host string, port int) (*NodeLoginHostKey, error) {
+	if len(role) == 0 {
+		role = nodeconfigs.NodeRoleNode
+	}
+	one, err := this.Query(tx).
+		Attr("role", role).
+		Attr("nodeId", nodeId).
+		Attr("host", host).
+		Attr("port", port).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*NodeLoginHostKey), nil
+}
+
+// FindLatestHostKeyWithAddr 查找某个主机地址最近记录的公钥，不区分节点
+func (this *NodeLoginHostKeyDAO) FindLatestHostKeyWithAddr(tx *dbs.Tx, host string, port int) (*NodeLoginHostKey, error) {
+	one, err := this.Query(tx).
+		Attr("host", host).
+		Attr("port", port).
+		DescPk().
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*NodeLoginHostKey), nil
+}
+
+// SaveHostKey 记录主机公钥，如果已经存在则覆盖
+func (this *NodeLoginHostKeyDAO) SaveHostKey(tx *dbs.Tx, loginId int64, role nodeconfigs.NodeRole, nodeId int64, host string, port int, keyType string, fingerprint string, publicKey string, source string) error {
+	if len(role) == 0 {
+		role = nodeconfigs.NodeRoleNode
+	}
+
+	oldKey, err := this.FindHostKey(tx, role, nodeId, host, port)
+	if err != nil {
+		return err
+	}
+
+	var op = NewNodeLoginHostKeyOperator()
+	if oldKey != nil {
+		op.Id = oldKey.Id
+		op.UpdatedAt = time.Now().Unix()
+	} else {
+		op.Role = role
+		op.NodeId = nodeId
+		op.Host = host
+		op.Port = port
+		op.CreatedAt = time.Now().Unix()
+	}
+	op.LoginId = loginId
+	op.KeyType = keyType
+	op.Fingerprint = fingerprint
+	op.PublicKey = publicKey
+	op.Source = source
+	return this.Save(tx, op)
+}
+
+// FindAllHostKeysWithNodeId 查找某个节点所有的主机公钥
+func (this *NodeLoginHostKeyDAO) FindAllHostKeysWithNodeId(tx *dbs.Tx, role nodeconfigs.NodeRole, nodeId int64) (result []*NodeLoginHostKey, err error) {
+	if len(role) == 0 {
+		role = nodeconfigs.NodeRoleNode
+	}
+	_, err = this.Query(tx).
+		Attr("role", role).
+		Attr("nodeId", nodeId).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// DeleteHostKeysWithNodeId 删除某个节点的主机公钥
+// host为空时删除所有的主机公钥
+func (this *NodeLoginHostKeyDAO) DeleteHostKeysWithNodeId(tx *dbs.Tx, role nodeconfigs.NodeRole, nodeId int64, host string, port int) error {
+	if len(role) == 0 {
+		role = nodeconfigs.NodeRoleNode
+	}
+	var query = this.Query(tx).
+		Attr("role", role).
+		Attr("nodeId", nodeId)
+	if len(host) > 0 {
+		query.Attr("host", host)
+		if port > 0 {
+			query.Attr("port", port)
+		}
+	}
+	_, err := query.Delete()
+	return err
+}
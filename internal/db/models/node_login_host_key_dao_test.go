@@ -0,0 +1,33 @@
+package models_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/iwind/TeaGo/bootstrap"
+	"github.com/iwind/TeaGo/dbs"
+	"testing"
+)
+
+func TestNodeLoginHostKeyDAO_SaveHostKey(t *testing.T) {
+	var dao = models.NewNodeLoginHostKeyDAO()
+	var tx *dbs.Tx
+	err := dao.SaveHostKey(tx, 0, nodeconfigs.NodeRoleNode, 1, "127.0.0.1", 22, "ssh-ed25519", "SHA256:test", "ssh-ed25519 AAAA", models.NodeLoginHostKeySourceManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostKey, err := dao.FindHostKey(tx, nodeconfigs.NodeRoleNode, 1, "127.0.0.1", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostKey == nil {
+		t.Fatal("should not be nil")
+	}
+	t.Log(hostKey.Fingerprint)
+
+	err = dao.DeleteHostKeysWithNodeId(tx, nodeconfigs.NodeRoleNode, 1, "127.0.0.1", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
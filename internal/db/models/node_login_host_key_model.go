@@ -0,0 +1,36 @@
+package models
+
+// NodeLoginHostKey 节点SSH主机公钥
+type NodeLoginHostKey struct {
+	Id          uint64 `field:"id"`          // ID
+	LoginId     uint64 `field:"loginId"`     // 登录信息ID
+	Role        string `field:"role"`        // 节点角色
+	NodeId      uint32 `field:"nodeId"`      // 节点ID
+	Host        string `field:"host"`        // 主机地址
+	Port        uint32 `field:"port"`        // 端口
+	KeyType     string `field:"keyType"`     // 公钥类型
+	Fingerprint string `field:"fingerprint"` // 公钥指纹
+	PublicKey   string `field:"publicKey"`   // 公钥，authorized_keys格式
+	Source      string `field:"source"`      // 来源：auto, manual
+	CreatedAt   uint64 `field:"createdAt"`   // 创建时间
+	UpdatedAt   uint64 `field:"updatedAt"`   // 修改时间
+}
+
+type NodeLoginHostKeyOperator struct {
+	Id          interface{} // ID
+	LoginId     interface{} // 登录信息ID
+	Role        interface{} // 节点角色
+	NodeId      interface{} // 节点ID
+	Host        interface{} // 主机地址
+	Port        interface{} // 端口
+	KeyType     interface{} // 公钥类型
+	Fingerprint interface{} // 公钥指纹
+	PublicKey   interface{} // 公钥，authorized_keys格式
+	Source      interface{} // 来源：auto, manual
+	CreatedAt   interface{} // 创建时间
+	UpdatedAt   interface{} // 修改时间
+}
+
+func NewNodeLoginHostKeyOperator() *NodeLoginHostKeyOperator {
+	return &NodeLoginHostKeyOperator{}
+}
@@ -1,7 +1,16 @@
 package models
 
 type NodeLoginSSHParams struct {
-	GrantId int64  `json:"grantId"`
+	GrantId     int64              `json:"grantId"`
+	Host        string             `json:"host"`
+	Port        int                `json:"port"`
+	HostKeyMode string             `json:"hostKeyMode"` // 主机公钥检查模式：acceptNew, strict
+	JumpHosts   []*NodeSSHJumpHost `json:"jumpHosts"`   // 跳板机，按顺序连接，如果为空则使用认证信息中的跳板机
+}
+
+// NodeSSHJumpHost SSH跳板机
+type NodeSSHJumpHost struct {
 	Host    string `json:"host"`
 	Port    int    `json:"port"`
+	GrantId int64  `json:"grantId"` // 跳板机使用的认证信息，为0表示和目标主机使用同一个认证信息
 }
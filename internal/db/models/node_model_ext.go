@@ -219,3 +219,21 @@ func (this *Node) DecodeAPINodeAddrs() []*serverconfigs.NetworkAddressConfig {
 func (this *Node) CheckIsOffline() bool {
 	return len(this.OfflineDay) > 0 && this.OfflineDay < timeutil.Format("Ymd")
 }
+
+// DecodeActionStatus 解析当前动作状态
+func (this *Node) DecodeActionStatus() *NodeActionStatus {
+	if IsNull(this.ActionStatus) {
+		return nil
+	}
+
+	var status = &NodeActionStatus{}
+	err := json.Unmarshal(this.ActionStatus, status)
+	if err != nil {
+		remotelogs.Error("Node.DecodeActionStatus", err.Error())
+		return nil
+	}
+	if len(status.Code) == 0 {
+		return nil
+	}
+	return status
+}
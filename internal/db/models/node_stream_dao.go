@@ -0,0 +1,121 @@
+package models
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"strings"
+	"time"
+)
+
+const (
+	NodeStreamKeepAliveInterval = 60  // API节点刷新命令通道在线时间的间隔（秒）
+	NodeStreamTTL               = 180 // 超过此时间没有刷新的命令通道视为已断开（秒）
+)
+
+type NodeStreamDAO dbs.DAO
+
+func NewNodeStreamDAO() *NodeStreamDAO {
+	return dbs.NewDAO(&NodeStreamDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNodeStreams",
+			Model:  new(NodeStream),
+			PkName: "id",
+		},
+	}).(*NodeStreamDAO)
+}
+
+var SharedNodeStreamDAO *NodeStreamDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNodeStreamDAO = NewNodeStreamDAO()
+	})
+}
+
+// UpdateNodeStream 记录边缘节点连接到了某个API节点
+// 每个边缘节点只保留最近一次连接
+func (this *NodeStreamDAO) UpdateNodeStream(tx *dbs.Tx, nodeId int64, apiNodeId int64) error {
+	if nodeId <= 0 || apiNodeId <= 0 {
+		return nil
+	}
+
+	streamId, err := this.Query(tx).
+		Attr("nodeId", nodeId).
+		ResultPk().
+		FindInt64Col(0)
+	if err != nil {
+		return err
+	}
+
+	var now = time.Now().Unix()
+	var op = NewNodeStreamOperator()
+	if streamId > 0 {
+		op.Id = streamId
+	}
+	op.NodeId = nodeId
+	op.APINodeId = apiNodeId
+	op.ConnectedAt = now
+	op.UpdatedAt = now
+	return this.Save(tx, op)
+}
+
+// DeleteNodeStream 删除命令通道记录
+// 只删除仍然指向当前API节点的记录，以免误删边缘节点在其他API节点上的新连接
+func (this *NodeStreamDAO) DeleteNodeStream(tx *dbs.Tx, nodeId int64, apiNodeId int64) error {
+	return this.Query(tx).
+		Attr("nodeId", nodeId).
+		Attr("apiNodeId", apiNodeId).
+		DeleteQuickly()
+}
+
+// KeepAPINodeStreams 刷新某个API节点上所有命令通道的在线时间，并清理已经断开的记录
+func (this *NodeStreamDAO) KeepAPINodeStreams(tx *dbs.Tx, apiNodeId int64, nodeIds []int64) error {
+	if apiNodeId <= 0 {
+		return nil
+	}
+
+	var now = time.Now().Unix()
+	if len(nodeIds) > 0 {
+		err := this.Query(tx).
+			Attr("apiNodeId", apiNodeId).
+			Attr("nodeId", nodeIds).
+			Set("updatedAt", now).
+			UpdateQuickly()
+		if err != nil {
+			return err
+		}
+	}
+
+	// 当前API节点上已经不存在的连接
+	var query = this.Query(tx).
+		Attr("apiNodeId", apiNodeId)
+	if len(nodeIds) > 0 {
+		var s = []string{}
+		for _, nodeId := range nodeIds {
+			s = append(s, types.String(nodeId))
+		}
+		query.Where("nodeId NOT IN (" + strings.Join(s, ",") + ")")
+	}
+	err := query.DeleteQuickly()
+	if err != nil {
+		return err
+	}
+
+	// 其他API节点异常退出后遗留的记录
+	return this.Query(tx).
+		Lt("updatedAt", now-NodeStreamTTL).
+		DeleteQuickly()
+}
+
+// FindNodeAPINodeId 查找边缘节点当前连接的API节点
+func (this *NodeStreamDAO) FindNodeAPINodeId(tx *dbs.Tx, nodeId int64) (int64, error) {
+	return this.Query(tx).
+		Attr("nodeId", nodeId).
+		Gte("updatedAt", time.Now().Unix()-NodeStreamTTL).
+		Result("apiNodeId").
+		DescPk().
+		FindInt64Col(0)
+}
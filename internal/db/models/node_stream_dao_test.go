@@ -0,0 +1,147 @@
+package models_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/iwind/TeaGo/bootstrap"
+	"github.com/iwind/TeaGo/dbs"
+	"testing"
+)
+
+func TestNodeStreamDAO_UpdateNodeStream(t *testing.T) {
+	dbs.NotifyReady()
+
+	var dao = models.NewNodeStreamDAO()
+	var tx *dbs.Tx
+	const nodeId = 1_000_001
+
+	err := dao.UpdateNodeStream(tx, nodeId, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = dao.DeleteNodeStream(tx, nodeId, 2)
+	}()
+
+	apiNodeId, err := dao.FindNodeAPINodeId(tx, nodeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiNodeId != 1 {
+		t.Fatal("expect api node 1, but got", apiNodeId)
+	}
+
+	// 连接到其他API节点后只保留最近的一条记录
+	err = dao.UpdateNodeStream(tx, nodeId, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiNodeId, err = dao.FindNodeAPINodeId(tx, nodeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiNodeId != 2 {
+		t.Fatal("expect api node 2, but got", apiNodeId)
+	}
+	count, err := dao.Query(tx).Attr("nodeId", nodeId).Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatal("expect 1 stream, but got", count)
+	}
+}
+
+func TestNodeStreamDAO_DeleteNodeStream(t *testing.T) {
+	dbs.NotifyReady()
+
+	var dao = models.NewNodeStreamDAO()
+	var tx *dbs.Tx
+	const nodeId = 1_000_002
+
+	err := dao.UpdateNodeStream(tx, nodeId, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧的API节点不能删除新的连接
+	err = dao.DeleteNodeStream(tx, nodeId, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiNodeId, err := dao.FindNodeAPINodeId(tx, nodeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiNodeId != 2 {
+		t.Fatal("stream should not be deleted by other api node")
+	}
+
+	err = dao.DeleteNodeStream(tx, nodeId, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiNodeId, err = dao.FindNodeAPINodeId(tx, nodeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiNodeId != 0 {
+		t.Fatal("stream should be deleted")
+	}
+}
+
+func TestNodeStreamDAO_KeepAPINodeStreams(t *testing.T) {
+	dbs.NotifyReady()
+
+	var dao = models.NewNodeStreamDAO()
+	var tx *dbs.Tx
+	const apiNodeId = 1_000_001
+	const keptNodeId = 1_000_003
+	const closedNodeId = 1_000_004
+	const staleNodeId = 1_000_005
+
+	for _, nodeId := range []int64{keptNodeId, closedNodeId, staleNodeId} {
+		err := dao.UpdateNodeStream(tx, nodeId, apiNodeId)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		_ = dao.KeepAPINodeStreams(tx, apiNodeId, nil)
+	}()
+
+	// 模拟其他API节点异常退出后遗留的记录
+	err := dao.UpdateNodeStream(tx, staleNodeId, apiNodeId+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dao.Query(tx).
+		Attr("nodeId", staleNodeId).
+		Set("updatedAt", 1).
+		UpdateQuickly()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dao.KeepAPINodeStreams(tx, apiNodeId, []int64{keptNodeId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for nodeId, expectedAPINodeId := range map[int64]int64{
+		keptNodeId:   apiNodeId,
+		closedNodeId: 0,
+		staleNodeId:  0,
+	} {
+		count, err := dao.Query(tx).Attr("nodeId", nodeId).Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expectedAPINodeId == 0 && count > 0 {
+			t.Fatal("stream of node", nodeId, "should be deleted")
+		}
+		if expectedAPINodeId > 0 && count != 1 {
+			t.Fatal("stream of node", nodeId, "should be kept")
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+// NodeStream 边缘节点和API节点之间的命令通道
+type NodeStream struct {
+	Id          uint64 `field:"id"`          // ID
+	NodeId      uint32 `field:"nodeId"`      // 边缘节点ID
+	APINodeId   uint32 `field:"apiNodeId"`   // API节点ID
+	ConnectedAt uint64 `field:"connectedAt"` // 连接时间
+	UpdatedAt   uint64 `field:"updatedAt"`   // 最后确认在线时间
+}
+
+type NodeStreamOperator struct {
+	Id          any // ID
+	NodeId      any // 边缘节点ID
+	APINodeId   any // API节点ID
+	ConnectedAt any // 连接时间
+	UpdatedAt   any // 最后确认在线时间
+}
+
+func NewNodeStreamOperator() *NodeStreamOperator {
+	return &NodeStreamOperator{}
+}
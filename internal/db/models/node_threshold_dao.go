@@ -104,6 +104,14 @@ func (this *NodeThresholdDAO) UpdateThreshold(tx *dbs.Tx, thresholdId int64, ite
 	return this.Save(tx, op)
 }
 
+// UpdateThresholdNotifiedAt 设置最后通知时间
+func (this *NodeThresholdDAO) UpdateThresholdNotifiedAt(tx *dbs.Tx, thresholdId int64, notifiedAt int64) error {
+	return this.Query(tx).
+		Pk(thresholdId).
+		Set("notifiedAt", notifiedAt).
+		UpdateQuickly()
+}
+
 // FindAllEnabledThresholds 列出所有阈值
 func (this *NodeThresholdDAO) FindAllEnabledThresholds(tx *dbs.Tx, role string, clusterId int64, nodeId int64) (result []*NodeThreshold, err error) {
 	if clusterId <= 0 && nodeId <= 0 {
@@ -3,10 +3,304 @@
 
 package models
 
-import "github.com/iwind/TeaGo/dbs"
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	"strings"
+	"sync"
+	"time"
+)
 
 // FireNodeThreshold 触发相关阈值设置
+// 只处理设置了统计时间段的阈值，没有设置时间段的阈值在节点上报数值时由 FireNodeThresholdWithValue 处理
 func (this *NodeThresholdDAO) FireNodeThreshold(tx *dbs.Tx, role string, nodeId int64, item string) error {
-	// stub
+	clusterId, thresholds, err := this.findNodeThresholds(tx, role, nodeId, item)
+	if err != nil {
+		return err
+	}
+
+	for _, threshold := range thresholds {
+		if threshold.Duration == 0 {
+			continue
+		}
+
+		value, err := SharedNodeValueDAO.SumNodeValues(tx, role, nodeId, item, threshold.Param, threshold.SumMethod, int32(threshold.Duration), threshold.DurationUnit)
+		if err != nil {
+			return err
+		}
+
+		err = this.checkThreshold(tx, threshold, clusterId, nodeId, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FireNodeThresholdWithValue 使用节点最新上报的数值触发没有设置统计时间段的阈值
+func (this *NodeThresholdDAO) FireNodeThresholdWithValue(tx *dbs.Tx, role string, nodeId int64, item string, valueJSON []byte) error {
+	if len(valueJSON) == 0 {
+		return nil
+	}
+
+	clusterId, thresholds, err := this.findNodeThresholds(tx, role, nodeId, item)
+	if err != nil {
+		return err
+	}
+	if len(thresholds) == 0 {
+		return nil
+	}
+
+	var valueMap = maps.Map{}
+	err = json.Unmarshal(valueJSON, &valueMap)
+	if err != nil {
+		// 不是有效的数据，直接跳过
+		return nil
+	}
+
+	for _, threshold := range thresholds {
+		if threshold.Duration > 0 || !valueMap.Has(threshold.Param) {
+			continue
+		}
+
+		err = this.checkThreshold(tx, threshold, clusterId, nodeId, valueMap.GetFloat64(threshold.Param))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 查找节点相关的阈值设置，包括节点专属的和集群的
+func (this *NodeThresholdDAO) findNodeThresholds(tx *dbs.Tx, role string, nodeId int64, item string) (clusterId int64, result []*NodeThreshold, err error) {
+	switch role {
+	case nodeconfigs.NodeRoleNode:
+		clusterId, err = SharedNodeDAO.FindNodeClusterId(tx, nodeId)
+	case nodeconfigs.NodeRoleDNS:
+		clusterId, err = SharedNSNodeDAO.FindNodeClusterId(tx, nodeId)
+	default:
+		return
+	}
+	if err != nil || clusterId <= 0 {
+		return
+	}
+
+	nodeThresholds, err := this.FindAllEnabledAndOnNodeThresholds(tx, role, clusterId, nodeId, item)
+	if err != nil {
+		return
+	}
+	clusterThresholds, err := this.FindAllEnabledAndOnClusterThresholds(tx, role, clusterId, item)
+	if err != nil {
+		return
+	}
+
+	result = append(nodeThresholds, clusterThresholds...)
+	return
+}
+
+// 节点在某个阈值上的状态
+type nodeThresholdState struct {
+	logId         int64 // 最近一次状态变更日志ID
+	isSatisfied   bool  // 是否满足阈值
+	notifiedAt    int64 // 最后通知时间
+	alarmNotified bool  // 是否已经发送了阈值通知但还没有发送恢复通知
+}
+
+// 缓存节点的阈值状态，避免每次上报数值时都要查询日志
+// 每个API节点单独缓存，状态变化时同时写入日志，重启后从日志中恢复
+var nodeThresholdStateMap = map[string]nodeThresholdState{} // thresholdId@nodeId => state
+var nodeThresholdStateLocker = &sync.Mutex{}
+
+// 查找节点在某个阈值上的状态
+func (this *NodeThresholdDAO) findNodeThresholdState(tx *dbs.Tx, thresholdId int64, nodeId int64) (nodeThresholdState, error) {
+	var key = types.String(thresholdId) + "@" + types.String(nodeId)
+	nodeThresholdStateLocker.Lock()
+	state, ok := nodeThresholdStateMap[key]
+	nodeThresholdStateLocker.Unlock()
+	if ok {
+		return state, nil
+	}
+
+	latestLog, err := SharedNodeThresholdLogDAO.FindLatestLog(tx, thresholdId, nodeId)
+	if err != nil {
+		return state, err
+	}
+	if latestLog != nil {
+		state.logId = int64(latestLog.Id)
+		state.isSatisfied = latestLog.IsSatisfied
+	}
+
+	notifiedLog, err := SharedNodeThresholdLogDAO.FindLastNotifiedLog(tx, thresholdId, nodeId)
+	if err != nil {
+		return state, err
+	}
+	if notifiedLog != nil {
+		state.notifiedAt = int64(notifiedLog.NotifiedAt)
+		state.alarmNotified = notifiedLog.IsSatisfied
+	}
+
+	this.updateNodeThresholdState(thresholdId, nodeId, state)
+	return state, nil
+}
+
+// 修改节点在某个阈值上的状态
+func (this *NodeThresholdDAO) updateNodeThresholdState(thresholdId int64, nodeId int64, state nodeThresholdState) {
+	var key = types.String(thresholdId) + "@" + types.String(nodeId)
+	nodeThresholdStateLocker.Lock()
+	nodeThresholdStateMap[key] = state
+	nodeThresholdStateLocker.Unlock()
+}
+
+// 检查阈值，并在状态变化时记录日志和发送消息
+// 阈值通知和恢复通知都遵守通知间隔，恢复通知只有在发送过阈值通知后才会发送；
+// 恢复时如果还在通知间隔内，则等到间隔过后节点再次上报数值时才发送
+func (this *NodeThresholdDAO) checkThreshold(tx *dbs.Tx, threshold *NodeThreshold, clusterId int64, nodeId int64, value float64) error {
+	var thresholdId = int64(threshold.Id)
+	var isSatisfied = threshold.Compare(value)
+
+	state, err := this.findNodeThresholdState(tx, thresholdId, nodeId)
+	if err != nil {
+		return err
+	}
+	var wasSatisfied = state.isSatisfied
+
+	// 记录状态变更
+	if isSatisfied != wasSatisfied {
+		state.logId, err = SharedNodeThresholdLogDAO.CreateLog(tx, threshold, nodeId, value, isSatisfied)
+		if err != nil {
+			return err
+		}
+		state.isSatisfied = isSatisfied
+		this.updateNodeThresholdState(thresholdId, nodeId, state)
+	}
+
+	// 检查通知间隔，每个节点单独计算
+	var now = time.Now().Unix()
+	var notifySeconds = int64(threshold.NotifyDuration) * 60
+	var inInterval = notifySeconds > 0 && state.notifiedAt+notifySeconds > now
+
+	if !isSatisfied {
+		// 已恢复
+		if !state.alarmNotified || inInterval {
+			return nil
+		}
+		err = this.updateNotifiedAt(tx, threshold, nodeId, &state, now, false)
+		if err != nil {
+			return err
+		}
+		return this.notify(tx, threshold, clusterId, nodeId, value, false)
+	}
+
+	if wasSatisfied {
+		// 持续满足阈值时，只有设置了通知间隔才会重复通知
+		if notifySeconds <= 0 || inInterval {
+			return nil
+		}
+	} else if inInterval {
+		return nil
+	}
+
+	err = this.updateNotifiedAt(tx, threshold, nodeId, &state, now, true)
+	if err != nil {
+		return err
+	}
+	return this.notify(tx, threshold, clusterId, nodeId, value, true)
+}
+
+// 记录通知时间
+func (this *NodeThresholdDAO) updateNotifiedAt(tx *dbs.Tx, threshold *NodeThreshold, nodeId int64, state *nodeThresholdState, now int64, isSatisfied bool) error {
+	var thresholdId = int64(threshold.Id)
+	err := SharedNodeThresholdLogDAO.UpdateLogNotifiedAt(tx, state.logId, now)
+	if err != nil {
+		return err
+	}
+	state.notifiedAt = now
+	state.alarmNotified = isSatisfied
+	this.updateNodeThresholdState(thresholdId, nodeId, *state)
+
+	if !isSatisfied {
+		return nil
+	}
+
+	// 阈值上的通知时间只用来显示
+	err = this.UpdateThresholdNotifiedAt(tx, thresholdId, now)
+	if err != nil {
+		return err
+	}
+	threshold.NotifiedAt = uint32(now)
 	return nil
 }
+
+// 发送阈值消息
+func (this *NodeThresholdDAO) notify(tx *dbs.Tx, threshold *NodeThreshold, clusterId int64, nodeId int64, value float64, isSatisfied bool) error {
+	var nodeName string
+	var err error
+	switch threshold.Role {
+	case nodeconfigs.NodeRoleNode:
+		nodeName, err = SharedNodeDAO.FindNodeName(tx, nodeId)
+	case nodeconfigs.NodeRoleDNS:
+		nodeName, err = SharedNSNodeDAO.FindEnabledNSNodeName(tx, nodeId)
+	}
+	if err != nil {
+		return err
+	}
+	if len(nodeName) == 0 {
+		nodeName = "#" + types.String(nodeId)
+	}
+
+	var valueString = types.String(threshold.DecodeValue())
+	var currentValueString = fmt.Sprintf("%.2f", value)
+
+	var itemDescription = threshold.ItemName()
+	if len(threshold.Param) > 0 {
+		itemDescription += "（" + threshold.Param + "）"
+	}
+	if threshold.Duration > 0 {
+		var sumMethodName = "平均值"
+		if threshold.SumMethod == nodeconfigs.NodeValueSumMethodSum {
+			sumMethodName = "总和"
+		}
+		itemDescription += "最近" + types.String(threshold.Duration) + "分钟" + sumMethodName
+	}
+
+	var subject string
+	var body string
+	var level string
+	if isSatisfied {
+		subject = "节点\"" + nodeName + "\"满足阈值设置"
+		body = "节点\"" + nodeName + "\"" + itemDescription + "为" + currentValueString + "，" + threshold.OperatorName() + "阈值" + valueString
+		if len(threshold.Message) > 0 {
+			body = strings.NewReplacer(
+				"${node}", nodeName,
+				"${item}", threshold.ItemName(),
+				"${param}", threshold.Param,
+				"${value}", currentValueString,
+				"${threshold}", valueString,
+			).Replace(threshold.Message)
+		}
+		level = MessageLevelWarning
+	} else {
+		subject = "节点\"" + nodeName + "\"已恢复正常"
+		body = "节点\"" + nodeName + "\"" + itemDescription + "为" + currentValueString + "，已不再" + threshold.OperatorName() + "阈值" + valueString
+		level = MessageLevelSuccess
+	}
+
+	paramsJSON, err := json.Marshal(maps.Map{
+		"thresholdId": threshold.Id,
+		"item":        threshold.Item,
+		"param":       threshold.Param,
+		"value":       value,
+		"isSatisfied": isSatisfied,
+	})
+	if err != nil {
+		return err
+	}
+
+	return SharedMessageDAO.CreateNodeMessage(tx, threshold.Role, clusterId, nodeId, MessageTypeThresholdSatisfied, level, subject, body, paramsJSON, true)
+}
@@ -3,4 +3,26 @@ package models
 import (
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/iwind/TeaGo/bootstrap"
+	"testing"
 )
+
+func TestNodeThreshold_Compare(t *testing.T) {
+	var threshold = &NodeThreshold{
+		Operator: "gte",
+		Value:    []byte("80"),
+	}
+	if !threshold.Compare(80) || !threshold.Compare(90.5) || threshold.Compare(79.9) {
+		t.Fatal("'gte' compare failed")
+	}
+
+	threshold.Operator = "lt"
+	threshold.Value = []byte(`"0.5"`)
+	if !threshold.Compare(0.1) || threshold.Compare(0.5) {
+		t.Fatal("'lt' compare failed")
+	}
+
+	threshold.Operator = "unknown"
+	if threshold.Compare(1) {
+		t.Fatal("unknown operator should always be false")
+	}
+}
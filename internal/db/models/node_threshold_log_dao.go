@@ -0,0 +1,147 @@
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/rands"
+	"github.com/iwind/TeaGo/types"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"time"
+)
+
+type NodeThresholdLogDAO dbs.DAO
+
+func NewNodeThresholdLogDAO() *NodeThresholdLogDAO {
+	return dbs.NewDAO(&NodeThresholdLogDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNodeThresholdLogs",
+			Model:  new(NodeThresholdLog),
+			PkName: "id",
+		},
+	}).(*NodeThresholdLogDAO)
+}
+
+var SharedNodeThresholdLogDAO *NodeThresholdLogDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNodeThresholdLogDAO = NewNodeThresholdLogDAO()
+	})
+}
+
+func init() {
+	dbs.OnReadyDone(func() {
+		// 清理数据任务
+		var ticker = time.NewTicker(time.Duration(rands.Int(24, 48)) * time.Hour)
+		goman.New(func() {
+			for range ticker.C {
+				err := SharedNodeThresholdLogDAO.CleanExpiredLogs(nil, 30) // 只保留30天
+				if err != nil {
+					remotelogs.Error("SharedNodeThresholdLogDAO", "clean expired data failed: "+err.Error())
+				}
+			}
+		})
+	})
+}
+
+// CreateLog 记录状态变更
+func (this *NodeThresholdLogDAO) CreateLog(tx *dbs.Tx, threshold *NodeThreshold, nodeId int64, value float64, isSatisfied bool) (int64, error) {
+	var op = NewNodeThresholdLogOperator()
+	op.ThresholdId = threshold.Id
+	op.Role = threshold.Role
+	op.ClusterId = threshold.ClusterId
+	op.NodeId = nodeId
+	op.Item = threshold.Item
+	op.Param = threshold.Param
+	op.Value = value
+	op.IsSatisfied = isSatisfied
+	op.CreatedAt = time.Now().Unix()
+	op.Day = timeutil.Format("Ymd")
+	err := this.Save(tx, op)
+	if err != nil {
+		return 0, err
+	}
+	return types.Int64(op.Id), nil
+}
+
+// FindLatestLog 查找某个节点最近一次状态变更
+func (this *NodeThresholdLogDAO) FindLatestLog(tx *dbs.Tx, thresholdId int64, nodeId int64) (*NodeThresholdLog, error) {
+	one, err := this.Query(tx).
+		Attr("thresholdId", thresholdId).
+		Attr("nodeId", nodeId).
+		DescPk().
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*NodeThresholdLog), nil
+}
+
+// FindLastNotifiedLog 查找某个节点最后一次发送过通知的日志
+func (this *NodeThresholdLogDAO) FindLastNotifiedLog(tx *dbs.Tx, thresholdId int64, nodeId int64) (*NodeThresholdLog, error) {
+	one, err := this.Query(tx).
+		Attr("thresholdId", thresholdId).
+		Attr("nodeId", nodeId).
+		Gt("notifiedAt", 0).
+		Result("id", "isSatisfied", "notifiedAt").
+		DescPk().
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*NodeThresholdLog), nil
+}
+
+// UpdateLogNotifiedAt 设置通知时间
+func (this *NodeThresholdLogDAO) UpdateLogNotifiedAt(tx *dbs.Tx, logId int64, notifiedAt int64) error {
+	return this.Query(tx).
+		Pk(logId).
+		Set("notifiedAt", notifiedAt).
+		UpdateQuickly()
+}
+
+// CountLogs 计算日志数量
+func (this *NodeThresholdLogDAO) CountLogs(tx *dbs.Tx, thresholdId int64, nodeId int64) (int64, error) {
+	var query = this.Query(tx)
+	if thresholdId > 0 {
+		query.Attr("thresholdId", thresholdId)
+	}
+	if nodeId > 0 {
+		query.Attr("nodeId", nodeId)
+	}
+	return query.Count()
+}
+
+// ListLogs 列出单页日志
+func (this *NodeThresholdLogDAO) ListLogs(tx *dbs.Tx, thresholdId int64, nodeId int64, offset int64, size int64) (result []*NodeThresholdLog, err error) {
+	var query = this.Query(tx)
+	if thresholdId > 0 {
+		query.Attr("thresholdId", thresholdId)
+	}
+	if nodeId > 0 {
+		query.Attr("nodeId", nodeId)
+	}
+	_, err = query.
+		Offset(offset).
+		Limit(size).
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// CleanExpiredLogs 清理
+func (this *NodeThresholdLogDAO) CleanExpiredLogs(tx *dbs.Tx, days int) error {
+	if days <= 0 {
+		days = 30
+	}
+	var day = timeutil.Format("Ymd", time.Now().AddDate(0, 0, -days))
+	_, err := this.Query(tx).
+		Lt("day", day).
+		Delete()
+	return err
+}
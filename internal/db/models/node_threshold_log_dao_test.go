@@ -0,0 +1,18 @@
+package models_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/iwind/TeaGo/bootstrap"
+	"github.com/iwind/TeaGo/dbs"
+	"testing"
+)
+
+func TestNodeThresholdLogDAO_CleanExpiredLogs(t *testing.T) {
+	var dao = models.NewNodeThresholdLogDAO()
+	var tx *dbs.Tx
+	err := dao.CleanExpiredLogs(tx, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
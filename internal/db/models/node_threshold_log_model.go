@@ -0,0 +1,36 @@
+package models
+
+// NodeThresholdLog 节点阈值状态变更日志
+type NodeThresholdLog struct {
+	Id          uint64  `field:"id"`          // ID
+	ThresholdId uint64  `field:"thresholdId"` // 阈值ID
+	Role        string  `field:"role"`        // 节点角色
+	ClusterId   uint32  `field:"clusterId"`   // 集群ID
+	NodeId      uint32  `field:"nodeId"`      // 节点ID
+	Item        string  `field:"item"`        // 监控项
+	Param       string  `field:"param"`       // 参数
+	Value       float64 `field:"value"`       // 当时的数值
+	IsSatisfied bool    `field:"isSatisfied"` // 是否满足阈值
+	NotifiedAt  uint64  `field:"notifiedAt"`  // 最后通知时间
+	CreatedAt   uint64  `field:"createdAt"`   // 创建时间
+	Day         string  `field:"day"`         // YYYYMMDD
+}
+
+type NodeThresholdLogOperator struct {
+	Id          interface{} // ID
+	ThresholdId interface{} // 阈值ID
+	Role        interface{} // 节点角色
+	ClusterId   interface{} // 集群ID
+	NodeId      interface{} // 节点ID
+	Item        interface{} // 监控项
+	Param       interface{} // 参数
+	Value       interface{} // 当时的数值
+	IsSatisfied interface{} // 是否满足阈值
+	NotifiedAt  interface{} // 最后通知时间
+	CreatedAt   interface{} // 创建时间
+	Day         interface{} // YYYYMMDD
+}
+
+func NewNodeThresholdLogOperator() *NodeThresholdLogOperator {
+	return &NodeThresholdLogOperator{}
+}
@@ -1 +1,82 @@
 package models
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	"github.com/iwind/TeaGo/types"
+)
+
+// DecodeValue 解析对比值
+func (this *NodeThreshold) DecodeValue() float64 {
+	if IsNull(this.Value) {
+		return 0
+	}
+	var value any
+	err := json.Unmarshal(this.Value, &value)
+	if err != nil {
+		return 0
+	}
+	return types.Float64(value)
+}
+
+// Compare 使用阈值中的操作符对比数值
+func (this *NodeThreshold) Compare(value float64) bool {
+	var thresholdValue = this.DecodeValue()
+	switch this.Operator {
+	case "gt":
+		return value > thresholdValue
+	case "gte":
+		return value >= thresholdValue
+	case "lt":
+		return value < thresholdValue
+	case "lte":
+		return value <= thresholdValue
+	case "eq":
+		return value == thresholdValue
+	case "neq":
+		return value != thresholdValue
+	}
+	return false
+}
+
+// OperatorName 操作符名称
+func (this *NodeThreshold) OperatorName() string {
+	switch this.Operator {
+	case "gt":
+		return "大于"
+	case "gte":
+		return "大于等于"
+	case "lt":
+		return "小于"
+	case "lte":
+		return "小于等于"
+	case "eq":
+		return "等于"
+	case "neq":
+		return "不等于"
+	}
+	return this.Operator
+}
+
+// ItemName 监控项名称
+func (this *NodeThreshold) ItemName() string {
+	switch this.Item {
+	case nodeconfigs.NodeValueItemCPU:
+		return "CPU"
+	case nodeconfigs.NodeValueItemMemory:
+		return "内存"
+	case nodeconfigs.NodeValueItemLoad:
+		return "负载"
+	case nodeconfigs.NodeValueItemTrafficIn:
+		return "入口流量"
+	case nodeconfigs.NodeValueItemTrafficOut:
+		return "出口流量"
+	case nodeconfigs.NodeValueItemConnections:
+		return "连接数"
+	case nodeconfigs.NodeValueItemRequests:
+		return "请求数"
+	case nodeconfigs.NodeValueItemAttackRequests:
+		return "攻击请求数"
+	}
+	return this.Item
+}
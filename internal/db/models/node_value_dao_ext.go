@@ -10,5 +10,6 @@ import (
 
 // 节点值变更Hook
 func (this *NodeValueDAO) nodeValueHook(tx *dbs.Tx, role nodeconfigs.NodeRole, nodeId int64, item nodeconfigs.NodeValueItem, valueJSON []byte) error {
-	return nil
+	// 检查没有设置统计时间段的阈值
+	return SharedNodeThresholdDAO.FireNodeThresholdWithValue(tx, role, nodeId, item, valueJSON)
 }
@@ -1869,6 +1869,19 @@ func (this *ServerDAO) FindAllBasicServersWithUserId(tx *dbs.Tx, userId int64) (
 	return
 }
 
+// ListEnabledServerNamesAfterId 从某个ID之后列出启用的服务域名，用于批量遍历
+func (this *ServerDAO) ListEnabledServerNamesAfterId(tx *dbs.Tx, lastId int64, size int64) (result []*Server, err error) {
+	_, err = this.Query(tx).
+		State(ServerStateEnabled).
+		Gt("id", lastId).
+		Result("id", "adminId", "userId", "plainServerNames").
+		AscPk().
+		Limit(size).
+		Slice(&result).
+		FindAll()
+	return
+}
+
 // FindAllAvailableServersWithUserId 查找用户的所有可用服务信息
 func (this *ServerDAO) FindAllAvailableServersWithUserId(tx *dbs.Tx, userId int64) (result []*Server, err error) {
 	_, err = this.Query(tx).
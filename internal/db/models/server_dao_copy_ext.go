@@ -4,32 +4,602 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/firewallconfigs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/shared"
 	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"strings"
 )
 
+// 可以直接复制的Web字段，这些字段中不包含对其他对象的引用
+// 缓存设置中引用了集群的缓存策略，需要单独处理，这里只用来判断是否可以复制
+var serverCopyWebFieldsMap = map[string][]string{
+	"cache":          {"cache"},
+	"cc":             {"cc"},
+	"referers":       {"referers"},
+	"userAgent":      {"userAgent"},
+	"charset":        {"charset"},
+	"accessLog":      {"accessLog"},
+	"stat":           {"stat"},
+	"compression":    {"compression"},
+	"optimization":   {"optimization"},
+	"webp":           {"webp"},
+	"root":           {"root"},
+	"remoteAddr":     {"remoteAddr"},
+	"requestLimit":   {"requestLimit"},
+	"requestScripts": {"requestScripts"},
+	"hostRedirects":  {"hostRedirects"},
+	"hls":            {"hls"},
+}
+
+// ServerCopyResult 复制配置到单个服务的结果
+type ServerCopyResult struct {
+	ServerId int64  // 目标服务ID
+	IsOk     bool   // 是否成功
+	Error    string // 失败原因
+}
+
 // CopyServerConfigToServers 拷贝服务配置到一组服务
 func (this *ServerDAO) CopyServerConfigToServers(tx *dbs.Tx, fromServerId int64, toServerIds []int64, configCode serverconfigs.ConfigCode, wafCopyRegions bool) error {
-	return errors.New("not implemented")
+	results, err := this.CopyServerConfigToServersWithResults(tx, fromServerId, toServerIds, configCode, wafCopyRegions)
+	if err != nil {
+		return err
+	}
+	return this.composeCopyError(results)
+}
+
+// CopyServerConfigToServersWithResults 拷贝服务配置到一组服务，并返回每个服务的复制结果
+// tx为空时每个服务在单独的事务中复制，单个服务复制失败不会留下复制了一半的数据
+func (this *ServerDAO) CopyServerConfigToServersWithResults(tx *dbs.Tx, fromServerId int64, toServerIds []int64, configCode serverconfigs.ConfigCode, wafCopyRegions bool) (results []*ServerCopyResult, err error) {
+	if fromServerId <= 0 {
+		return nil, errors.New("invalid 'fromServerId'")
+	}
+	if !this.isCopyableConfigCode(configCode) {
+		return nil, errors.New("unsupported config code '" + configCode + "'")
+	}
+
+	var fromWebId int64
+	if configCode != "uam" {
+		fromWebId, err = this.FindServerWebId(tx, fromServerId)
+		if err != nil {
+			return nil, err
+		}
+		if fromWebId <= 0 {
+			return nil, errors.New("the server '" + types.String(fromServerId) + "' does not have web settings")
+		}
+	}
+
+	var serverIdMap = map[int64]bool{}
+	for _, toServerId := range toServerIds {
+		if toServerId <= 0 || toServerId == fromServerId || serverIdMap[toServerId] {
+			continue
+		}
+		serverIdMap[toServerId] = true
+
+		var result = &ServerCopyResult{
+			ServerId: toServerId,
+		}
+		var copyErr error
+		if tx == nil {
+			// 每个目标服务使用单独的事务，失败时回滚已经复制的策略、规则分组等对象
+			copyErr = this.Instance.RunTx(func(tx *dbs.Tx) error {
+				return this.copyServerConfig(tx, fromServerId, fromWebId, toServerId, configCode, wafCopyRegions)
+			})
+		} else {
+			copyErr = this.copyServerConfig(tx, fromServerId, fromWebId, toServerId, configCode, wafCopyRegions)
+		}
+		if copyErr != nil {
+			result.Error = copyErr.Error()
+		} else {
+			result.IsOk = true
+		}
+		results = append(results, result)
+	}
+
+	return
 }
 
 // CopyServerConfigToGroups 拷贝服务配置到分组
-func (this *ServerDAO) CopyServerConfigToGroups(tx *dbs.Tx, fromServerId int64, groupIds []int64, configCode string, wafCopyRegions bool) error {
-	return errors.New("not implemented")
+func (this *ServerDAO) CopyServerConfigToGroups(tx *dbs.Tx, fromServerId int64, groupIds []int64, configCode string, wafCopyRegions bool) ([]*ServerCopyResult, error) {
+	var toServerIds = []int64{}
+	for _, groupId := range groupIds {
+		if groupId <= 0 {
+			continue
+		}
+		serverIds, err := this.FindAllEnabledServerIdsWithGroupId(tx, groupId)
+		if err != nil {
+			return nil, err
+		}
+		toServerIds = append(toServerIds, serverIds...)
+	}
+	return this.CopyServerConfigToServersWithResults(tx, fromServerId, toServerIds, configCode, wafCopyRegions)
 }
 
 // CopyServerConfigToCluster 拷贝服务配置到集群
-func (this *ServerDAO) CopyServerConfigToCluster(tx *dbs.Tx, fromServerId int64, clusterId int64, configCode string, wafCopyRegions bool) error {
-	return errors.New("not implemented")
+func (this *ServerDAO) CopyServerConfigToCluster(tx *dbs.Tx, fromServerId int64, clusterId int64, configCode string, wafCopyRegions bool) ([]*ServerCopyResult, error) {
+	if clusterId <= 0 {
+		return nil, nil
+	}
+	ones, err := this.Query(tx).
+		State(ServerStateEnabled).
+		Attr("clusterId", clusterId).
+		AscPk().
+		ResultPk().
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	var toServerIds = []int64{}
+	for _, one := range ones {
+		toServerIds = append(toServerIds, int64(one.(*Server).Id))
+	}
+	return this.CopyServerConfigToServersWithResults(tx, fromServerId, toServerIds, configCode, wafCopyRegions)
 }
 
 // CopyServerConfigToUser 拷贝服务配置到用户
-func (this *ServerDAO) CopyServerConfigToUser(tx *dbs.Tx, fromServerId int64, userId int64, configCode string, wafCopyRegions bool) error {
-	return errors.New("not implemented")
+// userId为0时表示复制到未分配用户的服务
+func (this *ServerDAO) CopyServerConfigToUser(tx *dbs.Tx, fromServerId int64, userId int64, configCode string, wafCopyRegions bool) ([]*ServerCopyResult, error) {
+	toServerIds, err := this.FindAllEnabledServerIdsWithUserId(tx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return this.CopyServerConfigToServersWithResults(tx, fromServerId, toServerIds, configCode, wafCopyRegions)
 }
 
 // CopyServerUAMConfigs 复制UAM设置
 func (this *ServerDAO) CopyServerUAMConfigs(tx *dbs.Tx, fromServerId int64, toServerIds []int64, wafCopyRegions bool) error {
-	return errors.New("not implemented")
+	return this.CopyServerConfigToServers(tx, fromServerId, toServerIds, "uam", wafCopyRegions)
+}
+
+// 判断某个配置是否可以复制
+func (this *ServerDAO) isCopyableConfigCode(configCode string) bool {
+	switch configCode {
+	case "uam", "waf", "headers", "pages", "websocket", "auth":
+		return true
+	}
+	_, ok := serverCopyWebFieldsMap[configCode]
+	return ok
+}
+
+// 将失败的结果组合成错误信息
+func (this *ServerDAO) composeCopyError(results []*ServerCopyResult) error {
+	var messages = []string{}
+	for _, result := range results {
+		if !result.IsOk {
+			messages = append(messages, "server '"+types.String(result.ServerId)+"': "+result.Error)
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return errors.New("copy to " + types.String(len(messages)) + "/" + types.String(len(results)) + " servers failed: " + strings.Join(messages, "; "))
+}
+
+// 复制配置到单个服务
+func (this *ServerDAO) copyServerConfig(tx *dbs.Tx, fromServerId int64, fromWebId int64, toServerId int64, configCode string, wafCopyRegions bool) error {
+	exists, err := this.ExistsServer(tx, toServerId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("server not found")
+	}
+
+	// UAM保存在服务中
+	if configCode == "uam" {
+		uamJSON, err := this.FindServerUAM(tx, fromServerId)
+		if err != nil {
+			return err
+		}
+		var uamValue any = dbs.SQL("NULL")
+		if IsNotNull(uamJSON) {
+			uamValue = uamJSON
+		}
+		err = this.Query(tx).
+			Pk(toServerId).
+			Set("uam", uamValue).
+			UpdateQuickly()
+		if err != nil {
+			return err
+		}
+		return this.NotifyUpdate(tx, toServerId)
+	}
+
+	toWebId, err := this.FindServerWebId(tx, toServerId)
+	if err != nil {
+		return err
+	}
+	if toWebId <= 0 {
+		toWebId, err = this.InitServerWeb(tx, toServerId)
+		if err != nil {
+			return err
+		}
+	}
+	toUserId, err := this.FindServerUserId(tx, toServerId)
+	if err != nil {
+		return err
+	}
+
+	switch configCode {
+	case "waf":
+		err = this.copyWebFirewall(tx, fromServerId, fromWebId, toServerId, toWebId, toUserId, wafCopyRegions)
+	case "cache":
+		err = this.copyWebCache(tx, fromWebId, toServerId, toWebId)
+	case "headers":
+		err = this.copyWebHeaders(tx, fromWebId, toWebId, toUserId)
+	case "pages":
+		err = this.copyWebPages(tx, fromWebId, toWebId)
+	case "websocket":
+		err = this.copyWebWebsocket(tx, fromWebId, toWebId)
+	case "auth":
+		err = this.copyWebAuth(tx, fromWebId, toWebId)
+	default:
+		err = this.copyWebFields(tx, fromWebId, toWebId, serverCopyWebFieldsMap[configCode]...)
+	}
+	if err != nil {
+		return err
+	}
+
+	return this.NotifyUpdate(tx, toServerId)
+}
+
+// 直接复制Web中的字段
+func (this *ServerDAO) copyWebFields(tx *dbs.Tx, fromWebId int64, toWebId int64, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	var resultFields = []any{}
+	for _, field := range fields {
+		resultFields = append(resultFields, field)
+	}
+	one, _, err := SharedHTTPWebDAO.Query(tx).
+		Pk(fromWebId).
+		Result(resultFields...).
+		FindOne()
+	if err != nil {
+		return err
+	}
+	if one == nil {
+		return errors.New("could not find web '" + types.String(fromWebId) + "'")
+	}
+
+	var query = SharedHTTPWebDAO.Query(tx).
+		Pk(toWebId)
+	for _, field := range fields {
+		var value = one[field]
+		if value == nil {
+			query.Set(field, dbs.SQL("NULL"))
+		} else {
+			query.Set(field, value)
+		}
+	}
+	return query.UpdateQuickly()
+}
+
+// 查找Web中的JSON字段
+func (this *ServerDAO) findWebJSONField(tx *dbs.Tx, webId int64, field string) ([]byte, error) {
+	return SharedHTTPWebDAO.Query(tx).
+		Pk(webId).
+		Result(field).
+		FindJSONCol()
+}
+
+// 复制缓存设置
+// 缓存条件中引用的缓存策略属于源服务所在的集群，需要换成目标服务所在集群的缓存策略
+func (this *ServerDAO) copyWebCache(tx *dbs.Tx, fromWebId int64, toServerId int64, toWebId int64) error {
+	cacheJSON, err := this.findWebJSONField(tx, fromWebId, "cache")
+	if err != nil {
+		return err
+	}
+	if !IsNotNull(cacheJSON) {
+		return this.copyWebFields(tx, fromWebId, toWebId, "cache")
+	}
+
+	// 使用Map解析，以保留所有的字段
+	var cacheMap = map[string]any{}
+	err = json.Unmarshal(cacheJSON, &cacheMap)
+	if err != nil {
+		return err
+	}
+	cacheRefs, ok := cacheMap["cacheRefs"].([]any)
+	if ok {
+		var toCachePolicyId int64 = -1
+		for _, cacheRef := range cacheRefs {
+			cacheRefMap, ok := cacheRef.(map[string]any)
+			if !ok {
+				continue
+			}
+			delete(cacheRefMap, "cachePolicy")
+			if types.Int64(cacheRefMap["cachePolicyId"]) <= 0 {
+				continue
+			}
+			if toCachePolicyId < 0 {
+				toClusterId, err := this.FindServerClusterId(tx, toServerId)
+				if err != nil {
+					return err
+				}
+				toCachePolicyId, err = SharedNodeClusterDAO.FindClusterHTTPCachePolicyId(tx, toClusterId, nil)
+				if err != nil {
+					return err
+				}
+			}
+			cacheRefMap["cachePolicyId"] = toCachePolicyId
+		}
+	}
+
+	cacheJSON, err = json.Marshal(cacheMap)
+	if err != nil {
+		return err
+	}
+	return SharedHTTPWebDAO.Query(tx).
+		Pk(toWebId).
+		Set("cache", cacheJSON).
+		UpdateQuickly()
+}
+
+// 复制WAF设置
+// 如果源服务使用的是自身的WAF策略，则为目标服务复制一份新的策略；否则直接引用同一个公用策略；
+// 目标服务原有的自身策略不再使用时，会被禁用
+func (this *ServerDAO) copyWebFirewall(tx *dbs.Tx, fromServerId int64, fromWebId int64, toServerId int64, toWebId int64, toUserId int64, copyRegions bool) error {
+	// 目标服务自身的策略
+	var toPolicyId int64
+	oldFirewallJSON, err := this.findWebJSONField(tx, toWebId, "firewall")
+	if err != nil {
+		return err
+	}
+	if IsNotNull(oldFirewallJSON) {
+		var oldFirewallRef = &firewallconfigs.HTTPFirewallRef{}
+		err = json.Unmarshal(oldFirewallJSON, oldFirewallRef)
+		if err != nil {
+			return err
+		}
+		if oldFirewallRef.FirewallPolicyId > 0 {
+			oldPolicyServerId, err := SharedHTTPFirewallPolicyDAO.FindServerIdWithFirewallPolicyId(tx, oldFirewallRef.FirewallPolicyId)
+			if err != nil {
+				return err
+			}
+			if oldPolicyServerId == toServerId {
+				toPolicyId = oldFirewallRef.FirewallPolicyId
+			}
+		}
+	}
+
+	firewallJSON, err := this.findWebJSONField(tx, fromWebId, "firewall")
+	if err != nil {
+		return err
+	}
+	if !IsNotNull(firewallJSON) {
+		err = this.copyWebFields(tx, fromWebId, toWebId, "firewall")
+		if err != nil {
+			return err
+		}
+		return this.disableOldFirewallPolicy(tx, toPolicyId, 0)
+	}
+
+	var firewallRef = &firewallconfigs.HTTPFirewallRef{}
+	err = json.Unmarshal(firewallJSON, firewallRef)
+	if err != nil {
+		return err
+	}
+
+	if firewallRef.FirewallPolicyId > 0 {
+		policyServerId, err := SharedHTTPFirewallPolicyDAO.FindServerIdWithFirewallPolicyId(tx, firewallRef.FirewallPolicyId)
+		if err != nil {
+			return err
+		}
+		if policyServerId == fromServerId {
+			// 目标服务已经有自身的策略时，覆盖原有策略，以保留其中的IP名单
+			newPolicyId, err := SharedHTTPFirewallPolicyDAO.CopyFirewallPolicy(tx, firewallRef.FirewallPolicyId, toPolicyId, toUserId, toServerId, copyRegions)
+			if err != nil {
+				return err
+			}
+			firewallRef.FirewallPolicyId = newPolicyId
+		}
+	}
+
+	firewallJSON, err = json.Marshal(firewallRef)
+	if err != nil {
+		return err
+	}
+	err = SharedHTTPWebDAO.Query(tx).
+		Pk(toWebId).
+		Set("firewall", firewallJSON).
+		UpdateQuickly()
+	if err != nil {
+		return err
+	}
+	return this.disableOldFirewallPolicy(tx, toPolicyId, firewallRef.FirewallPolicyId)
+}
+
+// 禁用目标服务不再使用的自身WAF策略
+func (this *ServerDAO) disableOldFirewallPolicy(tx *dbs.Tx, oldPolicyId int64, newPolicyId int64) error {
+	if oldPolicyId <= 0 || oldPolicyId == newPolicyId {
+		return nil
+	}
+	return SharedHTTPFirewallPolicyDAO.DisableFirewallPolicyAndRuleGroups(tx, oldPolicyId)
+}
+
+// 复制请求Header和响应Header
+// 目标服务原有的Header策略会被禁用
+func (this *ServerDAO) copyWebHeaders(tx *dbs.Tx, fromWebId int64, toWebId int64, toUserId int64) error {
+	for _, field := range []string{"requestHeader", "responseHeader"} {
+		oldRefJSON, err := this.findWebJSONField(tx, toWebId, field)
+		if err != nil {
+			return err
+		}
+		var oldRef = &shared.HTTPHeaderPolicyRef{}
+		if IsNotNull(oldRefJSON) {
+			err = json.Unmarshal(oldRefJSON, oldRef)
+			if err != nil {
+				return err
+			}
+		}
+
+		refJSON, err := this.findWebJSONField(tx, fromWebId, field)
+		if err != nil {
+			return err
+		}
+		if !IsNotNull(refJSON) {
+			err = this.copyWebFields(tx, fromWebId, toWebId, field)
+			if err != nil {
+				return err
+			}
+			err = this.disableOldHeaderPolicy(tx, oldRef.HeaderPolicyId)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var ref = &shared.HTTPHeaderPolicyRef{}
+		err = json.Unmarshal(refJSON, ref)
+		if err != nil {
+			return err
+		}
+		if ref.HeaderPolicyId > 0 {
+			ref.HeaderPolicyId, err = SharedHTTPHeaderPolicyDAO.CloneHeaderPolicy(tx, ref.HeaderPolicyId, toUserId)
+			if err != nil {
+				return err
+			}
+		}
+		refJSON, err = json.Marshal(ref)
+		if err != nil {
+			return err
+		}
+		err = SharedHTTPWebDAO.Query(tx).
+			Pk(toWebId).
+			Set(field, refJSON).
+			UpdateQuickly()
+		if err != nil {
+			return err
+		}
+		err = this.disableOldHeaderPolicy(tx, oldRef.HeaderPolicyId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 禁用目标服务不再使用的Header策略
+// Header策略只属于单个Web，不会被其他对象引用
+func (this *ServerDAO) disableOldHeaderPolicy(tx *dbs.Tx, oldPolicyId int64) error {
+	if oldPolicyId <= 0 {
+		return nil
+	}
+	return SharedHTTPHeaderPolicyDAO.DisableHTTPHeaderPolicy(tx, oldPolicyId)
+}
+
+// 复制自定义页面
+func (this *ServerDAO) copyWebPages(tx *dbs.Tx, fromWebId int64, toWebId int64) error {
+	err := this.copyWebFields(tx, fromWebId, toWebId, "enableGlobalPages", "shutdown")
+	if err != nil {
+		return err
+	}
+
+	pagesJSON, err := this.findWebJSONField(tx, fromWebId, "pages")
+	if err != nil {
+		return err
+	}
+	var newPages = []*serverconfigs.HTTPPageConfig{}
+	if IsNotNull(pagesJSON) {
+		var pages = []*serverconfigs.HTTPPageConfig{}
+		err = json.Unmarshal(pagesJSON, &pages)
+		if err != nil {
+			return err
+		}
+		for _, page := range pages {
+			newPageId, err := SharedHTTPPageDAO.ClonePage(tx, page.Id)
+			if err != nil {
+				return err
+			}
+			if newPageId <= 0 {
+				continue
+			}
+			page.Id = newPageId
+			newPages = append(newPages, page)
+		}
+	}
+	newPagesJSON, err := json.Marshal(newPages)
+	if err != nil {
+		return err
+	}
+	return SharedHTTPWebDAO.Query(tx).
+		Pk(toWebId).
+		Set("pages", newPagesJSON).
+		UpdateQuickly()
+}
+
+// 复制Websocket设置
+func (this *ServerDAO) copyWebWebsocket(tx *dbs.Tx, fromWebId int64, toWebId int64) error {
+	refJSON, err := this.findWebJSONField(tx, fromWebId, "websocket")
+	if err != nil {
+		return err
+	}
+	if !IsNotNull(refJSON) {
+		return this.copyWebFields(tx, fromWebId, toWebId, "websocket")
+	}
+
+	var ref = &serverconfigs.HTTPWebsocketRef{}
+	err = json.Unmarshal(refJSON, ref)
+	if err != nil {
+		return err
+	}
+	if ref.WebsocketId > 0 {
+		ref.WebsocketId, err = SharedHTTPWebsocketDAO.CloneWebsocket(tx, ref.WebsocketId)
+		if err != nil {
+			return err
+		}
+	}
+	refJSON, err = json.Marshal(ref)
+	if err != nil {
+		return err
+	}
+	return SharedHTTPWebDAO.Query(tx).
+		Pk(toWebId).
+		Set("websocket", refJSON).
+		UpdateQuickly()
+}
+
+// 复制认证设置
+func (this *ServerDAO) copyWebAuth(tx *dbs.Tx, fromWebId int64, toWebId int64) error {
+	authJSON, err := this.findWebJSONField(tx, fromWebId, "auth")
+	if err != nil {
+		return err
+	}
+	if !IsNotNull(authJSON) {
+		return this.copyWebFields(tx, fromWebId, toWebId, "auth")
+	}
+
+	var authConfig = &serverconfigs.HTTPAuthConfig{}
+	err = json.Unmarshal(authJSON, authConfig)
+	if err != nil {
+		return err
+	}
+	var newRefs = []*serverconfigs.HTTPAuthPolicyRef{}
+	for _, ref := range authConfig.PolicyRefs {
+		newPolicyId, err := SharedHTTPAuthPolicyDAO.CloneAuthPolicy(tx, ref.AuthPolicyId)
+		if err != nil {
+			return err
+		}
+		if newPolicyId <= 0 {
+			continue
+		}
+		ref.AuthPolicyId = newPolicyId
+		ref.AuthPolicy = nil
+		newRefs = append(newRefs, ref)
+	}
+	authConfig.PolicyRefs = newRefs
+
+	authJSON, err = json.Marshal(authConfig)
+	if err != nil {
+		return err
+	}
+	return SharedHTTPWebDAO.Query(tx).
+		Pk(toWebId).
+		Set("auth", authJSON).
+		UpdateQuickly()
 }
@@ -3,6 +3,7 @@ package models_test
 import (
 	"crypto/md5"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
@@ -317,6 +318,125 @@ func TestServerDAO_FindEnabledServersWithIds(t *testing.T) {
 	t.Log(len(servers), "servers")
 }
 
+func TestServerDAO_CopyServerConfigToServersWithResults(t *testing.T) {
+	dbs.NotifyReady()
+
+	var dao = models.SharedServerDAO
+	var errRollback = errors.New("rollback")
+
+	// 在事务中执行，结束后回滚，不留下测试数据
+	err := dao.Instance.RunTx(func(tx *dbs.Tx) error {
+		var createServer = func(name string) (serverId int64, webId int64) {
+			serverNamesJSON, err := json.Marshal([]*serverconfigs.ServerNameConfig{{Name: name}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			serverId, err = dao.CreateServer(tx, 0, 0, serverconfigs.ServerTypeHTTPProxy, name, "", serverNamesJSON, false, nil, nil, nil, nil, nil, nil, nil, 0, nil, 1, nil, nil, nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			webId, err = dao.InitServerWeb(tx, serverId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+		var createHeaderPolicy = func(webId int64) int64 {
+			policyId, err := models.SharedHTTPHeaderPolicyDAO.CreateHeaderPolicy(tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			refJSON, err := json.Marshal(&shared.HTTPHeaderPolicyRef{IsOn: true, HeaderPolicyId: policyId})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = models.SharedHTTPWebDAO.UpdateWebRequestHeaderPolicy(tx, webId, refJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return policyId
+		}
+
+		fromServerId, fromWebId := createServer("copy-from.example.com")
+		toServerId, toWebId := createServer("copy-to.example.com")
+		var fromHeaderPolicyId = createHeaderPolicy(fromWebId)
+		var oldHeaderPolicyId = createHeaderPolicy(toWebId)
+
+		// 复制Header
+		results, err := dao.CopyServerConfigToServersWithResults(tx, fromServerId, []int64{toServerId, fromServerId, 1_000_000_000}, "headers", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 {
+			t.Fatal("expect 2 results, but got", len(results))
+		}
+		if results[0].ServerId != toServerId || !results[0].IsOk {
+			t.Fatalf("copy to server '%d' should be ok: %+v", toServerId, results[0])
+		}
+		if results[1].IsOk || len(results[1].Error) == 0 {
+			t.Fatalf("copy to not existing server should fail: %+v", results[1])
+		}
+
+		refJSON, err := models.SharedHTTPWebDAO.Query(tx).Pk(toWebId).Result("requestHeader").FindJSONCol()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ref = &shared.HTTPHeaderPolicyRef{}
+		err = json.Unmarshal(refJSON, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ref.HeaderPolicyId <= 0 || ref.HeaderPolicyId == fromHeaderPolicyId || ref.HeaderPolicyId == oldHeaderPolicyId {
+			t.Fatal("should copy a new header policy, but got", ref.HeaderPolicyId)
+		}
+		oldPolicy, err := models.SharedHTTPHeaderPolicyDAO.FindEnabledHTTPHeaderPolicy(tx, oldHeaderPolicyId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if oldPolicy != nil {
+			t.Fatal("old header policy should be disabled")
+		}
+
+		// 复制缓存，缓存策略需要换成目标服务所在集群的策略
+		err = models.SharedHTTPWebDAO.UpdateWebCache(tx, fromWebId, []byte(`{"isOn":true,"cacheRefs":[{"isOn":true,"cachePolicyId":1000000000,"key":"${host}${requestURI}"}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = dao.CopyServerConfigToServers(tx, fromServerId, []int64{toServerId}, "cache", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cacheJSON, err := models.SharedHTTPWebDAO.Query(tx).Pk(toWebId).Result("cache").FindJSONCol()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cacheConfig = maps.Map{}
+		err = json.Unmarshal(cacheJSON, &cacheConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cacheRefs = cacheConfig.GetSlice("cacheRefs")
+		if len(cacheRefs) != 1 {
+			t.Fatal("expect 1 cache ref, but got", len(cacheRefs))
+		}
+		var cacheRef = maps.NewMap(cacheRefs[0])
+		if cacheRef.GetInt64("cachePolicyId") == 1000000000 || cacheRef.GetString("key") != "${host}${requestURI}" {
+			t.Fatal("unexpected cache ref:", cacheRef)
+		}
+
+		// 不支持的配置
+		_, err = dao.CopyServerConfigToServersWithResults(tx, fromServerId, []int64{toServerId}, "unknown", false)
+		if err == nil {
+			t.Fatal("should be failed")
+		}
+
+		return errRollback
+	})
+	if err != nil && err != errRollback {
+		t.Fatal(err)
+	}
+}
+
 func BenchmarkServerDAO_CountAllEnabledServers(b *testing.B) {
 	models.SharedServerDAO = models.NewServerDAO()
 
@@ -124,7 +124,13 @@ func (this *SSLCertDAO) CreateCert(tx *dbs.Tx, adminId int64, userId int64, isOn
 	if err != nil {
 		return 0, err
 	}
-	return types.Int64(op.Id), nil
+	var certId = types.Int64(op.Id)
+
+	err = SharedSSLCertSerialDAO.CreateSerialWithCertData(tx, certId, certData)
+	if err != nil {
+		return 0, err
+	}
+	return certId, nil
 }
 
 // UpdateCert 修改证书
@@ -199,6 +205,13 @@ func (this *SSLCertDAO) UpdateCert(tx *dbs.Tx,
 	if err != nil {
 		return err
 	}
+
+	if len(certData) > 0 {
+		err = SharedSSLCertSerialDAO.CreateSerialWithCertData(tx, certId, certData)
+		if err != nil {
+			return err
+		}
+	}
 	return this.NotifyUpdate(tx, certId)
 }
 
@@ -414,6 +427,63 @@ func (this *SSLCertDAO) FindAllExpiringCerts(tx *dbs.Tx, days int) (result []*SS
 	return
 }
 
+// FindAllExpiringCertsWithinDays 查找在指定天数内到期的证书
+// 这里我们只返回有限的字段以节省内存
+func (this *SSLCertDAO) FindAllExpiringCertsWithinDays(tx *dbs.Tx, days int) (result []*SSLCert, err error) {
+	if days < 0 {
+		days = 0
+	}
+
+	var now = time.Now().Unix()
+	_, err = this.Query(tx).
+		State(SSLCertStateEnabled).
+		Attr("isOn", true).
+		Gt("timeEndAt", now).
+		Lte("timeEndAt", now+int64(days+1)*86400).
+		Result("id", "adminId", "userId", "timeEndAt", "name", "dnsNames", "notifiedAt", "acmeTaskId").
+		Slice(&result).
+		AscPk().
+		FindAll()
+	return
+}
+
+// FindAllUnexpiredACMECerts 查找所有由ACME任务生成且尚未过期的证书
+// 不包含证书内容，需要时使用 FindCertData() 单独查询
+func (this *SSLCertDAO) FindAllUnexpiredACMECerts(tx *dbs.Tx) (result []*SSLCert, err error) {
+	_, err = this.Query(tx).
+		State(SSLCertStateEnabled).
+		Attr("isOn", true).
+		Gt("acmeTaskId", 0).
+		Gt("timeEndAt", time.Now().Unix()).
+		Result("id", "adminId", "userId", "timeEndAt", "name", "dnsNames", "acmeTaskId").
+		Slice(&result).
+		AscPk().
+		FindAll()
+	return
+}
+
+// FindCertData 查找证书内容
+func (this *SSLCertDAO) FindCertData(tx *dbs.Tx, certId int64) ([]byte, error) {
+	return this.Query(tx).
+		Pk(certId).
+		Result("certData").
+		FindBytesCol()
+}
+
+// ListEnabledCertsAfterId 从某个ID之后列出启用的非CA证书，用于批量遍历
+func (this *SSLCertDAO) ListEnabledCertsAfterId(tx *dbs.Tx, lastId int64, size int64) (result []*SSLCert, err error) {
+	_, err = this.Query(tx).
+		State(SSLCertStateEnabled).
+		Attr("isCA", false).
+		Gt("id", lastId).
+		Result("id", "adminId", "userId", "dnsNames", "certData").
+		AscPk().
+		Limit(size).
+		Slice(&result).
+		FindAll()
+	return
+}
+
 // UpdateCertNotifiedAt 设置当前证书事件通知时间
 func (this *SSLCertDAO) UpdateCertNotifiedAt(tx *dbs.Tx, certId int64) error {
 	_, err := this.Query(tx).
@@ -0,0 +1,104 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	SSLCertExpireConfigSettingCode      = "sslCertExpireConfig"          // 全局配置
+	SSLCertExpireConfigAdminSettingCode = "sslCertExpireConfig:admin:%d" // 管理员配置
+	SSLCertExpireConfigUserSettingCode  = "sslCertExpireConfig:user:%d"  // 用户配置
+
+	SSLCertExpireMaxNotifyDays = 90 // 最多可以提前通知的天数
+	SSLCertExpireMaxRenewDays  = 60 // 最多可以提前续期的天数
+)
+
+// SSLCertExpireConfig 证书到期通知和自动续期配置
+type SSLCertExpireConfig struct {
+	NotifyDays          []int `json:"notifyDays"`          // 到期前第几天发送通知
+	RenewBeforeDays     int   `json:"renewBeforeDays"`     // 到期前多少天开始自动续期
+	RetryMinIntervalMin int   `json:"retryMinIntervalMin"` // 续期失败后第一次重试的间隔（分钟）
+	RetryMaxIntervalMin int   `json:"retryMaxIntervalMin"` // 续期失败后重试的最大间隔（分钟），每次失败后间隔加倍直到此值
+}
+
+// DefaultSSLCertExpireConfig 默认配置
+func DefaultSSLCertExpireConfig() *SSLCertExpireConfig {
+	return &SSLCertExpireConfig{
+		NotifyDays:          []int{30, 14, 7, 3, 2, 1},
+		RenewBeforeDays:     3,
+		RetryMinIntervalMin: 60,
+		RetryMaxIntervalMin: 24 * 60,
+	}
+}
+
+// Init 校验并整理配置
+func (this *SSLCertExpireConfig) Init() error {
+	var days = []int{}
+	var dayMap = map[int]bool{}
+	for _, day := range this.NotifyDays {
+		if day <= 0 || day > SSLCertExpireMaxNotifyDays {
+			return errors.New("notify days should be between 1 and " + strconv.Itoa(SSLCertExpireMaxNotifyDays))
+		}
+		if dayMap[day] {
+			continue
+		}
+		dayMap[day] = true
+		days = append(days, day)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(days)))
+	this.NotifyDays = days
+
+	if this.RenewBeforeDays <= 0 || this.RenewBeforeDays > SSLCertExpireMaxRenewDays {
+		return errors.New("renew before days should be between 1 and " + strconv.Itoa(SSLCertExpireMaxRenewDays))
+	}
+	if this.RetryMinIntervalMin <= 0 {
+		return errors.New("retry min interval should be greater than 0")
+	}
+	if this.RetryMaxIntervalMin < this.RetryMinIntervalMin {
+		return errors.New("retry max interval should not be less than retry min interval")
+	}
+	return nil
+}
+
+// ShouldNotify 判断剩余天数是否需要发送通知
+func (this *SSLCertExpireConfig) ShouldNotify(days int) bool {
+	for _, day := range this.NotifyDays {
+		if day == days {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxNotifyDays 最早通知的天数
+func (this *SSLCertExpireConfig) MaxNotifyDays() int {
+	var maxDays = 0
+	for _, day := range this.NotifyDays {
+		if day > maxDays {
+			maxDays = day
+		}
+	}
+	return maxDays
+}
+
+// RetryInterval 第N次（从1开始）续期失败后的重试间隔
+func (this *SSLCertExpireConfig) RetryInterval(failures int) time.Duration {
+	var minInterval = time.Duration(this.RetryMinIntervalMin) * time.Minute
+	var maxInterval = time.Duration(this.RetryMaxIntervalMin) * time.Minute
+	var interval = minInterval
+	for i := 1; i < failures; i++ {
+		interval *= 2
+		if interval >= maxInterval {
+			return maxInterval
+		}
+	}
+	if interval > maxInterval {
+		return maxInterval
+	}
+	return interval
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package models_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"testing"
+	"time"
+)
+
+func TestSSLCertExpireConfig_Init(t *testing.T) {
+	var config = models.DefaultSSLCertExpireConfig()
+	config.NotifyDays = []int{7, 30, 7, 1}
+	err := config.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.NotifyDays) != 3 || config.NotifyDays[0] != 30 || config.MaxNotifyDays() != 30 {
+		t.Fatal("unexpected notify days:", config.NotifyDays)
+	}
+	if !config.ShouldNotify(7) || config.ShouldNotify(8) {
+		t.Fatal("unexpected ShouldNotify() result")
+	}
+
+	config.NotifyDays = []int{0}
+	if config.Init() == nil {
+		t.Fatal("should fail with invalid notify days")
+	}
+}
+
+func TestSSLCertExpireConfig_RetryInterval(t *testing.T) {
+	var config = &models.SSLCertExpireConfig{
+		RetryMinIntervalMin: 30,
+		RetryMaxIntervalMin: 180,
+	}
+	for failures, expected := range map[int]time.Duration{
+		1: 30 * time.Minute,
+		2: 60 * time.Minute,
+		3: 120 * time.Minute,
+		4: 180 * time.Minute,
+		9: 180 * time.Minute,
+	} {
+		var interval = config.RetryInterval(failures)
+		if interval != expected {
+			t.Fatal("failures:", failures, "expected:", expected, "got:", interval)
+		}
+	}
+}
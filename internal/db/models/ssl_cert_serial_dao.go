@@ -0,0 +1,65 @@
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/ctlogs"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+type SSLCertSerialDAO dbs.DAO
+
+func NewSSLCertSerialDAO() *SSLCertSerialDAO {
+	return dbs.NewDAO(&SSLCertSerialDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeSSLCertSerials",
+			Model:  new(SSLCertSerial),
+			PkName: "id",
+		},
+	}).(*SSLCertSerialDAO)
+}
+
+var SharedSSLCertSerialDAO *SSLCertSerialDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedSSLCertSerialDAO = NewSSLCertSerialDAO()
+	})
+}
+
+// CreateSerialWithCertData 记录证书的序列号
+// 证书续期或重新上传后会覆盖原来的证书内容，所以需要单独保存以前的序列号，用于证书透明度日志监控
+func (this *SSLCertSerialDAO) CreateSerialWithCertData(tx *dbs.Tx, certId int64, certData []byte) error {
+	var serialNumber = ctlogs.ParseSerialNumber(certData)
+	if len(serialNumber) == 0 {
+		return nil
+	}
+
+	exists, err := this.Query(tx).
+		Attr("certId", certId).
+		Attr("serialNumber", serialNumber).
+		Exist()
+	if err != nil || exists {
+		return err
+	}
+
+	var op = NewSSLCertSerialOperator()
+	op.CertId = certId
+	op.SerialNumber = serialNumber
+	op.CreatedAt = time.Now().Unix()
+	return this.Save(tx, op)
+}
+
+// ListSerialsAfterId 列出某个ID之后的序列号
+func (this *SSLCertSerialDAO) ListSerialsAfterId(tx *dbs.Tx, lastId int64, size int64) (result []*SSLCertSerial, err error) {
+	_, err = this.Query(tx).
+		Gt("id", lastId).
+		Result("id", "serialNumber").
+		AscPk().
+		Limit(size).
+		Slice(&result).
+		FindAll()
+	return
+}
@@ -0,0 +1,20 @@
+package models
+
+// SSLCertSerial 本系统签发或上传过的证书序列号
+type SSLCertSerial struct {
+	Id           uint64 `field:"id"`           // ID
+	CertId       uint32 `field:"certId"`       // 证书ID
+	SerialNumber string `field:"serialNumber"` // 序列号
+	CreatedAt    uint64 `field:"createdAt"`    // 创建时间
+}
+
+type SSLCertSerialOperator struct {
+	Id           any // ID
+	CertId       any // 证书ID
+	SerialNumber any // 序列号
+	CreatedAt    any // 创建时间
+}
+
+func NewSSLCertSerialOperator() *SSLCertSerialOperator {
+	return &SSLCertSerialOperator{}
+}
@@ -200,6 +200,67 @@ func (this *SSLPolicyDAO) FindAllEnabledPolicyIdsWithCertId(tx *dbs.Tx, certId i
 	return policyIds, nil
 }
 
+// AddCertToPoliciesWithCertId 将证书加入到所有使用某个证书的策略中
+func (this *SSLPolicyDAO) AddCertToPoliciesWithCertId(tx *dbs.Tx, certId int64, newCertId int64) error {
+	if certId <= 0 || newCertId <= 0 || certId == newCertId {
+		return nil
+	}
+
+	policyIds, err := this.FindAllEnabledPolicyIdsWithCertId(tx, certId)
+	if err != nil {
+		return err
+	}
+	for _, policyId := range policyIds {
+		certsJSON, err := this.Query(tx).
+			Pk(policyId).
+			Result("certs").
+			FindJSONCol()
+		if err != nil {
+			return err
+		}
+
+		var refs = []*sslconfigs.SSLCertRef{}
+		if IsNotNull(certsJSON) {
+			err = json.Unmarshal(certsJSON, &refs)
+			if err != nil {
+				return err
+			}
+		}
+
+		var found = false
+		for _, ref := range refs {
+			if ref.CertId == newCertId {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		refs = append(refs, &sslconfigs.SSLCertRef{
+			IsOn:   true,
+			CertId: newCertId,
+		})
+		refsJSON, err := json.Marshal(refs)
+		if err != nil {
+			return err
+		}
+		err = this.Query(tx).
+			Pk(policyId).
+			Set("certs", refsJSON).
+			UpdateQuickly()
+		if err != nil {
+			return err
+		}
+		err = this.NotifyUpdate(tx, policyId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CreatePolicy 创建Policy
 func (this *SSLPolicyDAO) CreatePolicy(tx *dbs.Tx, adminId int64, userId int64, http2Enabled bool, http3Enabled bool, minVersion string, certsJSON []byte, hstsJSON []byte, ocspIsOn bool, clientAuthType int32, clientCACertsJSON []byte, cipherSuitesIsOn bool, cipherSuites []string) (int64, error) {
 	var op = NewSSLPolicyOperator()
@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/zero"
@@ -261,3 +262,265 @@ func (this *SysSettingDAO) ReadDatabaseConfig(tx *dbs.Tx) (config *systemconfigs
 	}
 	return config, nil
 }
+
+// ReadSSLCertExpireConfig 读取证书到期通知和自动续期配置
+// 依次查找用户、管理员和全局配置，都没有设置时使用默认配置
+func (this *SysSettingDAO) ReadSSLCertExpireConfig(tx *dbs.Tx, adminId int64, userId int64) (*SSLCertExpireConfig, error) {
+	var codes = []string{}
+	if userId > 0 {
+		codes = append(codes, fmt.Sprintf(SSLCertExpireConfigUserSettingCode, userId))
+	}
+	if adminId > 0 {
+		codes = append(codes, fmt.Sprintf(SSLCertExpireConfigAdminSettingCode, adminId))
+	}
+	codes = append(codes, SSLCertExpireConfigSettingCode)
+
+	for _, code := range codes {
+		valueJSON, err := this.ReadSetting(tx, code)
+		if err != nil {
+			return nil, err
+		}
+		if len(valueJSON) == 0 {
+			continue
+		}
+
+		var config = DefaultSSLCertExpireConfig()
+		err = json.Unmarshal(valueJSON, config)
+		if err != nil {
+			return nil, err
+		}
+		err = config.Init()
+		if err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+
+	return DefaultSSLCertExpireConfig(), nil
+}
+
+// UpdateSSLCertExpireConfig 修改证书到期通知和自动续期配置
+// adminId和userId都为0时修改全局配置
+func (this *SysSettingDAO) UpdateSSLCertExpireConfig(tx *dbs.Tx, adminId int64, userId int64, config *SSLCertExpireConfig) error {
+	if config == nil {
+		return errors.New("config should not be nil")
+	}
+	err := config.Init()
+	if err != nil {
+		return err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	if userId > 0 {
+		return this.UpdateSetting(tx, SSLCertExpireConfigUserSettingCode, configJSON, userId)
+	}
+	if adminId > 0 {
+		return this.UpdateSetting(tx, SSLCertExpireConfigAdminSettingCode, configJSON, adminId)
+	}
+	return this.UpdateSetting(tx, SSLCertExpireConfigSettingCode, configJSON)
+}
+
+// ReadCTLogMonitorConfig 读取证书透明度日志监控配置
+func (this *SysSettingDAO) ReadCTLogMonitorConfig(tx *dbs.Tx) (*CTLogMonitorConfig, error) {
+	var config = DefaultCTLogMonitorConfig()
+	valueJSON, err := this.ReadSetting(tx, CTLogMonitorConfigSettingCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(valueJSON) == 0 {
+		return config, nil
+	}
+	err = json.Unmarshal(valueJSON, config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// UpdateCTLogMonitorConfig 修改证书透明度日志监控配置
+func (this *SysSettingDAO) UpdateCTLogMonitorConfig(tx *dbs.Tx, config *CTLogMonitorConfig) error {
+	if config == nil {
+		return errors.New("config should not be nil")
+	}
+	err := config.Init()
+	if err != nil {
+		return err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return this.UpdateSetting(tx, CTLogMonitorConfigSettingCode, configJSON)
+}
+
+// ReadCTLogMonitorState 读取证书透明度日志读取状态
+func (this *SysSettingDAO) ReadCTLogMonitorState(tx *dbs.Tx) (*CTLogMonitorState, error) {
+	var state = &CTLogMonitorState{}
+	valueJSON, err := this.ReadSetting(tx, CTLogMonitorStateSettingCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(valueJSON) == 0 {
+		return state, nil
+	}
+	err = json.Unmarshal(valueJSON, state)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// UpdateCTLogMonitorState 修改证书透明度日志读取状态
+func (this *SysSettingDAO) UpdateCTLogMonitorState(tx *dbs.Tx, state *CTLogMonitorState) error {
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return this.UpdateSetting(tx, CTLogMonitorStateSettingCode, stateJSON)
+}
+
+// ReadAPIAccessTokenConfig 读取API访问令牌配置
+func (this *SysSettingDAO) ReadAPIAccessTokenConfig(tx *dbs.Tx) (*APIAccessTokenConfig, error) {
+	var config = DefaultAPIAccessTokenConfig()
+	valueJSON, err := this.ReadSetting(tx, APIAccessTokenConfigSettingCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(valueJSON) > 0 {
+		err = json.Unmarshal(valueJSON, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.Init()
+	return config, nil
+}
+
+// ReadLoginWebAuthnConfig 读取安全密钥登录配置
+func (this *SysSettingDAO) ReadLoginWebAuthnConfig(tx *dbs.Tx) (*LoginWebAuthnConfig, error) {
+	var config = DefaultLoginWebAuthnConfig()
+	valueJSON, err := this.ReadSetting(tx, LoginWebAuthnConfigSettingCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(valueJSON) > 0 {
+		err = json.Unmarshal(valueJSON, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.Init()
+	return config, nil
+}
+
+// UpdateLoginWebAuthnConfig 修改安全密钥登录配置
+func (this *SysSettingDAO) UpdateLoginWebAuthnConfig(tx *dbs.Tx, config *LoginWebAuthnConfig) error {
+	if config == nil {
+		return errors.New("config should not be nil")
+	}
+	config.Init()
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return this.UpdateSetting(tx, LoginWebAuthnConfigSettingCode, configJSON)
+}
+
+// ReadAdminSSOConfig 读取管理员单点登录配置
+func (this *SysSettingDAO) ReadAdminSSOConfig(tx *dbs.Tx) (*AdminSSOConfig, error) {
+	var config = DefaultAdminSSOConfig()
+	valueJSON, err := this.ReadSetting(tx, AdminSSOConfigSettingCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(valueJSON) > 0 {
+		err = json.Unmarshal(valueJSON, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = config.Init()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// UpdateAdminSSOConfig 修改管理员单点登录配置
+func (this *SysSettingDAO) UpdateAdminSSOConfig(tx *dbs.Tx, config *AdminSSOConfig) error {
+	if config == nil {
+		return errors.New("config should not be nil")
+	}
+	err := config.Init()
+	if err != nil {
+		return err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return this.UpdateSetting(tx, AdminSSOConfigSettingCode, configJSON)
+}
+
+// ReadLoginSessionConfig 读取登录SESSION配置
+func (this *SysSettingDAO) ReadLoginSessionConfig(tx *dbs.Tx) (*LoginSessionConfig, error) {
+	var config = DefaultLoginSessionConfig()
+	valueJSON, err := this.ReadSetting(tx, LoginSessionConfigSettingCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(valueJSON) > 0 {
+		err = json.Unmarshal(valueJSON, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.Init()
+	return config, nil
+}
+
+// UpdateLoginSessionConfig 修改登录SESSION配置
+func (this *SysSettingDAO) UpdateLoginSessionConfig(tx *dbs.Tx, config *LoginSessionConfig) error {
+	if config == nil {
+		return errors.New("config should not be nil")
+	}
+	config.Init()
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return this.UpdateSetting(tx, LoginSessionConfigSettingCode, configJSON)
+}
+
+// ReadHTTPAccessLogSpoolConfig 读取访问日志本地磁盘队列配置
+func (this *SysSettingDAO) ReadHTTPAccessLogSpoolConfig(tx *dbs.Tx) (*HTTPAccessLogSpoolConfig, error) {
+	var config = DefaultHTTPAccessLogSpoolConfig()
+	valueJSON, err := this.ReadSetting(tx, HTTPAccessLogSpoolConfigSettingCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(valueJSON) > 0 {
+		err = json.Unmarshal(valueJSON, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.Init()
+	return config, nil
+}
+
+// UpdateHTTPAccessLogSpoolConfig 修改访问日志本地磁盘队列配置
+func (this *SysSettingDAO) UpdateHTTPAccessLogSpoolConfig(tx *dbs.Tx, config *HTTPAccessLogSpoolConfig) error {
+	if config == nil {
+		return errors.New("config should not be nil")
+	}
+	config.Init()
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return this.UpdateSetting(tx, HTTPAccessLogSpoolConfigSettingCode, configJSON)
+}
@@ -50,7 +50,12 @@ func (this *UserAccessKeyDAO) DisableUserAccessKey(tx *dbs.Tx, id int64) error {
 		Pk(id).
 		Set("state", UserAccessKeyStateDisabled).
 		Update()
-	return err
+	if err != nil {
+		return err
+	}
+
+	// 撤销所有令牌
+	return SharedAPIAccessTokenDAO.DeleteAccessTokensWithAccessKeyId(tx, id)
 }
 
 // FindEnabledUserAccessKey 查找启用中的条目
@@ -112,7 +117,15 @@ func (this *UserAccessKeyDAO) UpdateAccessKeyIsOn(tx *dbs.Tx, accessKeyId int64,
 		Pk(accessKeyId).
 		Set("isOn", isOn).
 		Update()
-	return err
+	if err != nil {
+		return err
+	}
+
+	// 停用时撤销所有令牌
+	if !isOn {
+		return SharedAPIAccessTokenDAO.DeleteAccessTokensWithAccessKeyId(tx, accessKeyId)
+	}
+	return nil
 }
 
 // FindAccessKeyWithUniqueId 根据UniqueId查找AccessKey
@@ -101,6 +101,12 @@ func (this *UserDAO) DisableUser(tx *dbs.Tx, userId int64) error {
 		return err
 	}
 
+	// 强制退出登录
+	err = SharedLoginSessionDAO.DeleteAllSessions(tx, 0, userId, "")
+	if err != nil {
+		return err
+	}
+
 	return this.NotifyUpdate(tx, userId)
 }
 
@@ -278,6 +284,14 @@ func (this *UserDAO) UpdateUser(tx *dbs.Tx, userId int64, username string, passw
 		}
 	}
 
+	// 禁用或修改密码后需要重新登录
+	if !isOn || len(password) > 0 {
+		err = SharedLoginSessionDAO.DeleteAllSessions(tx, 0, userId, "")
+		if err != nil {
+			return err
+		}
+	}
+
 	return this.NotifyUpdate(tx, userId)
 }
 
@@ -305,7 +319,16 @@ func (this *UserDAO) UpdateUserLogin(tx *dbs.Tx, userId int64, username string,
 	if len(password) > 0 {
 		op.Password = stringutil.Md5(password)
 	}
-	return this.Save(tx, op)
+	err := this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+
+	// 修改密码后需要重新登录
+	if len(password) > 0 {
+		return SharedLoginSessionDAO.DeleteAllSessions(tx, 0, userId, "")
+	}
+	return nil
 }
 
 // UpdateUserPassword 修改用户密码
@@ -313,12 +336,19 @@ func (this *UserDAO) UpdateUserPassword(tx *dbs.Tx, userId int64, password strin
 	if userId <= 0 {
 		return errors.New("invalid userId")
 	}
+	if len(password) == 0 {
+		return nil
+	}
 	var op = NewUserOperator()
 	op.Id = userId
-	if len(password) > 0 {
-		op.Password = stringutil.Md5(password)
+	op.Password = stringutil.Md5(password)
+	err := this.Save(tx, op)
+	if err != nil {
+		return err
 	}
-	return this.Save(tx, op)
+
+	// 修改密码后需要重新登录
+	return SharedLoginSessionDAO.DeleteAllSessions(tx, 0, userId, "")
 }
 
 // CountAllEnabledUsers 计算用户数量
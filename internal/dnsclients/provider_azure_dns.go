@@ -0,0 +1,545 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsclients
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/iwind/TeaGo/maps"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AzureDNSDefaultRoute = "default"
+	AzureDNSDefaultTTL   = 300
+	AzureDNSTimeout      = 30 * time.Second
+)
+
+// AzureDNSProvider Azure DNS
+// Azure DNS不支持按线路解析，所有记录都使用默认线路
+type AzureDNSProvider struct {
+	BaseProvider
+
+	ProviderId int64
+
+	subscriptionId string
+	resourceGroup  string // 资源组，为空时查找订阅下的所有区域
+
+	credential    azcore.TokenCredential
+	clientOptions *arm.ClientOptions // 自定义选项，用于测试
+
+	zonesClient      *armdns.ZonesClient
+	recordSetsClient *armdns.RecordSetsClient
+
+	zoneMap    map[string]string // domain => resourceGroup
+	zoneLocker sync.Mutex
+}
+
+// Auth 认证
+func (this *AzureDNSProvider) Auth(params maps.Map) error {
+	this.subscriptionId = params.GetString("subscriptionId")
+	if len(this.subscriptionId) == 0 {
+		return errors.New("'subscriptionId' should not be empty")
+	}
+	this.resourceGroup = params.GetString("resourceGroup")
+
+	if this.credential == nil {
+		var tenantId = params.GetString("tenantId")
+		var clientId = params.GetString("clientId")
+		var clientSecret = params.GetString("clientSecret")
+		if len(tenantId) == 0 {
+			return errors.New("'tenantId' should not be empty")
+		}
+		if len(clientId) == 0 {
+			return errors.New("'clientId' should not be empty")
+		}
+		if len(clientSecret) == 0 {
+			return errors.New("'clientSecret' should not be empty")
+		}
+		credential, err := azidentity.NewClientSecretCredential(tenantId, clientId, clientSecret, nil)
+		if err != nil {
+			return err
+		}
+		this.credential = credential
+	}
+
+	zonesClient, err := armdns.NewZonesClient(this.subscriptionId, this.credential, this.clientOptions)
+	if err != nil {
+		return err
+	}
+	recordSetsClient, err := armdns.NewRecordSetsClient(this.subscriptionId, this.credential, this.clientOptions)
+	if err != nil {
+		return err
+	}
+	this.zonesClient = zonesClient
+	this.recordSetsClient = recordSetsClient
+	this.zoneMap = map[string]string{}
+
+	return nil
+}
+
+// MaskParams 对参数进行掩码
+func (this *AzureDNSProvider) MaskParams(params maps.Map) {
+	if params == nil {
+		return
+	}
+	params["clientSecret"] = MaskString(params.GetString("clientSecret"))
+}
+
+// GetDomains 获取所有域名列表
+func (this *AzureDNSProvider) GetDomains() (domains []string, err error) {
+	zoneMap, err := this.findZones()
+	if err != nil {
+		return nil, err
+	}
+	for domain := range zoneMap {
+		domains = append(domains, domain)
+	}
+	return
+}
+
+// GetRecords 获取域名解析记录列表
+func (this *AzureDNSProvider) GetRecords(domain string) (records []*dnstypes.Record, err error) {
+	resourceGroup, err := this.findResourceGroupWithDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), AzureDNSTimeout)
+	defer cancel()
+
+	var pager = this.recordSetsClient.NewListAllByDNSZonePager(resourceGroup, domain, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, recordSet := range page.Value {
+			records = append(records, this.convertRecordSet(recordSet)...)
+		}
+	}
+	return
+}
+
+// GetRoutes 读取域名支持的线路数据
+func (this *AzureDNSProvider) GetRoutes(domain string) (routes []*dnstypes.Route, err error) {
+	routes = []*dnstypes.Route{
+		{Name: "默认", Code: AzureDNSDefaultRoute},
+	}
+	return
+}
+
+// QueryRecord 查询单个记录
+func (this *AzureDNSProvider) QueryRecord(domain string, name string, recordType dnstypes.RecordType) (*dnstypes.Record, error) {
+	records, err := this.QueryRecords(domain, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// QueryRecords 查询多个记录
+func (this *AzureDNSProvider) QueryRecords(domain string, name string, recordType dnstypes.RecordType) (records []*dnstypes.Record, err error) {
+	recordSet, _, err := this.findRecordSet(domain, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+	if recordSet == nil {
+		return nil, nil
+	}
+	return this.convertRecordSet(recordSet), nil
+}
+
+// AddRecord 设置记录
+func (this *AzureDNSProvider) AddRecord(domain string, newRecord *dnstypes.Record) error {
+	err := this.checkRoute(newRecord.Route)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+
+	recordSet, resourceGroup, err := this.findRecordSet(domain, newRecord.Name, newRecord.Type)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+
+	var options = &armdns.RecordSetsClientCreateOrUpdateOptions{}
+	if recordSet == nil {
+		recordSet = &armdns.RecordSet{}
+		options.IfNoneMatch = to.Ptr("*")
+	} else {
+		options.IfMatch = recordSet.Etag
+	}
+	if recordSet.Properties == nil {
+		recordSet.Properties = &armdns.RecordSetProperties{}
+	}
+
+	err = this.appendValue(recordSet.Properties, newRecord)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+	return this.WrapError(this.saveRecordSet(resourceGroup, domain, newRecord.Name, newRecord.Type, recordSet, options), domain, newRecord)
+}
+
+// UpdateRecord 修改记录
+func (this *AzureDNSProvider) UpdateRecord(domain string, record *dnstypes.Record, newRecord *dnstypes.Record) error {
+	// 不在同一个记录集中时，先添加再删除
+	if !strings.EqualFold(record.Name, newRecord.Name) || record.Type != newRecord.Type {
+		err := this.AddRecord(domain, newRecord)
+		if err != nil {
+			return err
+		}
+		return this.DeleteRecord(domain, record)
+	}
+
+	err := this.checkRoute(newRecord.Route)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+	recordSet, resourceGroup, err := this.findRecordSet(domain, record.Name, record.Type)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+	if recordSet == nil || recordSet.Properties == nil {
+		return this.AddRecord(domain, newRecord)
+	}
+
+	// 在一次请求中替换记录集中的值
+	this.removeValue(recordSet.Properties, record)
+	err = this.appendValue(recordSet.Properties, newRecord)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+	return this.WrapError(this.saveRecordSet(resourceGroup, domain, newRecord.Name, newRecord.Type, recordSet, &armdns.RecordSetsClientCreateOrUpdateOptions{
+		IfMatch: recordSet.Etag,
+	}), domain, newRecord)
+}
+
+// DeleteRecord 删除记录
+func (this *AzureDNSProvider) DeleteRecord(domain string, record *dnstypes.Record) error {
+	recordSet, resourceGroup, err := this.findRecordSet(domain, record.Name, record.Type)
+	if err != nil {
+		return this.WrapError(err, domain, record)
+	}
+	if recordSet == nil || recordSet.Properties == nil {
+		return nil
+	}
+
+	// 最后一个值被删除时删除整个记录集
+	var countLeft = this.removeValue(recordSet.Properties, record)
+	if countLeft < 0 {
+		return this.WrapError(errors.New("unsupported record type"), domain, record)
+	}
+	if countLeft == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), AzureDNSTimeout)
+		defer cancel()
+
+		_, err = this.recordSetsClient.Delete(ctx, resourceGroup, domain, this.relativeName(record.Name), armdns.RecordType(record.Type), &armdns.RecordSetsClientDeleteOptions{
+			IfMatch: recordSet.Etag,
+		})
+		return this.WrapError(err, domain, record)
+	}
+
+	return this.WrapError(this.saveRecordSet(resourceGroup, domain, record.Name, record.Type, recordSet, &armdns.RecordSetsClientCreateOrUpdateOptions{
+		IfMatch: recordSet.Etag,
+	}), domain, record)
+}
+
+// DefaultRoute 默认线路
+func (this *AzureDNSProvider) DefaultRoute() string {
+	return AzureDNSDefaultRoute
+}
+
+// 保存记录集
+func (this *AzureDNSProvider) saveRecordSet(resourceGroup string, domain string, name string, recordType dnstypes.RecordType, recordSet *armdns.RecordSet, options *armdns.RecordSetsClientCreateOrUpdateOptions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), AzureDNSTimeout)
+	defer cancel()
+
+	// 只提交可以修改的字段
+	_, err := this.recordSetsClient.CreateOrUpdate(ctx, resourceGroup, domain, this.relativeName(name), armdns.RecordType(recordType), armdns.RecordSet{
+		Properties: &armdns.RecordSetProperties{
+			TTL:         recordSet.Properties.TTL,
+			Metadata:    recordSet.Properties.Metadata,
+			ARecords:    recordSet.Properties.ARecords,
+			AaaaRecords: recordSet.Properties.AaaaRecords,
+			CnameRecord: recordSet.Properties.CnameRecord,
+			TxtRecords:  recordSet.Properties.TxtRecords,
+		},
+	}, options)
+	return err
+}
+
+// 查找记录集，不存在时返回nil
+func (this *AzureDNSProvider) findRecordSet(domain string, name string, recordType dnstypes.RecordType) (recordSet *armdns.RecordSet, resourceGroup string, err error) {
+	resourceGroup, err = this.findResourceGroupWithDomain(domain)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), AzureDNSTimeout)
+	defer cancel()
+
+	resp, err := this.recordSetsClient.Get(ctx, resourceGroup, domain, this.relativeName(name), armdns.RecordType(recordType), nil)
+	if err != nil {
+		// SDK直接返回 *azcore.ResponseError
+		respErr, ok := err.(*azcore.ResponseError)
+		if ok && respErr.StatusCode == http.StatusNotFound {
+			return nil, resourceGroup, nil
+		}
+		return nil, "", err
+	}
+	return &resp.RecordSet, resourceGroup, nil
+}
+
+// 转换记录集
+func (this *AzureDNSProvider) convertRecordSet(recordSet *armdns.RecordSet) (records []*dnstypes.Record) {
+	if recordSet == nil || recordSet.Properties == nil {
+		return nil
+	}
+
+	var name = azureValue(recordSet.Name)
+	var recordType = azureValue(recordSet.Type)
+	recordType = recordType[strings.LastIndex(recordType, "/")+1:]
+	var props = recordSet.Properties
+
+	var values = []string{}
+	switch recordType {
+	case dnstypes.RecordTypeA:
+		for _, aRecord := range props.ARecords {
+			values = append(values, azureValue(aRecord.IPv4Address))
+		}
+	case dnstypes.RecordTypeAAAA:
+		for _, aaaaRecord := range props.AaaaRecords {
+			values = append(values, azureValue(aaaaRecord.IPv6Address))
+		}
+	case dnstypes.RecordTypeCNAME:
+		if props.CnameRecord != nil {
+			values = append(values, strings.TrimSuffix(azureValue(props.CnameRecord.Cname), ".")+".")
+		}
+	case dnstypes.RecordTypeTXT:
+		for _, txtRecord := range props.TxtRecords {
+			values = append(values, this.joinTXT(txtRecord.Value))
+		}
+	default:
+		return nil
+	}
+
+	for _, value := range values {
+		records = append(records, &dnstypes.Record{
+			Id:    name + "@" + recordType + "@" + value,
+			Name:  name,
+			Type:  recordType,
+			Value: value,
+			Route: AzureDNSDefaultRoute,
+			TTL:   int32(azureValue(props.TTL)),
+		})
+	}
+	return
+}
+
+// 查找域名所在的资源组
+func (this *AzureDNSProvider) findResourceGroupWithDomain(domain string) (string, error) {
+	if len(this.resourceGroup) > 0 {
+		return this.resourceGroup, nil
+	}
+
+	this.zoneLocker.Lock()
+	resourceGroup, ok := this.zoneMap[domain]
+	this.zoneLocker.Unlock()
+	if ok {
+		return resourceGroup, nil
+	}
+
+	zoneMap, err := this.findZones()
+	if err != nil {
+		return "", err
+	}
+	resourceGroup, ok = zoneMap[domain]
+	if !ok {
+		return "", errors.New("can not found zone for domain '" + domain + "'")
+	}
+	return resourceGroup, nil
+}
+
+// 读取所有区域
+func (this *AzureDNSProvider) findZones() (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), AzureDNSTimeout)
+	defer cancel()
+
+	var zones = []*armdns.Zone{}
+	if len(this.resourceGroup) > 0 {
+		var pager = this.zonesClient.NewListByResourceGroupPager(this.resourceGroup, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			zones = append(zones, page.Value...)
+		}
+	} else {
+		var pager = this.zonesClient.NewListPager(nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			zones = append(zones, page.Value...)
+		}
+	}
+
+	var zoneMap = map[string]string{}
+	for _, zone := range zones {
+		// 只管理公共区域
+		if zone.Properties != nil && zone.Properties.ZoneType != nil && *zone.Properties.ZoneType != armdns.ZoneTypePublic {
+			continue
+		}
+		var resourceGroup = this.resourceGroup
+		if len(resourceGroup) == 0 {
+			resource, err := arm.ParseResourceID(azureValue(zone.ID))
+			if err != nil {
+				return nil, err
+			}
+			resourceGroup = resource.ResourceGroupName
+		}
+		zoneMap[azureValue(zone.Name)] = resourceGroup
+	}
+
+	this.zoneLocker.Lock()
+	this.zoneMap = zoneMap
+	this.zoneLocker.Unlock()
+
+	return zoneMap, nil
+}
+
+// 在记录集中增加一个值
+func (this *AzureDNSProvider) appendValue(props *armdns.RecordSetProperties, record *dnstypes.Record) error {
+	switch record.Type {
+	case dnstypes.RecordTypeA:
+		if net.ParseIP(record.Value).To4() == nil {
+			return errors.New("invalid IPv4 address")
+		}
+		props.ARecords = append(props.ARecords, &armdns.ARecord{IPv4Address: to.Ptr(record.Value)})
+	case dnstypes.RecordTypeAAAA:
+		if net.ParseIP(record.Value) == nil {
+			return errors.New("invalid IPv6 address")
+		}
+		props.AaaaRecords = append(props.AaaaRecords, &armdns.AaaaRecord{IPv6Address: to.Ptr(record.Value)})
+	case dnstypes.RecordTypeCNAME:
+		// CNAME记录集只能有一个值
+		props.CnameRecord = &armdns.CnameRecord{Cname: to.Ptr(strings.TrimSuffix(record.Value, "."))}
+	case dnstypes.RecordTypeTXT:
+		props.TxtRecords = append(props.TxtRecords, &armdns.TxtRecord{Value: this.splitTXT(record.Value)})
+	default:
+		return errors.New("unsupported record type")
+	}
+
+	if record.TTL > 0 {
+		props.TTL = to.Ptr(int64(record.TTL))
+	} else if props.TTL == nil {
+		props.TTL = to.Ptr(int64(AzureDNSDefaultTTL))
+	}
+	return nil
+}
+
+// 从记录集中删除一个值，返回剩余值的数量，不支持的记录类型返回-1
+func (this *AzureDNSProvider) removeValue(props *armdns.RecordSetProperties, record *dnstypes.Record) (countLeft int) {
+	switch record.Type {
+	case dnstypes.RecordTypeA:
+		var aRecords = []*armdns.ARecord{}
+		for _, aRecord := range props.ARecords {
+			if azureValue(aRecord.IPv4Address) != record.Value {
+				aRecords = append(aRecords, aRecord)
+			}
+		}
+		props.ARecords = aRecords
+		return len(aRecords)
+	case dnstypes.RecordTypeAAAA:
+		var aaaaRecords = []*armdns.AaaaRecord{}
+		for _, aaaaRecord := range props.AaaaRecords {
+			if !net.ParseIP(azureValue(aaaaRecord.IPv6Address)).Equal(net.ParseIP(record.Value)) {
+				aaaaRecords = append(aaaaRecords, aaaaRecord)
+			}
+		}
+		props.AaaaRecords = aaaaRecords
+		return len(aaaaRecords)
+	case dnstypes.RecordTypeCNAME:
+		if props.CnameRecord != nil && strings.EqualFold(strings.TrimSuffix(azureValue(props.CnameRecord.Cname), "."), strings.TrimSuffix(record.Value, ".")) {
+			props.CnameRecord = nil
+		}
+		if props.CnameRecord != nil {
+			return 1
+		}
+		return 0
+	case dnstypes.RecordTypeTXT:
+		var txtRecords = []*armdns.TxtRecord{}
+		for _, txtRecord := range props.TxtRecords {
+			if this.joinTXT(txtRecord.Value) != record.Value {
+				txtRecords = append(txtRecords, txtRecord)
+			}
+		}
+		props.TxtRecords = txtRecords
+		return len(txtRecords)
+	}
+	return -1
+}
+
+func (this *AzureDNSProvider) checkRoute(route string) error {
+	if len(route) > 0 && route != AzureDNSDefaultRoute {
+		return errors.New("unsupported route '" + route + "'")
+	}
+	return nil
+}
+
+// 相对名称，根域名使用@
+func (this *AzureDNSProvider) relativeName(name string) string {
+	if len(name) == 0 {
+		return "@"
+	}
+	return name
+}
+
+// 单个字符串不能超过255个字节
+func (this *AzureDNSProvider) splitTXT(value string) []*string {
+	var result = []*string{}
+	for {
+		var piece = value
+		if len(piece) > 255 {
+			piece = value[:255]
+		}
+		value = value[len(piece):]
+		result = append(result, to.Ptr(piece))
+		if len(value) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+func (this *AzureDNSProvider) joinTXT(values []*string) string {
+	var result = ""
+	for _, value := range values {
+		result += azureValue(value)
+	}
+	return result
+}
+
+// 读取指针指向的值，指针为nil时返回零值
+func azureValue[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
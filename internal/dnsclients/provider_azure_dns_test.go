@@ -0,0 +1,254 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsclients
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testAzureCredential struct{}
+
+func (this *testAzureCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token1", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// 测试用的Azure DNS接口，只实现用到的几个API
+type testAzureDNSServer struct {
+	server *httptest.Server
+
+	locker     sync.Mutex
+	recordSets map[string]*armdns.RecordSet // type/name => record set
+	etag       int
+	methods    []string
+}
+
+func newTestAzureDNSServer() *testAzureDNSServer {
+	var s = &testAzureDNSServer{
+		recordSets: map[string]*armdns.RecordSet{
+			"NS/@": {
+				Name:       to.Ptr("@"),
+				Type:       to.Ptr("Microsoft.Network/dnszones/NS"),
+				Etag:       to.Ptr("0"),
+				Properties: &armdns.RecordSetProperties{TTL: to.Ptr(int64(172800)), NsRecords: []*armdns.NsRecord{{Nsdname: to.Ptr("ns1-01.azure-dns.com.")}}},
+			},
+		},
+	}
+
+	var writeJSON = func(writer http.ResponseWriter, status int, v any) {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(status)
+		data, _ := json.Marshal(v)
+		_, _ = writer.Write(data)
+	}
+	var writeError = func(writer http.ResponseWriter, status int, code string) {
+		writeJSON(writer, status, maps.Map{"error": maps.Map{"code": code, "message": code}})
+	}
+
+	const zonePrefix = "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Network/dnsZones/example.com/"
+
+	s.server = httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token1" {
+			writeError(writer, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		s.locker.Lock()
+		defer s.locker.Unlock()
+
+		switch {
+		case req.URL.Path == "/subscriptions/sub1/providers/Microsoft.Network/dnszones":
+			writeJSON(writer, http.StatusOK, maps.Map{
+				"value": []maps.Map{
+					{"id": "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Network/dnszones/example.com", "name": "example.com", "properties": maps.Map{"zoneType": "Public"}},
+					{"id": "/subscriptions/sub1/resourceGroups/rg2/providers/Microsoft.Network/dnszones/internal.example.com", "name": "internal.example.com", "properties": maps.Map{"zoneType": "Private"}},
+				},
+			})
+		case req.URL.Path == zonePrefix+"all":
+			var recordSets = []*armdns.RecordSet{}
+			for _, recordSet := range s.recordSets {
+				recordSets = append(recordSets, recordSet)
+			}
+			sort.Slice(recordSets, func(i, j int) bool {
+				return *recordSets[i].Type+*recordSets[i].Name < *recordSets[j].Type+*recordSets[j].Name
+			})
+			writeJSON(writer, http.StatusOK, maps.Map{"value": recordSets})
+		case strings.HasPrefix(req.URL.Path, zonePrefix):
+			var key = strings.TrimPrefix(req.URL.Path, zonePrefix)
+			var pieces = strings.Split(key, "/")
+			var recordSet = s.recordSets[key]
+			s.methods = append(s.methods, req.Method)
+
+			// 检查ETag
+			var ifMatch = req.Header.Get("If-Match")
+			if len(ifMatch) > 0 && (recordSet == nil || *recordSet.Etag != ifMatch) {
+				writeError(writer, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+			if req.Header.Get("If-None-Match") == "*" && recordSet != nil {
+				writeError(writer, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+
+			switch req.Method {
+			case http.MethodGet:
+				if recordSet == nil {
+					writeError(writer, http.StatusNotFound, "NotFound")
+					return
+				}
+				writeJSON(writer, http.StatusOK, recordSet)
+			case http.MethodPut:
+				var newRecordSet = &armdns.RecordSet{}
+				err := json.NewDecoder(req.Body).Decode(newRecordSet)
+				if err != nil {
+					writeError(writer, http.StatusBadRequest, "BadRequest")
+					return
+				}
+				s.etag++
+				newRecordSet.Name = to.Ptr(pieces[1])
+				newRecordSet.Type = to.Ptr("Microsoft.Network/dnszones/" + pieces[0])
+				newRecordSet.Etag = to.Ptr(types.String(s.etag))
+				s.recordSets[key] = newRecordSet
+				writeJSON(writer, http.StatusOK, newRecordSet)
+			case http.MethodDelete:
+				delete(s.recordSets, key)
+				writer.WriteHeader(http.StatusOK)
+			}
+		default:
+			writeError(writer, http.StatusNotFound, "NotFound")
+		}
+	}))
+	return s
+}
+
+func TestAzureDNSProvider(t *testing.T) {
+	var server = newTestAzureDNSServer()
+	defer server.server.Close()
+
+	var provider = &AzureDNSProvider{
+		credential: &testAzureCredential{},
+		clientOptions: &arm.ClientOptions{
+			ClientOptions: policy.ClientOptions{
+				Cloud: cloud.Configuration{
+					ActiveDirectoryAuthorityHost: server.server.URL,
+					Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+						cloud.ResourceManager: {
+							Endpoint: server.server.URL,
+							Audience: "https://management.azure.com",
+						},
+					},
+				},
+				Transport: server.server.Client(),
+				Retry:     policy.RetryOptions{MaxRetries: -1},
+			},
+		},
+	}
+	err := provider.Auth(maps.Map{"subscriptionId": "sub1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	domains, err := provider.GetDomains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(domains, ",") != "example.com" {
+		t.Fatal("unexpected domains:", domains)
+	}
+
+	// 添加
+	for _, record := range []*dnstypes.Record{
+		{Name: "www", Type: dnstypes.RecordTypeA, Value: "1.1.1.1", Route: ""},
+		{Name: "www", Type: dnstypes.RecordTypeA, Value: "2.2.2.2", Route: "default", TTL: 120},
+		{Name: "cdn", Type: dnstypes.RecordTypeCNAME, Value: "cluster.example.net."},
+		{Name: "_acme-challenge", Type: dnstypes.RecordTypeTXT, Value: strings.Repeat("a", 300)},
+	} {
+		err = provider.AddRecord("example.com", record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := provider.GetRecords("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys = []string{}
+	for _, record := range records {
+		var value = record.Value
+		if len(value) > 10 {
+			value = value[:10]
+		}
+		keys = append(keys, record.Route+"/"+record.Name+"/"+record.Type+"/"+value+"/"+types.String(record.TTL))
+	}
+	if strings.Join(keys, ",") != "default/www/A/1.1.1.1/120,default/www/A/2.2.2.2/120,default/cdn/CNAME/cluster.ex/300,default/_acme-challenge/TXT/aaaaaaaaaa/300" {
+		t.Fatal("unexpected records:", keys)
+	}
+
+	// 查询
+	txtRecord, err := provider.QueryRecord("example.com", "_acme-challenge", dnstypes.RecordTypeTXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txtRecord == nil || txtRecord.Value != strings.Repeat("a", 300) {
+		t.Fatalf("unexpected txt record: %+v", txtRecord)
+	}
+	noneRecord, err := provider.QueryRecord("example.com", "none", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if noneRecord != nil {
+		t.Fatal("record should not exist")
+	}
+
+	// 修改
+	server.methods = nil
+	err = provider.UpdateRecord("example.com", records[0], &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA, Value: "3.3.3.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(server.methods, ",") != "GET,PUT" {
+		t.Fatal("record set should be updated with one request:", server.methods)
+	}
+	wwwRecords, err := provider.QueryRecords("example.com", "www", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wwwRecords) != 2 || wwwRecords[0].Value != "2.2.2.2" || wwwRecords[1].Value != "3.3.3.3" {
+		t.Fatal("unexpected records:", wwwRecords)
+	}
+
+	// 删除，最后一个值被删除时删除整个记录集
+	server.methods = nil
+	for _, record := range wwwRecords {
+		err = provider.DeleteRecord("example.com", record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(server.methods, ",") != "GET,PUT,GET,DELETE" {
+		t.Fatal("unexpected requests:", server.methods)
+	}
+
+	// 不支持的线路
+	err = provider.AddRecord("example.com", &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA, Value: "4.4.4.4", Route: "telecom"})
+	if err == nil {
+		t.Fatal("should fail with unsupported route")
+	}
+}
@@ -46,12 +46,13 @@ type DNSPodProvider struct {
 
 // Auth 认证
 func (this *DNSPodProvider) Auth(params maps.Map) error {
-	// 兼容腾讯云API
+	// 兼容腾讯云API，新的服务商请直接使用 ProviderTypeTencentDNS
 	var apiType = params.GetString("apiType")
 
 	switch apiType {
-	case "tencentDNS":
+	case ProviderTypeTencentDNS:
 		this.tencentDNSProvider = NewTencentDNSProvider()
+		this.tencentDNSProvider.ProviderId = this.ProviderId
 		return this.tencentDNSProvider.Auth(params)
 	default:
 		this.apiId = params.GetString("id")
@@ -75,7 +76,7 @@ func (this *DNSPodProvider) MaskParams(params maps.Map) {
 		return
 	}
 
-	if params.GetString("apiType") == "tencentDNS" {
+	if params.GetString("apiType") == ProviderTypeTencentDNS {
 		params["accessKeySecret"] = MaskString(params.GetString("accessKeySecret"))
 	} else {
 		params["token"] = MaskString(params.GetString("token"))
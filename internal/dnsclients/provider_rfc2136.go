@@ -0,0 +1,502 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsclients
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/miekg/dns"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	RFC2136DefaultRoute = "default"
+	RFC2136DefaultTTL   = 600
+	RFC2136Timeout      = 10 * time.Second
+)
+
+// RFC2136View 视图
+// BIND等服务通过TSIG密钥匹配视图（match-clients { key xxx; }），这里每个视图对应一条线路
+type RFC2136View struct {
+	Name      string `json:"name"`      // 线路名称
+	Code      string `json:"code"`      // 线路代号
+	KeyName   string `json:"keyName"`   // TSIG密钥名称
+	Secret    string `json:"secret"`    // TSIG密钥，Base64编码
+	Algorithm string `json:"algorithm"` // TSIG算法，默认为 hmac-sha256
+}
+
+func (this *RFC2136View) init() error {
+	if len(this.KeyName) == 0 {
+		return errors.New("'keyName' should not be empty")
+	}
+	this.KeyName = strings.ToLower(dns.Fqdn(this.KeyName))
+
+	if len(this.Secret) == 0 {
+		return errors.New("'secret' should not be empty")
+	}
+	_, err := base64.StdEncoding.DecodeString(this.Secret)
+	if err != nil {
+		return errors.New("'secret' should be encoded with base64")
+	}
+
+	if len(this.Algorithm) == 0 {
+		this.Algorithm = dns.HmacSHA256
+	}
+	this.Algorithm = strings.ToLower(dns.Fqdn(this.Algorithm))
+	switch this.Algorithm {
+	case dns.HmacSHA1, dns.HmacSHA224, dns.HmacSHA256, dns.HmacSHA384, dns.HmacSHA512:
+	default:
+		return errors.New("unsupported TSIG algorithm '" + strings.TrimSuffix(this.Algorithm, ".") + "'")
+	}
+	return nil
+}
+
+// RFC2136Provider 支持RFC 2136动态更新的权威DNS服务，比如BIND、PowerDNS、Knot等
+// 使用AXFR读取记录，使用TSIG签名的UPDATE消息修改记录
+type RFC2136Provider struct {
+	BaseProvider
+
+	ProviderId int64
+
+	server  string   // 服务器地址 host:port
+	network string   // udp|tcp
+	domains []string // 管理的区域
+
+	views []*RFC2136View // 第一个为默认线路
+}
+
+// Auth 认证
+func (this *RFC2136Provider) Auth(params maps.Map) error {
+	this.server = strings.TrimSpace(params.GetString("server"))
+	if len(this.server) == 0 {
+		return errors.New("'server' should not be empty")
+	}
+	_, _, err := net.SplitHostPort(this.server)
+	if err != nil {
+		this.server = net.JoinHostPort(strings.Trim(this.server, "[]"), "53")
+	}
+
+	this.network = params.GetString("network")
+	if len(this.network) == 0 {
+		this.network = "tcp"
+	}
+	if this.network != "tcp" && this.network != "udp" {
+		return errors.New("'network' should be 'tcp' or 'udp'")
+	}
+
+	this.domains = nil
+	for _, domain := range regexp.MustCompile(`[\s,;]+`).Split(params.GetString("domains"), -1) {
+		domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+		if len(domain) > 0 {
+			this.domains = append(this.domains, domain)
+		}
+	}
+
+	// 默认线路
+	var defaultView = &RFC2136View{
+		Name:      "默认",
+		Code:      RFC2136DefaultRoute,
+		KeyName:   params.GetString("keyName"),
+		Secret:    params.GetString("secret"),
+		Algorithm: params.GetString("algorithm"),
+	}
+	err = defaultView.init()
+	if err != nil {
+		return err
+	}
+	this.views = []*RFC2136View{defaultView}
+
+	// 其他视图
+	var viewsValue = params.Get("views")
+	if viewsValue != nil {
+		viewsJSON, err := json.Marshal(viewsValue)
+		if err != nil {
+			return err
+		}
+		var views = []*RFC2136View{}
+		err = json.Unmarshal(viewsJSON, &views)
+		if err != nil {
+			return errors.New("decode 'views' failed: " + err.Error())
+		}
+		for _, view := range views {
+			if len(view.Code) == 0 {
+				return errors.New("'code' of view should not be empty")
+			}
+			if this.findView(view.Code) != nil {
+				return errors.New("duplicate view code '" + view.Code + "'")
+			}
+			err = view.init()
+			if err != nil {
+				return errors.New("invalid view '" + view.Code + "': " + err.Error())
+			}
+			if len(view.Name) == 0 {
+				view.Name = view.Code
+			}
+			this.views = append(this.views, view)
+		}
+	}
+
+	return nil
+}
+
+// MaskParams 对参数进行掩码
+func (this *RFC2136Provider) MaskParams(params maps.Map) {
+	if params == nil {
+		return
+	}
+	params["secret"] = MaskString(params.GetString("secret"))
+
+	views, ok := params.Get("views").([]any)
+	if ok {
+		for _, view := range views {
+			viewMap, ok := view.(map[string]any)
+			if ok {
+				secret, _ := viewMap["secret"].(string)
+				viewMap["secret"] = MaskString(secret)
+			}
+		}
+	}
+}
+
+// GetDomains 获取所有域名列表
+// DNS协议无法列出服务器上的所有区域，所以这里返回参数中设置的区域
+func (this *RFC2136Provider) GetDomains() (domains []string, err error) {
+	return this.domains, nil
+}
+
+// GetRecords 获取域名解析记录列表
+func (this *RFC2136Provider) GetRecords(domain string) (records []*dnstypes.Record, err error) {
+	var zone = dns.Fqdn(strings.ToLower(domain))
+	for _, view := range this.views {
+		var msg = new(dns.Msg)
+		msg.SetAxfr(zone)
+		msg.SetTsig(view.KeyName, view.Algorithm, 300, time.Now().Unix())
+
+		var transfer = &dns.Transfer{
+			DialTimeout:  RFC2136Timeout,
+			ReadTimeout:  RFC2136Timeout,
+			WriteTimeout: RFC2136Timeout,
+			TsigSecret:   map[string]string{view.KeyName: view.Secret},
+		}
+		envelopes, err := transfer.In(msg, this.server)
+		if err != nil {
+			return nil, fmt.Errorf("transfer zone '%s' failed: %w", domain, err)
+		}
+		for envelope := range envelopes {
+			if envelope.Error != nil {
+				return nil, fmt.Errorf("transfer zone '%s' failed: %w", domain, envelope.Error)
+			}
+			for _, rr := range envelope.RR {
+				var record = this.convertRR(zone, rr, view.Code)
+				if record != nil {
+					records = append(records, record)
+				}
+			}
+		}
+	}
+
+	// AXFR的结果以SOA开头和结尾，其他记录不会重复
+	return records, nil
+}
+
+// GetRoutes 读取域名支持的线路数据
+func (this *RFC2136Provider) GetRoutes(domain string) (routes []*dnstypes.Route, err error) {
+	for _, view := range this.views {
+		routes = append(routes, &dnstypes.Route{
+			Name: view.Name,
+			Code: view.Code,
+		})
+	}
+	return
+}
+
+// QueryRecord 查询单个记录
+func (this *RFC2136Provider) QueryRecord(domain string, name string, recordType dnstypes.RecordType) (*dnstypes.Record, error) {
+	records, err := this.QueryRecords(domain, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// QueryRecords 查询多个记录
+func (this *RFC2136Provider) QueryRecords(domain string, name string, recordType dnstypes.RecordType) (records []*dnstypes.Record, err error) {
+	var zone = dns.Fqdn(strings.ToLower(domain))
+	var fqdn = this.fqdn(zone, name)
+	qType, ok := dns.StringToType[strings.ToUpper(recordType)]
+	if !ok {
+		return nil, errors.New("unsupported record type '" + recordType + "'")
+	}
+
+	for _, view := range this.views {
+		var msg = new(dns.Msg)
+		msg.SetQuestion(fqdn, qType)
+		msg.RecursionDesired = false
+
+		resp, err := this.exchange(view, msg)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Rcode == dns.RcodeNameError {
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			return nil, errors.New("query '" + fqdn + "' failed: " + dns.RcodeToString[resp.Rcode])
+		}
+		for _, rr := range resp.Answer {
+			// 排除CNAME链中的其他记录
+			if rr.Header().Rrtype != qType || !strings.EqualFold(rr.Header().Name, fqdn) {
+				continue
+			}
+			var record = this.convertRR(zone, rr, view.Code)
+			if record != nil {
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+// AddRecord 设置记录
+func (this *RFC2136Provider) AddRecord(domain string, newRecord *dnstypes.Record) error {
+	var zone = dns.Fqdn(strings.ToLower(domain))
+	view, err := this.findRouteView(newRecord.Route)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+	rr, err := this.buildRR(zone, newRecord)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+
+	var msg = new(dns.Msg)
+	msg.SetUpdate(zone)
+	msg.Insert([]dns.RR{rr})
+	return this.WrapError(this.update(view, msg), domain, newRecord)
+}
+
+// UpdateRecord 修改记录
+func (this *RFC2136Provider) UpdateRecord(domain string, record *dnstypes.Record, newRecord *dnstypes.Record) error {
+	var zone = dns.Fqdn(strings.ToLower(domain))
+	oldView, err := this.findRouteView(record.Route)
+	if err != nil {
+		return this.WrapError(err, domain, record)
+	}
+	newView, err := this.findRouteView(newRecord.Route)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+	oldRR, err := this.buildRR(zone, record)
+	if err != nil {
+		return this.WrapError(err, domain, record)
+	}
+	newRR, err := this.buildRR(zone, newRecord)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+
+	// 同一个视图中在一个UPDATE消息中完成，保证原子性
+	if oldView == newView {
+		var msg = new(dns.Msg)
+		msg.SetUpdate(zone)
+		msg.Remove([]dns.RR{oldRR})
+		msg.Insert([]dns.RR{newRR})
+		return this.WrapError(this.update(newView, msg), domain, newRecord)
+	}
+
+	// 不同的视图需要分别操作
+	err = this.AddRecord(domain, newRecord)
+	if err != nil {
+		return err
+	}
+	return this.DeleteRecord(domain, record)
+}
+
+// DeleteRecord 删除记录
+func (this *RFC2136Provider) DeleteRecord(domain string, record *dnstypes.Record) error {
+	var zone = dns.Fqdn(strings.ToLower(domain))
+	view, err := this.findRouteView(record.Route)
+	if err != nil {
+		return this.WrapError(err, domain, record)
+	}
+	rr, err := this.buildRR(zone, record)
+	if err != nil {
+		return this.WrapError(err, domain, record)
+	}
+
+	var msg = new(dns.Msg)
+	msg.SetUpdate(zone)
+	msg.Remove([]dns.RR{rr})
+	return this.WrapError(this.update(view, msg), domain, record)
+}
+
+// DefaultRoute 默认线路
+func (this *RFC2136Provider) DefaultRoute() string {
+	return RFC2136DefaultRoute
+}
+
+// 发送UPDATE消息
+func (this *RFC2136Provider) update(view *RFC2136View, msg *dns.Msg) error {
+	resp, err := this.exchange(view, msg)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return errors.New("update failed: " + dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+// 使用视图对应的TSIG密钥发送消息
+func (this *RFC2136Provider) exchange(view *RFC2136View, msg *dns.Msg) (*dns.Msg, error) {
+	msg.SetTsig(view.KeyName, view.Algorithm, 300, time.Now().Unix())
+
+	var client = &dns.Client{
+		Net:        this.network,
+		Timeout:    RFC2136Timeout,
+		TsigSecret: map[string]string{view.KeyName: view.Secret},
+	}
+	resp, _, err := client.Exchange(msg, this.server)
+	if err != nil {
+		return nil, err
+	}
+
+	// UDP响应被截断时使用TCP重试
+	if resp.Truncated && client.Net == "udp" {
+		client.Net = "tcp"
+		msg.SetTsig(view.KeyName, view.Algorithm, 300, time.Now().Unix())
+		resp, _, err = client.Exchange(msg, this.server)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// 转换记录，不支持的记录类型返回nil
+func (this *RFC2136Provider) convertRR(zone string, rr dns.RR, route string) *dnstypes.Record {
+	var header = rr.Header()
+	var value string
+	switch r := rr.(type) {
+	case *dns.SOA:
+		return nil
+	case *dns.A:
+		value = r.A.String()
+	case *dns.AAAA:
+		value = r.AAAA.String()
+	case *dns.CNAME:
+		value = r.Target
+	case *dns.TXT:
+		value = strings.Join(r.Txt, "")
+	default:
+		// DNSSEC相关记录由服务器自动维护
+		switch header.Rrtype {
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3, dns.TypeNSEC3PARAM, dns.TypeDNSKEY, dns.TypeTSIG:
+			return nil
+		}
+		value = strings.TrimSpace(strings.TrimPrefix(rr.String(), header.String()))
+	}
+
+	var name = strings.ToLower(header.Name)
+	if name == zone {
+		name = "@"
+	} else {
+		name = strings.TrimSuffix(name, "."+zone)
+	}
+
+	var recordType = dns.TypeToString[header.Rrtype]
+	return &dnstypes.Record{
+		Id:    route + "@" + name + "@" + recordType + "@" + value,
+		Name:  name,
+		Type:  recordType,
+		Value: value,
+		Route: route,
+		TTL:   int32(header.Ttl),
+	}
+}
+
+// 根据记录构造RR
+func (this *RFC2136Provider) buildRR(zone string, record *dnstypes.Record) (dns.RR, error) {
+	var ttl = record.TTL
+	if ttl <= 0 {
+		ttl = RFC2136DefaultTTL
+	}
+	var header = dns.RR_Header{
+		Name:  this.fqdn(zone, record.Name),
+		Class: dns.ClassINET,
+		Ttl:   uint32(ttl),
+	}
+
+	var recordType = strings.ToUpper(record.Type)
+	switch recordType {
+	case dnstypes.RecordTypeA:
+		var ip = net.ParseIP(record.Value)
+		if ip == nil || ip.To4() == nil {
+			return nil, errors.New("invalid IPv4 address '" + record.Value + "'")
+		}
+		header.Rrtype = dns.TypeA
+		return &dns.A{Hdr: header, A: ip.To4()}, nil
+	case dnstypes.RecordTypeAAAA:
+		var ip = net.ParseIP(record.Value)
+		if ip == nil || ip.To4() != nil {
+			return nil, errors.New("invalid IPv6 address '" + record.Value + "'")
+		}
+		header.Rrtype = dns.TypeAAAA
+		return &dns.AAAA{Hdr: header, AAAA: ip}, nil
+	case dnstypes.RecordTypeCNAME:
+		header.Rrtype = dns.TypeCNAME
+		return &dns.CNAME{Hdr: header, Target: dns.Fqdn(record.Value)}, nil
+	case dnstypes.RecordTypeTXT:
+		// 单个字符串不能超过255个字节
+		var txt = []string{}
+		var value = record.Value
+		for len(value) > 255 {
+			txt = append(txt, value[:255])
+			value = value[255:]
+		}
+		txt = append(txt, value)
+		header.Rrtype = dns.TypeTXT
+		return &dns.TXT{Hdr: header, Txt: txt}, nil
+	}
+
+	return dns.NewRR(fmt.Sprintf("%s %d IN %s %s", header.Name, ttl, recordType, record.Value))
+}
+
+// 记录的完整域名
+func (this *RFC2136Provider) fqdn(zone string, name string) string {
+	if len(name) == 0 || name == "@" {
+		return zone
+	}
+	return dns.Fqdn(strings.ToLower(name) + "." + zone)
+}
+
+// 查找线路对应的视图
+func (this *RFC2136Provider) findRouteView(route string) (*RFC2136View, error) {
+	if len(route) == 0 {
+		return this.views[0], nil
+	}
+	var view = this.findView(route)
+	if view == nil {
+		return nil, errors.New("can not find route '" + route + "'")
+	}
+	return view, nil
+}
+
+func (this *RFC2136Provider) findView(code string) *RFC2136View {
+	for _, view := range this.views {
+		if view.Code == code {
+			return view
+		}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsclients
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testRFC2136Zone          = "example.com."
+	testRFC2136DefaultKey    = "edge-default."
+	testRFC2136DefaultSecret = "c2VjcmV0LWRlZmF1bHQtc2VjcmV0LWRlZmF1bHQ="
+	testRFC2136TelecomKey    = "edge-telecom."
+	testRFC2136TelecomSecret = "c2VjcmV0LXRlbGVjb20tc2VjcmV0LXRlbGVjb20="
+)
+
+// 测试用的权威DNS服务，每个TSIG密钥对应一个视图
+type testRFC2136Server struct {
+	server *dns.Server
+	addr   string
+
+	locker sync.Mutex
+	views  map[string][]dns.RR // key name => records
+}
+
+func newTestRFC2136Server(t *testing.T) *testRFC2136Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var soa, _ = dns.NewRR(testRFC2136Zone + " 3600 IN SOA ns1.example.com. admin.example.com. 1 3600 600 86400 600")
+	var www, _ = dns.NewRR("www." + testRFC2136Zone + " 600 IN A 1.1.1.1")
+	var s = &testRFC2136Server{
+		addr: listener.Addr().String(),
+		views: map[string][]dns.RR{
+			testRFC2136DefaultKey: {soa, www},
+			testRFC2136TelecomKey: {soa},
+		},
+	}
+
+	var started = make(chan bool)
+	s.server = &dns.Server{
+		Listener: listener,
+		TsigSecret: map[string]string{
+			testRFC2136DefaultKey: testRFC2136DefaultSecret,
+			testRFC2136TelecomKey: testRFC2136TelecomSecret,
+		},
+		Handler: dns.HandlerFunc(s.handle),
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {
+			return dns.MsgAccept // 默认不接受UPDATE消息
+		},
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go func() {
+		_ = s.server.ActivateAndServe()
+	}()
+	<-started
+	return s
+}
+
+func (this *testRFC2136Server) Close() {
+	_ = this.server.Shutdown()
+}
+
+func (this *testRFC2136Server) handle(writer dns.ResponseWriter, req *dns.Msg) {
+	var resp = new(dns.Msg)
+	resp.SetReply(req)
+
+	var tsig = req.IsTsig()
+	if tsig == nil || writer.TsigStatus() != nil {
+		resp.SetRcode(req, dns.RcodeRefused)
+		_ = writer.WriteMsg(resp)
+		return
+	}
+	resp.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	var records = this.views[tsig.Hdr.Name]
+
+	switch {
+	case req.Opcode == dns.OpcodeUpdate:
+		for _, rr := range req.Ns {
+			switch rr.Header().Class {
+			case dns.ClassINET:
+				var exists = false
+				for _, record := range records {
+					if dns.IsDuplicate(record, rr) {
+						exists = true
+					}
+				}
+				if !exists {
+					records = append(records, rr)
+				}
+			case dns.ClassNONE:
+				var h = *rr.Header()
+				h.Class = dns.ClassINET
+				var newRecords = []dns.RR{}
+				for _, record := range records {
+					var target = dns.Copy(rr)
+					*target.Header() = h
+					if !dns.IsDuplicate(record, target) {
+						newRecords = append(newRecords, record)
+					}
+				}
+				records = newRecords
+			}
+		}
+		this.views[tsig.Hdr.Name] = records
+	case req.Question[0].Qtype == dns.TypeAXFR:
+		var ch = make(chan *dns.Envelope)
+		var transfer = new(dns.Transfer)
+		go func() {
+			ch <- &dns.Envelope{RR: append(append([]dns.RR{}, records...), records[0])}
+			close(ch)
+		}()
+		_ = transfer.Out(writer, req, ch)
+		return
+	default:
+		var q = req.Question[0]
+		for _, record := range records {
+			if strings.EqualFold(record.Header().Name, q.Name) && record.Header().Rrtype == q.Qtype {
+				resp.Answer = append(resp.Answer, record)
+			}
+		}
+	}
+	_ = writer.WriteMsg(resp)
+}
+
+func (this *testRFC2136Server) provider(t *testing.T) *RFC2136Provider {
+	var provider = &RFC2136Provider{}
+	err := provider.Auth(maps.Map{
+		"server":  this.addr,
+		"domains": "example.com, example.org",
+		"keyName": "edge-default",
+		"secret":  testRFC2136DefaultSecret,
+		"views": []maps.Map{
+			{"name": "电信", "code": "telecom", "keyName": "edge-telecom", "secret": testRFC2136TelecomSecret},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestRFC2136Provider_Records(t *testing.T) {
+	var server = newTestRFC2136Server(t)
+	defer server.Close()
+
+	var provider = server.provider(t)
+
+	domains, err := provider.GetDomains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(domains, ",") != "example.com,example.org" {
+		t.Fatal("unexpected domains:", domains)
+	}
+
+	routes, err := provider.GetRoutes("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 || routes[1].Code != "telecom" {
+		t.Fatal("unexpected routes:", routes)
+	}
+
+	// 添加
+	for _, record := range []*dnstypes.Record{
+		{Name: "www", Type: dnstypes.RecordTypeA, Value: "2.2.2.2", Route: "telecom"},
+		{Name: "cdn", Type: dnstypes.RecordTypeCNAME, Value: "cluster.example.net", Route: ""},
+		{Name: "_acme-challenge", Type: dnstypes.RecordTypeTXT, Value: "token1"},
+	} {
+		err = provider.AddRecord("example.com", record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := provider.GetRecords("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys = []string{}
+	for _, record := range records {
+		keys = append(keys, record.Route+"/"+record.Name+"/"+record.Type+"/"+record.Value)
+	}
+	if strings.Join(keys, ",") != "default/www/A/1.1.1.1,default/cdn/CNAME/cluster.example.net.,default/_acme-challenge/TXT/token1,telecom/www/A/2.2.2.2" {
+		t.Fatal("unexpected records:", keys)
+	}
+
+	// 查询
+	txtRecords, err := provider.QueryRecords("example.com", "_acme-challenge", dnstypes.RecordTypeTXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txtRecords) != 1 || txtRecords[0].Value != "token1" {
+		t.Fatal("unexpected txt records:", txtRecords)
+	}
+
+	// 修改
+	err = provider.UpdateRecord("example.com", records[0], &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA, Value: "3.3.3.3", Route: "default", TTL: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := provider.QueryRecord("example.com", "www", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || record.Value != "3.3.3.3" || record.TTL != 300 || record.Route != "default" {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+
+	// 删除
+	err = provider.DeleteRecord("example.com", txtRecords[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err = provider.QueryRecord("example.com", "_acme-challenge", dnstypes.RecordTypeTXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record != nil {
+		t.Fatal("record should be deleted")
+	}
+
+	// 不存在的线路
+	err = provider.AddRecord("example.com", &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA, Value: "4.4.4.4", Route: "mobile"})
+	if err == nil {
+		t.Fatal("should fail with unknown route")
+	}
+}
+
+func TestRFC2136Provider_BadKey(t *testing.T) {
+	var server = newTestRFC2136Server(t)
+	defer server.Close()
+
+	var provider = &RFC2136Provider{}
+	err := provider.Auth(maps.Map{
+		"server":  server.addr,
+		"keyName": "edge-default",
+		"secret":  testRFC2136TelecomSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = provider.AddRecord("example.com", &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA, Value: "4.4.4.4"})
+	if err == nil {
+		t.Fatal("should fail with wrong secret")
+	}
+}
+
+func TestRFC2136Provider_Auth(t *testing.T) {
+	for _, params := range []maps.Map{
+		{"keyName": "k", "secret": testRFC2136DefaultSecret},
+		{"server": "127.0.0.1", "secret": testRFC2136DefaultSecret},
+		{"server": "127.0.0.1", "keyName": "k", "secret": "not base64!"},
+		{"server": "127.0.0.1", "keyName": "k", "secret": testRFC2136DefaultSecret, "algorithm": "hmac-md5"},
+		{"server": "127.0.0.1", "keyName": "k", "secret": testRFC2136DefaultSecret, "views": []maps.Map{{"code": "default", "keyName": "k2", "secret": testRFC2136DefaultSecret}}},
+	} {
+		var provider = &RFC2136Provider{}
+		if provider.Auth(params) == nil {
+			t.Fatal("should be invalid:", params)
+		}
+	}
+
+	var provider = &RFC2136Provider{}
+	err := provider.Auth(maps.Map{"server": "::1", "keyName": "k", "secret": testRFC2136DefaultSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.server != "[::1]:53" {
+		t.Fatal("unexpected server:", provider.server)
+	}
+
+	var params = maps.Map{"secret": testRFC2136DefaultSecret, "views": []any{map[string]any{"secret": testRFC2136TelecomSecret}}}
+	provider.MaskParams(params)
+	if !IsMasked(params.GetString("secret")) || !IsMasked(params.Get("views").([]any)[0].(map[string]any)["secret"].(string)) {
+		t.Fatal("secrets should be masked:", params)
+	}
+}
@@ -0,0 +1,709 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsclients
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/iwind/TeaGo/maps"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	Route53DefaultRoute  = "default"
+	Route53DefaultTTL    = 300
+	Route53DefaultRegion = "us-east-1"
+
+	route53GeoRoutePrefix     = "geo:"
+	route53LatencyRoutePrefix = "latency:"
+)
+
+// 地理位置列表是固定的，这里全局缓存
+var route53GeoRoutes []*dnstypes.Route
+var route53GeoRoutesLocker = &sync.Mutex{}
+
+// Route53Provider AWS Route 53
+// 线路对应Route 53的路由策略：
+//
+//	default                  简单路由
+//	geo:*                    地理位置路由的默认位置
+//	geo:continent:AS         地理位置路由，按大洲
+//	geo:country:CN           地理位置路由，按国家/地区
+//	geo:country:US:CA        地理位置路由，按州/省
+//	latency:us-east-1        延迟路由，按AWS区域
+//
+// 同一个名称和类型下Route 53不允许混用不同的路由策略，添加记录时会提前检查，冲突时返回错误
+type Route53Provider struct {
+	BaseProvider
+
+	ProviderId int64
+
+	client   *route53.Route53
+	endpoint string // 自定义API地址，用于测试
+
+	zoneMap    map[string]string // domain => hostedZoneId
+	zoneLocker sync.Mutex
+}
+
+// Auth 认证
+func (this *Route53Provider) Auth(params maps.Map) error {
+	var accessKeyId = params.GetString("accessKeyId")
+	var accessKeySecret = params.GetString("accessKeySecret")
+	if len(accessKeyId) == 0 {
+		return errors.New("'accessKeyId' should not be empty")
+	}
+	if len(accessKeySecret) == 0 {
+		return errors.New("'accessKeySecret' should not be empty")
+	}
+
+	var region = params.GetString("region")
+	if len(region) == 0 {
+		region = Route53DefaultRegion
+	}
+
+	var config = &aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials(accessKeyId, accessKeySecret, ""),
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+		MaxRetries:  aws.Int(2),
+	}
+	if len(this.endpoint) > 0 {
+		config.Endpoint = aws.String(this.endpoint)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return err
+	}
+	this.client = route53.New(sess)
+	this.zoneMap = map[string]string{}
+
+	return nil
+}
+
+// MaskParams 对参数进行掩码
+func (this *Route53Provider) MaskParams(params maps.Map) {
+	if params == nil {
+		return
+	}
+	params["accessKeySecret"] = MaskString(params.GetString("accessKeySecret"))
+}
+
+// GetDomains 获取所有域名列表
+func (this *Route53Provider) GetDomains() (domains []string, err error) {
+	err = this.client.ListHostedZonesPages(&route53.ListHostedZonesInput{}, func(output *route53.ListHostedZonesOutput, lastPage bool) bool {
+		for _, zone := range output.HostedZones {
+			if zone.Config != nil && aws.BoolValue(zone.Config.PrivateZone) {
+				continue
+			}
+			domains = append(domains, strings.TrimSuffix(aws.StringValue(zone.Name), "."))
+		}
+		return true
+	})
+	return
+}
+
+// GetRecords 获取域名解析记录列表
+func (this *Route53Provider) GetRecords(domain string) (records []*dnstypes.Record, err error) {
+	zoneId, err := this.findZoneIdWithDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.client.ListResourceRecordSetsPages(&route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneId),
+	}, func(output *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+		for _, recordSet := range output.ResourceRecordSets {
+			records = append(records, this.convertRecordSet(domain, recordSet)...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// GetRoutes 读取域名支持的线路数据
+func (this *Route53Provider) GetRoutes(domain string) (routes []*dnstypes.Route, err error) {
+	routes = []*dnstypes.Route{
+		{Name: "默认", Code: Route53DefaultRoute},
+	}
+
+	// 地理位置
+	geoRoutes, err := this.findGeoRoutes()
+	if err != nil {
+		return nil, err
+	}
+	routes = append(routes, geoRoutes...)
+
+	// 延迟
+	var regions = endpoints.AwsPartition().Regions()
+	var regionIds = []string{}
+	for regionId := range regions {
+		regionIds = append(regionIds, regionId)
+	}
+	sort.Strings(regionIds)
+	for _, regionId := range regionIds {
+		routes = append(routes, &dnstypes.Route{
+			Name: "延迟：" + regions[regionId].Description(),
+			Code: route53LatencyRoutePrefix + regionId,
+		})
+	}
+
+	return
+}
+
+// QueryRecord 查询单个记录
+func (this *Route53Provider) QueryRecord(domain string, name string, recordType dnstypes.RecordType) (*dnstypes.Record, error) {
+	records, err := this.QueryRecords(domain, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// QueryRecords 查询多个记录
+func (this *Route53Provider) QueryRecords(domain string, name string, recordType dnstypes.RecordType) (records []*dnstypes.Record, err error) {
+	zoneId, err := this.findZoneIdWithDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	recordSets, err := this.findRecordSets(zoneId, this.fqdn(domain, name), recordType)
+	if err != nil {
+		return nil, err
+	}
+	for _, recordSet := range recordSets {
+		records = append(records, this.convertRecordSet(domain, recordSet)...)
+	}
+	return records, nil
+}
+
+// AddRecord 设置记录
+func (this *Route53Provider) AddRecord(domain string, newRecord *dnstypes.Record) error {
+	zoneId, err := this.findZoneIdWithDomain(domain)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+
+	recordSets, err := this.findRecordSets(zoneId, this.fqdn(domain, newRecord.Name), newRecord.Type)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+	var recordSet = this.matchRecordSet(recordSets, newRecord.Route)
+
+	// 同一个记录集中增加一个值
+	var action = route53.ChangeActionUpsert
+	if recordSet == nil {
+		err = this.checkRoutePolicy(recordSets, newRecord.Route)
+		if err != nil {
+			return this.WrapError(err, domain, newRecord)
+		}
+		recordSet, err = this.newRecordSet(domain, newRecord)
+		if err != nil {
+			return this.WrapError(err, domain, newRecord)
+		}
+		action = route53.ChangeActionCreate
+	} else {
+		recordSet.ResourceRecords = append(recordSet.ResourceRecords, &route53.ResourceRecord{
+			Value: aws.String(this.encodeValue(newRecord.Type, newRecord.Value)),
+		})
+		if newRecord.TTL > 0 {
+			recordSet.TTL = aws.Int64(int64(newRecord.TTL))
+		}
+	}
+
+	return this.WrapError(this.change(zoneId, action, recordSet), domain, newRecord)
+}
+
+// UpdateRecord 修改记录
+func (this *Route53Provider) UpdateRecord(domain string, record *dnstypes.Record, newRecord *dnstypes.Record) error {
+	// 不在同一个记录集中时，先添加再删除
+	if !strings.EqualFold(record.Name, newRecord.Name) || record.Type != newRecord.Type || this.routeCode(record.Route) != this.routeCode(newRecord.Route) {
+		err := this.AddRecord(domain, newRecord)
+		if err != nil {
+			return err
+		}
+		return this.DeleteRecord(domain, record)
+	}
+
+	zoneId, err := this.findZoneIdWithDomain(domain)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+	recordSet, err := this.findRecordSet(zoneId, domain, record)
+	if err != nil {
+		return this.WrapError(err, domain, newRecord)
+	}
+	if recordSet == nil {
+		return this.AddRecord(domain, newRecord)
+	}
+
+	var resourceRecords = []*route53.ResourceRecord{}
+	for _, resourceRecord := range recordSet.ResourceRecords {
+		if !this.isSameValue(record.Type, aws.StringValue(resourceRecord.Value), record.Value) {
+			resourceRecords = append(resourceRecords, resourceRecord)
+		}
+	}
+	resourceRecords = append(resourceRecords, &route53.ResourceRecord{
+		Value: aws.String(this.encodeValue(newRecord.Type, newRecord.Value)),
+	})
+	recordSet.ResourceRecords = resourceRecords
+	if newRecord.TTL > 0 {
+		recordSet.TTL = aws.Int64(int64(newRecord.TTL))
+	}
+
+	return this.WrapError(this.change(zoneId, route53.ChangeActionUpsert, recordSet), domain, newRecord)
+}
+
+// DeleteRecord 删除记录
+func (this *Route53Provider) DeleteRecord(domain string, record *dnstypes.Record) error {
+	zoneId, err := this.findZoneIdWithDomain(domain)
+	if err != nil {
+		return this.WrapError(err, domain, record)
+	}
+	recordSet, err := this.findRecordSet(zoneId, domain, record)
+	if err != nil {
+		return this.WrapError(err, domain, record)
+	}
+	if recordSet == nil {
+		return nil
+	}
+
+	var resourceRecords = []*route53.ResourceRecord{}
+	for _, resourceRecord := range recordSet.ResourceRecords {
+		if !this.isSameValue(record.Type, aws.StringValue(resourceRecord.Value), record.Value) {
+			resourceRecords = append(resourceRecords, resourceRecord)
+		}
+	}
+	if len(resourceRecords) == len(recordSet.ResourceRecords) {
+		return nil
+	}
+
+	// 删除整个记录集时需要提供和原来完全一致的内容
+	if len(resourceRecords) == 0 {
+		return this.WrapError(this.change(zoneId, route53.ChangeActionDelete, recordSet), domain, record)
+	}
+
+	recordSet.ResourceRecords = resourceRecords
+	return this.WrapError(this.change(zoneId, route53.ChangeActionUpsert, recordSet), domain, record)
+}
+
+// DefaultRoute 默认线路
+func (this *Route53Provider) DefaultRoute() string {
+	return Route53DefaultRoute
+}
+
+// 提交修改
+func (this *Route53Provider) change(zoneId string, action string, recordSet *route53.ResourceRecordSet) error {
+	_, err := this.client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneId),
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{
+				{
+					Action:            aws.String(action),
+					ResourceRecordSet: recordSet,
+				},
+			},
+		},
+	})
+	return err
+}
+
+// 查找记录所在的记录集
+func (this *Route53Provider) findRecordSet(zoneId string, domain string, record *dnstypes.Record) (*route53.ResourceRecordSet, error) {
+	recordSets, err := this.findRecordSets(zoneId, this.fqdn(domain, record.Name), record.Type)
+	if err != nil {
+		return nil, err
+	}
+	return this.matchRecordSet(recordSets, record.Route), nil
+}
+
+// 从记录集中查找某个线路对应的记录集
+func (this *Route53Provider) matchRecordSet(recordSets []*route53.ResourceRecordSet, route string) *route53.ResourceRecordSet {
+	route = this.routeCode(route)
+	for _, recordSet := range recordSets {
+		routeCode, ok := this.recordSetRoute(recordSet)
+		if ok && routeCode == route {
+			return recordSet
+		}
+	}
+	return nil
+}
+
+// 检查新的线路是否和同名同类型的已有记录集使用相同的路由策略
+// 简单路由的记录集不能和其他路由策略的记录集同时存在，地理位置路由和延迟路由之间也不能混用
+func (this *Route53Provider) checkRoutePolicy(recordSets []*route53.ResourceRecordSet, route string) error {
+	var policy = this.routePolicy(this.routeCode(route))
+	for _, recordSet := range recordSets {
+		existingRoute, ok := this.recordSetRoute(recordSet)
+		if !ok {
+			// 加权、故障转移等其他路由策略
+			return errors.New("route '" + route + "' conflicts with the existing record set '" + aws.StringValue(recordSet.SetIdentifier) + "' which uses other routing policy")
+		}
+		if this.routePolicy(existingRoute) != policy {
+			return errors.New("route '" + route + "' conflicts with the existing route '" + existingRoute + "', the record sets with same name and type can not use different routing policies")
+		}
+	}
+	return nil
+}
+
+// 线路对应的路由策略
+func (this *Route53Provider) routePolicy(route string) string {
+	switch {
+	case strings.HasPrefix(route, route53GeoRoutePrefix):
+		return route53GeoRoutePrefix
+	case strings.HasPrefix(route, route53LatencyRoutePrefix):
+		return route53LatencyRoutePrefix
+	default:
+		return Route53DefaultRoute
+	}
+}
+
+// 查找某个名称和类型的所有记录集
+func (this *Route53Provider) findRecordSets(zoneId string, fqdn string, recordType dnstypes.RecordType) (recordSets []*route53.ResourceRecordSet, err error) {
+	err = this.client.ListResourceRecordSetsPages(&route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(zoneId),
+		StartRecordName: aws.String(fqdn),
+		StartRecordType: aws.String(recordType),
+	}, func(output *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+		for _, recordSet := range output.ResourceRecordSets {
+			// 结果按名称排序，遇到其他的名称或类型时结束
+			if !strings.EqualFold(this.unescapeName(aws.StringValue(recordSet.Name)), fqdn) || aws.StringValue(recordSet.Type) != recordType {
+				return false
+			}
+			recordSets = append(recordSets, recordSet)
+		}
+		return true
+	})
+	return
+}
+
+// 构造新的记录集
+func (this *Route53Provider) newRecordSet(domain string, record *dnstypes.Record) (*route53.ResourceRecordSet, error) {
+	var ttl = int64(record.TTL)
+	if ttl <= 0 {
+		ttl = Route53DefaultTTL
+	}
+	var recordSet = &route53.ResourceRecordSet{
+		Name: aws.String(this.fqdn(domain, record.Name)),
+		Type: aws.String(record.Type),
+		TTL:  aws.Int64(ttl),
+		ResourceRecords: []*route53.ResourceRecord{
+			{
+				Value: aws.String(this.encodeValue(record.Type, record.Value)),
+			},
+		},
+	}
+
+	var route = this.routeCode(record.Route)
+	switch {
+	case route == Route53DefaultRoute:
+	case strings.HasPrefix(route, route53GeoRoutePrefix):
+		var geoLocation = &route53.GeoLocation{}
+		var pieces = strings.Split(strings.TrimPrefix(route, route53GeoRoutePrefix), ":")
+		switch {
+		case len(pieces) == 1 && pieces[0] == "*":
+			geoLocation.CountryCode = aws.String("*")
+		case len(pieces) == 2 && pieces[0] == "continent":
+			geoLocation.ContinentCode = aws.String(pieces[1])
+		case len(pieces) == 2 && pieces[0] == "country":
+			geoLocation.CountryCode = aws.String(pieces[1])
+		case len(pieces) == 3 && pieces[0] == "country":
+			geoLocation.CountryCode = aws.String(pieces[1])
+			geoLocation.SubdivisionCode = aws.String(pieces[2])
+		default:
+			return nil, errors.New("invalid route '" + route + "'")
+		}
+		recordSet.GeoLocation = geoLocation
+		recordSet.SetIdentifier = aws.String(route)
+	case strings.HasPrefix(route, route53LatencyRoutePrefix):
+		recordSet.Region = aws.String(strings.TrimPrefix(route, route53LatencyRoutePrefix))
+		recordSet.SetIdentifier = aws.String(route)
+	default:
+		return nil, errors.New("invalid route '" + route + "'")
+	}
+	return recordSet, nil
+}
+
+// 转换记录集
+// 别名记录以及加权、故障转移等路由策略的记录集不在支持的范围内，会被忽略
+func (this *Route53Provider) convertRecordSet(domain string, recordSet *route53.ResourceRecordSet) (records []*dnstypes.Record) {
+	var recordType = aws.StringValue(recordSet.Type)
+	if recordType == "SOA" {
+		return nil
+	}
+
+	route, ok := this.recordSetRoute(recordSet)
+	if !ok {
+		return nil
+	}
+
+	var name = strings.TrimSuffix(this.unescapeName(aws.StringValue(recordSet.Name)), ".")
+	if strings.EqualFold(name, domain) {
+		name = "@"
+	} else {
+		name = strings.TrimSuffix(name, "."+domain)
+	}
+
+	for _, resourceRecord := range recordSet.ResourceRecords {
+		var value = this.decodeValue(recordType, aws.StringValue(resourceRecord.Value))
+		records = append(records, &dnstypes.Record{
+			Id:    route + "@" + name + "@" + recordType + "@" + value,
+			Name:  name,
+			Type:  recordType,
+			Value: value,
+			Route: route,
+			TTL:   int32(aws.Int64Value(recordSet.TTL)),
+		})
+	}
+	return
+}
+
+// 记录集对应的线路
+func (this *Route53Provider) recordSetRoute(recordSet *route53.ResourceRecordSet) (route string, ok bool) {
+	if recordSet.GeoLocation != nil {
+		var geoLocation = recordSet.GeoLocation
+		if len(aws.StringValue(geoLocation.ContinentCode)) > 0 {
+			return route53GeoRoutePrefix + "continent:" + aws.StringValue(geoLocation.ContinentCode), true
+		}
+		var countryCode = aws.StringValue(geoLocation.CountryCode)
+		if countryCode == "*" {
+			return route53GeoRoutePrefix + "*", true
+		}
+		if len(aws.StringValue(geoLocation.SubdivisionCode)) > 0 {
+			return route53GeoRoutePrefix + "country:" + countryCode + ":" + aws.StringValue(geoLocation.SubdivisionCode), true
+		}
+		return route53GeoRoutePrefix + "country:" + countryCode, true
+	}
+	if recordSet.Region != nil {
+		return route53LatencyRoutePrefix + aws.StringValue(recordSet.Region), true
+	}
+	if recordSet.SetIdentifier == nil {
+		return Route53DefaultRoute, true
+	}
+	return "", false
+}
+
+// 读取地理位置线路
+func (this *Route53Provider) findGeoRoutes() ([]*dnstypes.Route, error) {
+	route53GeoRoutesLocker.Lock()
+	defer route53GeoRoutesLocker.Unlock()
+	if len(route53GeoRoutes) > 0 {
+		return route53GeoRoutes, nil
+	}
+
+	var routes = []*dnstypes.Route{}
+	var input = &route53.ListGeoLocationsInput{}
+	for {
+		output, err := this.client.ListGeoLocations(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, location := range output.GeoLocationDetailsList {
+			var route = &dnstypes.Route{}
+			switch {
+			case len(aws.StringValue(location.ContinentCode)) > 0:
+				route.Name = "地理位置：" + aws.StringValue(location.ContinentName)
+				route.Code = route53GeoRoutePrefix + "continent:" + aws.StringValue(location.ContinentCode)
+			case aws.StringValue(location.CountryCode) == "*":
+				route.Name = "地理位置：默认"
+				route.Code = route53GeoRoutePrefix + "*"
+			case len(aws.StringValue(location.SubdivisionCode)) > 0:
+				route.Name = "地理位置：" + aws.StringValue(location.CountryName) + " - " + aws.StringValue(location.SubdivisionName)
+				route.Code = route53GeoRoutePrefix + "country:" + aws.StringValue(location.CountryCode) + ":" + aws.StringValue(location.SubdivisionCode)
+			default:
+				route.Name = "地理位置：" + aws.StringValue(location.CountryName)
+				route.Code = route53GeoRoutePrefix + "country:" + aws.StringValue(location.CountryCode)
+			}
+			routes = append(routes, route)
+		}
+		if !aws.BoolValue(output.IsTruncated) {
+			break
+		}
+		input = &route53.ListGeoLocationsInput{
+			StartContinentCode:   output.NextContinentCode,
+			StartCountryCode:     output.NextCountryCode,
+			StartSubdivisionCode: output.NextSubdivisionCode,
+		}
+	}
+
+	route53GeoRoutes = routes
+	return routes, nil
+}
+
+// 列出一个域名对应的托管区域
+func (this *Route53Provider) findZoneIdWithDomain(domain string) (zoneId string, err error) {
+	this.zoneLocker.Lock()
+	cacheZoneId, ok := this.zoneMap[domain]
+	this.zoneLocker.Unlock()
+	if ok {
+		return cacheZoneId, nil
+	}
+
+	output, err := this.client.ListHostedZonesByName(&route53.ListHostedZonesByNameInput{
+		DNSName:  aws.String(domain),
+		MaxItems: aws.String("10"),
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, zone := range output.HostedZones {
+		if !strings.EqualFold(aws.StringValue(zone.Name), domain+".") {
+			continue
+		}
+		if zone.Config != nil && aws.BoolValue(zone.Config.PrivateZone) {
+			continue
+		}
+		zoneId = strings.TrimPrefix(aws.StringValue(zone.Id), "/hostedzone/")
+		break
+	}
+	if len(zoneId) == 0 {
+		return "", errors.New("can not found hosted zone for domain '" + domain + "'")
+	}
+
+	this.zoneLocker.Lock()
+	this.zoneMap[domain] = zoneId
+	this.zoneLocker.Unlock()
+	return zoneId, nil
+}
+
+func (this *Route53Provider) routeCode(route string) string {
+	if len(route) == 0 {
+		return Route53DefaultRoute
+	}
+	return route
+}
+
+// 记录的完整域名
+func (this *Route53Provider) fqdn(domain string, name string) string {
+	if len(name) == 0 || name == "@" {
+		return domain + "."
+	}
+	return name + "." + domain + "."
+}
+
+// 解码名称中的转义字符，比如 \052 => *
+func (this *Route53Provider) unescapeName(name string) string {
+	if !strings.Contains(name, "\\") {
+		return name
+	}
+	var result = []byte{}
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) {
+			code, err := strconv.ParseUint(name[i+1:i+4], 8, 8)
+			if err == nil {
+				result = append(result, byte(code))
+				i += 3
+				continue
+			}
+		}
+		result = append(result, name[i])
+	}
+	return string(result)
+}
+
+// 转换为Route 53中的值
+func (this *Route53Provider) encodeValue(recordType dnstypes.RecordType, value string) string {
+	switch recordType {
+	case dnstypes.RecordTypeCNAME:
+		if !strings.HasSuffix(value, ".") {
+			value += "."
+		}
+	case dnstypes.RecordTypeTXT:
+		// 单个字符串不能超过255个字节
+		var pieces = []string{}
+		for {
+			var piece = value
+			if len(piece) > 255 {
+				piece = value[:255]
+			}
+			value = value[len(piece):]
+			pieces = append(pieces, "\""+strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(piece)+"\"")
+			if len(value) == 0 {
+				break
+			}
+		}
+		return strings.Join(pieces, " ")
+	}
+	return value
+}
+
+// 从Route 53中的值转换
+func (this *Route53Provider) decodeValue(recordType dnstypes.RecordType, value string) string {
+	switch recordType {
+	case dnstypes.RecordTypeCNAME:
+		if !strings.HasSuffix(value, ".") {
+			value += "."
+		}
+	case dnstypes.RecordTypeTXT:
+		var result = []byte{}
+		for _, piece := range splitQuotedStrings(value) {
+			piece = strings.TrimSuffix(strings.TrimPrefix(piece, "\""), "\"")
+			for i := 0; i < len(piece); i++ {
+				if piece[i] == '\\' && i+1 < len(piece) {
+					// \ddd 形式的八进制编码
+					if i+3 < len(piece) {
+						code, err := strconv.ParseUint(piece[i+1:i+4], 8, 8)
+						if err == nil {
+							result = append(result, byte(code))
+							i += 3
+							continue
+						}
+					}
+					i++
+				}
+				result = append(result, piece[i])
+			}
+		}
+		return string(result)
+	}
+	return value
+}
+
+// 判断Route 53中的值和记录值是否一致
+func (this *Route53Provider) isSameValue(recordType dnstypes.RecordType, route53Value string, value string) bool {
+	var decodedValue = this.decodeValue(recordType, route53Value)
+	if recordType == dnstypes.RecordTypeCNAME {
+		return strings.EqualFold(decodedValue, this.decodeValue(recordType, value))
+	}
+	return decodedValue == value
+}
+
+// 分割 "a" "b" 形式的字符串
+func splitQuotedStrings(s string) []string {
+	var result = []string{}
+	var inQuote = false
+	var start = -1
+	for i := 0; i < len(s); i++ {
+		var c = s[i]
+		switch {
+		case c == '\\' && inQuote:
+			i++
+		case c == '"':
+			if inQuote {
+				result = append(result, s[start:i+1])
+				inQuote = false
+			} else {
+				inQuote = true
+				start = i
+			}
+		}
+	}
+	if len(result) == 0 && len(s) > 0 {
+		result = append(result, s)
+	}
+	return result
+}
@@ -0,0 +1,327 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsclients
+
+import (
+	"encoding/xml"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testRoute53GeoLocation struct {
+	ContinentCode   string `xml:"ContinentCode,omitempty"`
+	CountryCode     string `xml:"CountryCode,omitempty"`
+	SubdivisionCode string `xml:"SubdivisionCode,omitempty"`
+}
+
+type testRoute53RecordSet struct {
+	Name            string                  `xml:"Name"`
+	Type            string                  `xml:"Type"`
+	SetIdentifier   string                  `xml:"SetIdentifier,omitempty"`
+	Weight          int64                   `xml:"Weight,omitempty"`
+	Region          string                  `xml:"Region,omitempty"`
+	GeoLocation     *testRoute53GeoLocation `xml:"GeoLocation,omitempty"`
+	TTL             int64                   `xml:"TTL,omitempty"`
+	ResourceRecords []testRoute53Value      `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type testRoute53Value struct {
+	Value string `xml:"Value"`
+}
+
+func (this *testRoute53RecordSet) key() string {
+	return strings.ToLower(this.Name) + "|" + this.Type + "|" + this.SetIdentifier
+}
+
+// 测试用的Route 53接口，只实现用到的几个API
+type testRoute53Server struct {
+	server *httptest.Server
+
+	locker     sync.Mutex
+	recordSets []*testRoute53RecordSet
+	changes    []string
+}
+
+func newTestRoute53Server() *testRoute53Server {
+	var s = &testRoute53Server{
+		recordSets: []*testRoute53RecordSet{
+			{Name: "example.com.", Type: "SOA", TTL: 900, ResourceRecords: []testRoute53Value{{"ns-1.awsdns-1.com. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400"}}},
+			{Name: "\\052.example.com.", Type: "A", TTL: 60, ResourceRecords: []testRoute53Value{{"9.9.9.9"}}},
+			{Name: "weighted.example.com.", Type: "A", SetIdentifier: "w1", Weight: 10, TTL: 60, ResourceRecords: []testRoute53Value{{"8.8.8.8"}}},
+		},
+	}
+
+	var mux = http.NewServeMux()
+	var writeXML = func(writer http.ResponseWriter, v any) {
+		writer.Header().Set("Content-Type", "text/xml")
+		data, _ := xml.Marshal(v)
+		_, _ = writer.Write(data)
+	}
+	var writeError = func(writer http.ResponseWriter, code string, message string) {
+		writer.Header().Set("Content-Type", "text/xml")
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>` + code + `</Code><Message>` + message + `</Message></Error></ErrorResponse>`))
+	}
+
+	var zones = `<HostedZones>
+<HostedZone><Id>/hostedzone/Z1</Id><Name>example.com.</Name><CallerReference>1</CallerReference><Config><PrivateZone>false</PrivateZone></Config></HostedZone>
+<HostedZone><Id>/hostedzone/Z2</Id><Name>example.com.</Name><CallerReference>2</CallerReference><Config><PrivateZone>true</PrivateZone></Config></HostedZone>
+</HostedZones>`
+	mux.HandleFunc("/2013-04-01/hostedzone", func(writer http.ResponseWriter, req *http.Request) {
+		_, _ = writer.Write([]byte(`<ListHostedZonesResponse>` + zones + `<IsTruncated>false</IsTruncated><MaxItems>100</MaxItems></ListHostedZonesResponse>`))
+	})
+	mux.HandleFunc("/2013-04-01/hostedzonesbyname", func(writer http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("dnsname") != "example.com" {
+			_, _ = writer.Write([]byte(`<ListHostedZonesByNameResponse><HostedZones></HostedZones><IsTruncated>false</IsTruncated><MaxItems>10</MaxItems></ListHostedZonesByNameResponse>`))
+			return
+		}
+		_, _ = writer.Write([]byte(`<ListHostedZonesByNameResponse>` + zones + `<IsTruncated>false</IsTruncated><MaxItems>10</MaxItems></ListHostedZonesByNameResponse>`))
+	})
+	mux.HandleFunc("/2013-04-01/geolocations", func(writer http.ResponseWriter, req *http.Request) {
+		// 分两页返回
+		if req.URL.Query().Get("startcountrycode") == "" {
+			_, _ = writer.Write([]byte(`<ListGeoLocationsResponse><GeoLocationDetailsList>
+<GeoLocationDetails><ContinentCode>AS</ContinentCode><ContinentName>Asia</ContinentName></GeoLocationDetails>
+<GeoLocationDetails><CountryCode>*</CountryCode><CountryName>Default</CountryName></GeoLocationDetails>
+</GeoLocationDetailsList><IsTruncated>true</IsTruncated><NextCountryCode>CN</NextCountryCode><MaxItems>2</MaxItems></ListGeoLocationsResponse>`))
+			return
+		}
+		_, _ = writer.Write([]byte(`<ListGeoLocationsResponse><GeoLocationDetailsList>
+<GeoLocationDetails><CountryCode>CN</CountryCode><CountryName>China</CountryName></GeoLocationDetails>
+<GeoLocationDetails><CountryCode>US</CountryCode><CountryName>United States</CountryName><SubdivisionCode>CA</SubdivisionCode><SubdivisionName>California</SubdivisionName></GeoLocationDetails>
+</GeoLocationDetailsList><IsTruncated>false</IsTruncated><MaxItems>2</MaxItems></ListGeoLocationsResponse>`))
+	})
+	mux.HandleFunc("/2013-04-01/hostedzone/Z1/rrset", func(writer http.ResponseWriter, req *http.Request) {
+		s.locker.Lock()
+		defer s.locker.Unlock()
+
+		// 按名称和类型排序，从指定的名称和类型开始返回
+		var startName = strings.ToLower(req.URL.Query().Get("name"))
+		var startKey = startName + "|" + req.URL.Query().Get("type")
+		sort.Slice(s.recordSets, func(i, j int) bool {
+			return s.recordSets[i].key() < s.recordSets[j].key()
+		})
+		var result = []*testRoute53RecordSet{}
+		for _, recordSet := range s.recordSets {
+			if len(startName) == 0 || recordSet.key() >= startKey {
+				result = append(result, recordSet)
+			}
+		}
+		writeXML(writer, &struct {
+			XMLName     xml.Name                `xml:"ListResourceRecordSetsResponse"`
+			RecordSets  []*testRoute53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+			IsTruncated bool                    `xml:"IsTruncated"`
+			MaxItems    int                     `xml:"MaxItems"`
+		}{RecordSets: result, MaxItems: 100})
+	})
+	mux.HandleFunc("/2013-04-01/hostedzone/Z1/rrset/", func(writer http.ResponseWriter, req *http.Request) {
+		s.locker.Lock()
+		defer s.locker.Unlock()
+
+		var changeReq = &struct {
+			Changes []struct {
+				Action    string                `xml:"Action"`
+				RecordSet *testRoute53RecordSet `xml:"ResourceRecordSet"`
+			} `xml:"ChangeBatch>Changes>Change"`
+		}{}
+		err := xml.NewDecoder(req.Body).Decode(changeReq)
+		if err != nil {
+			writeError(writer, "InvalidInput", err.Error())
+			return
+		}
+		for _, change := range changeReq.Changes {
+			var index = -1
+			for i, recordSet := range s.recordSets {
+				if recordSet.key() == change.RecordSet.key() {
+					index = i
+				}
+			}
+			switch change.Action {
+			case "CREATE":
+				if index >= 0 {
+					writeError(writer, "InvalidChangeBatch", "record set already exists")
+					return
+				}
+				s.recordSets = append(s.recordSets, change.RecordSet)
+			case "UPSERT":
+				if index >= 0 {
+					s.recordSets[index] = change.RecordSet
+				} else {
+					s.recordSets = append(s.recordSets, change.RecordSet)
+				}
+			case "DELETE":
+				// 删除时内容必须完全一致
+				if index < 0 || !reflect.DeepEqual(s.recordSets[index], change.RecordSet) {
+					writeError(writer, "InvalidChangeBatch", "record set not found")
+					return
+				}
+				s.recordSets = append(s.recordSets[:index], s.recordSets[index+1:]...)
+			}
+			s.changes = append(s.changes, change.Action)
+		}
+		_, _ = writer.Write([]byte(`<ChangeResourceRecordSetsResponse><ChangeInfo><Id>/change/C1</Id><Status>PENDING</Status><SubmittedAt>2024-01-01T00:00:00Z</SubmittedAt></ChangeInfo></ChangeResourceRecordSetsResponse>`))
+	})
+
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+func TestRoute53Provider(t *testing.T) {
+	var server = newTestRoute53Server()
+	defer server.server.Close()
+
+	var provider = &Route53Provider{endpoint: server.server.URL}
+	err := provider.Auth(maps.Map{
+		"accessKeyId":     "AKID",
+		"accessKeySecret": "SECRET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	domains, err := provider.GetDomains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(domains, ",") != "example.com" {
+		t.Fatal("unexpected domains:", domains)
+	}
+
+	routes, err := provider.GetRoutes("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var routeCodes = map[string]bool{}
+	for _, route := range routes {
+		routeCodes[route.Code] = true
+	}
+	for _, code := range []string{"default", "geo:continent:AS", "geo:*", "geo:country:CN", "geo:country:US:CA", "latency:us-east-1", "latency:ap-northeast-1"} {
+		if !routeCodes[code] {
+			t.Fatal("route '" + code + "' should exist")
+		}
+	}
+
+	// 添加
+	for _, record := range []*dnstypes.Record{
+		{Name: "www", Type: dnstypes.RecordTypeA, Value: "1.1.1.1", Route: ""},
+		{Name: "www", Type: dnstypes.RecordTypeA, Value: "2.2.2.2", Route: "default"},
+		{Name: "img", Type: dnstypes.RecordTypeA, Value: "3.3.3.3", Route: "geo:country:CN"},
+		{Name: "api", Type: dnstypes.RecordTypeA, Value: "5.5.5.5", Route: "latency:ap-northeast-1"},
+		{Name: "cdn", Type: dnstypes.RecordTypeCNAME, Value: "cluster.example.net", Route: "default"},
+		{Name: "_acme-challenge", Type: dnstypes.RecordTypeTXT, Value: `token "1"` + strings.Repeat("a", 300), Route: "default"},
+	} {
+		err = provider.AddRecord("example.com", record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(server.changes, ",") != "CREATE,UPSERT,CREATE,CREATE,CREATE,CREATE" {
+		t.Fatal("unexpected changes:", server.changes)
+	}
+
+	records, err := provider.GetRecords("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys = []string{}
+	for _, record := range records {
+		var value = record.Value
+		if len(value) > 20 {
+			value = value[:20]
+		}
+		keys = append(keys, record.Route+"/"+record.Name+"/"+record.Type+"/"+value)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != `default/*/A/9.9.9.9,default/_acme-challenge/TXT/token "1"aaaaaaaaaaa,default/cdn/CNAME/cluster.example.net.,default/www/A/1.1.1.1,default/www/A/2.2.2.2,geo:country:CN/img/A/3.3.3.3,latency:ap-northeast-1/api/A/5.5.5.5` {
+		t.Fatal("unexpected records:", keys)
+	}
+
+	// 查询
+	txtRecord, err := provider.QueryRecord("example.com", "_acme-challenge", dnstypes.RecordTypeTXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txtRecord == nil || txtRecord.Value != `token "1"`+strings.Repeat("a", 300) {
+		t.Fatalf("unexpected txt record: %+v", txtRecord)
+	}
+
+	// 修改
+	err = provider.UpdateRecord("example.com", &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA, Value: "1.1.1.1", Route: "default"}, &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA, Value: "4.4.4.4", Route: "default", TTL: 120})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wwwRecords, err := provider.QueryRecords("example.com", "www", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values = []string{}
+	for _, record := range wwwRecords {
+		if record.Route == "default" {
+			values = append(values, record.Value)
+			if record.TTL != 120 {
+				t.Fatal("ttl should be updated")
+			}
+		}
+	}
+	sort.Strings(values)
+	if strings.Join(values, ",") != "2.2.2.2,4.4.4.4" {
+		t.Fatal("unexpected values:", values)
+	}
+
+	// 删除，最后一个值被删除时删除整个记录集
+	server.changes = nil
+	for _, record := range []*dnstypes.Record{
+		{Name: "www", Type: dnstypes.RecordTypeA, Value: "2.2.2.2", Route: "default"},
+		{Name: "www", Type: dnstypes.RecordTypeA, Value: "4.4.4.4", Route: "default"},
+		{Name: "img", Type: dnstypes.RecordTypeA, Value: "3.3.3.3", Route: "geo:country:CN"},
+		txtRecord,
+	} {
+		err = provider.DeleteRecord("example.com", record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(server.changes, ",") != "UPSERT,DELETE,DELETE,DELETE" {
+		t.Fatal("unexpected changes:", server.changes)
+	}
+
+	// 同名同类型的记录集不能混用不同的路由策略
+	server.changes = nil
+	for _, record := range []*dnstypes.Record{
+		{Name: "cdn", Type: dnstypes.RecordTypeCNAME, Value: "cluster2.example.net", Route: "geo:country:CN"},
+		{Name: "api", Type: dnstypes.RecordTypeA, Value: "6.6.6.6", Route: "default"},
+		{Name: "api", Type: dnstypes.RecordTypeA, Value: "6.6.6.6", Route: "geo:*"},
+		{Name: "weighted", Type: dnstypes.RecordTypeA, Value: "6.6.6.6", Route: "default"},
+	} {
+		err = provider.AddRecord("example.com", record)
+		if err == nil {
+			t.Fatal("should fail with different routing policies:", record.Name, record.Route)
+		}
+	}
+	if len(server.changes) > 0 {
+		t.Fatal("should not submit any changes:", server.changes)
+	}
+	err = provider.AddRecord("example.com", &dnstypes.Record{Name: "api", Type: dnstypes.RecordTypeA, Value: "6.6.6.6", Route: "latency:us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不存在的线路
+	err = provider.AddRecord("example.com", &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA, Value: "6.6.6.6", Route: "weighted:w1"})
+	if err == nil {
+		t.Fatal("should fail with invalid route")
+	}
+
+	// 不存在的域名
+	_, err = provider.GetRecords("example.org")
+	if err == nil {
+		t.Fatal("should fail with unknown domain")
+	}
+}
@@ -11,16 +11,24 @@ import (
 	tencenterrors "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
 	dnspod "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/dnspod/v20210323"
+	"net/url"
 	"strings"
 )
 
+const (
+	TencentDNSDefaultTTL   = 600
+	TencentDNSMaxPageLimit = 1000
+)
+
 // TencentDNSProvider 腾讯云DNS云解析
+// 使用腾讯云API 3.0签名，线路代号为腾讯云的线路ID
 type TencentDNSProvider struct {
 	BaseProvider
 
 	ProviderId int64
 
-	client *dnspod.Client
+	client   *dnspod.Client
+	endpoint string // 自定义API地址，用于测试
 }
 
 func NewTencentDNSProvider() *TencentDNSProvider {
@@ -38,7 +46,18 @@ func (this *TencentDNSProvider) Auth(params maps.Map) error {
 		return errors.New("'accessKeySecret' required")
 	}
 
-	client, err := dnspod.NewClient(common.NewCredential(accessKeyId, accessKeySecret), "", profile.NewClientProfile())
+	var clientProfile = profile.NewClientProfile()
+	clientProfile.HttpProfile.ReqTimeout = 10
+	if len(this.endpoint) > 0 {
+		u, err := url.Parse(this.endpoint)
+		if err != nil {
+			return err
+		}
+		clientProfile.HttpProfile.Scheme = strings.ToUpper(u.Scheme)
+		clientProfile.HttpProfile.Endpoint = u.Host
+	}
+
+	client, err := dnspod.NewClient(common.NewCredential(accessKeyId, accessKeySecret), "", clientProfile)
 	if err != nil {
 		return err
 	}
@@ -58,7 +77,7 @@ func (this *TencentDNSProvider) MaskParams(params maps.Map) {
 // GetDomains 获取所有域名列表
 func (this *TencentDNSProvider) GetDomains() (domains []string, err error) {
 	var offset int64 = 0
-	var limit int64 = 1000
+	var limit int64 = TencentDNSMaxPageLimit
 	for {
 		var req = dnspod.NewDescribeDomainListRequest()
 		req.Offset = this.int64Val(offset)
@@ -78,6 +97,11 @@ func (this *TencentDNSProvider) GetDomains() (domains []string, err error) {
 			domains = append(domains, *domainObj.Name)
 		}
 		offset += int64(countDomains)
+
+		// 检查是否到头
+		if resp.Response.DomainCountInfo != nil && resp.Response.DomainCountInfo.AllTotal != nil && uint64(offset) >= *resp.Response.DomainCountInfo.AllTotal {
+			break
+		}
 	}
 
 	return
@@ -86,7 +110,7 @@ func (this *TencentDNSProvider) GetDomains() (domains []string, err error) {
 // GetRecords 获取域名列表
 func (this *TencentDNSProvider) GetRecords(domain string) (records []*dnstypes.Record, err error) {
 	var offset uint64 = 0
-	var limit uint64 = 1000
+	var limit uint64 = TencentDNSMaxPageLimit
 	for {
 		var req = dnspod.NewDescribeRecordListRequest()
 		req.Domain = this.stringVal(domain)
@@ -104,16 +128,14 @@ func (this *TencentDNSProvider) GetRecords(domain string) (records []*dnstypes.R
 			break
 		}
 		for _, recordObj := range resp.Response.RecordList {
-			records = append(records, &dnstypes.Record{
-				Id:    types.String(*recordObj.RecordId),
-				Name:  *recordObj.Name,
-				Type:  *recordObj.Type,
-				Value: this.fixCNAME(*recordObj.Type, *recordObj.Value),
-				Route: *recordObj.LineId,
-				TTL:   types.Int32(*recordObj.TTL),
-			})
+			records = append(records, this.convertRecord(recordObj))
 		}
 		offset += uint64(countRecords)
+
+		// 检查是否到头
+		if this.isLastPage(resp.Response.RecordCountInfo, offset) {
+			break
+		}
 	}
 
 	// 写入缓存
@@ -154,12 +176,18 @@ func (this *TencentDNSProvider) GetRoutes(domain string) (routes []*dnstypes.Rou
 			return nil, respErr
 		}
 		for _, lineGroupObj := range resp.Response.LineGroupList {
+			if lineGroupObj.LineId == nil || lineGroupObj.Name == nil {
+				continue
+			}
 			routes = append(routes, &dnstypes.Route{
 				Name: "Group:" + *lineGroupObj.Name,
 				Code: *lineGroupObj.LineId,
 			})
 		}
 		for _, lineObj := range resp.Response.LineList {
+			if lineObj.LineId == nil || lineObj.Name == nil {
+				continue
+			}
 			routes = append(routes, &dnstypes.Route{
 				Name: *lineObj.Name,
 				Code: *lineObj.LineId,
@@ -180,13 +208,12 @@ func (this *TencentDNSProvider) QueryRecord(domain string, name string, recordTy
 		}
 	}
 
-	var offset uint64 = 0
-	var limit uint64 = 1000
 	var req = dnspod.NewDescribeRecordFilterListRequest()
 	req.Domain = this.stringVal(domain)
-	req.Offset = this.uint64Val(offset)
-	req.Limit = this.uint64Val(limit)
+	req.Offset = this.uint64Val(0)
+	req.Limit = this.uint64Val(TencentDNSMaxPageLimit)
 	req.SubDomain = this.stringVal(name)
+	req.IsExactSubDomain = this.boolVal(true)
 	req.RecordType = []*string{this.stringVal(recordType)}
 	resp, respErr := this.client.DescribeRecordFilterList(req)
 	if respErr != nil {
@@ -195,20 +222,9 @@ func (this *TencentDNSProvider) QueryRecord(domain string, name string, recordTy
 		}
 		return nil, respErr
 	}
-	var countRecords = len(resp.Response.RecordList)
-	if countRecords == 0 {
-		return nil, nil
-	}
 	for _, recordObj := range resp.Response.RecordList {
 		if *recordObj.Name == name && *recordObj.Type == recordType {
-			return &dnstypes.Record{
-				Id:    types.String(*recordObj.RecordId),
-				Name:  *recordObj.Name,
-				Type:  *recordObj.Type,
-				Value: this.fixCNAME(*recordObj.Type, *recordObj.Value),
-				Route: *recordObj.LineId,
-				TTL:   types.Int32(*recordObj.TTL),
-			}, nil
+			return this.convertRecord(recordObj), nil
 		}
 	}
 
@@ -226,14 +242,15 @@ func (this *TencentDNSProvider) QueryRecords(domain string, name string, recordT
 	}
 
 	var offset uint64 = 0
-	var limit uint64 = 1000
-	var records []*dnstypes.Record
+	var limit uint64 = TencentDNSMaxPageLimit
+	var records = []*dnstypes.Record{}
 	for {
 		var req = dnspod.NewDescribeRecordFilterListRequest()
 		req.Domain = this.stringVal(domain)
 		req.Offset = this.uint64Val(offset)
 		req.Limit = this.uint64Val(limit)
 		req.SubDomain = this.stringVal(name)
+		req.IsExactSubDomain = this.boolVal(true)
 		req.RecordType = []*string{this.stringVal(recordType)}
 		resp, respErr := this.client.DescribeRecordFilterList(req)
 		if respErr != nil {
@@ -247,16 +264,16 @@ func (this *TencentDNSProvider) QueryRecords(domain string, name string, recordT
 			break
 		}
 		for _, recordObj := range resp.Response.RecordList {
-			records = append(records, &dnstypes.Record{
-				Id:    types.String(*recordObj.RecordId),
-				Name:  *recordObj.Name,
-				Type:  *recordObj.Type,
-				Value: this.fixCNAME(*recordObj.Type, *recordObj.Value),
-				Route: *recordObj.LineId,
-				TTL:   types.Int32(*recordObj.TTL),
-			})
+			if *recordObj.Name == name && *recordObj.Type == recordType {
+				records = append(records, this.convertRecord(recordObj))
+			}
 		}
 		offset += uint64(countRecords)
+
+		// 检查是否到头
+		if this.isLastPage(resp.Response.RecordCountInfo, offset) {
+			break
+		}
 	}
 
 	return records, nil
@@ -273,9 +290,14 @@ func (this *TencentDNSProvider) AddRecord(domain string, newRecord *dnstypes.Rec
 		newRecord.Value += "."
 	}
 
+	var route = newRecord.Route
+	if len(route) == 0 {
+		route = this.DefaultRoute()
+	}
+
 	var ttl = newRecord.TTL
 	if ttl <= 0 {
-		ttl = 600
+		ttl = TencentDNSDefaultTTL
 	}
 
 	var req = dnspod.NewCreateRecordRequest()
@@ -284,13 +306,14 @@ func (this *TencentDNSProvider) AddRecord(domain string, newRecord *dnstypes.Rec
 	req.RecordType = this.stringVal(newRecord.Type)
 	req.TTL = this.uint64Val(uint64(ttl))
 	req.RecordLine = this.stringVal(this.DefaultRouteName()) // 默认必填项，但以RecordLineId优先
-	req.RecordLineId = this.stringVal(newRecord.Route)
+	req.RecordLineId = this.stringVal(route)
 	req.Value = this.stringVal(newRecord.Value)
 	resp, respErr := this.client.CreateRecord(req)
 	if respErr != nil {
-		return respErr
+		return this.WrapError(respErr, domain, newRecord)
 	}
 	newRecord.Id = types.String(*resp.Response.RecordId)
+	newRecord.Route = route
 
 	// 加入缓存
 	if this.ProviderId > 0 {
@@ -321,7 +344,7 @@ func (this *TencentDNSProvider) UpdateRecord(domain string, record *dnstypes.Rec
 
 	var ttl = newRecord.TTL
 	if ttl <= 0 {
-		ttl = 600
+		ttl = TencentDNSDefaultTTL
 	}
 
 	var req = dnspod.NewModifyRecordRequest()
@@ -331,13 +354,16 @@ func (this *TencentDNSProvider) UpdateRecord(domain string, record *dnstypes.Rec
 	req.RecordType = this.stringVal(newRecord.Type)
 	req.TTL = this.uint64Val(uint64(ttl))
 	req.RecordLine = this.stringVal(this.DefaultRouteName()) // 默认必填项，但以RecordLineId优先
-	req.RecordLineId = this.stringVal(newRecord.Route)
+	req.RecordLineId = this.stringVal(newRoute)
 	req.Value = this.stringVal(newRecord.Value)
 	_, respErr := this.client.ModifyRecord(req)
 	if respErr != nil {
-		return respErr
+		return this.WrapError(respErr, domain, newRecord)
 	}
 
+	newRecord.Id = record.Id
+	newRecord.Route = newRoute
+
 	// 修改缓存
 	if this.ProviderId > 0 {
 		sharedDomainRecordsCache.UpdateDomainRecord(this.ProviderId, domain, newRecord)
@@ -360,7 +386,7 @@ func (this *TencentDNSProvider) DeleteRecord(domain string, record *dnstypes.Rec
 		if len(record.Id) > 0 && this.isRecordInvalidErr(respErr) {
 			return nil
 		}
-		return respErr
+		return this.WrapError(respErr, domain, record)
 	}
 
 	// 删除缓存
@@ -380,6 +406,23 @@ func (this *TencentDNSProvider) DefaultRouteName() string {
 	return "默认"
 }
 
+// 转换记录
+func (this *TencentDNSProvider) convertRecord(recordObj *dnspod.RecordListItem) *dnstypes.Record {
+	return &dnstypes.Record{
+		Id:    types.String(*recordObj.RecordId),
+		Name:  *recordObj.Name,
+		Type:  *recordObj.Type,
+		Value: this.fixCNAME(*recordObj.Type, *recordObj.Value),
+		Route: *recordObj.LineId,
+		TTL:   types.Int32(*recordObj.TTL),
+	}
+}
+
+// 检查是否已经读取到最后一页
+func (this *TencentDNSProvider) isLastPage(countInfo *dnspod.RecordCountInfo, offset uint64) bool {
+	return countInfo != nil && countInfo.TotalCount != nil && offset >= *countInfo.TotalCount
+}
+
 func (this *TencentDNSProvider) fixCNAME(recordType string, recordValue string) string {
 	// 修正Record
 	if strings.ToUpper(recordType) == dnstypes.RecordTypeCNAME && !strings.HasSuffix(recordValue, ".") {
@@ -400,6 +443,10 @@ func (this *TencentDNSProvider) stringVal(s string) *string {
 	return &s
 }
 
+func (this *TencentDNSProvider) boolVal(b bool) *bool {
+	return &b
+}
+
 func (this *TencentDNSProvider) isNotFoundErr(err error) bool {
 	if err == nil {
 		return false
@@ -0,0 +1,320 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package dnsclients
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 测试用的腾讯云DNS接口，只实现用到的几个API
+type testTencentDNSServer struct {
+	server *httptest.Server
+
+	locker       sync.Mutex
+	records      []maps.Map
+	lastRecordId int64
+	actions      []string
+}
+
+func newTestTencentDNSServer(countRecords int) *testTencentDNSServer {
+	var s = &testTencentDNSServer{}
+	for i := 0; i < countRecords; i++ {
+		s.lastRecordId++
+		s.records = append(s.records, maps.Map{"RecordId": s.lastRecordId, "Name": "r" + types.String(i), "Type": "A", "Value": "1.1.1.1", "Line": "默认", "LineId": "0", "TTL": 600})
+	}
+
+	var writeResponse = func(writer http.ResponseWriter, response maps.Map) {
+		response["RequestId"] = "request1"
+		data, _ := json.Marshal(maps.Map{"Response": response})
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(data)
+	}
+	var writeError = func(writer http.ResponseWriter, code string) {
+		writeResponse(writer, maps.Map{"Error": maps.Map{"Code": code, "Message": code}})
+	}
+	var page = func(records []maps.Map, params maps.Map) []maps.Map {
+		var offset = params.GetInt("Offset")
+		var limit = params.GetInt("Limit")
+		if offset >= len(records) {
+			return nil
+		}
+		if offset+limit > len(records) {
+			limit = len(records) - offset
+		}
+		return records[offset : offset+limit]
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "TC3-HMAC-SHA256 Credential=AKID/") {
+			writeError(writer, "AuthFailure.SignatureFailure")
+			return
+		}
+
+		var params = maps.Map{}
+		err := json.NewDecoder(req.Body).Decode(&params)
+		if err != nil {
+			writeError(writer, "InvalidParameter")
+			return
+		}
+
+		s.locker.Lock()
+		defer s.locker.Unlock()
+
+		var action = req.Header.Get("X-TC-Action")
+		s.actions = append(s.actions, action)
+
+		if action != "DescribeDomainList" && params.GetString("Domain") != "example.com" {
+			writeError(writer, "ResourceNotFound.NoDataOfDomain")
+			return
+		}
+
+		switch action {
+		case "DescribeDomainList":
+			var domains = []maps.Map{}
+			for i := 0; i < 3; i++ {
+				domains = append(domains, maps.Map{"Name": "example" + types.String(i) + ".com"})
+			}
+			domains[0]["Name"] = "example.com"
+			writeResponse(writer, maps.Map{"DomainCountInfo": maps.Map{"AllTotal": len(domains)}, "DomainList": page(domains, params)})
+		case "DescribeDomain":
+			writeResponse(writer, maps.Map{"DomainInfo": maps.Map{"Domain": "example.com", "Grade": "DP_FREE"}})
+		case "DescribeRecordLineList":
+			if params.GetString("DomainGrade") != "DP_FREE" {
+				writeError(writer, "InvalidParameter.DomainGradeInvalid")
+				return
+			}
+			writeResponse(writer, maps.Map{
+				"LineList":      []maps.Map{{"Name": "默认", "LineId": "0"}, {"Name": "电信", "LineId": "10=0"}},
+				"LineGroupList": []maps.Map{{"Name": "华东", "LineId": "80=1", "Type": "custom", "LineList": []string{"上海"}}},
+			})
+		case "DescribeRecordList":
+			writeResponse(writer, maps.Map{"RecordCountInfo": maps.Map{"TotalCount": len(s.records), "ListCount": len(page(s.records, params))}, "RecordList": page(s.records, params)})
+		case "DescribeRecordFilterList":
+			var result = []maps.Map{}
+			for _, record := range s.records {
+				var recordType = record.GetString("Type")
+				var recordTypes = params.GetSlice("RecordType")
+				if len(recordTypes) > 0 && recordTypes[0] != recordType {
+					continue
+				}
+				if params.GetBool("IsExactSubDomain") && record.GetString("Name") != params.GetString("SubDomain") {
+					continue
+				}
+				if !strings.Contains(record.GetString("Name"), params.GetString("SubDomain")) {
+					continue
+				}
+				result = append(result, record)
+			}
+			if len(result) == 0 {
+				writeError(writer, "ResourceNotFound.NoDataOfRecord")
+				return
+			}
+			writeResponse(writer, maps.Map{"RecordCountInfo": maps.Map{"TotalCount": len(result)}, "RecordList": page(result, params)})
+		case "CreateRecord", "ModifyRecord":
+			if len(params.GetString("RecordLineId")) == 0 {
+				writeError(writer, "InvalidParameter.RecordLineInvalid")
+				return
+			}
+			var record = maps.Map{
+				"Name":   params.GetString("SubDomain"),
+				"Type":   params.GetString("RecordType"),
+				"Value":  params.GetString("Value"),
+				"LineId": params.GetString("RecordLineId"),
+				"TTL":    params.GetInt("TTL"),
+			}
+			if action == "CreateRecord" {
+				s.lastRecordId++
+				record["RecordId"] = s.lastRecordId
+				s.records = append(s.records, record)
+			} else {
+				var found = false
+				for index, oldRecord := range s.records {
+					if oldRecord.GetInt64("RecordId") == params.GetInt64("RecordId") {
+						record["RecordId"] = oldRecord.GetInt64("RecordId")
+						s.records[index] = record
+						found = true
+					}
+				}
+				if !found {
+					writeError(writer, "InvalidParameter.RecordIdInvalid")
+					return
+				}
+			}
+			writeResponse(writer, maps.Map{"RecordId": record.GetInt64("RecordId")})
+		case "DeleteRecord":
+			var newRecords = []maps.Map{}
+			var found = false
+			for _, record := range s.records {
+				if record.GetInt64("RecordId") == params.GetInt64("RecordId") {
+					found = true
+					continue
+				}
+				newRecords = append(newRecords, record)
+			}
+			if !found {
+				writeError(writer, "InvalidParameter.RecordIdInvalid")
+				return
+			}
+			s.records = newRecords
+			writeResponse(writer, maps.Map{})
+		default:
+			writeError(writer, "UnsupportedOperation")
+		}
+	}))
+	return s
+}
+
+func (this *testTencentDNSServer) provider(t *testing.T) *TencentDNSProvider {
+	var provider = &TencentDNSProvider{endpoint: this.server.URL}
+	err := provider.Auth(maps.Map{
+		"accessKeyId":     "AKID",
+		"accessKeySecret": "SECRET",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestTencentDNSProvider_Pagination(t *testing.T) {
+	var server = newTestTencentDNSServer(TencentDNSMaxPageLimit*2 + 5)
+	defer server.server.Close()
+
+	var provider = server.provider(t)
+
+	domains, err := provider.GetDomains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(domains) != 3 || domains[0] != "example.com" {
+		t.Fatal("unexpected domains:", domains)
+	}
+
+	server.actions = nil
+	records, err := provider.GetRecords("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != TencentDNSMaxPageLimit*2+5 {
+		t.Fatal("unexpected records count:", len(records))
+	}
+	if len(server.actions) != 3 {
+		t.Fatal("should stop after the last page:", server.actions)
+	}
+
+	// 不存在的域名
+	records, err = provider.GetRecords("example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatal("records should be empty")
+	}
+}
+
+func TestTencentDNSProvider_Records(t *testing.T) {
+	var server = newTestTencentDNSServer(0)
+	defer server.server.Close()
+
+	var provider = server.provider(t)
+
+	routes, err := provider.GetRoutes("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var routeStrings = []string{}
+	for _, route := range routes {
+		routeStrings = append(routeStrings, route.Code+"/"+route.Name)
+	}
+	if strings.Join(routeStrings, ",") != "80=1/Group:华东,0/默认,10=0/电信" {
+		t.Fatal("unexpected routes:", routeStrings)
+	}
+
+	// 添加
+	for _, record := range []*dnstypes.Record{
+		{Name: "www", Type: dnstypes.RecordTypeA, Value: "1.1.1.1", Route: ""},
+		{Name: "www", Type: dnstypes.RecordTypeA, Value: "2.2.2.2", Route: "10=0", TTL: 60},
+		{Name: "www1", Type: dnstypes.RecordTypeA, Value: "3.3.3.3", Route: "0"},
+		{Name: "cdn", Type: dnstypes.RecordTypeCNAME, Value: "cluster.example.net", Route: "80=1"},
+	} {
+		err = provider.AddRecord("example.com", record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(record.Id) == 0 {
+			t.Fatal("record id should be set")
+		}
+	}
+
+	records, err := provider.GetRecords("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys = []string{}
+	for _, record := range records {
+		keys = append(keys, record.Route+"/"+record.Name+"/"+record.Type+"/"+record.Value+"/"+types.String(record.TTL))
+	}
+	if strings.Join(keys, ",") != "0/www/A/1.1.1.1/600,10=0/www/A/2.2.2.2/60,0/www1/A/3.3.3.3/600,80=1/cdn/CNAME/cluster.example.net./600" {
+		t.Fatal("unexpected records:", keys)
+	}
+
+	// 查询，子域名需要精确匹配
+	wwwRecords, err := provider.QueryRecords("example.com", "www", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wwwRecords) != 2 {
+		t.Fatal("unexpected www records:", wwwRecords)
+	}
+	noneRecords, err := provider.QueryRecords("example.com", "none", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(noneRecords) != 0 {
+		t.Fatal("records should be empty")
+	}
+
+	// 修改，空线路使用默认线路
+	var newRecord = &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA, Value: "4.4.4.4"}
+	err = provider.UpdateRecord("example.com", wwwRecords[1], newRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newRecord.Id != wwwRecords[1].Id || newRecord.Route != "0" {
+		t.Fatalf("unexpected new record: %+v", newRecord)
+	}
+	record, err := provider.QueryRecord("example.com", "www1", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || record.Value != "3.3.3.3" {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+
+	// 删除
+	err = provider.DeleteRecord("example.com", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err = provider.QueryRecord("example.com", "www1", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record != nil {
+		t.Fatal("record should be deleted")
+	}
+
+	// 已经删除的记录
+	err = provider.DeleteRecord("example.com", &dnstypes.Record{Id: "100", Name: "www1", Type: dnstypes.RecordTypeA})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -9,12 +9,16 @@ type ProviderType = string
 // 服务商代号
 const (
 	ProviderTypeDNSPod       ProviderType = "dnspod"       // DNSPod
+	ProviderTypeTencentDNS   ProviderType = "tencentDNS"   // 腾讯云DNS（API 3.0）
 	ProviderTypeAliDNS       ProviderType = "alidns"       // 阿里云DNS
 	ProviderTypeHuaweiDNS    ProviderType = "huaweiDNS"    // 华为DNS
 	ProviderTypeCloudFlare   ProviderType = "cloudFlare"   // CloudFlare DNS
 	ProviderTypeLocalEdgeDNS ProviderType = "localEdgeDNS" // 和当前系统集成的EdgeDNS
 	ProviderTypeEdgeDNSAPI   ProviderType = "edgeDNSAPI"   // 通过API连接的EdgeDNS
 	ProviderTypeCustomHTTP   ProviderType = "customHTTP"   // 自定义HTTP接口
+	ProviderTypeRFC2136      ProviderType = "rfc2136"      // 支持RFC 2136动态更新的DNS服务
+	ProviderTypeRoute53      ProviderType = "route53"      // AWS Route 53
+	ProviderTypeAzureDNS     ProviderType = "azureDNS"     // Azure DNS
 )
 
 // FindAllProviderTypes 所有的服务商类型
@@ -30,6 +34,11 @@ func FindAllProviderTypes() []maps.Map {
 			"code":        ProviderTypeDNSPod,
 			"description": "DNSPod提供的DNS服务。",
 		},
+		{
+			"name":        "腾讯云DNS",
+			"code":        ProviderTypeTencentDNS,
+			"description": "腾讯云DNS解析服务，使用腾讯云API密钥（SecretId/SecretKey）认证。",
+		},
 		{
 			"name":        "华为云DNS",
 			"code":        ProviderTypeHuaweiDNS,
@@ -45,6 +54,21 @@ func FindAllProviderTypes() []maps.Map {
 			"code":        ProviderTypeEdgeDNSAPI,
 			"description": "通过API连接GoEdge商业版系统提供的DNS服务。",
 		},
+		{
+			"name":        "AWS Route 53",
+			"code":        ProviderTypeRoute53,
+			"description": "亚马逊AWS提供的DNS服务，支持地理位置和延迟路由。",
+		},
+		{
+			"name":        "Azure DNS",
+			"code":        ProviderTypeAzureDNS,
+			"description": "微软Azure提供的DNS服务。",
+		},
+		{
+			"name":        "RFC 2136动态更新",
+			"code":        ProviderTypeRFC2136,
+			"description": "通过TSIG签名的RFC 2136动态更新协议连接自建的BIND、PowerDNS、Knot等权威DNS服务。",
+		},
 	}
 
 	typeMaps = filterTypeMaps(typeMaps)
@@ -1,12 +1,18 @@
 package installers
 
 type Credentials struct {
-	Host       string
-	Port       int
-	Username   string
-	Password   string
-	PrivateKey string
-	Passphrase string
-	Method     string
-	Sudo       bool
+	Host        string
+	Port        int
+	Username    string
+	Password    string
+	PrivateKey  string
+	Passphrase  string
+	Certificate string // OpenSSH用户证书，仅用于certificate认证方式
+	Method      string
+	Sudo        bool
+
+	HostKeyStore HostKeyStore // 主机公钥存储，为空时不检查主机公钥
+	HostKeyMode  string       // 主机公钥检查模式
+
+	JumpHosts []*Credentials // 跳板机，按顺序连接
 }
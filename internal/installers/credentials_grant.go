@@ -0,0 +1,72 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/numberutils"
+	"github.com/iwind/TeaGo/dbs"
+)
+
+// NewCredentialsWithGrant 根据认证信息构造登录信息，不包含跳板机
+func NewCredentialsWithGrant(grant *models.NodeGrant, host string, port int) *Credentials {
+	return &Credentials{
+		Host:        host,
+		Port:        port,
+		Username:    grant.Username,
+		Password:    grant.Password,
+		PrivateKey:  grant.PrivateKey,
+		Passphrase:  grant.Passphrase,
+		Certificate: grant.Certificate,
+		Method:      grant.Method,
+		Sudo:        grant.Su == 1,
+	}
+}
+
+// ComposeJumpCredentials 构造跳板机登录信息
+// 如果jumpHosts为空，则使用认证信息中设置的跳板机；跳板机自身认证信息中的跳板机设置会被忽略
+func ComposeJumpCredentials(tx *dbs.Tx, grant *models.NodeGrant, jumpHosts []*models.NodeSSHJumpHost, hostKeyStore HostKeyStore, hostKeyMode string) ([]*Credentials, error) {
+	if len(jumpHosts) == 0 {
+		grantJumpHosts, err := grant.DecodeJumpHosts()
+		if err != nil {
+			return nil, fmt.Errorf("decode jump hosts of grant '%d' failed: %w", grant.Id, err)
+		}
+		jumpHosts = grantJumpHosts
+	}
+
+	var result = []*Credentials{}
+	for index, jumpHost := range jumpHosts {
+		if jumpHost == nil {
+			continue
+		}
+		if len(jumpHost.Host) == 0 {
+			return nil, errors.New("host of jump host #" + numberutils.FormatInt(index+1) + " should not be empty")
+		}
+
+		var jumpGrant = grant
+		if jumpHost.GrantId > 0 && jumpHost.GrantId != int64(grant.Id) {
+			var err error
+			jumpGrant, err = models.SharedNodeGrantDAO.FindEnabledNodeGrant(tx, jumpHost.GrantId)
+			if err != nil {
+				return nil, err
+			}
+			if jumpGrant == nil {
+				return nil, errors.New("can not find grant with id '" + numberutils.FormatInt64(jumpHost.GrantId) + "' for jump host '" + jumpHost.Host + "'")
+			}
+		}
+
+		var port = jumpHost.Port
+		if port <= 0 {
+			port = 22
+		}
+
+		var credentials = NewCredentialsWithGrant(jumpGrant, jumpHost.Host, port)
+		credentials.Sudo = false // 跳板机只用来转发连接
+		credentials.HostKeyStore = hostKeyStore
+		credentials.HostKeyMode = hostKeyMode
+		result = append(result, credentials)
+	}
+	return result, nil
+}
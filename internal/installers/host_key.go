@@ -0,0 +1,141 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	HostKeyModeAcceptNew = "acceptNew" // 第一次连接时自动信任并记录主机公钥
+	HostKeyModeStrict    = "strict"    // 只信任事先记录的主机公钥
+)
+
+// HostKeyStore 主机公钥存储
+type HostKeyStore interface {
+	// FindHostKey 查找已记录的主机公钥，没有记录时返回nil
+	FindHostKey(host string, port int) (ssh.PublicKey, error)
+
+	// SaveHostKey 记录主机公钥
+	SaveHostKey(host string, port int, key ssh.PublicKey) error
+}
+
+// HostKeyMismatchError 主机公钥和记录的不一致
+type HostKeyMismatchError struct {
+	Addr                string
+	ExpectedFingerprint string
+	ActualFingerprint   string
+}
+
+func (this *HostKeyMismatchError) Error() string {
+	return "host key mismatch for '" + this.Addr + "': expected '" + this.ExpectedFingerprint + "', but got '" + this.ActualFingerprint + "'. The host may have been reinstalled, or someone may be intercepting the connection; reset the recorded host key if the change is expected"
+}
+
+// HostKeyUnknownError 严格模式下没有记录主机公钥
+type HostKeyUnknownError struct {
+	Addr        string
+	Fingerprint string
+}
+
+func (this *HostKeyUnknownError) Error() string {
+	return "unknown host key '" + this.Fingerprint + "' for '" + this.Addr + "': host key checking is strict, add the host key before connecting"
+}
+
+// IsHostKeyMismatchError 判断是否为主机公钥不一致错误
+func IsHostKeyMismatchError(err error) bool {
+	var mismatchErr *HostKeyMismatchError
+	return errors.As(err, &mismatchErr)
+}
+
+// IsHostKeyUnknownError 判断是否为主机公钥未记录错误
+func IsHostKeyUnknownError(err error) bool {
+	var unknownErr *HostKeyUnknownError
+	return errors.As(err, &unknownErr)
+}
+
+// HostKeyFingerprint 计算公钥指纹
+func HostKeyFingerprint(key ssh.PublicKey) string {
+	return ssh.FingerprintSHA256(key)
+}
+
+// ParseHostKey 解析authorized_keys或known_hosts格式的公钥
+func ParseHostKey(publicKey string) (ssh.PublicKey, error) {
+	publicKey = strings.TrimSpace(publicKey)
+	if len(publicKey) == 0 {
+		return nil, errors.New("empty public key")
+	}
+
+	// 同时兼容known_hosts中的行，行首的主机地址会被当作选项忽略
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("parse public key failed: %w", err)
+	}
+	return key, nil
+}
+
+// MarshalHostKey 将公钥转换为authorized_keys格式
+func MarshalHostKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// 构造主机公钥检查函数
+// 返回的算法列表用于在已有记录时优先协商同类型的公钥，避免因为服务器上有多个公钥而误报
+func newHostKeyCallback(store HostKeyStore, mode string, host string, port int) (callback ssh.HostKeyCallback, algorithms []string, err error) {
+	if store == nil {
+		return nil, nil, errors.New("host key store should not be nil")
+	}
+
+	pinnedKey, err := store.FindHostKey(host, port)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find host key failed: %w", err)
+	}
+	if pinnedKey != nil {
+		algorithms = hostKeyAlgorithms(pinnedKey)
+	}
+
+	var addr = net.JoinHostPort(host, strconv.Itoa(port))
+	callback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if pinnedKey != nil {
+			if bytes.Equal(pinnedKey.Marshal(), key.Marshal()) {
+				return nil
+			}
+			return &HostKeyMismatchError{
+				Addr:                addr,
+				ExpectedFingerprint: HostKeyFingerprint(pinnedKey),
+				ActualFingerprint:   HostKeyFingerprint(key),
+			}
+		}
+
+		if mode == HostKeyModeStrict {
+			return &HostKeyUnknownError{
+				Addr:        addr,
+				Fingerprint: HostKeyFingerprint(key),
+			}
+		}
+
+		// 第一次连接，记录公钥
+		err := store.SaveHostKey(host, port, key)
+		if err != nil {
+			return fmt.Errorf("save host key failed: %w", err)
+		}
+		return nil
+	}
+	return
+}
+
+// 根据公钥类型获取可以使用的主机公钥算法
+func hostKeyAlgorithms(key ssh.PublicKey) []string {
+	switch key.Type() {
+	case ssh.KeyAlgoRSA:
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	case ssh.CertAlgoRSAv01:
+		return []string{ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01}
+	}
+	return []string{key.Type()}
+}
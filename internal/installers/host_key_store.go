@@ -0,0 +1,84 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// NodeHostKeyStore 使用数据库记录节点的主机公钥
+type NodeHostKeyStore struct {
+	role    nodeconfigs.NodeRole
+	nodeId  int64
+	loginId int64
+}
+
+// NewNodeHostKeyStore 获取新对象
+func NewNodeHostKeyStore(role nodeconfigs.NodeRole, nodeId int64, loginId int64) *NodeHostKeyStore {
+	return &NodeHostKeyStore{
+		role:    role,
+		nodeId:  nodeId,
+		loginId: loginId,
+	}
+}
+
+// FindHostKey 查找已记录的主机公钥
+func (this *NodeHostKeyStore) FindHostKey(host string, port int) (ssh.PublicKey, error) {
+	hostKey, err := models.SharedNodeLoginHostKeyDAO.FindHostKey(nil, this.role, this.nodeId, host, port)
+	if err != nil || hostKey == nil {
+		return nil, err
+	}
+	return ParseHostKey(hostKey.PublicKey)
+}
+
+// SaveHostKey 记录主机公钥
+func (this *NodeHostKeyStore) SaveHostKey(host string, port int, key ssh.PublicKey) error {
+	return models.SharedNodeLoginHostKeyDAO.SaveHostKey(nil, this.loginId, this.role, this.nodeId, host, port, key.Type(), HostKeyFingerprint(key), MarshalHostKey(key), models.NodeLoginHostKeySourceAuto)
+}
+
+// KnownHostKeyStore 使用已经为任一节点记录的主机公钥校验，不会修改数据库中的记录
+// 没有记录的主机公钥只在当前对象中保存，用于测试认证信息等没有关联节点的连接
+type KnownHostKeyStore struct {
+	findKnownKey func(host string, port int) (ssh.PublicKey, error)
+
+	keyMap map[string]ssh.PublicKey // host:port => key
+	locker sync.Mutex
+}
+
+// NewKnownHostKeyStore 获取新对象
+func NewKnownHostKeyStore() *KnownHostKeyStore {
+	return &KnownHostKeyStore{
+		findKnownKey: func(host string, port int) (ssh.PublicKey, error) {
+			hostKey, err := models.SharedNodeLoginHostKeyDAO.FindLatestHostKeyWithAddr(nil, host, port)
+			if err != nil || hostKey == nil {
+				return nil, err
+			}
+			return ParseHostKey(hostKey.PublicKey)
+		},
+		keyMap: map[string]ssh.PublicKey{},
+	}
+}
+
+// FindHostKey 查找已记录的主机公钥
+func (this *KnownHostKeyStore) FindHostKey(host string, port int) (ssh.PublicKey, error) {
+	this.locker.Lock()
+	key, ok := this.keyMap[net.JoinHostPort(host, strconv.Itoa(port))]
+	this.locker.Unlock()
+	if ok {
+		return key, nil
+	}
+	return this.findKnownKey(host, port)
+}
+
+// SaveHostKey 在内存中记录主机公钥
+func (this *KnownHostKeyStore) SaveHostKey(host string, port int, key ssh.PublicKey) error {
+	this.locker.Lock()
+	this.keyMap[net.JoinHostPort(host, strconv.Itoa(port))] = key
+	this.locker.Unlock()
+	return nil
+}
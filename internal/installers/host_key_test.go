@@ -0,0 +1,158 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"golang.org/x/crypto/ssh"
+	"strconv"
+	"testing"
+)
+
+type testHostKeyStore struct {
+	keys map[string]ssh.PublicKey
+}
+
+func (this *testHostKeyStore) FindHostKey(host string, port int) (ssh.PublicKey, error) {
+	return this.keys[host+":"+strconv.Itoa(port)], nil
+}
+
+func (this *testHostKeyStore) SaveHostKey(host string, port int, key ssh.PublicKey) error {
+	this.keys[host+":"+strconv.Itoa(port)] = key
+	return nil
+}
+
+func testGenerateHostKey(t *testing.T) ssh.PublicKey {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestHostKeyCallback_AcceptNew(t *testing.T) {
+	var store = &testHostKeyStore{keys: map[string]ssh.PublicKey{}}
+	var key1 = testGenerateHostKey(t)
+	var key2 = testGenerateHostKey(t)
+
+	// 第一次连接
+	callback, algorithms, err := newHostKeyCallback(store, HostKeyModeAcceptNew, "127.0.0.1", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(algorithms) != 0 {
+		t.Fatal("algorithms should be empty")
+	}
+	err = callback("127.0.0.1:22", nil, key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.keys["127.0.0.1:22"] == nil {
+		t.Fatal("host key should be saved")
+	}
+
+	// 相同的公钥
+	callback, algorithms, err = newHostKeyCallback(store, HostKeyModeAcceptNew, "127.0.0.1", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(algorithms) != 1 || algorithms[0] != ssh.KeyAlgoED25519 {
+		t.Fatal("unexpected algorithms", algorithms)
+	}
+	err = callback("127.0.0.1:22", nil, key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不同的公钥
+	err = callback("127.0.0.1:22", nil, key2)
+	if !IsHostKeyMismatchError(err) {
+		t.Fatal("should be mismatch error, but got:", err)
+	}
+	t.Log(err)
+}
+
+func TestHostKeyCallback_Strict(t *testing.T) {
+	var store = &testHostKeyStore{keys: map[string]ssh.PublicKey{}}
+	var key = testGenerateHostKey(t)
+
+	callback, _, err := newHostKeyCallback(store, HostKeyModeStrict, "127.0.0.1", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = callback("127.0.0.1:22", nil, key)
+	if !IsHostKeyUnknownError(err) {
+		t.Fatal("should be unknown error, but got:", err)
+	}
+	if len(store.keys) > 0 {
+		t.Fatal("host key should not be saved")
+	}
+
+	// 事先记录公钥
+	pinnedKey, err := ParseHostKey("127.0.0.1 " + MarshalHostKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = store.SaveHostKey("127.0.0.1", 22, pinnedKey)
+	callback, _, err = newHostKeyCallback(store, HostKeyModeStrict, "127.0.0.1", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = callback("127.0.0.1:22", nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKnownHostKeyStore(t *testing.T) {
+	var knownKey = testGenerateHostKey(t)
+	var store = &KnownHostKeyStore{
+		findKnownKey: func(host string, port int) (ssh.PublicKey, error) {
+			if host == "192.168.1.100" && port == 22 {
+				return knownKey, nil
+			}
+			return nil, nil
+		},
+		keyMap: map[string]ssh.PublicKey{},
+	}
+
+	// 已记录的主机公钥不一致
+	callback, _, err := newHostKeyCallback(store, HostKeyModeAcceptNew, "192.168.1.100", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = callback("192.168.1.100:22", nil, testGenerateHostKey(t))
+	if !IsHostKeyMismatchError(err) {
+		t.Fatal("expect host key mismatch error, but got:", err)
+	}
+	err = callback("192.168.1.100:22", nil, knownKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有记录的主机
+	var newKey = testGenerateHostKey(t)
+	callback, _, err = newHostKeyCallback(store, HostKeyModeAcceptNew, "192.168.1.101", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = callback("192.168.1.101:22", nil, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一次测试中主机公钥发生变化
+	callback, _, err = newHostKeyCallback(store, HostKeyModeAcceptNew, "192.168.1.101", 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = callback("192.168.1.101:22", nil, testGenerateHostKey(t))
+	if !IsHostKeyMismatchError(err) {
+		t.Fatal("expect host key mismatch error, but got:", err)
+	}
+}
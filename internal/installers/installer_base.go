@@ -2,18 +2,12 @@ package installers
 
 import (
 	"errors"
-	"fmt"
-	"github.com/TeaOSLab/EdgeCommon/pkg/configutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
 	"github.com/iwind/TeaGo/Tea"
 	stringutil "github.com/iwind/TeaGo/utils/string"
-	"golang.org/x/crypto/ssh"
-	"net"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
-	"time"
 )
 
 type BaseInstaller struct {
@@ -22,79 +16,16 @@ type BaseInstaller struct {
 
 // Login 登录SSH服务
 func (this *BaseInstaller) Login(credentials *Credentials) error {
-	var hostKeyCallback ssh.HostKeyCallback = nil
-
-	// 检查参数
-	if len(credentials.Host) == 0 {
-		return errors.New("'host' should not be empty")
-	}
-	if credentials.Port <= 0 {
-		return errors.New("'port' should be greater than 0")
-	}
-	if len(credentials.Password) == 0 && len(credentials.PrivateKey) == 0 {
-		return errors.New("require user 'password' or 'privateKey'")
-	}
-
-	// 不使用known_hosts
-	if hostKeyCallback == nil {
-		hostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		}
-	}
-
-	// 认证
-	var methods = []ssh.AuthMethod{}
-	if credentials.Method == "user" {
-		{
-			var authMethod = ssh.Password(credentials.Password)
-			methods = append(methods, authMethod)
-		}
-
-		{
-			authMethod := ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) (answers []string, err error) {
-				if len(questions) == 0 {
-					return []string{}, nil
-				}
-				return []string{credentials.Password}, nil
-			})
-			methods = append(methods, authMethod)
-		}
-	} else if credentials.Method == "privateKey" {
-		var signer ssh.Signer
-		var err error
-		if len(credentials.Passphrase) > 0 {
-			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(credentials.PrivateKey), []byte(credentials.Passphrase))
-		} else {
-			signer, err = ssh.ParsePrivateKey([]byte(credentials.PrivateKey))
-		}
-		if err != nil {
-			return fmt.Errorf("parse private key: %w", err)
-		}
-		authMethod := ssh.PublicKeys(signer)
-		methods = append(methods, authMethod)
-	} else {
-		return errors.New("invalid method '" + credentials.Method + "'")
-	}
-
-	// SSH客户端
-	if len(credentials.Username) == 0 {
-		credentials.Username = "root"
-	}
-	var config = &ssh.ClientConfig{
-		User:            credentials.Username,
-		Auth:            methods,
-		HostKeyCallback: hostKeyCallback,
-		Timeout:         5 * time.Second, // TODO 后期可以设置这个超时时间
-	}
-
-	sshClient, err := ssh.Dial("tcp", configutils.QuoteIP(credentials.Host)+":"+strconv.Itoa(credentials.Port), config)
+	sshClient, jumpClients, err := connectSSH(credentials)
 	if err != nil {
 		return err
 	}
 	client, err := NewSSHClient(sshClient)
 	if err != nil {
+		closeSSHClients(jumpClients)
 		return err
 	}
+	client.jumpClients = jumpClients
 
 	if credentials.Sudo {
 		client.Sudo(credentials.Password)
@@ -181,21 +181,24 @@ func (this *NodeQueue) InstallNode(nodeId int64, installStatus *models.NodeInsta
 		IsUpgrading: isUpgrading,
 	}
 
-	var installer = &NodeInstaller{}
-	err = installer.Login(&Credentials{
-		Host:       loginParams.Host,
-		Port:       loginParams.Port,
-		Username:   grant.Username,
-		Password:   grant.Password,
-		PrivateKey: grant.PrivateKey,
-		Passphrase: grant.Passphrase,
-		Method:     grant.Method,
-		Sudo:       grant.Su == 1,
-	})
+	credentials, err := this.composeCredentials(nodeId, login, loginParams, grant)
 	if err != nil {
 		installStatus.ErrorCode = "SSH_LOGIN_FAILED"
 		return err
 	}
+
+	var installer = &NodeInstaller{}
+	err = installer.Login(credentials)
+	if err != nil {
+		if IsHostKeyMismatchError(err) {
+			installStatus.ErrorCode = "SSH_HOST_KEY_MISMATCH"
+		} else if IsHostKeyUnknownError(err) {
+			installStatus.ErrorCode = "SSH_HOST_KEY_UNKNOWN"
+		} else {
+			installStatus.ErrorCode = "SSH_LOGIN_FAILED"
+		}
+		return err
+	}
 	defer func() {
 		_ = installer.Close()
 	}()
@@ -290,17 +293,13 @@ func (this *NodeQueue) StartNode(nodeId int64) error {
 		return newGrantError("can not find user grant with id '" + numberutils.FormatInt64(loginParams.GrantId) + "'")
 	}
 
+	credentials, err := this.composeCredentials(nodeId, login, loginParams, grant)
+	if err != nil {
+		return err
+	}
+
 	var installer = &NodeInstaller{}
-	err = installer.Login(&Credentials{
-		Host:       loginParams.Host,
-		Port:       loginParams.Port,
-		Username:   grant.Username,
-		Password:   grant.Password,
-		PrivateKey: grant.PrivateKey,
-		Passphrase: grant.Passphrase,
-		Method:     grant.Method,
-		Sudo:       grant.Su == 1,
-	})
+	err = installer.Login(credentials)
 	if err != nil {
 		return err
 	}
@@ -397,17 +396,13 @@ func (this *NodeQueue) StopNode(nodeId int64) error {
 		return errors.New("can not find user grant with id '" + numberutils.FormatInt64(loginParams.GrantId) + "'")
 	}
 
+	credentials, err := this.composeCredentials(nodeId, login, loginParams, grant)
+	if err != nil {
+		return err
+	}
+
 	var installer = &NodeInstaller{}
-	err = installer.Login(&Credentials{
-		Host:       loginParams.Host,
-		Port:       loginParams.Port,
-		Username:   grant.Username,
-		Password:   grant.Password,
-		PrivateKey: grant.PrivateKey,
-		Passphrase: grant.Passphrase,
-		Method:     grant.Method,
-		Sudo:       grant.Su == 1,
-	})
+	err = installer.Login(credentials)
 	if err != nil {
 		return err
 	}
@@ -436,6 +431,33 @@ func (this *NodeQueue) StopNode(nodeId int64) error {
 	return nil
 }
 
+// 组合SSH认证信息
+func (this *NodeQueue) composeCredentials(nodeId int64, login *models.NodeLogin, loginParams *models.NodeLoginSSHParams, grant *models.NodeGrant) (*Credentials, error) {
+	var loginId int64
+	if login != nil {
+		loginId = int64(login.Id)
+	}
+
+	var hostKeyMode = loginParams.HostKeyMode
+	if len(hostKeyMode) == 0 {
+		hostKeyMode = HostKeyModeAcceptNew
+	}
+	var hostKeyStore = NewNodeHostKeyStore(nodeconfigs.NodeRoleNode, nodeId, loginId)
+
+	var credentials = NewCredentialsWithGrant(grant, loginParams.Host, loginParams.Port)
+	credentials.HostKeyStore = hostKeyStore
+	credentials.HostKeyMode = hostKeyMode
+
+	// 跳板机
+	jumpHosts, err := ComposeJumpCredentials(nil, grant, loginParams.JumpHosts, hostKeyStore, hostKeyMode)
+	if err != nil {
+		return nil, err
+	}
+	credentials.JumpHosts = jumpHosts
+
+	return credentials, nil
+}
+
 func (this *NodeQueue) lookupNodeExe(node *models.Node, client *SSHClient) (string, error) {
 	// 安装目录
 	var nodeDirs = []string{}
@@ -11,8 +11,9 @@ import (
 )
 
 type SSHClient struct {
-	raw  *ssh.Client
-	sftp *sftp.Client
+	raw         *ssh.Client
+	sftp        *sftp.Client
+	jumpClients []*ssh.Client // 跳板机连接
 
 	sudo         bool
 	sudoPassword string
@@ -149,7 +150,9 @@ func (this *SSHClient) Close() error {
 	if this.sftp != nil {
 		_ = this.sftp.Close()
 	}
-	return this.raw.Close()
+	err := this.raw.Close()
+	closeSSHClients(this.jumpClients)
+	return err
 }
 
 func (this *SSHClient) OpenFile(path string, flags int) (*sftp.File, error) {
@@ -0,0 +1,212 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/TeaOSLab/EdgeCommon/pkg/configutils"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"strconv"
+	"time"
+)
+
+// TestCredentials 测试是否能够通过SSH登录
+func TestCredentials(credentials *Credentials) error {
+	client, jumpClients, err := connectSSH(credentials)
+	if err != nil {
+		return err
+	}
+	_ = client.Close()
+	closeSSHClients(jumpClients)
+	return nil
+}
+
+// 依次连接跳板机和目标主机
+// 返回的跳板机连接需要在目标主机连接关闭之后再关闭
+func connectSSH(credentials *Credentials) (client *ssh.Client, jumpClients []*ssh.Client, err error) {
+	var via *ssh.Client
+	for index, jumpCredentials := range credentials.JumpHosts {
+		if jumpCredentials == nil {
+			continue
+		}
+		if len(jumpCredentials.JumpHosts) > 0 {
+			closeSSHClients(jumpClients)
+			return nil, nil, errors.New("jump host #" + strconv.Itoa(index+1) + " should not have its own jump hosts")
+		}
+		jumpClient, err := dialSSH(via, jumpCredentials)
+		if err != nil {
+			closeSSHClients(jumpClients)
+			return nil, nil, fmt.Errorf("connect to jump host #%d '%s' failed: %w", index+1, sshAddr(jumpCredentials), err)
+		}
+		jumpClients = append(jumpClients, jumpClient)
+		via = jumpClient
+	}
+
+	client, err = dialSSH(via, credentials)
+	if err != nil {
+		closeSSHClients(jumpClients)
+		if via != nil {
+			return nil, nil, fmt.Errorf("connect to '%s' via jump hosts failed: %w", sshAddr(credentials), err)
+		}
+		return nil, nil, err
+	}
+	return client, jumpClients, nil
+}
+
+// 连接SSH主机，via不为空时通过via转发连接
+func dialSSH(via *ssh.Client, credentials *Credentials) (*ssh.Client, error) {
+	config, err := composeSSHClientConfig(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	var addr = sshAddr(credentials)
+	if via == nil {
+		return ssh.Dial("tcp", addr, config)
+	}
+
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// 构造SSH客户端配置
+func composeSSHClientConfig(credentials *Credentials) (*ssh.ClientConfig, error) {
+	var hostKeyCallback ssh.HostKeyCallback = nil
+	var hostKeyAlgorithms []string
+
+	// 检查参数
+	if len(credentials.Host) == 0 {
+		return nil, errors.New("'host' should not be empty")
+	}
+	if credentials.Port <= 0 {
+		return nil, errors.New("'port' should be greater than 0")
+	}
+	if len(credentials.Password) == 0 && len(credentials.PrivateKey) == 0 {
+		return nil, errors.New("require user 'password' or 'privateKey'")
+	}
+
+	// 检查主机公钥
+	if credentials.HostKeyStore != nil {
+		var err error
+		hostKeyCallback, hostKeyAlgorithms, err = newHostKeyCallback(credentials.HostKeyStore, credentials.HostKeyMode, credentials.Host, credentials.Port)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 不使用known_hosts
+	if hostKeyCallback == nil {
+		hostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return nil
+		}
+	}
+
+	// 认证
+	var methods = []ssh.AuthMethod{}
+	switch credentials.Method {
+	case "user":
+		{
+			var authMethod = ssh.Password(credentials.Password)
+			methods = append(methods, authMethod)
+		}
+
+		{
+			authMethod := ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) (answers []string, err error) {
+				if len(questions) == 0 {
+					return []string{}, nil
+				}
+				return []string{credentials.Password}, nil
+			})
+			methods = append(methods, authMethod)
+		}
+	case "privateKey":
+		signer, err := parseSSHSigner(credentials.PrivateKey, credentials.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	case "certificate":
+		signer, err := parseSSHSigner(credentials.PrivateKey, credentials.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		certSigner, err := parseSSHCertSigner(credentials.Certificate, signer)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(certSigner))
+	default:
+		return nil, errors.New("invalid method '" + credentials.Method + "'")
+	}
+
+	// SSH客户端
+	if len(credentials.Username) == 0 {
+		credentials.Username = "root"
+	}
+	return &ssh.ClientConfig{
+		User:              credentials.Username,
+		Auth:              methods,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		Timeout:           5 * time.Second, // TODO 后期可以设置这个超时时间
+	}, nil
+}
+
+// 解析私钥
+func parseSSHSigner(privateKey string, passphrase string) (ssh.Signer, error) {
+	var signer ssh.Signer
+	var err error
+	if len(passphrase) > 0 {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(privateKey))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	return signer, nil
+}
+
+// 使用OpenSSH用户证书（即*-cert.pub文件内容）包装私钥
+func parseSSHCertSigner(certificate string, signer ssh.Signer) (ssh.Signer, error) {
+	if len(certificate) == 0 {
+		return nil, errors.New("require user 'certificate'")
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certificate))
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	cert, ok := publicKey.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("parse certificate: '" + publicKey.Type() + "' is not a certificate")
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.New("parse certificate: not a user certificate")
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	return certSigner, nil
+}
+
+// 按照和连接相反的顺序关闭连接
+func closeSSHClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		_ = clients[i].Close()
+	}
+}
+
+func sshAddr(credentials *Credentials) string {
+	return configutils.QuoteIP(credentials.Host) + ":" + strconv.Itoa(credentials.Port)
+}
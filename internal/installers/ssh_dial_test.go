@@ -0,0 +1,203 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testNewSigner(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, privateKey
+}
+
+// 启动一个测试用的SSH服务，返回端口
+func testStartSSHServer(t *testing.T, config *ssh.ServerConfig, handleChannel func(newChannel ssh.NewChannel)) int {
+	hostSigner, _ := testNewSigner(t)
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					_ = conn.Close()
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					if handleChannel == nil {
+						_ = newChannel.Reject(ssh.Prohibited, "not supported")
+						continue
+					}
+					go handleChannel(newChannel)
+				}
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// 跳板机转发连接
+func testForwardChannel(newChannel ssh.NewChannel) {
+	if newChannel.ChannelType() != "direct-tcpip" {
+		_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+		return
+	}
+
+	var payload = struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}{}
+	err := ssh.Unmarshal(newChannel.ExtraData(), &payload)
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, reqs, err := newChannel.Accept()
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	go func() {
+		_, _ = io.Copy(conn, channel)
+		_ = conn.Close()
+	}()
+	_, _ = io.Copy(channel, conn)
+	_ = channel.Close()
+}
+
+func TestConnectSSH_JumpHostAndCertificate(t *testing.T) {
+	// 目标主机只接受CA签发的用户证书
+	caSigner, _ := testNewSigner(t)
+	var checker = &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), caSigner.PublicKey().Marshal())
+		},
+	}
+	var targetPort = testStartSSHServer(t, &ssh.ServerConfig{
+		PublicKeyCallback: checker.Authenticate,
+	}, nil)
+
+	// 跳板机使用密码认证
+	var jumpPort = testStartSSHServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "jump" && string(password) == "123456" {
+				return nil, nil
+			}
+			return nil, errors.New("invalid password")
+		},
+	}, testForwardChannel)
+
+	// 用户证书
+	userSigner, userPrivateKey := testNewSigner(t)
+	var cert = &ssh.Certificate{
+		Key:             userSigner.PublicKey(),
+		CertType:        ssh.UserCert,
+		KeyId:           "edge",
+		ValidPrincipals: []string{"edge"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	err := cert.SignCert(rand.Reader, caSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBlock, err := ssh.MarshalPrivateKey(userPrivateKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var store = &testHostKeyStore{keys: map[string]ssh.PublicKey{}}
+	var jumpCredentials = &Credentials{
+		Host:         "127.0.0.1",
+		Port:         jumpPort,
+		Username:     "jump",
+		Password:     "123456",
+		Method:       "user",
+		HostKeyStore: store,
+		HostKeyMode:  HostKeyModeAcceptNew,
+	}
+	var credentials = &Credentials{
+		Host:         "127.0.0.1",
+		Port:         targetPort,
+		Username:     "edge",
+		PrivateKey:   string(pem.EncodeToMemory(pemBlock)),
+		Certificate:  string(ssh.MarshalAuthorizedKey(cert)),
+		Method:       "certificate",
+		HostKeyStore: store,
+		HostKeyMode:  HostKeyModeAcceptNew,
+		JumpHosts:    []*Credentials{jumpCredentials},
+	}
+
+	client, jumpClients, err := connectSSH(credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jumpClients) != 1 {
+		t.Fatal("expect 1 jump client, but got", len(jumpClients))
+	}
+	_ = client.Close()
+	closeSSHClients(jumpClients)
+
+	// 跳板机和目标主机的公钥都应该被记录
+	if len(store.keys) != 2 {
+		t.Fatal("expect 2 host keys, but got", len(store.keys))
+	}
+
+	// 没有证书时不能登录
+	credentials.Method = "privateKey"
+	err = TestCredentials(credentials)
+	if err == nil {
+		t.Fatal("should fail without certificate")
+	}
+	t.Log(err)
+
+	// 跳板机密码错误
+	credentials.Method = "certificate"
+	jumpCredentials.Password = "654321"
+	err = TestCredentials(credentials)
+	if err == nil || !strings.Contains(err.Error(), "jump host #1") {
+		t.Fatal("should fail on jump host, but got:", err)
+	}
+	t.Log(err)
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package messagemedias
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"github.com/iwind/TeaGo/maps"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// EmailMedia 通过SMTP发送邮件
+type EmailMedia struct {
+	SMTP     string `json:"smtp"`     // SMTP地址，格式为 host:port
+	Username string `json:"username"` // 用户名
+	Password string `json:"password"` // 密码
+	From     string `json:"from"`     // 发件人邮箱
+	FromName string `json:"fromName"` // 发件人名称
+
+	host string
+	port string
+}
+
+// Init 初始化
+// 参数：
+//   - smtp
+//   - username
+//   - password
+//   - from
+//   - fromName
+func (this *EmailMedia) Init(params maps.Map) error {
+	err := decodeParams(params, this)
+	if err != nil {
+		return err
+	}
+
+	if len(this.SMTP) == 0 {
+		return errors.New("'smtp' should not be empty")
+	}
+	if !strings.Contains(this.SMTP, ":") {
+		this.SMTP += ":25"
+	}
+	this.host, this.port, err = net.SplitHostPort(this.SMTP)
+	if err != nil {
+		return errors.New("invalid 'smtp': " + err.Error())
+	}
+
+	if len(this.From) == 0 {
+		this.From = this.Username
+	}
+	if !utils.ValidateEmail(this.From) {
+		return errors.New("invalid 'from' address '" + this.From + "'")
+	}
+
+	return nil
+}
+
+// Send 发送邮件
+func (this *EmailMedia) Send(user string, subject string, body string) (resp []byte, err error) {
+	if !utils.ValidateEmail(user) {
+		return nil, errors.New("invalid email address '" + user + "'")
+	}
+
+	client, err := this.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if len(this.Username) > 0 {
+		if ok, _ := client.Extension("AUTH"); ok {
+			err = client.Auth(smtp.PlainAuth("", this.Username, this.Password, this.host))
+			if err != nil {
+				return nil, errors.New("auth failed: " + err.Error())
+			}
+		}
+	}
+
+	err = client.Mail(this.From)
+	if err != nil {
+		return nil, err
+	}
+	err = client.Rcpt(user)
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return nil, err
+	}
+	_, err = writer.Write(this.composeMessage(user, subject, body))
+	if err != nil {
+		_ = writer.Close()
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, client.Quit()
+}
+
+// 连接SMTP服务器
+// 465端口使用TLS直连，其他端口在服务器支持的情况下使用STARTTLS
+func (this *EmailMedia) dial() (*smtp.Client, error) {
+	var tlsConfig = &tls.Config{
+		ServerName: this.host,
+	}
+
+	var dialer = &net.Dialer{
+		Timeout: 10 * time.Second,
+	}
+
+	var conn net.Conn
+	var err error
+	if this.port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", this.SMTP, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", this.SMTP)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	client, err := smtp.NewClient(conn, this.host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if this.port != "465" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			err = client.StartTLS(tlsConfig)
+			if err != nil {
+				_ = client.Close()
+				return nil, err
+			}
+		}
+	}
+
+	return client, nil
+}
+
+// 构造邮件内容
+func (this *EmailMedia) composeMessage(to string, subject string, body string) []byte {
+	var from = this.From
+	if len(this.FromName) > 0 {
+		from = mime.BEncoding.Encode("UTF-8", this.FromName) + " <" + this.From + ">"
+	}
+
+	var buf = &bytes.Buffer{}
+	buf.WriteString("From: " + from + "\r\n")
+	buf.WriteString("To: " + to + "\r\n")
+	buf.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", subject) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("\r\n")
+
+	var encodedBody = base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encodedBody) > 76 {
+		buf.WriteString(encodedBody[:76] + "\r\n")
+		encodedBody = encodedBody[76:]
+	}
+	buf.WriteString(encodedBody + "\r\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,15 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package messagemedias
+
+import "github.com/iwind/TeaGo/maps"
+
+// MediaInterface 消息媒介接口
+type MediaInterface interface {
+	// Init 使用媒介参数初始化
+	Init(params maps.Map) error
+
+	// Send 发送消息
+	// user 为接收人标识，比如邮箱、Telegram的Chat ID等
+	Send(user string, subject string, body string) (resp []byte, err error)
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package messagemedias
+
+import (
+	"encoding/json"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TelegramMedia 通过Telegram机器人发送消息
+type TelegramMedia struct {
+	Token    string `json:"token"`    // 机器人Token
+	ProxyURL string `json:"proxyURL"` // 代理地址，比如 socks5://127.0.0.1:1080
+
+	client *http.Client
+}
+
+// Init 初始化
+// 参数：
+//   - token
+//   - proxyURL
+func (this *TelegramMedia) Init(params maps.Map) error {
+	err := decodeParams(params, this)
+	if err != nil {
+		return err
+	}
+
+	if len(this.Token) == 0 {
+		return errors.New("'token' should not be empty")
+	}
+
+	var transport = &http.Transport{}
+	if len(this.ProxyURL) > 0 {
+		proxyURL, err := url.Parse(this.ProxyURL)
+		if err != nil {
+			return errors.New("invalid 'proxyURL': " + err.Error())
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	this.client = &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+	}
+
+	return nil
+}
+
+// Send 发送消息
+// user 为Telegram的Chat ID
+func (this *TelegramMedia) Send(user string, subject string, body string) (resp []byte, err error) {
+	var chatId = types.Int64(user)
+	if chatId == 0 {
+		return nil, errors.New("invalid chat id '" + user + "'")
+	}
+
+	bot, err := tgbotapi.NewBotAPIWithClient(this.Token, this.client)
+	if err != nil {
+		return nil, err
+	}
+
+	var text = body
+	if len(subject) > 0 {
+		text = subject + "\n\n" + body
+	}
+
+	message, err := bot.Send(tgbotapi.NewMessage(chatId, text))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(maps.Map{
+		"messageId": message.MessageID,
+	})
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package messagemedias
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	teaconst "github.com/TeaOSLab/EdgeAPI/internal/const"
+	"github.com/iwind/TeaGo/maps"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var webHookHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// WebHookVariable WebHook参数
+type WebHookVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// WebHookMedia 通过HTTP请求发送消息
+type WebHookMedia struct {
+	URL     string             `json:"url"`     // URL
+	Method  string             `json:"method"`  // 请求方法：GET|POST
+	Headers []*WebHookVariable `json:"headers"` // 自定义Header
+	Params  []*WebHookVariable `json:"params"`  // 自定义参数
+	Body    string             `json:"body"`    // 自定义请求内容，为空时将以JSON格式发送
+}
+
+// Init 初始化
+// 参数：
+//   - url
+//   - method
+//   - headers
+//   - params
+//   - body
+func (this *WebHookMedia) Init(params maps.Map) error {
+	err := decodeParams(params, this)
+	if err != nil {
+		return err
+	}
+
+	if len(this.URL) == 0 {
+		return errors.New("'url' should not be empty")
+	}
+	if !strings.HasPrefix(this.URL, "http://") && !strings.HasPrefix(this.URL, "https://") {
+		return errors.New("invalid url '" + this.URL + "'")
+	}
+
+	this.Method = strings.ToUpper(this.Method)
+	if len(this.Method) == 0 {
+		this.Method = http.MethodPost
+	}
+	if this.Method != http.MethodGet && this.Method != http.MethodPost {
+		return errors.New("unsupported method '" + this.Method + "'")
+	}
+
+	return nil
+}
+
+// Send 发送消息
+func (this *WebHookMedia) Send(user string, subject string, body string) (resp []byte, err error) {
+	var replacer = strings.NewReplacer(
+		"${MessageUser}", user,
+		"${MessageSubject}", subject,
+		"${MessageBody}", body,
+	)
+
+	var urlString = replacer.Replace(this.URL)
+
+	// 参数
+	var query = url.Values{}
+	for _, param := range this.Params {
+		if len(param.Name) == 0 {
+			continue
+		}
+		query.Add(param.Name, replacer.Replace(param.Value))
+	}
+
+	var reqBody io.Reader
+	var contentType string
+	if this.Method == http.MethodGet {
+		if len(this.Params) == 0 {
+			query.Set("user", user)
+			query.Set("subject", subject)
+			query.Set("body", body)
+		}
+	} else if len(this.Body) > 0 {
+		reqBody = strings.NewReader(replacer.Replace(this.Body))
+	} else if len(this.Params) > 0 {
+		reqBody = strings.NewReader(query.Encode())
+		contentType = "application/x-www-form-urlencoded"
+		query = url.Values{}
+	} else {
+		bodyJSON, err := json.Marshal(maps.Map{
+			"user":    user,
+			"subject": subject,
+			"body":    body,
+		})
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(bodyJSON)
+		contentType = "application/json"
+	}
+
+	if len(query) > 0 {
+		if strings.Contains(urlString, "?") {
+			urlString += "&" + query.Encode()
+		} else {
+			urlString += "?" + query.Encode()
+		}
+	}
+
+	req, err := http.NewRequest(this.Method, urlString, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", teaconst.GlobalProductName+"/"+teaconst.Version)
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, header := range this.Headers {
+		if len(header.Name) == 0 {
+			continue
+		}
+		req.Header.Set(header.Name, replacer.Replace(header.Value))
+	}
+
+	httpResp, err := webHookHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+
+	resp, err = io.ReadAll(io.LimitReader(httpResp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return resp, errors.New("invalid response status code '" + strconv.Itoa(httpResp.StatusCode) + "'")
+	}
+
+	return resp, nil
+}
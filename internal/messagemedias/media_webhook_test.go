@@ -0,0 +1,98 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package messagemedias_test
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/messagemedias"
+	"github.com/iwind/TeaGo/maps"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebHookMedia_Send(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		data, _ := io.ReadAll(req.Body)
+		var m = maps.Map{}
+		_ = json.Unmarshal(data, &m)
+		if m.GetString("subject") != "Node Down" || req.Header.Get("X-Token") != "123456" {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = writer.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var media = messagemedias.FindMedia(messagemedias.MediaTypeWebHook)
+	err := media.Init(maps.Map{
+		"url": server.URL,
+		"headers": []maps.Map{
+			{
+				"name":  "X-Token",
+				"value": "123456",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := media.Send("admin", "Node Down", "node 1 is down")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "ok" {
+		t.Fatal("unexpected response: " + string(resp))
+	}
+}
+
+func TestWebHookMedia_Send_GET(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("msg") != "Node Down: node 1 is down" {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = writer.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var media = &messagemedias.WebHookMedia{}
+	err := media.Init(maps.Map{
+		"url":    server.URL,
+		"method": "get",
+		"params": []maps.Map{
+			{
+				"name":  "msg",
+				"value": "${MessageSubject}: ${MessageBody}",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = media.Send("admin", "Node Down", "node 1 is down")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebHookMedia_Send_Error(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var media = &messagemedias.WebHookMedia{}
+	err := media.Init(maps.Map{
+		"url": server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = media.Send("admin", "Node Down", "node 1 is down")
+	if err == nil {
+		t.Fatal("should return error")
+	}
+	t.Log("expected error:", err)
+}
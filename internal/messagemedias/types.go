@@ -0,0 +1,47 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package messagemedias
+
+import "github.com/iwind/TeaGo/maps"
+
+type MediaType = string
+
+const (
+	MediaTypeWebHook  MediaType = "webHook"
+	MediaTypeEmail    MediaType = "email"
+	MediaTypeTelegram MediaType = "telegram"
+)
+
+// FindAllMediaTypes 所有支持的媒介类型
+func FindAllMediaTypes() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "WebHook",
+			"code":        MediaTypeWebHook,
+			"description": "通过HTTP请求将消息发送到指定的URL。",
+		},
+		{
+			"name":        "邮件",
+			"code":        MediaTypeEmail,
+			"description": "通过SMTP服务器发送邮件。",
+		},
+		{
+			"name":        "Telegram机器人",
+			"code":        MediaTypeTelegram,
+			"description": "通过Telegram机器人发送消息，接收人为Chat ID。",
+		},
+	}
+}
+
+// FindMedia 查找媒介实例
+func FindMedia(mediaType MediaType) MediaInterface {
+	switch mediaType {
+	case MediaTypeWebHook:
+		return &WebHookMedia{}
+	case MediaTypeEmail:
+		return &EmailMedia{}
+	case MediaTypeTelegram:
+		return &TelegramMedia{}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package messagemedias
+
+import (
+	"encoding/json"
+	"github.com/iwind/TeaGo/maps"
+)
+
+// 最多读取的响应内容尺寸
+const maxResponseSize = 4 << 10
+
+// 将参数解析到结构体中
+func decodeParams(params maps.Map, ptr any) error {
+	if params == nil {
+		return nil
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(paramsJSON, ptr)
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package nodes
+
+import (
+	"context"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// gRPC调用时传递访问令牌的Metadata
+const grpcAccessTokenMetadataKey = "x-edge-access-token"
+
+var errInvalidAccessToken = errors.New("invalid access token")
+
+// 校验访问令牌及其访问范围，并返回用于调用服务的上下文
+// REST和gRPC共用此校验过程
+func validateAPIAccessToken(token string, serviceName string, methodName string) (*rpcutils.PlainContext, error) {
+	accessToken, err := models.SharedAPIAccessTokenDAO.FindAccessToken(nil, token)
+	if err != nil {
+		return nil, errors.New("server error: " + err.Error())
+	}
+	if accessToken == nil || int64(accessToken.ExpiredAt) < time.Now().Unix() {
+		return nil, errInvalidAccessToken
+	}
+
+	if !rpcutils.AccessScopesAllow(accessToken.DecodeScopes(), serviceName, methodName) {
+		return nil, errors.New("the access token is not allowed to call '" + serviceName + "." + methodName + "()'")
+	}
+
+	return accessTokenPlainContext(accessToken)
+}
+
+// 如果不需要令牌的方法中带有令牌，则返回令牌对应的上下文，用来限制新令牌的访问范围
+// 令牌无效时忽略，不影响原有的调用
+func optionalAPIAccessTokenContext(ctx context.Context, token string) context.Context {
+	accessToken, err := models.SharedAPIAccessTokenDAO.FindAccessToken(nil, token)
+	if err != nil || accessToken == nil || int64(accessToken.ExpiredAt) < time.Now().Unix() {
+		return ctx
+	}
+	plainCtx, err := accessTokenPlainContext(accessToken)
+	if err != nil {
+		return ctx
+	}
+	return plainCtx
+}
+
+func accessTokenPlainContext(accessToken *models.APIAccessToken) (*rpcutils.PlainContext, error) {
+	var plainCtx *rpcutils.PlainContext
+	if accessToken.UserId > 0 {
+		plainCtx = rpcutils.NewPlainContext(rpcutils.UserTypeUser, int64(accessToken.UserId))
+	} else if accessToken.AdminId > 0 {
+		plainCtx = rpcutils.NewPlainContext(rpcutils.UserTypeAdmin, int64(accessToken.AdminId))
+	} else {
+		// TODO 支持更多类型的角色
+		return nil, errors.New("not supported role")
+	}
+	plainCtx.Scopes = accessToken.DecodeScopes()
+	return plainCtx, nil
+}
+
+// 如果gRPC请求中带有访问令牌，则使用令牌对应的用户调用服务
+func grpcAccessTokenContext(ctx context.Context, fullMethod string) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	var tokens = md.Get(grpcAccessTokenMetadataKey)
+	if len(tokens) == 0 || len(tokens[0]) == 0 {
+		return ctx, nil
+	}
+
+	var serviceName, methodName = rpcutils.ParseFullMethod(fullMethod)
+	if !restMethodRequireToken(serviceName, methodName) {
+		return optionalAPIAccessTokenContext(ctx, tokens[0]), nil
+	}
+
+	plainCtx, err := validateAPIAccessToken(tokens[0], serviceName, methodName)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return plainCtx, nil
+}
@@ -812,6 +812,12 @@ func (this *APINode) listenSock() error {
 
 // 服务过滤器
 func (this *APINode) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	// 使用访问令牌调用
+	ctx, err = grpcAccessTokenContext(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
 	if teaconst.Debug {
 		var before = time.Now()
 		var traceCtx = rpc.NewContext(ctx)
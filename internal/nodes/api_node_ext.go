@@ -3,6 +3,13 @@
 
 package nodes
 
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/accesslogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+)
+
 func (this *APINode) startAccessLogStorages() {
-	
+	goman.New(func() {
+		accesslogs.SharedStorageManager.Start()
+	})
 }
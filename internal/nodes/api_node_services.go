@@ -538,7 +538,9 @@ func (this *APINode) registerServices(server *grpc.Server) {
 		if index >= 0 {
 			serviceName = serviceName[index+1:]
 		}
+		restServicesLocker.RLock()
 		_, ok := restServicesMap[serviceName]
+		restServicesLocker.RUnlock()
 		if !ok {
 			panic("can not find service '" + serviceName + "' in rest")
 		}
@@ -555,6 +557,9 @@ func (this *APINode) rest(instance interface{}) {
 		name = name[index+1:]
 	}
 
+	restServicesLocker.Lock()
+	defer restServicesLocker.Unlock()
+
 	_, ok := restServicesMap[name]
 	if ok {
 		return
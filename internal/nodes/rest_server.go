@@ -3,10 +3,8 @@ package nodes
 import (
 	"context"
 	"crypto/tls"
-	"encoding/json"
-	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
-	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils/sizes"
 	"github.com/iwind/TeaGo/maps"
 	"io"
@@ -14,14 +12,19 @@ import (
 	"net/http"
 	"reflect"
 	"regexp"
-	"strings"
-	"time"
+	"sync"
+)
+
+const (
+	restMaxBodySize          = 32 * sizes.M // 请求内容最大尺寸
+	restMaxAnonymousBodySize = 64 * sizes.K // 不需要认证的接口的请求内容最大尺寸
 )
 
 var servicePathReg = regexp.MustCompile(`^/([a-zA-Z0-9]+)/([a-zA-Z0-9]+)$`)
 var restServicesMap = map[string]reflect.Value{
 	"APIAccessTokenService": reflect.ValueOf(new(services.APIAccessTokenService)),
 }
+var restServicesLocker = &sync.RWMutex{}
 
 type RestServer struct{}
 
@@ -64,6 +67,47 @@ func (this *RestServer) handle(writer http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// 接口列表
+	if path == "/paths" {
+		err := this.checkDocAccess(req)
+		if err != nil {
+			writer.WriteHeader(http.StatusUnauthorized)
+			this.writeJSON(writer, maps.Map{
+				"code":    401,
+				"message": err.Error(),
+				"data":    maps.Map{},
+			}, shouldPretty)
+			return
+		}
+
+		this.writeJSON(writer, maps.Map{
+			"code":    200,
+			"message": "ok",
+			"data": maps.Map{
+				"paths": findAllRestMethods(),
+			},
+		}, shouldPretty)
+		return
+	}
+
+	// OpenAPI文档
+	if path == "/openapi.json" {
+		err := this.checkDocAccess(req)
+		if err != nil {
+			writer.WriteHeader(http.StatusUnauthorized)
+			this.writeJSON(writer, maps.Map{
+				"code":    401,
+				"message": err.Error(),
+				"data":    maps.Map{},
+			}, shouldPretty)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = writer.Write(restOpenAPIJSON())
+		return
+	}
+
 	var matches = servicePathReg.FindStringSubmatch(path)
 	if len(matches) != 3 {
 		writer.WriteHeader(http.StatusNotFound)
@@ -78,7 +122,9 @@ func (this *RestServer) handle(writer http.ResponseWriter, req *http.Request) {
 	var serviceName = matches[1]
 	var methodName = matches[2]
 
+	restServicesLocker.RLock()
 	serviceType, ok := restServicesMap[serviceName]
+	restServicesLocker.RUnlock()
 	if !ok {
 		writer.WriteHeader(http.StatusNotFound)
 		this.writeJSON(writer, maps.Map{
@@ -89,44 +135,8 @@ func (this *RestServer) handle(writer http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if len(methodName) == 0 {
-		writer.WriteHeader(http.StatusNotFound)
-		this.writeJSON(writer, maps.Map{
-			"code":    "404",
-			"message": "method '" + methodName + "' not found",
-			"data":    maps.Map{},
-		}, shouldPretty)
-		return
-	}
-
-	// 再次查找
-	methodName = strings.ToUpper(string(methodName[0])) + methodName[1:]
-	var method = serviceType.MethodByName(methodName)
-	if !method.IsValid() {
-		// 兼容Enabled
-		if strings.Contains(methodName, "Enabled") {
-			methodName = strings.Replace(methodName, "Enabled", "", 1)
-			method = serviceType.MethodByName(methodName)
-			if !method.IsValid() {
-				writer.WriteHeader(http.StatusNotFound)
-				this.writeJSON(writer, maps.Map{
-					"code":    "404",
-					"message": "method '" + methodName + "' not found",
-					"data":    maps.Map{},
-				}, shouldPretty)
-				return
-			}
-		} else {
-			writer.WriteHeader(http.StatusNotFound)
-			this.writeJSON(writer, maps.Map{
-				"code":    "404",
-				"message": "method '" + methodName + "' not found",
-				"data":    maps.Map{},
-			}, shouldPretty)
-			return
-		}
-	}
-	if method.Type().NumIn() != 2 || method.Type().NumOut() != 2 {
+	method, ok := findRestMethod(serviceType, methodName)
+	if !ok {
 		writer.WriteHeader(http.StatusNotFound)
 		this.writeJSON(writer, maps.Map{
 			"code":    "404",
@@ -135,70 +145,57 @@ func (this *RestServer) handle(writer http.ResponseWriter, req *http.Request) {
 		}, shouldPretty)
 		return
 	}
-	if method.Type().In(0).Name() != "Context" {
-		writer.WriteHeader(http.StatusNotFound)
-		this.writeJSON(writer, maps.Map{
-			"code":    "404",
-			"message": "method '" + methodName + "' not found (or invalid context)",
-			"data":    maps.Map{},
-		}, shouldPretty)
-		return
-	}
 
 	// 上下文
 	var ctx = context.Background()
 
-	if serviceName != "APIAccessTokenService" || (methodName != "GetAPIAccessToken" && methodName != "getAPIAccessToken") {
-		// 校验TOKEN
-		var token = req.Header.Get("X-Edge-Access-Token")
-		if len(token) == 0 {
-			token = req.Header.Get("Edge-Access-Token")
+	// 先校验Header中的认证信息，再读取请求内容
+	var maxBodySize = restMaxBodySize
+	var sign *restSignature
+	if restMethodRequireToken(serviceName, methodName) {
+		var authErr error
+		if isSignedRestRequest(req) {
+			// 签名中包含原始的请求内容，需要读取请求内容后才能完成校验
+			sign, authErr = checkRestSignatureHeaders(req)
+		} else {
+			// 校验TOKEN
+			var token = req.Header.Get(restAccessTokenHeader)
 			if len(token) == 0 {
-				this.writeJSON(writer, maps.Map{
-					"code":    400,
-					"data":    maps.Map{},
-					"message": "require 'X-Edge-Access-Token' header",
-				}, shouldPretty)
-				return
+				token = req.Header.Get("Edge-Access-Token")
+				if len(token) == 0 {
+					this.writeJSON(writer, maps.Map{
+						"code":    400,
+						"data":    maps.Map{},
+						"message": "require 'X-Edge-Access-Token' header or request signature",
+					}, shouldPretty)
+					return
+				}
 			}
+			ctx, authErr = validateAPIAccessToken(token, serviceName, methodName)
 		}
-
-		accessToken, err := models.SharedAPIAccessTokenDAO.FindAccessToken(nil, token)
-		if err != nil {
+		if authErr != nil {
 			this.writeJSON(writer, maps.Map{
 				"code":    400,
 				"data":    maps.Map{},
-				"message": "server error: " + err.Error(),
+				"message": authErr.Error(),
 			}, shouldPretty)
 			return
 		}
+	} else {
+		// 不需要令牌的接口只接收较小的请求内容
+		maxBodySize = restMaxAnonymousBodySize
 
-		if accessToken == nil || int64(accessToken.ExpiredAt) < time.Now().Unix() {
-			this.writeJSON(writer, maps.Map{
-				"code":    400,
-				"data":    maps.Map{},
-				"message": "invalid access token",
-			}, shouldPretty)
-			return
+		// 不需要令牌的接口中如果带有令牌，用来限制新令牌的访问范围
+		var token = req.Header.Get(restAccessTokenHeader)
+		if len(token) == 0 {
+			token = req.Header.Get("Edge-Access-Token")
 		}
-
-		if accessToken.UserId > 0 {
-			ctx = rpcutils.NewPlainContext("user", int64(accessToken.UserId))
-		} else if accessToken.AdminId > 0 {
-			ctx = rpcutils.NewPlainContext("admin", int64(accessToken.AdminId))
-		} else {
-			// TODO 支持更多类型的角色
-			this.writeJSON(writer, maps.Map{
-				"code":    400,
-				"data":    maps.Map{},
-				"message": "not supported role",
-			}, shouldPretty)
-			return
+		if len(token) > 0 {
+			ctx = optionalAPIAccessTokenContext(ctx, token)
 		}
 	}
 
-	// TODO 可以设置最大可接收内容尺寸
-	body, err := io.ReadAll(io.LimitReader(req.Body, 32*sizes.M))
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize))
 	if err != nil {
 		writer.WriteHeader(http.StatusBadRequest)
 		this.writeJSON(writer, maps.Map{
@@ -209,14 +206,26 @@ func (this *RestServer) handle(writer http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if sign != nil {
+		signCtx, authErr := validateRestSignature(req, sign, body)
+		if authErr != nil {
+			this.writeJSON(writer, maps.Map{
+				"code":    400,
+				"data":    maps.Map{},
+				"message": authErr.Error(),
+			}, shouldPretty)
+			return
+		}
+		ctx = signCtx
+	}
+
 	// 如果为空，表示传的数据为空
 	if len(body) == 0 {
 		body = []byte("{}")
 	}
 
 	// 请求数据
-	var reqValue = reflect.New(method.Type().In(1).Elem()).Interface()
-	err = json.Unmarshal(body, reqValue)
+	reqValue, err := decodeRestRequest(body, method.Type().In(1))
 	if err != nil {
 		writer.WriteHeader(http.StatusBadRequest)
 		this.writeJSON(writer, maps.Map{
@@ -270,6 +279,33 @@ func (this *RestServer) handle(writer http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// 检查是否可以查看接口文档，需要有效的访问令牌或者请求签名
+// 限定了访问范围的令牌不能查看
+func (this *RestServer) checkDocAccess(req *http.Request) error {
+	if isSignedRestRequest(req) {
+		sign, err := checkRestSignatureHeaders(req)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(io.LimitReader(req.Body, restMaxAnonymousBodySize))
+		if err != nil {
+			return err
+		}
+		_, err = validateRestSignature(req, sign, body)
+		return err
+	}
+
+	var token = req.Header.Get(restAccessTokenHeader)
+	if len(token) == 0 {
+		token = req.Header.Get("Edge-Access-Token")
+		if len(token) == 0 {
+			return errors.New("require 'X-Edge-Access-Token' header or request signature")
+		}
+	}
+	_, err := validateAPIAccessToken(token, "", "")
+	return err
+}
+
 func (this *RestServer) writeJSON(writer http.ResponseWriter, v maps.Map, pretty bool) {
 	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
 
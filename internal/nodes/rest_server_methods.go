@@ -0,0 +1,155 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package nodes
+
+import (
+	"encoding/json"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+var restErrorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// RestMethod REST可以调用的方法
+type RestMethod struct {
+	Path         string `json:"path"`
+	Service      string `json:"service"`
+	Method       string `json:"method"`
+	RequestType  string `json:"requestType"`
+	ResponseType string `json:"responseType"`
+	RequireToken bool   `json:"requireToken"`
+
+	requestType  reflect.Type
+	responseType reflect.Type
+}
+
+// 查找服务中的方法
+func findRestMethod(serviceValue reflect.Value, methodName string) (method reflect.Value, ok bool) {
+	if len(methodName) == 0 {
+		return
+	}
+
+	methodName = strings.ToUpper(string(methodName[0])) + methodName[1:]
+	method = serviceValue.MethodByName(methodName)
+	if !method.IsValid() {
+		// 兼容Enabled
+		if !strings.Contains(methodName, "Enabled") {
+			return
+		}
+		method = serviceValue.MethodByName(strings.Replace(methodName, "Enabled", "", 1))
+		if !method.IsValid() {
+			return
+		}
+	}
+
+	if !isRestMethodType(method.Type()) {
+		return
+	}
+
+	return method, true
+}
+
+// 判断是否为RPC方法：func(context.Context, *pb.XXXRequest) (*pb.XXXResponse, error)
+func isRestMethodType(methodType reflect.Type) bool {
+	if methodType.NumIn() != 2 || methodType.NumOut() != 2 {
+		return false
+	}
+	if methodType.In(0).Name() != "Context" {
+		return false
+	}
+
+	var reqType = methodType.In(1)
+	if reqType.Kind() != reflect.Ptr || reqType.Elem().Kind() != reflect.Struct || !reqType.Implements(protoMessageType) {
+		return false
+	}
+
+	var respType = methodType.Out(0)
+	if respType.Kind() != reflect.Ptr || respType.Elem().Kind() != reflect.Struct {
+		return false
+	}
+
+	return methodType.Out(1) == restErrorType
+}
+
+// 判断方法是否需要AccessToken
+// 获取、刷新和撤销令牌的方法本身不需要令牌
+func restMethodRequireToken(serviceName string, methodName string) bool {
+	if serviceName != "APIAccessTokenService" {
+		return true
+	}
+	return !strings.EqualFold(methodName, "GetAPIAccessToken") &&
+		!strings.EqualFold(methodName, "RefreshAPIAccessToken") &&
+		!strings.EqualFold(methodName, "RevokeAPIAccessToken")
+}
+
+// 列出所有可以调用的REST方法
+func findAllRestMethods() []*RestMethod {
+	restServicesLocker.RLock()
+	var servicesMap = make(map[string]reflect.Value, len(restServicesMap))
+	for serviceName, serviceValue := range restServicesMap {
+		servicesMap[serviceName] = serviceValue
+	}
+	restServicesLocker.RUnlock()
+
+	return findRestMethodsInServices(servicesMap)
+}
+
+// 列出一组服务中可以调用的REST方法
+func findRestMethodsInServices(servicesMap map[string]reflect.Value) []*RestMethod {
+	var result = []*RestMethod{}
+	for serviceName, serviceValue := range servicesMap {
+		var serviceType = serviceValue.Type()
+		for i := 0; i < serviceType.NumMethod(); i++ {
+			var methodName = serviceType.Method(i).Name
+			var method = serviceValue.Method(i)
+			if !isRestMethodType(method.Type()) {
+				continue
+			}
+
+			var reqType = method.Type().In(1).Elem()
+			var respType = method.Type().Out(0).Elem()
+			result = append(result, &RestMethod{
+				Path:         "/" + serviceName + "/" + strings.ToLower(methodName[:1]) + methodName[1:],
+				Service:      serviceName,
+				Method:       methodName,
+				RequestType:  reqType.Name(),
+				ResponseType: respType.Name(),
+				RequireToken: restMethodRequireToken(serviceName, methodName),
+				requestType:  reqType,
+				responseType: respType,
+			})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result
+}
+
+// 将请求内容解析为方法对应的请求对象
+// 优先使用兼容以往版本的JSON解析方式，失败后再使用protobuf的JSON映射规则（支持字符串形式的int64、枚举名称等）
+func decodeRestRequest(body []byte, reqType reflect.Type) (any, error) {
+	var reqValue = reflect.New(reqType.Elem()).Interface()
+	err := json.Unmarshal(body, reqValue)
+	if err == nil {
+		return reqValue, nil
+	}
+
+	message, ok := reqValue.(proto.Message)
+	if !ok {
+		return nil, err
+	}
+	proto.Reset(message)
+	protoErr := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, message)
+	if protoErr != nil {
+		// 返回原始的错误，便于调用者查看
+		return nil, err
+	}
+	return message, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package nodes
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/assert"
+	"reflect"
+	"testing"
+)
+
+func TestFindRestMethod(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var serviceValue = reflect.ValueOf(&services.NodeService{})
+
+	{
+		_, ok := findRestMethod(serviceValue, "findEnabledNode")
+		a.IsTrue(ok)
+	}
+	{
+		_, ok := findRestMethod(serviceValue, "FindEnabledNode")
+		a.IsTrue(ok)
+	}
+	{
+		// 非RPC方法
+		_, ok := findRestMethod(serviceValue, "validateUserNode")
+		a.IsFalse(ok)
+	}
+	{
+		_, ok := findRestMethod(serviceValue, "")
+		a.IsFalse(ok)
+	}
+}
+
+func TestFindAllRestMethods(t *testing.T) {
+	var methods = findRestMethodsInServices(map[string]reflect.Value{
+		"APIAccessTokenService": reflect.ValueOf(&services.APIAccessTokenService{}),
+		"NodeService":           reflect.ValueOf(&services.NodeService{}),
+	})
+	if len(methods) == 0 {
+		t.Fatal("should not be empty")
+	}
+	for _, method := range methods {
+		if method.Path == "/NodeService/validateUserNode" {
+			t.Fatal("should not contain non-rpc methods")
+		}
+	}
+	for _, method := range methods[:3] {
+		t.Log(method.Path, method.RequestType, method.ResponseType, method.RequireToken)
+	}
+}
+
+func TestDecodeRestRequest(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var reqType = reflect.TypeOf(&pb.FindEnabledNodeRequest{})
+
+	{
+		req, err := decodeRestRequest([]byte(`{"nodeId": 1}`), reqType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.IsTrue(req.(*pb.FindEnabledNodeRequest).NodeId == 1)
+	}
+
+	{
+		// int64 in string
+		req, err := decodeRestRequest([]byte(`{"nodeId": "2"}`), reqType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.IsTrue(req.(*pb.FindEnabledNodeRequest).NodeId == 2)
+	}
+
+	{
+		_, err := decodeRestRequest([]byte(`{"nodeId": "abc"}`), reqType)
+		a.IsNotNil(err)
+	}
+}
+
+func TestRestMethodRequireToken(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsFalse(restMethodRequireToken("APIAccessTokenService", "getAPIAccessToken"))
+	a.IsFalse(restMethodRequireToken("APIAccessTokenService", "RefreshAPIAccessToken"))
+	a.IsFalse(restMethodRequireToken("APIAccessTokenService", "revokeAPIAccessToken"))
+	a.IsTrue(restMethodRequireToken("APIAccessTokenService", "DeleteAPIAccessToken"))
+	a.IsTrue(restMethodRequireToken("NodeService", "findEnabledNode"))
+}
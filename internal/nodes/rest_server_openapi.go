@@ -0,0 +1,221 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package nodes
+
+import (
+	teaconst "github.com/TeaOSLab/EdgeAPI/internal/const"
+	"github.com/iwind/TeaGo/maps"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"reflect"
+	"sync"
+)
+
+const (
+	restAccessTokenHeader     = "X-Edge-Access-Token"
+	restAccessTokenSchemeName = "AccessToken"
+	restSignatureSchemeName   = "Signature"
+)
+
+var restOpenAPIData []byte
+var restOpenAPIOnce = sync.Once{}
+
+// 生成OpenAPI 3文档
+func restOpenAPIJSON() []byte {
+	restOpenAPIOnce.Do(func() {
+		restOpenAPIData = newRestOpenAPIBuilder().Build(findAllRestMethods()).AsPrettyJSON()
+	})
+	return restOpenAPIData
+}
+
+type restOpenAPIBuilder struct {
+	schemas maps.Map
+}
+
+func newRestOpenAPIBuilder() *restOpenAPIBuilder {
+	return &restOpenAPIBuilder{
+		schemas: maps.Map{},
+	}
+}
+
+// Build 根据REST方法列表构造文档
+func (this *restOpenAPIBuilder) Build(methods []*RestMethod) maps.Map {
+	var paths = maps.Map{}
+	for _, method := range methods {
+		var operation = maps.Map{
+			"operationId": method.Service + "_" + method.Method,
+			"tags":        []string{method.Service},
+			"requestBody": maps.Map{
+				"required": true,
+				"content": maps.Map{
+					"application/json": maps.Map{
+						"schema": this.messageSchemaRef(method.requestType),
+					},
+				},
+			},
+			"responses": maps.Map{
+				"200": maps.Map{
+					"description": "ok",
+					"content": maps.Map{
+						"application/json": maps.Map{
+							"schema": this.responseSchema(method.responseType),
+						},
+					},
+				},
+			},
+		}
+
+		if method.RequireToken {
+			operation["security"] = []maps.Map{
+				{
+					restAccessTokenSchemeName: []string{},
+				},
+				{
+					restSignatureSchemeName: []string{},
+				},
+			}
+		} else {
+			operation["security"] = []maps.Map{}
+		}
+
+		paths[method.Path] = maps.Map{
+			"post": operation,
+		}
+	}
+
+	return maps.Map{
+		"openapi": "3.0.3",
+		"info": maps.Map{
+			"title":   teaconst.GlobalProductName + " API",
+			"version": teaconst.Version,
+		},
+		"paths": paths,
+		"components": maps.Map{
+			"schemas": this.schemas,
+			"securitySchemes": maps.Map{
+				restAccessTokenSchemeName: maps.Map{
+					"type": "apiKey",
+					"in":   "header",
+					"name": restAccessTokenHeader,
+				},
+				restSignatureSchemeName: maps.Map{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": "EDGE-HMAC-SHA256 Credential={AccessKeyId}, Signature={Signature}, with '" + restSignatureDateHeader + "' and '" + restSignatureNonce + "' headers",
+				},
+			},
+		},
+	}
+}
+
+// 响应数据结构：{ "code": 200, "message": "ok", "data": { ... } }
+func (this *restOpenAPIBuilder) responseSchema(respType reflect.Type) maps.Map {
+	return maps.Map{
+		"type": "object",
+		"properties": maps.Map{
+			"code": maps.Map{
+				"type": "integer",
+			},
+			"message": maps.Map{
+				"type": "string",
+			},
+			"data": this.messageSchemaRef(respType),
+		},
+	}
+}
+
+func (this *restOpenAPIBuilder) messageSchemaRef(messageType reflect.Type) maps.Map {
+	if messageType == nil {
+		return maps.Map{"type": "object"}
+	}
+	if messageType.Kind() != reflect.Ptr {
+		messageType = reflect.PtrTo(messageType)
+	}
+	message, ok := reflect.New(messageType.Elem()).Interface().(proto.Message)
+	if !ok {
+		return maps.Map{"type": "object"}
+	}
+	return this.descriptorSchemaRef(message.ProtoReflect().Descriptor())
+}
+
+func (this *restOpenAPIBuilder) descriptorSchemaRef(descriptor protoreflect.MessageDescriptor) maps.Map {
+	// 使用全称，比如 pb.FindEnabledNodeRequest，防止不同包中或者嵌套的同名消息互相覆盖
+	var name = string(descriptor.FullName())
+
+	var ref = maps.Map{
+		"$ref": "#/components/schemas/" + name,
+	}
+	if this.schemas.Has(name) {
+		return ref
+	}
+
+	// 先占位，防止递归
+	this.schemas[name] = maps.Map{}
+
+	var properties = maps.Map{}
+	var fields = descriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		var field = fields.Get(i)
+		properties[string(field.Name())] = this.fieldSchema(field)
+	}
+
+	this.schemas[name] = maps.Map{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	return ref
+}
+
+func (this *restOpenAPIBuilder) fieldSchema(field protoreflect.FieldDescriptor) maps.Map {
+	if field.IsMap() {
+		return maps.Map{
+			"type":                 "object",
+			"additionalProperties": this.kindSchema(field.MapValue()),
+		}
+	}
+
+	var schema = this.kindSchema(field)
+	if field.IsList() {
+		return maps.Map{
+			"type":  "array",
+			"items": schema,
+		}
+	}
+	return schema
+}
+
+// 和 encoding/json 的输出格式保持一致
+func (this *restOpenAPIBuilder) kindSchema(field protoreflect.FieldDescriptor) maps.Map {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return maps.Map{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return maps.Map{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return maps.Map{"type": "integer", "format": "int32", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return maps.Map{"type": "integer", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return maps.Map{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.FloatKind:
+		return maps.Map{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return maps.Map{"type": "number", "format": "double"}
+	case protoreflect.StringKind:
+		return maps.Map{"type": "string"}
+	case protoreflect.BytesKind:
+		return maps.Map{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		var values = []int32{}
+		var enumValues = field.Enum().Values()
+		for i := 0; i < enumValues.Len(); i++ {
+			values = append(values, int32(enumValues.Get(i).Number()))
+		}
+		return maps.Map{"type": "integer", "format": "int32", "enum": values}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return this.descriptorSchemaRef(field.Message())
+	}
+	return maps.Map{}
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package nodes
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
+	"github.com/iwind/TeaGo/maps"
+	"reflect"
+	"testing"
+)
+
+func TestRestOpenAPIJSON(t *testing.T) {
+	var methods = findRestMethodsInServices(map[string]reflect.Value{
+		"APIAccessTokenService": reflect.ValueOf(&services.APIAccessTokenService{}),
+		"NodeService":           reflect.ValueOf(&services.NodeService{}),
+	})
+
+	var doc = maps.Map{}
+	err := json.Unmarshal(newRestOpenAPIBuilder().Build(methods).AsJSON(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths = doc.GetMap("paths")
+	if !paths.Has("/NodeService/findEnabledNode") {
+		t.Fatal("'/NodeService/findEnabledNode' should be in paths")
+	}
+	if !paths.Has("/APIAccessTokenService/getAPIAccessToken") {
+		t.Fatal("'/APIAccessTokenService/getAPIAccessToken' should be in paths")
+	}
+
+	var schemas = doc.GetMap("components").GetMap("schemas")
+	if !schemas.Has("pb.FindEnabledNodeRequest") {
+		t.Fatal("'pb.FindEnabledNodeRequest' should be in schemas")
+	}
+	t.Log(len(paths), "paths,", len(schemas), "schemas")
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package nodes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/ttlcache"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 请求签名
+// 类似于AWS SigV4，使用AccessKey的uniqueId和secret对请求签名，不需要事先获取AccessToken：
+//
+//	Authorization: EDGE-HMAC-SHA256 Credential={uniqueId}, Signature={signature}
+//	X-Edge-Date: 20240102T150405Z
+//	X-Edge-Nonce: {每次请求都不同的随机字符串}
+//
+// 签名计算方法：
+//
+//	canonicalRequest = METHOD + "\n" + PATH + "\n" + CANONICAL_QUERY + "\n" + DATE + "\n" + NONCE + "\n" + HEX(SHA256(BODY))
+//	stringToSign     = "EDGE-HMAC-SHA256" + "\n" + DATE + "\n" + HEX(SHA256(canonicalRequest))
+//	signingKey       = HMAC(HMAC("EDGE" + secret, DATE[:8]), "edge_request")
+//	signature        = HEX(HMAC(signingKey, stringToSign))
+const (
+	restSignatureAlgorithm  = "EDGE-HMAC-SHA256"
+	restSignatureDateHeader = "X-Edge-Date"
+	restSignatureNonce      = "X-Edge-Nonce"
+	restSignatureDateLayout = "20060102T150405Z"
+	restSignatureMaxSkew    = 5 * time.Minute // 允许的最大时间误差
+	restSignatureMinNonce   = 8
+	restSignatureMaxNonce   = 64
+)
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// 用来防止重放的nonce缓存
+// 缓存时间超过允许的时间误差，过期的请求会因为时间校验失败而被拒绝
+// 注意：缓存只在当前API节点有效，有多个API节点时，在允许的时间误差内，一个被截获的请求可以在其他每个API节点上各重放一次；
+// 所以调用方应该始终使用HTTPS，需要严格防重放时应该将同一个AccessKey的请求固定发送到同一个API节点
+var restNonceCache = ttlcache.NewCache(ttlcache.NewMaxItemsOption(1_000_000))
+
+// 判断请求是否使用了签名
+func isSignedRestRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("Authorization"), restSignatureAlgorithm+" ")
+}
+
+// 请求签名信息
+type restSignature struct {
+	credential string
+	signature  string
+	date       string
+	nonce      string
+	accessKey  *models.UserAccessKey
+}
+
+// 在读取请求内容之前校验签名相关的Header和AccessKey，防止未认证的请求占用资源
+func checkRestSignatureHeaders(req *http.Request) (*restSignature, error) {
+	credential, signature, ok := parseRestAuthorization(req.Header.Get("Authorization"))
+	if !ok {
+		return nil, errors.New("invalid 'Authorization' header")
+	}
+
+	// 检查时间
+	var date = req.Header.Get(restSignatureDateHeader)
+	signedAt, err := time.Parse(restSignatureDateLayout, date)
+	if err != nil {
+		return nil, errors.New("invalid '" + restSignatureDateHeader + "' header")
+	}
+	var skew = time.Since(signedAt)
+	if skew > restSignatureMaxSkew || skew < -restSignatureMaxSkew {
+		return nil, errors.New("request has expired or the clock is out of sync")
+	}
+
+	var nonce = req.Header.Get(restSignatureNonce)
+	if len(nonce) < restSignatureMinNonce || len(nonce) > restSignatureMaxNonce {
+		return nil, errors.New("invalid '" + restSignatureNonce + "' header")
+	}
+
+	// 检查AccessKey
+	accessKey, err := models.SharedUserAccessKeyDAO.FindAccessKeyWithUniqueId(nil, credential)
+	if err != nil {
+		return nil, errors.New("server error: " + err.Error())
+	}
+	if accessKey == nil {
+		return nil, errInvalidSignature
+	}
+
+	return &restSignature{
+		credential: credential,
+		signature:  signature,
+		date:       date,
+		nonce:      nonce,
+		accessKey:  accessKey,
+	}, nil
+}
+
+// 使用请求内容校验签名，并返回用于调用服务的上下文
+func validateRestSignature(req *http.Request, sign *restSignature, body []byte) (*rpcutils.PlainContext, error) {
+	var accessKey = sign.accessKey
+	var expectedSignature = signRestRequest(req.Method, req.URL.EscapedPath(), req.URL.Query(), sign.date, sign.nonce, body, accessKey.Secret)
+	if !hmac.Equal([]byte(expectedSignature), []byte(strings.ToLower(sign.signature))) {
+		return nil, errInvalidSignature
+	}
+
+	// 检查是否重放，缓存已满时也拒绝请求
+	var nonceKey = "restNonce@" + sign.credential + "@" + sign.nonce
+	if restNonceCache.IncreaseInt64(nonceKey, 1, time.Now().Add(2*restSignatureMaxSkew+time.Minute).Unix(), false) != 1 {
+		return nil, errors.New("nonce has been used")
+	}
+
+	// 检查用户状态
+	if accessKey.UserId > 0 {
+		user, err := models.SharedUserDAO.FindEnabledUser(nil, int64(accessKey.UserId), nil)
+		if err != nil {
+			return nil, errors.New("server error: " + err.Error())
+		}
+		if user == nil || !user.IsOn {
+			return nil, errors.New("the user is not available")
+		}
+		return rpcutils.NewPlainContext(rpcutils.UserTypeUser, int64(accessKey.UserId)), nil
+	}
+	if accessKey.AdminId > 0 {
+		admin, err := models.SharedAdminDAO.FindEnabledAdmin(nil, int64(accessKey.AdminId))
+		if err != nil {
+			return nil, errors.New("server error: " + err.Error())
+		}
+		if admin == nil || !admin.IsOn {
+			return nil, errors.New("the admin is not available")
+		}
+		return rpcutils.NewPlainContext(rpcutils.UserTypeAdmin, int64(accessKey.AdminId)), nil
+	}
+	return nil, errors.New("not supported role")
+}
+
+// 解析Authorization：EDGE-HMAC-SHA256 Credential=xxx, Signature=xxx
+func parseRestAuthorization(authorization string) (credential string, signature string, ok bool) {
+	if !strings.HasPrefix(authorization, restSignatureAlgorithm+" ") {
+		return
+	}
+	for _, piece := range strings.Split(authorization[len(restSignatureAlgorithm)+1:], ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(piece), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "Credential":
+			credential = value
+		case "Signature":
+			signature = value
+		}
+	}
+	ok = len(credential) > 0 && len(signature) > 0
+	return
+}
+
+// 计算请求签名
+func signRestRequest(method string, path string, query url.Values, date string, nonce string, body []byte, secret string) string {
+	if len(path) == 0 {
+		path = "/"
+	}
+	var bodyHash = sha256.Sum256(body)
+	var canonicalRequest = strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		canonicalRestQuery(query),
+		date,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+	var canonicalRequestHash = sha256.Sum256([]byte(canonicalRequest))
+	var stringToSign = restSignatureAlgorithm + "\n" + date + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	var day = date
+	if len(day) > 8 {
+		day = day[:8]
+	}
+	var signingKey = hmacSHA256(hmacSHA256([]byte("EDGE"+secret), []byte(day)), []byte("edge_request"))
+	return hex.EncodeToString(hmacSHA256(signingKey, []byte(stringToSign)))
+}
+
+// 按参数名排序后的查询参数
+func canonicalRestQuery(query url.Values) string {
+	var keys = []string{}
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pieces = []string{}
+	for _, key := range keys {
+		var values = append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pieces = append(pieces, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	return strings.Join(pieces, "&")
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	var h = hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package nodes
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseRestAuthorization(t *testing.T) {
+	credential, signature, ok := parseRestAuthorization("EDGE-HMAC-SHA256 Credential=abc, Signature=0123")
+	if !ok || credential != "abc" || signature != "0123" {
+		t.Fatal("parse failed:", credential, signature, ok)
+	}
+
+	for _, authorization := range []string{
+		"",
+		"Bearer abc",
+		"EDGE-HMAC-SHA256 Credential=abc",
+		"EDGE-HMAC-SHA256 Signature=0123",
+	} {
+		_, _, ok = parseRestAuthorization(authorization)
+		if ok {
+			t.Fatal("'" + authorization + "' should be invalid")
+		}
+	}
+}
+
+func TestCanonicalRestQuery(t *testing.T) {
+	var query = url.Values{}
+	query.Add("b", "2")
+	query.Add("a", "y")
+	query.Add("a", "x")
+	query.Add("c", "hello world")
+	var result = canonicalRestQuery(query)
+	if result != "a=x&a=y&b=2&c=hello+world" {
+		t.Fatal("unexpected result:", result)
+	}
+}
+
+func TestSignRestRequest(t *testing.T) {
+	var query = url.Values{"pretty": []string{"true"}}
+	var body = []byte(`{"serverId":1}`)
+	var signature = signRestRequest("post", "/ServerService/findEnabledServer", query, "20240102T150405Z", "abcdefgh", body, "secret")
+	t.Log(signature)
+
+	if signature != signRestRequest("POST", "/ServerService/findEnabledServer", query, "20240102T150405Z", "abcdefgh", body, "secret") {
+		t.Fatal("signature should be stable")
+	}
+
+	// 任何一部分变化都会导致签名变化
+	for _, other := range []string{
+		signRestRequest("POST", "/ServerService/findEnabledServer", query, "20240102T150405Z", "abcdefgh", []byte(`{"serverId":2}`), "secret"),
+		signRestRequest("POST", "/ServerService/deleteServer", query, "20240102T150405Z", "abcdefgh", body, "secret"),
+		signRestRequest("POST", "/ServerService/findEnabledServer", query, "20240102T150406Z", "abcdefgh", body, "secret"),
+		signRestRequest("POST", "/ServerService/findEnabledServer", query, "20240102T150405Z", "abcdefgi", body, "secret"),
+		signRestRequest("POST", "/ServerService/findEnabledServer", url.Values{}, "20240102T150405Z", "abcdefgh", body, "secret"),
+		signRestRequest("POST", "/ServerService/findEnabledServer", query, "20240102T150405Z", "abcdefgh", body, "secret2"),
+	} {
+		if other == signature {
+			t.Fatal("signature should change")
+		}
+	}
+}
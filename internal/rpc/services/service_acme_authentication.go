@@ -33,3 +33,28 @@ func (this *ACMEAuthenticationService) FindACMEAuthenticationKeyWithToken(ctx co
 	}
 	return &pb.FindACMEAuthenticationKeyWithTokenResponse{Key: auth.Key}, nil
 }
+
+// FindACMETLSALPNChallengeWithDomain 根据域名获取TLS-ALPN-01验证证书
+func (this *ACMEAuthenticationService) FindACMETLSALPNChallengeWithDomain(ctx context.Context, req *pb.FindACMETLSALPNChallengeWithDomainRequest) (*pb.FindACMETLSALPNChallengeWithDomainResponse, error) {
+	_, err := this.ValidateNode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Domain) == 0 {
+		return nil, errors.New("'domain' should not be empty")
+	}
+
+	var tx = this.NullTx()
+
+	auth, err := acme.SharedACMEAuthenticationDAO.FindTLSALPNAuthWithDomain(tx, req.Domain)
+	if err != nil {
+		return nil, err
+	}
+	if auth == nil {
+		return &pb.FindACMETLSALPNChallengeWithDomainResponse{}, nil
+	}
+	return &pb.FindACMETLSALPNChallengeWithDomainResponse{
+		CertData: []byte(auth.CertData),
+		KeyData:  []byte(auth.KeyData),
+	}, nil
+}
@@ -4,8 +4,12 @@ package services
 
 import (
 	"context"
-	"github.com/TeaOSLab/EdgeAPI/internal/acme"
+	acmeutils "github.com/TeaOSLab/EdgeAPI/internal/acme"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"net/url"
+	"regexp"
 )
 
 // ACMEProviderService ACME服务商
@@ -20,15 +24,23 @@ func (this *ACMEProviderService) FindAllACMEProviders(ctx context.Context, req *
 		return nil, err
 	}
 
+	var tx = this.NullTx()
+	providers, err := acme.SharedACMEProviderDAO.FindAllProviders(tx)
+	if err != nil {
+		return nil, err
+	}
+
 	var pbProviders = []*pb.ACMEProvider{}
-	for _, provider := range acme.FindAllProviders() {
+	for _, provider := range providers {
 		pbProviders = append(pbProviders, &pb.ACMEProvider{
+			Id:             provider.Id,
 			Name:           provider.Name,
 			Code:           provider.Code,
 			Description:    provider.Description,
 			ApiURL:         provider.APIURL,
 			RequireEAB:     provider.RequireEAB,
 			EabDescription: provider.EABDescription,
+			IsCustom:       provider.IsCustom,
 		})
 	}
 
@@ -42,7 +54,11 @@ func (this *ACMEProviderService) FindACMEProviderWithCode(ctx context.Context, r
 		return nil, err
 	}
 
-	var provider = acme.FindProviderWithCode(req.AcmeProviderCode)
+	var tx = this.NullTx()
+	provider, err := acme.SharedACMEProviderDAO.FindProviderWithCode(tx, req.AcmeProviderCode)
+	if err != nil {
+		return nil, err
+	}
 	if provider == nil {
 		return &pb.FindACMEProviderWithCodeResponse{
 			AcmeProvider: nil,
@@ -51,12 +67,123 @@ func (this *ACMEProviderService) FindACMEProviderWithCode(ctx context.Context, r
 
 	return &pb.FindACMEProviderWithCodeResponse{
 		AcmeProvider: &pb.ACMEProvider{
+			Id:             provider.Id,
 			Name:           provider.Name,
 			Code:           provider.Code,
 			Description:    provider.Description,
 			ApiURL:         provider.APIURL,
 			RequireEAB:     provider.RequireEAB,
 			EabDescription: provider.EABDescription,
+			IsCustom:       provider.IsCustom,
 		},
 	}, nil
 }
+
+// CreateACMEProvider 创建自定义服务商
+func (this *ACMEProviderService) CreateACMEProvider(ctx context.Context, req *pb.CreateACMEProviderRequest) (*pb.CreateACMEProviderResponse, error) {
+	adminId, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !regexp.MustCompile(`^[a-zA-Z0-9_-]{1,50}$`).MatchString(req.Code) {
+		return nil, errors.New("'code' should only contain letters, numbers, '_' and '-'")
+	}
+	err = this.checkProvider(req.Name, req.ApiURL, req.CaCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	providerId, err := acme.SharedACMEProviderDAO.CreateProvider(tx, adminId, req.Name, req.Code, req.Description, req.ApiURL, req.RequireEAB, req.EabDescription, req.CaCerts)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateACMEProviderResponse{AcmeProviderId: providerId}, nil
+}
+
+// UpdateACMEProvider 修改自定义服务商
+func (this *ACMEProviderService) UpdateACMEProvider(ctx context.Context, req *pb.UpdateACMEProviderRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.checkProvider(req.Name, req.ApiURL, req.CaCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = acme.SharedACMEProviderDAO.UpdateProvider(tx, req.AcmeProviderId, req.Name, req.Description, req.ApiURL, req.RequireEAB, req.EabDescription, req.CaCerts, req.IsOn)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// DeleteACMEProvider 删除自定义服务商
+func (this *ACMEProviderService) DeleteACMEProvider(ctx context.Context, req *pb.DeleteACMEProviderRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = acme.SharedACMEProviderDAO.DisableACMEProvider(tx, req.AcmeProviderId)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindAllCustomACMEProviders 查找所有自定义服务商
+func (this *ACMEProviderService) FindAllCustomACMEProviders(ctx context.Context, req *pb.FindAllCustomACMEProvidersRequest) (*pb.FindAllCustomACMEProvidersResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	providers, err := acme.SharedACMEProviderDAO.FindAllEnabledProviders(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbProviders = []*pb.ACMEProvider{}
+	for _, provider := range providers {
+		pbProviders = append(pbProviders, &pb.ACMEProvider{
+			Id:             int64(provider.Id),
+			IsOn:           provider.IsOn,
+			Name:           provider.Name,
+			Code:           provider.Code,
+			Description:    provider.Description,
+			ApiURL:         provider.ApiURL,
+			RequireEAB:     provider.RequireEAB,
+			EabDescription: provider.EabDescription,
+			IsCustom:       true,
+			CaCerts:        provider.CaCerts,
+		})
+	}
+	return &pb.FindAllCustomACMEProvidersResponse{AcmeProviders: pbProviders}, nil
+}
+
+// 检查自定义服务商参数
+func (this *ACMEProviderService) checkProvider(name string, apiURL string, caCerts string) error {
+	if len(name) == 0 {
+		return errors.New("'name' should not be empty")
+	}
+
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+		return errors.New("'apiURL' should be a valid https directory url")
+	}
+
+	if len(caCerts) > 0 {
+		_, err = acmeutils.ParseCACerts(caCerts)
+		if err != nil {
+			return errors.New("invalid 'caCerts': " + err.Error())
+		}
+	}
+	return nil
+}
@@ -4,7 +4,6 @@ package services
 
 import (
 	"context"
-	acmeutils "github.com/TeaOSLab/EdgeAPI/internal/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 )
@@ -46,7 +45,10 @@ func (this *ACMEProviderAccountService) FindAllACMEProviderAccountsWithProviderC
 	var pbAccounts = []*pb.ACMEProviderAccount{}
 	for _, account := range accounts {
 		var pbProvider *pb.ACMEProvider
-		provider := acmeutils.FindProviderWithCode(account.ProviderCode)
+		provider, err := acme.SharedACMEProviderDAO.FindProviderWithCode(tx, account.ProviderCode)
+		if err != nil {
+			return nil, err
+		}
 		if provider != nil {
 			pbProvider = &pb.ACMEProvider{
 				Name:        provider.Name,
@@ -148,7 +150,10 @@ func (this *ACMEProviderAccountService) FindEnabledACMEProviderAccount(ctx conte
 	}
 
 	var pbProvider *pb.ACMEProvider
-	provider := acmeutils.FindProviderWithCode(account.ProviderCode)
+	provider, err := acme.SharedACMEProviderDAO.FindProviderWithCode(tx, account.ProviderCode)
+	if err != nil {
+		return nil, err
+	}
 	if provider != nil {
 		pbProvider = &pb.ACMEProvider{
 			Name:           provider.Name,
@@ -202,7 +207,10 @@ func (this *ACMEProviderAccountService) ListEnabledACMEProviderAccounts(ctx cont
 	var pbAccounts = []*pb.ACMEProviderAccount{}
 	for _, account := range accounts {
 		var pbProvider *pb.ACMEProvider
-		provider := acmeutils.FindProviderWithCode(account.ProviderCode)
+		provider, err := acme.SharedACMEProviderDAO.FindProviderWithCode(tx, account.ProviderCode)
+		if err != nil {
+			return nil, err
+		}
 		if provider != nil {
 			pbProvider = &pb.ACMEProvider{
 				Name:           provider.Name,
@@ -7,7 +7,9 @@ import (
 	acmemodels "github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
 )
 
 // ACMETaskService ACME任务相关服务
@@ -111,7 +113,10 @@ func (this *ACMETaskService) ListEnabledACMETasks(ctx context.Context, req *pb.L
 		if len(acmeUser.ProviderCode) == 0 {
 			acmeUser.ProviderCode = acme.DefaultProviderCode
 		}
-		var provider = acme.FindProviderWithCode(acmeUser.ProviderCode)
+		provider, err := acmemodels.SharedACMEProviderDAO.FindProviderWithCode(tx, acmeUser.ProviderCode)
+		if err != nil {
+			return nil, err
+		}
 		if provider != nil {
 			pbACMEUser.AcmeProvider = &pb.ACMEProvider{
 				Name:           provider.Name,
@@ -137,7 +142,10 @@ func (this *ACMETaskService) ListEnabledACMETasks(ctx context.Context, req *pb.L
 					AcmeProvider: nil,
 				}
 
-				var provider = acme.FindProviderWithCode(account.ProviderCode)
+				provider, err := acmemodels.SharedACMEProviderDAO.FindProviderWithCode(tx, account.ProviderCode)
+				if err != nil {
+					return nil, err
+				}
 				if provider != nil {
 					pbACMEUser.AcmeProviderAccount.AcmeProvider = &pb.ACMEProvider{
 						Name:           provider.Name,
@@ -215,6 +223,10 @@ func (this *ACMETaskService) ListEnabledACMETasks(ctx context.Context, req *pb.L
 			LatestACMETaskLog: pbTaskLog,
 			AuthType:          task.AuthType,
 			AuthURL:           task.AuthURL,
+			KeyType:           task.KeyType,
+			DualKeyType:       task.DualKeyType,
+			ReuseKey:          task.ReuseKey == 1,
+			DnsDelegation:     task.DnsDelegation == 1,
 		})
 	}
 
@@ -231,6 +243,14 @@ func (this *ACMETaskService) CreateACMETask(ctx context.Context, req *pb.CreateA
 	if len(req.AuthType) == 0 {
 		req.AuthType = acme.AuthTypeDNS
 	}
+	switch req.AuthType {
+	case acme.AuthTypeDNS, acme.AuthTypeHTTP, acme.AuthTypeTLSALPN:
+	default:
+		return nil, errors.New("invalid auth type '" + req.AuthType + "'")
+	}
+	if req.AuthType != acme.AuthTypeDNS {
+		req.DnsDelegation = false
+	}
 
 	if adminId > 0 {
 		if req.UserId > 0 {
@@ -238,8 +258,13 @@ func (this *ACMETaskService) CreateACMETask(ctx context.Context, req *pb.CreateA
 		}
 	}
 
+	err = this.checkKeyTypes(req.KeyType, req.DualKeyType)
+	if err != nil {
+		return nil, err
+	}
+
 	var tx = this.NullTx()
-	taskId, err := acmemodels.SharedACMETaskDAO.CreateACMETask(tx, adminId, userId, req.AuthType, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew, req.AuthURL)
+	taskId, err := acmemodels.SharedACMETaskDAO.CreateACMETask(tx, adminId, userId, req.AuthType, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew, req.AuthURL, req.KeyType, req.DualKeyType, req.ReuseKey, req.DnsDelegation)
 	if err != nil {
 		return nil, err
 	}
@@ -263,7 +288,12 @@ func (this *ACMETaskService) UpdateACMETask(ctx context.Context, req *pb.UpdateA
 		return nil, this.PermissionError()
 	}
 
-	err = acmemodels.SharedACMETaskDAO.UpdateACMETask(tx, req.AcmeTaskId, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew, req.AuthURL)
+	err = this.checkKeyTypes(req.KeyType, req.DualKeyType)
+	if err != nil {
+		return nil, err
+	}
+
+	err = acmemodels.SharedACMETaskDAO.UpdateACMETask(tx, req.AcmeTaskId, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew, req.AuthURL, req.KeyType, req.DualKeyType, req.ReuseKey, req.DnsDelegation)
 	if err != nil {
 		return nil, err
 	}
@@ -311,7 +341,23 @@ func (this *ACMETaskService) RunACMETask(ctx context.Context, req *pb.RunACMETas
 		return nil, this.PermissionError()
 	}
 
-	isOk, msg, certId := acmemodels.SharedACMETaskDAO.RunTask(tx, req.AcmeTaskId)
+	task, err := acmemodels.SharedACMETaskDAO.FindEnabledACMETask(tx, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("can not find task")
+	}
+
+	isOk, msg, certId, taskLogId := acmemodels.SharedACMETaskDAO.RunTaskWithLogId(tx, req.AcmeTaskId)
+
+	// 已有证书时记录为一次手动续期
+	if task.CertId > 0 {
+		_, err = acmemodels.SharedACMERenewalLogDAO.CreateLog(tx, int64(task.AdminId), int64(task.UserId), int64(task.CertId), req.AcmeTaskId, taskLogId, acmemodels.ACMERenewalTriggerManual, isOk, msg, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return &pb.RunACMETaskResponse{
 		IsOk:      isOk,
@@ -364,7 +410,10 @@ func (this *ACMETaskService) FindEnabledACMETask(ctx context.Context, req *pb.Fi
 			if len(acmeUser.ProviderCode) == 0 {
 				acmeUser.ProviderCode = acme.DefaultProviderCode
 			}
-			var provider = acme.FindProviderWithCode(acmeUser.ProviderCode)
+			provider, err := acmemodels.SharedACMEProviderDAO.FindProviderWithCode(tx, acmeUser.ProviderCode)
+			if err != nil {
+				return nil, err
+			}
 			if provider != nil {
 				pbACMEUser.AcmeProvider = &pb.ACMEProvider{
 					Name:           provider.Name,
@@ -390,7 +439,10 @@ func (this *ACMETaskService) FindEnabledACMETask(ctx context.Context, req *pb.Fi
 						AcmeProvider: nil,
 					}
 
-					var provider = acme.FindProviderWithCode(account.ProviderCode)
+					provider, err := acmemodels.SharedACMEProviderDAO.FindProviderWithCode(tx, account.ProviderCode)
+					if err != nil {
+						return nil, err
+					}
 					if provider != nil {
 						pbACMEUser.AcmeProviderAccount.AcmeProvider = &pb.ACMEProvider{
 							Name:           provider.Name,
@@ -427,20 +479,46 @@ func (this *ACMETaskService) FindEnabledACMETask(ctx context.Context, req *pb.Fi
 	}
 
 	return &pb.FindEnabledACMETaskResponse{AcmeTask: &pb.ACMETask{
-		Id:          int64(task.Id),
-		IsOn:        task.IsOn,
-		DnsDomain:   task.DnsDomain,
-		Domains:     task.DecodeDomains(),
-		CreatedAt:   int64(task.CreatedAt),
-		AutoRenew:   task.AutoRenew == 1,
-		DnsProvider: pbProvider,
-		AcmeUser:    pbACMEUser,
-		AuthType:    task.AuthType,
-		AuthURL:     task.AuthURL,
-		SslCert:     pbCert,
+		Id:            int64(task.Id),
+		IsOn:          task.IsOn,
+		DnsDomain:     task.DnsDomain,
+		Domains:       task.DecodeDomains(),
+		CreatedAt:     int64(task.CreatedAt),
+		AutoRenew:     task.AutoRenew == 1,
+		DnsProvider:   pbProvider,
+		AcmeUser:      pbACMEUser,
+		AuthType:      task.AuthType,
+		AuthURL:       task.AuthURL,
+		SslCert:       pbCert,
+		KeyType:       task.KeyType,
+		DualKeyType:   task.DualKeyType,
+		ReuseKey:      task.ReuseKey == 1,
+		DualSSLCertId: int64(task.DualCertId),
+		DnsDelegation: task.DnsDelegation == 1,
 	}}, nil
 }
 
+// 检查私钥类型
+func (this *ACMETaskService) checkKeyTypes(keyType string, dualKeyType string) error {
+	if len(keyType) > 0 && !acme.IsValidKeyType(keyType) {
+		return errors.New("invalid key type '" + keyType + "'")
+	}
+	if len(dualKeyType) > 0 {
+		if !acme.IsValidKeyType(dualKeyType) {
+			return errors.New("invalid dual key type '" + dualKeyType + "'")
+		}
+
+		// 双证书需要同时有RSA和ECDSA
+		if len(keyType) == 0 {
+			keyType = acme.DefaultKeyType
+		}
+		if acme.IsECKeyType(keyType) == acme.IsECKeyType(dualKeyType) {
+			return errors.New("dual key type should be different algorithm from key type")
+		}
+	}
+	return nil
+}
+
 // FindACMETaskUser 查找任务所属用户
 func (this *ACMETaskService) FindACMETaskUser(ctx context.Context, req *pb.FindACMETaskUserRequest) (*pb.FindACMETaskUserResponse, error) {
 	_, err := this.ValidateAdmin(ctx)
@@ -477,3 +555,82 @@ func (this *ACMETaskService) FindACMETaskUser(ctx context.Context, req *pb.FindA
 		},
 	}, nil
 }
+
+// CountACMERenewalLogs 计算证书续期记录数量
+func (this *ACMETaskService) CountACMERenewalLogs(ctx context.Context, req *pb.CountACMERenewalLogsRequest) (*pb.RPCCountResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = this.checkRenewalLogAccess(tx, userId, req.SslCertId, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := acmemodels.SharedACMERenewalLogDAO.CountLogs(tx, userId, req.SslCertId, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	return this.SuccessCount(count)
+}
+
+// ListACMERenewalLogs 列出单页证书续期记录
+func (this *ACMETaskService) ListACMERenewalLogs(ctx context.Context, req *pb.ListACMERenewalLogsRequest) (*pb.ListACMERenewalLogsResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = this.checkRenewalLogAccess(tx, userId, req.SslCertId, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+
+	renewalLogs, err := acmemodels.SharedACMERenewalLogDAO.ListLogs(tx, userId, req.SslCertId, req.AcmeTaskId, req.Offset, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbLogs = []*pb.ACMERenewalLog{}
+	for _, renewalLog := range renewalLogs {
+		pbLogs = append(pbLogs, &pb.ACMERenewalLog{
+			Id:            int64(renewalLog.Id),
+			SslCertId:     int64(renewalLog.CertId),
+			AcmeTaskId:    int64(renewalLog.TaskId),
+			AcmeTaskLogId: int64(renewalLog.TaskLogId),
+			Trigger:       renewalLog.Trigger,
+			IsOk:          renewalLog.IsOk,
+			Error:         renewalLog.Error,
+			Failures:      int32(renewalLog.Failures),
+			NextRetryAt:   int64(renewalLog.NextRetryAt),
+			CreatedAt:     int64(renewalLog.CreatedAt),
+		})
+	}
+	return &pb.ListACMERenewalLogsResponse{AcmeRenewalLogs: pbLogs}, nil
+}
+
+// 检查用户是否可以查看续期记录
+func (this *ACMETaskService) checkRenewalLogAccess(tx *dbs.Tx, userId int64, certId int64, taskId int64) error {
+	if userId <= 0 {
+		return nil
+	}
+	if certId > 0 {
+		err := models.SharedSSLCertDAO.CheckUserCert(tx, certId, userId)
+		if err != nil {
+			return this.PermissionError()
+		}
+	}
+	if taskId > 0 {
+		canAccess, err := acmemodels.SharedACMETaskDAO.CheckUserACMETask(tx, userId, taskId)
+		if err != nil {
+			return err
+		}
+		if !canAccess {
+			return this.PermissionError()
+		}
+	}
+	return nil
+}
@@ -139,7 +139,10 @@ func (this *ACMEUserService) ListACMEUsers(ctx context.Context, req *pb.ListACME
 		if len(user.ProviderCode) == 0 {
 			user.ProviderCode = acme.DefaultProviderCode
 		}
-		var provider = acme.FindProviderWithCode(user.ProviderCode)
+		provider, err := acmemodels.SharedACMEProviderDAO.FindProviderWithCode(tx, user.ProviderCode)
+		if err != nil {
+			return nil, err
+		}
 		if provider != nil {
 			pbUser.AcmeProvider = &pb.ACMEProvider{
 				Name:           provider.Name,
@@ -165,7 +168,10 @@ func (this *ACMEUserService) ListACMEUsers(ctx context.Context, req *pb.ListACME
 					AcmeProvider: nil,
 				}
 
-				var provider = acme.FindProviderWithCode(account.ProviderCode)
+				provider, err := acmemodels.SharedACMEProviderDAO.FindProviderWithCode(tx, account.ProviderCode)
+				if err != nil {
+					return nil, err
+				}
 				if provider != nil {
 					pbUser.AcmeProviderAccount.AcmeProvider = &pb.ACMEProvider{
 						Name:           provider.Name,
@@ -221,7 +227,10 @@ func (this *ACMEUserService) FindEnabledACMEUser(ctx context.Context, req *pb.Fi
 	if len(acmeUser.ProviderCode) == 0 {
 		acmeUser.ProviderCode = acme.DefaultProviderCode
 	}
-	var provider = acme.FindProviderWithCode(acmeUser.ProviderCode)
+	provider, err := acmemodels.SharedACMEProviderDAO.FindProviderWithCode(tx, acmeUser.ProviderCode)
+	if err != nil {
+		return nil, err
+	}
 	if provider != nil {
 		pbACMEUser.AcmeProvider = &pb.ACMEProvider{
 			Name:           provider.Name,
@@ -247,7 +256,10 @@ func (this *ACMEUserService) FindEnabledACMEUser(ctx context.Context, req *pb.Fi
 				AcmeProvider: nil,
 			}
 
-			var provider = acme.FindProviderWithCode(account.ProviderCode)
+			provider, err := acmemodels.SharedACMEProviderDAO.FindProviderWithCode(tx, account.ProviderCode)
+			if err != nil {
+				return nil, err
+			}
 			if provider != nil {
 				pbACMEUser.AcmeProviderAccount.AcmeProvider = &pb.ACMEProvider{
 					Name:           provider.Name,
@@ -13,6 +13,7 @@ import (
 	"github.com/TeaOSLab/EdgeCommon/pkg/configutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"github.com/TeaOSLab/EdgeCommon/pkg/systemconfigs"
+	"github.com/iwind/TeaGo/dbs"
 	timeutil "github.com/iwind/TeaGo/utils/time"
 	"time"
 )
@@ -516,11 +517,44 @@ func (this *AdminService) CheckAdminOTPWithUsername(ctx context.Context, req *pb
 		return &pb.CheckAdminOTPWithUsernameResponse{RequireOTP: false}, nil
 	}
 
-	otpIsOn, err := models.SharedLoginDAO.CheckLoginIsOn(tx, adminId, 0, "otp")
+	requireOTP, requireWebAuthn, requireWebAuthnRegistration, err := this.checkAdminSecondFactors(tx, adminId)
 	if err != nil {
 		return nil, err
 	}
-	return &pb.CheckAdminOTPWithUsernameResponse{RequireOTP: otpIsOn}, nil
+
+	return &pb.CheckAdminOTPWithUsernameResponse{
+		RequireOTP:                  requireOTP,
+		RequireWebAuthn:             requireWebAuthn,
+		RequireWebAuthnRegistration: requireWebAuthnRegistration,
+	}, nil
+}
+
+// 检查管理员登录时需要的其他认证方式
+func (this *AdminService) checkAdminSecondFactors(tx *dbs.Tx, adminId int64) (requireOTP bool, requireWebAuthn bool, requireWebAuthnRegistration bool, err error) {
+	requireOTP, err = models.SharedLoginDAO.CheckLoginIsOn(tx, adminId, 0, "otp")
+	if err != nil {
+		return
+	}
+
+	// 安全密钥
+	requireWebAuthn, err = models.SharedLoginDAO.CheckLoginIsOn(tx, adminId, 0, models.LoginTypeWebAuthn)
+	if err != nil {
+		return
+	}
+	if !requireWebAuthn {
+		webAuthnConfig, err := models.SharedSysSettingDAO.ReadLoginWebAuthnConfig(tx)
+		if err != nil {
+			return false, false, false, err
+		}
+		if webAuthnConfig.RequireSuperAdmins {
+			isSuper, err := models.SharedAdminDAO.CheckSuperAdmin(tx, adminId)
+			if err != nil {
+				return false, false, false, err
+			}
+			requireWebAuthnRegistration = isSuper
+		}
+	}
+	return
 }
 
 // ComposeAdminDashboard 取得管理员Dashboard数据
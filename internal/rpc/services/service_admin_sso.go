@@ -0,0 +1,373 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/sso"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/TeaOSLab/EdgeCommon/pkg/systemconfigs"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"time"
+)
+
+const adminOIDCLoginSessionKey = "@oidcLogin" // SESSION中保存OIDC登录状态的键
+
+// FindAdminSSOConfig 查找单点登录配置
+func (this *AdminService) FindAdminSSOConfig(ctx context.Context, req *pb.FindAdminSSOConfigRequest) (*pb.FindAdminSSOConfigResponse, error) {
+	_, err := this.validateSuperAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := models.SharedSysSettingDAO.ReadAdminSSOConfig(this.NullTx())
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FindAdminSSOConfigResponse{ConfigJSON: configJSON}, nil
+}
+
+// UpdateAdminSSOConfig 修改单点登录配置
+func (this *AdminService) UpdateAdminSSOConfig(ctx context.Context, req *pb.UpdateAdminSSOConfigRequest) (*pb.RPCSuccess, error) {
+	// 映射中可以授予超级管理员权限，所以只允许超级管理员修改
+	_, err := this.validateSuperAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var config = models.DefaultAdminSSOConfig()
+	err = json.Unmarshal(req.ConfigJSON, config)
+	if err != nil {
+		return nil, errors.New("decode config failed: " + err.Error())
+	}
+	err = models.SharedSysSettingDAO.UpdateAdminSSOConfig(this.NullTx(), config)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindAllEnabledAdminSSOProviders 列出登录页面上可以使用的认证服务
+func (this *AdminService) FindAllEnabledAdminSSOProviders(ctx context.Context, req *pb.FindAllEnabledAdminSSOProvidersRequest) (*pb.FindAllEnabledAdminSSOProvidersResponse, error) {
+	_, _, _, err := rpcutils.ValidateRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := models.SharedSysSettingDAO.ReadAdminSSOConfig(this.NullTx())
+	if err != nil {
+		return nil, err
+	}
+	var pbProviders = []*pb.AdminSSOProvider{}
+	for _, provider := range config.Providers {
+		if !provider.IsOn {
+			continue
+		}
+		pbProviders = append(pbProviders, &pb.AdminSSOProvider{
+			Code: provider.Code,
+			Name: provider.Name,
+			Type: provider.Type,
+		})
+	}
+	return &pb.FindAllEnabledAdminSSOProvidersResponse{AdminSSOProviders: pbProviders}, nil
+}
+
+// LoginAdminWithLDAP 使用LDAP账号登录
+func (this *AdminService) LoginAdminWithLDAP(ctx context.Context, req *pb.LoginAdminWithLDAPRequest) (*pb.LoginAdminResponse, error) {
+	_, _, _, err := rpcutils.ValidateRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Username) == 0 || len(req.Password) == 0 {
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: "请输入正确的用户名密码",
+		}, nil
+	}
+
+	var tx = this.NullTx()
+	provider, err := this.findAdminSSOProvider(tx, req.ProviderCode, sso.ProviderTypeLDAP)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticator, err := sso.NewLDAPAuthenticator(provider.LDAP)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := authenticator.Authenticate(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, sso.ErrInvalidCredentials) {
+			return &pb.LoginAdminResponse{
+				IsOk:    false,
+				Message: "请输入正确的用户名密码",
+			}, nil
+		}
+		remotelogs.Warn("SSO", "ldap provider '"+provider.Code+"' authenticate failed: "+err.Error())
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: "认证服务暂时不可用，请稍后再试",
+		}, nil
+	}
+
+	return this.loginAdminWithIdentity(provider, identity)
+}
+
+// CreateAdminOIDCAuthURL 生成跳转到OIDC认证服务的地址
+func (this *AdminService) CreateAdminOIDCAuthURL(ctx context.Context, req *pb.CreateAdminOIDCAuthURLRequest) (*pb.CreateAdminOIDCAuthURLResponse, error) {
+	_, _, _, err := rpcutils.ValidateRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Sid) == 0 {
+		return nil, errors.New("'sid' should not be empty")
+	}
+	if len(req.RedirectURL) == 0 {
+		return nil, errors.New("'redirectURL' should not be empty")
+	}
+
+	var tx = this.NullTx()
+	provider, err := this.findAdminSSOProvider(tx, req.ProviderCode, sso.ProviderTypeOIDC)
+	if err != nil {
+		return nil, err
+	}
+	oidcProvider, err := sso.NewOIDCProvider(provider.OIDC)
+	if err != nil {
+		return nil, err
+	}
+
+	var randomValues = []string{}
+	for i := 0; i < 3; i++ {
+		value, err := sso.NewRandomString()
+		if err != nil {
+			return nil, err
+		}
+		randomValues = append(randomValues, value)
+	}
+	var state, nonce, codeVerifier = randomValues[0], randomValues[1], randomValues[2]
+
+	authURL, err := oidcProvider.AuthCodeURL(req.RedirectURL, state, nonce, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedLoginSessionDAO.WriteSessionValue(tx, req.Sid, adminOIDCLoginSessionKey, maps.Map{
+		"providerCode": provider.Code,
+		"redirectURL":  req.RedirectURL,
+		"state":        state,
+		"nonce":        nonce,
+		"codeVerifier": codeVerifier,
+		"expiresAt":    time.Now().Unix() + models.AdminSSOStateTTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CreateAdminOIDCAuthURLResponse{Url: authURL}, nil
+}
+
+// LoginAdminWithOIDC 使用OIDC认证服务返回的授权码登录
+func (this *AdminService) LoginAdminWithOIDC(ctx context.Context, req *pb.LoginAdminWithOIDCRequest) (*pb.LoginAdminResponse, error) {
+	_, _, _, err := rpcutils.ValidateRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Sid) == 0 || len(req.State) == 0 || len(req.Code) == 0 {
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: "登录请求参数错误，请重新登录",
+		}, nil
+	}
+
+	var tx = this.NullTx()
+
+	// 登录状态只能使用一次
+	value, err := models.SharedLoginSessionDAO.PopSessionValue(tx, req.Sid, adminOIDCLoginSessionKey)
+	if err != nil {
+		return nil, err
+	}
+	var stateMap = maps.NewMap(value)
+	if len(stateMap.GetString("state")) == 0 ||
+		stateMap.GetString("state") != req.State ||
+		stateMap.GetString("providerCode") != req.ProviderCode ||
+		stateMap.GetInt64("expiresAt") < time.Now().Unix() {
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: "登录状态已失效，请重新登录",
+		}, nil
+	}
+
+	provider, err := this.findAdminSSOProvider(tx, req.ProviderCode, sso.ProviderTypeOIDC)
+	if err != nil {
+		return nil, err
+	}
+	oidcProvider, err := sso.NewOIDCProvider(provider.OIDC)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := oidcProvider.Exchange(req.Code, stateMap.GetString("redirectURL"), stateMap.GetString("nonce"), stateMap.GetString("codeVerifier"))
+	if err != nil {
+		remotelogs.Warn("SSO", "oidc provider '"+provider.Code+"' exchange failed: "+err.Error())
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: "认证失败，请重新登录",
+		}, nil
+	}
+
+	return this.loginAdminWithIdentity(provider, identity)
+}
+
+// 查找启用的认证服务
+func (this *AdminService) findAdminSSOProvider(tx *dbs.Tx, providerCode string, providerType sso.ProviderType) (*models.AdminSSOProvider, error) {
+	config, err := models.SharedSysSettingDAO.ReadAdminSSOConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	var provider = config.FindProvider(providerCode)
+	if provider == nil || provider.Type != providerType {
+		return nil, errors.New("sso provider '" + providerCode + "' not found")
+	}
+	return provider, nil
+}
+
+// 根据外部身份查找或创建管理员
+func (this *AdminService) loginAdminWithIdentity(provider *models.AdminSSOProvider, identity *sso.Identity) (*pb.LoginAdminResponse, error) {
+	matched, isSuper, moduleCodes := sso.MatchRoles(identity.Groups, provider.Mappings)
+	if provider.RequireMapping && !matched {
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: "当前账号没有登录权限，请联系管理员",
+		}, nil
+	}
+
+	var modules = []*systemconfigs.AdminModule{}
+	for _, code := range moduleCodes {
+		modules = append(modules, &systemconfigs.AdminModule{
+			Code:     code,
+			AllowAll: true,
+			Actions:  []string{},
+		})
+	}
+	modulesJSON, err := json.Marshal(modules)
+	if err != nil {
+		return nil, err
+	}
+
+	var adminId int64
+	var message string
+	err = this.RunTx(func(tx *dbs.Tx) error {
+		ssoIdentity, err := models.SharedAdminSSOIdentityDAO.FindEnabledIdentity(tx, provider.Code, identity.Subject)
+		if err != nil {
+			return err
+		}
+
+		// 已经关联过的管理员
+		if ssoIdentity != nil {
+			admin, err := models.SharedAdminDAO.FindEnabledAdmin(tx, int64(ssoIdentity.AdminId))
+			if err != nil {
+				return err
+			}
+			if admin == nil || !admin.IsOn {
+				message = "当前账号已被禁用，请联系管理员"
+				return nil
+			}
+			if provider.SyncRoles {
+				err = models.SharedAdminDAO.UpdateAdminRoles(tx, int64(admin.Id), isSuper, modulesJSON)
+				if err != nil {
+					return err
+				}
+			}
+			err = models.SharedAdminSSOIdentityDAO.UpdateIdentityLogin(tx, int64(ssoIdentity.Id), identity)
+			if err != nil {
+				return err
+			}
+			adminId = int64(admin.Id)
+			return nil
+		}
+
+		// 第一次登录
+		if !provider.AutoCreate {
+			message = "当前账号尚未开通，请联系管理员"
+			return nil
+		}
+
+		// 不能接管已有的本地管理员
+		exists, err := models.SharedAdminDAO.CheckAdminUsername(tx, 0, identity.Username)
+		if err != nil {
+			return err
+		}
+		if exists {
+			remotelogs.Warn("SSO", "provider '"+provider.Code+"': username '"+identity.Username+"' is already used by another admin")
+			message = "用户名'" + identity.Username + "'已被其他管理员使用，请联系管理员"
+			return nil
+		}
+
+		// 本地密码随机生成，并且不允许使用密码登录
+		password, err := sso.NewRandomString()
+		if err != nil {
+			return err
+		}
+		var fullname = identity.Fullname
+		if len(fullname) == 0 {
+			fullname = identity.Username
+		}
+		adminId, err = models.SharedAdminDAO.CreateAdmin(tx, identity.Username, false, password, fullname, isSuper, modulesJSON)
+		if err != nil {
+			return err
+		}
+		_, err = models.SharedAdminSSOIdentityDAO.CreateIdentity(tx, adminId, provider.Code, identity)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if adminId <= 0 {
+		return &pb.LoginAdminResponse{
+			IsOk:    false,
+			Message: message,
+		}, nil
+	}
+	// 和使用密码登录一样，需要继续校验OTP和安全密钥
+	requireOTP, requireWebAuthn, requireWebAuthnRegistration, err := this.checkAdminSecondFactors(this.NullTx(), adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.LoginAdminResponse{
+		AdminId:                     adminId,
+		IsOk:                        true,
+		RequireOTP:                  requireOTP,
+		RequireWebAuthn:             requireWebAuthn,
+		RequireWebAuthnRegistration: requireWebAuthnRegistration,
+	}, nil
+}
+
+// 校验超级管理员
+func (this *AdminService) validateSuperAdmin(ctx context.Context) (adminId int64, err error) {
+	adminId, err = this.ValidateAdmin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	isSuper, err := models.SharedAdminDAO.CheckSuperAdmin(this.NullTx(), adminId)
+	if err != nil {
+		return 0, err
+	}
+	if !isSuper {
+		return 0, this.PermissionError()
+	}
+	return adminId, nil
+}
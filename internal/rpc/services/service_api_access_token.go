@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
 )
 
 // APIAccessTokenService AccessToken相关服务
@@ -31,53 +33,195 @@ func (this *APIAccessTokenService) GetAPIAccessToken(ctx context.Context, req *p
 		return nil, errors.New("access key not found")
 	}
 
+	// 检查访问范围
+	for _, scope := range req.Scopes {
+		if !rpcutils.IsValidAccessScope(scope) {
+			return nil, errors.New("invalid scope '" + scope + "'")
+		}
+	}
+
+	// 同时带有令牌时，新的令牌不能比原令牌拥有更大的范围
+	plainCtx, ok := ctx.(*rpcutils.PlainContext)
+	if ok && !rpcutils.AccessScopesContain(plainCtx.Scopes, req.Scopes) {
+		return nil, errors.New("the scopes should not exceed the scopes of current access token")
+	}
+
 	// 检查数据
-	switch req.Type {
+	err = this.checkAccessKeyOwner(tx, req.Type, accessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// 更新AccessKey访问时间
+	err = models.SharedUserAccessKeyDAO.UpdateAccessKeyAccessedAt(tx, int64(accessKey.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建AccessToken
+	token, err := models.SharedAPIAccessTokenDAO.GenerateAccessToken(tx, int64(accessKey.AdminId), int64(accessKey.UserId), int64(accessKey.Id), req.Scopes, req.Ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetAPIAccessTokenResponse{
+		Token:            token.Token,
+		ExpiresAt:        token.ExpiresAt,
+		RefreshToken:     token.RefreshToken,
+		RefreshExpiresAt: token.RefreshExpiresAt,
+		Scopes:           token.Scopes,
+	}, nil
+}
+
+// RefreshAPIAccessToken 使用刷新令牌获取新的AccessToken
+func (this *APIAccessTokenService) RefreshAPIAccessToken(ctx context.Context, req *pb.RefreshAPIAccessTokenRequest) (*pb.RefreshAPIAccessTokenResponse, error) {
+	var tx = this.NullTx()
+
+	oldToken, token, err := models.SharedAPIAccessTokenDAO.RefreshAccessToken(tx, req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	// 检查AccessKey和用户状态
+	if oldToken.AccessKeyId > 0 {
+		accessKey, err := models.SharedUserAccessKeyDAO.FindEnabledUserAccessKey(tx, int64(oldToken.AccessKeyId))
+		if err != nil {
+			return nil, err
+		}
+		if accessKey == nil || !accessKey.IsOn {
+			_ = models.SharedAPIAccessTokenDAO.DeleteAccessToken(tx, int64(oldToken.Id))
+			return nil, errors.New("access key not found")
+		}
+
+		var userType = "admin"
+		if accessKey.UserId > 0 {
+			userType = "user"
+		}
+		err = this.checkAccessKeyOwner(tx, userType, accessKey)
+		if err != nil {
+			_ = models.SharedAPIAccessTokenDAO.DeleteAccessToken(tx, int64(oldToken.Id))
+			return nil, err
+		}
+	}
+
+	return &pb.RefreshAPIAccessTokenResponse{
+		Token:            token.Token,
+		ExpiresAt:        token.ExpiresAt,
+		RefreshToken:     token.RefreshToken,
+		RefreshExpiresAt: token.RefreshExpiresAt,
+		Scopes:           token.Scopes,
+	}, nil
+}
+
+// RevokeAPIAccessToken 撤销AccessToken
+// 可以使用令牌或者刷新令牌撤销，撤销后两者同时失效
+func (this *APIAccessTokenService) RevokeAPIAccessToken(ctx context.Context, req *pb.RevokeAPIAccessTokenRequest) (*pb.RPCSuccess, error) {
+	var tx = this.NullTx()
+	err := models.SharedAPIAccessTokenDAO.RevokeAccessToken(tx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindAllAPIAccessTokensWithUserAccessKeyId 列出某个AccessKey的所有有效令牌
+func (this *APIAccessTokenService) FindAllAPIAccessTokensWithUserAccessKeyId(ctx context.Context, req *pb.FindAllAPIAccessTokensWithUserAccessKeyIdRequest) (*pb.FindAllAPIAccessTokensWithUserAccessKeyIdResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	if userId > 0 {
+		ok, err := models.SharedUserAccessKeyDAO.CheckUserAccessKey(tx, 0, userId, req.UserAccessKeyId)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, this.PermissionError()
+		}
+	}
+
+	tokens, err := models.SharedAPIAccessTokenDAO.FindAllAccessTokensWithAccessKeyId(tx, req.UserAccessKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbTokens = []*pb.APIAccessToken{}
+	for _, token := range tokens {
+		pbTokens = append(pbTokens, &pb.APIAccessToken{
+			Id:               int64(token.Id),
+			UserAccessKeyId:  int64(token.AccessKeyId),
+			Scopes:           token.DecodeScopes(),
+			CreatedAt:        int64(token.CreatedAt),
+			ExpiresAt:        int64(token.ExpiredAt),
+			RefreshExpiresAt: int64(token.RefreshExpiredAt),
+		})
+	}
+	return &pb.FindAllAPIAccessTokensWithUserAccessKeyIdResponse{ApiAccessTokens: pbTokens}, nil
+}
+
+// DeleteAPIAccessToken 删除某个令牌
+func (this *APIAccessTokenService) DeleteAPIAccessToken(ctx context.Context, req *pb.DeleteAPIAccessTokenRequest) (*pb.RPCSuccess, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	token, err := models.SharedAPIAccessTokenDAO.FindEnabledAccessToken(tx, req.ApiAccessTokenId)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return this.Success()
+	}
+	if userId > 0 && int64(token.UserId) != userId {
+		return nil, this.PermissionError()
+	}
+
+	err = models.SharedAPIAccessTokenDAO.DeleteAccessToken(tx, req.ApiAccessTokenId)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// 检查AccessKey所属的用户或管理员是否可用
+func (this *APIAccessTokenService) checkAccessKeyOwner(tx *dbs.Tx, userType string, accessKey *models.UserAccessKey) error {
+	switch userType {
 	case "user":
 		// TODO 将来支持子用户
 		if accessKey.UserId == 0 {
-			return nil, errors.New("access key not found")
+			return errors.New("access key not found")
 		}
 
 		// 检查用户状态
 		user, err := models.SharedUserDAO.FindEnabledUser(tx, int64(accessKey.UserId), nil)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if user == nil || !user.IsOn {
-			return nil, errors.New("the user is not available")
+			return errors.New("the user is not available")
 		}
 	case "admin":
 		if accessKey.AdminId == 0 {
-			return nil, errors.New("access key not found")
+			return errors.New("access key not found")
 		}
 
 		// 检查管理员状态
 		admin, err := models.SharedAdminDAO.FindEnabledAdmin(tx, int64(accessKey.AdminId))
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if admin == nil || !admin.IsOn {
-			return nil, errors.New("the admin is not available")
+			return errors.New("the admin is not available")
 		}
 	default:
-		return nil, errors.New("invalid type '" + req.Type + "'")
-	}
-
-	// 更新AccessKey访问时间
-	err = models.SharedUserAccessKeyDAO.UpdateAccessKeyAccessedAt(tx, int64(accessKey.Id))
-	if err != nil {
-		return nil, err
+		return errors.New("invalid type '" + userType + "'")
 	}
-
-	// 创建AccessToken
-	token, expiresAt, err := models.SharedAPIAccessTokenDAO.GenerateAccessToken(tx, int64(accessKey.AdminId), int64(accessKey.UserId))
-	if err != nil {
-		return nil, err
-	}
-
-	return &pb.GetAPIAccessTokenResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
-	}, nil
+	return nil
 }
@@ -31,6 +31,12 @@ func (this *HTTPAccessLogService) CreateHTTPAccessLogs(ctx context.Context, req
 
 	var tx = this.NullTx()
 
+	// 写入到存储策略
+	err = this.writeAccessLogsToPolicy(req.HttpAccessLogs)
+	if err != nil {
+		return nil, err
+	}
+
 	if this.canWriteAccessLogsToDB() {
 		err = models.SharedHTTPAccessLogDAO.CreateHTTPAccessLogs(tx, req.HttpAccessLogs)
 		if err != nil {
@@ -38,11 +44,6 @@ func (this *HTTPAccessLogService) CreateHTTPAccessLogs(ctx context.Context, req
 		}
 	}
 
-	err = this.writeAccessLogsToPolicy(req.HttpAccessLogs)
-	if err != nil {
-		return nil, err
-	}
-
 	return &pb.CreateHTTPAccessLogsResponse{}, nil
 }
 
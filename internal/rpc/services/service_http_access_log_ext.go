@@ -3,12 +3,23 @@
 
 package services
 
-import "github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/accesslogs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+)
 
+// 是否同时写入到默认的数据库中
 func (this *HTTPAccessLogService) canWriteAccessLogsToDB() bool {
-	return true
+	return !accesslogs.SharedStorageManager.DisableDefaultDB()
 }
 
+// 写入日志到所有生效的存储策略
 func (this *HTTPAccessLogService) writeAccessLogsToPolicy(pbAccessLogs []*pb.HTTPAccessLog) error {
+	if !accesslogs.SharedStorageManager.HasPolicies() {
+		return nil
+	}
+
+	// 日志会被异步写入，写入数据库的过程不会修改日志内容，所以这里不需要复制
+	accesslogs.SharedStorageManager.WriteToAllPolicies(pbAccessLogs)
 	return nil
 }
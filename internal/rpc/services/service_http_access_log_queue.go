@@ -0,0 +1,76 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"time"
+)
+
+// FindHTTPAccessLogQueueStats 查看访问日志队列积压情况
+func (this *HTTPAccessLogService) FindHTTPAccessLogQueueStats(ctx context.Context, req *pb.FindHTTPAccessLogQueueStatsRequest) (*pb.FindHTTPAccessLogQueueStatsResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats = models.SharedHTTPAccessLogDAO.FindHTTPAccessLogQueueStats()
+	var oldestAt int64
+	var ageSeconds int64
+	if !stats.OldestAt.IsZero() {
+		oldestAt = stats.OldestAt.Unix()
+		ageSeconds = int64(time.Since(stats.OldestAt).Seconds())
+	}
+	return &pb.FindHTTPAccessLogQueueStatsResponse{
+		MemoryCount:  stats.MemoryCount,
+		SpoolIsOn:    stats.SpoolIsOn,
+		SpoolCount:   stats.SpoolCount,
+		SpoolSize:    stats.SpoolSize,
+		SpoolFiles:   int32(stats.SpoolFiles),
+		OldestAt:     oldestAt,
+		AgeSeconds:   ageSeconds,
+		DroppedCount: stats.DroppedCount,
+	}, nil
+}
+
+// FindHTTPAccessLogSpoolConfig 读取访问日志磁盘队列配置
+func (this *HTTPAccessLogService) FindHTTPAccessLogSpoolConfig(ctx context.Context, req *pb.FindHTTPAccessLogSpoolConfigRequest) (*pb.FindHTTPAccessLogSpoolConfigResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := models.SharedSysSettingDAO.ReadHTTPAccessLogSpoolConfig(this.NullTx())
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FindHTTPAccessLogSpoolConfigResponse{ConfigJSON: configJSON}, nil
+}
+
+// UpdateHTTPAccessLogSpoolConfig 修改访问日志磁盘队列配置
+// 修改后需要重启API节点才能生效
+func (this *HTTPAccessLogService) UpdateHTTPAccessLogSpoolConfig(ctx context.Context, req *pb.UpdateHTTPAccessLogSpoolConfigRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var config = models.DefaultHTTPAccessLogSpoolConfig()
+	err = json.Unmarshal(req.ConfigJSON, config)
+	if err != nil {
+		return nil, errors.New("decode config failed: " + err.Error())
+	}
+	err = models.SharedSysSettingDAO.UpdateHTTPAccessLogSpoolConfig(this.NullTx(), config)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
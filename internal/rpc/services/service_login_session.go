@@ -4,6 +4,7 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
@@ -71,17 +72,17 @@ func (this *LoginSessionService) FindLoginSession(ctx context.Context, req *pb.F
 		}, nil
 	}
 
+	// 记录最后活跃时间
+	err = models.SharedLoginSessionDAO.UpdateSessionActiveAt(tx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbSession = this.convertLoginSession(session)
+	pbSession.Sid = session.Sid
+	pbSession.ValuesJSON = session.Values
 	return &pb.FindLoginSessionResponse{
-		LoginSession: &pb.LoginSession{
-			Id:         int64(session.Id),
-			Sid:        session.Sid,
-			AdminId:    int64(session.AdminId),
-			UserId:     int64(session.UserId),
-			Ip:         session.Ip,
-			CreatedAt:  int64(session.CreatedAt),
-			ExpiresAt:  int64(session.ExpiresAt),
-			ValuesJSON: session.Values,
-		},
+		LoginSession: pbSession,
 	}, nil
 }
 
@@ -112,3 +113,170 @@ func (this *LoginSessionService) ClearOldLoginSessions(ctx context.Context, req
 
 	return this.Success()
 }
+
+// FindAllLoginSessions 列出管理员或用户当前在线的SESSION
+func (this *LoginSessionService) FindAllLoginSessions(ctx context.Context, req *pb.FindAllLoginSessionsRequest) (*pb.FindAllLoginSessionsResponse, error) {
+	adminId, userId, err := this.validateLoginSessionOwner(ctx, req.AdminId, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	sessions, err := models.SharedLoginSessionDAO.FindAllAvailableSessions(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	var pbSessions = []*pb.LoginSession{}
+	for _, session := range sessions {
+		pbSessions = append(pbSessions, this.convertLoginSession(session))
+	}
+	return &pb.FindAllLoginSessionsResponse{LoginSessions: pbSessions}, nil
+}
+
+// RevokeLoginSession 强制某个SESSION退出登录
+func (this *LoginSessionService) RevokeLoginSession(ctx context.Context, req *pb.RevokeLoginSessionRequest) (*pb.RPCSuccess, error) {
+	if req.LoginSessionId <= 0 {
+		return nil, errors.New("invalid 'loginSessionId'")
+	}
+
+	var tx = this.NullTx()
+	session, err := models.SharedLoginSessionDAO.FindSessionWithId(tx, req.LoginSessionId)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return this.Success()
+	}
+
+	// 检查权限
+	_, _, err = this.validateLoginSessionOwner(ctx, int64(session.AdminId), int64(session.UserId))
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedLoginSessionDAO.DeleteSessionWithId(tx, int64(session.Id))
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// RevokeAllLoginSessions 强制管理员或用户的所有SESSION退出登录
+func (this *LoginSessionService) RevokeAllLoginSessions(ctx context.Context, req *pb.RevokeAllLoginSessionsRequest) (*pb.RPCSuccess, error) {
+	adminId, userId, err := this.validateLoginSessionOwner(ctx, req.AdminId, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = models.SharedLoginSessionDAO.DeleteAllSessions(tx, adminId, userId, req.ExceptSid)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindLoginSessionConfig 查找登录SESSION配置
+func (this *LoginSessionService) FindLoginSessionConfig(ctx context.Context, req *pb.FindLoginSessionConfigRequest) (*pb.FindLoginSessionConfigResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := models.SharedSysSettingDAO.ReadLoginSessionConfig(this.NullTx())
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FindLoginSessionConfigResponse{ConfigJSON: configJSON}, nil
+}
+
+// UpdateLoginSessionConfig 修改登录SESSION配置
+// 只有超级管理员可以修改
+func (this *LoginSessionService) UpdateLoginSessionConfig(ctx context.Context, req *pb.UpdateLoginSessionConfigRequest) (*pb.RPCSuccess, error) {
+	_, err := this.validateSuperAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var config = models.DefaultLoginSessionConfig()
+	err = json.Unmarshal(req.ConfigJSON, config)
+	if err != nil {
+		return nil, errors.New("decode config failed: " + err.Error())
+	}
+	err = models.SharedSysSettingDAO.UpdateLoginSessionConfig(this.NullTx(), config)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// 检查是否可以管理某个管理员或用户的SESSION
+// 用户只能管理自己的SESSION；管理员可以管理自己和用户的SESSION，只有超级管理员可以管理其他管理员的SESSION；没有指定时为管理员自己
+func (this *LoginSessionService) validateLoginSessionOwner(ctx context.Context, reqAdminId int64, reqUserId int64) (adminId int64, userId int64, err error) {
+	ctxAdminId, ctxUserId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if ctxUserId > 0 {
+		if reqAdminId > 0 || (reqUserId > 0 && reqUserId != ctxUserId) {
+			return 0, 0, this.PermissionError()
+		}
+		return 0, ctxUserId, nil
+	}
+
+	// 尚未登录的管理平台请求
+	if ctxAdminId <= 0 {
+		return 0, 0, this.PermissionError()
+	}
+	if reqAdminId > 0 {
+		if reqAdminId != ctxAdminId {
+			isSuper, err := models.SharedAdminDAO.CheckSuperAdmin(this.NullTx(), ctxAdminId)
+			if err != nil {
+				return 0, 0, err
+			}
+			if !isSuper {
+				return 0, 0, this.PermissionError()
+			}
+		}
+		return reqAdminId, 0, nil
+	}
+	if reqUserId > 0 {
+		return 0, reqUserId, nil
+	}
+	return ctxAdminId, 0, nil
+}
+
+// 校验超级管理员
+func (this *LoginSessionService) validateSuperAdmin(ctx context.Context) (adminId int64, err error) {
+	adminId, err = this.ValidateAdmin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	isSuper, err := models.SharedAdminDAO.CheckSuperAdmin(this.NullTx(), adminId)
+	if err != nil {
+		return 0, err
+	}
+	if !isSuper {
+		return 0, this.PermissionError()
+	}
+	return adminId, nil
+}
+
+// 转换为RPC对象，其中不包含SID和数据，以免泄露登录凭证
+func (this *LoginSessionService) convertLoginSession(session *models.LoginSession) *pb.LoginSession {
+	return &pb.LoginSession{
+		Id:        int64(session.Id),
+		AdminId:   int64(session.AdminId),
+		UserId:    int64(session.UserId),
+		Ip:        session.Ip,
+		UserAgent: session.UserAgent,
+		CreatedAt: int64(session.CreatedAt),
+		ActiveAt:  int64(session.ActiveAt),
+		ExpiresAt: int64(session.ExpiresAt),
+	}
+}
@@ -0,0 +1,668 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/webauthn"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	loginWebAuthnChallengeSessionKey = "@webAuthnChallenge"  // SESSION中保存挑战码的键
+	loginWebAuthnVerifiedSessionKey  = "@webAuthnVerifiedAt" // SESSION中保存验证通过时间的键
+
+	loginWebAuthnPurposeRegister = "register"
+	loginWebAuthnPurposeLogin    = "login"
+)
+
+// FindLoginWebAuthnConfig 查找安全密钥登录配置
+func (this *LoginService) FindLoginWebAuthnConfig(ctx context.Context, req *pb.FindLoginWebAuthnConfigRequest) (*pb.FindLoginWebAuthnConfigResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := models.SharedSysSettingDAO.ReadLoginWebAuthnConfig(this.NullTx())
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FindLoginWebAuthnConfigResponse{ConfigJSON: configJSON}, nil
+}
+
+// UpdateLoginWebAuthnConfig 修改安全密钥登录配置
+func (this *LoginService) UpdateLoginWebAuthnConfig(ctx context.Context, req *pb.UpdateLoginWebAuthnConfigRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var config = models.DefaultLoginWebAuthnConfig()
+	err = json.Unmarshal(req.ConfigJSON, config)
+	if err != nil {
+		return nil, errors.New("decode config failed: " + err.Error())
+	}
+	err = models.SharedSysSettingDAO.UpdateLoginWebAuthnConfig(this.NullTx(), config)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// CreateLoginWebAuthnRegistrationOptions 生成注册安全密钥的选项
+func (this *LoginService) CreateLoginWebAuthnRegistrationOptions(ctx context.Context, req *pb.CreateLoginWebAuthnRegistrationOptionsRequest) (*pb.CreateLoginWebAuthnRegistrationOptionsResponse, error) {
+	adminId, userId, err := this.validateLoginOwner(ctx, req.AdminId, req.UserId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	config, err := models.SharedSysSettingDAO.ReadLoginWebAuthnConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 用户信息
+	var userEntity webauthn.UserEntity
+	if adminId > 0 {
+		admin, err := models.SharedAdminDAO.FindEnabledAdmin(tx, adminId)
+		if err != nil {
+			return nil, err
+		}
+		if admin == nil {
+			return nil, errors.New("admin not found")
+		}
+		userEntity = webauthn.UserEntity{
+			Id:          webauthn.EncodeBase64([]byte("admin:" + types.String(adminId))),
+			Name:        admin.Username,
+			DisplayName: admin.Fullname,
+		}
+	} else {
+		user, err := models.SharedUserDAO.FindEnabledUser(tx, userId, nil)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, errors.New("user not found")
+		}
+		userEntity = webauthn.UserEntity{
+			Id:          webauthn.EncodeBase64([]byte("user:" + types.String(userId))),
+			Name:        user.Username,
+			DisplayName: user.Fullname,
+		}
+	}
+	if len(userEntity.DisplayName) == 0 {
+		userEntity.DisplayName = userEntity.Name
+	}
+
+	credentials, err := models.SharedLoginWebAuthnCredentialDAO.FindAllEnabledCredentials(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	var excludeCredentials = []webauthn.CredentialDescriptor{}
+	for _, credential := range credentials {
+		excludeCredentials = append(excludeCredentials, webauthn.NewCredentialDescriptor(credential.DecodeCredentialId(), credential.DecodeTransports()))
+	}
+
+	challenge, err := this.createLoginWebAuthnChallenge(tx, req.Sid, loginWebAuthnPurposeRegister, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var rp = config.NewRelyingParty(this.hostWithoutPort(req.Host))
+	optionsJSON, err := json.Marshal(rp.NewCreationOptions(challenge, userEntity, excludeCredentials, config.UserVerification))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateLoginWebAuthnRegistrationOptionsResponse{OptionsJSON: optionsJSON}, nil
+}
+
+// RegisterLoginWebAuthnCredential 注册安全密钥
+// 注册第一个安全密钥时会启用安全密钥登录，并生成恢复码
+// 已经有安全密钥时，需要在最近通过安全密钥或恢复码校验后才能注册新的安全密钥
+func (this *LoginService) RegisterLoginWebAuthnCredential(ctx context.Context, req *pb.RegisterLoginWebAuthnCredentialRequest) (*pb.RegisterLoginWebAuthnCredentialResponse, error) {
+	adminId, userId, err := this.validateLoginOwner(ctx, req.AdminId, req.UserId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	err = this.checkLoginCallerReverified(ctx, tx, req.Sid)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := models.SharedSysSettingDAO.ReadLoginWebAuthnConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := this.popLoginWebAuthnChallenge(tx, req.Sid, loginWebAuthnPurposeRegister, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var rp = config.NewRelyingParty(this.hostWithoutPort(req.Host))
+	credential, err := rp.VerifyRegistration(challenge, &webauthn.RegistrationResponse{
+		ClientDataJSON:    req.ClientDataJSON,
+		AttestationObject: req.AttestationObject,
+	}, config.RequireUserVerification())
+	if err != nil {
+		return nil, errors.New("verify security key failed: " + err.Error())
+	}
+
+	var name = strings.TrimSpace(req.Name)
+	if len(name) == 0 {
+		name = "Security Key"
+	}
+
+	var credentialId int64
+	var recoveryCodes []string
+	err = this.RunTx(func(tx *dbs.Tx) error {
+		count, err := models.SharedLoginWebAuthnCredentialDAO.CountEnabledCredentials(tx, adminId, userId)
+		if err != nil {
+			return err
+		}
+
+		credentialId, err = models.SharedLoginWebAuthnCredentialDAO.CreateCredential(tx, adminId, userId, name, credential, req.Transports)
+		if err != nil {
+			return err
+		}
+
+		err = models.SharedLoginDAO.UpdateLogin(tx, adminId, userId, models.LoginTypeWebAuthn, maps.Map{}, true)
+		if err != nil {
+			return err
+		}
+
+		// 第一个安全密钥
+		if count == 0 {
+			recoveryCodes, err = models.SharedLoginDAO.GenerateRecoveryCodes(tx, adminId, userId)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RegisterLoginWebAuthnCredentialResponse{
+		LoginWebAuthnCredentialId: credentialId,
+		RecoveryCodes:             recoveryCodes,
+	}, nil
+}
+
+// FindAllLoginWebAuthnCredentials 列出所有安全密钥
+func (this *LoginService) FindAllLoginWebAuthnCredentials(ctx context.Context, req *pb.FindAllLoginWebAuthnCredentialsRequest) (*pb.FindAllLoginWebAuthnCredentialsResponse, error) {
+	adminId, userId, err := this.validateLoginOwner(ctx, req.AdminId, req.UserId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	credentials, err := models.SharedLoginWebAuthnCredentialDAO.FindAllEnabledCredentials(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	var pbCredentials = []*pb.LoginWebAuthnCredential{}
+	for _, credential := range credentials {
+		pbCredentials = append(pbCredentials, &pb.LoginWebAuthnCredential{
+			Id:           int64(credential.Id),
+			Name:         credential.Name,
+			CredentialId: credential.CredentialId,
+			Aaguid:       credential.Aaguid,
+			Transports:   credential.DecodeTransports(),
+			CreatedAt:    int64(credential.CreatedAt),
+			LastUsedAt:   int64(credential.LastUsedAt),
+		})
+	}
+
+	countRecoveryCodes, err := models.SharedLoginDAO.CountRecoveryCodes(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.FindAllLoginWebAuthnCredentialsResponse{
+		LoginWebAuthnCredentials: pbCredentials,
+		CountRecoveryCodes:       int32(countRecoveryCodes),
+	}, nil
+}
+
+// UpdateLoginWebAuthnCredentialName 修改安全密钥名称
+func (this *LoginService) UpdateLoginWebAuthnCredentialName(ctx context.Context, req *pb.UpdateLoginWebAuthnCredentialNameRequest) (*pb.RPCSuccess, error) {
+	var tx = this.NullTx()
+	_, err := this.findLoginWebAuthnCredential(ctx, tx, req.LoginWebAuthnCredentialId)
+	if err != nil {
+		return nil, err
+	}
+
+	var name = strings.TrimSpace(req.Name)
+	if len(name) == 0 {
+		return nil, errors.New("'name' should not be empty")
+	}
+	err = models.SharedLoginWebAuthnCredentialDAO.UpdateCredentialName(tx, req.LoginWebAuthnCredentialId, name)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// DeleteLoginWebAuthnCredential 删除安全密钥
+// 删除最后一个安全密钥后，自动停用安全密钥登录和恢复码
+// 需要在最近通过安全密钥或恢复码校验后才能删除
+func (this *LoginService) DeleteLoginWebAuthnCredential(ctx context.Context, req *pb.DeleteLoginWebAuthnCredentialRequest) (*pb.RPCSuccess, error) {
+	credential, err := this.findLoginWebAuthnCredential(ctx, this.NullTx(), req.LoginWebAuthnCredentialId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.checkLoginCallerReverified(ctx, this.NullTx(), req.Sid)
+	if err != nil {
+		return nil, err
+	}
+
+	var adminId = int64(credential.AdminId)
+	var userId = int64(credential.UserId)
+	err = this.RunTx(func(tx *dbs.Tx) error {
+		err := models.SharedLoginWebAuthnCredentialDAO.DisableCredential(tx, int64(credential.Id))
+		if err != nil {
+			return err
+		}
+
+		count, err := models.SharedLoginWebAuthnCredentialDAO.CountEnabledCredentials(tx, adminId, userId)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			err = models.SharedLoginDAO.DisableLoginWithType(tx, adminId, userId, models.LoginTypeWebAuthn)
+			if err != nil {
+				return err
+			}
+			err = models.SharedLoginDAO.DisableLoginWithType(tx, adminId, userId, models.LoginTypeRecoveryCodes)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// CreateLoginWebAuthnAssertionOptions 生成使用安全密钥登录的选项
+func (this *LoginService) CreateLoginWebAuthnAssertionOptions(ctx context.Context, req *pb.CreateLoginWebAuthnAssertionOptionsRequest) (*pb.CreateLoginWebAuthnAssertionOptionsResponse, error) {
+	adminId, userId, err := this.validateLoginOwner(ctx, req.AdminId, req.UserId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	config, err := models.SharedSysSettingDAO.ReadLoginWebAuthnConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := models.SharedLoginWebAuthnCredentialDAO.FindAllEnabledCredentials(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, errors.New("no security keys registered")
+	}
+	var allowCredentials = []webauthn.CredentialDescriptor{}
+	for _, credential := range credentials {
+		allowCredentials = append(allowCredentials, webauthn.NewCredentialDescriptor(credential.DecodeCredentialId(), credential.DecodeTransports()))
+	}
+
+	challenge, err := this.createLoginWebAuthnChallenge(tx, req.Sid, loginWebAuthnPurposeLogin, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var rp = config.NewRelyingParty(this.hostWithoutPort(req.Host))
+	optionsJSON, err := json.Marshal(rp.NewRequestOptions(challenge, allowCredentials, config.UserVerification))
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateLoginWebAuthnAssertionOptionsResponse{OptionsJSON: optionsJSON}, nil
+}
+
+// VerifyLoginWebAuthnAssertion 校验安全密钥登录
+// 校验通过后会在SESSION中记录通过的时间
+func (this *LoginService) VerifyLoginWebAuthnAssertion(ctx context.Context, req *pb.VerifyLoginWebAuthnAssertionRequest) (*pb.VerifyLoginWebAuthnAssertionResponse, error) {
+	adminId, userId, err := this.validateLoginOwner(ctx, req.AdminId, req.UserId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	config, err := models.SharedSysSettingDAO.ReadLoginWebAuthnConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 挑战码只能使用一次，无论校验是否成功
+	challenge, err := this.popLoginWebAuthnChallenge(tx, req.Sid, loginWebAuthnPurposeLogin, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := models.SharedLoginWebAuthnCredentialDAO.FindEnabledCredentialWithCredentialId(tx, adminId, userId, req.CredentialId)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return &pb.VerifyLoginWebAuthnAssertionResponse{IsOk: false}, nil
+	}
+
+	var rp = config.NewRelyingParty(this.hostWithoutPort(req.Host))
+	signCount, err := rp.VerifyAssertion(challenge, credential.DecodePublicKey(), credential.SignCount, &webauthn.AssertionResponse{
+		CredentialId:      req.CredentialId,
+		ClientDataJSON:    req.ClientDataJSON,
+		AuthenticatorData: req.AuthenticatorData,
+		Signature:         req.Signature,
+	}, config.RequireUserVerification())
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCountRegression) {
+			remotelogs.Warn("LOGIN", "security key '"+credential.Name+"' (id: "+types.String(credential.Id)+") sign count regression, it may have been cloned")
+		}
+		return &pb.VerifyLoginWebAuthnAssertionResponse{IsOk: false}, nil
+	}
+
+	err = models.SharedLoginWebAuthnCredentialDAO.UpdateCredentialSignCount(tx, int64(credential.Id), signCount)
+	if err != nil {
+		return nil, err
+	}
+	err = models.SharedLoginSessionDAO.WriteSessionValue(tx, req.Sid, loginWebAuthnVerifiedSessionKey, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.VerifyLoginWebAuthnAssertionResponse{IsOk: true}, nil
+}
+
+// GenerateLoginRecoveryCodes 重新生成恢复码
+// 只能由本人在最近通过安全密钥或恢复码校验后调用
+func (this *LoginService) GenerateLoginRecoveryCodes(ctx context.Context, req *pb.GenerateLoginRecoveryCodesRequest) (*pb.GenerateLoginRecoveryCodesResponse, error) {
+	adminId, userId, err := this.validateLoginOwner(ctx, req.AdminId, req.UserId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	count, err := models.SharedLoginWebAuthnCredentialDAO.CountEnabledCredentials(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("recovery codes can only be generated after a security key is registered")
+	}
+
+	// 需要先使用安全密钥或恢复码再次确认
+	err = this.checkLoginWebAuthnVerified(tx, req.Sid, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := models.SharedLoginDAO.GenerateRecoveryCodes(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GenerateLoginRecoveryCodesResponse{Codes: codes}, nil
+}
+
+// VerifyLoginRecoveryCode 使用恢复码代替安全密钥登录
+func (this *LoginService) VerifyLoginRecoveryCode(ctx context.Context, req *pb.VerifyLoginRecoveryCodeRequest) (*pb.VerifyLoginRecoveryCodeResponse, error) {
+	adminId, userId, err := this.validateLoginOwner(ctx, req.AdminId, req.UserId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	ok, err := models.SharedLoginDAO.UseRecoveryCode(tx, adminId, userId, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &pb.VerifyLoginRecoveryCodeResponse{IsOk: false}, nil
+	}
+
+	if len(req.Sid) > 0 {
+		err = models.SharedLoginSessionDAO.WriteSessionValue(tx, req.Sid, loginWebAuthnVerifiedSessionKey, time.Now().Unix())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	countRecoveryCodes, err := models.SharedLoginDAO.CountRecoveryCodes(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.VerifyLoginRecoveryCodeResponse{
+		IsOk:               true,
+		CountRecoveryCodes: int32(countRecoveryCodes),
+	}, nil
+}
+
+// 检查要操作的管理员或用户
+// 用户只能操作自己的认证，管理员只能操作自己和用户的认证，超级管理员可以操作其他管理员的认证
+// 只有登录过程中的校验（allowBeforeLogin）可以在登录之前调用
+func (this *LoginService) validateLoginOwner(ctx context.Context, adminId int64, userId int64, allowBeforeLogin bool) (resultAdminId int64, resultUserId int64, err error) {
+	reqUserType, _, reqUserId, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin, rpcutils.UserTypeUser)
+	if err != nil {
+		return 0, 0, err
+	}
+	if reqUserId < 0 {
+		return 0, 0, this.PermissionError()
+	}
+
+	switch reqUserType {
+	case rpcutils.UserTypeUser:
+		// 用户平台不能操作管理员的认证
+		if adminId > 0 {
+			return 0, 0, this.PermissionError()
+		}
+		if reqUserId > 0 {
+			return 0, reqUserId, nil
+		}
+		if allowBeforeLogin && userId > 0 {
+			return 0, userId, nil
+		}
+		return 0, 0, this.PermissionError()
+	case rpcutils.UserTypeAdmin:
+		if reqUserId == 0 {
+			if allowBeforeLogin && adminId > 0 {
+				return adminId, 0, nil
+			}
+			return 0, 0, this.PermissionError()
+		}
+		if adminId > 0 {
+			if adminId != reqUserId {
+				isSuper, err := models.SharedAdminDAO.CheckSuperAdmin(this.NullTx(), reqUserId)
+				if err != nil {
+					return 0, 0, err
+				}
+				if !isSuper {
+					return 0, 0, this.PermissionError()
+				}
+			}
+			return adminId, 0, nil
+		}
+		if userId > 0 {
+			return 0, userId, nil
+		}
+		return 0, 0, errors.New("require 'adminId' or 'userId'")
+	}
+	return 0, 0, this.PermissionError()
+}
+
+// 检查SESSION中是否在最近一段时间内通过了安全密钥校验，用于敏感操作之前的再次确认
+func (this *LoginService) checkLoginWebAuthnVerified(tx *dbs.Tx, sid string, adminId int64, userId int64) error {
+	var verifyErr = errors.New("please verify with a security key or a recovery code first")
+	if len(sid) == 0 {
+		return verifyErr
+	}
+	session, err := models.SharedLoginSessionDAO.FindSession(tx, sid)
+	if err != nil {
+		return err
+	}
+	if session == nil || !session.IsAvailable() || int64(session.AdminId) != adminId || int64(session.UserId) != userId {
+		return verifyErr
+	}
+	var values = maps.Map{}
+	if len(session.Values) > 0 {
+		err = json.Unmarshal(session.Values, &values)
+		if err != nil {
+			return err
+		}
+	}
+	if values.GetInt64(loginWebAuthnVerifiedSessionKey) < time.Now().Unix()-models.LoginWebAuthnReverifyTTL {
+		return verifyErr
+	}
+	return nil
+}
+
+// 检查调用者本人是否在最近通过了安全密钥或恢复码校验
+// 调用者还没有安全密钥时（比如注册第一个安全密钥）无法校验，直接通过
+func (this *LoginService) checkLoginCallerReverified(ctx context.Context, tx *dbs.Tx, sid string) error {
+	reqUserType, _, reqUserId, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin, rpcutils.UserTypeUser)
+	if err != nil {
+		return err
+	}
+	if reqUserId <= 0 {
+		return this.PermissionError()
+	}
+
+	var adminId int64
+	var userId int64
+	if reqUserType == rpcutils.UserTypeAdmin {
+		adminId = reqUserId
+	} else {
+		userId = reqUserId
+	}
+
+	count, err := models.SharedLoginWebAuthnCredentialDAO.CountEnabledCredentials(tx, adminId, userId)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	return this.checkLoginWebAuthnVerified(tx, sid, adminId, userId)
+}
+
+// 查找安全密钥，并检查权限
+// 只有安全密钥的所有者和超级管理员可以管理
+func (this *LoginService) findLoginWebAuthnCredential(ctx context.Context, tx *dbs.Tx, credentialId int64) (*models.LoginWebAuthnCredential, error) {
+	reqUserType, _, reqUserId, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAdmin, rpcutils.UserTypeUser)
+	if err != nil {
+		return nil, err
+	}
+	if reqUserId <= 0 {
+		return nil, this.PermissionError()
+	}
+
+	credential, err := models.SharedLoginWebAuthnCredentialDAO.FindEnabledCredential(tx, credentialId)
+	if err != nil {
+		return nil, err
+	}
+	var notFoundErr = errors.New("security key not found")
+	if credential == nil {
+		return nil, notFoundErr
+	}
+
+	switch reqUserType {
+	case rpcutils.UserTypeUser:
+		if credential.AdminId > 0 || int64(credential.UserId) != reqUserId {
+			return nil, notFoundErr
+		}
+	case rpcutils.UserTypeAdmin:
+		if credential.AdminId > 0 && int64(credential.AdminId) == reqUserId {
+			return credential, nil
+		}
+		isSuper, err := models.SharedAdminDAO.CheckSuperAdmin(tx, reqUserId)
+		if err != nil {
+			return nil, err
+		}
+		if !isSuper {
+			return nil, notFoundErr
+		}
+	default:
+		return nil, this.PermissionError()
+	}
+	return credential, nil
+}
+
+// 生成挑战码并保存到SESSION中
+func (this *LoginService) createLoginWebAuthnChallenge(tx *dbs.Tx, sid string, purpose string, adminId int64, userId int64) (string, error) {
+	if len(sid) == 0 {
+		return "", errors.New("'sid' should not be empty")
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", err
+	}
+	err = models.SharedLoginSessionDAO.WriteSessionValue(tx, sid, loginWebAuthnChallengeSessionKey, maps.Map{
+		"challenge": challenge,
+		"purpose":   purpose,
+		"adminId":   adminId,
+		"userId":    userId,
+		"expiresAt": time.Now().Unix() + models.LoginWebAuthnChallengeTTL,
+	})
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// 从SESSION中取出挑战码
+func (this *LoginService) popLoginWebAuthnChallenge(tx *dbs.Tx, sid string, purpose string, adminId int64, userId int64) (string, error) {
+	if len(sid) == 0 {
+		return "", errors.New("'sid' should not be empty")
+	}
+	value, err := models.SharedLoginSessionDAO.PopSessionValue(tx, sid, loginWebAuthnChallengeSessionKey)
+	if err != nil {
+		return "", err
+	}
+	var challengeMap = maps.NewMap(value)
+	var challenge = challengeMap.GetString("challenge")
+	if len(challenge) == 0 ||
+		challengeMap.GetString("purpose") != purpose ||
+		challengeMap.GetInt64("adminId") != adminId ||
+		challengeMap.GetInt64("userId") != userId ||
+		challengeMap.GetInt64("expiresAt") < time.Now().Unix() {
+		return "", errors.New("challenge not found or expired, please try again")
+	}
+	return challenge, nil
+}
+
+// 去掉域名中的端口
+func (this *LoginService) hostWithoutPort(host string) string {
+	host = strings.TrimSpace(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.Trim(host, "[]")
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/messagemedias"
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/maps"
+)
+
+// CreateNodeAction 创建节点动作
+func (this *NodeService) CreateNodeAction(ctx context.Context, req *pb.CreateNodeActionRequest) (*pb.CreateNodeActionResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.NodeId <= 0 {
+		return nil, errors.New("invalid 'nodeId'")
+	}
+	conds, action, err := this.decodeNodeAction(req.CondsJSON, req.ActionJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var role = req.Role
+	if len(role) == 0 {
+		role = nodeconfigs.NodeRoleNode
+	}
+
+	var tx = this.NullTx()
+	actionId, err := models.SharedNodeActionDAO.CreateNodeAction(tx, role, req.NodeId, conds, action)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CreateNodeActionResponse{NodeActionId: actionId}, nil
+}
+
+// UpdateNodeAction 修改节点动作
+func (this *NodeService) UpdateNodeAction(ctx context.Context, req *pb.UpdateNodeActionRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conds, action, err := this.decodeNodeAction(req.CondsJSON, req.ActionJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	nodeAction, err := models.SharedNodeActionDAO.FindEnabledNodeAction(tx, req.NodeActionId)
+	if err != nil {
+		return nil, err
+	}
+	if nodeAction == nil {
+		return nil, errors.New("node action not found")
+	}
+
+	err = models.SharedNodeActionDAO.UpdateNodeAction(tx, req.NodeActionId, conds, action, req.IsOn)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// DeleteNodeAction 删除节点动作
+func (this *NodeService) DeleteNodeAction(ctx context.Context, req *pb.DeleteNodeActionRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = models.SharedNodeActionDAO.DisableNodeAction(tx, req.NodeActionId)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindAllNodeActions 查找节点的所有动作
+func (this *NodeService) FindAllNodeActions(ctx context.Context, req *pb.FindAllNodeActionsRequest) (*pb.FindAllNodeActionsResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var role = req.Role
+	if len(role) == 0 {
+		role = nodeconfigs.NodeRoleNode
+	}
+
+	var tx = this.NullTx()
+	actions, err := models.SharedNodeActionDAO.FindAllEnabledNodeActions(tx, role, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	var pbActions = []*pb.NodeAction{}
+	for _, action := range actions {
+		pbActions = append(pbActions, &pb.NodeAction{
+			Id:         int64(action.Id),
+			NodeId:     int64(action.NodeId),
+			Role:       action.Role,
+			IsOn:       action.IsOn,
+			CondsJSON:  action.Conds,
+			ActionJSON: action.Action,
+			Order:      int32(action.Order),
+		})
+	}
+	return &pb.FindAllNodeActionsResponse{NodeActions: pbActions}, nil
+}
+
+// CountAllNodeActionLogs 计算节点动作执行日志数量
+func (this *NodeService) CountAllNodeActionLogs(ctx context.Context, req *pb.CountAllNodeActionLogsRequest) (*pb.RPCCountResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	count, err := models.SharedNodeActionLogDAO.CountLogs(tx, req.Role, req.NodeId, req.NodeActionId)
+	if err != nil {
+		return nil, err
+	}
+	return this.SuccessCount(count)
+}
+
+// ListNodeActionLogs 列出单页节点动作执行日志
+func (this *NodeService) ListNodeActionLogs(ctx context.Context, req *pb.ListNodeActionLogsRequest) (*pb.ListNodeActionLogsResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	logs, err := models.SharedNodeActionLogDAO.ListLogs(tx, req.Role, req.NodeId, req.NodeActionId, req.Offset, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	var pbLogs = []*pb.NodeActionLog{}
+	for _, log := range logs {
+		pbLogs = append(pbLogs, &pb.NodeActionLog{
+			Id:            int64(log.Id),
+			NodeActionId:  int64(log.ActionId),
+			Role:          log.Role,
+			NodeClusterId: int64(log.ClusterId),
+			NodeId:        int64(log.NodeId),
+			Event:         log.Event,
+			Code:          log.Code,
+			IsOk:          log.IsOk,
+			Error:         log.Error,
+			CreatedAt:     int64(log.CreatedAt),
+		})
+	}
+	return &pb.ListNodeActionLogsResponse{NodeActionLogs: pbLogs}, nil
+}
+
+// 解析并校验节点动作的条件和配置
+func (this *NodeService) decodeNodeAction(condsJSON []byte, actionJSON []byte) (*models.NodeActionConds, *models.NodeActionConfig, error) {
+	var conds = &models.NodeActionConds{}
+	if len(condsJSON) > 0 {
+		err := json.Unmarshal(condsJSON, conds)
+		if err != nil {
+			return nil, nil, errors.New("decode conds failed: " + err.Error())
+		}
+	}
+	if len(conds.Events) == 0 {
+		return nil, nil, errors.New("require at least one event")
+	}
+	for _, event := range conds.Events {
+		if event != models.NodeActionEventHealthCheckFailed && event != models.NodeActionEventHealthCheckRecovered {
+			return nil, nil, errors.New("unknown event '" + event + "'")
+		}
+	}
+
+	var action = &models.NodeActionConfig{}
+	err := json.Unmarshal(actionJSON, action)
+	if err != nil {
+		return nil, nil, errors.New("decode action failed: " + err.Error())
+	}
+	if action.Params == nil {
+		action.Params = maps.Map{}
+	}
+	if action.CooldownSeconds <= 0 {
+		action.CooldownSeconds = models.NodeActionDefaultCooldownSeconds
+	}
+
+	switch action.Code {
+	case models.NodeActionCodeSwitchToBackupCluster:
+		if action.Params.GetInt64("clusterId") <= 0 {
+			return nil, nil, errors.New("require backup cluster 'clusterId' in params")
+		}
+	case models.NodeActionCodeOffline, models.NodeActionCodeRestore:
+	case models.NodeActionCodeWebHook:
+		err = (&messagemedias.WebHookMedia{}).Init(action.Params)
+		if err != nil {
+			return nil, nil, errors.New("invalid webhook params: " + err.Error())
+		}
+	default:
+		return nil, nil, errors.New("unknown action code '" + action.Code + "'")
+	}
+
+	return conds, action, nil
+}
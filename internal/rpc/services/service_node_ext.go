@@ -5,6 +5,7 @@ package services
 
 import (
 	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 )
 
@@ -32,8 +33,20 @@ func (this *NodeService) UpdateNodeScheduleInfo(ctx context.Context, req *pb.Upd
 	return nil, this.NotImplementedYet()
 }
 
+// ResetNodeActionStatus 重置节点动作状态
 func (this *NodeService) ResetNodeActionStatus(ctx context.Context, req *pb.ResetNodeActionStatusRequest) (*pb.RPCSuccess, error) {
-	return nil, this.NotImplementedYet()
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	err = models.SharedNodeDAO.UpdateNodeActionStatus(tx, req.NodeId, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return this.Success()
 }
 
 func (this *NodeService) FindAllNodeScheduleInfoWithNodeClusterId(ctx context.Context, req *pb.FindAllNodeScheduleInfoWithNodeClusterIdRequest) (*pb.FindAllNodeScheduleInfoWithNodeClusterIdResponse, error) {
@@ -2,17 +2,15 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/installers"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils/numberutils"
-	"github.com/TeaOSLab/EdgeCommon/pkg/configutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
-	"golang.org/x/crypto/ssh"
-	"net"
+	"github.com/iwind/TeaGo/dbs"
 	"regexp"
 	"strings"
-	"time"
 )
 
 type NodeGrantService struct {
@@ -28,7 +26,12 @@ func (this *NodeGrantService) CreateNodeGrant(ctx context.Context, req *pb.Creat
 
 	var tx = this.NullTx()
 
-	grantId, err := models.SharedNodeGrantDAO.CreateGrant(tx, adminId, req.Name, req.Method, req.Username, req.Password, req.PrivateKey, req.Passphrase, req.Description, req.NodeId, req.Su)
+	err = this.validateJumpHosts(tx, 0, req.JumpHostsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	grantId, err := models.SharedNodeGrantDAO.CreateGrant(tx, adminId, req.Name, req.Method, req.Username, req.Password, req.PrivateKey, req.Passphrase, req.Certificate, req.JumpHostsJSON, req.Description, req.NodeId, req.Su)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +72,15 @@ func (this *NodeGrantService) UpdateNodeGrant(ctx context.Context, req *pb.Updat
 		req.PrivateKey = grant.PrivateKey
 	}
 
-	err = models.SharedNodeGrantDAO.UpdateGrant(tx, req.NodeGrantId, req.Name, req.Method, req.Username, req.Password, req.PrivateKey, req.Passphrase, req.Description, req.NodeId, req.Su)
+	err = this.validateJumpHosts(tx, req.NodeGrantId, req.JumpHostsJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.SharedNodeGrantDAO.UpdateGrant(tx, req.NodeGrantId, req.Name, req.Method, req.Username, req.Password, req.PrivateKey, req.Passphrase, req.Certificate, req.JumpHostsJSON, req.Description, req.NodeId, req.Su)
+	if err != nil {
+		return nil, err
+	}
 	return this.Success()
 }
 
@@ -118,15 +129,17 @@ func (this *NodeGrantService) ListEnabledNodeGrants(ctx context.Context, req *pb
 	result := []*pb.NodeGrant{}
 	for _, grant := range grants {
 		result = append(result, &pb.NodeGrant{
-			Id:          int64(grant.Id),
-			Name:        grant.Name,
-			Method:      grant.Method,
-			Username:    grant.Username,
-			Password:    grant.Password,
-			Su:          grant.Su == 1,
-			PrivateKey:  grant.PrivateKey,
-			Description: grant.Description,
-			NodeId:      int64(grant.NodeId),
+			Id:            int64(grant.Id),
+			Name:          grant.Name,
+			Method:        grant.Method,
+			Username:      grant.Username,
+			Password:      grant.Password,
+			Su:            grant.Su == 1,
+			PrivateKey:    grant.PrivateKey,
+			Certificate:   grant.Certificate,
+			JumpHostsJSON: grant.JumpHosts,
+			Description:   grant.Description,
+			NodeId:        int64(grant.NodeId),
 		})
 	}
 
@@ -146,15 +159,17 @@ func (this *NodeGrantService) FindAllEnabledNodeGrants(ctx context.Context, req
 	result := []*pb.NodeGrant{}
 	for _, grant := range grants {
 		result = append(result, &pb.NodeGrant{
-			Id:          int64(grant.Id),
-			Name:        grant.Name,
-			Method:      grant.Method,
-			Username:    grant.Username,
-			Password:    grant.Password,
-			Su:          grant.Su == 1,
-			PrivateKey:  grant.PrivateKey,
-			Description: grant.Description,
-			NodeId:      int64(grant.NodeId),
+			Id:            int64(grant.Id),
+			Name:          grant.Name,
+			Method:        grant.Method,
+			Username:      grant.Username,
+			Password:      grant.Password,
+			Su:            grant.Su == 1,
+			PrivateKey:    grant.PrivateKey,
+			Certificate:   grant.Certificate,
+			JumpHostsJSON: grant.JumpHosts,
+			Description:   grant.Description,
+			NodeId:        int64(grant.NodeId),
 		})
 	}
 
@@ -176,16 +191,18 @@ func (this *NodeGrantService) FindEnabledNodeGrant(ctx context.Context, req *pb.
 		return &pb.FindEnabledNodeGrantResponse{}, nil
 	}
 	return &pb.FindEnabledNodeGrantResponse{NodeGrant: &pb.NodeGrant{
-		Id:          int64(grant.Id),
-		Name:        grant.Name,
-		Method:      grant.Method,
-		Username:    grant.Username,
-		Password:    grant.Password,
-		Su:          grant.Su == 1,
-		PrivateKey:  grant.PrivateKey,
-		Passphrase:  grant.Passphrase,
-		Description: grant.Description,
-		NodeId:      int64(grant.NodeId),
+		Id:            int64(grant.Id),
+		Name:          grant.Name,
+		Method:        grant.Method,
+		Username:      grant.Username,
+		Password:      grant.Password,
+		Su:            grant.Su == 1,
+		PrivateKey:    grant.PrivateKey,
+		Passphrase:    grant.Passphrase,
+		Certificate:   grant.Certificate,
+		JumpHostsJSON: grant.JumpHosts,
+		Description:   grant.Description,
+		NodeId:        int64(grant.NodeId),
 	}}, nil
 }
 
@@ -196,8 +213,6 @@ func (this *NodeGrantService) TestNodeGrant(ctx context.Context, req *pb.TestNod
 		return nil, err
 	}
 
-	var hostKeyCallback ssh.HostKeyCallback = nil
-
 	resp := &pb.TestNodeGrantResponse{
 		IsOk:  false,
 		Error: "",
@@ -213,81 +228,25 @@ func (this *NodeGrantService) TestNodeGrant(ctx context.Context, req *pb.TestNod
 		return resp, nil
 	}
 
-	// 检查参数
-	if len(req.Host) == 0 {
-		resp.Error = "'host' should not be empty"
-		return resp, nil
-	}
-	if req.Port <= 0 {
-		resp.Error = "'port' should be greater than 0"
-		return resp, nil
-	}
+	// 使用已经记录的主机公钥校验，没有记录的主机在第一次连接时信任，但不会保存
+	var hostKeyStore = installers.NewKnownHostKeyStore()
+	var credentials = installers.NewCredentialsWithGrant(grant, req.Host, int(req.Port))
+	credentials.HostKeyStore = hostKeyStore
+	credentials.HostKeyMode = installers.HostKeyModeAcceptNew
 
-	if len(grant.Password) == 0 && len(grant.PrivateKey) == 0 {
-		resp.Error = "require user 'password' or 'privateKey'"
+	// 跳板机
+	jumpHosts, err := installers.ComposeJumpCredentials(tx, grant, nil, hostKeyStore, installers.HostKeyModeAcceptNew)
+	if err != nil {
+		resp.Error = err.Error()
 		return resp, nil
 	}
+	credentials.JumpHosts = jumpHosts
 
-	// 不使用known_hosts
-	if hostKeyCallback == nil {
-		hostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			return nil
-		}
-	}
-
-	// 认证
-	methods := []ssh.AuthMethod{}
-	if grant.Method == "user" {
-		{
-			authMethod := ssh.Password(grant.Password)
-			methods = append(methods, authMethod)
-		}
-
-		{
-			authMethod := ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) (answers []string, err error) {
-				if len(questions) == 0 {
-					return []string{}, nil
-				}
-				return []string{grant.Password}, nil
-			})
-			methods = append(methods, authMethod)
-		}
-	} else if grant.Method == "privateKey" {
-		var signer ssh.Signer
-		if len(grant.Passphrase) != 0 {
-			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(grant.PrivateKey), []byte(grant.Passphrase))
-		} else {
-			signer, err = ssh.ParsePrivateKey([]byte(grant.PrivateKey))
-		}
-		if err != nil {
-			resp.Error = "parse private key: " + err.Error()
-			return resp, nil
-		}
-		authMethod := ssh.PublicKeys(signer)
-		methods = append(methods, authMethod)
-	} else {
-		return nil, errors.New("invalid method '" + grant.Method + "'")
-	}
-
-	// SSH客户端
-	if len(grant.Username) == 0 {
-		grant.Username = "root"
-	}
-	config := &ssh.ClientConfig{
-		User:            grant.Username,
-		Auth:            methods,
-		HostKeyCallback: hostKeyCallback,
-		Timeout:         5 * time.Second, // TODO 后期可以设置这个超时时间
-	}
-
-	sshClient, err := ssh.Dial("tcp", configutils.QuoteIP(req.Host)+":"+fmt.Sprintf("%d", req.Port), config)
+	err = installers.TestCredentials(credentials)
 	if err != nil {
 		resp.Error = "connect failed: " + err.Error()
 		return resp, nil
 	}
-	defer func() {
-		_ = sshClient.Close()
-	}()
 
 	resp.IsOk = true
 	return resp, nil
@@ -324,3 +283,85 @@ func (this *NodeGrantService) FindSuggestNodeGrants(ctx context.Context, req *pb
 	}
 	return &pb.FindSuggestNodeGrantsResponse{NodeGrants: pbGrants}, nil
 }
+
+// 校验跳板机设置
+// 跳板机使用的认证信息必须存在，且不能引用当前认证信息自身，也不能通过其他认证信息的跳板机设置循环引用当前认证信息
+func (this *NodeGrantService) validateJumpHosts(tx *dbs.Tx, grantId int64, jumpHostsJSON []byte) error {
+	if !models.IsNotNull(jumpHostsJSON) {
+		return nil
+	}
+
+	var jumpHosts = []*models.NodeSSHJumpHost{}
+	err := json.Unmarshal(jumpHostsJSON, &jumpHosts)
+	if err != nil {
+		return errors.New("decode 'jumpHostsJSON' failed: " + err.Error())
+	}
+
+	var refGrantIds = []int64{}
+	for index, jumpHost := range jumpHosts {
+		if jumpHost == nil {
+			continue
+		}
+		var prefix = "jump host #" + numberutils.FormatInt(index+1)
+		if len(jumpHost.Host) == 0 {
+			return errors.New(prefix + ": 'host' should not be empty")
+		}
+		if jumpHost.Port < 0 || jumpHost.Port > 65535 {
+			return errors.New(prefix + ": invalid 'port'")
+		}
+		if jumpHost.GrantId <= 0 {
+			continue
+		}
+		if grantId > 0 && jumpHost.GrantId == grantId {
+			return errors.New(prefix + ": should not refer to current grant")
+		}
+
+		jumpGrant, err := models.SharedNodeGrantDAO.FindEnabledNodeGrant(tx, jumpHost.GrantId)
+		if err != nil {
+			return err
+		}
+		if jumpGrant == nil {
+			return errors.New(prefix + ": can not find grant with id '" + numberutils.FormatInt64(jumpHost.GrantId) + "'")
+		}
+		refGrantIds = append(refGrantIds, jumpHost.GrantId)
+	}
+
+	// 新创建的认证信息不会被其他认证信息引用
+	if grantId <= 0 {
+		return nil
+	}
+
+	// 检查循环引用
+	var visitedMap = map[int64]bool{}
+	for len(refGrantIds) > 0 {
+		var refGrantId = refGrantIds[0]
+		refGrantIds = refGrantIds[1:]
+		if refGrantId == grantId {
+			return errors.New("jump hosts should not refer to current grant circularly")
+		}
+		if visitedMap[refGrantId] {
+			continue
+		}
+		visitedMap[refGrantId] = true
+
+		refGrant, err := models.SharedNodeGrantDAO.FindEnabledNodeGrant(tx, refGrantId)
+		if err != nil {
+			return err
+		}
+		if refGrant == nil {
+			continue
+		}
+		refJumpHosts, err := refGrant.DecodeJumpHosts()
+		if err != nil {
+			// 忽略无法解析的旧数据
+			continue
+		}
+		for _, refJumpHost := range refJumpHosts {
+			if refJumpHost != nil && refJumpHost.GrantId > 0 {
+				refGrantIds = append(refGrantIds, refJumpHost.GrantId)
+			}
+		}
+	}
+
+	return nil
+}
@@ -5,6 +5,8 @@ package services
 import (
 	"context"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/installers"
 	"github.com/TeaOSLab/EdgeCommon/pkg/configutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"github.com/iwind/TeaGo/types"
@@ -66,3 +68,98 @@ func (this *NodeLoginService) FindNodeLoginSuggestPorts(ctx context.Context, req
 		AvailablePorts: availablePorts,
 	}, nil
 }
+
+// FindNodeLoginHostKeys 查找节点已记录的SSH主机公钥
+func (this *NodeLoginService) FindNodeLoginHostKeys(ctx context.Context, req *pb.FindNodeLoginHostKeysRequest) (*pb.FindNodeLoginHostKeysResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.NodeId <= 0 {
+		return nil, errors.New("invalid 'nodeId'")
+	}
+
+	var tx = this.NullTx()
+	hostKeys, err := models.SharedNodeLoginHostKeyDAO.FindAllHostKeysWithNodeId(tx, req.Role, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbHostKeys = []*pb.NodeLoginHostKey{}
+	for _, hostKey := range hostKeys {
+		pbHostKeys = append(pbHostKeys, &pb.NodeLoginHostKey{
+			Id:          int64(hostKey.Id),
+			NodeId:      int64(hostKey.NodeId),
+			Host:        hostKey.Host,
+			Port:        int32(hostKey.Port),
+			KeyType:     hostKey.KeyType,
+			Fingerprint: hostKey.Fingerprint,
+			PublicKey:   hostKey.PublicKey,
+			Source:      hostKey.Source,
+			CreatedAt:   int64(hostKey.CreatedAt),
+			UpdatedAt:   int64(hostKey.UpdatedAt),
+		})
+	}
+	return &pb.FindNodeLoginHostKeysResponse{
+		NodeLoginHostKeys: pbHostKeys,
+	}, nil
+}
+
+// ResetNodeLoginHostKeys 清除节点已记录的SSH主机公钥，下次连接时重新记录
+func (this *NodeLoginService) ResetNodeLoginHostKeys(ctx context.Context, req *pb.ResetNodeLoginHostKeysRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.NodeId <= 0 {
+		return nil, errors.New("invalid 'nodeId'")
+	}
+
+	var tx = this.NullTx()
+	err = models.SharedNodeLoginHostKeyDAO.DeleteHostKeysWithNodeId(tx, req.Role, req.NodeId, req.Host, int(req.Port))
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// AddNodeLoginHostKey 事先添加节点的SSH主机公钥
+func (this *NodeLoginService) AddNodeLoginHostKey(ctx context.Context, req *pb.AddNodeLoginHostKeyRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.NodeId <= 0 {
+		return nil, errors.New("invalid 'nodeId'")
+	}
+	if len(req.Host) == 0 {
+		return nil, errors.New("'host' should not be empty")
+	}
+	if req.Port <= 0 {
+		req.Port = 22
+	}
+
+	key, err := installers.ParseHostKey(req.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	var loginId int64
+	login, err := models.SharedNodeLoginDAO.FindEnabledNodeLoginWithNodeId(tx, req.Role, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	if login != nil {
+		loginId = int64(login.Id)
+	}
+
+	err = models.SharedNodeLoginHostKeyDAO.SaveHostKey(tx, loginId, req.Role, req.NodeId, req.Host, int(req.Port), key.Type(), installers.HostKeyFingerprint(key), installers.MarshalHostKey(key), models.NodeLoginHostKeySourceManual)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
@@ -65,6 +65,28 @@ func init() {
 			nodeLocker.Unlock()
 		}
 	})
+
+	// 刷新当前API节点上的命令通道，以便其他API节点转发命令
+	var keepAliveTicker = time.NewTicker(models.NodeStreamKeepAliveInterval * time.Second)
+	goman.New(func() {
+		for range keepAliveTicker.C {
+			if models.SharedNodeStreamDAO == nil || teaconst.NodeId <= 0 {
+				continue
+			}
+
+			nodeLocker.Lock()
+			var nodeIds = []int64{}
+			for nodeId := range nodeRequestChanMap {
+				nodeIds = append(nodeIds, nodeId)
+			}
+			nodeLocker.Unlock()
+
+			err := models.SharedNodeStreamDAO.KeepAPINodeStreams(nil, teaconst.NodeId, nodeIds)
+			if err != nil {
+				remotelogs.Error("NODE_SERVICE", "keep node streams failed: "+err.Error())
+			}
+		}
+	})
 }
 
 // NodeStream 节点stream
@@ -156,10 +178,21 @@ func (this *NodeService) NodeStream(server pb.NodeService_NodeStreamServer) erro
 	}
 	nodeLocker.Unlock()
 
+	// 记录命令通道所在的API节点
+	err = models.SharedNodeStreamDAO.UpdateNodeStream(tx, nodeId, teaconst.NodeId)
+	if err != nil {
+		remotelogs.Error("NODE_SERVICE", "update node stream failed: "+err.Error())
+	}
+
 	defer func() {
 		nodeLocker.Lock()
 		delete(nodeRequestChanMap, nodeId)
 		nodeLocker.Unlock()
+
+		err := models.SharedNodeStreamDAO.DeleteNodeStream(nil, nodeId, teaconst.NodeId)
+		if err != nil {
+			remotelogs.Error("NODE_SERVICE", "delete node stream failed: "+err.Error())
+		}
 	}()
 
 	// 发送请求
@@ -241,27 +274,59 @@ func (this *NodeService) SendCommandToNode(ctx context.Context, req *pb.NodeStre
 }
 
 // SendCommandToNode 向节点发送命令
+// 如果节点没有连接到当前API节点，则转发到节点所连接的API节点
 func SendCommandToNode(nodeId int64, requestId int64, messageCode string, dataJSON []byte, timeoutSeconds int32, forceConnecting bool) (result *pb.NodeStreamMessage, err error) {
-	nodeLocker.Lock()
-	requestChan, ok := nodeRequestChanMap[nodeId]
-	nodeLocker.Unlock()
+	result, isConnected := sendCommandToLocalNode(nodeId, messageCode, dataJSON, timeoutSeconds)
+	if isConnected {
+		return result, nil
+	}
 
-	if !ok {
-		if forceConnecting {
+	// 查找节点连接的其他API节点
+	apiNodeId, err := models.SharedNodeStreamDAO.FindNodeAPINodeId(nil, nodeId)
+	if err != nil {
+		return nil, err
+	}
+	if apiNodeId > 0 && apiNodeId != teaconst.NodeId {
+		result, err = forwardCommandToNode(apiNodeId, nodeId, messageCode, dataJSON, timeoutSeconds)
+		if err == nil {
+			return result, nil
+		}
+		if err != errNodeStreamNotConnected {
+			remotelogs.Warn("NODE_SERVICE", "forward command '"+messageCode+"' to api node '"+strconv.FormatInt(apiNodeId, 10)+"' failed: "+err.Error())
 			return &pb.NodeStreamMessage{
 				RequestId: requestId,
+				Code:      messageCode,
 				IsOk:      false,
-				Message:   "node '" + strconv.FormatInt(nodeId, 10) + "' not connected yet",
-			}, nil
-		} else {
-			return &pb.NodeStreamMessage{
-				RequestId: requestId,
-				IsOk:      true,
+				Message:   "forward command to api node '" + strconv.FormatInt(apiNodeId, 10) + "' failed: " + err.Error(),
 			}, nil
 		}
 	}
 
-	requestId = NextCommandRequestId()
+	if forceConnecting {
+		return &pb.NodeStreamMessage{
+			RequestId: requestId,
+			IsOk:      false,
+			Message:   "node '" + strconv.FormatInt(nodeId, 10) + "' not connected yet",
+		}, nil
+	} else {
+		return &pb.NodeStreamMessage{
+			RequestId: requestId,
+			IsOk:      true,
+		}, nil
+	}
+}
+
+// 向连接到当前API节点的节点发送命令
+func sendCommandToLocalNode(nodeId int64, messageCode string, dataJSON []byte, timeoutSeconds int32) (result *pb.NodeStreamMessage, isConnected bool) {
+	nodeLocker.Lock()
+	requestChan, ok := nodeRequestChanMap[nodeId]
+	nodeLocker.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	var requestId = NextCommandRequestId()
 
 	select {
 	case requestChan <- &CommandRequest{
@@ -299,10 +364,10 @@ func SendCommandToNode(nodeId int64, requestId int64, messageCode string, dataJS
 					Code:      messageCode,
 					Message:   "response timeout",
 					IsOk:      false,
-				}, nil
+				}, true
 			}
 
-			return resp, nil
+			return resp, true
 		case <-timeout.C:
 			// 从队列中删除
 			nodeLocker.Lock()
@@ -315,7 +380,7 @@ func SendCommandToNode(nodeId int64, requestId int64, messageCode string, dataJS
 				Code:      messageCode,
 				Message:   "response timeout over " + fmt.Sprintf("%d", timeoutSeconds) + " seconds",
 				IsOk:      false,
-			}, nil
+			}, true
 		}
 	default:
 		return &pb.NodeStreamMessage{
@@ -323,6 +388,6 @@ func SendCommandToNode(nodeId int64, requestId int64, messageCode string, dataJS
 			Code:      messageCode,
 			Message:   "command queue is full over " + strconv.Itoa(len(requestChan)),
 			IsOk:      false,
-		}, nil
+		}, true
 	}
 }
@@ -0,0 +1,301 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/TeaOSLab/EdgeAPI/internal/configs"
+	teaconst "github.com/TeaOSLab/EdgeAPI/internal/const"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/encrypt"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/lists"
+	"github.com/iwind/TeaGo/maps"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 节点没有连接到目标API节点
+var errNodeStreamNotConnected = errors.New("node stream not connected")
+
+// 到其他API节点的连接
+type apiNodeClientConn struct {
+	conn   *grpc.ClientConn
+	pinKey string // 连接时使用的证书指纹，证书变化后需要重新连接
+}
+
+var apiNodeConnMap = map[int64]map[string]*apiNodeClientConn{} // apiNodeId => { addr => conn }
+var apiNodeConnLocker = &sync.Mutex{}
+
+// ForwardCommandToNode 接收其他API节点转发的命令，并发送给连接到当前API节点的节点
+func (this *NodeService) ForwardCommandToNode(ctx context.Context, req *pb.NodeStreamMessage) (*pb.NodeStreamMessage, error) {
+	_, _, _, err := rpcutils.ValidateRequest(ctx, rpcutils.UserTypeAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	// 请求中的类型是由调用者自己声明的，所以这里还需要检查调用者是否为可用的API节点
+	err = this.validateAPINodeCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.NodeId <= 0 {
+		return nil, errors.New("node id should not be less than 0")
+	}
+
+	// 这里只发送给本地的节点，不再继续转发，以免形成循环
+	result, isConnected := sendCommandToLocalNode(req.NodeId, req.Code, req.DataJSON, req.TimeoutSeconds)
+	if !isConnected {
+		return nil, status.Error(codes.NotFound, "node '"+strconv.FormatInt(req.NodeId, 10)+"' not connected to this api node")
+	}
+	return result, nil
+}
+
+// 将命令转发到节点所连接的API节点
+func forwardCommandToNode(apiNodeId int64, nodeId int64, messageCode string, dataJSON []byte, timeoutSeconds int32) (*pb.NodeStreamMessage, error) {
+	apiNode, err := models.SharedAPINodeDAO.FindEnabledAPINode(nil, apiNodeId, nil)
+	if err != nil {
+		return nil, err
+	}
+	if apiNode == nil || !apiNode.IsOn {
+		closeAPINodeConns(apiNodeId, nil)
+		return nil, errNodeStreamNotConnected
+	}
+	addrs, err := apiNode.DecodeAccessAddrStrings()
+	if err != nil {
+		return nil, err
+	}
+	closeAPINodeConns(apiNodeId, addrs)
+	if len(addrs) == 0 {
+		return nil, errors.New("no access addresses for api node")
+	}
+
+	pinnedCerts, err := findAPINodeCertificates(apiNode)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := apiNodeForwardContext()
+	if err != nil {
+		return nil, err
+	}
+
+	// 多等待一段时间，以便接收目标API节点返回的超时信息
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = 10
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds+5)*time.Second)
+	defer cancel()
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := apiNodeConn(apiNodeId, addr, pinnedCerts)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		result, err := pb.NewNodeServiceClient(conn).ForwardCommandToNode(ctx, &pb.NodeStreamMessage{
+			NodeId:         nodeId,
+			Code:           messageCode,
+			DataJSON:       dataJSON,
+			TimeoutSeconds: timeoutSeconds,
+		})
+		if err == nil {
+			return result, nil
+		}
+
+		switch status.Code(err) {
+		case codes.NotFound:
+			return nil, errNodeStreamNotConnected
+		case codes.DeadlineExceeded:
+			return &pb.NodeStreamMessage{
+				Code:    messageCode,
+				Message: "response timeout over " + strconv.Itoa(int(timeoutSeconds)) + " seconds",
+				IsOk:    false,
+			}, nil
+		case codes.Unavailable:
+			// 尝试下一个地址
+			lastErr = err
+			continue
+		}
+		return nil, err
+	}
+	return nil, lastErr
+}
+
+// 检查调用者是否为可用的API节点
+func (this *NodeService) validateAPINodeCaller(ctx context.Context) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return this.PermissionError()
+	}
+	var nodeIds = md.Get("nodeid")
+	if len(nodeIds) == 0 || len(nodeIds[0]) == 0 {
+		return this.PermissionError()
+	}
+
+	var tx = this.NullTx()
+	apiToken, err := models.SharedApiTokenDAO.FindEnabledTokenWithNodeCacheable(tx, nodeIds[0])
+	if err != nil {
+		return err
+	}
+	if apiToken == nil || apiToken.Role != nodeconfigs.NodeRoleAPI {
+		return this.PermissionError()
+	}
+
+	apiNodeId, err := models.SharedAPINodeDAO.FindEnabledAPINodeIdWithUniqueId(tx, nodeIds[0])
+	if err != nil {
+		return err
+	}
+	if apiNodeId <= 0 {
+		return this.PermissionError()
+	}
+	return nil
+}
+
+// 查找API节点HTTPS使用的证书，用来校验其他API节点的身份
+func findAPINodeCertificates(apiNode *models.APINode) ([][]byte, error) {
+	var result = [][]byte{}
+	httpsConfig, err := apiNode.DecodeHTTPS(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decode https config of api node failed: %w", err)
+	}
+	if httpsConfig == nil || httpsConfig.SSLPolicy == nil {
+		return result, nil
+	}
+	for _, cert := range httpsConfig.SSLPolicy.Certs {
+		var certObject = cert.CertObject()
+		if certObject != nil && len(certObject.Certificate) > 0 {
+			result = append(result, certObject.Certificate[0])
+		}
+	}
+	return result, nil
+}
+
+// 获取到其他API节点的连接
+// 使用HTTPS时，对方的证书必须是目标API节点中设置的证书之一
+func apiNodeConn(apiNodeId int64, addr string, pinnedCerts [][]byte) (*grpc.ClientConn, error) {
+	apiNodeConnLocker.Lock()
+	defer apiNodeConnLocker.Unlock()
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var pinKey string
+	var transportCredentials credentials.TransportCredentials
+	switch u.Scheme {
+	case "http":
+		transportCredentials = insecure.NewCredentials()
+	case "https":
+		if len(pinnedCerts) == 0 {
+			return nil, errors.New("no certificates found for api node address '" + addr + "'")
+		}
+		var h = sha256.New()
+		for _, pinnedCert := range pinnedCerts {
+			h.Write(pinnedCert)
+		}
+		pinKey = fmt.Sprintf("%x", h.Sum(nil))
+		transportCredentials = credentials.NewTLS(&tls.Config{
+			InsecureSkipVerify:    true, // 不校验证书链和域名，API节点通常使用自签名证书和IP地址访问，改为在 VerifyPeerCertificate 中比对证书
+			VerifyPeerCertificate: verifyAPINodePeerCertificate(pinnedCerts),
+		})
+	default:
+		return nil, errors.New("invalid api node address '" + addr + "'")
+	}
+
+	clientConn, ok := apiNodeConnMap[apiNodeId][addr]
+	if ok {
+		if clientConn.pinKey == pinKey {
+			return clientConn.conn, nil
+		}
+		_ = clientConn.conn.Close()
+		delete(apiNodeConnMap[apiNodeId], addr)
+	}
+
+	conn, err := grpc.Dial(u.Host, grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		return nil, err
+	}
+	if apiNodeConnMap[apiNodeId] == nil {
+		apiNodeConnMap[apiNodeId] = map[string]*apiNodeClientConn{}
+	}
+	apiNodeConnMap[apiNodeId][addr] = &apiNodeClientConn{
+		conn:   conn,
+		pinKey: pinKey,
+	}
+	return conn, nil
+}
+
+// 检查对方API节点的证书是否为设置的证书之一
+func verifyAPINodePeerCertificate(pinnedCerts [][]byte) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no certificate from api node")
+		}
+		for _, pinnedCert := range pinnedCerts {
+			if bytes.Equal(rawCerts[0], pinnedCert) {
+				return nil
+			}
+		}
+		return errors.New("the certificate of api node does not match the configured certificates")
+	}
+}
+
+// 关闭API节点中不在addrs中的连接，以便在API节点地址变化或停用后不再使用旧的连接
+func closeAPINodeConns(apiNodeId int64, addrs []string) {
+	apiNodeConnLocker.Lock()
+	defer apiNodeConnLocker.Unlock()
+
+	for addr, clientConn := range apiNodeConnMap[apiNodeId] {
+		if !lists.ContainsString(addrs, addr) {
+			_ = clientConn.conn.Close()
+			delete(apiNodeConnMap[apiNodeId], addr)
+		}
+	}
+	if len(apiNodeConnMap[apiNodeId]) == 0 {
+		delete(apiNodeConnMap, apiNodeId)
+	}
+}
+
+// 使用当前API节点的身份构造请求上下文
+func apiNodeForwardContext() (context.Context, error) {
+	config, err := configs.SharedAPIConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := encrypt.NewMethodInstance(teaconst.EncryptMethod, config.Secret, config.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	data, err := method.Encrypt(maps.Map{
+		"timestamp": time.Now().Unix(),
+		"type":      rpcutils.UserTypeAPI,
+		"userId":    0,
+	}.AsJSON())
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata.AppendToOutgoingContext(context.Background(), "nodeId", config.NodeId, "token", base64.StdEncoding.EncodeToString(data)), nil
+}
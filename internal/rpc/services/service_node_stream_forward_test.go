@@ -0,0 +1,133 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// 模拟对方API节点
+type testForwardPeer struct {
+	pb.NodeServiceServer
+}
+
+func (this *testForwardPeer) ForwardCommandToNode(ctx context.Context, req *pb.NodeStreamMessage) (*pb.NodeStreamMessage, error) {
+	return &pb.NodeStreamMessage{
+		NodeId:    req.NodeId,
+		RequestId: req.RequestId,
+		Code:      req.Code,
+		IsOk:      true,
+	}, nil
+}
+
+func TestAPINodeConn_PinnedCert(t *testing.T) {
+	peerCert := testForwardCert(t)
+	otherCert := testForwardCert(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var server = grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{peerCert},
+	})))
+	pb.RegisterNodeServiceServer(server, &testForwardPeer{})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	var addr = "https://" + listener.Addr().String()
+	var forward = func(apiNodeId int64, pinnedCerts [][]byte) error {
+		defer closeAPINodeConns(apiNodeId, nil)
+
+		conn, err := apiNodeConn(apiNodeId, addr, pinnedCerts)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		result, err := pb.NewNodeServiceClient(conn).ForwardCommandToNode(ctx, &pb.NodeStreamMessage{
+			NodeId:    1,
+			RequestId: 2,
+			Code:      "test",
+		})
+		if err != nil {
+			return err
+		}
+		if !result.IsOk || result.NodeId != 1 || result.Code != "test" {
+			t.Fatal("unexpected result:", result)
+		}
+		return nil
+	}
+
+	// 证书一致
+	err = forward(1, [][]byte{otherCert.Certificate[0], peerCert.Certificate[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 证书不一致
+	err = forward(2, [][]byte{otherCert.Certificate[0]})
+	if err == nil {
+		t.Fatal("should fail with unmatched certificate")
+	}
+	t.Log("expected error:", err)
+
+	// 没有设置证书
+	_, err = apiNodeConn(3, addr, nil)
+	if err == nil {
+		t.Fatal("should fail without certificates")
+	}
+}
+
+func TestVerifyAPINodePeerCertificate(t *testing.T) {
+	var verify = verifyAPINodePeerCertificate([][]byte{[]byte("a"), []byte("b")})
+	if verify([][]byte{[]byte("b")}, nil) != nil {
+		t.Fatal("'b' should be accepted")
+	}
+	if verify([][]byte{[]byte("c"), []byte("b")}, nil) == nil {
+		t.Fatal("only leaf certificate should be compared")
+	}
+	if verify(nil, nil) == nil {
+		t.Fatal("empty certificates should be rejected")
+	}
+}
+
+// 生成自签名证书
+func testForwardCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "api-node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{certDER},
+		PrivateKey:  key,
+	}
+}
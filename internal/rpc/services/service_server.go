@@ -3234,7 +3234,40 @@ func (this *ServerService) UpdateServerName(ctx context.Context, req *pb.UpdateS
 }
 
 // CopyServerConfig 在服务之间复制配置
+// 有服务复制失败时返回错误
 func (this *ServerService) CopyServerConfig(ctx context.Context, req *pb.CopyServerConfigRequest) (*pb.RPCSuccess, error) {
+	results, err := this.copyServerConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if !result.IsOk {
+			return nil, errors.New("copy config to server '" + types.String(result.ServerId) + "' failed: " + result.Error)
+		}
+	}
+	return this.Success()
+}
+
+// CopyServerConfigWithResults 在服务之间复制配置，并返回每个服务的复制结果
+func (this *ServerService) CopyServerConfigWithResults(ctx context.Context, req *pb.CopyServerConfigRequest) (*pb.CopyServerConfigResponse, error) {
+	results, err := this.copyServerConfig(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbResults = []*pb.CopyServerConfigResponse_Result{}
+	for _, result := range results {
+		pbResults = append(pbResults, &pb.CopyServerConfigResponse_Result{
+			ServerId: result.ServerId,
+			IsOk:     result.IsOk,
+			Error:    result.Error,
+		})
+	}
+	return &pb.CopyServerConfigResponse{Results: pbResults}, nil
+}
+
+// 复制配置，返回每个目标服务的复制结果
+func (this *ServerService) copyServerConfig(ctx context.Context, req *pb.CopyServerConfigRequest) (results []*models.ServerCopyResult, err error) {
 	adminId, userId, err := this.ValidateAdminAndUser(ctx, true)
 	if err != nil {
 		return nil, err
@@ -3258,7 +3291,7 @@ func (this *ServerService) CopyServerConfig(ctx context.Context, req *pb.CopySer
 	case "servers":
 		// 检查权限
 		if len(req.TargetServerIds) == 0 {
-			return this.Success()
+			return nil, nil
 		}
 		if userId > 0 {
 			for _, targetServerId := range req.TargetServerIds {
@@ -3268,14 +3301,14 @@ func (this *ServerService) CopyServerConfig(ctx context.Context, req *pb.CopySer
 				}
 			}
 		}
-		err = models.SharedServerDAO.CopyServerConfigToServers(tx, req.ServerId, req.TargetServerIds, req.ConfigCode, req.WafCopyRegions)
+		results, err = models.SharedServerDAO.CopyServerConfigToServersWithResults(tx, req.ServerId, req.TargetServerIds, req.ConfigCode, req.WafCopyRegions)
 		if err != nil {
 			return nil, err
 		}
 	case "groups":
 		// 检查权限
 		if len(req.TargetServerGroupIds) == 0 {
-			return this.Success()
+			return nil, nil
 		}
 		if userId > 0 {
 			for _, targetGroupId := range req.TargetServerGroupIds {
@@ -3285,7 +3318,7 @@ func (this *ServerService) CopyServerConfig(ctx context.Context, req *pb.CopySer
 				}
 			}
 		}
-		err = models.SharedServerDAO.CopyServerConfigToGroups(tx, req.ServerId, req.TargetServerGroupIds, req.ConfigCode, req.WafCopyRegions)
+		results, err = models.SharedServerDAO.CopyServerConfigToGroups(tx, req.ServerId, req.TargetServerGroupIds, req.ConfigCode, req.WafCopyRegions)
 		if err != nil {
 			return nil, err
 		}
@@ -3295,9 +3328,9 @@ func (this *ServerService) CopyServerConfig(ctx context.Context, req *pb.CopySer
 			return nil, this.PermissionError()
 		}
 		if req.TargetClusterId <= 0 {
-			return this.Success()
+			return nil, nil
 		}
-		err = models.SharedServerDAO.CopyServerConfigToCluster(tx, req.ServerId, req.TargetClusterId, req.ConfigCode, req.WafCopyRegions)
+		results, err = models.SharedServerDAO.CopyServerConfigToCluster(tx, req.ServerId, req.TargetClusterId, req.ConfigCode, req.WafCopyRegions)
 		if err != nil {
 			return nil, err
 		}
@@ -3316,13 +3349,13 @@ func (this *ServerService) CopyServerConfig(ctx context.Context, req *pb.CopySer
 			// 只能同步到自己的网站
 			req.TargetUserId = userId
 		}
-		err = models.SharedServerDAO.CopyServerConfigToUser(tx, req.ServerId, req.TargetUserId, req.ConfigCode, req.WafCopyRegions)
+		results, err = models.SharedServerDAO.CopyServerConfigToUser(tx, req.ServerId, req.TargetUserId, req.ConfigCode, req.WafCopyRegions)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return this.Success()
+	return results, nil
 }
 
 // FindServerAuditingPrompt 获取域名审核时的提示文字
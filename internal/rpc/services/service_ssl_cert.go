@@ -405,3 +405,150 @@ func (this *SSLCertService) FindSSLCertUser(ctx context.Context, req *pb.FindSSL
 		},
 	}, nil
 }
+
+// FindSSLCertExpireConfig 查找证书到期通知和自动续期配置
+// 管理员可以查看全局配置或者自己的配置，用户只能查看自己的配置
+func (this *SSLCertService) FindSSLCertExpireConfig(ctx context.Context, req *pb.FindSSLCertExpireConfigRequest) (*pb.FindSSLCertExpireConfigResponse, error) {
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if userId > 0 || req.IsGlobal {
+		adminId = 0
+	}
+
+	var tx = this.NullTx()
+	config, err := models.SharedSysSettingDAO.ReadSSLCertExpireConfig(tx, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FindSSLCertExpireConfigResponse{ConfigJSON: configJSON}, nil
+}
+
+// UpdateSSLCertExpireConfig 修改证书到期通知和自动续期配置
+// 只有超级管理员才能修改全局配置
+func (this *SSLCertService) UpdateSSLCertExpireConfig(ctx context.Context, req *pb.UpdateSSLCertExpireConfigRequest) (*pb.RPCSuccess, error) {
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+
+	if userId > 0 {
+		adminId = 0
+	} else if req.IsGlobal {
+		isSuper, err := models.SharedAdminDAO.CheckSuperAdmin(tx, adminId)
+		if err != nil {
+			return nil, err
+		}
+		if !isSuper {
+			return nil, this.PermissionError()
+		}
+		adminId = 0
+	}
+
+	var config = models.DefaultSSLCertExpireConfig()
+	err = json.Unmarshal(req.ConfigJSON, config)
+	if err != nil {
+		return nil, errors.New("decode config failed: " + err.Error())
+	}
+
+	err = models.SharedSysSettingDAO.UpdateSSLCertExpireConfig(tx, adminId, userId, config)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindCTLogMonitorConfig 查找证书透明度日志监控配置
+func (this *SSLCertService) FindCTLogMonitorConfig(ctx context.Context, req *pb.FindCTLogMonitorConfigRequest) (*pb.FindCTLogMonitorConfigResponse, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	config, err := models.SharedSysSettingDAO.ReadCTLogMonitorConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FindCTLogMonitorConfigResponse{ConfigJSON: configJSON}, nil
+}
+
+// UpdateCTLogMonitorConfig 修改证书透明度日志监控配置
+func (this *SSLCertService) UpdateCTLogMonitorConfig(ctx context.Context, req *pb.UpdateCTLogMonitorConfigRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var config = models.DefaultCTLogMonitorConfig()
+	err = json.Unmarshal(req.ConfigJSON, config)
+	if err != nil {
+		return nil, errors.New("decode config failed: " + err.Error())
+	}
+
+	var tx = this.NullTx()
+	err = models.SharedSysSettingDAO.UpdateCTLogMonitorConfig(tx, config)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// CountCTLogCerts 计算在证书透明度日志中发现的证书数量
+func (this *SSLCertService) CountCTLogCerts(ctx context.Context, req *pb.CountCTLogCertsRequest) (*pb.RPCCountResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	count, err := models.SharedCTLogCertDAO.CountCerts(tx, userId, req.Domain)
+	if err != nil {
+		return nil, err
+	}
+	return this.SuccessCount(count)
+}
+
+// ListCTLogCerts 列出单页在证书透明度日志中发现的证书
+func (this *SSLCertService) ListCTLogCerts(ctx context.Context, req *pb.ListCTLogCertsRequest) (*pb.ListCTLogCertsResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	certs, err := models.SharedCTLogCertDAO.ListCerts(tx, userId, req.Domain, req.Offset, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbCerts = []*pb.CTLogCert{}
+	for _, cert := range certs {
+		pbCerts = append(pbCerts, &pb.CTLogCert{
+			Id:           int64(cert.Id),
+			Domain:       cert.Domain,
+			DnsNamesJSON: cert.DnsNames,
+			CommonName:   cert.CommonName,
+			IssuerName:   cert.IssuerName,
+			SerialNumber: cert.SerialNumber,
+			NotBefore:    int64(cert.NotBefore),
+			NotAfter:     int64(cert.NotAfter),
+			LoggedAt:     int64(cert.LoggedAt),
+			SourceType:   cert.SourceType,
+			CreatedAt:    int64(cert.CreatedAt),
+		})
+	}
+	return &pb.ListCTLogCertsResponse{CtLogCerts: pbCerts}, nil
+}
@@ -780,7 +780,16 @@ func (this *UserService) CheckUserOTPWithUsername(ctx context.Context, req *pb.C
 	if err != nil {
 		return nil, err
 	}
-	return &pb.CheckUserOTPWithUsernameResponse{RequireOTP: otpIsOn}, nil
+
+	// 安全密钥
+	webAuthnIsOn, err := models.SharedLoginDAO.CheckLoginIsOn(tx, 0, userId, models.LoginTypeWebAuthn)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CheckUserOTPWithUsernameResponse{
+		RequireOTP:      otpIsOn,
+		RequireWebAuthn: webAuthnIsOn,
+	}, nil
 }
 
 // CheckUserServersState 检查用户服务可用状态
@@ -0,0 +1,188 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package rpcutils
+
+import (
+	"github.com/iwind/TeaGo/lists"
+	"strings"
+)
+
+type AccessScope = string
+
+const (
+	AccessScopeAll        AccessScope = "all"        // 所有接口
+	AccessScopeReadOnly   AccessScope = "readOnly"   // 只读接口
+	AccessScopeStats      AccessScope = "stats"      // 统计数据（只读）
+	AccessScopeCachePurge AccessScope = "cachePurge" // 刷新和预热缓存
+)
+
+type AccessScopeDefinition struct {
+	Name        string `json:"name"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// FindAllAccessScopes 所有的访问范围
+func FindAllAccessScopes() []*AccessScopeDefinition {
+	return []*AccessScopeDefinition{
+		{Name: "所有接口", Code: AccessScopeAll, Description: "可以调用所有接口。"},
+		{Name: "只读", Code: AccessScopeReadOnly, Description: "只能调用网站、WAF、缓存等配置和统计数据的查询接口，不能读取节点、证书和DNS服务商等包含认证信息的数据。"},
+		{Name: "统计数据", Code: AccessScopeStats, Description: "只能查询统计数据。"},
+		{Name: "缓存清理", Code: AccessScopeCachePurge, Description: "只能提交和查询刷新、预热缓存任务。"},
+	}
+}
+
+// IsValidAccessScope 判断访问范围是否可用
+func IsValidAccessScope(scope AccessScope) bool {
+	for _, def := range FindAllAccessScopes() {
+		if def.Code == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// 只读方法的前缀
+var readOnlyMethodPrefixes = []string{"Find", "List", "Count", "Check", "Sum", "Lookup", "Exists"}
+
+// 以只读前缀开头但会修改数据的方法前缀
+var readOnlyExcludedMethodPrefixes = []string{"FindAndInit"}
+
+// 缓存清理相关的服务
+var cachePurgeServiceNames = []string{"HTTPCacheTaskService", "HTTPCacheTaskKeyService"}
+
+// 统计数据相关的服务
+var statServiceNames = []string{
+	"MetricChartService",
+	"MetricStatService",
+	"ServerBandwidthStatService",
+	"ServerClientBrowserMonthlyStatService",
+	"ServerClientSystemMonthlyStatService",
+	"ServerDailyStatService",
+	"ServerDomainHourlyStatService",
+	"ServerHTTPFirewallDailyStatService",
+	"ServerRegionCityMonthlyStatService",
+	"ServerRegionCountryMonthlyStatService",
+	"ServerRegionProviderMonthlyStatService",
+	"ServerRegionProvinceMonthlyStatService",
+	"ServerStatBoardChartService",
+	"ServerStatBoardService",
+	"TrafficDailyStatService",
+}
+
+// 只读范围可以调用的服务
+// 只列出不会返回密钥、密码、私钥和节点Secret等认证信息的服务，
+// 像NodeService、NodeClusterService、APINodeService、DNSProviderService、SSLCertService、ACME相关服务等都不在其中
+var readOnlyServiceNames = []string{
+	"HTTPAccessLogService",
+	"HTTPCachePolicyService",
+	"HTTPFirewallPolicyService",
+	"HTTPFirewallRuleGroupService",
+	"HTTPFirewallRuleSetService",
+	"HTTPGzipService",
+	"HTTPHeaderPolicyService",
+	"HTTPHeaderService",
+	"HTTPLocationService",
+	"HTTPPageService",
+	"HTTPRewriteRuleService",
+	"HTTPWebService",
+	"HTTPWebsocketService",
+	"IPItemService",
+	"IPListService",
+	"NodeGroupService",
+	"NodeRegionService",
+	"RegionCityService",
+	"RegionCountryService",
+	"RegionProviderService",
+	"RegionProvinceService",
+	"RegionTownService",
+	"ServerGroupService",
+	"ServerService",
+}
+
+// 只读范围的服务中会返回认证信息的方法，比如包含证书私钥、源站证书和认证密码的完整配置
+var readOnlyDeniedMethods = []string{
+	"HTTPLocationService.FindEnabledHTTPLocationConfig",
+	"HTTPWebService.FindEnabledHTTPWebConfig",
+	"ServerService.FindEnabledServerConfig",
+}
+
+// AccessScopesAllow 判断访问范围是否允许调用某个方法
+// 每个范围只能调用明确列出的服务，不在列表中的服务一律拒绝
+// 范围为空时表示不限制，以兼容以前生成的令牌
+func AccessScopesAllow(scopes []AccessScope, serviceName string, methodName string) bool {
+	if IsUnlimitedAccessScopes(scopes) {
+		return true
+	}
+
+	if len(methodName) > 0 {
+		methodName = strings.ToUpper(methodName[:1]) + methodName[1:]
+	}
+
+	for _, scope := range scopes {
+		switch scope {
+		case AccessScopeReadOnly:
+			if (lists.ContainsString(readOnlyServiceNames, serviceName) || lists.ContainsString(statServiceNames, serviceName) || lists.ContainsString(cachePurgeServiceNames, serviceName)) &&
+				!lists.ContainsString(readOnlyDeniedMethods, serviceName+"."+methodName) &&
+				isReadOnlyMethod(methodName) {
+				return true
+			}
+		case AccessScopeStats:
+			if lists.ContainsString(statServiceNames, serviceName) && isReadOnlyMethod(methodName) {
+				return true
+			}
+		case AccessScopeCachePurge:
+			if lists.ContainsString(cachePurgeServiceNames, serviceName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsUnlimitedAccessScopes 判断访问范围是否不受限制
+func IsUnlimitedAccessScopes(scopes []AccessScope) bool {
+	return len(scopes) == 0 || lists.ContainsString(scopes, AccessScopeAll)
+}
+
+// AccessScopesContain 判断访问范围parentScopes是否包含scopes中的所有范围
+// 用来保证新的令牌不会比申请时使用的令牌拥有更大的范围
+func AccessScopesContain(parentScopes []AccessScope, scopes []AccessScope) bool {
+	if IsUnlimitedAccessScopes(parentScopes) {
+		return true
+	}
+	if IsUnlimitedAccessScopes(scopes) {
+		return false
+	}
+	for _, scope := range scopes {
+		if !lists.ContainsString(parentScopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseFullMethod 从gRPC方法全称中解析服务名和方法名，比如 /pb.ServerService/FindEnabledServer
+func ParseFullMethod(fullMethod string) (serviceName string, methodName string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	serviceName, methodName, _ = strings.Cut(fullMethod, "/")
+	var index = strings.LastIndex(serviceName, ".")
+	if index >= 0 {
+		serviceName = serviceName[index+1:]
+	}
+	return
+}
+
+func isReadOnlyMethod(methodName string) bool {
+	for _, prefix := range readOnlyExcludedMethodPrefixes {
+		if strings.HasPrefix(methodName, prefix) {
+			return false
+		}
+	}
+	for _, prefix := range readOnlyMethodPrefixes {
+		if strings.HasPrefix(methodName, prefix) {
+			return true
+		}
+	}
+	return false
+}
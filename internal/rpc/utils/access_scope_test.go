@@ -0,0 +1,77 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package rpcutils_test
+
+import (
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/iwind/TeaGo/assert"
+	"testing"
+)
+
+func TestAccessScopesAllow(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	a.IsTrue(rpcutils.AccessScopesAllow(nil, "ServerService", "DeleteServer"))
+	a.IsTrue(rpcutils.AccessScopesAllow([]string{rpcutils.AccessScopeAll}, "ServerService", "DeleteServer"))
+
+	var readOnly = []string{rpcutils.AccessScopeReadOnly}
+	a.IsTrue(rpcutils.AccessScopesAllow(readOnly, "ServerService", "findEnabledServer"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "ServerService", "DeleteServer"))
+
+	// 涉及认证信息的服务
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "UserAccessKeyService", "FindAllEnabledUserAccessKeys"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "AdminService", "FindEnabledAdmin"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "APIAccessTokenService", "FindAllAPIAccessTokensWithUserAccessKeyId"))
+	a.IsTrue(rpcutils.AccessScopesAllow(nil, "UserAccessKeyService", "FindAllEnabledUserAccessKeys"))
+
+	// 会返回密钥、私钥和节点Secret的服务
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "NodeService", "FindEnabledNode"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "NodeClusterService", "FindEnabledNodeCluster"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "APINodeService", "FindEnabledAPINode"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "DNSProviderService", "FindEnabledDNSProvider"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "SSLCertService", "FindEnabledSSLCertConfig"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "SSLPolicyService", "FindEnabledSSLPolicyConfig"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "ACMEUserService", "FindEnabledACMEUser"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "ACMEProviderAccountService", "FindEnabledACMEProviderAccount"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "HTTPAuthPolicyService", "FindEnabledHTTPAuthPolicy"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "OriginService", "FindEnabledOriginConfig"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "ServerService", "FindEnabledServerConfig"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "ServerService", "ComposeServerConfig"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "HTTPWebService", "FindEnabledHTTPWebConfig"))
+	a.IsTrue(rpcutils.AccessScopesAllow(readOnly, "HTTPWebService", "FindEnabledHTTPWeb"))
+
+	// 会修改数据的查询方法
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "ServerService", "FindAndInitServerWebConfig"))
+
+	// 只读范围包含统计数据
+	a.IsTrue(rpcutils.AccessScopesAllow(readOnly, "ServerDailyStatService", "FindLatestServerDailyStats"))
+	a.IsFalse(rpcutils.AccessScopesAllow(readOnly, "HTTPCacheTaskService", "CreateHTTPCacheTask"))
+
+	var stats = []string{rpcutils.AccessScopeStats}
+	a.IsTrue(rpcutils.AccessScopesAllow(stats, "ServerDailyStatService", "FindLatestServerDailyStats"))
+	a.IsFalse(rpcutils.AccessScopesAllow(stats, "ServerService", "FindEnabledServer"))
+	a.IsFalse(rpcutils.AccessScopesAllow(stats, "ServerDailyStatService", "UploadServerDailyStats"))
+	a.IsFalse(rpcutils.AccessScopesAllow(stats, "UserStatService", "FindUserStats"))
+
+	var cachePurge = []string{rpcutils.AccessScopeCachePurge, rpcutils.AccessScopeStats}
+	a.IsTrue(rpcutils.AccessScopesAllow(cachePurge, "HTTPCacheTaskService", "CreateHTTPCacheTask"))
+	a.IsFalse(rpcutils.AccessScopesAllow(cachePurge, "ServerService", "CreateServer"))
+}
+
+func TestAccessScopesContain(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	a.IsTrue(rpcutils.AccessScopesContain(nil, []string{rpcutils.AccessScopeReadOnly}))
+	a.IsTrue(rpcutils.AccessScopesContain([]string{rpcutils.AccessScopeAll}, nil))
+	a.IsTrue(rpcutils.AccessScopesContain([]string{rpcutils.AccessScopeReadOnly, rpcutils.AccessScopeStats}, []string{rpcutils.AccessScopeStats}))
+	a.IsFalse(rpcutils.AccessScopesContain([]string{rpcutils.AccessScopeReadOnly}, nil))
+	a.IsFalse(rpcutils.AccessScopesContain([]string{rpcutils.AccessScopeReadOnly}, []string{rpcutils.AccessScopeAll}))
+	a.IsFalse(rpcutils.AccessScopesContain([]string{rpcutils.AccessScopeStats}, []string{rpcutils.AccessScopeStats, rpcutils.AccessScopeCachePurge}))
+}
+
+func TestParseFullMethod(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	serviceName, methodName := rpcutils.ParseFullMethod("/pb.ServerService/FindEnabledServer")
+	a.IsTrue(serviceName == "ServerService")
+	a.IsTrue(methodName == "FindEnabledServer")
+}
@@ -16,6 +16,7 @@ func IsRest(ctx context.Context) bool {
 type PlainContext struct {
 	UserType string
 	UserId   int64
+	Scopes   []string // 访问令牌的范围，为空表示不限制
 
 	ctx context.Context
 }
@@ -9,11 +9,13 @@ import (
 	"github.com/iwind/TeaGo/maps"
 	"github.com/iwind/TeaGo/types"
 	"io"
+	"reflect"
 	"regexp"
 	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 var recordsTables = []*SQLRecordsTable{
@@ -70,10 +72,23 @@ var recordsTables = []*SQLRecordsTable{
 type sqlItem struct {
 	sqlString string
 	args      []any
+	migration *SQLMigration
+}
+
+// 通过队列执行的迁移操作
+// 迁移中的语句由多个线程并发执行，开始时间为第一条语句开始执行的时间，结束时间为最后一条语句执行完的时间
+type sqlQueuedMigration struct {
+	migration  *SQLMigration
+	startedAt  time.Time
+	finishedAt time.Time
 }
 
 type SQLDump struct {
 	logWriter io.Writer
+	dryRun    bool
+
+	plan    *SQLMigrationPlan
+	history *SQLMigrationHistory
 }
 
 func NewSQLDump() *SQLDump {
@@ -84,6 +99,21 @@ func (this *SQLDump) SetLogWriter(logWriter io.Writer) {
 	this.logWriter = logWriter
 }
 
+// SetDryRun 设置是否只生成迁移计划而不执行
+func (this *SQLDump) SetDryRun(dryRun bool) {
+	this.dryRun = dryRun
+}
+
+// Plan 最近一次Apply()生成的迁移计划
+func (this *SQLDump) Plan() *SQLMigrationPlan {
+	return this.plan
+}
+
+// History 最近一次Apply()使用的迁移历史，试运行时为nil
+func (this *SQLDump) History() *SQLMigrationHistory {
+	return this.history
+}
+
 // Dump 导出数据
 func (this *SQLDump) Dump(db *dbs.DB, includingRecords bool) (result *SQLDumpResult, err error) {
 	result = &SQLDumpResult{}
@@ -176,6 +206,9 @@ func (this *SQLDump) Dump(db *dbs.DB, includingRecords bool) (result *SQLDumpRes
 
 // Apply 应用数据
 func (this *SQLDump) Apply(db *dbs.DB, newResult *SQLDumpResult, showLog bool) (ops []string, err error) {
+	this.plan = NewSQLMigrationPlan(ComposeSQLVersion())
+	this.history = nil
+
 	// 设置Innodb事务提交模式
 	{
 		// 检查是否为root用户
@@ -187,7 +220,7 @@ func (this *SQLDump) Apply(db *dbs.DB, newResult *SQLDumpResult, showLog bool) (
 		if err != nil || dsnConfig == nil {
 			return nil, err
 		}
-		if dsnConfig.User == "root" {
+		if dsnConfig.User == "root" && !this.dryRun {
 			result, err := db.FindOne("SHOW VARIABLES WHERE variable_name='innodb_flush_log_at_trx_commit'")
 			if err == nil && result != nil {
 				var oldValue = result.GetInt("Value")
@@ -198,6 +231,16 @@ func (this *SQLDump) Apply(db *dbs.DB, newResult *SQLDumpResult, showLog bool) (
 		}
 	}
 
+	// 迁移历史
+	if !this.dryRun {
+		var history = NewSQLMigrationHistory(db, ComposeSQLVersion())
+		err = history.Init()
+		if err != nil {
+			return nil, fmt.Errorf("init migration history failed: %w", err)
+		}
+		this.history = history
+	}
+
 	// 执行队列
 	var execQueue = make(chan *sqlItem, 256)
 
@@ -207,24 +250,59 @@ func (this *SQLDump) Apply(db *dbs.DB, newResult *SQLDumpResult, showLog bool) (
 
 	var applyOps []string
 	var applyErr error
+	var queuedMigrations []*sqlQueuedMigration
+	var queuedMigrationMap = map[*SQLMigration]*sqlQueuedMigration{}
+	var queuedMigrationLocker = &sync.Mutex{}
+	var findQueuedMigration = func(migration *SQLMigration) *sqlQueuedMigration {
+		queuedMigrationLocker.Lock()
+		defer queuedMigrationLocker.Unlock()
+		queuedMigration, ok := queuedMigrationMap[migration]
+		if !ok {
+			queuedMigration = &sqlQueuedMigration{migration: migration}
+			queuedMigrationMap[migration] = queuedMigration
+		}
+		return queuedMigration
+	}
 	go func() {
 		defer wg.Done()
 		defer close(execQueue)
 
-		applyOps, applyErr = this.applyQueue(db, newResult, showLog, execQueue)
+		applyOps, queuedMigrations, applyErr = this.applyQueue(db, newResult, showLog, execQueue, findQueuedMigration)
 	}()
 
 	var sqlErrors = []error{}
+	var migrationErrors = map[*SQLMigration]error{}
 	var sqlErrLocker = &sync.Mutex{}
 	for i := 0; i < threads; i++ {
 		go func() {
 			defer wg.Done()
 
 			for item := range execQueue {
+				var queuedMigration *sqlQueuedMigration
+				if item.migration != nil {
+					queuedMigration = findQueuedMigration(item.migration)
+					queuedMigrationLocker.Lock()
+					if queuedMigration.startedAt.IsZero() {
+						queuedMigration.startedAt = time.Now()
+					}
+					queuedMigrationLocker.Unlock()
+				}
+
 				_, err := db.Exec(item.sqlString, item.args...)
+
+				if queuedMigration != nil {
+					queuedMigrationLocker.Lock()
+					queuedMigration.finishedAt = time.Now()
+					queuedMigrationLocker.Unlock()
+				}
+
 				if err != nil {
+					var sqlErr = errors.New(item.sqlString + ": " + err.Error())
 					sqlErrLocker.Lock()
-					sqlErrors = append(sqlErrors, errors.New(item.sqlString+": "+err.Error()))
+					sqlErrors = append(sqlErrors, sqlErr)
+					if item.migration != nil && migrationErrors[item.migration] == nil {
+						migrationErrors[item.migration] = sqlErr
+					}
 					sqlErrLocker.Unlock()
 					break
 				}
@@ -233,13 +311,30 @@ func (this *SQLDump) Apply(db *dbs.DB, newResult *SQLDumpResult, showLog bool) (
 	}
 	wg.Wait()
 
+	// 记录通过队列执行的迁移操作
+	if this.history != nil {
+		for _, queuedMigration := range queuedMigrations {
+			// 执行线程提前退出时，可能有迁移操作没有被执行
+			var startedAt = queuedMigration.startedAt
+			var finishedAt = queuedMigration.finishedAt
+			if startedAt.IsZero() {
+				startedAt = time.Now()
+				finishedAt = startedAt
+			}
+			err = this.history.Record(queuedMigration.migration, startedAt, finishedAt, migrationErrors[queuedMigration.migration])
+			if err != nil {
+				return nil, fmt.Errorf("record migration history failed: %w", err)
+			}
+		}
+	}
+
 	if applyErr != nil {
 		return nil, applyErr
 	}
 
 	if len(sqlErrors) == 0 {
 		// 升级数据
-		err = UpgradeSQLData(db)
+		err = this.upgradeData(db)
 		if err != nil {
 			return nil, errors.New("upgrade data failed: " + err.Error())
 		}
@@ -250,17 +345,26 @@ func (this *SQLDump) Apply(db *dbs.DB, newResult *SQLDumpResult, showLog bool) (
 	return nil, sqlErrors[0]
 }
 
-func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bool, queue chan *sqlItem) (ops []string, err error) {
-	var execSQL = func(sqlString string, args ...any) {
+func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bool, queue chan *sqlItem, findQueuedMigration func(migration *SQLMigration) *sqlQueuedMigration) (ops []string, queuedMigrations []*sqlQueuedMigration, err error) {
+	var execSQL = func(migration *SQLMigration, sqlString string, args ...any) {
+		migration.AddStatement(sqlString, args...)
+		if this.dryRun {
+			return
+		}
 		queue <- &sqlItem{
 			sqlString: sqlString,
 			args:      args,
+			migration: migration,
 		}
 	}
+	var queueMigration = func(migration *SQLMigration) {
+		this.plan.Add(migration)
+		queuedMigrations = append(queuedMigrations, findQueuedMigration(migration))
+	}
 
 	currentResult, err := this.Dump(db, false)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// 新增表格
@@ -272,12 +376,19 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 			if showLog {
 				this.log(op)
 			}
+			var migration = NewSQLMigration(SQLMigrationKindSchema, op)
+			migration.AddReverseStatement("DROP TABLE `" + newTable.Name + "`")
 			if len(newTable.Records) == 0 {
-				execSQL(newTable.Definition)
+				queueMigration(migration)
+				execSQL(migration, newTable.Definition)
 			} else {
-				_, err = db.Exec(newTable.Definition)
+				migration.AddStatement(newTable.Definition)
+				err = this.applyMigration(migration, func() error {
+					_, err := db.Exec(newTable.Definition)
+					return err
+				})
 				if err != nil {
-					return nil, errors.New("'" + op + "' failed: " + err.Error())
+					return nil, nil, errors.New("'" + op + "' failed: " + err.Error())
 				}
 			}
 		} else if oldTable.Definition != newTable.Definition {
@@ -291,9 +402,16 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 					if showLog {
 						this.log(op)
 					}
-					_, err = db.Exec("ALTER TABLE " + newTable.Name + " ADD `" + newField.Name + "` " + newField.Definition)
+					var migration = NewSQLMigration(SQLMigrationKindSchema, op)
+					var sqlString = "ALTER TABLE " + newTable.Name + " ADD `" + newField.Name + "` " + newField.Definition
+					migration.AddStatement(sqlString)
+					migration.AddReverseStatement("ALTER TABLE " + newTable.Name + " DROP COLUMN `" + newField.Name + "`")
+					err = this.applyMigration(migration, func() error {
+						_, err := db.Exec(sqlString)
+						return err
+					})
 					if err != nil {
-						return nil, errors.New("'" + op + "' failed: " + err.Error())
+						return nil, nil, errors.New("'" + op + "' failed: " + err.Error())
 					}
 				} else if !newField.EqualDefinition(oldField.Definition) {
 					var op = "* " + newTable.Name + " " + newField.Name
@@ -301,9 +419,17 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 					if showLog {
 						this.log(op)
 					}
-					_, err = db.Exec("ALTER TABLE " + newTable.Name + " MODIFY `" + newField.Name + "` " + newField.Definition)
+					var migration = NewSQLMigration(SQLMigrationKindSchema, op)
+					migration.IsDestructive = true
+					var sqlString = "ALTER TABLE " + newTable.Name + " MODIFY `" + newField.Name + "` " + newField.Definition
+					migration.AddStatement(sqlString)
+					migration.AddReverseStatement("ALTER TABLE " + newTable.Name + " MODIFY `" + newField.Name + "` " + oldField.Definition)
+					err = this.applyMigration(migration, func() error {
+						_, err := db.Exec(sqlString)
+						return err
+					})
 					if err != nil {
-						return nil, errors.New("'" + op + "' failed: " + err.Error())
+						return nil, nil, errors.New("'" + op + "' failed: " + err.Error())
 					}
 				}
 			}
@@ -318,12 +444,19 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 					if showLog {
 						this.log(op)
 					}
-					_, err = db.Exec("ALTER TABLE " + newTable.Name + " ADD " + newIndex.Definition)
-					if err != nil {
-						err = this.tryCreateIndex(err, db, newTable.Name, newIndex.Definition)
+					var migration = NewSQLMigration(SQLMigrationKindSchema, op)
+					var sqlString = "ALTER TABLE " + newTable.Name + " ADD " + newIndex.Definition
+					migration.AddStatement(sqlString)
+					migration.AddReverseStatement("ALTER TABLE " + newTable.Name + " DROP KEY " + newIndex.Name)
+					err = this.applyMigration(migration, func() error {
+						_, err := db.Exec(sqlString)
 						if err != nil {
-							return nil, errors.New("'" + op + "' failed: " + err.Error())
+							err = this.tryCreateIndex(err, db, newTable.Name, newIndex.Definition)
 						}
+						return err
+					})
+					if err != nil {
+						return nil, nil, errors.New("'" + op + "' failed: " + err.Error())
 					}
 				} else if oldIndex.Definition != newIndex.Definition {
 					var op = "* index " + newTable.Name + " " + newIndex.Name
@@ -331,16 +464,27 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 					if showLog {
 						this.log(op)
 					}
-					_, err = db.Exec("ALTER TABLE " + newTable.Name + " DROP KEY " + newIndex.Name)
-					if err != nil {
-						return nil, errors.New("'" + op + "' drop old key failed: " + err.Error())
-					}
-					_, err = db.Exec("ALTER TABLE " + newTable.Name + " ADD " + newIndex.Definition)
-					if err != nil {
-						err = this.tryCreateIndex(err, db, newTable.Name, newIndex.Definition)
+					var migration = NewSQLMigration(SQLMigrationKindSchema, op)
+					migration.IsDestructive = true
+					var dropSQL = "ALTER TABLE " + newTable.Name + " DROP KEY " + newIndex.Name
+					var addSQL = "ALTER TABLE " + newTable.Name + " ADD " + newIndex.Definition
+					migration.AddStatement(dropSQL)
+					migration.AddStatement(addSQL)
+					migration.AddReverseStatement("ALTER TABLE " + newTable.Name + " DROP KEY " + newIndex.Name)
+					migration.AddReverseStatement("ALTER TABLE " + newTable.Name + " ADD " + oldIndex.Definition)
+					err = this.applyMigration(migration, func() error {
+						_, err := db.Exec(dropSQL)
 						if err != nil {
-							return nil, errors.New("'" + op + "' failed: " + err.Error())
+							return errors.New("drop old key failed: " + err.Error())
 						}
+						_, err = db.Exec(addSQL)
+						if err != nil {
+							err = this.tryCreateIndex(err, db, newTable.Name, newIndex.Definition)
+						}
+						return err
+					})
+					if err != nil {
+						return nil, nil, errors.New("'" + op + "' failed: " + err.Error())
 					}
 				}
 			}
@@ -354,9 +498,17 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 					if showLog {
 						this.log(op)
 					}
-					_, err = db.Exec("ALTER TABLE " + oldTable.Name + " DROP KEY " + oldIndex.Name)
+					var migration = NewSQLMigration(SQLMigrationKindSchema, op)
+					migration.IsDestructive = true
+					var sqlString = "ALTER TABLE " + oldTable.Name + " DROP KEY " + oldIndex.Name
+					migration.AddStatement(sqlString)
+					migration.AddReverseStatement("ALTER TABLE " + oldTable.Name + " ADD " + oldIndex.Definition)
+					err = this.applyMigration(migration, func() error {
+						_, err := db.Exec(sqlString)
+						return err
+					})
 					if err != nil {
-						return nil, errors.New("'" + op + "' failed: " + err.Error())
+						return nil, nil, errors.New("'" + op + "' failed: " + err.Error())
 					}
 				}
 			}
@@ -371,9 +523,17 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 					if showLog {
 						this.log(op)
 					}
-					_, err = db.Exec("ALTER TABLE " + oldTable.Name + " DROP COLUMN `" + oldField.Name + "`")
+					var migration = NewSQLMigration(SQLMigrationKindSchema, op)
+					migration.IsDestructive = true
+					var sqlString = "ALTER TABLE " + oldTable.Name + " DROP COLUMN `" + oldField.Name + "`"
+					migration.AddStatement(sqlString)
+					migration.AddReverseStatement("ALTER TABLE " + oldTable.Name + " ADD `" + oldField.Name + "` " + oldField.Definition)
+					err = this.applyMigration(migration, func() error {
+						_, err := db.Exec(sqlString)
+						return err
+					})
 					if err != nil {
-						return nil, errors.New("'" + op + "' failed: " + err.Error())
+						return nil, nil, errors.New("'" + op + "' failed: " + err.Error())
 					}
 				}
 			}
@@ -382,6 +542,14 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 		// 对比记录
 		// +
 		var newRecordsTable = this.findRecordsTable(newTable.Name)
+		var recordsMigration *SQLMigration // 同一个表中的记录作为一个迁移操作
+		var getRecordsMigration = func() *SQLMigration {
+			if recordsMigration == nil {
+				recordsMigration = NewSQLMigration(SQLMigrationKindRecords, "records "+newTable.Name)
+				queueMigration(recordsMigration)
+			}
+			return recordsMigration
+		}
 		for _, record := range newTable.Records {
 			var queryArgs = []string{}
 			var queryValues = []any{}
@@ -402,7 +570,9 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 
 			var one maps.Map
 
-			if newRecordsTable != nil && newRecordsTable.IgnoreId {
+			if this.dryRun && oldTable == nil {
+				// 试运行时新表还没有创建，所有记录都需要插入
+			} else if newRecordsTable != nil && newRecordsTable.IgnoreId {
 				one, err = db.FindOne("SELECT * FROM "+newTable.Name+" WHERE (("+strings.Join(queryArgs, " AND ")+"))", queryValues...)
 			} else {
 				queryValues = append(queryValues, recordId)
@@ -410,7 +580,7 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 			}
 
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			if one == nil {
 				ops = append(ops, "+ record "+newTable.Name+" "+strings.Join(valueStrings, ", "))
@@ -435,7 +605,7 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 					values = append(values, v)
 				}
 
-				execSQL("INSERT INTO "+newTable.Name+" ("+strings.Join(params, ", ")+") VALUES ("+strings.Join(args, ", ")+")", values...)
+				execSQL(getRecordsMigration(), "INSERT INTO "+newTable.Name+" ("+strings.Join(params, ", ")+") VALUES ("+strings.Join(args, ", ")+")", values...)
 			} else if !record.ValuesEquals(one) {
 				ops = append(ops, "* record "+newTable.Name+" "+strings.Join(valueStrings, ", "))
 				if showLog {
@@ -443,6 +613,8 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 				}
 				var args = []string{}
 				var values = []any{}
+				var oldArgs = []string{}
+				var oldValues = []any{}
 				for k, v := range record.Values {
 					if k == "id" {
 						continue
@@ -455,10 +627,21 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 
 					args = append(args, "`"+k+"`"+"=?")
 					values = append(values, v)
+
+					if one.Has(k) {
+						oldArgs = append(oldArgs, "`"+k+"`"+"=?")
+						oldValues = append(oldValues, one.Get(k))
+					}
 				}
 				values = append(values, one.GetInt("id"))
+				oldValues = append(oldValues, one.GetInt("id"))
 
-				execSQL("UPDATE "+newTable.Name+" SET "+strings.Join(args, ", ")+" WHERE id=?", values...)
+				var migration = getRecordsMigration()
+				migration.IsDestructive = true
+				if len(oldArgs) > 0 {
+					migration.AddReverseStatement("UPDATE "+newTable.Name+" SET "+strings.Join(oldArgs, ", ")+" WHERE id=?", oldValues...)
+				}
+				execSQL(migration, "UPDATE "+newTable.Name+" SET "+strings.Join(args, ", ")+" WHERE id=?", values...)
 			}
 		}
 	}
@@ -469,6 +652,62 @@ func (this *SQLDump) applyQueue(db *dbs.DB, newResult *SQLDumpResult, showLog bo
 	return
 }
 
+// 应用迁移操作，试运行时只加入到迁移计划中
+func (this *SQLDump) applyMigration(migration *SQLMigration, f func() error) error {
+	this.plan.Add(migration)
+	if this.dryRun {
+		return nil
+	}
+
+	var startedAt = time.Now()
+	var err = f()
+	if this.history != nil {
+		historyErr := this.history.Record(migration, startedAt, time.Now(), err)
+		if historyErr != nil && err == nil {
+			err = fmt.Errorf("record migration history failed: %w", historyErr)
+		}
+	}
+	return err
+}
+
+// 升级版本数据
+func (this *SQLDump) upgradeData(db *dbs.DB) error {
+	pendingFuncs, err := findPendingUpgradeFuncs(db)
+	if err != nil {
+		if this.dryRun {
+			// 试运行时版本表可能还没有创建
+			return nil
+		}
+		return err
+	}
+	for _, f := range pendingFuncs {
+		var migration = NewSQLMigration(SQLMigrationKindData, "upgrade data v"+f.version)
+		migration.FuncName = upgradeFuncName(f.f)
+		var upgradeFunc = f.f
+		err = this.applyMigration(migration, func() error {
+			return upgradeFunc(db)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 版本数据升级函数名称
+func upgradeFuncName(f func(db *dbs.DB) error) string {
+	var fn = runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return ""
+	}
+	var name = fn.Name()
+	var index = strings.LastIndex(name, ".")
+	if index >= 0 {
+		name = name[index+1:]
+	}
+	return name
+}
+
 // 查找所有表的完整信息
 func (this *SQLDump) findFullTables(db *dbs.DB, tableNames []string) ([]*dbs.Table, error) {
 	var fullTables = []*dbs.Table{}
@@ -24,6 +24,10 @@ import (
 type SQLExecutor struct {
 	dbConfig  *dbs.DBConfig
 	logWriter io.Writer
+	dryRun    bool
+
+	plan    *SQLMigrationPlan
+	batchId string
 }
 
 func NewSQLExecutor(dbConfig *dbs.DBConfig) *SQLExecutor {
@@ -50,6 +54,21 @@ func (this *SQLExecutor) SetLogWriter(logWriter io.Writer) {
 	this.logWriter = logWriter
 }
 
+// SetDryRun 设置是否只生成迁移计划而不修改数据库
+func (this *SQLExecutor) SetDryRun(dryRun bool) {
+	this.dryRun = dryRun
+}
+
+// Plan 最近一次Run()生成的迁移计划
+func (this *SQLExecutor) Plan() *SQLMigrationPlan {
+	return this.plan
+}
+
+// BatchId 最近一次Run()在迁移历史中的批次，试运行时为空
+func (this *SQLExecutor) BatchId() string {
+	return this.batchId
+}
+
 func (this *SQLExecutor) Run(showLog bool) error {
 	db, err := dbs.NewInstanceFromConfig(this.dbConfig)
 	if err != nil {
@@ -68,6 +87,7 @@ func (this *SQLExecutor) Run(showLog bool) error {
 
 	var sqlDump = NewSQLDump()
 	sqlDump.SetLogWriter(this.logWriter)
+	sqlDump.SetDryRun(this.dryRun)
 	if this.logWriter != nil {
 		showLog = true
 	}
@@ -77,12 +97,22 @@ func (this *SQLExecutor) Run(showLog bool) error {
 	if err != nil {
 		return fmt.Errorf("decode sql data failed: %w", err)
 	}
+	mergePendingSQLTables(sqlResult, pendingSQLTables)
 
 	_, err = sqlDump.Apply(db, sqlResult, showLog)
+	this.plan = sqlDump.Plan()
+	if sqlDump.History() != nil {
+		this.batchId = sqlDump.History().BatchId()
+	}
 	if err != nil {
 		return err
 	}
 
+	// 试运行时不检查数据
+	if this.dryRun {
+		return nil
+	}
+
 	// 检查数据
 	err = this.checkData(db)
 	if err != nil {
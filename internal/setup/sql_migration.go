@@ -0,0 +1,166 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package setup
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/iwind/TeaGo/types"
+	"strings"
+)
+
+const (
+	SQLMigrationKindSchema  = "schema"  // 表结构
+	SQLMigrationKindRecords = "records" // 内置数据
+	SQLMigrationKindData    = "data"    // 版本数据升级
+)
+
+// SQLMigration 单个迁移操作
+type SQLMigration struct {
+	Kind              string   `json:"kind"`
+	Op                string   `json:"op"`                // 操作说明，比如 + table edgeUsers
+	Statements        []string `json:"statements"`        // 要执行的语句
+	ReverseStatements []string `json:"reverseStatements"` // 回滚语句
+	IsDestructive     bool     `json:"isDestructive"`     // 是否为破坏性操作：删除字段、修改字段定义、修改或删除索引、覆盖数据等
+	FuncName          string   `json:"funcName"`          // 版本数据升级函数名称，函数中执行的语句无法提前列出
+}
+
+// NewSQLMigration 获取新对象
+func NewSQLMigration(kind string, op string) *SQLMigration {
+	return &SQLMigration{
+		Kind: kind,
+		Op:   op,
+	}
+}
+
+// AddStatement 添加要执行的语句
+func (this *SQLMigration) AddStatement(sqlString string, args ...any) {
+	this.Statements = append(this.Statements, formatSQL(sqlString, args...))
+}
+
+// AddReverseStatement 添加回滚语句
+func (this *SQLMigration) AddReverseStatement(sqlString string, args ...any) {
+	this.ReverseStatements = append(this.ReverseStatements, formatSQL(sqlString, args...))
+}
+
+// Checksum 计算校验和
+func (this *SQLMigration) Checksum() string {
+	var h = sha256.New()
+	h.Write([]byte(this.Kind + "\n" + this.Op + "\n"))
+	for _, statement := range this.Statements {
+		h.Write([]byte(statement + "\n"))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// SQLMigrationPlan 迁移计划
+type SQLMigrationPlan struct {
+	Version    string          `json:"version"`
+	Migrations []*SQLMigration `json:"migrations"`
+}
+
+// NewSQLMigrationPlan 获取新对象
+func NewSQLMigrationPlan(version string) *SQLMigrationPlan {
+	return &SQLMigrationPlan{
+		Version: version,
+	}
+}
+
+// Add 添加迁移操作
+func (this *SQLMigrationPlan) Add(migration *SQLMigration) {
+	this.Migrations = append(this.Migrations, migration)
+}
+
+// IsEmpty 判断是否没有任何迁移操作
+func (this *SQLMigrationPlan) IsEmpty() bool {
+	return len(this.Migrations) == 0
+}
+
+// HasDestructive 判断是否包含破坏性操作
+func (this *SQLMigrationPlan) HasDestructive() bool {
+	for _, migration := range this.Migrations {
+		if migration.IsDestructive {
+			return true
+		}
+	}
+	return false
+}
+
+// ForwardScript 生成要执行的脚本
+func (this *SQLMigrationPlan) ForwardScript() string {
+	var lines = []string{"-- upgrade to v" + this.Version}
+	for _, migration := range this.Migrations {
+		var comment = "-- [" + migration.Kind + "] " + migration.Op
+		if migration.IsDestructive {
+			comment += " (destructive)"
+		}
+		lines = append(lines, "", comment)
+		if len(migration.FuncName) > 0 {
+			lines = append(lines, "-- will call data upgrade function "+migration.FuncName+"(), its statements can not be listed before running")
+		}
+		for _, statement := range migration.Statements {
+			lines = append(lines, statement+";")
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ReverseScript 生成破坏性操作的回滚脚本
+// 回滚语句按照和执行相反的顺序排列；删除字段的回滚只能恢复表结构，不能恢复数据，所以升级前仍然需要备份数据库
+func (this *SQLMigrationPlan) ReverseScript() string {
+	var lines = []string{
+		"-- rollback destructive operations of upgrading to v" + this.Version,
+		"-- dropped columns can only be re-created, their data can not be restored by this script",
+	}
+	for i := len(this.Migrations) - 1; i >= 0; i-- {
+		var migration = this.Migrations[i]
+		if !migration.IsDestructive {
+			continue
+		}
+		lines = append(lines, "", "-- revert: "+migration.Op)
+		if len(migration.ReverseStatements) == 0 {
+			lines = append(lines, "-- (no reverse statements available)")
+			continue
+		}
+		for _, statement := range migration.ReverseStatements {
+			lines = append(lines, statement+";")
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// 将参数填充到SQL语句中，仅用来展示和生成脚本
+func formatSQL(sqlString string, args ...any) string {
+	if len(args) == 0 {
+		return sqlString
+	}
+
+	var builder = strings.Builder{}
+	var argIndex = 0
+	for _, r := range sqlString {
+		if r == '?' && argIndex < len(args) {
+			builder.WriteString(quoteSQLValue(args[argIndex]))
+			argIndex++
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// 转义SQL中的值
+func quoteSQLValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return types.String(v)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	var replacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\x00", `\0`)
+	return "'" + replacer.Replace(types.String(value)) + "'"
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package setup
+
+import (
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/rands"
+	"strings"
+	"time"
+)
+
+// SQLMigrationTableName 迁移历史表
+const SQLMigrationTableName = "edgeSQLMigrations"
+
+// SQLMigrationHistory 记录迁移历史
+// 历史表在升级之前单独创建，不依赖于sql.json中的表结构
+type SQLMigrationHistory struct {
+	db      *dbs.DB
+	version string
+	batchId string
+}
+
+// NewSQLMigrationHistory 获取新对象
+func NewSQLMigrationHistory(db *dbs.DB, version string) *SQLMigrationHistory {
+	return &SQLMigrationHistory{
+		db:      db,
+		version: version,
+		batchId: time.Now().Format("20060102150405") + "-" + rands.HexString(8),
+	}
+}
+
+// BatchId 当前升级批次
+func (this *SQLMigrationHistory) BatchId() string {
+	return this.batchId
+}
+
+// Init 初始化历史表
+func (this *SQLMigrationHistory) Init() error {
+	_, err := this.db.Exec("CREATE TABLE IF NOT EXISTS `" + SQLMigrationTableName + "` (" +
+		"`id` bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID'," +
+		"`batchId` varchar(64) DEFAULT NULL COMMENT '升级批次'," +
+		"`version` varchar(64) DEFAULT NULL COMMENT '目标版本'," +
+		"`kind` varchar(32) DEFAULT NULL COMMENT '类型'," +
+		"`op` varchar(1024) DEFAULT NULL COMMENT '操作'," +
+		"`checksum` varchar(64) DEFAULT NULL COMMENT '校验和'," +
+		"`statements` longtext COMMENT '执行的语句'," +
+		"`reverseStatements` longtext COMMENT '回滚语句'," +
+		"`isDestructive` tinyint(1) unsigned DEFAULT '0' COMMENT '是否为破坏性操作'," +
+		"`isOk` tinyint(1) unsigned DEFAULT '0' COMMENT '是否成功'," +
+		"`error` text COMMENT '错误信息'," +
+		"`startedAt` bigint(20) unsigned DEFAULT '0' COMMENT '开始时间（毫秒）'," +
+		"`costMs` int(11) unsigned DEFAULT '0' COMMENT '耗时（毫秒）'," +
+		"`createdAt` bigint(20) unsigned DEFAULT '0' COMMENT '创建时间'," +
+		"PRIMARY KEY (`id`)," +
+		"KEY `batchId` (`batchId`)," +
+		"KEY `version` (`version`)" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='数据库迁移历史'")
+	return err
+}
+
+// Record 记录一次迁移操作
+func (this *SQLMigrationHistory) Record(migration *SQLMigration, startedAt time.Time, finishedAt time.Time, migrationErr error) error {
+	var isOk = migrationErr == nil
+	var errString = ""
+	if migrationErr != nil {
+		errString = migrationErr.Error()
+	}
+
+	var isDestructive = 0
+	if migration.IsDestructive {
+		isDestructive = 1
+	}
+
+	var op = migration.Op
+	if opRunes := []rune(op); len(opRunes) > 1024 {
+		op = string(opRunes[:1024])
+	}
+
+	_, err := this.db.Exec("INSERT INTO `"+SQLMigrationTableName+"` (`batchId`, `version`, `kind`, `op`, `checksum`, `statements`, `reverseStatements`, `isDestructive`, `isOk`, `error`, `startedAt`, `costMs`, `createdAt`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		this.batchId,
+		this.version,
+		migration.Kind,
+		op,
+		migration.Checksum(),
+		strings.Join(migration.Statements, ";\n"),
+		strings.Join(migration.ReverseStatements, ";\n"),
+		isDestructive,
+		isOk,
+		errString,
+		startedAt.UnixMilli(),
+		finishedAt.Sub(startedAt).Milliseconds(),
+		time.Now().Unix())
+	return err
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package setup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSQLMigrationPlan_Scripts(t *testing.T) {
+	var plan = NewSQLMigrationPlan("1.3.5")
+
+	var addTable = NewSQLMigration(SQLMigrationKindSchema, "+ table edgeTests")
+	addTable.AddStatement("CREATE TABLE `edgeTests` (`id` int)")
+	addTable.AddReverseStatement("DROP TABLE `edgeTests`")
+	plan.Add(addTable)
+
+	var dropField = NewSQLMigration(SQLMigrationKindSchema, "- field edgeUsers remark")
+	dropField.IsDestructive = true
+	dropField.AddStatement("ALTER TABLE edgeUsers DROP COLUMN `remark`")
+	dropField.AddReverseStatement("ALTER TABLE edgeUsers ADD `remark` varchar(255) DEFAULT NULL")
+	plan.Add(dropField)
+
+	var updateRecord = NewSQLMigration(SQLMigrationKindRecords, "records edgeMessageMedias")
+	updateRecord.IsDestructive = true
+	updateRecord.AddStatement("UPDATE edgeMessageMedias SET `name`=? WHERE id=?", "It's new", 1)
+	updateRecord.AddReverseStatement("UPDATE edgeMessageMedias SET `name`=? WHERE id=?", "old", 1)
+	plan.Add(updateRecord)
+
+	var upgradeData = NewSQLMigration(SQLMigrationKindData, "upgrade data v1.3.5")
+	upgradeData.FuncName = "upgradeV1_3_5"
+	plan.Add(upgradeData)
+
+	if !plan.HasDestructive() {
+		t.Fatal("plan should have destructive migrations")
+	}
+
+	var forwardScript = plan.ForwardScript()
+	t.Log(forwardScript)
+	if !strings.Contains(forwardScript, "UPDATE edgeMessageMedias SET `name`='It\\'s new' WHERE id=1;") {
+		t.Fatal("args should be formatted into statements")
+	}
+	if !strings.Contains(forwardScript, "-- [data] upgrade data v1.3.5\n-- will call data upgrade function upgradeV1_3_5()") {
+		t.Fatal("data upgrade functions should be listed")
+	}
+
+	var reverseScript = plan.ReverseScript()
+	t.Log(reverseScript)
+	if strings.Contains(reverseScript, "DROP TABLE") {
+		t.Fatal("non-destructive migrations should not be reverted")
+	}
+	var recordIndex = strings.Index(reverseScript, "SET `name`='old'")
+	var fieldIndex = strings.Index(reverseScript, "ADD `remark`")
+	if recordIndex < 0 || fieldIndex < 0 || recordIndex > fieldIndex {
+		t.Fatal("reverse statements should be in reverse order")
+	}
+}
+
+func TestSQLMigration_Checksum(t *testing.T) {
+	var migration1 = NewSQLMigration(SQLMigrationKindSchema, "+ edgeUsers remark")
+	migration1.AddStatement("ALTER TABLE edgeUsers ADD `remark` varchar(255)")
+
+	var migration2 = NewSQLMigration(SQLMigrationKindSchema, "+ edgeUsers remark")
+	migration2.AddStatement("ALTER TABLE edgeUsers ADD `remark` varchar(255)")
+	if migration1.Checksum() != migration2.Checksum() {
+		t.Fatal("checksum should be equal")
+	}
+
+	migration2.AddStatement("ALTER TABLE edgeUsers ADD `remark2` varchar(255)")
+	if migration1.Checksum() == migration2.Checksum() {
+		t.Fatal("checksum should be different")
+	}
+}
+
+func TestUpgradeFuncName(t *testing.T) {
+	var name = upgradeFuncName(upgradeV0_0_3)
+	if name != "upgradeV0_0_3" {
+		t.Fatal("unexpected function name:", name)
+	}
+}
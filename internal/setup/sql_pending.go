@@ -0,0 +1,306 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package setup
+
+import (
+	"strings"
+)
+
+// 尚未导出到sql.json中的表结构
+// sql.json是通过 build/sql.sh 从开发数据库中导出的，重新导出之前，新增的表和字段需要在这里声明：
+// 升级时会合并到sql.json的表结构中，不存在的表会被创建，已有的表会补充缺少的字段和索引；
+// 重新导出后，sql.json中已经包含的表、字段和索引会被自动忽略
+var pendingSQLTables = []*SQLTable{
+	// 节点阈值状态变更日志
+	newPendingSQLTable("edgeNodeThresholdLogs", "节点阈值状态变更日志", []*SQLField{
+		{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		{Name: "thresholdId", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '阈值ID'"},
+		{Name: "role", Definition: "varchar(64) COMMENT '节点角色'"},
+		{Name: "clusterId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '集群ID'"},
+		{Name: "nodeId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '节点ID'"},
+		{Name: "item", Definition: "varchar(255) COMMENT '监控项'"},
+		{Name: "param", Definition: "varchar(255) COMMENT '参数'"},
+		{Name: "value", Definition: "double DEFAULT '0' COMMENT '当时的数值'"},
+		{Name: "isSatisfied", Definition: "tinyint(1) unsigned DEFAULT '0' COMMENT '是否满足阈值'"},
+		{Name: "notifiedAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '最后通知时间'"},
+		{Name: "createdAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '创建时间'"},
+		{Name: "day", Definition: "varchar(8) COMMENT 'YYYYMMDD'"},
+	}, []*SQLIndex{
+		{Name: "thresholdId_nodeId", Definition: "KEY `thresholdId_nodeId` (`thresholdId`,`nodeId`) USING BTREE"},
+		{Name: "nodeId", Definition: "KEY `nodeId` (`nodeId`) USING BTREE"},
+		{Name: "day", Definition: "KEY `day` (`day`) USING BTREE"},
+	}),
+
+	// 节点动作执行日志
+	newPendingSQLTable("edgeNodeActionLogs", "节点动作执行日志", []*SQLField{
+		{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		{Name: "actionId", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '动作ID'"},
+		{Name: "role", Definition: "varchar(64) COMMENT '节点角色'"},
+		{Name: "clusterId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '集群ID'"},
+		{Name: "nodeId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '节点ID'"},
+		{Name: "event", Definition: "varchar(64) COMMENT '触发事件'"},
+		{Name: "code", Definition: "varchar(64) COMMENT '动作代号'"},
+		{Name: "isOk", Definition: "tinyint(1) unsigned DEFAULT '0' COMMENT '是否成功'"},
+		{Name: "error", Definition: "text COMMENT '错误信息'"},
+		{Name: "createdAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '创建时间'"},
+		{Name: "day", Definition: "varchar(8) COMMENT 'YYYYMMDD'"},
+	}, []*SQLIndex{
+		{Name: "actionId_nodeId", Definition: "KEY `actionId_nodeId` (`actionId`,`nodeId`) USING BTREE"},
+		{Name: "nodeId", Definition: "KEY `nodeId` (`nodeId`) USING BTREE"},
+		{Name: "day", Definition: "KEY `day` (`day`) USING BTREE"},
+	}),
+
+	// 节点SSH主机公钥
+	newPendingSQLTable("edgeNodeLoginHostKeys", "节点SSH主机公钥", []*SQLField{
+		{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		{Name: "loginId", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '登录信息ID'"},
+		{Name: "role", Definition: "varchar(64) COMMENT '节点角色'"},
+		{Name: "nodeId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '节点ID'"},
+		{Name: "host", Definition: "varchar(255) COMMENT '主机地址'"},
+		{Name: "port", Definition: "int(10) unsigned DEFAULT '0' COMMENT '端口'"},
+		{Name: "keyType", Definition: "varchar(64) COMMENT '公钥类型'"},
+		{Name: "fingerprint", Definition: "varchar(255) COMMENT '公钥指纹'"},
+		{Name: "publicKey", Definition: "text COMMENT '公钥，authorized_keys格式'"},
+		{Name: "source", Definition: "varchar(32) COMMENT '来源：auto, manual'"},
+		{Name: "createdAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '创建时间'"},
+		{Name: "updatedAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '修改时间'"},
+	}, []*SQLIndex{
+		{Name: "role_nodeId_host_port", Definition: "KEY `role_nodeId_host_port` (`role`,`nodeId`,`host`,`port`) USING BTREE"},
+		{Name: "host_port", Definition: "KEY `host_port` (`host`,`port`) USING BTREE"},
+	}),
+
+	// 安全密钥
+	newPendingSQLTable("edgeLoginWebAuthnCredentials", "安全密钥", []*SQLField{
+		{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		{Name: "adminId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '管理员ID'"},
+		{Name: "userId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '用户ID'"},
+		{Name: "name", Definition: "varchar(255) COMMENT '名称'"},
+		{Name: "credentialId", Definition: "varchar(1024) COMMENT '凭证ID（base64url）'"},
+		{Name: "publicKey", Definition: "text COMMENT 'COSE格式的公钥（base64url）'"},
+		{Name: "algorithm", Definition: "int(11) DEFAULT '0' COMMENT '签名算法'"},
+		{Name: "signCount", Definition: "int(10) unsigned DEFAULT '0' COMMENT '签名计数器'"},
+		{Name: "aaguid", Definition: "varchar(64) COMMENT '认证器型号'"},
+		{Name: "attestationFormat", Definition: "varchar(64) COMMENT '证明格式'"},
+		{Name: "transports", Definition: "json COMMENT '传输方式'"},
+		{Name: "createdAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '创建时间'"},
+		{Name: "lastUsedAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '最后使用时间'"},
+		{Name: "state", Definition: "tinyint(1) unsigned DEFAULT '1' COMMENT '状态'"},
+	}, []*SQLIndex{
+		{Name: "adminId", Definition: "KEY `adminId` (`adminId`) USING BTREE"},
+		{Name: "userId", Definition: "KEY `userId` (`userId`) USING BTREE"},
+		{Name: "credentialId", Definition: "KEY `credentialId` (`credentialId`(255)) USING BTREE"},
+	}),
+
+	// 登录SESSION
+	newPendingSQLFields("edgeLoginSessions", []*SQLField{
+		{Name: "userAgent", Definition: "varchar(512) COMMENT '浏览器信息'"},
+		{Name: "activeAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '最后活跃时间'"},
+	}, []*SQLIndex{
+		{Name: "adminId_userId", Definition: "KEY `adminId_userId` (`adminId`,`userId`) USING BTREE"},
+	}),
+
+	// 边缘节点命令通道
+	newPendingSQLTable("edgeNodeStreams", "边缘节点和API节点之间的命令通道", []*SQLField{
+		{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		{Name: "nodeId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '边缘节点ID'"},
+		{Name: "apiNodeId", Definition: "int(10) unsigned DEFAULT '0' COMMENT 'API节点ID'"},
+		{Name: "connectedAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '连接时间'"},
+		{Name: "updatedAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '最后确认在线时间'"},
+	}, []*SQLIndex{
+		{Name: "nodeId", Definition: "KEY `nodeId` (`nodeId`) USING BTREE"},
+		{Name: "apiNodeId", Definition: "KEY `apiNodeId` (`apiNodeId`) USING BTREE"},
+		{Name: "updatedAt", Definition: "KEY `updatedAt` (`updatedAt`) USING BTREE"},
+	}),
+
+	// 证书透明度日志中发现的证书
+	newPendingSQLTable("edgeCTLogCerts", "在证书透明度日志中发现的非本系统签发的证书", []*SQLField{
+		{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		{Name: "adminId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '管理员ID'"},
+		{Name: "userId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '用户ID'"},
+		{Name: "domain", Definition: "varchar(255) COMMENT '匹配的域名'"},
+		{Name: "dnsNames", Definition: "json COMMENT '证书中的域名'"},
+		{Name: "commonName", Definition: "varchar(255) COMMENT '证书CN'"},
+		{Name: "issuerName", Definition: "varchar(255) COMMENT '颁发机构'"},
+		{Name: "serialNumber", Definition: "varchar(128) COMMENT '序列号'"},
+		{Name: "notBefore", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '生效时间'"},
+		{Name: "notAfter", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '过期时间'"},
+		{Name: "loggedAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '记录到日志的时间'"},
+		{Name: "sourceType", Definition: "varchar(32) COMMENT '来源类型'"},
+		{Name: "entryId", Definition: "varchar(64) COMMENT '来源中的记录ID'"},
+		{Name: "createdAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '创建时间'"},
+	}, []*SQLIndex{
+		{Name: "adminId_userId_serialNumber", Definition: "KEY `adminId_userId_serialNumber` (`adminId`,`userId`,`serialNumber`) USING BTREE"},
+		{Name: "userId_domain", Definition: "KEY `userId_domain` (`userId`,`domain`) USING BTREE"},
+	}),
+
+	// 证书序列号
+	newPendingSQLTable("edgeSSLCertSerials", "本系统签发或上传过的证书序列号", []*SQLField{
+		{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		{Name: "certId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '证书ID'"},
+		{Name: "serialNumber", Definition: "varchar(128) COMMENT '序列号'"},
+		{Name: "createdAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '创建时间'"},
+	}, []*SQLIndex{
+		{Name: "certId_serialNumber", Definition: "KEY `certId_serialNumber` (`certId`,`serialNumber`) USING BTREE"},
+	}),
+
+	// 自定义ACME服务商
+	newPendingSQLTable("edgeACMEProviders", "自定义ACME服务商", []*SQLField{
+		{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		{Name: "adminId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '管理员ID'"},
+		{Name: "isOn", Definition: "tinyint(1) unsigned DEFAULT '1' COMMENT '是否启用'"},
+		{Name: "name", Definition: "varchar(255) COMMENT '名称'"},
+		{Name: "code", Definition: "varchar(100) COMMENT '代号'"},
+		{Name: "description", Definition: "varchar(512) COMMENT '描述'"},
+		{Name: "apiURL", Definition: "varchar(255) COMMENT '目录地址'"},
+		{Name: "requireEAB", Definition: "tinyint(1) unsigned DEFAULT '0' COMMENT '是否需要EAB'"},
+		{Name: "eabDescription", Definition: "varchar(512) COMMENT 'EAB说明'"},
+		{Name: "caCerts", Definition: "text COMMENT '额外信任的CA证书'"},
+		{Name: "createdAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '创建时间'"},
+		{Name: "state", Definition: "tinyint(1) unsigned DEFAULT '1' COMMENT '状态'"},
+	}, []*SQLIndex{
+		{Name: "code", Definition: "KEY `code` (`code`) USING BTREE"},
+	}),
+
+	// 证书续期历史
+	newPendingSQLTable("edgeACMERenewalLogs", "证书续期历史", []*SQLField{
+		{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		{Name: "adminId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '管理员ID'"},
+		{Name: "userId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '用户ID'"},
+		{Name: "certId", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '证书ID'"},
+		{Name: "taskId", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '任务ID'"},
+		{Name: "taskLogId", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '任务日志ID'"},
+		{Name: "trigger", Definition: "varchar(32) COMMENT '触发方式'"},
+		{Name: "isOk", Definition: "tinyint(1) unsigned DEFAULT '0' COMMENT '是否成功'"},
+		{Name: "error", Definition: "varchar(1024) COMMENT '错误信息'"},
+		{Name: "failures", Definition: "int(10) unsigned DEFAULT '0' COMMENT '连续失败次数'"},
+		{Name: "nextRetryAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '下次重试时间'"},
+		{Name: "createdAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '创建时间'"},
+	}, []*SQLIndex{
+		{Name: "certId", Definition: "KEY `certId` (`certId`) USING BTREE"},
+		{Name: "taskId", Definition: "KEY `taskId` (`taskId`) USING BTREE"},
+		{Name: "userId", Definition: "KEY `userId` (`userId`) USING BTREE"},
+	}),
+
+	// 节点认证信息
+	newPendingSQLFields("edgeNodeGrants", []*SQLField{
+		{Name: "certificate", Definition: "text COMMENT 'OpenSSH用户证书'"},
+		{Name: "jumpHosts", Definition: "json COMMENT '跳板机'"},
+	}, nil),
+
+	// ACME任务私钥类型和DNS委托验证
+	newPendingSQLFields("edgeACMETasks", []*SQLField{
+		{Name: "keyType", Definition: "varchar(32) COMMENT '私钥类型'"},
+		{Name: "dualKeyType", Definition: "varchar(32) COMMENT '同时申请的另一种私钥类型'"},
+		{Name: "dualCertId", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '另一种私钥类型的证书ID'"},
+		{Name: "reuseKey", Definition: "tinyint(1) unsigned DEFAULT '0' COMMENT '续期时是否复用私钥'"},
+		{Name: "dnsDelegation", Definition: "tinyint(1) unsigned DEFAULT '0' COMMENT '是否通过CNAME委托DNS验证'"},
+	}, nil),
+
+	// ACME认证TLS-ALPN-01验证
+	newPendingSQLFields("edgeACMEAuthentications", []*SQLField{
+		{Name: "type", Definition: "varchar(32) COMMENT '认证类型'"},
+		{Name: "certData", Definition: "text COMMENT 'TLS-ALPN-01验证证书'"},
+		{Name: "keyData", Definition: "text COMMENT 'TLS-ALPN-01验证证书私钥'"},
+	}, []*SQLIndex{
+		{Name: "domain_type", Definition: "KEY `domain_type` (`domain`,`type`) USING BTREE"},
+	}),
+
+	// 管理员关联的外部身份
+	newPendingSQLTable("edgeAdminSSOIdentities", "管理员关联的外部身份", []*SQLField{
+		{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		{Name: "adminId", Definition: "int(10) unsigned DEFAULT '0' COMMENT '管理员ID'"},
+		{Name: "providerCode", Definition: "varchar(64) COMMENT '认证服务代号'"},
+		{Name: "subject", Definition: "varchar(255) COMMENT '外部身份唯一标识'"},
+		{Name: "username", Definition: "varchar(255) COMMENT '外部用户名'"},
+		{Name: "email", Definition: "varchar(255) COMMENT '邮箱'"},
+		{Name: "groups", Definition: "json COMMENT '分组'"},
+		{Name: "createdAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '创建时间'"},
+		{Name: "lastLoginAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '最后登录时间'"},
+		{Name: "state", Definition: "tinyint(1) unsigned DEFAULT '1' COMMENT '状态'"},
+	}, []*SQLIndex{
+		{Name: "providerCode_subject", Definition: "KEY `providerCode_subject` (`providerCode`,`subject`) USING BTREE"},
+		{Name: "adminId", Definition: "KEY `adminId` (`adminId`) USING BTREE"},
+	}),
+
+	// API访问令牌
+	newPendingSQLFields("edgeAPIAccessTokens", []*SQLField{
+		{Name: "accessKeyId", Definition: "int(10) unsigned DEFAULT '0' COMMENT 'AccessKey ID'"},
+		{Name: "scopes", Definition: "json COMMENT '访问范围'"},
+		{Name: "refreshToken", Definition: "varchar(128) COMMENT '刷新令牌（哈希值）'"},
+		{Name: "refreshExpiredAt", Definition: "bigint(20) unsigned DEFAULT '0' COMMENT '刷新令牌过期时间'"},
+	}, []*SQLIndex{
+		{Name: "accessKeyId", Definition: "KEY `accessKeyId` (`accessKeyId`) USING BTREE"},
+		{Name: "refreshToken", Definition: "KEY `refreshToken` (`refreshToken`) USING BTREE"},
+	}),
+}
+
+// 构造新的表结构，第一个字段为自增主键
+func newPendingSQLTable(tableName string, comment string, fields []*SQLField, indexes []*SQLIndex) *SQLTable {
+	var lines = []string{}
+	for _, field := range fields {
+		lines = append(lines, "  `"+field.Name+"` "+field.Definition)
+	}
+	if len(fields) > 0 {
+		lines = append(lines, "  PRIMARY KEY (`"+fields[0].Name+"`)")
+	}
+	for _, index := range indexes {
+		lines = append(lines, "  "+index.Definition)
+	}
+
+	return &SQLTable{
+		Name:       tableName,
+		Engine:     "InnoDB",
+		Charset:    "utf8mb4_general_ci",
+		Definition: "CREATE TABLE `" + tableName + "` (\n" + strings.Join(lines, ",\n") + "\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='" + comment + "'",
+		Fields:     fields,
+		Indexes:    indexes,
+	}
+}
+
+// 构造已有表中新增的字段和索引
+func newPendingSQLFields(tableName string, fields []*SQLField, indexes []*SQLIndex) *SQLTable {
+	return &SQLTable{
+		Name:    tableName,
+		Fields:  fields,
+		Indexes: indexes,
+	}
+}
+
+// 将尚未导出的表结构合并到sql.json的表结构中
+func mergePendingSQLTables(result *SQLDumpResult, pendingTables []*SQLTable) {
+	for _, pendingTable := range pendingTables {
+		var table = result.FindTable(pendingTable.Name)
+		if table == nil {
+			// 只有完整的表结构才能创建
+			if len(pendingTable.Definition) > 0 {
+				result.Tables = append(result.Tables, pendingTable)
+			}
+			continue
+		}
+
+		// 修改表定义后，升级时才会对比字段和索引
+		for _, field := range pendingTable.Fields {
+			if table.FindField(field.Name) != nil {
+				continue
+			}
+			table.Fields = append(table.Fields, field)
+			table.Definition = insertSQLDefinition(table.Definition, "\n  PRIMARY KEY", "\n  `"+field.Name+"` "+field.Definition+",")
+		}
+		for _, index := range pendingTable.Indexes {
+			if table.FindIndex(index.Name) != nil {
+				continue
+			}
+			table.Indexes = append(table.Indexes, index)
+			table.Definition = insertSQLDefinition(table.Definition, "\n) ENGINE", ",\n  "+index.Definition)
+		}
+	}
+}
+
+// 在表定义中某个位置之前插入内容，找不到位置时追加到定义的末尾，以保证定义有变化
+func insertSQLDefinition(definition string, before string, piece string) string {
+	var index = strings.Index(definition, before)
+	if index < 0 {
+		return definition + piece
+	}
+	return definition[:index] + piece + definition[index:]
+}
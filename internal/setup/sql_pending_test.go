@@ -0,0 +1,78 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package setup
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMergePendingSQLTables(t *testing.T) {
+	var result = &SQLDumpResult{
+		Tables: []*SQLTable{
+			{
+				Name:       "edgeA",
+				Definition: "CREATE TABLE `edgeA` (\n  `id` int(10) unsigned NOT NULL AUTO_INCREMENT COMMENT 'ID',\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+				Fields: []*SQLField{
+					{Name: "id", Definition: "int(10) unsigned auto_increment COMMENT 'ID'"},
+				},
+			},
+		},
+	}
+
+	mergePendingSQLTables(result, []*SQLTable{
+		newPendingSQLFields("edgeA", []*SQLField{
+			{Name: "id", Definition: "int(10) unsigned auto_increment COMMENT 'ID'"},
+			{Name: "name", Definition: "varchar(255) COMMENT '名称'"},
+		}, []*SQLIndex{
+			{Name: "name", Definition: "KEY `name` (`name`) USING BTREE"},
+		}),
+		newPendingSQLFields("edgeB", []*SQLField{
+			{Name: "name", Definition: "varchar(255) COMMENT '名称'"},
+		}, nil),
+		newPendingSQLTable("edgeC", "测试", []*SQLField{
+			{Name: "id", Definition: "bigint(20) unsigned auto_increment COMMENT 'ID'"},
+		}, nil),
+	})
+
+	if len(result.Tables) != 2 {
+		t.Fatal("expect 2 tables, but got", len(result.Tables))
+	}
+
+	var tableA = result.FindTable("edgeA")
+	if len(tableA.Fields) != 2 || len(tableA.Indexes) != 1 {
+		t.Fatal("fields or indexes not merged")
+	}
+	if !strings.Contains(tableA.Definition, "  `name` varchar(255) COMMENT '名称',\n  PRIMARY KEY (`id`),\n  KEY `name` (`name`) USING BTREE\n) ENGINE") {
+		t.Fatal("invalid definition:", tableA.Definition)
+	}
+
+	var tableC = result.FindTable("edgeC")
+	if tableC == nil || !strings.HasPrefix(tableC.Definition, "CREATE TABLE `edgeC` (\n  `id` bigint(20) unsigned auto_increment COMMENT 'ID',\n  PRIMARY KEY (`id`)\n)") {
+		t.Fatal("table 'edgeC' should be created")
+	}
+}
+
+func TestPendingSQLTables(t *testing.T) {
+	var columnReg = regexp.MustCompile("`([^`]+)`")
+	var tableNames = map[string]bool{}
+	for _, table := range pendingSQLTables {
+		if tableNames[table.Name] {
+			t.Fatal("duplicate table '" + table.Name + "'")
+		}
+		tableNames[table.Name] = true
+		if len(table.Fields) == 0 {
+			t.Fatal("table '" + table.Name + "' should have fields")
+		}
+		for _, index := range table.Indexes {
+			var columnsString = index.Definition[strings.Index(index.Definition, "(")+1 : strings.LastIndex(index.Definition, ")")]
+			for _, match := range columnReg.FindAllStringSubmatch(columnsString, -1) {
+				var column = match[1]
+				if table.FindField(column) == nil && len(table.Definition) > 0 {
+					t.Fatal("index '" + index.Name + "' of table '" + table.Name + "' refers to unknown field '" + column + "'")
+				}
+			}
+		}
+	}
+}
@@ -107,27 +107,43 @@ var upgradeFuncs = []*upgradeVersion{
 	{
 		"1.3.4", upgradeV1_3_4,
 	},
+	{
+		"1.3.8.3", upgradeV1_3_8_3,
+	},
 }
 
 // UpgradeSQLData 升级SQL数据
 func UpgradeSQLData(db *dbs.DB) error {
-	version, err := db.FindCol(0, "SELECT version FROM edgeVersions")
+	pendingFuncs, err := findPendingUpgradeFuncs(db)
 	if err != nil {
 		return err
 	}
+	for _, f := range pendingFuncs {
+		err = f.f(db)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 查找需要执行的数据升级函数
+func findPendingUpgradeFuncs(db *dbs.DB) ([]*upgradeVersion, error) {
+	version, err := db.FindCol(0, "SELECT version FROM edgeVersions")
+	if err != nil {
+		return nil, err
+	}
+	var result = []*upgradeVersion{}
 	var versionString = types.String(version)
 	if len(versionString) > 0 {
 		for _, f := range upgradeFuncs {
 			if CompareVersion(versionString, f.version) >= 0 {
 				continue
 			}
-			err = f.f(db)
-			if err != nil {
-				return err
-			}
+			result = append(result, f)
 		}
 	}
-	return nil
+	return result, nil
 }
 
 // v0.0.3
@@ -1227,3 +1243,38 @@ func upgradeV1_3_4(db *dbs.DB) error {
 
 	return nil
 }
+
+// 1.3.8.3
+// 可以重复执行：已经转换过的服务商类型不再是dnspod，不会被再次转换
+func upgradeV1_3_8_3(db *dbs.DB) error {
+	// 使用腾讯云API的DNSPod服务商转换为独立的腾讯云DNS服务商，线路ID保持不变
+	ones, _, err := db.FindOnes("SELECT id, apiParams FROM edgeDNSProviders WHERE type='dnspod'")
+	if err != nil {
+		return err
+	}
+	for _, one := range ones {
+		if !isDNSPodProviderUsingTencentDNS(one.GetString("apiParams")) {
+			continue
+		}
+		_, err = db.Exec("UPDATE edgeDNSProviders SET type='tencentDNS' WHERE id=?", one.GetInt64("id"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 判断DNSPod服务商是否使用腾讯云API
+func isDNSPodProviderUsingTencentDNS(apiParamsJSON string) bool {
+	if len(apiParamsJSON) == 0 {
+		return false
+	}
+	var apiParams = maps.Map{}
+	err := json.Unmarshal([]byte(apiParamsJSON), &apiParams)
+	if err != nil {
+		// 忽略错误的参数
+		return false
+	}
+	return apiParams.GetString("apiType") == "tencentDNS"
+}
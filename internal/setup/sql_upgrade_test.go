@@ -1,6 +1,7 @@
 package setup
 
 import (
+	"github.com/iwind/TeaGo/assert"
 	"github.com/iwind/TeaGo/dbs"
 	"testing"
 )
@@ -308,4 +309,49 @@ func TestUpgradeSQLData_v1_3_2(t *testing.T) {
 		t.Fatal(err)
 	}
 	t.Log("ok")
-}
\ No newline at end of file
+}
+
+func TestUpgradeSQLData_v1_3_8_3(t *testing.T) {
+	db, err := dbs.NewInstanceFromConfig(&dbs.DBConfig{
+		Driver: "mysql",
+		Dsn:    "root:123456@tcp(127.0.0.1:3306)/db_edge?charset=utf8mb4&timeout=30s",
+		Prefix: "edge",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	err = upgradeV1_3_8_3(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不应该再有使用腾讯云API的DNSPod服务商
+	ones, _, err := db.FindOnes("SELECT apiParams FROM edgeDNSProviders WHERE type='dnspod'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, one := range ones {
+		if isDNSPodProviderUsingTencentDNS(one.GetString("apiParams")) {
+			t.Fatal("provider should be converted to 'tencentDNS':", one.GetString("apiParams"))
+		}
+	}
+
+	// 可以重复执行
+	err = upgradeV1_3_8_3(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("ok")
+}
+
+func TestIsDNSPodProviderUsingTencentDNS(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsTrue(isDNSPodProviderUsingTencentDNS(`{"apiType":"tencentDNS","accessKeyId":"a","accessKeySecret":"b"}`))
+	a.IsFalse(isDNSPodProviderUsingTencentDNS(`{"apiType":"dnsPodToken","id":"a","token":"b"}`))
+	a.IsFalse(isDNSPodProviderUsingTencentDNS(`{"id":"a","token":"b"}`))
+	a.IsFalse(isDNSPodProviderUsingTencentDNS(""))
+	a.IsFalse(isDNSPodProviderUsingTencentDNS("{"))
+}
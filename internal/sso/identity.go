@@ -0,0 +1,80 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package sso
+
+import (
+	"errors"
+	"strings"
+)
+
+type ProviderType = string
+
+const (
+	ProviderTypeLDAP ProviderType = "ldap"
+	ProviderTypeOIDC ProviderType = "oidc"
+)
+
+// ErrInvalidCredentials 用户名或密码错误
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Identity 外部身份
+type Identity struct {
+	Subject  string   // 唯一标识，LDAP中为DN，OIDC中为sub
+	Username string   // 用户名
+	Fullname string   // 全名
+	Email    string   // 邮箱
+	Groups   []string // 所属分组
+}
+
+// RoleMapping 分组和管理员权限之间的映射
+type RoleMapping struct {
+	Group   string   `json:"group"`   // 分组名或DN，* 表示所有人
+	IsSuper bool     `json:"isSuper"` // 是否为超级管理员
+	Modules []string `json:"modules"` // 允许的模块代号
+}
+
+// MatchRoles 根据分组计算管理员权限
+// 匹配多个映射时合并其中的权限；分组比较时不区分大小写，并且可以用CN匹配分组的DN
+func MatchRoles(groups []string, mappings []*RoleMapping) (matched bool, isSuper bool, modules []string) {
+	modules = []string{}
+	var moduleMap = map[string]bool{}
+	for _, mapping := range mappings {
+		if mapping == nil || !matchGroup(groups, mapping.Group) {
+			continue
+		}
+		matched = true
+		if mapping.IsSuper {
+			isSuper = true
+		}
+		for _, module := range mapping.Modules {
+			if len(module) > 0 && !moduleMap[module] {
+				moduleMap[module] = true
+				modules = append(modules, module)
+			}
+		}
+	}
+	return
+}
+
+func matchGroup(groups []string, group string) bool {
+	group = strings.TrimSpace(group)
+	if len(group) == 0 {
+		return false
+	}
+	if group == "*" {
+		return true
+	}
+	for _, g := range groups {
+		if strings.EqualFold(g, group) {
+			return true
+		}
+
+		// cn=admins,ou=groups,dc=example,dc=com
+		var firstRDN, _, _ = strings.Cut(g, ",")
+		key, value, found := strings.Cut(firstRDN, "=")
+		if found && strings.EqualFold(strings.TrimSpace(key), "cn") && strings.EqualFold(strings.TrimSpace(value), group) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package sso
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// JSON Web Key
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// 转换为公钥
+func (this *jsonWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch this.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(this.N)
+		if err != nil {
+			return nil, errors.New("jwk: invalid 'n'")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(this.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("jwk: invalid 'e'")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch this.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("jwk: unsupported curve '" + this.Crv + "'")
+		}
+		x, err := base64.RawURLEncoding.DecodeString(this.X)
+		if err != nil {
+			return nil, errors.New("jwk: invalid 'x'")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(this.Y)
+		if err != nil {
+			return nil, errors.New("jwk: invalid 'y'")
+		}
+		var key = &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("jwk: point is not on curve")
+		}
+		return key, nil
+	case "OKP":
+		if this.Crv != "Ed25519" {
+			return nil, errors.New("jwk: unsupported curve '" + this.Crv + "'")
+		}
+		x, err := base64.RawURLEncoding.DecodeString(this.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("jwk: invalid 'x'")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errors.New("jwk: unsupported key type '" + this.Kty + "'")
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// 校验JWT签名，并返回其中的声明
+// 不支持none和HMAC算法
+func verifyJWT(token string, findKey func(header *jwtHeader) (crypto.PublicKey, error)) (map[string]any, error) {
+	var pieces = strings.Split(token, ".")
+	if len(pieces) != 3 {
+		return nil, errors.New("jwt: invalid token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(pieces[0])
+	if err != nil {
+		return nil, errors.New("jwt: invalid header")
+	}
+	var header = &jwtHeader{}
+	err = json.Unmarshal(headerJSON, header)
+	if err != nil {
+		return nil, errors.New("jwt: invalid header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(pieces[2])
+	if err != nil {
+		return nil, errors.New("jwt: invalid signature")
+	}
+
+	publicKey, err := findKey(header)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyJWTSignature(header.Alg, publicKey, []byte(pieces[0]+"."+pieces[1]), signature)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(pieces[1])
+	if err != nil {
+		return nil, errors.New("jwt: invalid payload")
+	}
+	var claims = map[string]any{}
+	var decoder = json.NewDecoder(strings.NewReader(string(payloadJSON)))
+	decoder.UseNumber()
+	err = decoder.Decode(&claims)
+	if err != nil {
+		return nil, errors.New("jwt: invalid payload")
+	}
+	return claims, nil
+}
+
+func verifyJWTSignature(alg string, publicKey crypto.PublicKey, data []byte, signature []byte) error {
+	var errSignature = errors.New("jwt: invalid signature")
+	var errKey = errors.New("jwt: key does not match the algorithm '" + alg + "'")
+
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return errKey
+		}
+		if !ed25519.Verify(key, data, signature) {
+			return errSignature
+		}
+		return nil
+	default:
+		return errors.New("jwt: unsupported algorithm '" + alg + "'")
+	}
+
+	var digest []byte
+	switch hash {
+	case crypto.SHA256:
+		var sum = sha256.Sum256(data)
+		digest = sum[:]
+	case crypto.SHA384:
+		var sum = sha512.Sum384(data)
+		digest = sum[:]
+	default:
+		var sum = sha512.Sum512(data)
+		digest = sum[:]
+	}
+
+	switch alg[0] {
+	case 'R', 'P':
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return errKey
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(key, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(key, hash, digest, signature, nil)
+		}
+		if err != nil {
+			return errSignature
+		}
+		return nil
+	default:
+		// JWS中的ECDSA签名为 R || S
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return errKey
+		}
+		var size = (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errSignature
+		}
+		var r = new(big.Int).SetBytes(signature[:size])
+		var s = new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errSignature
+		}
+		return nil
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package sso
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ldapApplicationBindRequest      = 0
+	ldapApplicationBindResponse     = 1
+	ldapApplicationUnbindRequest    = 2
+	ldapApplicationSearchRequest    = 3
+	ldapApplicationSearchEntry      = 4
+	ldapApplicationSearchDone       = 5
+	ldapApplicationSearchReference  = 19
+	ldapApplicationExtendedRequest  = 23
+	ldapApplicationExtendedResponse = 24
+
+	ldapResultSuccess            = 0
+	ldapResultInvalidCredentials = 49
+
+	ldapStartTLSOID = "1.3.6.1.4.1.1466.20037"
+)
+
+// LDAPConfig LDAP配置
+type LDAPConfig struct {
+	URL                string `json:"url"`                // 服务地址：ldap://host:389 或 ldaps://host:636
+	StartTLS           bool   `json:"startTLS"`           // 是否在ldap://连接上使用StartTLS
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"` // 是否忽略证书错误
+	BindDN             string `json:"bindDN"`             // 用来查找用户的账号，为空时匿名查找
+	BindPassword       string `json:"bindPassword"`       // 查找用户的账号密码
+	BaseDN             string `json:"baseDN"`             // 查找用户的起始DN
+	UserFilter         string `json:"userFilter"`         // 查找用户的过滤器，其中的 {username} 会被替换为用户名，比如 (uid={username})
+	UsernameAttr       string `json:"usernameAttr"`       // 用户名属性
+	FullnameAttr       string `json:"fullnameAttr"`       // 全名属性
+	EmailAttr          string `json:"emailAttr"`          // 邮箱属性
+	GroupAttr          string `json:"groupAttr"`          // 分组属性，比如 memberOf
+	TimeoutSeconds     int    `json:"timeoutSeconds"`     // 超时时间
+}
+
+// Init 初始化
+func (this *LDAPConfig) Init() error {
+	u, err := url.Parse(this.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || len(u.Hostname()) == 0 {
+		return errors.New("invalid ldap url '" + this.URL + "'")
+	}
+	if len(this.BaseDN) == 0 {
+		return errors.New("'baseDN' should not be empty")
+	}
+	if len(this.UserFilter) == 0 {
+		this.UserFilter = "(uid={username})"
+	}
+	if !strings.Contains(this.UserFilter, "{username}") {
+		return errors.New("'userFilter' should contain '{username}'")
+	}
+	_, err = compileLDAPFilter(strings.ReplaceAll(this.UserFilter, "{username}", "test"))
+	if err != nil {
+		return err
+	}
+	if len(this.UsernameAttr) == 0 {
+		this.UsernameAttr = "uid"
+	}
+	if len(this.FullnameAttr) == 0 {
+		this.FullnameAttr = "cn"
+	}
+	if len(this.EmailAttr) == 0 {
+		this.EmailAttr = "mail"
+	}
+	if len(this.GroupAttr) == 0 {
+		this.GroupAttr = "memberOf"
+	}
+	if this.TimeoutSeconds <= 0 {
+		this.TimeoutSeconds = 10
+	}
+	return nil
+}
+
+// LDAPAuthenticator 使用LDAP认证用户
+type LDAPAuthenticator struct {
+	config *LDAPConfig
+}
+
+// NewLDAPAuthenticator 获取新对象
+func NewLDAPAuthenticator(config *LDAPConfig) (*LDAPAuthenticator, error) {
+	if config == nil {
+		return nil, errors.New("config should not be nil")
+	}
+	err := config.Init()
+	if err != nil {
+		return nil, err
+	}
+	return &LDAPAuthenticator{config: config}, nil
+}
+
+// Authenticate 认证用户
+// 先查找用户的DN，然后使用用户的DN和密码绑定
+func (this *LDAPAuthenticator) Authenticate(username string, password string) (*Identity, error) {
+	username = strings.TrimSpace(username)
+
+	// 空密码会被服务器当作匿名绑定而认证成功
+	if len(username) == 0 || len(password) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := this.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// 查找用户
+	if len(this.config.BindDN) > 0 {
+		err = conn.Bind(this.config.BindDN, this.config.BindPassword)
+		if err != nil {
+			if errors.Is(err, ErrInvalidCredentials) {
+				return nil, errors.New("bind with service account failed: " + err.Error())
+			}
+			return nil, err
+		}
+	}
+
+	var filter = strings.ReplaceAll(this.config.UserFilter, "{username}", EscapeLDAPFilter(username))
+	entries, err := conn.Search(this.config.BaseDN, filter, []string{
+		this.config.UsernameAttr,
+		this.config.FullnameAttr,
+		this.config.EmailAttr,
+		this.config.GroupAttr,
+	}, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	if len(entries) > 1 {
+		return nil, errors.New("more than one entries found with the username")
+	}
+	var entry = entries[0]
+
+	// 使用用户密码绑定
+	err = conn.Bind(entry.DN, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var identity = &Identity{
+		Subject:  entry.DN,
+		Username: entry.First(this.config.UsernameAttr),
+		Fullname: entry.First(this.config.FullnameAttr),
+		Email:    entry.First(this.config.EmailAttr),
+		Groups:   entry.All(this.config.GroupAttr),
+	}
+	if len(identity.Username) == 0 {
+		identity.Username = username
+	}
+	return identity, nil
+}
+
+func (this *LDAPAuthenticator) dial() (*ldapConn, error) {
+	u, err := url.Parse(this.config.URL)
+	if err != nil {
+		return nil, err
+	}
+	var host = u.Hostname()
+	var port = u.Port()
+	if len(port) == 0 {
+		if u.Scheme == "ldaps" {
+			port = "636"
+		} else {
+			port = "389"
+		}
+	}
+
+	var timeout = time.Duration(this.config.TimeoutSeconds) * time.Second
+	var tlsConfig = &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: this.config.InsecureSkipVerify,
+	}
+	var rawConn net.Conn
+	var dialer = &net.Dialer{Timeout: timeout}
+	if u.Scheme == "ldaps" {
+		rawConn, err = tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), tlsConfig)
+	} else {
+		rawConn, err = dialer.Dial("tcp", net.JoinHostPort(host, port))
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = rawConn.SetDeadline(time.Now().Add(timeout))
+
+	var conn = newLDAPConn(rawConn)
+	if u.Scheme == "ldap" && this.config.StartTLS {
+		err = conn.StartTLS(tlsConfig)
+		if err != nil {
+			_ = rawConn.Close()
+			return nil, err
+		}
+		_ = conn.conn.SetDeadline(time.Now().Add(timeout))
+	}
+	return conn, nil
+}
+
+// LDAPEntry 查找到的条目
+type LDAPEntry struct {
+	DN         string
+	Attributes map[string][]string // 属性名为小写
+}
+
+// First 读取属性的第一个值
+func (this *LDAPEntry) First(attr string) string {
+	var values = this.Attributes[strings.ToLower(attr)]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// All 读取属性的所有值
+func (this *LDAPEntry) All(attr string) []string {
+	return this.Attributes[strings.ToLower(attr)]
+}
+
+// LDAP连接
+type ldapConn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	messageId int64
+}
+
+func newLDAPConn(conn net.Conn) *ldapConn {
+	return &ldapConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+}
+
+// Bind 简单绑定
+func (this *ldapConn) Bind(dn string, password string) error {
+	var request = newBERConstructed(berClassApplication, ldapApplicationBindRequest,
+		newBERInteger(3),
+		newBERString(dn),
+		newBERPrimitive(berClassContext, 0, []byte(password)),
+	)
+	response, err := this.roundTrip(request)
+	if err != nil {
+		return err
+	}
+	var op = response.Child(1)
+	if !op.Is(berClassApplication, ldapApplicationBindResponse) {
+		return errors.New("ldap: unexpected bind response")
+	}
+	return ldapResultError(op)
+}
+
+// StartTLS 升级为TLS连接
+func (this *ldapConn) StartTLS(tlsConfig *tls.Config) error {
+	var request = newBERConstructed(berClassApplication, ldapApplicationExtendedRequest,
+		newBERPrimitive(berClassContext, 0, []byte(ldapStartTLSOID)),
+	)
+	response, err := this.roundTrip(request)
+	if err != nil {
+		return err
+	}
+	var op = response.Child(1)
+	if !op.Is(berClassApplication, ldapApplicationExtendedResponse) {
+		return errors.New("ldap: unexpected extended response")
+	}
+	err = ldapResultError(op)
+	if err != nil {
+		return errors.New("ldap: start tls failed: " + err.Error())
+	}
+
+	var tlsConn = tls.Client(this.conn, tlsConfig)
+	err = tlsConn.Handshake()
+	if err != nil {
+		return err
+	}
+	this.conn = tlsConn
+	this.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// Search 在子树中查找
+func (this *ldapConn) Search(baseDN string, filter string, attributes []string, sizeLimit int) ([]*LDAPEntry, error) {
+	filterPacket, err := compileLDAPFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	var attributesPacket = newBERSequence()
+	for _, attr := range attributes {
+		attributesPacket.Append(newBERString(attr))
+	}
+	var request = newBERConstructed(berClassApplication, ldapApplicationSearchRequest,
+		newBERString(baseDN),
+		newBEREnumerated(2), // wholeSubtree
+		newBEREnumerated(0), // neverDerefAliases
+		newBERInteger(int64(sizeLimit)),
+		newBERInteger(0),
+		newBERBoolean(false),
+		filterPacket,
+		attributesPacket,
+	)
+	messageId, err := this.send(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries = []*LDAPEntry{}
+	for {
+		response, err := this.receive(messageId)
+		if err != nil {
+			return nil, err
+		}
+		var op = response.Child(1)
+		switch {
+		case op.Is(berClassApplication, ldapApplicationSearchEntry):
+			var entry = &LDAPEntry{
+				DN:         op.Child(0).String(),
+				Attributes: map[string][]string{},
+			}
+			for _, attrPacket := range op.Child(1).Children {
+				var name = strings.ToLower(attrPacket.Child(0).String())
+				for _, valuePacket := range attrPacket.Child(1).Children {
+					entry.Attributes[name] = append(entry.Attributes[name], valuePacket.String())
+				}
+			}
+			entries = append(entries, entry)
+		case op.Is(berClassApplication, ldapApplicationSearchReference):
+			// 忽略引用
+		case op.Is(berClassApplication, ldapApplicationSearchDone):
+			err = ldapResultError(op)
+			if err != nil {
+				return nil, err
+			}
+			return entries, nil
+		default:
+			return nil, errors.New("ldap: unexpected search response")
+		}
+	}
+}
+
+// Close 关闭连接
+func (this *ldapConn) Close() error {
+	_, _ = this.send(newBERPrimitive(berClassApplication, ldapApplicationUnbindRequest, nil))
+	return this.conn.Close()
+}
+
+func (this *ldapConn) roundTrip(op *berPacket) (*berPacket, error) {
+	messageId, err := this.send(op)
+	if err != nil {
+		return nil, err
+	}
+	return this.receive(messageId)
+}
+
+func (this *ldapConn) send(op *berPacket) (messageId int64, err error) {
+	this.messageId++
+	_, err = this.conn.Write(newBERSequence(newBERInteger(this.messageId), op).Bytes())
+	return this.messageId, err
+}
+
+func (this *ldapConn) receive(messageId int64) (*berPacket, error) {
+	for {
+		packet, err := readBERPacket(this.reader)
+		if err != nil {
+			return nil, errors.New("ldap: read response failed: " + err.Error())
+		}
+		if !packet.Is(berClassUniversal, berTagSequence) || len(packet.Children) < 2 {
+			return nil, errors.New("ldap: invalid response")
+		}
+		var responseId = packet.Child(0).Int()
+		if responseId == 0 {
+			// 服务器主动发送的通知，通常意味着连接即将关闭
+			return nil, errors.New("ldap: connection closed by server: " + ldapResultMessage(packet.Child(1)))
+		}
+		if responseId == messageId {
+			return packet, nil
+		}
+	}
+}
+
+func ldapResultError(op *berPacket) error {
+	var code = op.Child(0).Int()
+	switch code {
+	case ldapResultSuccess:
+		return nil
+	case ldapResultInvalidCredentials:
+		return ErrInvalidCredentials
+	}
+	return errors.New("ldap: result code " + strconv.FormatInt(code, 10) + ": " + ldapResultMessage(op))
+}
+
+func ldapResultMessage(op *berPacket) string {
+	return op.Child(2).String()
+}
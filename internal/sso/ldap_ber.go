@@ -0,0 +1,286 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package sso
+
+import (
+	"errors"
+	"io"
+)
+
+// LDAP使用的BER编码，只实现了LDAP消息中用到的部分
+
+const (
+	berClassUniversal   byte = 0x00
+	berClassApplication byte = 0x40
+	berClassContext     byte = 0x80
+
+	berTagBoolean     = 0x01
+	berTagInteger     = 0x02
+	berTagOctetString = 0x04
+	berTagNull        = 0x05
+	berTagEnumerated  = 0x0a
+	berTagSequence    = 0x10
+	berTagSet         = 0x11
+
+	berMaxPacketSize = 16 << 20
+)
+
+// berPacket BER数据包
+type berPacket struct {
+	Class       byte
+	Constructed bool
+	Tag         int
+	Value       []byte       // 基本类型的内容
+	Children    []*berPacket // 结构类型的子元素
+}
+
+func newBERPrimitive(class byte, tag int, value []byte) *berPacket {
+	return &berPacket{
+		Class: class,
+		Tag:   tag,
+		Value: value,
+	}
+}
+
+func newBERConstructed(class byte, tag int, children ...*berPacket) *berPacket {
+	return &berPacket{
+		Class:       class,
+		Constructed: true,
+		Tag:         tag,
+		Children:    children,
+	}
+}
+
+func newBERSequence(children ...*berPacket) *berPacket {
+	return newBERConstructed(berClassUniversal, berTagSequence, children...)
+}
+
+func newBERString(value string) *berPacket {
+	return newBERPrimitive(berClassUniversal, berTagOctetString, []byte(value))
+}
+
+func newBERInteger(value int64) *berPacket {
+	return newBERPrimitive(berClassUniversal, berTagInteger, encodeBERInteger(value))
+}
+
+func newBEREnumerated(value int64) *berPacket {
+	return newBERPrimitive(berClassUniversal, berTagEnumerated, encodeBERInteger(value))
+}
+
+func newBERBoolean(value bool) *berPacket {
+	if value {
+		return newBERPrimitive(berClassUniversal, berTagBoolean, []byte{0xff})
+	}
+	return newBERPrimitive(berClassUniversal, berTagBoolean, []byte{0x00})
+}
+
+// Append 添加子元素
+func (this *berPacket) Append(children ...*berPacket) *berPacket {
+	this.Children = append(this.Children, children...)
+	return this
+}
+
+// Is 判断类型
+func (this *berPacket) Is(class byte, tag int) bool {
+	return this.Class == class && this.Tag == tag
+}
+
+// Int 读取整数
+func (this *berPacket) Int() int64 {
+	return decodeBERInteger(this.Value)
+}
+
+// String 读取字符串
+func (this *berPacket) String() string {
+	return string(this.Value)
+}
+
+// Child 读取子元素，不存在时返回空元素
+func (this *berPacket) Child(index int) *berPacket {
+	if index < 0 || index >= len(this.Children) {
+		return &berPacket{}
+	}
+	return this.Children[index]
+}
+
+// Bytes 编码
+func (this *berPacket) Bytes() []byte {
+	var content = this.Value
+	if this.Constructed {
+		content = []byte{}
+		for _, child := range this.Children {
+			content = append(content, child.Bytes()...)
+		}
+	}
+
+	var identifier = this.Class
+	if this.Constructed {
+		identifier |= 0x20
+	}
+	var result []byte
+	if this.Tag < 31 {
+		result = []byte{identifier | byte(this.Tag)}
+	} else {
+		// LDAP中的标签都小于127
+		result = []byte{identifier | 0x1f, byte(this.Tag & 0x7f)}
+	}
+	result = append(result, encodeBERLength(len(content))...)
+	return append(result, content...)
+}
+
+// 从数据流中读取一个完整的数据包
+func readBERPacket(reader io.Reader) (*berPacket, error) {
+	var head = make([]byte, 2)
+	_, err := io.ReadFull(reader, head)
+	if err != nil {
+		return nil, err
+	}
+	var data = append([]byte{}, head...)
+	if head[0]&0x1f == 0x1f {
+		var b = make([]byte, 1)
+		_, err = io.ReadFull(reader, b)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
+	}
+
+	// 长度
+	var lengthByte = data[len(data)-1]
+	var length int
+	if lengthByte&0x80 == 0 {
+		length = int(lengthByte)
+	} else {
+		var count = int(lengthByte & 0x7f)
+		if count == 0 || count > 4 {
+			return nil, errors.New("ber: unsupported length")
+		}
+		var lengthBytes = make([]byte, count)
+		_, err = io.ReadFull(reader, lengthBytes)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, lengthBytes...)
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length < 0 || length > berMaxPacketSize {
+		return nil, errors.New("ber: packet too large")
+	}
+
+	var content = make([]byte, length)
+	_, err = io.ReadFull(reader, content)
+	if err != nil {
+		return nil, err
+	}
+	packet, rest, err := parseBERPacket(append(data, content...), 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("ber: unexpected data")
+	}
+	return packet, nil
+}
+
+// 解析数据包
+func parseBERPacket(data []byte, depth int) (packet *berPacket, rest []byte, err error) {
+	if depth > 32 {
+		return nil, nil, errors.New("ber: too deeply nested")
+	}
+	if len(data) < 2 {
+		return nil, nil, errors.New("ber: unexpected end of data")
+	}
+
+	packet = &berPacket{
+		Class:       data[0] & 0xc0,
+		Constructed: data[0]&0x20 != 0,
+		Tag:         int(data[0] & 0x1f),
+	}
+	data = data[1:]
+	if packet.Tag == 0x1f {
+		if data[0]&0x80 != 0 {
+			return nil, nil, errors.New("ber: unsupported tag")
+		}
+		packet.Tag = int(data[0])
+		data = data[1:]
+		if len(data) == 0 {
+			return nil, nil, errors.New("ber: unexpected end of data")
+		}
+	}
+
+	var length int
+	if data[0]&0x80 == 0 {
+		length = int(data[0])
+		data = data[1:]
+	} else {
+		var count = int(data[0] & 0x7f)
+		data = data[1:]
+		if count == 0 || count > 4 || len(data) < count {
+			return nil, nil, errors.New("ber: unsupported length")
+		}
+		for _, b := range data[:count] {
+			length = length<<8 | int(b)
+		}
+		data = data[count:]
+	}
+	if length < 0 || length > len(data) {
+		return nil, nil, errors.New("ber: unexpected end of data")
+	}
+
+	var content = data[:length]
+	rest = data[length:]
+	if !packet.Constructed {
+		packet.Value = content
+		return packet, rest, nil
+	}
+
+	for len(content) > 0 {
+		var child *berPacket
+		child, content, err = parseBERPacket(content, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		packet.Children = append(packet.Children, child)
+	}
+	return packet, rest, nil
+}
+
+func encodeBERLength(length int) []byte {
+	if length < 0x80 {
+		return []byte{byte(length)}
+	}
+	var result = []byte{}
+	for length > 0 {
+		result = append([]byte{byte(length)}, result...)
+		length >>= 8
+	}
+	return append([]byte{0x80 | byte(len(result))}, result...)
+}
+
+func encodeBERInteger(value int64) []byte {
+	var result = []byte{byte(value)}
+	for {
+		var last = result[0]
+		value >>= 8
+		if (value == 0 && last&0x80 == 0) || (value == -1 && last&0x80 != 0) {
+			return result
+		}
+		result = append([]byte{byte(value)}, result...)
+	}
+}
+
+func decodeBERInteger(data []byte) int64 {
+	if len(data) == 0 || len(data) > 8 {
+		return 0
+	}
+	var value int64
+	if data[0]&0x80 != 0 {
+		value = -1
+	}
+	for _, b := range data {
+		value = value<<8 | int64(b)
+	}
+	return value
+}
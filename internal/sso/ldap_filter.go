@@ -0,0 +1,180 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package sso
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// LDAP过滤器，参考 RFC 4515
+// 支持 &、|、!、等于、存在（attr=*）和子串（attr=a*b*c）
+
+const (
+	ldapFilterAnd        = 0
+	ldapFilterOr         = 1
+	ldapFilterNot        = 2
+	ldapFilterEquality   = 3
+	ldapFilterSubstrings = 4
+	ldapFilterPresent    = 7
+
+	ldapSubstringInitial = 0
+	ldapSubstringAny     = 1
+	ldapSubstringFinal   = 2
+)
+
+// EscapeLDAPFilter 转义过滤器中的值
+func EscapeLDAPFilter(value string) string {
+	var builder = strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		var c = value[i]
+		switch c {
+		case '\\', '*', '(', ')', 0:
+			builder.WriteString("\\" + hex.EncodeToString([]byte{c}))
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String()
+}
+
+// 将过滤器编译为BER数据包
+func compileLDAPFilter(filter string) (*berPacket, error) {
+	filter = strings.TrimSpace(filter)
+	if len(filter) == 0 {
+		return nil, errors.New("empty filter")
+	}
+	if filter[0] != '(' {
+		filter = "(" + filter + ")"
+	}
+	packet, rest, err := parseLDAPFilter(filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(rest)) > 0 {
+		return nil, errors.New("invalid filter: unexpected '" + rest + "'")
+	}
+	return packet, nil
+}
+
+func parseLDAPFilter(filter string, depth int) (packet *berPacket, rest string, err error) {
+	if depth > 16 {
+		return nil, "", errors.New("invalid filter: too deeply nested")
+	}
+	if len(filter) < 3 || filter[0] != '(' {
+		return nil, "", errors.New("invalid filter: expect '('")
+	}
+	filter = filter[1:]
+
+	switch filter[0] {
+	case '&', '|':
+		var tag = ldapFilterAnd
+		if filter[0] == '|' {
+			tag = ldapFilterOr
+		}
+		packet = newBERConstructed(berClassContext, tag)
+		filter = filter[1:]
+		for len(filter) > 0 && filter[0] == '(' {
+			var child *berPacket
+			child, filter, err = parseLDAPFilter(filter, depth+1)
+			if err != nil {
+				return nil, "", err
+			}
+			packet.Append(child)
+		}
+		if len(packet.Children) == 0 {
+			return nil, "", errors.New("invalid filter: empty set")
+		}
+	case '!':
+		var child *berPacket
+		child, filter, err = parseLDAPFilter(filter[1:], depth+1)
+		if err != nil {
+			return nil, "", err
+		}
+		packet = newBERConstructed(berClassContext, ldapFilterNot, child)
+	default:
+		var end = strings.IndexByte(filter, ')')
+		if end < 0 {
+			return nil, "", errors.New("invalid filter: expect ')'")
+		}
+		packet, err = parseLDAPFilterItem(filter[:end])
+		if err != nil {
+			return nil, "", err
+		}
+		filter = filter[end:]
+	}
+
+	if len(filter) == 0 || filter[0] != ')' {
+		return nil, "", errors.New("invalid filter: expect ')'")
+	}
+	return packet, filter[1:], nil
+}
+
+func parseLDAPFilterItem(item string) (*berPacket, error) {
+	var index = strings.IndexByte(item, '=')
+	if index <= 0 {
+		return nil, errors.New("invalid filter item '" + item + "'")
+	}
+	var attr = item[:index]
+	var value = item[index+1:]
+	if strings.ContainsAny(attr, "<>~:") {
+		return nil, errors.New("unsupported filter item '" + item + "'")
+	}
+
+	if value == "*" {
+		return newBERPrimitive(berClassContext, ldapFilterPresent, []byte(attr)), nil
+	}
+
+	if !strings.Contains(value, "*") {
+		unescaped, err := unescapeLDAPFilterValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return newBERConstructed(berClassContext, ldapFilterEquality, newBERString(attr), newBERString(unescaped)), nil
+	}
+
+	// 子串
+	var pieces = strings.Split(value, "*")
+	var substrings = newBERSequence()
+	for index, piece := range pieces {
+		if len(piece) == 0 {
+			continue
+		}
+		unescaped, err := unescapeLDAPFilterValue(piece)
+		if err != nil {
+			return nil, err
+		}
+		var tag = ldapSubstringAny
+		if index == 0 {
+			tag = ldapSubstringInitial
+		} else if index == len(pieces)-1 {
+			tag = ldapSubstringFinal
+		}
+		substrings.Append(newBERPrimitive(berClassContext, tag, []byte(unescaped)))
+	}
+	return newBERConstructed(berClassContext, ldapFilterSubstrings, newBERString(attr), substrings), nil
+}
+
+func unescapeLDAPFilterValue(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+	var builder = strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			builder.WriteByte(value[i])
+			continue
+		}
+		if i+3 > len(value) {
+			return "", errors.New("invalid escape in filter value '" + value + "'")
+		}
+		b, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", errors.New("invalid escape in filter value '" + value + "'")
+		}
+		builder.Write(b)
+		i += 2
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package sso
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// 测试用的LDAP服务
+type testLDAPServer struct {
+	listener net.Listener
+	users    map[string]string // dn => password
+	entries  []*LDAPEntry
+}
+
+func newTestLDAPServer(t *testing.T) *testLDAPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var server = &testLDAPServer{
+		listener: listener,
+		users: map[string]string{
+			"cn=reader,dc=example,dc=com":           "reader-secret",
+			"uid=alice,ou=people,dc=example,dc=com": "alice-secret",
+		},
+		entries: []*LDAPEntry{
+			{
+				DN: "uid=alice,ou=people,dc=example,dc=com",
+				Attributes: map[string][]string{
+					"uid":      {"alice"},
+					"cn":       {"Alice Liu"},
+					"mail":     {"alice@example.com"},
+					"memberof": {"cn=ops,ou=groups,dc=example,dc=com", "cn=dev,ou=groups,dc=example,dc=com"},
+				},
+			},
+		},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn)
+		}
+	}()
+	return server
+}
+
+func (this *testLDAPServer) URL() string {
+	return "ldap://" + this.listener.Addr().String()
+}
+
+func (this *testLDAPServer) Close() {
+	_ = this.listener.Close()
+}
+
+func (this *testLDAPServer) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var reader = bufio.NewReader(conn)
+	var write = func(messageId int64, op *berPacket) {
+		_, _ = conn.Write(newBERSequence(newBERInteger(messageId), op).Bytes())
+	}
+	var result = func(tag int, code int64) *berPacket {
+		return newBERConstructed(berClassApplication, tag, newBEREnumerated(code), newBERString(""), newBERString(""))
+	}
+
+	for {
+		packet, err := readBERPacket(reader)
+		if err != nil {
+			return
+		}
+		var messageId = packet.Child(0).Int()
+		var op = packet.Child(1)
+		switch {
+		case op.Is(berClassApplication, ldapApplicationBindRequest):
+			var dn = op.Child(1).String()
+			var password = op.Child(2).String()
+			if len(password) > 0 && this.users[dn] == password {
+				write(messageId, result(ldapApplicationBindResponse, ldapResultSuccess))
+			} else {
+				write(messageId, result(ldapApplicationBindResponse, ldapResultInvalidCredentials))
+			}
+		case op.Is(berClassApplication, ldapApplicationSearchRequest):
+			// 只支持 (&(objectClass=*)(uid=xxx)) 格式的过滤器
+			var filter = op.Child(6)
+			var uid = ""
+			if filter.Is(berClassContext, ldapFilterAnd) && filter.Child(1).Is(berClassContext, ldapFilterEquality) {
+				uid = filter.Child(1).Child(1).String()
+			}
+			for _, entry := range this.entries {
+				if entry.First("uid") != uid {
+					continue
+				}
+				var attributes = newBERSequence()
+				for name, values := range entry.Attributes {
+					var valuesPacket = newBERConstructed(berClassUniversal, berTagSet)
+					for _, value := range values {
+						valuesPacket.Append(newBERString(value))
+					}
+					attributes.Append(newBERSequence(newBERString(name), valuesPacket))
+				}
+				write(messageId, newBERConstructed(berClassApplication, ldapApplicationSearchEntry, newBERString(entry.DN), attributes))
+			}
+			write(messageId, result(ldapApplicationSearchDone, ldapResultSuccess))
+		case op.Is(berClassApplication, ldapApplicationUnbindRequest):
+			return
+		}
+	}
+}
+
+func TestLDAPAuthenticator_Authenticate(t *testing.T) {
+	var server = newTestLDAPServer(t)
+	defer server.Close()
+
+	authenticator, err := NewLDAPAuthenticator(&LDAPConfig{
+		URL:          server.URL(),
+		BindDN:       "cn=reader,dc=example,dc=com",
+		BindPassword: "reader-secret",
+		BaseDN:       "dc=example,dc=com",
+		UserFilter:   "(&(objectClass=*)(uid={username}))",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := authenticator.Authenticate("alice", "alice-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "uid=alice,ou=people,dc=example,dc=com" || identity.Username != "alice" || identity.Email != "alice@example.com" || len(identity.Groups) != 2 {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+
+	// 错误的密码
+	for _, password := range []string{"wrong", ""} {
+		_, err = authenticator.Authenticate("alice", password)
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatal("should be invalid credentials, but got:", err)
+		}
+	}
+
+	// 不存在的用户，用户名中的特殊字符会被转义
+	for _, username := range []string{"bob", "*", "alice)(uid=*"} {
+		_, err = authenticator.Authenticate(username, "alice-secret")
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatal("should be invalid credentials, but got:", err)
+		}
+	}
+}
+
+func TestCompileLDAPFilter(t *testing.T) {
+	for _, filter := range []string{
+		"(uid=alice)",
+		"uid=alice",
+		"(&(objectClass=person)(|(uid=alice)(mail=alice@example.com))(!(disabled=TRUE)))",
+		"(cn=Ali*ce*)",
+		"(mail=*)",
+		"(cn=a\\2ab)",
+	} {
+		_, err := compileLDAPFilter(filter)
+		if err != nil {
+			t.Fatal(filter, err)
+		}
+	}
+
+	for _, filter := range []string{
+		"",
+		"(uid=alice",
+		"(&)",
+		"(uid>=1)",
+		"(cn=a\\2)",
+		"(uid=alice))",
+	} {
+		_, err := compileLDAPFilter(filter)
+		if err == nil {
+			t.Fatal("'" + filter + "' should be invalid")
+		}
+	}
+
+	if EscapeLDAPFilter("a*(b)\\") != "a\\2a\\28b\\29\\5c" {
+		t.Fatal("unexpected escaped value:", EscapeLDAPFilter("a*(b)\\"))
+	}
+}
+
+func TestMatchRoles(t *testing.T) {
+	var mappings = []*RoleMapping{
+		{Group: "ops", Modules: []string{"servers", "clusters"}},
+		{Group: "cn=admins,ou=groups,dc=example,dc=com", IsSuper: true},
+		{Group: "dev", Modules: []string{"servers", "logs"}},
+	}
+
+	matched, isSuper, modules := MatchRoles([]string{"CN=ops,ou=groups,dc=example,dc=com", "dev"}, mappings)
+	if !matched || isSuper || strings.Join(modules, ",") != "servers,clusters,logs" {
+		t.Fatal("unexpected result:", matched, isSuper, modules)
+	}
+
+	matched, isSuper, _ = MatchRoles([]string{"cn=admins,ou=groups,dc=example,dc=com"}, mappings)
+	if !matched || !isSuper {
+		t.Fatal("should be super admin")
+	}
+
+	matched, _, _ = MatchRoles([]string{"guests"}, mappings)
+	if matched {
+		t.Fatal("should not match")
+	}
+
+	matched, _, _ = MatchRoles(nil, []*RoleMapping{{Group: "*"}})
+	if !matched {
+		t.Fatal("'*' should match all")
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package sso
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	OIDCTokenAuthMethodBasic = "client_secret_basic"
+	OIDCTokenAuthMethodPost  = "client_secret_post"
+
+	oidcClockSkew = 2 * time.Minute
+)
+
+// OIDCConfig OpenID Connect配置
+type OIDCConfig struct {
+	Issuer          string   `json:"issuer"`          // 签发者，用来获取 {issuer}/.well-known/openid-configuration
+	ClientId        string   `json:"clientId"`        // 客户端ID
+	ClientSecret    string   `json:"clientSecret"`    // 客户端密钥
+	Scopes          []string `json:"scopes"`          // 除openid之外的其他scope
+	TokenAuthMethod string   `json:"tokenAuthMethod"` // 获取令牌时的认证方式
+	UsernameClaim   string   `json:"usernameClaim"`   // 用户名声明
+	FullnameClaim   string   `json:"fullnameClaim"`   // 全名声明
+	EmailClaim      string   `json:"emailClaim"`      // 邮箱声明
+	GroupsClaim     string   `json:"groupsClaim"`     // 分组声明
+	TimeoutSeconds  int      `json:"timeoutSeconds"`  // 超时时间
+}
+
+// Init 初始化
+func (this *OIDCConfig) Init() error {
+	u, err := url.Parse(this.Issuer)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return errors.New("invalid issuer '" + this.Issuer + "'")
+	}
+	if len(this.ClientId) == 0 {
+		return errors.New("'clientId' should not be empty")
+	}
+	if len(this.Scopes) == 0 {
+		this.Scopes = []string{"profile", "email"}
+	}
+	switch this.TokenAuthMethod {
+	case OIDCTokenAuthMethodBasic, OIDCTokenAuthMethodPost:
+	default:
+		this.TokenAuthMethod = OIDCTokenAuthMethodBasic
+	}
+	if len(this.UsernameClaim) == 0 {
+		this.UsernameClaim = "preferred_username"
+	}
+	if len(this.FullnameClaim) == 0 {
+		this.FullnameClaim = "name"
+	}
+	if len(this.EmailClaim) == 0 {
+		this.EmailClaim = "email"
+	}
+	if len(this.GroupsClaim) == 0 {
+		this.GroupsClaim = "groups"
+	}
+	if this.TimeoutSeconds <= 0 {
+		this.TimeoutSeconds = 10
+	}
+	return nil
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider 使用授权码流程登录
+type OIDCProvider struct {
+	config *OIDCConfig
+	client *http.Client
+}
+
+// NewOIDCProvider 获取新对象
+func NewOIDCProvider(config *OIDCConfig) (*OIDCProvider, error) {
+	if config == nil {
+		return nil, errors.New("config should not be nil")
+	}
+	err := config.Init()
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCProvider{
+		config: config,
+		client: &http.Client{
+			Timeout: time.Duration(config.TimeoutSeconds) * time.Second,
+		},
+	}, nil
+}
+
+// NewRandomString 生成state、nonce和PKCE中使用的随机字符串
+func NewRandomString() (string, error) {
+	var buf = make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// AuthCodeURL 构造跳转到认证服务的地址
+func (this *OIDCProvider) AuthCodeURL(redirectURL string, state string, nonce string, codeVerifier string) (string, error) {
+	discovery, err := this.discover()
+	if err != nil {
+		return "", err
+	}
+
+	var challenge = sha256.Sum256([]byte(codeVerifier))
+	var query = url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", this.config.ClientId)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("scope", strings.Join(append([]string{"openid"}, this.config.Scopes...), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	var separator = "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange 使用授权码换取并校验ID Token
+func (this *OIDCProvider) Exchange(code string, redirectURL string, nonce string, codeVerifier string) (*Identity, error) {
+	if len(code) == 0 {
+		return nil, errors.New("'code' should not be empty")
+	}
+	if len(nonce) == 0 {
+		return nil, errors.New("'nonce' should not be empty")
+	}
+
+	discovery, err := this.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取令牌
+	var form = url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("code_verifier", codeVerifier)
+	if this.config.TokenAuthMethod == OIDCTokenAuthMethodPost {
+		form.Set("client_id", this.config.ClientId)
+		form.Set("client_secret", this.config.ClientSecret)
+	}
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if this.config.TokenAuthMethod == OIDCTokenAuthMethodBasic {
+		req.SetBasicAuth(url.QueryEscape(this.config.ClientId), url.QueryEscape(this.config.ClientSecret))
+	}
+	var tokenResponse = &struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	err = this.doJSON(req, tokenResponse)
+	if len(tokenResponse.Error) > 0 {
+		return nil, errors.New("oidc: exchange code failed: " + tokenResponse.Error + ": " + tokenResponse.ErrorDescription)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(tokenResponse.IdToken) == 0 {
+		return nil, errors.New("oidc: no id_token in token response")
+	}
+
+	return this.verifyIdToken(discovery, tokenResponse.IdToken, nonce)
+}
+
+// 校验ID Token
+func (this *OIDCProvider) verifyIdToken(discovery *oidcDiscovery, idToken string, nonce string) (*Identity, error) {
+	var keys []*jsonWebKey
+	claims, err := verifyJWT(idToken, func(header *jwtHeader) (crypto.PublicKey, error) {
+		if keys == nil {
+			var err error
+			keys, err = this.fetchKeys(discovery)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, key := range keys {
+			if (len(header.Kid) == 0 || key.Kid == header.Kid) && (len(key.Use) == 0 || key.Use == "sig") && (len(key.Alg) == 0 || key.Alg == header.Alg) {
+				return key.PublicKey()
+			}
+		}
+		return nil, errors.New("oidc: signing key '" + header.Kid + "' not found")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var claimString = func(name string) string {
+		s, _ := claims[name].(string)
+		return s
+	}
+	var claimTime = func(name string) (time.Time, bool) {
+		n, ok := claims[name].(json.Number)
+		if !ok {
+			return time.Time{}, false
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(int64(f), 0), true
+	}
+
+	if claimString("iss") != discovery.Issuer {
+		return nil, errors.New("oidc: issuer mismatch")
+	}
+
+	// 受众
+	var audiences = []string{}
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = append(audiences, aud)
+	case []any:
+		for _, a := range aud {
+			s, ok := a.(string)
+			if ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	var audienceMatched = false
+	for _, aud := range audiences {
+		if aud == this.config.ClientId {
+			audienceMatched = true
+		}
+	}
+	if !audienceMatched {
+		return nil, errors.New("oidc: audience mismatch")
+	}
+	if len(audiences) > 1 && claimString("azp") != this.config.ClientId {
+		return nil, errors.New("oidc: authorized party mismatch")
+	}
+
+	// 时间
+	var now = time.Now()
+	expiresAt, ok := claimTime("exp")
+	if !ok || now.After(expiresAt.Add(oidcClockSkew)) {
+		return nil, errors.New("oidc: id token has expired")
+	}
+	issuedAt, ok := claimTime("iat")
+	if ok && issuedAt.After(now.Add(oidcClockSkew)) {
+		return nil, errors.New("oidc: id token is issued in the future")
+	}
+
+	if claimString("nonce") != nonce {
+		return nil, errors.New("oidc: nonce mismatch")
+	}
+
+	var subject = claimString("sub")
+	if len(subject) == 0 {
+		return nil, errors.New("oidc: missing 'sub' claim")
+	}
+
+	var identity = &Identity{
+		Subject:  subject,
+		Username: claimString(this.config.UsernameClaim),
+		Fullname: claimString(this.config.FullnameClaim),
+		Email:    claimString(this.config.EmailClaim),
+		Groups:   []string{},
+	}
+	if len(identity.Username) == 0 {
+		identity.Username = identity.Email
+	}
+	if len(identity.Username) == 0 {
+		identity.Username = subject
+	}
+	switch groups := claims[this.config.GroupsClaim].(type) {
+	case string:
+		identity.Groups = append(identity.Groups, groups)
+	case []any:
+		for _, group := range groups {
+			s, ok := group.(string)
+			if ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// 获取服务配置
+func (this *OIDCProvider) discover() (*oidcDiscovery, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(this.config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var discovery = &oidcDiscovery{}
+	err = this.doJSON(req, discovery)
+	if err != nil {
+		return nil, errors.New("oidc: discover failed: " + err.Error())
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != strings.TrimRight(this.config.Issuer, "/") {
+		return nil, errors.New("oidc: issuer '" + discovery.Issuer + "' in discovery document does not match the configured issuer")
+	}
+	if len(discovery.AuthorizationEndpoint) == 0 || len(discovery.TokenEndpoint) == 0 || len(discovery.JWKSURI) == 0 {
+		return nil, errors.New("oidc: incomplete discovery document")
+	}
+	return discovery, nil
+}
+
+// 获取签名公钥
+func (this *OIDCProvider) fetchKeys(discovery *oidcDiscovery) ([]*jsonWebKey, error) {
+	req, err := http.NewRequest(http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var keySet = &struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}{}
+	err = this.doJSON(req, keySet)
+	if err != nil {
+		return nil, errors.New("oidc: fetch keys failed: " + err.Error())
+	}
+	return keySet.Keys, nil
+}
+
+func (this *OIDCProvider) doJSON(req *http.Request, result any) error {
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	var decodeErr = json.Unmarshal(data, result)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return errors.New("decode response failed: " + decodeErr.Error())
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package sso
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testOIDCServer struct {
+	server       *httptest.Server
+	key          *rsa.PrivateKey
+	claims       map[string]any
+	codeVerifier string
+}
+
+func newTestOIDCServer(t *testing.T) *testOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s = &testOIDCServer{key: key}
+
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(writer http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(writer).Encode(map[string]any{
+			"issuer":                 s.server.URL,
+			"authorization_endpoint": s.server.URL + "/authorize",
+			"token_endpoint":         s.server.URL + "/token",
+			"jwks_uri":               s.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(writer http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(writer).Encode(map[string]any{
+			"keys": []map[string]any{
+				{
+					"kty": "RSA",
+					"kid": "key1",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	})
+	mux.HandleFunc("/token", func(writer http.ResponseWriter, req *http.Request) {
+		clientId, clientSecret, _ := req.BasicAuth()
+		if clientId != "edge" || clientSecret != "secret" || req.FormValue("code") != "code1" {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		s.codeVerifier = req.FormValue("code_verifier")
+		_ = json.NewEncoder(writer).Encode(map[string]any{
+			"id_token": s.sign(t, s.claims),
+		})
+	})
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+func (this *testOIDCServer) sign(t *testing.T, claims map[string]any) string {
+	headerJSON, _ := json.Marshal(map[string]any{"alg": "RS256", "kid": "key1", "typ": "JWT"})
+	claimsJSON, _ := json.Marshal(claims)
+	var data = base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	var sum = sha256.Sum256([]byte(data))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, this.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	var server = newTestOIDCServer(t)
+	defer server.server.Close()
+
+	provider, err := NewOIDCProvider(&OIDCConfig{
+		Issuer:       server.server.URL,
+		ClientId:     "edge",
+		ClientSecret: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := provider.AuthCodeURL("https://admin.example.com/sso/callback", "state1", "nonce1", "verifier1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("state") != "state1" || u.Query().Get("code_challenge_method") != "S256" || !strings.Contains(u.Query().Get("scope"), "openid") {
+		t.Fatal("unexpected auth url:", authURL)
+	}
+
+	var validClaims = func() map[string]any {
+		return map[string]any{
+			"iss":                server.server.URL,
+			"sub":                "10001",
+			"aud":                "edge",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"iat":                time.Now().Unix(),
+			"nonce":              "nonce1",
+			"preferred_username": "alice",
+			"email":              "alice@example.com",
+			"groups":             []string{"ops", "dev"},
+		}
+	}
+
+	server.claims = validClaims()
+	identity, err := provider.Exchange("code1", "https://admin.example.com/sso/callback", "nonce1", "verifier1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "10001" || identity.Username != "alice" || len(identity.Groups) != 2 {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+	if server.codeVerifier != "verifier1" {
+		t.Fatal("code verifier should be sent")
+	}
+
+	// 不合法的令牌
+	for name, modify := range map[string]func(claims map[string]any){
+		"nonce":    func(claims map[string]any) { claims["nonce"] = "other" },
+		"audience": func(claims map[string]any) { claims["aud"] = "other" },
+		"issuer":   func(claims map[string]any) { claims["iss"] = "https://evil.example.com" },
+		"expired":  func(claims map[string]any) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"azp":      func(claims map[string]any) { claims["aud"] = []string{"edge", "other"} },
+	} {
+		server.claims = validClaims()
+		modify(server.claims)
+		_, err = provider.Exchange("code1", "https://admin.example.com/sso/callback", "nonce1", "verifier1")
+		if err == nil {
+			t.Fatal("should fail with invalid " + name)
+		}
+	}
+
+	// 错误的授权码
+	server.claims = validClaims()
+	_, err = provider.Exchange("code2", "https://admin.example.com/sso/callback", "nonce1", "verifier1")
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatal("should fail with invalid_grant, but got:", err)
+	}
+}
+
+func TestVerifyJWT_Algorithm(t *testing.T) {
+	var server = newTestOIDCServer(t)
+	defer server.server.Close()
+
+	var token = server.sign(t, map[string]any{"sub": "1"})
+	var pieces = strings.Split(token, ".")
+
+	// 不允许使用none算法
+	headerJSON, _ := json.Marshal(map[string]any{"alg": "none"})
+	var noneToken = base64.RawURLEncoding.EncodeToString(headerJSON) + "." + pieces[1] + "."
+	_, err := verifyJWT(noneToken, func(header *jwtHeader) (crypto.PublicKey, error) {
+		return &server.key.PublicKey, nil
+	})
+	if err == nil {
+		t.Fatal("'none' algorithm should not be allowed")
+	}
+
+	// 篡改内容
+	claimsJSON, _ := json.Marshal(map[string]any{"sub": "2"})
+	var tamperedToken = pieces[0] + "." + base64.RawURLEncoding.EncodeToString(claimsJSON) + "." + pieces[2]
+	_, err = verifyJWT(tamperedToken, func(header *jwtHeader) (crypto.PublicKey, error) {
+		return &server.key.PublicKey, nil
+	})
+	if err == nil {
+		t.Fatal("tampered token should be invalid")
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package tasks
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/ctlogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		goman.New(func() {
+			NewCTLogMonitorExecutor(10 * time.Minute).Start()
+		})
+	})
+}
+
+// CTLogMonitorExecutor 证书透明度日志监控
+// 定期从证书透明度日志中查找包含本系统中域名的证书，如果证书不是本系统签发或上传的，则发送消息提醒
+type CTLogMonitorExecutor struct {
+	BaseTask
+
+	ticker     *time.Ticker
+	httpClient *http.Client
+	lastRunAt  time.Time
+}
+
+func NewCTLogMonitorExecutor(duration time.Duration) *CTLogMonitorExecutor {
+	return &CTLogMonitorExecutor{
+		ticker:     time.NewTicker(duration),
+		httpClient: utils.SharedHttpClient(60 * time.Second),
+	}
+}
+
+func (this *CTLogMonitorExecutor) Start() {
+	for range this.ticker.C {
+		err := this.Loop()
+		if err != nil {
+			this.logErr("CTLogMonitorExecutor", err.Error())
+		}
+	}
+}
+
+// Loop 单次执行
+func (this *CTLogMonitorExecutor) Loop() error {
+	// 检查是否为主节点
+	if !this.IsPrimaryNode() {
+		return nil
+	}
+
+	config, err := models.SharedSysSettingDAO.ReadCTLogMonitorConfig(nil)
+	if err != nil {
+		return err
+	}
+	if !config.IsOn {
+		return nil
+	}
+	if time.Since(this.lastRunAt) < time.Duration(config.IntervalMinutes)*time.Minute {
+		return nil
+	}
+	this.lastRunAt = time.Now()
+
+	// 通过通用的系统设置接口修改的配置可能没有设置开始时间
+	if config.SinceAt <= 0 {
+		err = models.SharedSysSettingDAO.UpdateCTLogMonitorConfig(nil, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	return this.Check(config)
+}
+
+// Check 使用某个配置检查一次
+func (this *CTLogMonitorExecutor) Check(config *models.CTLogMonitorConfig) error {
+	err := config.Init()
+	if err != nil {
+		return err
+	}
+
+	matcher, knownSerials, err := this.loadDomains()
+	if err != nil {
+		return err
+	}
+	if matcher.Len() == 0 {
+		return nil
+	}
+
+	source, err := ctlogs.NewSource(config.SourceType, config.APIURL, this.httpClient)
+	if err != nil {
+		return err
+	}
+	switch s := source.(type) {
+	case ctlogs.DomainSource:
+		return this.checkDomains(config, s, matcher, knownSerials)
+	case ctlogs.LogSource:
+		return this.checkLog(config, s, matcher, knownSerials)
+	}
+	return errors.New("unsupported source type '" + config.SourceType + "'")
+}
+
+// 逐个域名查询
+func (this *CTLogMonitorExecutor) checkDomains(config *models.CTLogMonitorConfig, source ctlogs.DomainSource, matcher *ctlogs.DomainMatcher, knownSerials map[string]bool) error {
+	for index, domain := range matcher.QueryDomains() {
+		if index > 0 && config.RequestIntervalMs > 0 {
+			time.Sleep(time.Duration(config.RequestIntervalMs) * time.Millisecond)
+		}
+
+		entries, err := source.FindEntriesWithDomain(domain)
+		if err != nil {
+			// 单个域名失败时继续查询其他域名
+			this.logErr("CTLogMonitorExecutor", "query domain '"+domain+"' failed: "+err.Error())
+			continue
+		}
+		for _, entry := range entries {
+			err = this.processEntry(config, entry, matcher, knownSerials)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 按顺序读取日志
+// 第一次读取时从日志当前位置开始，不处理以前的记录；
+// 单次读取不完时，下一次定时任务触发时立即继续读取，不再等待检查间隔，
+// 但是大型的公共日志每天新增的记录数量非常大，仍然可能追不上，此时建议使用crt.sh或者记录较少的日志
+func (this *CTLogMonitorExecutor) checkLog(config *models.CTLogMonitorConfig, source ctlogs.LogSource, matcher *ctlogs.DomainMatcher, knownSerials map[string]bool) error {
+	state, err := models.SharedSysSettingDAO.ReadCTLogMonitorState(nil)
+	if err != nil {
+		return err
+	}
+
+	treeSize, err := source.TreeSize()
+	if err != nil {
+		return err
+	}
+
+	if state.APIURL != config.APIURL || state.NextIndex > treeSize {
+		state.APIURL = config.APIURL
+		state.NextIndex = treeSize
+		return models.SharedSysSettingDAO.UpdateCTLogMonitorState(nil, state)
+	}
+
+	const batchSize = 256
+	var endIndex = treeSize
+	if endIndex-state.NextIndex > int64(config.MaxEntriesPerLoop) {
+		endIndex = state.NextIndex + int64(config.MaxEntriesPerLoop)
+
+		// 尚未读取完，下次立即继续
+		this.lastRunAt = time.Time{}
+		this.logErr("CTLogMonitorExecutor", "ct log is "+types.String(treeSize-state.NextIndex)+" entries behind, it will continue reading in next loop")
+	}
+	for state.NextIndex < endIndex {
+		var size = batchSize
+		if endIndex-state.NextIndex < int64(size) {
+			size = int(endIndex - state.NextIndex)
+		}
+		entries, next, err := source.FetchEntries(state.NextIndex, size)
+		if err != nil {
+			return err
+		}
+		if next <= state.NextIndex {
+			break
+		}
+		for _, entry := range entries {
+			err = this.processEntry(config, entry, matcher, knownSerials)
+			if err != nil {
+				return err
+			}
+		}
+
+		state.NextIndex = next
+		err = models.SharedSysSettingDAO.UpdateCTLogMonitorState(nil, state)
+		if err != nil {
+			return err
+		}
+
+		if config.RequestIntervalMs > 0 {
+			time.Sleep(time.Duration(config.RequestIntervalMs) * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+// 处理单条记录
+func (this *CTLogMonitorExecutor) processEntry(config *models.CTLogMonitorConfig, entry *ctlogs.Entry, matcher *ctlogs.DomainMatcher, knownSerials map[string]bool) error {
+	if len(entry.SerialNumber) == 0 || knownSerials[entry.SerialNumber] {
+		return nil
+	}
+
+	// 忽略已过期和开始监控之前签发的证书
+	if !entry.NotAfter.IsZero() && entry.NotAfter.Before(time.Now()) {
+		return nil
+	}
+	var issuedAt = entry.NotBefore
+	if issuedAt.IsZero() {
+		issuedAt = entry.LoggedAt
+	}
+	if issuedAt.Unix() < config.SinceAt {
+		return nil
+	}
+
+	for domain, ownerKeys := range matcher.Match(entry.AllNames()) {
+		for _, ownerKey := range ownerKeys {
+			adminId, userId := this.decodeOwnerKey(ownerKey)
+			isCreated, err := models.SharedCTLogCertDAO.CreateCertIfNotExists(nil, adminId, userId, domain, config.SourceType, entry)
+			if err != nil {
+				return err
+			}
+			if !isCreated {
+				continue
+			}
+
+			var subject = "在证书透明度日志中发现了包含域名\"" + domain + "\"的未知证书"
+			var msg = "在证书透明度日志中发现了包含域名\"" + domain + "\"的SSL证书（颁发机构：" + entry.IssuerName + "，序列号：" + entry.SerialNumber + "，生效时间：" + timeutil.FormatTime("Y-m-d H:i:s", issuedAt.Unix()) + "），此证书不是由本系统签发或上传的，如果不是你本人申请的，请及时检查域名的解析和安全设置。"
+			err = models.SharedMessageDAO.CreateMessage(nil, adminId, userId, models.MessageTypeSSLCertCTLogFound, models.MessageLevelWarning, subject, msg, maps.Map{
+				"domain":       domain,
+				"serialNumber": entry.SerialNumber,
+				"entryId":      entry.Id,
+			}.AsJSON())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 读取需要监控的域名和本系统中签发或上传过的证书序列号
+func (this *CTLogMonitorExecutor) loadDomains() (matcher *ctlogs.DomainMatcher, knownSerials map[string]bool, err error) {
+	matcher = ctlogs.NewDomainMatcher()
+	knownSerials = map[string]bool{}
+
+	const size = 1000
+
+	// 证书
+	var lastId int64 = 0
+	for {
+		certs, err := models.SharedSSLCertDAO.ListEnabledCertsAfterId(nil, lastId, size)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, cert := range certs {
+			lastId = int64(cert.Id)
+
+			var serialNumber = ctlogs.ParseSerialNumber(cert.CertData)
+			if len(serialNumber) > 0 {
+				knownSerials[serialNumber] = true
+			}
+
+			if models.IsNotNull(cert.DnsNames) {
+				var dnsNames = []string{}
+				err = json.Unmarshal(cert.DnsNames, &dnsNames)
+				if err != nil {
+					continue
+				}
+				for _, dnsName := range dnsNames {
+					matcher.AddDomain(dnsName, this.encodeOwnerKey(int64(cert.AdminId), int64(cert.UserId)))
+				}
+			}
+		}
+		if len(certs) < size {
+			break
+		}
+	}
+
+	// 证书续期或重新上传之前的序列号
+	lastId = 0
+	for {
+		serials, err := models.SharedSSLCertSerialDAO.ListSerialsAfterId(nil, lastId, size)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, serial := range serials {
+			lastId = int64(serial.Id)
+			knownSerials[serial.SerialNumber] = true
+		}
+		if len(serials) < size {
+			break
+		}
+	}
+
+	// 服务
+	lastId = 0
+	for {
+		servers, err := models.SharedServerDAO.ListEnabledServerNamesAfterId(nil, lastId, size)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, server := range servers {
+			lastId = int64(server.Id)
+
+			if models.IsNotNull(server.PlainServerNames) {
+				var serverNames = []string{}
+				err = json.Unmarshal(server.PlainServerNames, &serverNames)
+				if err != nil {
+					continue
+				}
+				for _, serverName := range serverNames {
+					matcher.AddDomain(serverName, this.encodeOwnerKey(int64(server.AdminId), int64(server.UserId)))
+				}
+			}
+		}
+		if len(servers) < size {
+			break
+		}
+	}
+
+	return
+}
+
+func (this *CTLogMonitorExecutor) encodeOwnerKey(adminId int64, userId int64) string {
+	return types.String(adminId) + "_" + types.String(userId)
+}
+
+func (this *CTLogMonitorExecutor) decodeOwnerKey(ownerKey string) (adminId int64, userId int64) {
+	adminIdString, userIdString, _ := strings.Cut(ownerKey, "_")
+	return types.Int64(adminIdString), types.Int64(userIdString)
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package tasks_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/ctlogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/tasks"
+	"github.com/iwind/TeaGo/dbs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCTLogMonitorExecutor_Check(t *testing.T) {
+	dbs.NotifyReady()
+
+	// 用本地服务代替crt.sh
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		t.Log("query:", req.URL.Query().Get("q"))
+		_, _ = writer.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	var config = models.DefaultCTLogMonitorConfig()
+	config.IsOn = true
+	config.SourceType = ctlogs.SourceTypeCrtSh
+	config.APIURL = server.URL
+	config.RequestIntervalMs = 0
+	config.SinceAt = time.Now().Unix()
+
+	var task = tasks.NewCTLogMonitorExecutor(10 * time.Minute)
+	err := task.Check(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("ok")
+}
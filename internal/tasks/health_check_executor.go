@@ -29,10 +29,16 @@ type HealthCheckExecutor struct {
 	BaseTask
 
 	clusterId int64
+
+	firedNodeEvents map[string]bool // 本次检查中已触发的节点事件：nodeId@event => true
+	firedLocker     sync.Mutex
 }
 
 func NewHealthCheckExecutor(clusterId int64) *HealthCheckExecutor {
-	return &HealthCheckExecutor{clusterId: clusterId}
+	return &HealthCheckExecutor{
+		clusterId:       clusterId,
+		firedNodeEvents: map[string]bool{},
+	}
 }
 
 func (this *HealthCheckExecutor) Run() ([]*HealthCheckResult, error) {
@@ -238,6 +244,16 @@ func (this *HealthCheckExecutor) runNode(healthCheckConfig *serverconfigs.Health
 				this.logErr("HealthCheckExecutor", err.Error())
 				return
 			}
+
+			// 触发节点动作
+			var event = models.NodeActionEventHealthCheckFailed
+			if result.IsOk {
+				event = models.NodeActionEventHealthCheckRecovered
+			}
+			err = this.fireNodeEventActions(result.Node, event)
+			if err != nil {
+				this.logErr("HealthCheckExecutor", err.Error())
+			}
 		}
 	} else {
 		// 通知健康检查结果
@@ -253,6 +269,16 @@ func (this *HealthCheckExecutor) runNode(healthCheckConfig *serverconfigs.Health
 			this.logErr("HealthCheckExecutor", err.Error())
 			return
 		}
+
+		// 触发节点动作：失败时受冷却时间限制；只有在之前的动作仍然生效时才触发恢复
+		if !result.IsOk {
+			err = this.fireNodeEventActions(result.Node, models.NodeActionEventHealthCheckFailed)
+		} else if result.Node.DecodeActionStatus() != nil {
+			err = this.fireNodeEventActions(result.Node, models.NodeActionEventHealthCheckRecovered)
+		}
+		if err != nil {
+			this.logErr("HealthCheckExecutor", err.Error())
+		}
 	}
 }
 
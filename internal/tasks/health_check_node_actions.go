@@ -0,0 +1,116 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package tasks
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/messagemedias"
+	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"time"
+)
+
+// 触发节点事件相关的动作
+func (this *HealthCheckExecutor) fireNodeEventActions(node *models.Node, event models.NodeActionEvent) error {
+	if node == nil {
+		return nil
+	}
+	var nodeId = int64(node.Id)
+
+	// 同一个节点可能有多个IP，每次检查中同一个事件只触发一次
+	var eventKey = types.String(nodeId) + "@" + event
+	this.firedLocker.Lock()
+	if this.firedNodeEvents[eventKey] {
+		this.firedLocker.Unlock()
+		return nil
+	}
+	this.firedNodeEvents[eventKey] = true
+	this.firedLocker.Unlock()
+
+	var tx *dbs.Tx
+	actions, err := models.SharedNodeActionDAO.FindAllAvailableNodeActions(tx, nodeconfigs.NodeRoleNode, nodeId)
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		if !action.DecodeConds().MatchEvent(event) {
+			continue
+		}
+
+		err = this.runNodeAction(tx, node, action, event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 执行单个动作
+func (this *HealthCheckExecutor) runNodeAction(tx *dbs.Tx, node *models.Node, action *models.NodeAction, event models.NodeActionEvent) error {
+	var nodeId = int64(node.Id)
+
+	actionConfig, err := action.DecodeAction()
+	if err != nil {
+		return models.SharedNodeActionLogDAO.CreateLog(tx, action, this.clusterId, event, "", errors.New("decode action failed: "+err.Error()))
+	}
+
+	// 检查冷却时间
+	latestLog, err := models.SharedNodeActionLogDAO.FindLatestLog(tx, int64(action.Id), nodeId)
+	if err != nil {
+		return err
+	}
+	if latestLog != nil && latestLog.Event == event && int64(latestLog.CreatedAt)+actionConfig.CooldownSeconds > time.Now().Unix() {
+		return nil
+	}
+
+	var actionErr error
+	switch actionConfig.Code {
+	case models.NodeActionCodeSwitchToBackupCluster:
+		var backupClusterId = actionConfig.Params.GetInt64("clusterId")
+		if backupClusterId <= 0 || backupClusterId == this.clusterId {
+			actionErr = errors.New("invalid backup cluster id '" + types.String(backupClusterId) + "'")
+			break
+		}
+		exists, err := models.SharedNodeClusterDAO.ExistsEnabledCluster(tx, backupClusterId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			actionErr = errors.New("backup cluster '" + types.String(backupClusterId) + "' not found")
+			break
+		}
+		actionErr = models.SharedNodeDAO.UpdateNodeActionStatus(tx, nodeId, &models.NodeActionStatus{
+			ActionId:        int64(action.Id),
+			Code:            actionConfig.Code,
+			BackupClusterId: backupClusterId,
+			CreatedAt:       time.Now().Unix(),
+		})
+	case models.NodeActionCodeOffline:
+		actionErr = models.SharedNodeDAO.UpdateNodeActionStatus(tx, nodeId, &models.NodeActionStatus{
+			ActionId:  int64(action.Id),
+			Code:      actionConfig.Code,
+			CreatedAt: time.Now().Unix(),
+		})
+	case models.NodeActionCodeRestore:
+		actionErr = models.SharedNodeDAO.UpdateNodeActionStatus(tx, nodeId, nil)
+	case models.NodeActionCodeWebHook:
+		var media = &messagemedias.WebHookMedia{}
+		actionErr = media.Init(actionConfig.Params)
+		if actionErr == nil {
+			var subject = "节点\"" + node.Name + "\"触发动作"
+			var body = "节点\"" + node.Name + "\"(ID:" + types.String(nodeId) + ")触发事件：" + event
+			_, actionErr = media.Send("", subject, body)
+		}
+	default:
+		actionErr = errors.New("unknown action code '" + actionConfig.Code + "'")
+	}
+
+	if actionErr != nil {
+		this.logErr("HealthCheckExecutor", "run node action '"+types.String(action.Id)+"' failed: "+actionErr.Error())
+	}
+
+	return models.SharedNodeActionLogDAO.CreateLog(tx, action, this.clusterId, event, actionConfig.Code, actionErr)
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+//go:build !plus
+
+package tasks
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/goman"
+	"github.com/TeaOSLab/EdgeAPI/internal/messagemedias"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		goman.New(func() {
+			NewMessageTaskExecutor(10 * time.Second).Start()
+		})
+	})
+}
+
+// MessageTaskExecutor 消息发送任务执行器
+type MessageTaskExecutor struct {
+	BaseTask
+
+	ticker    *time.Ticker
+	findMedia func(mediaType messagemedias.MediaType) messagemedias.MediaInterface
+}
+
+// NewMessageTaskExecutor 获取新对象
+func NewMessageTaskExecutor(duration time.Duration) *MessageTaskExecutor {
+	return &MessageTaskExecutor{
+		ticker:    time.NewTicker(duration),
+		findMedia: messagemedias.FindMedia,
+	}
+}
+
+// Start 开始运行
+func (this *MessageTaskExecutor) Start() {
+	for {
+		select {
+		case <-this.ticker.C:
+		case <-models.MessageTasksNotifier:
+			time.Sleep(1 * time.Second) // 等待同一批次的任务创建完成
+		}
+
+		err := this.Loop()
+		if err != nil {
+			this.logErr("MessageTaskExecutor", err.Error())
+		}
+	}
+}
+
+// Loop 单次运行
+func (this *MessageTaskExecutor) Loop() error {
+	if !this.IsPrimaryNode() {
+		return nil
+	}
+
+	return this.loop()
+}
+
+func (this *MessageTaskExecutor) loop() error {
+	var tx *dbs.Tx
+
+	// 处理长时间没有返回的任务
+	err := models.SharedMessageTaskDAO.ResetTimeoutSendingTasks(tx, 300)
+	if err != nil {
+		return err
+	}
+
+	// 重试发送失败的任务
+	err = models.SharedMessageTaskDAO.RetryFailedMessageTasks(tx, 100)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := models.SharedMessageTaskDAO.FindSendingMessageTasks(tx, 100)
+	if err != nil {
+		return err
+	}
+
+	var cacheMap = utils.NewCacheMap()
+	var sentCountMap = map[int64]int64{} // instanceId => count
+	for _, task := range tasks {
+		var taskId = int64(task.Id)
+		var instanceId = int64(task.InstanceId)
+
+		instance, err := models.SharedMessageMediaInstanceDAO.FindEnabledMessageMediaInstance(tx, instanceId, cacheMap)
+		if err != nil {
+			return err
+		}
+		if instance == nil || !instance.IsOn {
+			err = models.SharedMessageTaskDAO.UpdateMessageTaskResult(tx, taskId, false, "media instance not found or disabled", "")
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// 检查发送频率，超出限制的任务留到以后再发送
+		var rate = instance.DecodeRate()
+		if rate.IsValid() {
+			sentCount, ok := sentCountMap[instanceId]
+			if !ok {
+				sentCount, err = models.SharedMessageTaskDAO.CountInstanceSentTasks(tx, instanceId, time.Now().Unix()-int64(rate.Minutes)*60)
+				if err != nil {
+					return err
+				}
+			}
+			if sentCount >= int64(rate.Count) {
+				sentCountMap[instanceId] = sentCount
+				continue
+			}
+			sentCountMap[instanceId] = sentCount + 1
+		}
+
+		err = models.SharedMessageTaskDAO.UpdateMessageTaskStatus(tx, taskId, models.MessageTaskStatusSending, nil)
+		if err != nil {
+			return err
+		}
+
+		var isOk = true
+		var errString string
+		resp, sendErr := this.send(instance, task)
+		if sendErr != nil {
+			isOk = false
+			errString = sendErr.Error()
+		}
+		err = models.SharedMessageTaskDAO.UpdateMessageTaskResult(tx, taskId, isOk, errString, string(resp))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 通过媒介发送单个任务
+func (this *MessageTaskExecutor) send(instance *models.MessageMediaInstance, task *models.MessageTask) ([]byte, error) {
+	var media = this.findMedia(instance.MediaType)
+	if media == nil {
+		return nil, errors.New("unsupported media type '" + instance.MediaType + "'")
+	}
+
+	err := media.Init(instance.DecodeParams())
+	if err != nil {
+		return nil, errors.New("init media failed: " + err.Error())
+	}
+
+	return media.Send(task.User, task.Subject, task.Body)
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+//go:build !plus
+
+package tasks
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/messagemedias"
+	"github.com/iwind/TeaGo/assert"
+	"github.com/iwind/TeaGo/maps"
+	"testing"
+	"time"
+)
+
+type testMessageMedia struct {
+	params  maps.Map
+	user    string
+	subject string
+	body    string
+	sendErr error
+}
+
+func (this *testMessageMedia) Init(params maps.Map) error {
+	if len(params.GetString("url")) == 0 {
+		return errors.New("'url' should not be empty")
+	}
+	this.params = params
+	return nil
+}
+
+func (this *testMessageMedia) Send(user string, subject string, body string) (resp []byte, err error) {
+	this.user = user
+	this.subject = subject
+	this.body = body
+	return []byte("ok"), this.sendErr
+}
+
+func TestMessageTaskExecutor_Send(t *testing.T) {
+	var a = assert.NewAssertion(t)
+
+	var media = &testMessageMedia{}
+	var executor = NewMessageTaskExecutor(10 * time.Second)
+	executor.findMedia = func(mediaType messagemedias.MediaType) messagemedias.MediaInterface {
+		if mediaType == "test" {
+			return media
+		}
+		return nil
+	}
+
+	var task = &models.MessageTask{
+		User:    "hello@example.com",
+		Subject: "Subject",
+		Body:    "Body",
+	}
+
+	// 发送成功
+	resp, err := executor.send(&models.MessageMediaInstance{
+		MediaType: "test",
+		Params:    []byte(`{"url":"https://example.com/hook"}`),
+	}, task)
+	a.IsNil(err)
+	a.IsTrue(string(resp) == "ok")
+	a.IsTrue(media.user == task.User && media.subject == task.Subject && media.body == task.Body)
+	a.IsTrue(media.params.GetString("url") == "https://example.com/hook")
+
+	// 发送失败
+	media.sendErr = errors.New("connection refused")
+	_, err = executor.send(&models.MessageMediaInstance{
+		MediaType: "test",
+		Params:    []byte(`{"url":"https://example.com/hook"}`),
+	}, task)
+	a.IsNotNil(err)
+
+	// 参数错误
+	_, err = executor.send(&models.MessageMediaInstance{
+		MediaType: "test",
+		Params:    []byte(`{}`),
+	}, task)
+	a.IsNotNil(err)
+
+	// 不支持的媒介
+	_, err = executor.send(&models.MessageMediaInstance{
+		MediaType: "unknown",
+	}, task)
+	a.IsNotNil(err)
+}
+
+func TestMessageTaskRetryDelay(t *testing.T) {
+	var a = assert.NewAssertion(t)
+	a.IsTrue(models.MessageTaskRetryDelay(0) == models.MessageTaskRetryBaseSeconds)
+	a.IsTrue(models.MessageTaskRetryDelay(1) == models.MessageTaskRetryBaseSeconds)
+	a.IsTrue(models.MessageTaskRetryDelay(2) == models.MessageTaskRetryBaseSeconds*2)
+	a.IsTrue(models.MessageTaskRetryDelay(3) == models.MessageTaskRetryBaseSeconds*4)
+	a.IsTrue(models.MessageTaskRetryDelay(10) == models.MessageTaskRetryBaseSeconds*4)
+}
@@ -2,6 +2,7 @@ package tasks
 
 import (
 	"encoding/json"
+	acmeutils "github.com/TeaOSLab/EdgeAPI/internal/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/goman"
@@ -9,6 +10,7 @@ import (
 	"github.com/iwind/TeaGo/maps"
 	"github.com/iwind/TeaGo/types"
 	timeutil "github.com/iwind/TeaGo/utils/time"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -27,11 +29,24 @@ type SSLCertExpireCheckExecutor struct {
 	BaseTask
 
 	ticker *time.Ticker
+
+	renewalInfoMap map[int64]*sslCertRenewalInfo // certId => 续期信息
+	renewedTaskIds map[int64]bool                // 单次执行中已经执行过的ACME任务
+}
+
+// 证书的ACME续期信息（ARI）
+type sslCertRenewalInfo struct {
+	timeEndAt uint64                 // 查询时证书的过期时间，证书更新后需要重新查询
+	info      *acmeutils.RenewalInfo // 为nil表示服务商不支持ARI
+	ariCertId string                 // ARI中的证书ID
+	checkAt   time.Time              // 下次查询的时间
 }
 
 func NewSSLCertExpireCheckExecutor(duration time.Duration) *SSLCertExpireCheckExecutor {
 	return &SSLCertExpireCheckExecutor{
-		ticker: time.NewTicker(duration),
+		ticker:         time.NewTicker(duration),
+		renewalInfoMap: map[int64]*sslCertRenewalInfo{},
+		renewedTaskIds: map[int64]bool{},
 	}
 }
 
@@ -52,35 +67,32 @@ func (this *SSLCertExpireCheckExecutor) Loop() error {
 		return nil
 	}
 
-	// 查找需要自动更新的证书
-	// 30, 14 ... 是到期的天数
-	for _, days := range []int{30, 14, 7} {
+	var configMap = map[string]*models.SSLCertExpireConfig{}
+	this.renewedTaskIds = map[int64]bool{}
+
+	// 根据服务商建议的续期时间窗口自动续期
+	ariCertIds, err := this.loopRenewalInfo(configMap)
+	if err != nil {
+		return err
+	}
+
+	// 即将到期的证书：发送通知或自动续期
+	err = this.loopExpiringCerts(configMap, ariCertIds)
+	if err != nil {
+		return err
+	}
+
+	// 当天过期
+	for _, days := range []int{0} {
 		certs, err := models.SharedSSLCertDAO.FindAllExpiringCerts(nil, days)
 		if err != nil {
 			return err
 		}
 		for _, cert := range certs {
 			// 发送消息
-			var subject = "SSL证书\"" + cert.Name + "\"在" + strconv.Itoa(days) + "天后将到期，"
-			var msg = "SSL证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）在" + strconv.Itoa(days) + "天后将到期，"
-
-			// 是否有自动更新任务
-			if cert.AcmeTaskId > 0 {
-				task, err := acme.SharedACMETaskDAO.FindEnabledACMETask(nil, int64(cert.AcmeTaskId))
-				if err != nil {
-					return err
-				}
-				if task != nil {
-					if task.AutoRenew == 1 {
-						msg += "此证书是免费申请的证书，且已设置了自动续期，将会在到期前三天自动尝试续期。"
-					} else {
-						msg += "此证书是免费申请的证书，没有设置自动续期，请在到期前手动执行续期任务。"
-					}
-				}
-			} else {
-				msg += "请及时更新证书。"
-			}
-
+			var today = timeutil.Format("Y-m-d")
+			var subject = "SSL证书\"" + cert.Name + "\"在今天（" + today + "）过期"
+			var msg = "SSL证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）在今天（" + today + "）过期，请及时更新证书，之后将不再重复提醒。"
 			err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertExpiring, models.MessageLevelWarning, subject, msg, maps.Map{
 				"certId":     cert.Id,
 				"acmeTaskId": cert.AcmeTaskId,
@@ -97,119 +109,314 @@ func (this *SSLCertExpireCheckExecutor) Loop() error {
 		}
 	}
 
-	// 自动续期
-	for _, days := range []int{3, 2, 1} {
-		certs, err := models.SharedSSLCertDAO.FindAllExpiringCerts(nil, days)
-		if err != nil {
-			return err
+	return nil
+}
+
+// 检查即将到期的证书
+// 通知天数和自动续期提前天数从管理员或用户的配置中读取
+func (this *SSLCertExpireCheckExecutor) loopExpiringCerts(configMap map[string]*models.SSLCertExpireConfig, ariCertIds map[int64]bool) error {
+	var maxDays = models.SSLCertExpireMaxNotifyDays
+	if models.SSLCertExpireMaxRenewDays > maxDays {
+		maxDays = models.SSLCertExpireMaxRenewDays
+	}
+	certs, err := models.SharedSSLCertDAO.FindAllExpiringCertsWithinDays(nil, maxDays)
+	if err != nil {
+		return err
+	}
+
+	var now = time.Now()
+	var today = timeutil.Format("Y-m-d")
+	for _, cert := range certs {
+		var days = this.daysLeft(int64(cert.TimeEndAt), now)
+		if days <= 0 {
+			// 当天过期的证书单独处理
+			continue
 		}
-		for _, cert := range certs {
-			// 发送消息
-			var subject = "SSL证书\"" + cert.Name + "\"在" + strconv.Itoa(days) + "天后将到期，"
-			var msg = "SSL证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）在" + strconv.Itoa(days) + "天后将到期，"
-
-			// 是否有自动更新任务
-			if cert.AcmeTaskId > 0 {
-				task, err := acme.SharedACMETaskDAO.FindEnabledACMETask(nil, int64(cert.AcmeTaskId))
-				if err != nil {
-					return err
-				}
-				if task != nil {
-					if task.AutoRenew == 1 {
-						isOk, errMsg, _ := acme.SharedACMETaskDAO.RunTask(nil, int64(cert.AcmeTaskId))
-						if isOk {
-							// 发送成功通知
-							subject = "系统已成功为你自动更新了证书\"" + cert.Name + "\""
-							msg = "系统已成功为你自动更新了证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）。"
-							err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskSuccess, models.MessageLevelSuccess, subject, msg, maps.Map{
-								"certId":     cert.Id,
-								"acmeTaskId": cert.AcmeTaskId,
-							}.AsJSON())
-							if err != nil {
-								return err
-							}
-
-							// 更新通知时间
-							err = models.SharedSSLCertDAO.UpdateCertNotifiedAt(nil, int64(cert.Id))
-							if err != nil {
-								return err
-							}
-						} else {
-							// 发送失败通知
-							subject = "系统在尝试自动更新证书\"" + cert.Name + "\"时发生错误"
-							msg = "系统在尝试自动更新证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）时发生错误：" + errMsg + "。请检查系统设置并修复错误。"
-							err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskFailed, models.MessageLevelError, subject, msg, maps.Map{
-								"certId":     cert.Id,
-								"acmeTaskId": cert.AcmeTaskId,
-							}.AsJSON())
-							if err != nil {
-								return err
-							}
-
-							// 更新通知时间
-							err = models.SharedSSLCertDAO.UpdateCertNotifiedAt(nil, int64(cert.Id))
-							if err != nil {
-								return err
-							}
-						}
-
-						// 中止不发送消息
-						continue
-
-					} else {
-						msg += "此证书是免费申请的证书，没有设置自动续期，请在到期前手动执行续期任务。"
-					}
-				}
-			} else {
-				msg += "请及时更新证书。"
-			}
 
-			err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertExpiring, models.MessageLevelWarning, subject, msg, maps.Map{
-				"certId":     cert.Id,
-				"acmeTaskId": cert.AcmeTaskId,
-			}.AsJSON())
+		var config = this.findExpireConfig(configMap, cert)
+
+		// 是否有自动更新任务
+		var task *acme.ACMETask
+		if cert.AcmeTaskId > 0 {
+			task, err = acme.SharedACMETaskDAO.FindEnabledACMETask(nil, int64(cert.AcmeTaskId))
 			if err != nil {
 				return err
 			}
 
-			// 设置最后通知时间
-			err = models.SharedSSLCertDAO.UpdateCertNotifiedAt(nil, int64(cert.Id))
+			// 另一种私钥类型的证书跟随主证书续期和通知
+			isDualCert, err := this.isDualCert(task, cert)
 			if err != nil {
 				return err
 			}
+			if isDualCert {
+				continue
+			}
 		}
-	}
 
-	// 当天过期
-	for _, days := range []int{0} {
-		certs, err := models.SharedSSLCertDAO.FindAllExpiringCerts(nil, days)
-		if err != nil {
-			return err
-		}
-		for _, cert := range certs {
-			// 发送消息
-			var today = timeutil.Format("Y-m-d")
-			var subject = "SSL证书\"" + cert.Name + "\"在今天（" + today + "）过期"
-			var msg = "SSL证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）在今天（" + today + "）过期，请及时更新证书，之后将不再重复提醒。"
-			err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertExpiring, models.MessageLevelWarning, subject, msg, maps.Map{
-				"certId":     cert.Id,
-				"acmeTaskId": cert.AcmeTaskId,
-			}.AsJSON())
-			if err != nil {
-				return err
+		// 自动续期
+		if task != nil && task.AutoRenew == 1 && int64(cert.TimeEndAt)-now.Unix() <= int64(config.RenewBeforeDays)*86400 {
+			// 由续期信息（ARI）决定续期时间
+			if ariCertIds[int64(cert.Id)] {
+				continue
 			}
 
-			// 设置最后通知时间
-			err = models.SharedSSLCertDAO.UpdateCertNotifiedAt(nil, int64(cert.Id))
+			_, err = this.renewCert(cert, config, acme.ACMERenewalTriggerExpiring)
 			if err != nil {
 				return err
 			}
+
+			// 中止不发送消息
+			continue
+		}
+
+		// 发送消息
+		if !config.ShouldNotify(days) || timeutil.FormatTime("Y-m-d", int64(cert.NotifiedAt)) == today {
+			continue
+		}
+
+		var subject = "SSL证书\"" + cert.Name + "\"在" + strconv.Itoa(days) + "天后将到期，"
+		var msg = "SSL证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）在" + strconv.Itoa(days) + "天后将到期，"
+		if task != nil {
+			if task.AutoRenew == 1 && ariCertIds[int64(cert.Id)] {
+				msg += "此证书是免费申请的证书，且已设置了自动续期，将会在证书服务商建议的时间自动尝试续期。"
+			} else if task.AutoRenew == 1 {
+				msg += "此证书是免费申请的证书，且已设置了自动续期，将会在到期前" + strconv.Itoa(config.RenewBeforeDays) + "天自动尝试续期。"
+			} else {
+				msg += "此证书是免费申请的证书，没有设置自动续期，请在到期前手动执行续期任务。"
+			}
+		} else {
+			msg += "请及时更新证书。"
+		}
+
+		err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertExpiring, models.MessageLevelWarning, subject, msg, maps.Map{
+			"certId":     cert.Id,
+			"acmeTaskId": cert.AcmeTaskId,
+		}.AsJSON())
+		if err != nil {
+			return err
+		}
+
+		// 设置最后通知时间
+		err = models.SharedSSLCertDAO.UpdateCertNotifiedAt(nil, int64(cert.Id))
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// 根据ACME续期信息（ARI）自动续期
+// 返回由ARI决定续期时间的证书，这些证书不再使用固定的到期前天数续期
+func (this *SSLCertExpireCheckExecutor) loopRenewalInfo(configMap map[string]*models.SSLCertExpireConfig) (ariCertIds map[int64]bool, err error) {
+	ariCertIds = map[int64]bool{}
+
+	certs, err := models.SharedSSLCertDAO.FindAllUnexpiredACMECerts(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var now = time.Now()
+	var validCertIds = map[int64]bool{}
+	for _, cert := range certs {
+		var certId = int64(cert.Id)
+		validCertIds[certId] = true
+
+		task, err := acme.SharedACMETaskDAO.FindEnabledACMETask(nil, int64(cert.AcmeTaskId))
+		if err != nil {
+			return nil, err
+		}
+		if task == nil || task.AutoRenew != 1 {
+			continue
+		}
+
+		// 另一种私钥类型的证书跟随主证书续期
+		isDualCert, err := this.isDualCert(task, cert)
+		if err != nil {
+			return nil, err
+		}
+		if isDualCert {
+			continue
+		}
+
+		// 查询续期信息
+		var renewalInfo = this.renewalInfoMap[certId]
+		if renewalInfo == nil || renewalInfo.timeEndAt != cert.TimeEndAt || !now.Before(renewalInfo.checkAt) {
+			renewalInfo = this.fetchRenewalInfo(task, cert, renewalInfo)
+			this.renewalInfoMap[certId] = renewalInfo
+		}
+		if renewalInfo.info == nil {
+			continue
+		}
+		ariCertIds[certId] = true
+
+		if !renewalInfo.info.ShouldRenew(renewalInfo.ariCertId, now) {
+			continue
+		}
+
+		isOk, err := this.renewCert(cert, this.findExpireConfig(configMap, cert), acme.ACMERenewalTriggerARI)
+		if err != nil {
+			return nil, err
+		}
+		if isOk {
+			delete(this.renewalInfoMap, certId)
+		}
+	}
+
+	// 清除已经不存在的证书
+	for certId := range this.renewalInfoMap {
+		if !validCertIds[certId] {
+			delete(this.renewalInfoMap, certId)
+		}
+	}
+
+	return
+}
+
+// 从服务商查询证书续期信息
+func (this *SSLCertExpireCheckExecutor) fetchRenewalInfo(task *acme.ACMETask, cert *models.SSLCert, oldRenewalInfo *sslCertRenewalInfo) *sslCertRenewalInfo {
+	var renewalInfo = &sslCertRenewalInfo{
+		timeEndAt: cert.TimeEndAt,
+		checkAt:   time.Now().Add(acmeutils.DefaultRenewalInfoRetryAfter),
+	}
+
+	provider, err := acme.SharedACMETaskDAO.FindACMETaskProvider(nil, task)
+	if err != nil {
+		this.logErr("SSLCertExpireCheckExecutor", "find provider of acme task '"+types.String(task.Id)+"' failed: "+err.Error())
+		return renewalInfo
+	}
+	if provider == nil {
+		return renewalInfo
+	}
+
+	certData, err := models.SharedSSLCertDAO.FindCertData(nil, int64(cert.Id))
+	if err != nil {
+		this.logErr("SSLCertExpireCheckExecutor", "find data of cert '"+types.String(cert.Id)+"' failed: "+err.Error())
+		return renewalInfo
+	}
+
+	info, ariCertId, err := acmeutils.FetchRenewalInfo(provider, certData)
+	if err != nil {
+		this.logErr("SSLCertExpireCheckExecutor", "fetch renewal info of cert '"+types.String(cert.Id)+"' failed: "+err.Error())
+
+		// 查询失败时继续使用上次的结果
+		if oldRenewalInfo != nil && oldRenewalInfo.timeEndAt == cert.TimeEndAt {
+			renewalInfo.info = oldRenewalInfo.info
+			renewalInfo.ariCertId = oldRenewalInfo.ariCertId
+		}
+		return renewalInfo
+	}
+	if info != nil {
+		renewalInfo.info = info
+		renewalInfo.ariCertId = ariCertId
+		renewalInfo.checkAt = time.Now().Add(info.RetryAfter)
+	}
+	return renewalInfo
+}
+
+// 执行证书续期任务、记录续期历史并发送通知
+// 上次续期失败时，需要等到重试时间之后才会再次续期
+func (this *SSLCertExpireCheckExecutor) renewCert(cert *models.SSLCert, config *models.SSLCertExpireConfig, trigger string) (isOk bool, err error) {
+	// 同一个任务在单次执行中只续期一次
+	if this.renewedTaskIds[int64(cert.AcmeTaskId)] {
+		return false, nil
+	}
+
+	latestLog, err := acme.SharedACMERenewalLogDAO.FindLatestLogWithCertId(nil, int64(cert.Id))
+	if err != nil {
+		return false, err
+	}
+	if latestLog != nil {
+		if !latestLog.IsOk && int64(latestLog.NextRetryAt) > time.Now().Unix() {
+			return false, nil
+		}
+
+		// 刚刚续期成功的证书不再重复续期
+		if latestLog.IsOk && trigger == acme.ACMERenewalTriggerExpiring && int64(latestLog.CreatedAt) > time.Now().Unix()-86400 {
+			return false, nil
+		}
+	}
+
+	this.renewedTaskIds[int64(cert.AcmeTaskId)] = true
+	isOk, errMsg, _, taskLogId := acme.SharedACMETaskDAO.RunTaskWithLogId(nil, int64(cert.AcmeTaskId))
+
+	// 记录续期历史
+	renewalLog, err := acme.SharedACMERenewalLogDAO.CreateLog(nil, int64(cert.AdminId), int64(cert.UserId), int64(cert.Id), int64(cert.AcmeTaskId), taskLogId, trigger, isOk, errMsg, config.RetryInterval)
+	if err != nil {
+		return false, err
+	}
+
+	if isOk {
+		// 发送成功通知
+		var subject = "系统已成功为你自动更新了证书\"" + cert.Name + "\""
+		var msg = "系统已成功为你自动更新了证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）。"
+		err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskSuccess, models.MessageLevelSuccess, subject, msg, maps.Map{
+			"certId":     cert.Id,
+			"acmeTaskId": cert.AcmeTaskId,
+		}.AsJSON())
+		if err != nil {
+			return false, err
+		}
+	} else {
+		// 发送失败通知
+		var subject = "系统在尝试自动更新证书\"" + cert.Name + "\"时发生错误"
+		var msg = "系统在尝试自动更新证书\"" + cert.Name + "\"（" + this.summaryDNSNames(cert.DnsNames) + "）时发生错误：" + errMsg + "。请检查系统设置并修复错误，系统将在" + timeutil.FormatTime("Y-m-d H:i", int64(renewalLog.NextRetryAt)) + "再次尝试续期。"
+		err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskFailed, models.MessageLevelError, subject, msg, maps.Map{
+			"certId":     cert.Id,
+			"acmeTaskId": cert.AcmeTaskId,
+		}.AsJSON())
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// 更新通知时间
+	err = models.SharedSSLCertDAO.UpdateCertNotifiedAt(nil, int64(cert.Id))
+	if err != nil {
+		return false, err
+	}
+
+	return isOk, nil
+}
+
+// 判断证书是否为ACME任务中另一种私钥类型的证书
+// 主证书已经被删除时，仍然按普通证书处理
+func (this *SSLCertExpireCheckExecutor) isDualCert(task *acme.ACMETask, cert *models.SSLCert) (bool, error) {
+	if task == nil || int64(task.DualCertId) != int64(cert.Id) || task.CertId == 0 || int64(task.CertId) == int64(cert.Id) {
+		return false, nil
+	}
+	mainCert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(nil, int64(task.CertId))
+	if err != nil {
+		return false, err
+	}
+	return mainCert != nil, nil
+}
+
+// 查找证书所属管理员或用户的到期配置
+func (this *SSLCertExpireCheckExecutor) findExpireConfig(configMap map[string]*models.SSLCertExpireConfig, cert *models.SSLCert) *models.SSLCertExpireConfig {
+	var key = types.String(cert.AdminId) + "_" + types.String(cert.UserId)
+	config, ok := configMap[key]
+	if ok {
+		return config
+	}
+	config, err := models.SharedSysSettingDAO.ReadSSLCertExpireConfig(nil, int64(cert.AdminId), int64(cert.UserId))
+	if err != nil {
+		// 配置有误时使用默认配置，以免影响其他证书
+		this.logErr("SSLCertExpireCheckExecutor", "read expire config failed: "+err.Error())
+		config = models.DefaultSSLCertExpireConfig()
+	}
+	configMap[key] = config
+	return config
+}
+
+// 计算距离到期的天数，按自然日计算
+func (this *SSLCertExpireCheckExecutor) daysLeft(timeEndAt int64, now time.Time) int {
+	var endTime = time.Unix(timeEndAt, 0)
+	var endDay = time.Date(endTime.Year(), endTime.Month(), endTime.Day(), 0, 0, 0, 0, time.Local)
+	var today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return int(math.Round(endDay.Sub(today).Hours() / 24))
+}
+
 // 对证书中DNS域名的描述
 func (this *SSLCertExpireCheckExecutor) summaryDNSNames(dnsNamesJSON []byte) string {
 	if len(dnsNamesJSON) == 0 {
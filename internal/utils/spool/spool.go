@@ -0,0 +1,603 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 文件结构：
+//
+//	dir/
+//	  00000000000000000001.seg   分段文件，由若干条记录组成
+//	  00000000000000000002.seg
+//	  cursor                     已经处理到的位置
+//
+// 单条记录：
+//
+//	| length (4) | crc32 (4) | unix nano (8) | data (length) |
+const (
+	segmentExt      = ".seg"
+	cursorFile      = "cursor"
+	recordHeaderLen = 16
+
+	MaxRecordSize = 16 << 20 // 单条记录最大尺寸
+)
+
+var ErrClosed = errors.New("spool: closed")
+
+// Options 选项
+type Options struct {
+	SegmentSize int64 // 单个分段文件最大尺寸
+	MaxSize     int64 // 所有分段文件最大总尺寸，超出时丢弃最早的分段
+}
+
+// Record 读取的单条记录
+type Record struct {
+	Data      []byte
+	CreatedAt time.Time
+
+	seq    int64 // 所在分段
+	endPos int64 // 记录结束的位置
+}
+
+// Stats 积压信息
+type Stats struct {
+	Count        int64     // 未处理的记录数
+	Size         int64     // 未处理的数据尺寸
+	Segments     int       // 分段文件数量
+	OldestAt     time.Time // 最早未处理记录的写入时间，没有积压时为零值
+	DroppedCount int64     // 因为超出尺寸限制而丢弃的记录数
+}
+
+type segment struct {
+	seq   int64
+	path  string
+	size  int64
+	count int64 // 未处理的记录数
+}
+
+type cursor struct {
+	Seq    int64 `json:"seq"`
+	Offset int64 `json:"offset"`
+}
+
+// Spool 基于本地磁盘的分段预写队列
+// 写入的数据先追加到分段文件中，处理成功后再通过 Commit() 提交处理位置，进程重启后从提交的位置继续处理
+type Spool struct {
+	dir     string
+	options *Options
+
+	locker   sync.Mutex
+	segments []*segment // 按照序号排列，最后一个为当前写入的分段
+
+	writer     *os.File
+	writeBuf   *bufio.Writer
+	isDirty    bool // 是否有尚未同步到磁盘的数据
+	readCursor cursor
+
+	droppedCount int64
+	isClosed     bool
+
+	// 最早未处理记录的写入时间，在处理位置变化时失效，避免每次查询积压信息都要读取分段文件
+	oldestAt    time.Time
+	hasOldestAt bool
+}
+
+// Open 打开目录中的队列，如果目录不存在则自动创建
+func Open(dir string, options *Options) (*Spool, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	if options.MaxSize <= 0 {
+		options.MaxSize = 1 << 30
+	}
+	if options.SegmentSize <= 0 {
+		options.SegmentSize = 32 << 20
+	}
+	// 至少保留几个分段，以便超出尺寸时可以丢弃最早的分段
+	if options.SegmentSize > options.MaxSize/4 {
+		options.SegmentSize = options.MaxSize / 4
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	var spool = &Spool{
+		dir:     dir,
+		options: options,
+	}
+	err = spool.load()
+	if err != nil {
+		return nil, err
+	}
+	return spool, nil
+}
+
+// Write 写入一组记录
+func (this *Spool) Write(dataList ...[]byte) error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.isClosed {
+		return ErrClosed
+	}
+
+	var now = time.Now().UnixNano()
+	var header = make([]byte, recordHeaderLen)
+
+	// 没有积压时，第一条写入的记录即为最早的记录
+	if !this.hasOldestAt && len(dataList) > 0 && this.countRecords() == 0 {
+		this.oldestAt = time.Unix(0, now)
+		this.hasOldestAt = true
+	}
+	for _, data := range dataList {
+		if len(data) > MaxRecordSize {
+			return errors.New("spool: record too large")
+		}
+		var recordSize = int64(recordHeaderLen + len(data))
+
+		var current = this.segments[len(this.segments)-1]
+		if current.size > 0 && current.size+recordSize > this.options.SegmentSize {
+			err := this.rotate()
+			if err != nil {
+				return err
+			}
+			current = this.segments[len(this.segments)-1]
+		}
+
+		binary.BigEndian.PutUint32(header[0:], uint32(len(data)))
+		binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(data))
+		binary.BigEndian.PutUint64(header[8:], uint64(now))
+		_, err := this.writeBuf.Write(header)
+		if err != nil {
+			return err
+		}
+		_, err = this.writeBuf.Write(data)
+		if err != nil {
+			return err
+		}
+		current.size += recordSize
+		current.count++
+		this.isDirty = true
+	}
+
+	// 写入到系统缓冲区，以便进程异常退出时不丢失数据
+	err := this.writeBuf.Flush()
+	if err != nil {
+		return err
+	}
+
+	this.trim()
+	return nil
+}
+
+// Read 从上次提交的位置读取最多 size 条记录
+// 在调用 Commit() 之前，多次调用会返回同样的记录
+func (this *Spool) Read(size int) ([]*Record, error) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.isClosed {
+		return nil, ErrClosed
+	}
+
+	err := this.sync()
+	if err != nil {
+		return nil, err
+	}
+
+	var result = []*Record{}
+	var seq = this.readCursor.Seq
+	var offset = this.readCursor.Offset
+	for _, seg := range this.segments {
+		if len(result) >= size {
+			break
+		}
+		if seg.seq < seq {
+			continue
+		}
+		if seg.seq > seq {
+			offset = 0
+		}
+		if offset >= seg.size {
+			continue
+		}
+
+		records, err := this.readSegment(seg, offset, size-len(result))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, records...)
+	}
+
+	// 从处理位置开始读取，第一条记录即为最早的记录
+	if len(result) > 0 {
+		this.oldestAt = result[0].CreatedAt
+		this.hasOldestAt = true
+	}
+	return result, nil
+}
+
+// Commit 提交已经处理的记录
+// records 必须是上一次 Read() 返回结果的开头部分
+func (this *Spool) Commit(records []*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.isClosed {
+		return ErrClosed
+	}
+
+	this.hasOldestAt = false
+	for _, record := range records {
+		// 分段可能已经因为超出尺寸被丢弃
+		if record.seq < this.readCursor.Seq || (record.seq == this.readCursor.Seq && record.endPos <= this.readCursor.Offset) {
+			continue
+		}
+		var seg = this.findSegment(record.seq)
+		if seg != nil && seg.count > 0 {
+			seg.count--
+		}
+		this.readCursor = cursor{
+			Seq:    record.seq,
+			Offset: record.endPos,
+		}
+	}
+
+	// 删除已经处理完的分段
+	for len(this.segments) > 1 {
+		var first = this.segments[0]
+		if first.seq > this.readCursor.Seq || (first.seq == this.readCursor.Seq && this.readCursor.Offset < first.size) {
+			break
+		}
+		this.removeFirstSegment()
+	}
+
+	return this.writeCursor()
+}
+
+// Stats 获取积压信息
+func (this *Spool) Stats() *Stats {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	var stats = &Stats{
+		Count:        this.countRecords(),
+		Segments:     len(this.segments),
+		DroppedCount: this.droppedCount,
+	}
+	for _, seg := range this.segments {
+		if seg.seq == this.readCursor.Seq {
+			stats.Size += seg.size - this.readCursor.Offset
+		} else if seg.seq > this.readCursor.Seq {
+			stats.Size += seg.size
+		}
+	}
+
+	if stats.Count > 0 {
+		if !this.hasOldestAt && !this.isClosed {
+			this.loadOldestAt()
+		}
+		stats.OldestAt = this.oldestAt
+	}
+
+	return stats
+}
+
+// Close 关闭队列
+func (this *Spool) Close() error {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.isClosed {
+		return nil
+	}
+	this.isClosed = true
+
+	err := this.sync()
+	if err != nil {
+		_ = this.writer.Close()
+		return err
+	}
+	err = this.writer.Close()
+	if err != nil {
+		return err
+	}
+	return this.writeCursor()
+}
+
+// 加载已有的分段和处理位置
+func (this *Spool) load() error {
+	matches, err := filepath.Glob(filepath.Join(this.dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	for _, path := range matches {
+		seq, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(path), segmentExt), 10, 64)
+		if err != nil || seq <= 0 {
+			continue
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		this.segments = append(this.segments, &segment{
+			seq:  seq,
+			path: path,
+			size: stat.Size(),
+		})
+	}
+	sort.Slice(this.segments, func(i, j int) bool {
+		return this.segments[i].seq < this.segments[j].seq
+	})
+
+	// 处理位置
+	data, err := os.ReadFile(filepath.Join(this.dir, cursorFile))
+	if err == nil {
+		_ = json.Unmarshal(data, &this.readCursor)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// 删除已经处理完的分段，并统计未处理的记录数
+	var segments = []*segment{}
+	for _, seg := range this.segments {
+		if seg.seq < this.readCursor.Seq || (seg.seq == this.readCursor.Seq && this.readCursor.Offset >= seg.size) {
+			_ = os.Remove(seg.path)
+			continue
+		}
+		var offset int64
+		if seg.seq == this.readCursor.Seq {
+			offset = this.readCursor.Offset
+		}
+		count, err := this.countSegment(seg, offset)
+		if err != nil {
+			return err
+		}
+		seg.count = count
+		segments = append(segments, seg)
+	}
+	this.segments = segments
+
+	// 总是使用新的分段写入，避免在可能已经损坏的文件末尾追加数据
+	return this.rotate()
+}
+
+// 切换到新的分段
+func (this *Spool) rotate() error {
+	if this.writer != nil {
+		err := this.sync()
+		if err != nil {
+			return err
+		}
+		err = this.writer.Close()
+		if err != nil {
+			return err
+		}
+		this.writer = nil
+	}
+
+	var seq = this.readCursor.Seq + 1
+	if len(this.segments) > 0 && this.segments[len(this.segments)-1].seq >= seq {
+		seq = this.segments[len(this.segments)-1].seq + 1
+	}
+	var path = filepath.Join(this.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+	fp, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	this.writer = fp
+	this.writeBuf = bufio.NewWriterSize(fp, 64<<10)
+	this.segments = append(this.segments, &segment{
+		seq:  seq,
+		path: path,
+	})
+
+	// 新的队列，从第一个分段开始处理
+	if this.readCursor.Seq < this.segments[0].seq {
+		this.readCursor = cursor{Seq: this.segments[0].seq}
+	}
+	return nil
+}
+
+// 超出尺寸时丢弃最早的分段
+func (this *Spool) trim() {
+	var totalSize int64
+	for _, seg := range this.segments {
+		totalSize += seg.size
+	}
+	for totalSize > this.options.MaxSize && len(this.segments) > 1 {
+		var first = this.segments[0]
+		totalSize -= first.size
+		this.droppedCount += first.count
+		this.removeFirstSegment()
+	}
+}
+
+func (this *Spool) removeFirstSegment() {
+	this.hasOldestAt = false
+
+	var first = this.segments[0]
+	_ = os.Remove(first.path)
+	this.segments = this.segments[1:]
+	if this.readCursor.Seq <= first.seq {
+		this.readCursor = cursor{Seq: this.segments[0].seq}
+	}
+}
+
+// 未处理的记录数
+func (this *Spool) countRecords() int64 {
+	var count int64
+	for _, seg := range this.segments {
+		count += seg.count
+	}
+	return count
+}
+
+// 从分段文件中读取最早未处理记录的写入时间
+func (this *Spool) loadOldestAt() {
+	_ = this.writeBuf.Flush()
+	for _, seg := range this.segments {
+		if seg.count <= 0 {
+			continue
+		}
+		var offset int64
+		if seg.seq == this.readCursor.Seq {
+			offset = this.readCursor.Offset
+		}
+		records, err := this.readSegment(seg, offset, 1)
+		if err == nil && len(records) > 0 {
+			this.oldestAt = records[0].CreatedAt
+			this.hasOldestAt = true
+		}
+		return
+	}
+}
+
+func (this *Spool) findSegment(seq int64) *segment {
+	for _, seg := range this.segments {
+		if seg.seq == seq {
+			return seg
+		}
+	}
+	return nil
+}
+
+// 同步到磁盘
+func (this *Spool) sync() error {
+	err := this.writeBuf.Flush()
+	if err != nil {
+		return err
+	}
+	if this.isDirty {
+		this.isDirty = false
+		return this.writer.Sync()
+	}
+	return nil
+}
+
+// 保存处理位置
+func (this *Spool) writeCursor() error {
+	data, err := json.Marshal(this.readCursor)
+	if err != nil {
+		return err
+	}
+	var path = filepath.Join(this.dir, cursorFile)
+	err = os.WriteFile(path+".tmp", data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// 从分段中读取记录
+// 遇到不完整或者损坏的记录时停止读取该分段
+func (this *Spool) readSegment(seg *segment, offset int64, size int) ([]*Record, error) {
+	fp, err := os.Open(seg.path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	_, err = fp.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader = bufio.NewReader(io.LimitReader(fp, seg.size-offset))
+	var result = []*Record{}
+	var header = make([]byte, recordHeaderLen)
+	for len(result) < size {
+		data, createdAt, ok := this.readRecord(reader, header)
+		if !ok {
+			break
+		}
+		offset += int64(recordHeaderLen + len(data))
+		result = append(result, &Record{
+			Data:      data,
+			CreatedAt: createdAt,
+			seq:       seg.seq,
+			endPos:    offset,
+		})
+	}
+
+	// 跳过分段中损坏的部分
+	if len(result) < size && offset < seg.size && seg != this.segments[len(this.segments)-1] {
+		seg.size = offset
+	}
+	return result, nil
+}
+
+// 统计分段中的记录数
+func (this *Spool) countSegment(seg *segment, offset int64) (int64, error) {
+	fp, err := os.Open(seg.path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	_, err = fp.Seek(offset, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	var reader = bufio.NewReader(fp)
+	var header = make([]byte, recordHeaderLen)
+	var count int64
+	for {
+		data, _, ok := this.readRecord(reader, header)
+		if !ok {
+			break
+		}
+		offset += int64(recordHeaderLen + len(data))
+		count++
+	}
+
+	// 忽略末尾不完整的数据
+	seg.size = offset
+	return count, nil
+}
+
+func (this *Spool) readRecord(reader io.Reader, header []byte) (data []byte, createdAt time.Time, ok bool) {
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return
+	}
+	var length = binary.BigEndian.Uint32(header[0:])
+	if length > MaxRecordSize {
+		return
+	}
+	data = make([]byte, length)
+	_, err = io.ReadFull(reader, data)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, time.Time{}, false
+	}
+	return data, time.Unix(0, int64(binary.BigEndian.Uint64(header[8:]))), true
+}
@@ -0,0 +1,241 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package spool_test
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/spool"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSpool_WriteAndReplay(t *testing.T) {
+	var dir = t.TempDir()
+
+	s, err := spool.Open(dir, &spool.Options{SegmentSize: 256, MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		err = s.Write([]byte("log-" + strconv.Itoa(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stats = s.Stats()
+	if stats.Count != 100 || stats.Segments < 2 || stats.OldestAt.IsZero() {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// 读取但不提交时，再次读取得到相同的记录
+	records, err := s.Read(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 30 || string(records[0].Data) != "log-0" {
+		t.Fatal("unexpected records:", len(records))
+	}
+	records, err = s.Read(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(records[0].Data) != "log-0" {
+		t.Fatal("records should be read again before commit")
+	}
+
+	// 部分提交
+	err = s.Commit(records[:25])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开后从提交的位置继续
+	s, err = spool.Open(dir, &spool.Options{SegmentSize: 256, MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Close()
+	}()
+	if s.Stats().Count != 75 {
+		t.Fatal("expect 75 records after reopen, but got", s.Stats().Count)
+	}
+
+	err = s.Write([]byte("log-100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var all = []string{}
+	for {
+		records, err := s.Read(7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) == 0 {
+			break
+		}
+		for _, record := range records {
+			all = append(all, string(record.Data))
+		}
+		err = s.Commit(records)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(all) != 76 || all[0] != "log-25" || all[75] != "log-100" {
+		t.Fatal("unexpected replay:", len(all), all[0], all[len(all)-1])
+	}
+
+	stats = s.Stats()
+	if stats.Count != 0 || stats.Size != 0 || stats.Segments != 1 || !stats.OldestAt.IsZero() {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestSpool_MaxSize(t *testing.T) {
+	s, err := spool.Open(t.TempDir(), &spool.Options{SegmentSize: 1024, MaxSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Close()
+	}()
+
+	var data = make([]byte, 100)
+	for i := 0; i < 200; i++ {
+		err = s.Write(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stats = s.Stats()
+	if stats.Size > 4096 || stats.DroppedCount == 0 || stats.Count+stats.DroppedCount != 200 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	records, err := s.Read(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(records)) != stats.Count {
+		t.Fatal("expect", stats.Count, "records, but got", len(records))
+	}
+}
+
+func TestSpool_TornWrite(t *testing.T) {
+	var dir = t.TempDir()
+	s, err := spool.Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		err = s.Write([]byte("log-" + strconv.Itoa(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟进程退出时只写入了部分数据
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if len(matches) == 0 {
+		t.Fatal("no segment files")
+	}
+	fp, err := os.OpenFile(matches[0], os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fp.Write([]byte{0, 0, 0, 10, 1, 2})
+	_ = fp.Close()
+
+	s, err = spool.Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Close()
+	}()
+	err = s.Write([]byte("log-3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := s.Read(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || string(records[3].Data) != "log-3" {
+		t.Fatal("unexpected records:", len(records))
+	}
+}
+
+func TestSpool_StatsOldestAt(t *testing.T) {
+	var dir = t.TempDir()
+
+	s, err := spool.Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Close()
+	}()
+
+	if !s.Stats().OldestAt.IsZero() {
+		t.Fatal("oldestAt should be zero without backlog")
+	}
+
+	var before = time.Now()
+	err = s.Write([]byte("log-0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	var middle = time.Now()
+	err = s.Write([]byte("log-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var oldestAt = s.Stats().OldestAt
+	if oldestAt.Before(before) || !oldestAt.Before(middle) {
+		t.Fatal("unexpected oldestAt:", oldestAt)
+	}
+
+	// 提交后最早的记录变为下一条
+	records, err := s.Read(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Commit(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldestAt = s.Stats().OldestAt
+	if oldestAt.Before(middle) {
+		t.Fatal("oldestAt should move to next record, but got:", oldestAt)
+	}
+
+	// 全部提交后没有积压
+	records, err = s.Read(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Commit(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Stats().OldestAt.IsZero() {
+		t.Fatal("oldestAt should be zero after all records committed")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	FlagUserPresent    byte = 0x01 // UP
+	FlagUserVerified   byte = 0x04 // UV
+	FlagBackupEligible byte = 0x08 // BE
+	FlagBackupState    byte = 0x10 // BS
+	FlagAttestedData   byte = 0x40 // AT
+	FlagExtensionData  byte = 0x80 // ED
+)
+
+// AuthenticatorData 认证器数据
+type AuthenticatorData struct {
+	RPIdHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// 以下只在注册时有
+	AAGUID       []byte
+	CredentialId []byte
+	PublicKey    []byte // COSE格式的原始公钥数据
+}
+
+// ParseAuthenticatorData 解析认证器数据
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+
+	var result = &AuthenticatorData{
+		RPIdHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	var rest = data[37:]
+
+	if result.HasFlag(FlagAttestedData) {
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		result.AAGUID = rest[:16]
+		var credentialIdLength = int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if credentialIdLength == 0 || credentialIdLength > 1023 || len(rest) < credentialIdLength {
+			return nil, errors.New("invalid credential id")
+		}
+		result.CredentialId = rest[:credentialIdLength]
+		rest = rest[credentialIdLength:]
+
+		_, keyRest, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.New("invalid credential public key: " + err.Error())
+		}
+		result.PublicKey = rest[:len(rest)-len(keyRest)]
+		rest = keyRest
+	}
+
+	if result.HasFlag(FlagExtensionData) {
+		_, extRest, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.New("invalid extension data: " + err.Error())
+		}
+		rest = extRest
+	}
+
+	if len(rest) > 0 {
+		return nil, errors.New("unexpected data after authenticator data")
+	}
+	return result, nil
+}
+
+// HasFlag 判断是否有某个标志位
+func (this *AuthenticatorData) HasFlag(flag byte) bool {
+	return this.Flags&flag == flag
+}
@@ -0,0 +1,180 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// 最大嵌套层级
+const cborMaxDepth = 16
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+// 解析CBOR数据
+// 只实现了WebAuthn中用到的部分：整数、字节串、文本、数组、Map、标签和简单值，不支持不定长编码
+// 整数解析为int64，Map解析为map[any]any，其中的键为int64或string
+func decodeCBOR(data []byte) (value any, rest []byte, err error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (value any, rest []byte, err error) {
+	if depth > cborMaxDepth {
+		return nil, nil, errors.New("cbor: too deeply nested")
+	}
+	if len(data) == 0 {
+		return nil, nil, errCBORTruncated
+	}
+
+	var majorType = data[0] >> 5
+	var info = data[0] & 0x1f
+	data = data[1:]
+
+	// 简单值和浮点数
+	if majorType == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		case 25:
+			if len(data) < 2 {
+				return nil, nil, errCBORTruncated
+			}
+			return float64(float16ToFloat32(binary.BigEndian.Uint16(data))), data[2:], nil
+		case 26:
+			if len(data) < 4 {
+				return nil, nil, errCBORTruncated
+			}
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), data[4:], nil
+		case 27:
+			if len(data) < 8 {
+				return nil, nil, errCBORTruncated
+			}
+			return math.Float64frombits(binary.BigEndian.Uint64(data)), data[8:], nil
+		}
+		if info < 20 {
+			return int64(info), data, nil
+		}
+		return nil, nil, errors.New("cbor: unsupported simple value")
+	}
+
+	// 长度或整数值
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		if len(data) < 1 {
+			return nil, nil, errCBORTruncated
+		}
+		arg = uint64(data[0])
+		data = data[1:]
+	case info == 25:
+		if len(data) < 2 {
+			return nil, nil, errCBORTruncated
+		}
+		arg = uint64(binary.BigEndian.Uint16(data))
+		data = data[2:]
+	case info == 26:
+		if len(data) < 4 {
+			return nil, nil, errCBORTruncated
+		}
+		arg = uint64(binary.BigEndian.Uint32(data))
+		data = data[4:]
+	case info == 27:
+		if len(data) < 8 {
+			return nil, nil, errCBORTruncated
+		}
+		arg = binary.BigEndian.Uint64(data)
+		data = data[8:]
+	default:
+		return nil, nil, errors.New("cbor: indefinite length is not supported")
+	}
+
+	switch majorType {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBORTruncated
+		}
+		if majorType == 2 {
+			return append([]byte{}, data[:arg]...), data[arg:], nil
+		}
+		return string(data[:arg]), data[arg:], nil
+	case 4:
+		// 每个元素至少占用1个字节
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBORTruncated
+		}
+		var items = make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item any
+			item, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case 5:
+		if arg > uint64(len(data))/2 {
+			return nil, nil, errCBORTruncated
+		}
+		var m = make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key any
+			key, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errors.New("cbor: unsupported map key type")
+			}
+			var item any
+			item, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[key] = item
+		}
+		return m, data, nil
+	case 6:
+		// 忽略标签，直接返回被标记的值
+		return decodeCBORItem(data, depth+1)
+	}
+	return nil, nil, errors.New("cbor: invalid major type")
+}
+
+// 半精度浮点数
+func float16ToFloat32(h uint16) float32 {
+	var sign = uint32(h>>15) << 31
+	var exp = uint32(h>>10) & 0x1f
+	var frac = uint32(h & 0x3ff)
+	switch exp {
+	case 0:
+		var f = float32(frac) / 1024 / 16384
+		if sign != 0 {
+			return -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+112)<<23 | frac<<13)
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+// COSEAlgorithm COSE签名算法
+// 参考：https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+type COSEAlgorithm = int64
+
+const (
+	COSEAlgorithmES256 COSEAlgorithm = -7
+	COSEAlgorithmES384 COSEAlgorithm = -35
+	COSEAlgorithmES512 COSEAlgorithm = -36
+	COSEAlgorithmEdDSA COSEAlgorithm = -8
+	COSEAlgorithmPS256 COSEAlgorithm = -37
+	COSEAlgorithmRS256 COSEAlgorithm = -257
+)
+
+// FindAllCOSEAlgorithms 支持的签名算法，按照优先级排列
+func FindAllCOSEAlgorithms() []COSEAlgorithm {
+	return []COSEAlgorithm{
+		COSEAlgorithmES256,
+		COSEAlgorithmEdDSA,
+		COSEAlgorithmES384,
+		COSEAlgorithmES512,
+		COSEAlgorithmPS256,
+		COSEAlgorithmRS256,
+	}
+}
+
+const (
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveP384    = 2
+	coseCurveP521    = 3
+	coseCurveEd25519 = 6
+)
+
+// PublicKey 凭证公钥
+type PublicKey struct {
+	Algorithm COSEAlgorithm
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey 解析COSE格式的公钥
+func ParsePublicKey(coseKey []byte) (*PublicKey, error) {
+	value, rest, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("unexpected data after public key")
+	}
+	m, ok := value.(map[any]any)
+	if !ok {
+		return nil, errors.New("invalid public key")
+	}
+	return parseCOSEKeyMap(m)
+}
+
+func parseCOSEKeyMap(m map[any]any) (*PublicKey, error) {
+	keyType, _ := m[int64(1)].(int64)
+	alg, ok := m[int64(3)].(int64)
+	if !ok {
+		return nil, errors.New("public key: missing algorithm")
+	}
+
+	switch keyType {
+	case coseKeyTypeEC2:
+		var curve elliptic.Curve
+		crv, _ := m[int64(-1)].(int64)
+		switch crv {
+		case coseCurveP256:
+			curve = elliptic.P256()
+		case coseCurveP384:
+			curve = elliptic.P384()
+		case coseCurveP521:
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("public key: unsupported curve '" + strconv.FormatInt(crv, 10) + "'")
+		}
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if len(x) == 0 || len(y) == 0 {
+			return nil, errors.New("public key: invalid coordinates")
+		}
+		var key = &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("public key: point is not on curve")
+		}
+		return &PublicKey{Algorithm: alg, Key: key}, nil
+	case coseKeyTypeRSA:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("public key: invalid rsa parameters")
+		}
+		var exponent = int(new(big.Int).SetBytes(e).Int64())
+		return &PublicKey{Algorithm: alg, Key: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: exponent,
+		}}, nil
+	case coseKeyTypeOKP:
+		crv, _ := m[int64(-1)].(int64)
+		if crv != coseCurveEd25519 {
+			return nil, errors.New("public key: unsupported curve '" + strconv.FormatInt(crv, 10) + "'")
+		}
+		x, _ := m[int64(-2)].([]byte)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("public key: invalid ed25519 key")
+		}
+		return &PublicKey{Algorithm: alg, Key: ed25519.PublicKey(x)}, nil
+	}
+	return nil, errors.New("public key: unsupported key type '" + strconv.FormatInt(keyType, 10) + "'")
+}
+
+// Verify 校验签名
+func (this *PublicKey) Verify(data []byte, signature []byte) error {
+	return verifySignature(this.Algorithm, this.Key, data, signature)
+}
+
+func verifySignature(alg COSEAlgorithm, publicKey crypto.PublicKey, data []byte, signature []byte) error {
+	var errSignature = errors.New("invalid signature")
+
+	switch alg {
+	case COSEAlgorithmES256, COSEAlgorithmES384, COSEAlgorithmES512:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("algorithm does not match the public key")
+		}
+		var digest []byte
+		switch alg {
+		case COSEAlgorithmES256:
+			var sum = sha256.Sum256(data)
+			digest = sum[:]
+		case COSEAlgorithmES384:
+			var sum = sha512.Sum384(data)
+			digest = sum[:]
+		default:
+			var sum = sha512.Sum512(data)
+			digest = sum[:]
+		}
+		if !ecdsa.VerifyASN1(key, digest, signature) {
+			return errSignature
+		}
+		return nil
+	case COSEAlgorithmRS256, COSEAlgorithmPS256:
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("algorithm does not match the public key")
+		}
+		var sum = sha256.Sum256(data)
+		var err error
+		if alg == COSEAlgorithmRS256 {
+			err = rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature)
+		} else {
+			err = rsa.VerifyPSS(key, crypto.SHA256, sum[:], signature, nil)
+		}
+		if err != nil {
+			return errSignature
+		}
+		return nil
+	case COSEAlgorithmEdDSA:
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("algorithm does not match the public key")
+		}
+		if !ed25519.Verify(key, data, signature) {
+			return errSignature
+		}
+		return nil
+	}
+	return errors.New("unsupported algorithm '" + strconv.FormatInt(alg, 10) + "'")
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthn
+
+// 传给浏览器 navigator.credentials.create() 和 navigator.credentials.get() 的选项
+// 其中的二进制数据均使用base64url编码，需要在页面中转换为ArrayBuffer
+
+type RPEntity struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	Id          string `json:"id"` // base64url编码的用户句柄
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string        `json:"type"`
+	Alg  COSEAlgorithm `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	Id         string   `json:"id"` // base64url编码的凭证ID
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	AuthenticatorAttachment string `json:"authenticatorAttachment,omitempty"`
+	ResidentKey             string `json:"residentKey"`
+	UserVerification        string `json:"userVerification"`
+}
+
+// CreationOptions 注册选项
+type CreationOptions struct {
+	RP                     RPEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions 登录选项
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int                    `json:"timeout"`
+	RPId             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// NewCreationOptions 构造注册选项
+// excludeCredentials 为已经注册的凭证，避免同一个安全密钥重复注册
+func (this *RelyingParty) NewCreationOptions(challenge string, user UserEntity, excludeCredentials []CredentialDescriptor, userVerification string) *CreationOptions {
+	var params = []CredentialParameter{}
+	for _, alg := range FindAllCOSEAlgorithms() {
+		params = append(params, CredentialParameter{
+			Type: "public-key",
+			Alg:  alg,
+		})
+	}
+	if excludeCredentials == nil {
+		excludeCredentials = []CredentialDescriptor{}
+	}
+	return &CreationOptions{
+		RP: RPEntity{
+			Id:   this.Id,
+			Name: this.Name,
+		},
+		User:               user,
+		Challenge:          challenge,
+		PubKeyCredParams:   params,
+		Timeout:            DefaultTimeoutMs,
+		ExcludeCredentials: excludeCredentials,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "discouraged",
+			UserVerification: normalizeUserVerification(userVerification),
+		},
+		Attestation: "none",
+	}
+}
+
+// NewRequestOptions 构造登录选项
+func (this *RelyingParty) NewRequestOptions(challenge string, allowCredentials []CredentialDescriptor, userVerification string) *RequestOptions {
+	if allowCredentials == nil {
+		allowCredentials = []CredentialDescriptor{}
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          DefaultTimeoutMs,
+		RPId:             this.Id,
+		AllowCredentials: allowCredentials,
+		UserVerification: normalizeUserVerification(userVerification),
+	}
+}
+
+// NewCredentialDescriptor 构造凭证描述
+func NewCredentialDescriptor(credentialId []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{
+		Type:       "public-key",
+		Id:         EncodeBase64(credentialId),
+		Transports: transports,
+	}
+}
+
+func normalizeUserVerification(userVerification string) string {
+	switch userVerification {
+	case UserVerificationRequired, UserVerificationPreferred, UserVerificationDiscouraged:
+		return userVerification
+	}
+	return UserVerificationPreferred
+}
@@ -0,0 +1,337 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+const (
+	clientDataTypeCreate = "webauthn.create"
+	clientDataTypeGet    = "webauthn.get"
+
+	DefaultTimeoutMs = 120_000 // 默认超时时间（毫秒）
+)
+
+var ErrSignCountRegression = errors.New("sign count regression, the authenticator may have been cloned")
+
+// RelyingParty 依赖方，即管理平台或用户平台
+type RelyingParty struct {
+	Id      string   // RP ID，通常为平台的域名
+	Name    string   // 显示的名称
+	Origins []string // 允许的来源，比如 https://example.com:8080 ；为空时允许 RP ID 及其子域名的HTTPS来源
+}
+
+// NewRelyingParty 获取新对象
+func NewRelyingParty(id string, name string, origins []string) *RelyingParty {
+	if len(name) == 0 {
+		name = id
+	}
+	return &RelyingParty{
+		Id:      strings.ToLower(id),
+		Name:    name,
+		Origins: origins,
+	}
+}
+
+// NewChallenge 生成随机的挑战码
+func NewChallenge() (string, error) {
+	var buf = make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return EncodeBase64(buf), nil
+}
+
+// EncodeBase64 编码为浏览器使用的base64url格式
+func EncodeBase64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeBase64 解码base64url，兼容带补齐字符的格式
+func DecodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// Credential 注册成功的凭证
+type Credential struct {
+	Id                []byte
+	PublicKey         []byte // COSE格式的公钥
+	Algorithm         COSEAlgorithm
+	SignCount         uint32
+	AAGUID            []byte
+	AttestationFormat string
+	IsUserVerified    bool
+	IsBackupEligible  bool
+}
+
+// RegistrationResponse 注册时浏览器返回的数据
+type RegistrationResponse struct {
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// AssertionResponse 登录时浏览器返回的数据
+type AssertionResponse struct {
+	CredentialId      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+// 浏览器生成的客户端数据
+type collectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// VerifyRegistration 校验注册数据
+// 不校验认证器的证书链，只接受 none 和 packed 两种证明格式中的签名，其他格式的证明数据会被忽略
+func (this *RelyingParty) VerifyRegistration(challenge string, response *RegistrationResponse, requireUserVerification bool) (*Credential, error) {
+	if response == nil {
+		return nil, errors.New("response should not be nil")
+	}
+
+	clientDataHash, err := this.verifyClientData(response.ClientDataJSON, clientDataTypeCreate, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	value, rest, err := decodeCBOR(response.AttestationObject)
+	if err != nil {
+		return nil, errors.New("invalid attestation object: " + err.Error())
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("unexpected data after attestation object")
+	}
+	attestation, ok := value.(map[any]any)
+	if !ok {
+		return nil, errors.New("invalid attestation object")
+	}
+	format, _ := attestation["fmt"].(string)
+	rawAuthData, _ := attestation["authData"].([]byte)
+	attStmt, _ := attestation["attStmt"].(map[any]any)
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	err = this.verifyAuthenticatorData(authData, requireUserVerification)
+	if err != nil {
+		return nil, err
+	}
+	if !authData.HasFlag(FlagAttestedData) {
+		return nil, errors.New("missing attested credential data")
+	}
+
+	publicKey, err := ParsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if !isSupportedAlgorithm(publicKey.Algorithm) {
+		return nil, errors.New("unsupported public key algorithm")
+	}
+
+	// 校验证明签名
+	switch format {
+	case "none":
+	case "packed":
+		err = verifyPackedAttestation(attStmt, publicKey, append(append([]byte{}, rawAuthData...), clientDataHash...))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Credential{
+		Id:                authData.CredentialId,
+		PublicKey:         authData.PublicKey,
+		Algorithm:         publicKey.Algorithm,
+		SignCount:         authData.SignCount,
+		AAGUID:            authData.AAGUID,
+		AttestationFormat: format,
+		IsUserVerified:    authData.HasFlag(FlagUserVerified),
+		IsBackupEligible:  authData.HasFlag(FlagBackupEligible),
+	}, nil
+}
+
+// VerifyAssertion 校验登录数据，并返回新的签名计数器
+// storedSignCount 为上次保存的计数器，计数器回退时返回 ErrSignCountRegression
+func (this *RelyingParty) VerifyAssertion(challenge string, coseKey []byte, storedSignCount uint32, response *AssertionResponse, requireUserVerification bool) (signCount uint32, err error) {
+	if response == nil {
+		return 0, errors.New("response should not be nil")
+	}
+
+	clientDataHash, err := this.verifyClientData(response.ClientDataJSON, clientDataTypeGet, challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	authData, err := ParseAuthenticatorData(response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	err = this.verifyAuthenticatorData(authData, requireUserVerification)
+	if err != nil {
+		return 0, err
+	}
+
+	publicKey, err := ParsePublicKey(coseKey)
+	if err != nil {
+		return 0, err
+	}
+	err = publicKey.Verify(append(append([]byte{}, response.AuthenticatorData...), clientDataHash...), response.Signature)
+	if err != nil {
+		return 0, err
+	}
+
+	// 计数器为0表示认证器不支持计数
+	if (authData.SignCount > 0 || storedSignCount > 0) && authData.SignCount <= storedSignCount {
+		return 0, ErrSignCountRegression
+	}
+	return authData.SignCount, nil
+}
+
+// 校验客户端数据，并返回其摘要
+func (this *RelyingParty) verifyClientData(clientDataJSON []byte, dataType string, challenge string) ([]byte, error) {
+	if len(clientDataJSON) == 0 {
+		return nil, errors.New("client data should not be empty")
+	}
+	var clientData = &collectedClientData{}
+	err := json.Unmarshal(clientDataJSON, clientData)
+	if err != nil {
+		return nil, errors.New("invalid client data: " + err.Error())
+	}
+	if clientData.Type != dataType {
+		return nil, errors.New("invalid client data type '" + clientData.Type + "'")
+	}
+	if len(challenge) == 0 || subtle.ConstantTimeCompare([]byte(strings.TrimRight(clientData.Challenge, "=")), []byte(challenge)) != 1 {
+		return nil, errors.New("challenge mismatch")
+	}
+	if clientData.CrossOrigin {
+		return nil, errors.New("cross origin request is not allowed")
+	}
+	if !this.isAllowedOrigin(clientData.Origin) {
+		return nil, errors.New("origin '" + clientData.Origin + "' is not allowed")
+	}
+
+	var sum = sha256.Sum256(clientDataJSON)
+	return sum[:], nil
+}
+
+func (this *RelyingParty) verifyAuthenticatorData(authData *AuthenticatorData, requireUserVerification bool) error {
+	var rpIdHash = sha256.Sum256([]byte(this.Id))
+	if !bytes.Equal(authData.RPIdHash, rpIdHash[:]) {
+		return errors.New("rp id mismatch")
+	}
+	if !authData.HasFlag(FlagUserPresent) {
+		return errors.New("user is not present")
+	}
+	if requireUserVerification && !authData.HasFlag(FlagUserVerified) {
+		return errors.New("user is not verified")
+	}
+	return nil
+}
+
+// 检查来源
+func (this *RelyingParty) isAllowedOrigin(origin string) bool {
+	if len(origin) == 0 {
+		return false
+	}
+	if len(this.Origins) > 0 {
+		for _, allowedOrigin := range this.Origins {
+			if strings.EqualFold(strings.TrimRight(allowedOrigin, "/"), origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	var host = strings.ToLower(u.Hostname())
+	if host != this.Id && !strings.HasSuffix(host, "."+this.Id) {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		// 浏览器只允许在本机上使用HTTP
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return true
+		}
+		var ip = net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
+
+// 校验packed格式的证明
+func verifyPackedAttestation(attStmt map[any]any, credentialKey *PublicKey, signedData []byte) error {
+	if attStmt == nil {
+		return errors.New("packed attestation: missing statement")
+	}
+	alg, ok := attStmt["alg"].(int64)
+	if !ok {
+		return errors.New("packed attestation: missing alg")
+	}
+	sig, _ := attStmt["sig"].([]byte)
+	if len(sig) == 0 {
+		return errors.New("packed attestation: missing sig")
+	}
+
+	// 使用证书中的公钥签名
+	x5c, _ := attStmt["x5c"].([]any)
+	if len(x5c) > 0 {
+		certData, _ := x5c[0].([]byte)
+		cert, err := x509.ParseCertificate(certData)
+		if err != nil {
+			return errors.New("packed attestation: invalid certificate: " + err.Error())
+		}
+		err = verifySignature(alg, cert.PublicKey, signedData, sig)
+		if err != nil {
+			return errors.New("packed attestation: " + err.Error())
+		}
+		return nil
+	}
+
+	// 自证明
+	if alg != credentialKey.Algorithm {
+		return errors.New("packed attestation: algorithm mismatch")
+	}
+	err := credentialKey.Verify(signedData, sig)
+	if err != nil {
+		return errors.New("packed attestation: " + err.Error())
+	}
+	return nil
+}
+
+func isSupportedAlgorithm(alg COSEAlgorithm) bool {
+	for _, supportedAlg := range FindAllCOSEAlgorithms() {
+		if supportedAlg == alg {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,319 @@
+// Copyright 2024 GoEdge CDN goedge.cdn@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+)
+
+// 测试用的简单CBOR编码
+func testEncodeCBOR(value any) []byte {
+	var head = func(majorType byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{majorType<<5 | byte(n)}
+		case n < 256:
+			return []byte{majorType<<5 | 24, byte(n)}
+		case n < 65536:
+			return []byte{majorType<<5 | 25, byte(n >> 8), byte(n)}
+		}
+		var buf = make([]byte, 5)
+		buf[0] = majorType<<5 | 26
+		binary.BigEndian.PutUint32(buf[1:], uint32(n))
+		return buf
+	}
+
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case int64:
+		return testEncodeCBOR(int(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case []any:
+		var result = head(4, uint64(len(v)))
+		for _, item := range v {
+			result = append(result, testEncodeCBOR(item)...)
+		}
+		return result
+	case map[any]any:
+		var keys = []any{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return string(testEncodeCBOR(keys[i])) < string(testEncodeCBOR(keys[j]))
+		})
+		var result = head(5, uint64(len(v)))
+		for _, key := range keys {
+			result = append(result, testEncodeCBOR(key)...)
+			result = append(result, testEncodeCBOR(v[key])...)
+		}
+		return result
+	}
+	panic("unsupported type")
+}
+
+type testAuthenticator struct {
+	credentialId []byte
+	ecKey        *ecdsa.PrivateKey
+	edKey        ed25519.PrivateKey
+	signCount    uint32
+}
+
+func newTestAuthenticator(t *testing.T, useEd25519 bool) *testAuthenticator {
+	var authenticator = &testAuthenticator{
+		credentialId: make([]byte, 16),
+	}
+	_, _ = rand.Read(authenticator.credentialId)
+	if useEd25519 {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticator.edKey = key
+	} else {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticator.ecKey = key
+	}
+	return authenticator
+}
+
+func (this *testAuthenticator) coseKey() []byte {
+	if this.edKey != nil {
+		return testEncodeCBOR(map[any]any{
+			1:  coseKeyTypeOKP,
+			3:  int(COSEAlgorithmEdDSA),
+			-1: coseCurveEd25519,
+			-2: []byte(this.edKey.Public().(ed25519.PublicKey)),
+		})
+	}
+	return testEncodeCBOR(map[any]any{
+		1:  coseKeyTypeEC2,
+		3:  int(COSEAlgorithmES256),
+		-1: coseCurveP256,
+		-2: this.ecKey.X.FillBytes(make([]byte, 32)),
+		-3: this.ecKey.Y.FillBytes(make([]byte, 32)),
+	})
+}
+
+func (this *testAuthenticator) sign(data []byte) []byte {
+	if this.edKey != nil {
+		return ed25519.Sign(this.edKey, data)
+	}
+	var sum = sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, this.ecKey, sum[:])
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+func (this *testAuthenticator) authData(rpId string, flags byte, attested bool) []byte {
+	var rpIdHash = sha256.Sum256([]byte(rpId))
+	var data = append([]byte{}, rpIdHash[:]...)
+	if attested {
+		flags |= FlagAttestedData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, this.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(this.credentialId)))
+		data = append(data, this.credentialId...)
+		data = append(data, this.coseKey()...)
+	}
+	return data
+}
+
+func testClientData(dataType string, challenge string, origin string) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"type":      dataType,
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	return data
+}
+
+func (this *testAuthenticator) register(rpId string, challenge string, origin string, format string) *RegistrationResponse {
+	var clientDataJSON = testClientData(clientDataTypeCreate, challenge, origin)
+	var authData = this.authData(rpId, FlagUserPresent|FlagUserVerified, true)
+	var attStmt = map[any]any{}
+	if format == "packed" {
+		var clientDataHash = sha256.Sum256(clientDataJSON)
+		var alg = COSEAlgorithmES256
+		if this.edKey != nil {
+			alg = COSEAlgorithmEdDSA
+		}
+		attStmt["alg"] = int(alg)
+		attStmt["sig"] = this.sign(append(append([]byte{}, authData...), clientDataHash[:]...))
+	}
+	return &RegistrationResponse{
+		ClientDataJSON: clientDataJSON,
+		AttestationObject: testEncodeCBOR(map[any]any{
+			"fmt":      format,
+			"attStmt":  attStmt,
+			"authData": authData,
+		}),
+	}
+}
+
+func (this *testAuthenticator) assert(rpId string, challenge string, origin string) *AssertionResponse {
+	this.signCount++
+	var clientDataJSON = testClientData(clientDataTypeGet, challenge, origin)
+	var authData = this.authData(rpId, FlagUserPresent, false)
+	var clientDataHash = sha256.Sum256(clientDataJSON)
+	return &AssertionResponse{
+		CredentialId:      this.credentialId,
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authData,
+		Signature:         this.sign(append(append([]byte{}, authData...), clientDataHash[:]...)),
+	}
+}
+
+func TestRelyingParty_Flow(t *testing.T) {
+	var rp = NewRelyingParty("example.com", "", nil)
+
+	for _, useEd25519 := range []bool{false, true} {
+		for _, format := range []string{"none", "packed"} {
+			var authenticator = newTestAuthenticator(t, useEd25519)
+
+			challenge, err := NewChallenge()
+			if err != nil {
+				t.Fatal(err)
+			}
+			credential, err := rp.VerifyRegistration(challenge, authenticator.register("example.com", challenge, "https://admin.example.com", format), true)
+			if err != nil {
+				t.Fatal(format, err)
+			}
+			if string(credential.Id) != string(authenticator.credentialId) {
+				t.Fatal("credential id mismatch")
+			}
+
+			// 登录
+			challenge, _ = NewChallenge()
+			signCount, err := rp.VerifyAssertion(challenge, credential.PublicKey, credential.SignCount, authenticator.assert("example.com", challenge, "https://example.com"), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signCount != 1 {
+				t.Fatal("unexpected sign count:", signCount)
+			}
+
+			// 需要用户验证
+			challenge, _ = NewChallenge()
+			_, err = rp.VerifyAssertion(challenge, credential.PublicKey, signCount, authenticator.assert("example.com", challenge, "https://example.com"), true)
+			if err == nil {
+				t.Fatal("should require user verification")
+			}
+
+			// 计数器回退
+			challenge, _ = NewChallenge()
+			_, err = rp.VerifyAssertion(challenge, credential.PublicKey, 100, authenticator.assert("example.com", challenge, "https://example.com"), false)
+			if !errors.Is(err, ErrSignCountRegression) {
+				t.Fatal("should be sign count regression, but got:", err)
+			}
+		}
+	}
+}
+
+func TestRelyingParty_Reject(t *testing.T) {
+	var rp = NewRelyingParty("example.com", "", nil)
+	var authenticator = newTestAuthenticator(t, false)
+	challenge, _ := NewChallenge()
+
+	credential, err := rp.VerifyRegistration(challenge, authenticator.register("example.com", challenge, "https://example.com", "none"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 挑战码不一致
+	_, err = rp.VerifyAssertion(challenge, credential.PublicKey, 0, authenticator.assert("example.com", "other", "https://example.com"), false)
+	if err == nil {
+		t.Fatal("challenge should mismatch")
+	}
+
+	// 来源不合法
+	for _, origin := range []string{"https://example.org", "https://badexample.com", "http://example.com", ""} {
+		_, err = rp.VerifyAssertion(challenge, credential.PublicKey, 0, authenticator.assert("example.com", challenge, origin), false)
+		if err == nil {
+			t.Fatal("origin '" + origin + "' should not be allowed")
+		}
+	}
+
+	// RP ID不一致
+	_, err = rp.VerifyAssertion(challenge, credential.PublicKey, 0, authenticator.assert("example.org", challenge, "https://example.com"), false)
+	if err == nil {
+		t.Fatal("rp id should mismatch")
+	}
+
+	// 使用其他密钥签名
+	var otherAuthenticator = newTestAuthenticator(t, false)
+	_, err = rp.VerifyAssertion(challenge, credential.PublicKey, 0, otherAuthenticator.assert("example.com", challenge, "https://example.com"), false)
+	if err == nil {
+		t.Fatal("signature should be invalid")
+	}
+
+	// 指定来源
+	rp = NewRelyingParty("localhost", "", []string{"http://localhost:7788"})
+	challenge, _ = NewChallenge()
+	_, err = rp.VerifyRegistration(challenge, authenticator.register("localhost", challenge, "http://localhost:7788", "none"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rp.VerifyRegistration(challenge, authenticator.register("localhost", challenge, "http://localhost:7789", "none"), false)
+	if err == nil {
+		t.Fatal("origin should not be allowed")
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	value, rest, err := decodeCBOR(testEncodeCBOR(map[any]any{
+		"a": []any{1, -2, "x", []byte{1, 2}},
+		3:   1000000,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 0 {
+		t.Fatal("rest should be empty")
+	}
+	var m = value.(map[any]any)
+	if m[int64(3)].(int64) != 1000000 {
+		t.Fatal("unexpected value:", m[int64(3)])
+	}
+	var items = m["a"].([]any)
+	if items[1].(int64) != -2 || items[2].(string) != "x" || len(items[3].([]byte)) != 2 {
+		t.Fatal("unexpected items:", items)
+	}
+
+	// 不完整的数据
+	for _, data := range [][]byte{
+		{},
+		{0x42, 0x01},
+		{0x9a, 0xff, 0xff, 0xff, 0xff},
+		{0x5f},
+	} {
+		_, _, err = decodeCBOR(data)
+		if err == nil {
+			t.Fatal("should fail:", data)
+		}
+	}
+}